@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 
+	"github.com/root-gg/plik/client/archive/native"
 	"github.com/root-gg/plik/client/archive/tar"
 	"github.com/root-gg/plik/client/archive/zip"
 )
@@ -26,6 +27,10 @@ func NewArchiveBackend(name string, config map[string]interface{}) (backend Back
 		backend, err = tar.NewTarBackend(config)
 	case "zip":
 		backend, err = zip.NewZipBackend(config)
+	case "gotar":
+		backend, err = native.NewTarBackend(config)
+	case "gozip":
+		backend, err = native.NewZipBackend(config)
 	default:
 		err = errors.New("Invalid archive backend")
 	}
@@ -0,0 +1,49 @@
+package native
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/root-gg/utils"
+)
+
+// BackendConfig object
+type BackendConfig struct {
+	Compress string // Compression codec of tar archives : gzip|zstd|xz|no
+	Exclude  string // Comma separated glob patterns of the files to exclude
+}
+
+// NewBackendConfig instantiate a new Backend Configuration
+// from config map passed as argument
+func NewBackendConfig(config map[string]interface{}) (nb *BackendConfig) {
+	nb = new(BackendConfig)
+	nb.Compress = "gzip"
+	utils.Assign(nb, config)
+	return
+}
+
+// configure override the configuration from command line arguments
+func (nb *BackendConfig) configure(arguments map[string]interface{}) {
+	if arguments["--compress"] != nil && arguments["--compress"].(string) != "" {
+		nb.Compress = arguments["--compress"].(string)
+	}
+	if arguments["--exclude"] != nil && arguments["--exclude"].(string) != "" {
+		nb.Exclude = arguments["--exclude"].(string)
+	}
+}
+
+// patterns return the exclusion glob patterns
+func (nb *BackendConfig) patterns() (patterns []string, err error) {
+	for _, pattern := range strings.Split(nb.Exclude, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err = path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q : %s", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
@@ -0,0 +1,225 @@
+package native
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// archiveTypes map the archive file name extensions to the tar compression codec ( or zip )
+var archiveTypes = []struct {
+	extension string
+	codec     string
+}{
+	{".tar.gz", "gzip"},
+	{".tgz", "gzip"},
+	{".tar.zst", "zstd"},
+	{".tar.xz", "xz"},
+	{".tar", "no"},
+	{".zip", "zip"},
+}
+
+func getArchiveType(name string) (codec string) {
+	name = strings.ToLower(name)
+	for _, t := range archiveTypes {
+		if strings.HasSuffix(name, t.extension) {
+			return t.codec
+		}
+	}
+	return ""
+}
+
+// IsArchive return true if the file name is the name of an archive Extract is able to extract
+func IsArchive(name string) bool {
+	return getArchiveType(name) != ""
+}
+
+// Extract the archive file at path to the directory dir
+// Existing files are never overwritten and entries can't be extracted outside of dir
+// not even through a symlink of the archive. Owners are not restored.
+func Extract(path string, dir string) (err error) {
+	switch codec := getArchiveType(path); codec {
+	case "":
+		return fmt.Errorf("unknown archive type %s", filepath.Base(path))
+	case "zip":
+		return extractZip(path, dir)
+	default:
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return extractTar(f, codec, dir)
+	}
+}
+
+func extractTar(reader io.Reader, codec string, dir string) (err error) {
+	switch codec {
+	case "gzip":
+		reader, err = gzip.NewReader(reader)
+	case "zstd":
+		var decoder *zstd.Decoder
+		decoder, err = zstd.NewReader(reader)
+		if err == nil {
+			defer decoder.Close()
+			reader = decoder
+		}
+	case "xz":
+		reader, err = xz.NewReader(reader)
+	}
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		mode := header.FileInfo().Mode()
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeDir, tar.TypeSymlink:
+			err = extractEntry(dir, header.Name, mode, header.Linkname, tr)
+		case tar.TypeLink:
+			err = extractHardLink(dir, header.Name, header.Linkname)
+		case tar.TypeXGlobalHeader:
+		default:
+			fmt.Fprintf(os.Stderr, "Skipping special file %s\n", header.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(path string, dir string) (err error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		err = extractZipEntry(dir, f)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractZipEntry(dir string, f *zip.File) (err error) {
+	mode := f.Mode()
+	if mode&(fs.ModeType&^(fs.ModeDir|fs.ModeSymlink)) != 0 {
+		fmt.Fprintf(os.Stderr, "Skipping special file %s\n", f.Name)
+		return nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Info-ZIP stores the target of symlinks as content
+	link := ""
+	if mode&fs.ModeSymlink != 0 {
+		target, err := io.ReadAll(io.LimitReader(rc, 4096))
+		if err != nil {
+			return err
+		}
+		link = string(target)
+	}
+
+	return extractEntry(dir, f.Name, mode, link, rc)
+}
+
+// extractEntry create a directory, file or symlink in dir
+func extractEntry(dir string, name string, mode fs.FileMode, link string, content io.Reader) (err error) {
+	target, err := safePath(dir, name)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case mode.IsDir():
+		return os.MkdirAll(target, mode.Perm()|0700)
+	case mode&fs.ModeSymlink != 0:
+		return os.Symlink(link, target)
+	}
+
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, content)
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("unable to extract %s : %s", name, err)
+	}
+
+	return f.Close()
+}
+
+func extractHardLink(dir string, name string, link string) (err error) {
+	target, err := safePath(dir, name)
+	if err != nil {
+		return err
+	}
+
+	source, err := safePath(dir, link)
+	if err != nil {
+		return err
+	}
+
+	return os.Link(source, target)
+}
+
+// safePath return the path in dir where the archive entry has to be extracted
+// Parent directories are created and must not be symlinks
+func safePath(dir string, name string) (target string, err error) {
+	clean := path.Clean(strings.TrimSuffix(name, "/"))
+	if name == "" || path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(name, "\\") {
+		return "", fmt.Errorf("invalid path %s in archive", name)
+	}
+
+	parent := dir
+	elements := strings.Split(clean, "/")
+	for _, element := range elements[:len(elements)-1] {
+		parent = filepath.Join(parent, element)
+
+		info, err := os.Lstat(parent)
+		if os.IsNotExist(err) {
+			err = os.Mkdir(parent, 0755)
+			if err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("invalid path %s in archive, %s is not a directory", name, parent)
+		}
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
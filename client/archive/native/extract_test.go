@@ -0,0 +1,115 @@
+package native
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readTree list the files of a directory like they are listed in an archive
+func readTree(t *testing.T, dir string) (files []testFile) {
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		if p == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		require.NoError(t, err)
+
+		file := testFile{name: filepath.ToSlash(rel), mode: info.Mode()}
+		switch {
+		case info.IsDir():
+			file.name += "/"
+		case info.Mode()&os.ModeSymlink != 0:
+			file.link, err = os.Readlink(p)
+			require.NoError(t, err)
+		default:
+			content, err := os.ReadFile(p)
+			require.NoError(t, err)
+			file.content = string(content)
+		}
+		files = append(files, file)
+		return nil
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func TestExtract(t *testing.T) {
+	dir := createTree(t)
+	files := []string{filepath.Join(dir, "data"), filepath.Join(dir, "single.txt")}
+
+	for _, name := range []string{"archive.tar.gz", "archive.tar.zst", "archive.tar.xz", "archive.tar", "archive.zip"} {
+		t.Run(name, func(t *testing.T) {
+			require.True(t, IsArchive(name), "archive not detected")
+
+			var data []byte
+			if filepath.Ext(name) == ".zip" {
+				zb, err := NewZipBackend(map[string]interface{}{})
+				require.NoError(t, err)
+				data = archive(t, zb, files)
+			} else {
+				tb, err := NewTarBackend(map[string]interface{}{})
+				require.NoError(t, err)
+				require.NoError(t, tb.Configure(map[string]interface{}{"--compress": getArchiveType(name)}))
+				data = archive(t, tb, files)
+			}
+
+			tmp := t.TempDir()
+			path := filepath.Join(tmp, name)
+			require.NoError(t, os.WriteFile(path, data, 0644))
+
+			out := filepath.Join(tmp, "out")
+			require.NoError(t, os.Mkdir(out, 0755))
+			require.NoError(t, Extract(path, out))
+			require.Equal(t, expectedTree(true), readTree(t, out))
+
+			// Existing files are not overwritten
+			require.Error(t, Extract(path, out), "missing error for existing files")
+		})
+	}
+
+	require.False(t, IsArchive("file.txt"), "invalid archive detection")
+	require.Error(t, Extract("file.txt", t.TempDir()), "missing error for unknown archive type")
+}
+
+func writeTestTar(t *testing.T, headers ...*tar.Header) (path string) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, header := range headers {
+		require.NoError(t, tw.WriteHeader(header))
+		if header.Size > 0 {
+			_, err := tw.Write(make([]byte, header.Size))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+
+	path = filepath.Join(t.TempDir(), "archive.tar")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	return path
+}
+
+func TestExtractPathTraversal(t *testing.T) {
+	for _, name := range []string{"../evil", "a/../../evil", "/etc/evil"} {
+		path := writeTestTar(t, &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: 1})
+		require.Error(t, Extract(path, t.TempDir()), "missing error for %s", name)
+	}
+}
+
+func TestExtractSymlinkTraversal(t *testing.T) {
+	outside := t.TempDir()
+	path := writeTestTar(t,
+		&tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: outside, Mode: 0777},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "link/evil", Mode: 0644, Size: 1})
+
+	require.Error(t, Extract(path, t.TempDir()), "missing error for symlink traversal")
+
+	_, err := os.Stat(filepath.Join(outside, "evil"))
+	require.True(t, os.IsNotExist(err), "file extracted outside of the directory")
+}
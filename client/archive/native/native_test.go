@@ -0,0 +1,228 @@
+package native
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+type testFile struct {
+	name    string
+	mode    os.FileMode
+	content string
+	link    string
+}
+
+// createTree create a directory tree to archive in a temporary directory
+func createTree(t *testing.T) (dir string) {
+	dir = t.TempDir()
+
+	write := func(name string, content string, mode os.FileMode) {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), mode))
+		require.NoError(t, os.Chmod(p, mode))
+	}
+
+	write("data/b.txt", "bbb", 0644)
+	write("data/a.txt", "aaa", 0600)
+	write("data/run.sh", "#!/bin/sh", 0755)
+	write("data/sub/c.txt", "ccc", 0644)
+	write("data/debug.log", "log", 0644)
+	write("data/.git/config", "git", 0644)
+	write("single.txt", "single", 0644)
+	require.NoError(t, os.Symlink("a.txt", filepath.Join(dir, "data", "link")))
+
+	return dir
+}
+
+func expectedTree(withExcluded bool) (files []testFile) {
+	files = append(files, testFile{name: "data/", mode: os.ModeDir | 0755})
+	if withExcluded {
+		files = append(files,
+			testFile{name: "data/.git/", mode: os.ModeDir | 0755},
+			testFile{name: "data/.git/config", mode: 0644, content: "git"})
+	}
+	files = append(files,
+		testFile{name: "data/a.txt", mode: 0600, content: "aaa"},
+		testFile{name: "data/b.txt", mode: 0644, content: "bbb"})
+	if withExcluded {
+		files = append(files, testFile{name: "data/debug.log", mode: 0644, content: "log"})
+	}
+	files = append(files,
+		testFile{name: "data/link", mode: os.ModeSymlink | 0777, link: "a.txt"},
+		testFile{name: "data/run.sh", mode: 0755, content: "#!/bin/sh"},
+		testFile{name: "data/sub/", mode: os.ModeDir | 0755},
+		testFile{name: "data/sub/c.txt", mode: 0644, content: "ccc"},
+		testFile{name: "single.txt", mode: 0644, content: "single"})
+	return files
+}
+
+func readTar(t *testing.T, reader io.Reader, codec string) (files []testFile) {
+	var err error
+	switch codec {
+	case "gzip":
+		reader, err = gzip.NewReader(reader)
+	case "zstd":
+		reader, err = zstd.NewReader(reader)
+	case "xz":
+		reader, err = xz.NewReader(reader)
+	}
+	require.NoError(t, err)
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		require.Equal(t, 0, header.Uid)
+		require.Equal(t, 0, header.Gid)
+		require.Equal(t, "", header.Uname)
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files = append(files, testFile{
+			name:    header.Name,
+			mode:    header.FileInfo().Mode(),
+			content: string(content),
+			link:    header.Linkname,
+		})
+	}
+	return files
+}
+
+func readZip(t *testing.T, data []byte) (files []testFile) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+
+		file := testFile{name: f.Name, mode: f.Mode()}
+		if f.Mode()&os.ModeSymlink != 0 {
+			file.link = string(content)
+		} else {
+			file.content = string(content)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+func archive(t *testing.T, backend interface {
+	Archive(files []string) (io.Reader, error)
+}, files []string) []byte {
+	reader, err := backend.Archive(files)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return data
+}
+
+func TestTar(t *testing.T) {
+	dir := createTree(t)
+	files := []string{filepath.Join(dir, "data"), filepath.Join(dir, "single.txt")}
+
+	for _, codec := range []string{"gzip", "zstd", "xz", "no"} {
+		t.Run(codec, func(t *testing.T) {
+			tb, err := NewTarBackend(map[string]interface{}{"Compress": codec})
+			require.NoError(t, err)
+			require.NoError(t, tb.Configure(map[string]interface{}{}))
+
+			data := archive(t, tb, files)
+			require.Equal(t, expectedTree(true), readTar(t, bytes.NewReader(data), codec))
+
+			// Archiving the same tree twice gives the same archive
+			require.Equal(t, data, archive(t, tb, files), "archive is not deterministic")
+		})
+	}
+}
+
+func TestTarExclude(t *testing.T) {
+	dir := createTree(t)
+
+	tb, err := NewTarBackend(map[string]interface{}{"Exclude": "*.log"})
+	require.NoError(t, err)
+	require.NoError(t, tb.Configure(map[string]interface{}{"--exclude": "*.log, data/.git", "--compress": "no"}))
+
+	data := archive(t, tb, []string{filepath.Join(dir, "data"), filepath.Join(dir, "single.txt")})
+	require.Equal(t, expectedTree(false), readTar(t, bytes.NewReader(data), "no"))
+}
+
+func TestTarInvalidConfig(t *testing.T) {
+	tb, err := NewTarBackend(map[string]interface{}{})
+	require.NoError(t, err)
+	require.Error(t, tb.Configure(map[string]interface{}{"--compress": "bzip2"}))
+
+	tb, err = NewTarBackend(map[string]interface{}{"Exclude": "[a-"})
+	require.NoError(t, err)
+	require.Error(t, tb.Configure(map[string]interface{}{}))
+}
+
+func TestTarErrors(t *testing.T) {
+	tb, err := NewTarBackend(map[string]interface{}{})
+	require.NoError(t, err)
+
+	_, err = tb.Archive(nil)
+	require.Error(t, err, "missing error for STDIN")
+
+	reader, err := tb.Archive([]string{filepath.Join(t.TempDir(), "missing")})
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	require.Error(t, err, "missing error for missing file")
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "x"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "b", "x"), 0755))
+	reader, err = tb.Archive([]string{filepath.Join(dir, "a", "x"), filepath.Join(dir, "b", "x")})
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	require.Error(t, err, "missing error for duplicate names")
+}
+
+func TestTarFileName(t *testing.T) {
+	tb, err := NewTarBackend(map[string]interface{}{"Compress": "zstd"})
+	require.NoError(t, err)
+	require.Equal(t, "dir.tar.zst", tb.GetFileName([]string{"/tmp/dir"}))
+	require.Equal(t, "archive.tar.zst", tb.GetFileName([]string{"a", "b"}))
+	require.Equal(t, "tar xvf - --zstd", tb.Comments())
+
+	tb.Config.Compress = "no"
+	require.Equal(t, "archive.tar", tb.GetFileName([]string{"a", "b"}))
+	require.Equal(t, "tar xvf -", tb.Comments())
+}
+
+func TestZip(t *testing.T) {
+	dir := createTree(t)
+	files := []string{filepath.Join(dir, "data"), filepath.Join(dir, "single.txt")}
+
+	zb, err := NewZipBackend(map[string]interface{}{})
+	require.NoError(t, err)
+	require.NoError(t, zb.Configure(map[string]interface{}{}))
+
+	data := archive(t, zb, files)
+	require.Equal(t, expectedTree(true), readZip(t, data))
+	require.Equal(t, data, archive(t, zb, files), "archive is not deterministic")
+
+	require.NoError(t, zb.Configure(map[string]interface{}{"--exclude": "*.log,.git"}))
+	require.Equal(t, expectedTree(false), readZip(t, archive(t, zb, files)))
+
+	require.Equal(t, "data.zip", zb.GetFileName(files[:1]))
+	require.Equal(t, "archive.zip", zb.GetFileName(files))
+}
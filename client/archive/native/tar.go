@@ -0,0 +1,183 @@
+package native
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// TarBackend object
+type TarBackend struct {
+	Config *BackendConfig
+}
+
+// NewTarBackend instantiate a new native Tar Archive Backend
+// and configure it from config map
+func NewTarBackend(config map[string]interface{}) (tb *TarBackend, err error) {
+	tb = new(TarBackend)
+	tb.Config = NewBackendConfig(config)
+	return
+}
+
+// Configure implementation for native TAR Archive Backend
+func (tb *TarBackend) Configure(arguments map[string]interface{}) (err error) {
+	tb.Config.configure(arguments)
+
+	switch tb.Config.Compress {
+	case "gzip", "zstd", "xz", "no":
+	default:
+		return fmt.Errorf("invalid compression codec %s, expected gzip|zstd|xz|no", tb.Config.Compress)
+	}
+
+	_, err = tb.Config.patterns()
+	return err
+}
+
+// Archive implementation for native TAR Archive Backend
+func (tb *TarBackend) Archive(files []string) (reader io.Reader, err error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("unable to make a tar archive from STDIN")
+	}
+
+	patterns, err := tb.Config.patterns()
+	if err != nil {
+		return nil, err
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+
+	go func() {
+		pipeWriter.CloseWithError(tb.write(pipeWriter, files, patterns))
+	}()
+
+	return pipeReader, nil
+}
+
+// write the compressed tar archive of files to writer
+func (tb *TarBackend) write(writer io.Writer, files []string, patterns []string) (err error) {
+	compressor, err := newCompressor(writer, tb.Config.Compress)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(compressor)
+	err = walk(files, patterns, func(e *entry) error {
+		return writeTarEntry(tw, e)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return compressor.Close()
+}
+
+// writeTarEntry add a file to the tar archive
+func writeTarEntry(tw *tar.Writer, e *entry) (err error) {
+	header, err := tar.FileInfoHeader(e.info, e.link)
+	if err != nil {
+		return err
+	}
+
+	// Only keep what is needed to extract the file the same way on any host
+	header.Name = e.name
+	if e.info.IsDir() {
+		header.Name += "/"
+	}
+	header.Uid = 0
+	header.Gid = 0
+	header.Uname = ""
+	header.Gname = ""
+	header.ModTime = e.info.ModTime().Truncate(time.Second)
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Format = tar.FormatUnknown
+
+	err = tw.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if !e.info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(tw, f)
+	if err != nil {
+		return fmt.Errorf("unable to archive %s : %s", e.path, err)
+	}
+
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// newCompressor return a writer compressing the data with the given codec
+func newCompressor(writer io.Writer, codec string) (compressor io.WriteCloser, err error) {
+	switch codec {
+	case "gzip":
+		return gzip.NewWriter(writer), nil
+	case "zstd":
+		return zstd.NewWriter(writer)
+	case "xz":
+		return xz.NewWriter(writer)
+	case "no":
+		return nopWriteCloser{writer}, nil
+	default:
+		return nil, fmt.Errorf("invalid compression codec %s", codec)
+	}
+}
+
+// Comments implementation for native TAR Archive Backend
+func (tb *TarBackend) Comments() string {
+	comment := "tar xvf -"
+	if tb.Config.Compress != "no" {
+		comment += " --" + tb.Config.Compress
+	}
+
+	return comment
+}
+
+// GetConfiguration implementation for native TAR Archive Backend
+func (tb *TarBackend) GetConfiguration() interface{} {
+	return tb.Config
+}
+
+// GetFileName returns the final archive file name
+func (tb *TarBackend) GetFileName(files []string) (name string) {
+	name = "archive"
+	if len(files) == 1 {
+		name = filepath.Base(files[0])
+	}
+	name += ".tar"
+	switch tb.Config.Compress {
+	case "gzip":
+		name += ".gz"
+	case "zstd":
+		name += ".zst"
+	case "xz":
+		name += ".xz"
+	}
+	return
+}
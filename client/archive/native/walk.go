@@ -0,0 +1,99 @@
+package native
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// entry is a file to add to the archive
+type entry struct {
+	path string      // Path on the filesystem
+	name string      // Slash separated path in the archive
+	info fs.FileInfo // Information about the file itself, symlinks are not followed
+	link string      // Target of symlinks
+}
+
+// walk call fn for each file to archive
+//
+// Each file or directory is stored under its base name and directories are walked
+// recursively in lexical order so the same tree always produces the same archive.
+// Symlinks are not followed, files matching any of the exclusion patterns either by
+// base name or by path in the archive are skipped and so are the content of excluded
+// directories. Other special files ( devices, sockets, pipes ) are skipped with a warning.
+func walk(files []string, patterns []string, fn func(e *entry) error) (err error) {
+	roots := make(map[string]string)
+	for _, file := range files {
+		absolute, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		root := filepath.Base(absolute)
+		if root == string(filepath.Separator) || root == "." {
+			return fmt.Errorf("unable to archive %s", file)
+		}
+		if other, ok := roots[root]; ok {
+			return fmt.Errorf("%s and %s would have the same name %s in the archive", other, file, root)
+		}
+		roots[root] = file
+
+		err = filepath.WalkDir(file, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(file, p)
+			if err != nil {
+				return err
+			}
+			name := path.Join(root, filepath.ToSlash(rel))
+
+			if excluded(name, patterns) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			e := &entry{path: p, name: name, info: info}
+			switch mode := info.Mode(); {
+			case mode.IsDir(), mode.IsRegular():
+			case mode&fs.ModeSymlink != 0:
+				e.link, err = os.Readlink(p)
+				if err != nil {
+					return err
+				}
+			default:
+				fmt.Fprintf(os.Stderr, "Skipping special file %s\n", p)
+				return nil
+			}
+
+			return fn(e)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// excluded return true if the base name or the path in the archive matches any of the patterns
+func excluded(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, path.Base(name)); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
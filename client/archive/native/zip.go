@@ -0,0 +1,126 @@
+package native
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ZipBackend object
+type ZipBackend struct {
+	Config *BackendConfig
+}
+
+// NewZipBackend instantiate a new native Zip Archive Backend
+// and configure it from config map
+func NewZipBackend(config map[string]interface{}) (zb *ZipBackend, err error) {
+	zb = new(ZipBackend)
+	zb.Config = NewBackendConfig(config)
+	return
+}
+
+// Configure implementation for native ZIP Archive Backend
+func (zb *ZipBackend) Configure(arguments map[string]interface{}) (err error) {
+	zb.Config.configure(arguments)
+	_, err = zb.Config.patterns()
+	return err
+}
+
+// Archive implementation for native ZIP Archive Backend
+func (zb *ZipBackend) Archive(files []string) (reader io.Reader, err error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("unable to make a zip archive from STDIN")
+	}
+
+	patterns, err := zb.Config.patterns()
+	if err != nil {
+		return nil, err
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+
+	go func() {
+		pipeWriter.CloseWithError(zb.write(pipeWriter, files, patterns))
+	}()
+
+	return pipeReader, nil
+}
+
+// write the zip archive of files to writer
+func (zb *ZipBackend) write(writer io.Writer, files []string, patterns []string) (err error) {
+	zw := zip.NewWriter(writer)
+	err = walk(files, patterns, func(e *entry) error {
+		return writeZipEntry(zw, e)
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writeZipEntry add a file to the zip archive
+// Symlinks are stored the way Info-ZIP does, with the target as content
+func writeZipEntry(zw *zip.Writer, e *entry) (err error) {
+	header, err := zip.FileInfoHeader(e.info)
+	if err != nil {
+		return err
+	}
+
+	header.Name = e.name
+	header.Method = zip.Store
+	if e.info.IsDir() {
+		header.Name += "/"
+	} else if e.info.Mode().IsRegular() {
+		header.Method = zip.Deflate
+	}
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if e.link != "" {
+		_, err = io.WriteString(w, e.link)
+		return err
+	}
+
+	if !e.info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	if err != nil {
+		return fmt.Errorf("unable to archive %s : %s", e.path, err)
+	}
+
+	return nil
+}
+
+// Comments implementation for native ZIP Archive Backend
+func (zb *ZipBackend) Comments() string {
+	return ""
+}
+
+// GetConfiguration implementation for native ZIP Archive Backend
+func (zb *ZipBackend) GetConfiguration() interface{} {
+	return zb.Config
+}
+
+// GetFileName returns the final archive file name
+func (zb *ZipBackend) GetFileName(files []string) (name string) {
+	name = "archive"
+	if len(files) == 1 {
+		name = filepath.Base(files[0])
+	}
+	name += ".zip"
+	return
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/root-gg/plik/client/archive/native"
+	"github.com/root-gg/plik/client/crypto"
+	"github.com/root-gg/plik/client/crypto/aes"
+	"github.com/root-gg/plik/plik"
+	"github.com/root-gg/plik/server/common"
+)
+
+// uploadRef identify an upload or a file of an upload passed on the command line
+type uploadRef struct {
+	URL         string // URL of the Plik server
+	uploadID    string
+	uploadToken string // Upload token of an upload admin URL
+	fileID      string
+	key         string // End-to-end encryption key of a file URL
+}
+
+// parseUploadRef parse an upload ID, an upload URL ( http://plik/#/?id=... ) or a file URL ( http://plik/file/... )
+func parseUploadRef(ref string) (r *uploadRef, err error) {
+	r = &uploadRef{URL: config.URL}
+
+	if !strings.Contains(ref, "://") {
+		if ref == "" || strings.ContainsAny(ref, "/?#") {
+			return nil, fmt.Errorf("Invalid upload id %s", ref)
+		}
+		r.uploadID = ref
+		return r, nil
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid URL %s : %s", ref, err)
+	}
+	base := &url.URL{Scheme: u.Scheme, Host: u.Host}
+
+	// File URL
+	for _, mode := range []string{"/file/", "/stream/"} {
+		i := strings.LastIndex(u.Path, mode)
+		if i < 0 {
+			continue
+		}
+
+		parts := strings.Split(u.Path[i+len(mode):], "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid file URL %s", ref)
+		}
+
+		r.uploadID = parts[0]
+		r.fileID = parts[1]
+		r.key = u.Fragment
+		base.Path = u.Path[:i]
+		r.URL = base.String()
+		return r, nil
+	}
+
+	// Upload URL of the web application
+	if i := strings.Index(u.Fragment, "?"); i >= 0 {
+		query, err := url.ParseQuery(u.Fragment[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid URL %s : %s", ref, err)
+		}
+		r.uploadID = query.Get("id")
+		r.uploadToken = query.Get("uploadToken")
+	}
+	if r.uploadID == "" {
+		return nil, fmt.Errorf("Missing upload id in URL %s", ref)
+	}
+
+	base.Path = strings.TrimSuffix(u.Path, "/")
+	r.URL = base.String()
+	return r, nil
+}
+
+// getUpload fetch the metadata of the upload and select the files of the reference
+func getUpload(client *plik.Client, r *uploadRef) (upload *plik.Upload, files []*plik.File, err error) {
+	client.URL = r.URL
+
+	if r.uploadToken != "" {
+		upload, err = client.GetUploadWithUploadToken(r.uploadID, r.uploadToken)
+	} else {
+		upload, err = client.GetUpload(r.uploadID)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to get upload %s : %s", r.uploadID, err)
+	}
+
+	for _, file := range upload.Files() {
+		if r.fileID == "" || file.Metadata().ID == r.fileID {
+			files = append(files, file)
+		}
+	}
+
+	if r.fileID != "" && len(files) == 0 {
+		return nil, nil, fmt.Errorf("File %s not found in upload %s", r.fileID, r.uploadID)
+	}
+
+	return upload, files, nil
+}
+
+// setupCommandClient set the credentials of the client from the configuration
+func setupCommandClient(client *plik.Client) {
+	client.Token = config.Token
+	client.Login = config.Login
+	client.Password = config.Password
+}
+
+// get download the files of the uploads passed as arguments to the current directory
+// End-to-end encrypted files and files encrypted with the aes crypto backend are decrypted
+// and archives are extracted unless --no-extract is set
+func get(client *plik.Client) (err error) {
+	setupCommandClient(client)
+
+	for _, ref := range arguments["URL"].([]string) {
+		r, err := parseUploadRef(ref)
+		if err != nil {
+			return err
+		}
+
+		upload, files, err := getUpload(client, r)
+		if err != nil {
+			return err
+		}
+
+		if upload.E2EE && r.key == "" {
+			return fmt.Errorf("%s : Missing end-to-end encryption key, use the file URL displayed after the upload", ref)
+		}
+
+		for _, file := range files {
+			err = getFile(upload, file, r.key)
+			if err != nil {
+				return fmt.Errorf("%s : %s", file.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// getFile download, decrypt and extract a file
+func getFile(upload *plik.Upload, file *plik.File, key string) (err error) {
+	metadata := file.Metadata()
+	if !upload.Stream && metadata.Status != common.FileUploaded {
+		return fmt.Errorf("File is not available ( %s )", metadata.Status)
+	}
+
+	name := filepath.Base(file.Name)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		return fmt.Errorf("Invalid file name")
+	}
+
+	// Never overwrite an existing file
+	if _, err = os.Lstat(name); err == nil {
+		return fmt.Errorf("%s already exists", name)
+	}
+
+	// Files are downloaded to a temporary file to resume the download if it gets interrupted
+	part := name + ".part"
+	err = downloadFile(file, part)
+	if err != nil {
+		return err
+	}
+
+	err = decryptFile(part, name, key)
+	if err != nil {
+		return err
+	}
+
+	printf("%s\n", name)
+
+	if native.IsArchive(name) && !arguments["--no-extract"].(bool) {
+		err = native.Extract(name, ".")
+		if err != nil {
+			return fmt.Errorf("Unable to extract archive %s : %s", name, err)
+		}
+
+		err = os.Remove(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// downloadFile download a file to path and verify its md5 sum ( the SHA-256 digest is verified by the plik library )
+// If path already exists the download resumes at the end of it
+func downloadFile(file *plik.File, path string) (err error) {
+	metadata := file.Metadata()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to create file : %s", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	hash := md5.New()
+	offset, err := io.Copy(hash, f)
+	if err != nil {
+		return fmt.Errorf("Unable to read file : %s", err)
+	}
+
+	// Nothing left to download if a previous download completed but the file could not be decrypted
+	if offset == 0 || metadata.Size == 0 || offset < metadata.Size {
+		reader, start, err := file.DownloadFrom(offset, io.NewSectionReader(f, 0, offset))
+		if err != nil {
+			return fmt.Errorf("Unable to download file : %s", err)
+		}
+		defer func() { _ = reader.Close() }()
+
+		if start != offset {
+			// The server sends the whole file if it changed
+			hash.Reset()
+			err = f.Truncate(0)
+			if err != nil {
+				return err
+			}
+			_, err = f.Seek(0, io.SeekStart)
+			if err != nil {
+				return err
+			}
+		} else if offset > 0 {
+			printf("Resuming %s at %s\n", file.Name, humanize.IBytes(uint64(offset)))
+		}
+
+		_, err = io.Copy(io.MultiWriter(f, hash), reader)
+		if errors.Is(err, plik.ErrDigestMismatch) {
+			// Resuming would not help if the content already downloaded is corrupted
+			_ = f.Close()
+			_ = os.Remove(path)
+			return fmt.Errorf("Invalid file : %s", err)
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to download file, run the same command to resume : %s", err)
+		}
+	}
+
+	if metadata.Md5 != "" {
+		sum := fmt.Sprintf("%x", hash.Sum(nil))
+		if sum != metadata.Md5 {
+			_ = f.Close()
+			_ = os.Remove(path)
+			return fmt.Errorf("Invalid md5 sum %s, expected %s", sum, metadata.Md5)
+		}
+	}
+
+	return f.Close()
+}
+
+// decryptFile decrypt the downloaded file at part to name
+// Files that are not encrypted by plik are just renamed
+func decryptFile(part string, name string, key string) (err error) {
+	f, err := os.Open(part)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	header := make([]byte, 16)
+	n, _ := io.ReadFull(f, header)
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	switch {
+	case key != "":
+		reader, err = plik.NewE2EEReader(f, key)
+	case aes.IsEncrypted(header[:n]):
+		var backend crypto.DecryptBackend
+		backend, err = newAESDecryptBackend()
+		if err == nil {
+			reader, err = backend.Decrypt(f)
+		}
+	default:
+		_ = f.Close()
+		return os.Rename(part, name)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to decrypt file, the encrypted file is kept in %s : %s", part, err)
+	}
+
+	out, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to create file : %s", err)
+	}
+
+	_, err = io.Copy(out, reader)
+	if err != nil {
+		_ = out.Close()
+		_ = os.Remove(name)
+		return fmt.Errorf("Unable to decrypt file, the encrypted file is kept in %s : %s", part, err)
+	}
+
+	err = out.Close()
+	if err != nil {
+		return fmt.Errorf("Unable to write file : %s", err)
+	}
+
+	_ = f.Close()
+	return os.Remove(part)
+}
+
+// newAESDecryptBackend initialize the aes crypto backend to decrypt the downloaded files
+// The passphrase comes from --passphrase or from the [SecureOptions] section of .plikrc
+func newAESDecryptBackend() (backend crypto.DecryptBackend, err error) {
+	if backend, ok := cryptoBackend.(crypto.DecryptBackend); ok {
+		return backend, nil
+	}
+
+	b, err := crypto.NewCryptoBackend("aes", config.SecureOptions)
+	if err != nil {
+		return nil, err
+	}
+	backend = b.(crypto.DecryptBackend)
+
+	args := make(map[string]interface{})
+	for k, v := range arguments {
+		args[k] = v
+	}
+	args["--decrypt"] = true
+
+	err = backend.Configure(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cryptoBackend = backend
+	return backend, nil
+}
+
+// list display the uploads of the user the token belongs to
+func list(client *plik.Client) (err error) {
+	if config.Token == "" {
+		return fmt.Errorf("A token is required to list your uploads ( --token or Token in ~/.plikrc )")
+	}
+	setupCommandClient(client)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if !config.Quiet {
+		_, _ = fmt.Fprintln(w, "ID\tCREATED\tEXPIRES\tFILES\tSIZE\tCOMMENTS")
+	}
+
+	cursor := ""
+	for {
+		uploads, next, err := client.GetUserUploads(100, cursor)
+		if err != nil {
+			return fmt.Errorf("Unable to get uploads : %s", err)
+		}
+
+		for _, upload := range uploads {
+			metadata := upload.Metadata()
+			if config.Quiet {
+				fmt.Println(metadata.ID)
+				continue
+			}
+
+			var size int64
+			for _, file := range upload.Files() {
+				size += file.Metadata().Size
+			}
+
+			comments := strings.SplitN(strings.TrimSpace(metadata.Comments), "\n", 2)[0]
+			if len(comments) > 40 {
+				comments = comments[:37] + "..."
+			}
+
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", metadata.ID, formatDate(metadata.CreatedAt), formatExpireDate(metadata.ExpireAt),
+				len(upload.Files()), humanize.IBytes(uint64(size)), comments)
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	if !config.Quiet {
+		stats, err := client.GetUserStatistics()
+		if err != nil {
+			return fmt.Errorf("Unable to get statistics : %s", err)
+		}
+		fmt.Printf("\n%d uploads, %d files, %s\n", stats.Uploads, stats.Files, humanize.IBytes(uint64(stats.TotalSize)))
+	}
+
+	return nil
+}
+
+// remove delete the uploads or the files passed as arguments
+func remove(client *plik.Client) (err error) {
+	setupCommandClient(client)
+
+	for _, ref := range arguments["UPLOAD"].([]string) {
+		r, err := parseUploadRef(ref)
+		if err != nil {
+			return err
+		}
+
+		upload, files, err := getUpload(client, r)
+		if err != nil {
+			return err
+		}
+
+		if r.fileID != "" {
+			err = files[0].Delete()
+			if err != nil {
+				return fmt.Errorf("Unable to remove file %s : %s", files[0].Name, err)
+			}
+			printf("File %s removed\n", files[0].Name)
+			continue
+		}
+
+		err = upload.Delete()
+		if err != nil {
+			return fmt.Errorf("Unable to remove upload %s : %s", r.uploadID, err)
+		}
+		printf("Upload %s removed\n", r.uploadID)
+	}
+
+	return nil
+}
+
+// uploadInfo display the metadata of the uploads passed as arguments
+func uploadInfo(client *plik.Client) (err error) {
+	setupCommandClient(client)
+
+	for i, ref := range arguments["UPLOAD"].([]string) {
+		r, err := parseUploadRef(ref)
+		if err != nil {
+			return err
+		}
+
+		upload, files, err := getUpload(client, r)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+
+		metadata := upload.Metadata()
+		uploadURL, err := upload.GetURL()
+		if err != nil {
+			return err
+		}
+
+		var options []string
+		if metadata.OneShot {
+			options = append(options, "oneshot")
+		}
+		if metadata.MaxDownloads > 0 {
+			options = append(options, fmt.Sprintf("max %d downloads", metadata.MaxDownloads))
+		}
+		if metadata.Removable {
+			options = append(options, "removable")
+		}
+		if metadata.Stream {
+			options = append(options, "stream")
+		}
+		if metadata.ExtendTTL {
+			options = append(options, "extend ttl")
+		}
+		if metadata.ProtectedByPassword {
+			options = append(options, "password protected")
+		}
+		if metadata.E2EE {
+			options = append(options, "end-to-end encrypted")
+		}
+
+		fmt.Printf("Upload   : %s\n", metadata.ID)
+		fmt.Printf("URL      : %s\n", uploadURL)
+		fmt.Printf("Created  : %s\n", formatDate(metadata.CreatedAt))
+		fmt.Printf("Expires  : %s\n", formatExpireDate(metadata.ExpireAt))
+		if len(options) > 0 {
+			fmt.Printf("Options  : %s\n", strings.Join(options, ", "))
+		}
+		if metadata.Comments != "" {
+			fmt.Printf("Comments : %s\n", metadata.Comments)
+		}
+
+		fmt.Printf("Files    :\n")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, file := range files {
+			fileMetadata := file.Metadata()
+			fileURL, err := file.GetURL()
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(w, "    %s\t%s\t%s\t%s\n", fileMetadata.Name, humanize.IBytes(uint64(fileMetadata.Size)), fileMetadata.Status, fileURL)
+		}
+		err = w.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatDate(date time.Time) string {
+	return date.Local().Format("2006-01-02 15:04")
+}
+
+func formatExpireDate(date *time.Time) string {
+	if date == nil {
+		return "never"
+	}
+	return formatDate(*date)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/docopt/docopt-go"
+	"github.com/dustin/go-humanize"
 	"github.com/mitchellh/go-homedir"
 
 	"github.com/root-gg/plik/plik"
@@ -23,10 +24,12 @@ type CliConfig struct {
 	URL            string
 	OneShot        bool
 	Removable      bool
+	MaxDownloads   int
 	Stream         bool
 	Secure         bool
 	SecureMethod   string
 	SecureOptions  map[string]interface{}
+	E2EE           bool
 	Archive        bool
 	ArchiveMethod  string
 	ArchiveOptions map[string]interface{}
@@ -40,8 +43,12 @@ type CliConfig struct {
 	Token          string
 	DisableStdin   bool
 	Insecure       bool
+	ChunkSize      string
+	Parallel       int
+	LimitRate      string
 
 	filePaths        []string
+	decryptURLs      []string
 	filenameOverride string
 }
 
@@ -60,6 +67,7 @@ func NewUploadConfig() (config *CliConfig) {
 	config.SecureOptions["Cipher"] = "aes-256-cbc"
 	config.SecureOptions["Options"] = "-md sha512 -pbkdf2 -iter 120000"
 	config.DownloadBinary = "curl"
+	config.ChunkSize = "16MB"
 	return
 }
 
@@ -244,6 +252,12 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 		return fmt.Errorf("No files specified")
 	}
 
+	// Files to decrypt are URLs
+	if opts["--decrypt"].(bool) {
+		config.filePaths = nil
+		config.decryptURLs = opts["FILE"].([]string)
+	}
+
 	for _, path := range config.filePaths {
 		// Test if file exists
 		fileInfo, err := os.Stat(path)
@@ -274,6 +288,14 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 		config.Stream = true
 	}
 
+	if opts["--max-downloads"] != nil && opts["--max-downloads"].(string) != "" {
+		maxDownloads, err := strconv.Atoi(opts["--max-downloads"].(string))
+		if err != nil || maxDownloads < 0 {
+			return fmt.Errorf("Invalid max downloads %s", opts["--max-downloads"].(string))
+		}
+		config.MaxDownloads = maxDownloads
+	}
+
 	if opts["--comments"] != nil && opts["--comments"].(string) != "" {
 		config.Comments = opts["--comments"].(string)
 	}
@@ -322,6 +344,14 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 		}
 	}
 
+	// Enable end-to-end encryption ?
+	if opts["--e2ee"].(bool) {
+		config.E2EE = true
+	}
+	if config.E2EE && config.Secure && !opts["--decrypt"].(bool) {
+		return fmt.Errorf("End-to-end encryption can't be combined with a crypto backend ( use --not-secure )")
+	}
+
 	// Enable password protection ?
 	if opts["-p"].(bool) {
 		fmt.Printf("Login [plik]: ")
@@ -372,5 +402,60 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 		config.DisableStdin = false
 	}
 
+	// Override resumable upload chunk size ?
+	if opts["--chunk-size"] != nil && opts["--chunk-size"].(string) != "" {
+		config.ChunkSize = opts["--chunk-size"].(string)
+	}
+
+	if _, err := config.GetChunkSize(); err != nil {
+		return err
+	}
+
+	// Override the number of files uploaded in parallel ?
+	if opts["--parallel"] != nil && opts["--parallel"].(string) != "" {
+		parallel, err := strconv.Atoi(opts["--parallel"].(string))
+		if err != nil || parallel < 0 {
+			return fmt.Errorf("Invalid parallel file count %s", opts["--parallel"].(string))
+		}
+		config.Parallel = parallel
+	}
+
+	// Override upload bandwidth limit ?
+	if opts["--limit-rate"] != nil && opts["--limit-rate"].(string) != "" {
+		config.LimitRate = opts["--limit-rate"].(string)
+	}
+
+	if _, err := config.GetRateLimit(); err != nil {
+		return err
+	}
+
 	return
 }
+
+// GetChunkSize return the resumable upload chunk size in bytes ( 0 to disable resumable uploads )
+func (config *CliConfig) GetChunkSize() (size int64, err error) {
+	if config.ChunkSize == "" || config.ChunkSize == "0" {
+		return 0, nil
+	}
+
+	chunkSize, err := humanize.ParseBytes(config.ChunkSize)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid chunk size %s : %s", config.ChunkSize, err)
+	}
+
+	return int64(chunkSize), nil
+}
+
+// GetRateLimit return the upload bandwidth limit in bytes per second ( 0 for unlimited )
+func (config *CliConfig) GetRateLimit() (rate int64, err error) {
+	if config.LimitRate == "" || config.LimitRate == "0" {
+		return 0, nil
+	}
+
+	limitRate, err := humanize.ParseBytes(config.LimitRate)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid rate limit %s : %s", config.LimitRate, err)
+	}
+
+	return int64(limitRate), nil
+}
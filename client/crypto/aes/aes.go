@@ -0,0 +1,89 @@
+package aes
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// Backend object
+type Backend struct {
+	Config *Config
+}
+
+// NewAESBackend instantiate a new AES Crypto Backend
+// and configure it from config map
+func NewAESBackend(config map[string]interface{}) (ab *Backend) {
+	ab = new(Backend)
+	ab.Config = NewAESBackendConfig(config)
+	return
+}
+
+// Configure implementation for AES Crypto Backend
+func (ab *Backend) Configure(arguments map[string]interface{}) (err error) {
+	if arguments["--passphrase"] != nil && arguments["--passphrase"].(string) != "" {
+		ab.Config.Passphrase = arguments["--passphrase"].(string)
+		if ab.Config.Passphrase == "-" {
+			fmt.Printf("Please enter a passphrase : ")
+			_, err = fmt.Scanln(&ab.Config.Passphrase)
+			if err != nil {
+				return err
+			}
+		}
+	} else if arguments["--decrypt"] == true {
+		if ab.Config.Passphrase == "" {
+			return fmt.Errorf("No passphrase specified (--passphrase or Passphrase param in section [SecureOptions] of .plikrc)")
+		}
+	} else {
+		ab.Config.Passphrase = common.GenerateRandomID(25)
+		fmt.Println("Passphrase : " + ab.Config.Passphrase)
+	}
+
+	return
+}
+
+// Encrypt implementation for AES Crypto Backend
+func (ab *Backend) Encrypt(in io.Reader) (out io.Reader, err error) {
+	out, pipe := io.Pipe()
+
+	go func() {
+		// The header is written to the pipe so the writer must be created in the goroutine
+		writer, err := NewWriter(pipe, ab.Config.Passphrase)
+		if err != nil {
+			_ = pipe.CloseWithError(err)
+			return
+		}
+
+		_, err = io.Copy(writer, in)
+		if err != nil {
+			_ = pipe.CloseWithError(err)
+			return
+		}
+
+		err = writer.Close()
+		if err != nil {
+			_ = pipe.CloseWithError(err)
+			return
+		}
+
+		_ = pipe.Close()
+	}()
+
+	return out, nil
+}
+
+// Decrypt implementation for AES Crypto Backend
+func (ab *Backend) Decrypt(in io.Reader) (out io.Reader, err error) {
+	return NewReader(in, ab.Config.Passphrase)
+}
+
+// Comments implementation for AES Crypto Backend
+func (ab *Backend) Comments() string {
+	return fmt.Sprintf("plik --decrypt --secure aes --passphrase %s", ab.Config.Passphrase)
+}
+
+// GetConfiguration implementation for AES Crypto Backend
+func (ab *Backend) GetConfiguration() interface{} {
+	return ab.Config
+}
@@ -0,0 +1,18 @@
+package aes
+
+import (
+	"github.com/root-gg/utils"
+)
+
+// Config object
+type Config struct {
+	Passphrase string
+}
+
+// NewAESBackendConfig instantiate a new Backend Configuration
+// from config map passed as argument
+func NewAESBackendConfig(params map[string]interface{}) (config *Config) {
+	config = new(Config)
+	utils.Assign(config, params)
+	return
+}
@@ -0,0 +1,92 @@
+package aes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/root-gg/plik/plik/aead"
+)
+
+// Encrypted stream format :
+//   - Header : magic ( "PLIKAES" + version byte ) followed by a random 16 bytes salt
+//   - The AES-256 key is derived from the passphrase and the salt with Argon2id
+//   - The plaintext is encrypted with AES-GCM in chunks ( see the plik/aead package )
+
+const (
+	saltSize = 16
+
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	keySize       = 32
+)
+
+var magic = []byte("PLIKAES\x01")
+
+// ErrInvalidStream is returned when the encrypted stream can't be decrypted
+var ErrInvalidStream = errors.New("invalid encrypted stream or passphrase")
+
+// IsEncrypted return true if data starts with the header of an encrypted stream
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+func newAEAD(passphrase string, salt []byte) (gcm cipher.AEAD, err error) {
+	key := argon2.IDKey([]byte(passphrase), salt, argon2Time, argon2Memory, argon2Threads, keySize)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// NewWriter write the header to w and return a writer encrypting data with the passphrase
+// Close must be called to write the last chunk
+func NewWriter(w io.Writer, passphrase string) (writer io.WriteCloser, err error) {
+	salt := make([]byte, saltSize)
+	_, err = io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate salt : %s", err)
+	}
+
+	gcm, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(append(append([]byte{}, magic...), salt...))
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.NewWriter(w, gcm), nil
+}
+
+// NewReader read the header from r and return a reader decrypting data with the passphrase
+func NewReader(r io.Reader, passphrase string) (reader io.Reader, err error) {
+	header := make([]byte, len(magic)+saltSize)
+	_, err = io.ReadFull(r, header)
+	if err != nil {
+		return nil, ErrInvalidStream
+	}
+
+	if string(header[:len(magic)]) != string(magic) {
+		return nil, ErrInvalidStream
+	}
+
+	gcm, err := newAEAD(passphrase, header[len(magic):])
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.NewReader(r, gcm, ErrInvalidStream), nil
+}
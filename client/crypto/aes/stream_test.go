@@ -0,0 +1,99 @@
+package aes
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/plik/aead"
+)
+
+func encrypt(t *testing.T, plaintext []byte, passphrase string) []byte {
+	buf := &bytes.Buffer{}
+	writer, err := NewWriter(buf, passphrase)
+	require.NoError(t, err, "unable to create writer")
+
+	_, err = writer.Write(plaintext)
+	require.NoError(t, err, "unable to write")
+
+	err = writer.Close()
+	require.NoError(t, err, "unable to close writer")
+
+	return buf.Bytes()
+}
+
+func decrypt(ciphertext []byte, passphrase string) ([]byte, error) {
+	reader, err := NewReader(bytes.NewReader(ciphertext), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, size := range []int{0, 1, aead.ChunkSize - 1, aead.ChunkSize, aead.ChunkSize + 1, 3 * aead.ChunkSize} {
+		plaintext := make([]byte, size)
+		_, err := rand.Read(plaintext)
+		require.NoError(t, err, "unable to generate data")
+
+		ciphertext := encrypt(t, plaintext, "foobar")
+
+		result, err := decrypt(ciphertext, "foobar")
+		require.NoError(t, err, "unable to decrypt %d bytes", size)
+		require.True(t, bytes.Equal(plaintext, result), "invalid plaintext for %d bytes", size)
+	}
+}
+
+func TestDecryptInvalidPassphrase(t *testing.T) {
+	ciphertext := encrypt(t, []byte("data"), "foobar")
+
+	_, err := decrypt(ciphertext, "invalid")
+	require.Equal(t, ErrInvalidStream, err, "invalid error")
+}
+
+func TestDecryptTruncated(t *testing.T) {
+	plaintext := make([]byte, 2*aead.ChunkSize)
+	ciphertext := encrypt(t, plaintext, "foobar")
+
+	// Remove the last chunk
+	truncated := ciphertext[:len(magic)+saltSize+aead.ChunkSize+16]
+	_, err := decrypt(truncated, "foobar")
+	require.Equal(t, ErrInvalidStream, err, "invalid error")
+
+	_, err = decrypt(ciphertext[:len(ciphertext)-1], "foobar")
+	require.Equal(t, ErrInvalidStream, err, "invalid error")
+
+	_, err = decrypt(append(ciphertext, 0), "foobar")
+	require.Equal(t, ErrInvalidStream, err, "invalid error")
+}
+
+func TestDecryptInvalidHeader(t *testing.T) {
+	_, err := decrypt([]byte("not encrypted"), "foobar")
+	require.Equal(t, ErrInvalidStream, err, "invalid error")
+}
+
+func TestIsEncrypted(t *testing.T) {
+	require.True(t, IsEncrypted(encrypt(t, []byte("data"), "foobar")), "encrypted data not detected")
+	require.False(t, IsEncrypted([]byte("not encrypted")), "invalid encrypted data detection")
+	require.False(t, IsEncrypted(nil), "invalid encrypted data detection")
+}
+
+func TestBackend(t *testing.T) {
+	backend := NewAESBackend(map[string]interface{}{"Passphrase": "foobar"})
+	err := backend.Configure(map[string]interface{}{"--passphrase": "foobar"})
+	require.NoError(t, err, "unable to configure backend")
+
+	reader, err := backend.Encrypt(bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to encrypt")
+
+	reader, err = backend.Decrypt(reader)
+	require.NoError(t, err, "unable to decrypt")
+
+	result, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read")
+	require.Equal(t, "data", string(result), "invalid plaintext")
+	require.Contains(t, backend.Comments(), "plik --decrypt --secure aes --passphrase foobar", "invalid comments")
+}
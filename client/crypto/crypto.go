@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 
+	"github.com/root-gg/plik/client/crypto/aes"
 	"github.com/root-gg/plik/client/crypto/openssl"
 	"github.com/root-gg/plik/client/crypto/pgp"
 )
@@ -17,6 +18,13 @@ type Backend interface {
 	GetConfiguration() interface{}
 }
 
+// DecryptBackend interface describe crypto backends
+// able to decrypt the files with plik --decrypt
+type DecryptBackend interface {
+	Backend
+	Decrypt(in io.Reader) (out io.Reader, err error)
+}
+
 // NewCryptoBackend instantiate the wanted archive backend with the name provided in configuration file
 // We are passing its configuration found in .plikrc file or arguments
 func NewCryptoBackend(name string, config map[string]interface{}) (backend Backend, err error) {
@@ -25,6 +33,8 @@ func NewCryptoBackend(name string, config map[string]interface{}) (backend Backe
 		backend = openssl.NewOpenSSLBackend(config)
 	case "pgp":
 		backend = pgp.NewPgpBackend(config)
+	case "aes":
+		backend = aes.NewAESBackend(config)
 	default:
 		err = errors.New("Invalid crypto backend")
 	}
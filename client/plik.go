@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"runtime"
 	"time"
 
@@ -37,11 +40,24 @@ func main() {
 	usage := `plik
 
 Usage:
+  plik get [options] URL ...
+  plik ls [options]
+  plik rm [options] UPLOAD ...
+  plik info [options] UPLOAD ...
   plik [options] [FILE] ...
 
+Commands:
+  get                       Download, decrypt and extract the files of an upload or file URL ( resume interrupted downloads )
+  ls                        List your uploads ( requires a token )
+  rm                        Remove uploads ( or a single file with a file URL )
+  info                      Show upload details
+
+  UPLOAD can be an upload id, an upload URL, an upload admin URL or a file URL
+
 Options:
   -o, --oneshot             Enable OneShot ( Each file will be deleted on first download )
   -r, --removable           Enable Removable upload ( Each file can be deleted by anyone at any moment )
+  --max-downloads N         Each file will be deleted after N downloads
   -S, --stream              Enable Streaming ( It will block until remote user starts downloading )
   -t, --ttl TTL             Time before expiration (Upload will be removed in m|h|d)
   --extend-ttl              Extend upload expiration date by TTL when accessed
@@ -53,17 +69,27 @@ Options:
   -p                        Protect the upload with login and password ( be prompted )
   --password PASSWD         Protect the upload with "login:password" ( if omitted default login is "plik" )
   -a                        Archive upload using default archive params ( see ~/.plikrc )
-  --archive MODE            Archive upload using the specified archive backend : tar|zip
+  --archive MODE            Archive upload using the specified archive backend : tar|zip|gotar|gozip
   --compress MODE           [tar] Compression codec : gzip|bzip2|xz|lzip|lzma|lzop|compress|no
+                            [gotar] Compression codec : gzip|zstd|xz|no
   --archive-options OPTIONS [tar|zip] Additional command line options
+  --exclude PATTERNS        [gotar|gozip] Comma separated glob patterns of the files to exclude
   -s                        Encrypt upload using the default encryption parameters ( see ~/.plikrc )
   --not-secure              Do not encrypt upload files regardless of the ~/.plikrc configurations
-  --secure MODE             Encrypt upload files using the specified crypto backend : openssl|pgp
+  --secure MODE             Encrypt upload files using the specified crypto backend : openssl|pgp|aes
   --cipher CIPHER           [openssl] Openssl cipher to use ( see openssl help )
-  --passphrase PASSPHRASE   [openssl] Passphrase or '-' to be prompted for a passphrase
+  --passphrase PASSPHRASE   [openssl|aes] Passphrase or '-' to be prompted for a passphrase
   --recipient RECIPIENT     [pgp] Set recipient for pgp backend ( example : --recipient Bob )
   --secure-options OPTIONS  [openssl|pgp] Additional command line options
+  --e2ee                    Encrypt upload files with a random key only present in the download URLs
+  --no-extract              [get] Do not extract the downloaded archives
+  --decrypt                 [aes|e2ee] Download and decrypt the files at the URLs passed as FILE ( or STDIN to STDOUT )
   --insecure                (TLS) Do not verify the server's certificate chain and hostname
+  --chunk-size SIZE         Upload files by chunks to resume after network errors ( 0 to disable )
+  --parallel N              Upload at most N files at the same time ( 0 for unlimited )
+  --limit-rate RATE         Limit the upload bandwidth to RATE bytes per second ( example : 500KB )
+                            Files are sent again after network or server errors, files read from STDIN, archived
+                            or encrypted only as long as none of their data has been sent
   --update                  Update client
   -q --quiet                Enable quiet mode
   -d --debug                Enable debug mode
@@ -109,6 +135,21 @@ Options:
 		client.Insecure()
 	}
 
+	// Resumable uploads
+	client.ChunkSize, err = config.GetChunkSize()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	// Parallel and bandwidth limited uploads
+	client.Uploader.MaxParallelFiles = config.Parallel
+	client.Uploader.RateLimit, err = config.GetRateLimit()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
 	// Display info
 	if arguments["--info"].(bool) {
 		err = info(client)
@@ -119,6 +160,29 @@ Options:
 		os.Exit(0)
 	}
 
+	// Download, list and manage uploads
+	commands := map[string]func(client *plik.Client) error{"get": get, "ls": list, "rm": remove, "info": uploadInfo}
+	for name, command := range commands {
+		if arguments[name].(bool) {
+			err = command(client)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+	}
+
+	// Download and decrypt files
+	if arguments["--decrypt"].(bool) {
+		err = decrypt(client)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Update
 	updateFlag := arguments["--update"].(bool)
 	err = update(client, updateFlag)
@@ -158,9 +222,11 @@ Options:
 	upload.Stream = config.Stream
 	upload.OneShot = config.OneShot
 	upload.Removable = config.Removable
+	upload.MaxDownloads = config.MaxDownloads
 	upload.Comments = config.Comments
 	upload.Login = config.Login
 	upload.Password = config.Password
+	upload.E2EE = config.E2EE
 
 	if len(config.filePaths) == 0 {
 		if config.DisableStdin {
@@ -342,7 +408,170 @@ func info(client *plik.Client) (err error) {
 	return nil
 }
 
+// decrypt download and decrypt the files at the URLs passed as arguments
+// or decrypt STDIN to STDOUT if there is none
+// End-to-end encrypted files are decrypted with the key from the URL fragment
+func decrypt(client *plik.Client) (err error) {
+	if len(config.decryptURLs) == 0 {
+		decryptBackend, err := newDecryptBackend()
+		if err != nil {
+			return err
+		}
+
+		reader, err := decryptBackend.Decrypt(bufio.NewReader(os.Stdin))
+		if err != nil {
+			return fmt.Errorf("Unable to decrypt STDIN : %s", err)
+		}
+
+		_, err = io.Copy(os.Stdout, reader)
+		if err != nil {
+			return fmt.Errorf("Unable to decrypt STDIN : %s", err)
+		}
+
+		return nil
+	}
+
+	// The crypto backend is only needed for the files that are not end-to-end encrypted
+	var decryptBackend crypto.DecryptBackend
+	for _, URL := range config.decryptURLs {
+		if getE2EEKey(URL) == "" {
+			decryptBackend, err = newDecryptBackend()
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	for _, URL := range config.decryptURLs {
+		err = decryptURL(client, decryptBackend, URL)
+		if err != nil {
+			return fmt.Errorf("%s : %s", URL, err)
+		}
+	}
+
+	return nil
+}
+
+// getE2EEKey return the end-to-end encryption key from the URL fragment
+func getE2EEKey(URL string) string {
+	fileURL, err := url.Parse(URL)
+	if err != nil {
+		return ""
+	}
+	return fileURL.Fragment
+}
+
+// newDecryptBackend initialize the crypto backend to decrypt the files with
+func newDecryptBackend() (decryptBackend crypto.DecryptBackend, err error) {
+	if !config.Secure {
+		return nil, fmt.Errorf("No crypto backend specified (--secure aes) and no end-to-end encryption key in URL")
+	}
+
+	backend, err := crypto.NewCryptoBackend(config.SecureMethod, config.SecureOptions)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to initialize crypto backend : %s", err)
+	}
+
+	decryptBackend, ok := backend.(crypto.DecryptBackend)
+	if !ok {
+		return nil, fmt.Errorf("Crypto backend %s is unable to decrypt files, use the download command displayed after the upload", config.SecureMethod)
+	}
+
+	if len(config.decryptURLs) == 0 && arguments["--passphrase"] == "-" {
+		return nil, fmt.Errorf("Unable to prompt for a passphrase when decrypting STDIN")
+	}
+
+	err = decryptBackend.Configure(arguments)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to configure crypto backend : %s", err)
+	}
+
+	return decryptBackend, nil
+}
+
+// decryptURL download and decrypt a file to the current directory
+func decryptURL(client *plik.Client, backend crypto.DecryptBackend, URL string) (err error) {
+	fileURL, err := url.Parse(URL)
+	if err != nil {
+		return fmt.Errorf("Invalid URL : %s", err)
+	}
+
+	// The end-to-end encryption key must not be sent to the server
+	key := getE2EEKey(URL)
+	fileURL.Fragment = ""
+
+	name := path.Base(fileURL.Path)
+	if name == "/" || name == "." {
+		return fmt.Errorf("Missing file name in URL")
+	}
+
+	req, err := http.NewRequest("GET", fileURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if config.Login != "" || config.Password != "" {
+		req.SetBasicAuth(config.Login, config.Password)
+	}
+
+	resp, err := client.MakeRequest(req)
+	if err != nil {
+		return fmt.Errorf("Unable to download file : %s", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var reader io.Reader
+	if key != "" {
+		reader, err = plik.NewE2EEReader(resp.Body, key)
+	} else {
+		reader, err = backend.Decrypt(resp.Body)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to decrypt file : %s", err)
+	}
+
+	// Never overwrite an existing file
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to create file : %s", err)
+	}
+
+	_, err = io.Copy(file, reader)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(name)
+		return fmt.Errorf("Unable to decrypt file : %s", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("Unable to write file : %s", err)
+	}
+
+	printf("%s\n", name)
+	return nil
+}
+
 func getFileCommand(file *plik.File) (command string, err error) {
+	URL, err := file.GetURL()
+	if err != nil {
+		return "", err
+	}
+
+	// End-to-end encrypted files are downloaded with plik --decrypt, the key is in the URL
+	if config.E2EE {
+		return fmt.Sprintf(`plik --decrypt "%s"`, URL), nil
+	}
+
+	// Crypto backends able to decrypt files download them with plik --decrypt
+	// unless the archive has to be extracted
+	if _, ok := cryptoBackend.(crypto.DecryptBackend); ok && config.Secure {
+		if !config.Archive || isZipArchive() {
+			return fmt.Sprintf(`%s "%s"`, cryptoBackend.Comments(), URL), nil
+		}
+	}
+
 	// Step one - Downloading file
 	switch config.DownloadBinary {
 	case "wget":
@@ -353,10 +582,6 @@ func getFileCommand(file *plik.File) (command string, err error) {
 		command += config.DownloadBinary
 	}
 
-	URL, err := file.GetURL()
-	if err != nil {
-		return "", err
-	}
 	command += fmt.Sprintf(` "%s"`, URL)
 
 	// If Ssl
@@ -366,7 +591,7 @@ func getFileCommand(file *plik.File) (command string, err error) {
 
 	// If archive
 	if config.Archive {
-		if config.ArchiveMethod == "zip" {
+		if isZipArchive() {
 			command += fmt.Sprintf(` > '%s'`, file.Name)
 		} else {
 			command += fmt.Sprintf(" | %s", archiveBackend.Comments())
@@ -378,6 +603,11 @@ func getFileCommand(file *plik.File) (command string, err error) {
 	return
 }
 
+// isZipArchive return true if the archive can't be extracted from a stream
+func isZipArchive() bool {
+	return config.ArchiveMethod == "zip" || config.ArchiveMethod == "gozip"
+}
+
 func printf(format string, args ...interface{}) {
 	if !config.Quiet {
 		fmt.Printf(format, args...)
@@ -57,7 +57,7 @@ func (p *Progress) register(file *plik.File) {
 	p.mu.Unlock()
 
 	file.WrapReader(func(fileReader io.ReadCloser) io.ReadCloser {
-		return io.NopCloser(io.TeeReader(fileReader, bar))
+		return &progressReader{reader: fileReader, bar: bar}
 	})
 
 	file.RegisterUploadCallback(func(metadata *common.File, err error) {
@@ -88,3 +88,36 @@ func (p *Progress) stop() {
 		p.pool.Stop()
 	}
 }
+
+// progressReader display the data read from the file in its progress bar
+// It can seek if the file reader can so the file can be sent again after a failure
+type progressReader struct {
+	reader io.ReadCloser
+	bar    *pb.ProgressBar
+}
+
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.bar.Add64(int64(n))
+	return n, err
+}
+
+// Seek move the progress bar back to the new position
+func (r *progressReader) Seek(offset int64, whence int) (position int64, err error) {
+	seeker, ok := r.reader.(io.Seeker)
+	if !ok {
+		return 0, errors.New("file reader is not seekable")
+	}
+
+	position, err = seeker.Seek(offset, whence)
+	if err != nil {
+		return position, err
+	}
+
+	r.bar.Set64(position)
+	return position, nil
+}
+
+func (r *progressReader) Close() error {
+	return r.reader.Close()
+}
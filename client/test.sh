@@ -464,6 +464,26 @@ upload --not-secure && download && check
 grep '^curl ' $CLIENT_LOG | grep -v '|' >/dev/null 2>/dev/null
 echo "OK"
 
+#---------------------------------------------
+
+echo -n " - parallel : "
+
+before
+for i in $(seq 1 5); do cp $SPECIMEN $TMPDIR/upload/FILE$i; done
+upload --parallel 2 && download && check
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - limit rate : "
+
+before
+head -c 200000 /dev/urandom > $TMPDIR/upload/FILE1
+START=$(date +%s%N)
+upload --limit-rate 100KB && download && check
+test $(( ($(date +%s%N) - START) / 1000000 )) -ge 1000
+echo "OK"
+
 ###
 # Tar archive
 ###
@@ -612,6 +632,57 @@ rm foobar.zip
 check
 echo "OK"
 
+###
+# Native archive
+###
+
+echo -n " - gotar directory : "
+before
+mkdir $TMPDIR/upload/DIR
+cp $SPECIMEN $TMPDIR/upload/DIR/FILE1
+cp $SPECIMEN $TMPDIR/upload/DIR/FILE2
+upload --archive gotar && download && check
+grep 'DIR.tar.gz' $CLIENT_LOG >/dev/null 2>/dev/null
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - gotar custom compression codec : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --archive gotar --compress xz && download && check
+grep 'FILE1.tar.xz' $CLIENT_LOG >/dev/null 2>/dev/null
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - gotar exclude : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+cp $SPECIMEN $TMPDIR/upload/EXCLUDE
+upload --archive gotar --exclude EXCLUDE
+rm $TMPDIR/upload/EXCLUDE
+download && check
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - gozip directory : "
+before
+mkdir $TMPDIR/upload/DIR
+cp $SPECIMEN $TMPDIR/upload/DIR/FILE1
+cp $SPECIMEN $TMPDIR/upload/DIR/FILE2
+upload --archive gozip && download
+# Unzip manually
+cd $TMPDIR/download
+test -f DIR.zip || exit 1
+unzip DIR.zip >/dev/null 2>/dev/null
+rm DIR.zip
+check
+echo "OK"
+
+#---------------------------------------------
+
 ###
 # Openssl
 ###
@@ -661,6 +732,99 @@ curl $(cat $CLIENT_LOG | grep "curl" | sed -n 's/^.*"\(.*\)".*$/\1/p') >$TMPDIR/
 file $TMPDIR/download/ARMORED | grep "ASCII text\|base64" >/dev/null 2>/dev/null
 echo "OK"
 
+###
+# AES
+###
+
+# Download files by running the output plik --decrypt cmds
+function decrypt {
+    cd $TMPDIR/download
+    local COMMANDS=$(cat $CLIENT_LOG | grep "plik --decrypt" | sed "s#^plik #$CLIENT #;s#| plik #| $CLIENT #")
+    local IFS='\n'
+    for COMMAND in "$COMMANDS"
+    do
+        eval "$COMMAND" >/dev/null 2>/dev/null
+    done
+}
+
+echo -n " - aes auto passphrase : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --secure aes && decrypt && check
+grep 'Passphrase' $CLIENT_LOG >/dev/null 2>/dev/null
+grep 'plik --decrypt --secure aes --passphrase' $CLIENT_LOG >/dev/null 2>/dev/null
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - aes custom passphrase : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --secure aes --passphrase foobar && decrypt && check
+grep 'plik --decrypt.*foobar' $CLIENT_LOG >/dev/null 2>/dev/null
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - aes tar archive : "
+before
+mkdir $TMPDIR/upload/DIR
+cp $SPECIMEN $TMPDIR/upload/DIR/FILE1
+upload --secure aes --passphrase foobar && decrypt && check
+grep 'plik --decrypt.*| tar' $CLIENT_LOG >/dev/null 2>/dev/null
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - aes decrypt stdin : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --secure aes --passphrase foobar
+curl -s $(cat $CLIENT_LOG | grep "plik --decrypt" | sed -n 's/^.*"\(.*\)".*$/\1/p') | $CLIENT --decrypt --secure aes --passphrase foobar >$TMPDIR/download/FILE1
+check
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - aes invalid passphrase : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --secure aes --passphrase foobar
+cd $TMPDIR/download
+$CLIENT --decrypt --secure aes --passphrase invalid $(cat $CLIENT_LOG | grep "plik --decrypt" | sed -n 's/^.*"\(.*\)".*$/\1/p') >$CLIENT_LOG 2>&1 && exit 1
+grep 'invalid encrypted stream or passphrase' $CLIENT_LOG >/dev/null 2>/dev/null
+test ! -f $TMPDIR/download/FILE1
+echo "OK"
+
+###
+# E2EE
+###
+
+echo -n " - e2ee : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --e2ee && decrypt && check
+grep 'plik --decrypt ".*#.*"' $CLIENT_LOG >/dev/null 2>/dev/null
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - e2ee server only has ciphertext : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --e2ee
+curl -s $(cat $CLIENT_LOG | grep "plik --decrypt" | sed -n 's/^.*"\(.*\)#.*".*$/\1/p') | head -c 7 | grep PLIKE2E >/dev/null 2>/dev/null
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - e2ee with crypto backend : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --e2ee --secure aes && exit 1
+grep "can't be combined" $CLIENT_LOG >/dev/null 2>/dev/null
+echo "OK"
+
 ###
 # PGP
 ###
@@ -723,6 +887,65 @@ upload --debug --secure pgp --recipient 'plik.root.gg'
 download && check
 echo "OK"
 
+###
+# Commands
+###
+
+function fileURL {
+    grep -o 'http[^"]*/file/[^"]*' $CLIENT_LOG | head -n 1
+}
+
+echo -n " - get : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload
+FILE_URL=$( fileURL )
+cd $TMPDIR/download
+$CLIENT get "$FILE_URL" >$CLIENT_LOG 2>&1
+check
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - get resume : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload
+FILE_URL=$( fileURL )
+cd $TMPDIR/download
+head -c 1000 $SPECIMEN > FILE1.part
+$CLIENT get "$FILE_URL" >$CLIENT_LOG 2>&1
+grep 'Resuming FILE1' $CLIENT_LOG >/dev/null 2>/dev/null
+check
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - get archive : "
+before
+mkdir $TMPDIR/upload/DIR
+cp $SPECIMEN $TMPDIR/upload/DIR/FILE1
+cp $SPECIMEN $TMPDIR/upload/DIR/FILE2
+upload --archive gotar
+FILE_URL=$( fileURL )
+cd $TMPDIR/download
+$CLIENT get "$FILE_URL" >$CLIENT_LOG 2>&1
+check
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - info : "
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --comments foobar && uploadOpts
+$CLIENT info "$UPLOAD_ID" >$CLIENT_LOG 2>&1
+grep 'Comments : foobar' $CLIENT_LOG >/dev/null 2>/dev/null
+grep 'FILE1' $CLIENT_LOG >/dev/null 2>/dev/null
+echo "OK"
+
+#---------------------------------------------
+
 ###
 # UPDATE
 ###
@@ -0,0 +1,210 @@
+package aead
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+)
+
+// Chunked AEAD stream format :
+//   - The plaintext is split in chunks of 64KiB, each chunk is sealed with the AEAD ( AES-GCM )
+//   - The 12 bytes nonce of a chunk is its 11 bytes big endian index followed by a flag set to 1
+//     for the last chunk so that a truncated or reordered stream fails to decrypt
+//   - The last chunk may be empty
+//
+// Each key must only be used to encrypt a single stream as the nonces only depend on the chunk index.
+// The stream header ( magic, salt, ... ) is the responsibility of the caller.
+
+// ChunkSize is the size of a plaintext chunk
+const ChunkSize = 64 * 1024
+
+func chunkNonce(nonce []byte, index uint64, last bool) {
+	for i := range nonce {
+		nonce[i] = 0
+	}
+	binary.BigEndian.PutUint64(nonce[3:11], index)
+	if last {
+		nonce[11] = 1
+	}
+}
+
+// Writer encrypt the data written to it
+// Close must be called to write the last chunk
+type Writer struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	nonce []byte
+	index uint64
+	buf   []byte
+	out   []byte
+}
+
+// NewWriter return a Writer encrypting data to w with the AEAD
+func NewWriter(w io.Writer, aead cipher.AEAD) (writer *Writer) {
+	writer = &Writer{w: w, aead: aead}
+	writer.nonce = make([]byte, aead.NonceSize())
+	writer.buf = make([]byte, 0, ChunkSize)
+	writer.out = make([]byte, 0, ChunkSize+aead.Overhead())
+	return writer
+}
+
+// Write implementation of io.Writer
+// A full chunk is only sealed once more data is written as the last chunk must be flagged
+func (w *Writer) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if len(w.buf) == ChunkSize {
+			err = w.flush(false)
+			if err != nil {
+				return n, err
+			}
+		}
+
+		c := copy(w.buf[len(w.buf):ChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+c]
+		p = p[c:]
+		n += c
+	}
+
+	return n, nil
+}
+
+// Close write the last chunk
+func (w *Writer) Close() (err error) {
+	return w.flush(true)
+}
+
+func (w *Writer) flush(last bool) (err error) {
+	chunkNonce(w.nonce, w.index, last)
+	w.out = w.aead.Seal(w.out[:0], w.nonce, w.buf, nil)
+
+	_, err = w.w.Write(w.out)
+	if err != nil {
+		return err
+	}
+
+	w.buf = w.buf[:0]
+	w.index++
+	return nil
+}
+
+// Encrypter encrypt the data read from the underlying reader
+type Encrypter struct {
+	reader io.Reader
+	writer *Writer
+	buf    []byte
+	out    bytes.Buffer // encrypted data not read yet
+	done   bool
+}
+
+// NewEncrypter return a reader encrypting the data of reader with the AEAD
+func NewEncrypter(reader io.Reader, aead cipher.AEAD) (encrypter *Encrypter) {
+	encrypter = &Encrypter{reader: reader}
+	encrypter.writer = NewWriter(&encrypter.out, aead)
+	encrypter.buf = make([]byte, ChunkSize)
+	return encrypter
+}
+
+// Read implementation of io.Reader
+func (e *Encrypter) Read(p []byte) (n int, err error) {
+	for e.out.Len() == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+
+		n, err = e.reader.Read(e.buf)
+		if n > 0 {
+			// Writing to a bytes.Buffer can't fail
+			_, _ = e.writer.Write(e.buf[:n])
+		}
+		if err == io.EOF {
+			_ = e.writer.Close()
+			e.done = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	return e.out.Read(p)
+}
+
+// Reader decrypt the data read from an encrypted stream
+type Reader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	invalid error
+	nonce   []byte
+	index   uint64
+	in      []byte
+	out     []byte
+	buf     []byte // decrypted data not read yet
+	done    bool
+}
+
+// NewReader return a Reader decrypting the data of r with the AEAD
+// invalid is returned if the stream is truncated, altered or encrypted with another key
+func NewReader(r io.Reader, aead cipher.AEAD, invalid error) (reader *Reader) {
+	reader = &Reader{r: r, aead: aead, invalid: invalid}
+	reader.nonce = make([]byte, aead.NonceSize())
+	reader.in = make([]byte, ChunkSize+aead.Overhead())
+	reader.out = make([]byte, 0, ChunkSize)
+	return reader
+}
+
+// Read implementation of io.Reader
+func (r *Reader) Read(p []byte) (n int, err error) {
+	for len(r.buf) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		err = r.next()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n = copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// next decrypt the next chunk
+func (r *Reader) next() (err error) {
+	n, err := io.ReadFull(r.r, r.in)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	if n < r.aead.Overhead() {
+		return r.invalid
+	}
+
+	// A full chunk is the last one only if it can be opened with the last chunk nonce
+	// Open clears its output on failure so chunks are not decrypted in place
+	if n == len(r.in) {
+		chunkNonce(r.nonce, r.index, false)
+		r.buf, err = r.aead.Open(r.out[:0], r.nonce, r.in[:n], nil)
+		if err == nil {
+			r.index++
+			return nil
+		}
+	}
+
+	chunkNonce(r.nonce, r.index, true)
+	r.buf, err = r.aead.Open(r.out[:0], r.nonce, r.in[:n], nil)
+	if err != nil {
+		return r.invalid
+	}
+
+	// Nothing may follow the last chunk
+	extra, _ := io.ReadFull(r.r, make([]byte, 1))
+	if extra > 0 {
+		return r.invalid
+	}
+
+	r.done = true
+	return nil
+}
@@ -0,0 +1,110 @@
+package aead
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errInvalid = errors.New("invalid stream")
+
+func newGCM(t *testing.T) cipher.AEAD {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err, "unable to generate key")
+
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err, "unable to create cipher")
+
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err, "unable to create gcm")
+	return gcm
+}
+
+func encrypt(t *testing.T, gcm cipher.AEAD, plaintext []byte) []byte {
+	buf := &bytes.Buffer{}
+	writer := NewWriter(buf, gcm)
+
+	_, err := writer.Write(plaintext)
+	require.NoError(t, err, "unable to write")
+
+	err = writer.Close()
+	require.NoError(t, err, "unable to close writer")
+
+	return buf.Bytes()
+}
+
+func decrypt(gcm cipher.AEAD, ciphertext []byte) ([]byte, error) {
+	return io.ReadAll(NewReader(bytes.NewReader(ciphertext), gcm, errInvalid))
+}
+
+func TestWriterEncrypter(t *testing.T) {
+	gcm := newGCM(t)
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3 * ChunkSize} {
+		plaintext := make([]byte, size)
+		_, err := rand.Read(plaintext)
+		require.NoError(t, err, "unable to generate data")
+
+		ciphertext := encrypt(t, gcm, plaintext)
+		chunks := (size + ChunkSize - 1) / ChunkSize
+		if chunks == 0 {
+			chunks = 1
+		}
+		require.Equal(t, size+chunks*gcm.Overhead(), len(ciphertext), "invalid ciphertext size for %d bytes", size)
+
+		// Short reads to make sure the encrypter does not depend on the underlying reader read size
+		encrypted, err := io.ReadAll(NewEncrypter(iotest.HalfReader(bytes.NewReader(plaintext)), gcm))
+		require.NoError(t, err, "unable to encrypt %d bytes", size)
+		require.True(t, bytes.Equal(ciphertext, encrypted), "writer and encrypter mismatch for %d bytes", size)
+
+		result, err := decrypt(gcm, ciphertext)
+		require.NoError(t, err, "unable to decrypt %d bytes", size)
+		require.True(t, bytes.Equal(plaintext, result), "invalid plaintext for %d bytes", size)
+	}
+}
+
+func TestReaderInvalidKey(t *testing.T) {
+	ciphertext := encrypt(t, newGCM(t), []byte("data"))
+
+	_, err := decrypt(newGCM(t), ciphertext)
+	require.Equal(t, errInvalid, err, "invalid error")
+}
+
+func TestReaderTruncated(t *testing.T) {
+	gcm := newGCM(t)
+	ciphertext := encrypt(t, gcm, make([]byte, 2*ChunkSize))
+	chunk := ChunkSize + gcm.Overhead()
+
+	// Remove the last chunk
+	_, err := decrypt(gcm, ciphertext[:chunk])
+	require.Equal(t, errInvalid, err, "invalid error")
+
+	_, err = decrypt(gcm, ciphertext[:len(ciphertext)-1])
+	require.Equal(t, errInvalid, err, "invalid error")
+
+	_, err = decrypt(gcm, append(ciphertext, 0))
+	require.Equal(t, errInvalid, err, "invalid error")
+
+	_, err = decrypt(gcm, nil)
+	require.Equal(t, errInvalid, err, "invalid error")
+}
+
+func TestReaderReordered(t *testing.T) {
+	gcm := newGCM(t)
+	ciphertext := encrypt(t, gcm, make([]byte, 3*ChunkSize))
+	chunk := ChunkSize + gcm.Overhead()
+
+	reordered := append([]byte{}, ciphertext[chunk:2*chunk]...)
+	reordered = append(reordered, ciphertext[:chunk]...)
+	reordered = append(reordered, ciphertext[2*chunk:]...)
+
+	_, err := decrypt(gcm, reordered)
+	require.Equal(t, errInvalid, err, "invalid error")
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"runtime"
+	"time"
 
 	"github.com/root-gg/plik/server/common"
 )
@@ -22,6 +23,13 @@ type Client struct {
 	ClientUserAgent string // User-Agent HTTP Header setting
 
 	HTTPClient *http.Client // HTTP Client ot use to make the requests
+
+	ChunkSize    int64 // Upload files by chunks of ChunkSize bytes to resume after network errors ( 0 to disable )
+	ChunkRetries int   // Number of times a chunk is sent again before giving up
+
+	Uploader UploaderConfig // Concurrency, retry policy and bandwidth limit of the file uploads
+
+	limiter rateLimiter // Share the Uploader.RateLimit between all the uploads of the client
 }
 
 // NewClient creates a new Plik Client
@@ -39,6 +47,11 @@ func NewClient(url string) (c *Client) {
 
 	c.HTTPClient = NewHTTPClient(false)
 
+	c.ChunkRetries = 5
+
+	c.Uploader.FileRetries = 3
+	c.Uploader.RetryBackoff = time.Second
+
 	return c
 }
 
@@ -168,12 +181,25 @@ func (c *Client) GetUploadProtectedByPassword(id string, login string, password
 	return upload, nil
 }
 
+// GetUploadWithUploadToken fetch upload metadata from the server with the upload token of the upload admin URL
+// The upload can then be deleted even if it is not linked to the client token
+func (c *Client) GetUploadWithUploadToken(id string, uploadToken string) (upload *Upload, err error) {
+	uploadParams := c.NewUpload().getParams()
+	uploadParams.ID = id
+	uploadParams.UploadToken = uploadToken
+
+	return c.getUploadWithParams(uploadParams)
+}
+
 // NewHTTPClient Create a new HTTP client with ProxyFromEnvironment and InsecureSkipVerify setup
 func NewHTTPClient(insecure bool) *http.Client {
 	return &http.Client{
 		Transport: &http.Transport{
 			Proxy:           http.ProxyFromEnvironment,
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+
+			// Send the file anyway if the server does not answer to "Expect: 100-continue"
+			ExpectContinueTimeout: time.Second,
 		},
 	}
 }
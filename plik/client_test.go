@@ -2,6 +2,7 @@ package plik
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
@@ -190,6 +191,30 @@ func TestUploadReadCloser(t *testing.T) {
 	require.Equal(t, data, string(content), "invalid file content")
 }
 
+func TestDownloadFileDigestMismatch(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	data := "data data data"
+	upload, file, err := pc.UploadReader("filename", io.NopCloser(bytes.NewBufferString(data)))
+	require.NoError(t, err, "unable to upload file")
+	require.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte(data))), file.Metadata().Sha256, "invalid file sha256")
+
+	// Corrupt the file in the data backend
+	err = ps.GetDataBackend().RemoveFile(file.Metadata())
+	require.NoError(t, err, "unable to remove file")
+	err = ps.GetDataBackend().AddFile(file.Metadata(), bytes.NewBufferString("data data dada"))
+	require.NoError(t, err, "unable to corrupt file")
+
+	reader, err := pc.downloadFile(upload.getParams(), file.getParams())
+	require.NoError(t, err, "unable to download file")
+	_, err = io.ReadAll(reader)
+	common.RequireError(t, err, "file digest mismatch")
+}
+
 func TestUploadFiles(t *testing.T) {
 	ps, pc := newPlikServerAndClient()
 	defer shutdown(ps)
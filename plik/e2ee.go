@@ -0,0 +1,89 @@
+package plik
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/root-gg/plik/plik/aead"
+)
+
+// End-to-end encryption stream format :
+//   - Each file is encrypted with its own random 256 bits key which is only shared in the fragment
+//     of the download URL ( base64url encoded ) so it never reaches the server
+//   - Header : magic ( "PLIKE2E" + version byte )
+//   - The plaintext is encrypted with AES-256-GCM in chunks ( see the plik/aead package )
+
+const e2eeKeySize = 32
+
+var e2eeMagic = []byte("PLIKE2E\x01")
+
+// ErrInvalidE2EEStream is returned when an end-to-end encrypted file can't be decrypted
+var ErrInvalidE2EEStream = errors.New("invalid end-to-end encrypted file or key")
+
+// newE2EEKey generate a new random end-to-end encryption key
+func newE2EEKey() (key []byte, err error) {
+	key = make([]byte, e2eeKeySize)
+	_, err = io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate encryption key : %s", err)
+	}
+	return key, nil
+}
+
+// encodeE2EEKey encode the key to be set as the URL fragment
+func encodeE2EEKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+func newE2EEAEAD(key []byte) (gcm cipher.AEAD, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newE2EEEncrypter return a reader encrypting the data of reader with key
+func newE2EEEncrypter(reader io.ReadCloser, key []byte) (encrypter io.ReadCloser, err error) {
+	gcm, err := newE2EEAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := io.MultiReader(bytes.NewReader(e2eeMagic), aead.NewEncrypter(reader, gcm))
+	return &e2eeReadCloser{Reader: encrypted, Closer: reader}, nil
+}
+
+// NewE2EEReader return a reader decrypting an end-to-end encrypted file
+// with the key from the fragment of its download URL
+func NewE2EEReader(reader io.Reader, key string) (decrypter io.Reader, err error) {
+	rawKey, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil || len(rawKey) != e2eeKeySize {
+		return nil, fmt.Errorf("invalid end-to-end encryption key")
+	}
+
+	gcm, err := newE2EEAEAD(rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(e2eeMagic))
+	_, err = io.ReadFull(reader, header)
+	if err != nil || string(header) != string(e2eeMagic) {
+		return nil, ErrInvalidE2EEStream
+	}
+
+	return aead.NewReader(reader, gcm, ErrInvalidE2EEStream), nil
+}
+
+// e2eeReadCloser close the underlying reader of an encrypter or a decrypter
+type e2eeReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,73 @@
+package plik
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/plik/aead"
+)
+
+func e2eeEncrypt(t *testing.T, plaintext []byte) (ciphertext []byte, key string) {
+	rawKey, err := newE2EEKey()
+	require.NoError(t, err, "unable to generate key")
+
+	encrypter, err := newE2EEEncrypter(io.NopCloser(bytes.NewReader(plaintext)), rawKey)
+	require.NoError(t, err, "unable to create encrypter")
+
+	ciphertext, err = io.ReadAll(encrypter)
+	require.NoError(t, err, "unable to encrypt")
+
+	return ciphertext, encodeE2EEKey(rawKey)
+}
+
+func e2eeDecrypt(ciphertext []byte, key string) ([]byte, error) {
+	reader, err := NewE2EEReader(bytes.NewReader(ciphertext), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func TestE2EEEncryptDecrypt(t *testing.T) {
+	for _, size := range []int{0, 1, aead.ChunkSize - 1, aead.ChunkSize, aead.ChunkSize + 1, 3 * aead.ChunkSize} {
+		plaintext := make([]byte, size)
+		_, err := rand.Read(plaintext)
+		require.NoError(t, err, "unable to generate data")
+
+		ciphertext, key := e2eeEncrypt(t, plaintext)
+
+		result, err := e2eeDecrypt(ciphertext, key)
+		require.NoError(t, err, "unable to decrypt %d bytes", size)
+		require.True(t, bytes.Equal(plaintext, result), "invalid plaintext for %d bytes", size)
+	}
+}
+
+func TestE2EEInvalidKey(t *testing.T) {
+	ciphertext, _ := e2eeEncrypt(t, []byte("data"))
+
+	_, err := e2eeDecrypt(ciphertext, "invalid")
+	require.Error(t, err, "missing error")
+	require.Contains(t, err.Error(), "invalid end-to-end encryption key", "invalid error")
+
+	_, otherKey := e2eeEncrypt(t, nil)
+	_, err = e2eeDecrypt(ciphertext, otherKey)
+	require.Equal(t, ErrInvalidE2EEStream, err, "invalid error")
+}
+
+func TestE2EETruncated(t *testing.T) {
+	ciphertext, key := e2eeEncrypt(t, make([]byte, 2*aead.ChunkSize))
+
+	// Remove the last chunk
+	_, err := e2eeDecrypt(ciphertext[:len(e2eeMagic)+aead.ChunkSize+16], key)
+	require.Equal(t, ErrInvalidE2EEStream, err, "invalid error")
+
+	_, err = e2eeDecrypt(ciphertext[:len(ciphertext)-1], key)
+	require.Equal(t, ErrInvalidE2EEStream, err, "invalid error")
+
+	_, err = e2eeDecrypt(append(ciphertext, 0), key)
+	require.Equal(t, ErrInvalidE2EEStream, err, "invalid error")
+}
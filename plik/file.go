@@ -1,6 +1,7 @@
 package plik
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/url"
@@ -19,8 +20,9 @@ type File struct {
 	reader io.ReadCloser // Byte stream to upload
 	upload *Upload       // Link to upload and client
 
-	lock     sync.Mutex   // The two following fields need to be protected
+	lock     sync.Mutex   // The following fields need to be protected
 	metadata *common.File // File metadata returned by the server
+	key      []byte       // End-to-end encryption key ( E2EE uploads only )
 
 	callback func(metadata *common.File, err error) // Callback to execute once the file has been uploaded
 
@@ -38,10 +40,21 @@ func newFileFromReadCloser(upload *Upload, name string, reader io.ReadCloser) *F
 }
 
 // NewFileFromReader creates a File from a filename and an io.Reader
+// Readers implementing io.Seeker stay seekable so the file can be sent again after a failure
 func newFileFromReader(upload *Upload, name string, reader io.Reader) *File {
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		return newFileFromReadCloser(upload, name, readSeekNopCloser{seeker})
+	}
 	return newFileFromReadCloser(upload, name, io.NopCloser(reader))
 }
 
+// readSeekNopCloser is io.NopCloser for an io.ReadSeeker
+type readSeekNopCloser struct {
+	io.ReadSeeker
+}
+
+func (readSeekNopCloser) Close() error { return nil }
+
 // NewFileFromPath creates a File from a filesystem path
 func newFileFromPath(upload *Upload, path string) (file *File, err error) {
 
@@ -144,6 +157,11 @@ func (file *File) ready() (done chan struct{}, abort bool) {
 
 // Upload uploads a single file.
 func (file *File) Upload() (err error) {
+	return file.UploadContext(context.Background())
+}
+
+// UploadContext uploads a single file, the upload is aborted if the context is canceled
+func (file *File) UploadContext(ctx context.Context) (err error) {
 
 	// initialize the upload if not already done
 	err = file.upload.Create()
@@ -162,7 +180,19 @@ func (file *File) Upload() (err error) {
 
 	// Upload file to the server
 	defer func() { _ = file.reader.Close() }()
-	fileMetadata, err := file.upload.client.uploadFile(file.upload.getParams(), file.getParams(), file.reader)
+
+	var fileMetadata *common.File
+	uploadParams := file.upload.getParams()
+	fileParams := file.getParams()
+
+	// Encrypt the file with its own key
+	if uploadParams.E2EE {
+		err = file.encrypt()
+	}
+
+	if err == nil {
+		fileMetadata, err = file.send(ctx, uploadParams, fileParams)
+	}
 
 	// update file with API call result
 	file.lock.Lock()
@@ -185,6 +215,46 @@ func (file *File) Upload() (err error) {
 	return err
 }
 
+// send the file data to the server, throttled to the client rate limit
+// The file is sent again after transient failures, from the start if its reader implements io.Seeker
+// ( files added from a path, bytes.Reader, ... ), otherwise only as long as none of its data has been read
+// ( pipes, STDIN, encrypted files, ... ). Resumable uploads send the failed chunks again by themselves ( see Client.ChunkRetries )
+func (file *File) send(ctx context.Context, uploadParams *common.Upload, fileParams *common.File) (fileMetadata *common.File, err error) {
+	client := file.upload.client
+	config := client.Uploader
+	reader := newUploadReader(ctx, file.reader, &client.limiter, config.RateLimit)
+
+	for attempt := 0; ; attempt++ {
+		if client.ChunkSize > 0 && !uploadParams.Stream && fileParams.ID != "" {
+			fileMetadata, err = client.uploadFileChunks(ctx, uploadParams, fileParams, reader)
+			if err == errResumableUploadNotSupported {
+				// Fallback to a regular upload for older servers
+				fileMetadata, err = client.uploadFile(ctx, uploadParams, fileParams, reader)
+			}
+		} else {
+			fileMetadata, err = client.uploadFile(ctx, uploadParams, fileParams, reader)
+		}
+
+		if err == nil || attempt >= config.FileRetries || !reader.retryable(err) {
+			return fileMetadata, err
+		}
+
+		if client.Debug {
+			fmt.Printf("unable to upload file %s : %s, retrying\n", file.Name, err)
+		}
+
+		err = sleep(ctx, config.RetryBackoff<<uint(attempt))
+		if err != nil {
+			return nil, err
+		}
+
+		err = reader.rewind()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
 // GetURL returns the URL to download the file
 func (file *File) GetURL() (URL *url.URL, err error) {
 
@@ -215,7 +285,58 @@ func (file *File) GetURL() (URL *url.URL, err error) {
 	fileURL := fmt.Sprintf("%s/%s/%s/%s/%s", domain, mode, uploadMetadata.ID, fileMetadata.ID, fileMetadata.Name)
 
 	// Parse to get a nice escaped url
-	return url.Parse(fileURL)
+	URL, err = url.Parse(fileURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key of end-to-end encrypted files is never sent to the server
+	if uploadMetadata.E2EE {
+		key, err := file.getKey()
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			URL.Fragment = encodeE2EEKey(key)
+		}
+	}
+
+	return URL, nil
+}
+
+// getKey return the end-to-end encryption key of the file
+// A new key is generated for files to upload, the key of files uploaded by another client is unknown
+func (file *File) getKey() (key []byte, err error) {
+	file.lock.Lock()
+	defer file.lock.Unlock()
+
+	if file.key == nil && file.reader != nil {
+		file.key, err = newE2EEKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return file.key, nil
+}
+
+// encrypt wrap the file reader to encrypt the file with its end-to-end encryption key
+func (file *File) encrypt() (err error) {
+	key, err := file.getKey()
+	if err != nil {
+		return err
+	}
+
+	file.WrapReader(func(reader io.ReadCloser) io.ReadCloser {
+		var encrypter io.ReadCloser
+		encrypter, err = newE2EEEncrypter(reader, key)
+		if err != nil {
+			return reader
+		}
+		return encrypter
+	})
+
+	return err
 }
 
 // WrapReader a convenient function to alter the content of the file on the file ( encrypt / display progress / ... )
@@ -231,9 +352,45 @@ func (file *File) RegisterUploadCallback(callback UploadCallback) {
 	file.callback = callback
 }
 
-// Download downloads all the upload files in a zip archive
+// Download downloads the file
+// End-to-end encrypted files are decrypted if their key is known
 func (file *File) Download() (reader io.ReadCloser, err error) {
-	return file.upload.client.downloadFile(file.upload.getParams(), file.getParams())
+	uploadParams := file.upload.getParams()
+	reader, err = file.upload.client.downloadFile(uploadParams, file.getParams())
+	if err != nil || !uploadParams.E2EE {
+		return reader, err
+	}
+
+	file.lock.Lock()
+	key := file.key
+	file.lock.Unlock()
+	if key == nil {
+		return reader, nil
+	}
+
+	decrypter, err := NewE2EEReader(reader, encodeE2EEKey(key))
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+
+	return &e2eeReadCloser{Reader: decrypter, Closer: reader}, nil
+}
+
+// DownloadFrom downloads the file starting at offset to resume an interrupted download
+// The content is returned as stored on the server so end-to-end encrypted files are not decrypted.
+// If the server sends the whole file instead ( the file changed ) start is 0.
+// downloaded must return the first offset bytes of the file ( already downloaded ), they are read
+// when the download is resumed to verify the SHA-256 digest of the whole file on the last read.
+func (file *File) DownloadFrom(offset int64, downloaded io.Reader) (reader io.ReadCloser, start int64, err error) {
+	fileParams := file.getParams()
+
+	// The server only resumes the download if the file did not change since its metadata was fetched
+	if metadata := file.Metadata(); metadata != nil {
+		fileParams.Md5 = metadata.Md5
+	}
+
+	return file.upload.client.downloadFileFrom(file.upload.getParams(), fileParams, offset, downloaded)
 }
 
 // Delete remove the upload and all the associated files from the remote server
@@ -2,15 +2,20 @@ package plik
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/root-gg/utils"
 
@@ -62,7 +67,7 @@ func (c *Client) create(uploadParams *common.Upload) (uploadMetadata *common.Upl
 }
 
 // UploadFile uploads a data stream to the Plik Server and return the file metadata
-func (c *Client) uploadFile(upload *common.Upload, fileParams *common.File, reader io.Reader) (fileInfo *common.File, err error) {
+func (c *Client) uploadFile(ctx context.Context, upload *common.Upload, fileParams *common.File, reader io.Reader) (fileInfo *common.File, err error) {
 	pipeReader, pipeWriter := io.Pipe()
 	multipartWriter := multipart.NewWriter(pipeWriter)
 
@@ -70,7 +75,7 @@ func (c *Client) uploadFile(upload *common.Upload, fileParams *common.File, read
 		return nil, errors.New("missing file upload parameter")
 	}
 
-	errCh := make(chan error)
+	errCh := make(chan error, 1)
 	go func(errCh chan error) {
 		writer, err := multipartWriter.CreateFormFile("file", fileParams.Name)
 		if err != nil {
@@ -123,10 +128,27 @@ func (c *Client) uploadFile(upload *common.Upload, fileParams *common.File, read
 		return nil, err
 	}
 
+	req = req.WithContext(ctx)
 	req.Header.Set("Content-Type", multipartWriter.FormDataContentType())
 
+	// Close the pipe if the context is canceled, the transport does not abort a request waiting for the file data
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = pipeReader.CloseWithError(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	// Do not send the file until the server accepts the request so it can be sent again if it is rejected
+	req.Header.Set("Expect", "100-continue")
+
 	resp, err := c.MakeRequest(req)
 	if err != nil {
+		// Stop the multipart writer
+		_ = pipeReader.CloseWithError(err)
 		return nil, err
 	}
 
@@ -155,6 +177,181 @@ func (c *Client) uploadFile(upload *common.Upload, fileParams *common.File, read
 	return fileInfo, nil
 }
 
+var errResumableUploadNotSupported = errors.New("resumable upload is not supported by the server")
+
+// uploadFileChunks uploads a data stream to the Plik Server using the resumable upload protocol and return the file metadata
+// Each chunk is buffered in memory to be sent again if a network error occurs. If the server already received
+// some data for this file ( from a previous interrupted call ) the upload resumes at the server offset.
+func (c *Client) uploadFileChunks(ctx context.Context, upload *common.Upload, fileParams *common.File, reader io.Reader) (fileInfo *common.File, err error) {
+	if upload == nil || fileParams == nil || reader == nil {
+		return nil, errors.New("missing file upload parameter")
+	}
+
+	if fileParams.ID == "" {
+		return nil, errors.New("files must be added to upload before creation for resumable upload to work")
+	}
+
+	URL, err := url.Parse(c.URL + "/chunk/" + upload.ID + "/" + fileParams.ID + "/" + fileParams.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resume from the server offset
+	offset, err := c.getFileUploadOffset(ctx, upload, URL.String())
+	if err != nil {
+		if e, ok := err.(*responseError); ok && (e.statusCode == http.StatusNotFound || e.statusCode == http.StatusMethodNotAllowed) {
+			return nil, errResumableUploadNotSupported
+		}
+		return nil, err
+	}
+
+	if offset > 0 {
+		_, err = io.CopyN(io.Discard, reader, offset)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resume upload at offset %d : %s", offset, err)
+		}
+	}
+
+	buf := make([]byte, c.ChunkSize)
+	for {
+		n, err := io.ReadFull(reader, buf)
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !eof {
+			return nil, err
+		}
+
+		if n > 0 {
+			offset, err = c.uploadChunk(ctx, upload, URL.String(), offset, buf[:n])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if eof {
+			break
+		}
+	}
+
+	// Commit
+	req, err := c.UploadRequest(upload, "POST", URL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := c.MakeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse json response
+	fileInfo = &common.File{}
+	err = json.Unmarshal(body, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Debug {
+		fmt.Printf("File uploaded : %s\n", utils.Sdump(fileInfo))
+	}
+
+	return fileInfo, nil
+}
+
+// uploadChunk send a chunk of data at the given offset and return the new offset
+// The chunk is sent again on errors until the server acknowledges it or ChunkRetries is reached
+func (c *Client) uploadChunk(ctx context.Context, upload *common.Upload, URL string, offset int64, chunk []byte) (newOffset int64, err error) {
+	expected := offset + int64(len(chunk))
+
+	for attempt := 0; ; attempt++ {
+		err = c.patchChunk(ctx, upload, URL, offset, chunk)
+		if err == nil {
+			return expected, nil
+		}
+
+		// Do not retry if the server explicitly rejected the chunk
+		if e, ok := err.(*responseError); ok && e.statusCode >= 400 && e.statusCode < 500 && e.statusCode != http.StatusConflict {
+			return offset, err
+		}
+
+		if attempt >= c.ChunkRetries || ctx.Err() != nil {
+			return offset, err
+		}
+
+		if c.Debug {
+			fmt.Printf("unable to upload chunk at offset %d : %s, retrying\n", offset, err)
+		}
+
+		e := sleep(ctx, time.Duration(1<<uint(attempt))*100*time.Millisecond)
+		if e != nil {
+			return offset, e
+		}
+
+		// The chunk might have been received even if the response was lost
+		serverOffset, e := c.getFileUploadOffset(ctx, upload, URL)
+		if e != nil {
+			continue
+		}
+		if serverOffset == expected {
+			return expected, nil
+		}
+		if serverOffset != offset {
+			return offset, fmt.Errorf("unexpected upload offset %d, expected %d", serverOffset, offset)
+		}
+	}
+}
+
+func (c *Client) patchChunk(ctx context.Context, upload *common.Upload, URL string, offset int64, chunk []byte) (err error) {
+	req, err := c.UploadRequest(upload, "PATCH", URL, bytes.NewReader(chunk))
+	if err != nil {
+		return err
+	}
+
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+
+	resp, err := c.MakeRequest(req)
+	if err != nil {
+		return err
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+// getFileUploadOffset return the number of bytes the server has already received for a resumable upload
+func (c *Client) getFileUploadOffset(ctx context.Context, upload *common.Upload, URL string) (offset int64, err error) {
+	req, err := c.UploadRequest(upload, "HEAD", URL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	req = req.WithContext(ctx)
+
+	resp, err := c.MakeRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+
+	offset, err = strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Upload-Offset header : %s", err)
+	}
+
+	return offset, nil
+}
+
 // UploadRequest creates a new HTTP request with the header generated from the given upload params
 func (c *Client) UploadRequest(upload *common.Upload, method, URL string, body io.Reader) (req *http.Request, err error) {
 	req, err = http.NewRequest(method, URL, body)
@@ -225,9 +422,110 @@ func (c *Client) downloadFile(uploadParams *common.Upload, fileParams *common.Fi
 		return nil, err
 	}
 
+	// Verify the SHA-256 digest of the file once downloaded
+	digest, err := getDigest(resp, fileParams)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if digest != "" && resp.StatusCode == http.StatusOK {
+		return newDigestVerifier(resp.Body, digest), nil
+	}
+
 	return resp.Body, nil
 }
 
+// getDigest return the SHA-256 digest of the downloaded file from the response or the file metadata
+func getDigest(resp *http.Response, fileParams *common.File) (digest string, err error) {
+	digest, err = common.ParseReprDigest(resp.Header.Get("Repr-Digest"))
+	if err != nil {
+		return "", err
+	}
+	if digest == "" {
+		digest = fileParams.Sha256
+	}
+	return digest, nil
+}
+
+// downloadFileFrom download the remote file from the server starting at offset
+// The server sends the whole file if it changed since its metadata was fetched, start is then 0
+// If the download is resumed the first offset bytes are read from downloaded to verify the SHA-256 digest of the whole file
+func (c *Client) downloadFileFrom(uploadParams *common.Upload, fileParams *common.File, offset int64, downloaded io.Reader) (reader io.ReadCloser, start int64, err error) {
+	URL := c.URL + "/file/" + uploadParams.ID + "/" + fileParams.ID + "/" + fileParams.Name
+
+	req, err := c.UploadRequest(uploadParams, "GET", URL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if fileParams.Md5 != "" {
+			req.Header.Set("If-Range", fmt.Sprintf(`"%s"`, fileParams.Md5))
+		}
+	}
+
+	resp, err := c.MakeRequest(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode == http.StatusPartialContent {
+		start = offset
+	}
+
+	digest, err := getDigest(resp, fileParams)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, 0, err
+	}
+	if digest == "" {
+		return resp.Body, start, nil
+	}
+
+	verifier := newDigestVerifier(resp.Body, digest)
+	if start > 0 {
+		if downloaded == nil {
+			_ = resp.Body.Close()
+			return nil, 0, errors.New("missing downloaded content to verify the file digest")
+		}
+
+		_, err = io.CopyN(verifier.hash, downloaded, start)
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, 0, fmt.Errorf("unable to read the downloaded content : %s", err)
+		}
+	}
+
+	return verifier, start, nil
+}
+
+// ErrDigestMismatch is returned ( wrapped ) by the last read of a downloaded file whose SHA-256 digest does not match
+var ErrDigestMismatch = errors.New("file digest mismatch")
+
+// digestVerifier fails the last read if the SHA-256 digest of the content does not match
+type digestVerifier struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func newDigestVerifier(reader io.ReadCloser, sha256sum string) *digestVerifier {
+	return &digestVerifier{ReadCloser: reader, hash: sha256.New(), expected: sha256sum}
+}
+
+func (v *digestVerifier) Read(p []byte) (n int, err error) {
+	n, err = v.ReadCloser.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF {
+		got := fmt.Sprintf("%x", v.hash.Sum(nil))
+		if got != v.expected {
+			return n, fmt.Errorf("%w : expected %s, got %s", ErrDigestMismatch, v.expected, got)
+		}
+	}
+	return n, err
+}
+
 // downloadArchive download the remote upload files as a zip archive from the server
 func (c *Client) downloadArchive(uploadParams *common.Upload) (reader io.ReadCloser, err error) {
 	URL := c.URL + "/archive/" + uploadParams.ID + "/archive.zip"
@@ -302,7 +600,7 @@ func (c *Client) MakeRequest(req *http.Request) (resp *http.Response, err error)
 	// Log request
 	if c.Debug {
 		dumpBody := true
-		if req.Method == "POST" && (strings.Contains(req.URL.String(), "/file") || strings.Contains(req.URL.String(), "/stream")) {
+		if (req.Method == "POST" || req.Method == "PATCH") && (strings.Contains(req.URL.String(), "/file") || strings.Contains(req.URL.String(), "/stream") || strings.Contains(req.URL.String(), "/chunk")) {
 			dumpBody = false
 		}
 		dump, err := httputil.DumpRequest(req, dumpBody)
@@ -319,7 +617,7 @@ func (c *Client) MakeRequest(req *http.Request) (resp *http.Response, err error)
 		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 		return nil, parseErrorResponse(resp)
 	}
 
@@ -340,6 +638,20 @@ func (c *Client) MakeRequest(req *http.Request) (resp *http.Response, err error)
 	return resp, nil
 }
 
+// responseError is returned when the server responds with an unexpected HTTP status code
+type responseError struct {
+	status     string
+	statusCode int
+	body       string
+}
+
+func (e *responseError) Error() string {
+	if len(e.body) > 0 {
+		return fmt.Sprintf("%s : %s", e.status, e.body)
+	}
+	return e.status
+}
+
 func parseErrorResponse(resp *http.Response) (err error) {
 	defer func() { _ = resp.Body.Close() }()
 
@@ -351,9 +663,5 @@ func parseErrorResponse(resp *http.Response) (err error) {
 		return err
 	}
 
-	if len(body) > 0 {
-		return fmt.Errorf("%s : %s", resp.Status, string(body))
-	}
-
-	return fmt.Errorf("%s", resp.Status)
+	return &responseError{status: resp.Status, statusCode: resp.StatusCode, body: string(body)}
 }
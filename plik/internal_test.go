@@ -26,6 +26,7 @@ package plik
 
 import (
 	"bytes"
+	gocontext "context"
 	"io"
 	"net/http"
 	"os"
@@ -111,7 +112,7 @@ func TestUploadFileNoUpload(t *testing.T) {
 	file.Name = "filename"
 	upload.InitializeForTests()
 
-	_, err = pc.uploadFile(upload, file, bytes.NewBufferString("data"))
+	_, err = pc.uploadFile(gocontext.Background(), upload, file, bytes.NewBufferString("data"))
 	common.RequireError(t, err, "upload "+upload.ID+" not found")
 }
 
@@ -125,38 +126,39 @@ func TestUploadFileReaderError(t *testing.T) {
 	err = common.CheckHTTPServer(ps.GetConfig().ListenPort)
 	require.NoError(t, err, "server unreachable")
 
-	upload := &common.Upload{}
-	file := upload.NewFile()
-	file.Name = "filename"
-	upload.InitializeForTests()
+	// The server must accept the upload to start reading the file
+	uploadToCreate := &common.Upload{}
+	uploadToCreate.NewFile().Name = "filename"
+	upload, err := pc.create(uploadToCreate)
+	require.NoError(t, err, "unable to create upload")
 
-	_, err = pc.uploadFile(upload, file, common.NewErrorReaderString("io error"))
+	_, err = pc.uploadFile(gocontext.Background(), upload, upload.Files[0], common.NewErrorReaderString("io error"))
 	common.RequireError(t, err, "io error")
 }
 
 func TestUploadFileInvalidParams(t *testing.T) {
 	_, pc := newPlikServerAndClient()
 
-	_, err := pc.uploadFile(nil, nil, nil)
+	_, err := pc.uploadFile(gocontext.Background(), nil, nil, nil)
 	common.RequireError(t, err, "missing file upload parameter")
 
 	pc.URL = string([]byte{0})
 
-	_, err = pc.uploadFile(&common.Upload{}, common.NewFile(), &bytes.Buffer{})
+	_, err = pc.uploadFile(gocontext.Background(), &common.Upload{}, common.NewFile(), &bytes.Buffer{})
 	common.RequireError(t, err, "")
 }
 
 func TestUploadFileAPIFail(t *testing.T) {
 	_, pc := newPlikServerAndClient()
 
-	_, err := pc.uploadFile(&common.Upload{}, common.NewFile(), &bytes.Buffer{})
+	_, err := pc.uploadFile(gocontext.Background(), &common.Upload{}, common.NewFile(), &bytes.Buffer{})
 	common.RequireError(t, err, "connection refused")
 
 	shutdown, err := common.StartAPIMockServer(common.DummyHandler)
 	defer shutdown()
 	require.NoError(t, err, "unable to start HTTP server server")
 
-	_, err = pc.uploadFile(&common.Upload{}, common.NewFile(), &bytes.Buffer{})
+	_, err = pc.uploadFile(gocontext.Background(), &common.Upload{}, common.NewFile(), &bytes.Buffer{})
 	common.RequireError(t, err, "")
 }
 
@@ -174,7 +176,7 @@ func TestUploadFileInvalidJSON(t *testing.T) {
 	defer shutdown()
 	require.NoError(t, err, "unable to start HTTP server server")
 
-	_, err = pc.uploadFile(&common.Upload{}, common.NewFile(), &bytes.Buffer{})
+	_, err = pc.uploadFile(gocontext.Background(), &common.Upload{}, common.NewFile(), &bytes.Buffer{})
 	common.RequireError(t, err, "")
 }
 
@@ -83,7 +83,11 @@ func TestMain(m *testing.M) {
 			}
 		}
 
-		dataBackend = file.NewBackend(&file.Config{Directory: dir})
+		dataBackend, err = file.NewBackend(&file.Config{Directory: dir})
+		if err != nil {
+			fmt.Printf("Unable to setup file data backend : %s\n", err)
+			os.Exit(1)
+		}
 		fmt.Println("running tests with file data backend")
 	case "swift":
 		swiftConfig := swift.NewConfig(testConfig.DataBackendConfig)
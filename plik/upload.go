@@ -1,6 +1,7 @@
 package plik
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/url"
@@ -17,6 +18,8 @@ type UploadParams struct {
 	OneShot   bool // Force deletion of the file from the server after the first download
 	Removable bool // Allow upload and upload files to be removed from the server at any time
 
+	MaxDownloads int // Remove each file from the server after this many downloads ( 0 for unlimited )
+
 	TTL       int    // Time in second before automatic deletion of the file from the server
 	ExtendTTL bool   // Extend upload expiration date by TTL when accessed
 	Comments  string // Arbitrary comment to attach to the upload ( the web interface support markdown language )
@@ -25,6 +28,8 @@ type UploadParams struct {
 
 	Login    string // HttpBasic protection for the upload
 	Password string // Login and Password
+
+	E2EE bool // Encrypt the files before uploading them, the key of each file is only in the fragment of its URL
 }
 
 // Upload store the necessary data to upload files to a Plik server
@@ -57,9 +62,11 @@ func newUploadFromMetadata(client *Client, uploadMetadata *common.Upload) (uploa
 	upload.Stream = uploadMetadata.Stream
 	upload.OneShot = uploadMetadata.OneShot
 	upload.Removable = uploadMetadata.Removable
+	upload.MaxDownloads = uploadMetadata.MaxDownloads
 	upload.TTL = uploadMetadata.TTL
 	upload.ExtendTTL = uploadMetadata.ExtendTTL
 	upload.Comments = uploadMetadata.Comments
+	upload.E2EE = uploadMetadata.E2EE
 	upload.metadata = uploadMetadata
 
 	// Generate files
@@ -122,12 +129,14 @@ func (upload *Upload) getParams() (params *common.Upload) {
 	params.Stream = upload.Stream
 	params.OneShot = upload.OneShot
 	params.Removable = upload.Removable
+	params.MaxDownloads = upload.MaxDownloads
 	params.TTL = upload.TTL
 	params.ExtendTTL = upload.ExtendTTL
 	params.Comments = upload.Comments
 	params.Token = upload.Token
 	params.Login = upload.Login
 	params.Password = upload.Password
+	params.E2EE = upload.E2EE
 
 	if upload.metadata != nil {
 		params.ID = upload.metadata.ID
@@ -249,6 +258,12 @@ LOOP:
 
 // Upload uploads all files of the upload in parallel
 func (upload *Upload) Upload() (err error) {
+	return upload.UploadContext(context.Background())
+}
+
+// UploadContext uploads all files of the upload in parallel, at most Client.Uploader.MaxParallelFiles at a time
+// The files not uploaded yet are aborted if the context is canceled
+func (upload *Upload) UploadContext(ctx context.Context) (err error) {
 
 	// initialize the upload if not already done
 	err = upload.Create()
@@ -259,13 +274,26 @@ func (upload *Upload) Upload() (err error) {
 	files := upload.Files()
 	errors := make(chan error, len(files))
 
-	var wg sync.WaitGroup
+	workers := upload.client.Uploader.MaxParallelFiles
+	if workers <= 0 || workers > len(files) {
+		workers = len(files)
+	}
+
+	queue := make(chan *File, len(files))
 	for _, file := range files {
+		queue <- file
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go func(file *File) {
+		go func() {
 			defer wg.Done()
-			errors <- file.Upload()
-		}(file)
+			for file := range queue {
+				errors <- file.UploadContext(ctx)
+			}
+		}()
 	}
 
 	// Wait for all files to be uploaded
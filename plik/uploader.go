@@ -0,0 +1,144 @@
+package plik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UploaderConfig control how the files of an upload are sent to the server
+type UploaderConfig struct {
+	MaxParallelFiles int           // Maximum number of files Upload() sends at the same time ( 0 for unlimited )
+	FileRetries      int           // Number of times a file is sent again after a transient failure
+	RetryBackoff     time.Duration // Delay before the first retry, doubled after each retry
+	RateLimit        int64         // Maximum upload bandwidth in bytes per second shared by all the uploads of the client ( 0 for unlimited )
+}
+
+// rateLimiter share a bandwidth between concurrent readers
+// Each read reserves the time needed to send its bytes at the given rate after the previous reservations
+type rateLimiter struct {
+	lock sync.Mutex
+	next time.Time // Time at which all the bytes already read will have been sent
+}
+
+// wait block until n more bytes can be sent without exceeding rate bytes per second
+func (l *rateLimiter) wait(ctx context.Context, n int, rate int64) (err error) {
+	l.lock.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / rate))
+	l.lock.Unlock()
+
+	return sleep(ctx, delay)
+}
+
+// sleep for the given duration or until the context is done
+func sleep(ctx context.Context, duration time.Duration) (err error) {
+	if duration <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// uploadReader wrap the reader of a file to throttle it to the client rate limit
+// and to know if the file can be sent again after a failure
+type uploadReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *rateLimiter
+	rate    int64
+
+	seeker io.Seeker // Set if the file can be read again from the start
+	start  int64     // Offset of the start of the file
+
+	reads int64 // Number of reads started on the file since the last rewind ( atomic )
+}
+
+func newUploadReader(ctx context.Context, reader io.Reader, limiter *rateLimiter, rate int64) *uploadReader {
+	r := &uploadReader{ctx: ctx, reader: reader, limiter: limiter, rate: rate}
+
+	// Readers implementing io.Seeker may still not be able to seek ( pipes, wrappers )
+	if seeker, ok := reader.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			r.seeker = seeker
+			r.start = start
+		}
+	}
+
+	return r
+}
+
+func (r *uploadReader) Read(p []byte) (n int, err error) {
+	// Read at most a tenth of a second of data at a time to keep the rate smooth
+	if r.rate > 0 {
+		if burst := r.rate / 10; burst > 0 && int64(len(p)) > burst {
+			p = p[:burst]
+		}
+	}
+
+	atomic.AddInt64(&r.reads, 1)
+	n, err = r.reader.Read(p)
+
+	if n > 0 && r.rate > 0 {
+		e := r.limiter.wait(r.ctx, n, r.rate)
+		if e != nil {
+			return n, e
+		}
+	}
+
+	return n, err
+}
+
+// retryable return true if the error is a transient failure ( network error, server error or too many requests )
+// and the file can be sent again : either it has not been read yet or it can be rewound ( see rewind )
+func (r *uploadReader) retryable(err error) bool {
+	if (atomic.LoadInt64(&r.reads) > 0 && r.seeker == nil) || r.ctx.Err() != nil {
+		return false
+	}
+
+	var e *responseError
+	if errors.As(err, &e) {
+		return e.statusCode >= 500 || e.statusCode == http.StatusTooManyRequests
+	}
+
+	var urlError *url.Error
+	return errors.As(err, &urlError)
+}
+
+// rewind seek back to the start of the file if it has been read so it can be sent again
+func (r *uploadReader) rewind() (err error) {
+	if atomic.LoadInt64(&r.reads) == 0 {
+		return nil
+	}
+
+	if r.seeker == nil {
+		return errors.New("unable to send the file again as it can't be read from the start again")
+	}
+
+	_, err = r.seeker.Seek(r.start, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("unable to rewind the file : %s", err)
+	}
+
+	atomic.StoreInt64(&r.reads, 0)
+	return nil
+}
@@ -0,0 +1,116 @@
+package plik
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := &rateLimiter{}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		err := limiter.wait(context.Background(), 100, 1000)
+		require.NoError(t, err, "unable to wait")
+	}
+
+	// The first 100 bytes are sent right away
+	require.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond, "rate limit exceeded")
+}
+
+func TestRateLimiterContext(t *testing.T) {
+	limiter := &rateLimiter{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The first bytes are sent without delay
+	err := limiter.wait(ctx, 100, 1)
+	require.NoError(t, err, "unexpected error")
+
+	err = limiter.wait(ctx, 100, 1)
+	require.Equal(t, context.Canceled, err, "invalid error")
+}
+
+func TestUploadReaderBurst(t *testing.T) {
+	reader := newUploadReader(context.Background(), bytes.NewBufferString("data data data"), &rateLimiter{}, 20)
+
+	buf := make([]byte, 10)
+	n, err := reader.Read(buf)
+	require.NoError(t, err, "unable to read")
+	require.Equal(t, 2, n, "reads must be limited to a tenth of the rate")
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read")
+	require.Equal(t, "ta data data", string(content), "invalid content")
+}
+
+func TestUploadReaderRetryable(t *testing.T) {
+	networkError := &url.Error{Op: "Post", URL: "http://127.0.0.1", Err: errors.New("connection refused")}
+
+	reader := newUploadReader(context.Background(), &bytes.Buffer{}, &rateLimiter{}, 0)
+	require.True(t, reader.retryable(networkError), "network errors are retryable")
+	require.True(t, reader.retryable(&responseError{statusCode: http.StatusServiceUnavailable}), "server errors are retryable")
+	require.True(t, reader.retryable(&responseError{statusCode: http.StatusTooManyRequests}), "too many requests is retryable")
+	require.False(t, reader.retryable(&responseError{statusCode: http.StatusBadRequest}), "client errors are not retryable")
+	require.False(t, reader.retryable(errors.New("error")), "unknown errors are not retryable")
+
+	// The file can't be sent again once it has been read
+	reader = newUploadReader(context.Background(), bytes.NewBufferString("data"), &rateLimiter{}, 0)
+	_, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read")
+	require.False(t, reader.retryable(networkError), "consumed files are not retryable")
+
+	reader = newUploadReader(context.Background(), common.NewErrorReaderString("io error"), &rateLimiter{}, 0)
+	_, err = io.ReadAll(reader)
+	common.RequireError(t, err, "io error")
+	require.False(t, reader.retryable(networkError), "reader errors are not retryable")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reader = newUploadReader(ctx, &bytes.Buffer{}, &rateLimiter{}, 0)
+	require.False(t, reader.retryable(networkError), "canceled uploads are not retryable")
+
+	// Seekable files can be sent again once they have been read
+	reader = newUploadReader(context.Background(), bytes.NewReader([]byte("data")), &rateLimiter{}, 0)
+	_, err = io.ReadAll(reader)
+	require.NoError(t, err, "unable to read")
+	require.True(t, reader.retryable(networkError), "consumed seekable files are retryable")
+}
+
+func TestUploadReaderRewind(t *testing.T) {
+	source := bytes.NewReader([]byte("0123456789"))
+	_, err := source.Seek(2, io.SeekStart)
+	require.NoError(t, err, "unable to seek")
+
+	reader := newUploadReader(context.Background(), source, &rateLimiter{}, 0)
+	err = reader.rewind()
+	require.NoError(t, err, "files not read yet don't need to be rewound")
+
+	buf := make([]byte, 3)
+	_, err = io.ReadFull(reader, buf)
+	require.NoError(t, err, "unable to read")
+	require.Equal(t, "234", string(buf), "invalid content")
+
+	// Rewind to the offset the file started at
+	err = reader.rewind()
+	require.NoError(t, err, "unable to rewind")
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read")
+	require.Equal(t, "23456789", string(content), "invalid content after rewind")
+
+	reader = newUploadReader(context.Background(), bytes.NewBufferString("data"), &rateLimiter{}, 0)
+	_, err = io.ReadAll(reader)
+	require.NoError(t, err, "unable to read")
+	common.RequireError(t, reader.rewind(), "can't be read from the start again")
+}
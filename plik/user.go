@@ -0,0 +1,158 @@
+package plik
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// The user API authenticates the user with the client token ( Client.Token )
+// GET calls need a token with the read scope and token management calls a token with the token scope
+
+// GetUserInfo return the user the client token belongs to
+func (c *Client) GetUserInfo() (user *common.User, err error) {
+	user = &common.User{}
+	err = c.userCall("GET", "/me", nil, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetUserUploads return a page of the uploads of the user, most recent first
+// Pass the returned cursor to get the next page, there are no more uploads if it is empty
+func (c *Client) GetUserUploads(limit int, cursor string) (uploads []*Upload, next string, err error) {
+	var results []*common.Upload
+	next, err = c.userPage("/me/uploads", limit, cursor, &results)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, params := range results {
+		uploads = append(uploads, newUploadFromMetadata(c, params))
+	}
+
+	return uploads, next, nil
+}
+
+// GetUserTokens return a page of the tokens of the user, most recent first
+// Pass the returned cursor to get the next page, there are no more tokens if it is empty
+func (c *Client) GetUserTokens(limit int, cursor string) (tokens []*common.Token, next string, err error) {
+	next, err = c.userPage("/me/token", limit, cursor, &tokens)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return tokens, next, nil
+}
+
+// CreateToken create a new token for the user
+// An empty list of scopes grants everything the user can do but the admin and token scopes
+func (c *Client) CreateToken(comment string, scopes ...string) (token *common.Token, err error) {
+	params := &common.Token{Comment: comment, Scope: strings.Join(scopes, " ")}
+
+	j, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	token = &common.Token{}
+	err = c.userCall("POST", "/me/token", bytes.NewBuffer(j), token)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// RevokeToken remove a token of the user
+func (c *Client) RevokeToken(token string) (err error) {
+	return c.userCall("DELETE", "/me/token/"+url.PathEscape(token), nil, nil)
+}
+
+// GetUserStatistics return the number of uploads and files of the user and the total size used
+func (c *Client) GetUserStatistics() (stats *common.UserStats, err error) {
+	stats = &common.UserStats{}
+	err = c.userCall("GET", "/me/stats", nil, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// userPage fetch a page of a paginated user API call
+func (c *Client) userPage(path string, limit int, cursor string, results interface{}) (next string, err error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if cursor != "" {
+		query.Set("after", cursor)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	page := &struct {
+		After   *string         `json:"after"`
+		Results json.RawMessage `json:"results"`
+	}{}
+	err = c.userCall("GET", path, nil, page)
+	if err != nil {
+		return "", err
+	}
+
+	err = json.Unmarshal(page.Results, results)
+	if err != nil {
+		return "", err
+	}
+
+	if page.After != nil {
+		next = *page.After
+	}
+
+	return next, nil
+}
+
+// userCall make a user API call and parse the json response in result if not nil
+func (c *Client) userCall(method string, path string, body io.Reader, result interface{}) (err error) {
+	req, err := c.UploadRequest(&common.Upload{Token: c.Token}, method, c.URL+path, body)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.MakeRequest(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	// Parse json response
+	err = json.Unmarshal(content, result)
+	if err != nil {
+		return fmt.Errorf("invalid response from %s %s : %s", method, path, err)
+	}
+
+	return nil
+}
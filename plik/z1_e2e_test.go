@@ -2,6 +2,7 @@ package plik
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -33,12 +34,12 @@ func TestUploadFileTwice(t *testing.T) {
 	file := &common.File{}
 	file.Name = "filename"
 
-	fileParams, err := pc.uploadFile(uploadParams, file, bytes.NewBufferString("data"))
+	fileParams, err := pc.uploadFile(context.Background(), uploadParams, file, bytes.NewBufferString("data"))
 	require.NoError(t, err, "unable to upload file")
 	require.NotNil(t, fileParams, "invalid nil file params")
 	require.NotZero(t, fileParams.ID, "invalid file id")
 
-	_, err = pc.uploadFile(uploadParams, fileParams, bytes.NewBufferString("data"))
+	_, err = pc.uploadFile(context.Background(), uploadParams, fileParams, bytes.NewBufferString("data"))
 	require.Error(t, err, "missing error")
 	require.Contains(t, err.Error(), "invalid file status uploaded, expected missing", "invalid error")
 }
@@ -267,6 +268,57 @@ func TestStream(t *testing.T) {
 	require.Contains(t, err.Error(), fmt.Sprintf("file %s (%s) is not available : deleted", file.Name, file.metadata.ID), "invalid error")
 }
 
+func TestE2EE(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	pc.E2EE = true
+	pc.ChunkSize = 1024
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	data := strings.Repeat("data ", 100000)
+	upload, file, err := pc.UploadReader("filename", bytes.NewBufferString(data))
+	require.NoError(t, err, "unable to upload file")
+	require.True(t, upload.Metadata().E2EE, "invalid upload non e2ee")
+
+	URL, err := file.GetURL()
+	require.NoError(t, err, "unable to get file url")
+	require.NotEmpty(t, URL.Fragment, "missing key in url fragment")
+
+	// The server only has the encrypted file
+	reader, err := pc.downloadFile(upload.Metadata(), file.Metadata())
+	require.NoError(t, err, "unable to download file")
+	ciphertext, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.NotContains(t, string(ciphertext), "data", "file is not encrypted")
+	require.Equal(t, int64(len(ciphertext)), file.Metadata().Size, "invalid file size")
+
+	decrypter, err := NewE2EEReader(bytes.NewReader(ciphertext), URL.Fragment)
+	require.NoError(t, err, "unable to decrypt file")
+	content, err := io.ReadAll(decrypter)
+	require.NoError(t, err, "unable to decrypt file")
+	require.Equal(t, data, string(content), "invalid file content")
+
+	// The uploading client knows the key
+	reader, err = file.Download()
+	require.NoError(t, err, "unable to download file")
+	content, err = io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, data, string(content), "invalid file content")
+
+	// Other clients don't
+	upload, err = pc.GetUpload(upload.ID())
+	require.NoError(t, err, "unable to get upload")
+	require.True(t, upload.E2EE, "invalid upload non e2ee")
+	require.Len(t, upload.Files(), 1, "invalid file count")
+
+	URL, err = upload.Files()[0].GetURL()
+	require.NoError(t, err, "unable to get file url")
+	require.Empty(t, URL.Fragment, "unexpected key in url fragment")
+}
+
 func TestTTL(t *testing.T) {
 	ps, pc := newPlikServerAndClient()
 	defer shutdown(ps)
@@ -361,3 +413,70 @@ func TestCreateUploadWithForbidenOptions(t *testing.T) {
 	require.NotEqual(t, uploadToCreate.UploadToken, upload.Metadata().UploadToken, "invalid upload download domain")
 	require.NotEqual(t, uploadToCreate.CreatedAt, upload.Metadata().CreatedAt, "invalid upload download domain")
 }
+
+func TestResumableUpload(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	pc.ChunkSize = 4
+
+	content := "data data data"
+	upload := pc.NewUpload()
+	file := upload.AddFileFromReader("filename", bytes.NewBufferString(content))
+
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload file")
+	require.Equal(t, common.FileUploaded, file.Metadata().Status, "invalid file status")
+	require.Equal(t, int64(len(content)), file.Metadata().Size, "invalid file size")
+
+	reader, err := file.Download()
+	require.NoError(t, err, "unable to download file")
+	defer func() { _ = reader.Close() }()
+
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, content, string(body), "invalid file content")
+}
+
+func TestResumableUploadResume(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	pc.ChunkSize = 4
+
+	uploadToCreate := &common.Upload{}
+	uploadToCreate.NewFile().Name = "filename"
+	uploadParams, err := pc.create(uploadToCreate)
+	require.NoError(t, err, "unable to create upload")
+	require.Len(t, uploadParams.Files, 1, "invalid file count")
+	fileParams := uploadParams.Files[0]
+
+	// Simulate an interrupted upload
+	URL := pc.URL + "/chunk/" + uploadParams.ID + "/" + fileParams.ID + "/" + fileParams.Name
+	err = pc.patchChunk(context.Background(), uploadParams, URL, 0, []byte("data "))
+	require.NoError(t, err, "unable to upload chunk")
+
+	offset, err := pc.getFileUploadOffset(context.Background(), uploadParams, URL)
+	require.NoError(t, err, "unable to get upload offset")
+	require.Equal(t, int64(5), offset, "invalid upload offset")
+
+	content := "data data data"
+	fileInfo, err := pc.uploadFileChunks(context.Background(), uploadParams, fileParams, bytes.NewBufferString(content))
+	require.NoError(t, err, "unable to resume upload")
+	require.Equal(t, common.FileUploaded, fileInfo.Status, "invalid file status")
+	require.Equal(t, int64(len(content)), fileInfo.Size, "invalid file size")
+
+	reader, err := pc.downloadFile(uploadParams, fileInfo)
+	require.NoError(t, err, "unable to download file")
+	defer func() { _ = reader.Close() }()
+
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, content, string(body), "invalid file content")
+}
@@ -0,0 +1,170 @@
+package plik
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestUserAPI(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	ps.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	user := common.NewUser("local", "user-api")
+	user.Name = "plik"
+	token := user.NewToken()
+
+	// Token management needs a token explicitly granted the admin scope
+	token.Scope = strings.Join(common.TokenScopes, " ")
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start Plik server")
+
+	err = ps.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	pc.Token = token.Token
+
+	info, err := pc.GetUserInfo()
+	require.NoError(t, err, "unable to get user info")
+	require.Equal(t, user.ID, info.ID, "invalid user")
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		upload, _, err := pc.UploadReader("filename", bytes.NewBufferString("data"))
+		require.NoError(t, err, "unable to upload file")
+		ids = append([]string{upload.ID()}, ids...)
+	}
+
+	// Other uploads are not listed
+	pc.Token = ""
+	_, _, err = pc.UploadReader("filename", bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to upload file")
+	pc.Token = token.Token
+
+	var listed []string
+	cursor := ""
+	for {
+		uploads, next, err := pc.GetUserUploads(2, cursor)
+		require.NoError(t, err, "unable to get user uploads")
+		for _, upload := range uploads {
+			listed = append(listed, upload.ID())
+			require.Len(t, upload.Files(), 1, "invalid file count")
+			require.Equal(t, "filename", upload.Files()[0].Name, "invalid file name")
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	require.Equal(t, ids, listed, "invalid user uploads")
+
+	stats, err := pc.GetUserStatistics()
+	require.NoError(t, err, "unable to get user statistics")
+	require.Equal(t, 3, stats.Uploads, "invalid upload count")
+	require.Equal(t, 3, stats.Files, "invalid file count")
+	require.Equal(t, int64(12), stats.TotalSize, "invalid total size")
+
+	readToken, err := pc.CreateToken("read only", common.TokenScopeRead)
+	require.NoError(t, err, "unable to create token")
+	require.Equal(t, "read only", readToken.Comment, "invalid token comment")
+	require.Equal(t, common.TokenScopeRead, readToken.Scope, "invalid token scope")
+
+	tokens, next, err := pc.GetUserTokens(10, "")
+	require.NoError(t, err, "unable to get user tokens")
+	require.Len(t, tokens, 2, "invalid token count")
+	require.Empty(t, next, "unexpected cursor")
+
+	// Token management needs the token scope
+	pc.Token = readToken.Token
+	_, err = pc.GetUserStatistics()
+	require.NoError(t, err, "unable to get user statistics")
+	_, _, err = pc.GetUserTokens(10, "")
+	common.RequireError(t, err, "token does not have the token scope")
+	_, err = pc.CreateToken("escalation")
+	common.RequireError(t, err, "token does not have the token scope")
+
+	pc.Token = token.Token
+	err = pc.RevokeToken(readToken.Token)
+	require.NoError(t, err, "unable to revoke token")
+
+	tokens, _, err = pc.GetUserTokens(10, "")
+	require.NoError(t, err, "unable to get user tokens")
+	require.Len(t, tokens, 1, "invalid token count")
+
+	pc.Token = ""
+	_, err = pc.GetUserInfo()
+	common.RequireError(t, err, "you must be authenticated")
+}
+
+func TestDownloadFrom(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start Plik server")
+
+	data := "0123456789"
+	upload, _, err := pc.UploadReader("filename", bytes.NewBufferString(data))
+	require.NoError(t, err, "unable to upload file")
+
+	upload, err = pc.GetUpload(upload.ID())
+	require.NoError(t, err, "unable to get upload")
+	file := upload.Files()[0]
+
+	reader, start, err := file.DownloadFrom(4, strings.NewReader(data[:4]))
+	require.NoError(t, err, "unable to download file")
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, int64(4), start, "invalid start offset")
+	require.Equal(t, data[4:], string(content), "invalid file content")
+
+	// The digest of the whole file is verified
+	reader, _, err = file.DownloadFrom(4, strings.NewReader("xxxx"))
+	require.NoError(t, err, "unable to download file")
+	_, err = io.ReadAll(reader)
+	require.ErrorIs(t, err, ErrDigestMismatch, "invalid error")
+
+	_, _, err = file.DownloadFrom(4, strings.NewReader("xx"))
+	common.RequireError(t, err, "unable to read the downloaded content")
+
+	// The whole file is sent if it changed
+	file.metadata.Md5 = "changed"
+	reader, start, err = file.DownloadFrom(4, strings.NewReader(data[:4]))
+	require.NoError(t, err, "unable to download file")
+	content, err = io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, int64(0), start, "invalid start offset")
+	require.Equal(t, data, string(content), "invalid file content")
+}
+
+func TestGetUploadWithUploadToken(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start Plik server")
+
+	upload, _, err := pc.UploadReader("filename", bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to upload file")
+
+	other, err := pc.GetUpload(upload.ID())
+	require.NoError(t, err, "unable to get upload")
+	err = other.Delete()
+	common.RequireError(t, err, "you are not allowed to remove this upload")
+
+	other, err = pc.GetUploadWithUploadToken(upload.ID(), upload.Metadata().UploadToken)
+	require.NoError(t, err, "unable to get upload")
+	err = other.Delete()
+	require.NoError(t, err, "unable to remove upload")
+
+	_, err = pc.GetUpload(upload.ID())
+	common.RequireError(t, err, "not found")
+}
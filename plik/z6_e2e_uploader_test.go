@@ -0,0 +1,246 @@
+package plik
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// concurrencyReader track how many files are being read at the same time
+type concurrencyReader struct {
+	reader  io.Reader
+	active  *int32
+	max     *int32
+	started bool
+}
+
+func (r *concurrencyReader) Read(p []byte) (n int, err error) {
+	if !r.started {
+		r.started = true
+		active := atomic.AddInt32(r.active, 1)
+		for {
+			max := atomic.LoadInt32(r.max)
+			if active <= max || atomic.CompareAndSwapInt32(r.max, max, active) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	n, err = r.reader.Read(p)
+	if err == io.EOF {
+		atomic.AddInt32(r.active, -1)
+	}
+	return n, err
+}
+
+func TestUploadMaxParallelFiles(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	pc.Uploader.MaxParallelFiles = 2
+
+	var active, max int32
+	upload := pc.NewUpload()
+	for i := 0; i < 6; i++ {
+		reader := &concurrencyReader{reader: bytes.NewBufferString(fmt.Sprintf("data %d", i)), active: &active, max: &max}
+		upload.AddFileFromReader(fmt.Sprintf("file_%d", i), reader)
+	}
+
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload files")
+	require.Equal(t, int32(2), max, "invalid number of files uploaded in parallel")
+
+	for _, file := range upload.Files() {
+		require.NoError(t, file.Error(), "unable to upload file")
+	}
+}
+
+// newFlakyProxy forward the requests to the Plik server but reject the first file uploads with the given status code
+func newFlakyProxy(t *testing.T, target string, failures int32, status int) (proxy *httptest.Server, uploads *int32) {
+	targetURL, err := url.Parse(target)
+	require.NoError(t, err, "invalid server url")
+
+	uploads = new(int32)
+	reverseProxy := httputil.NewSingleHostReverseProxy(targetURL)
+	proxy = httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" && strings.HasPrefix(req.URL.Path, "/file/") {
+			if atomic.AddInt32(uploads, 1) <= failures {
+				http.Error(resp, "try again later", status)
+				return
+			}
+		}
+		reverseProxy.ServeHTTP(resp, req)
+	}))
+
+	return proxy, uploads
+}
+
+func TestUploadRetry(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	proxy, uploads := newFlakyProxy(t, pc.URL, 2, http.StatusServiceUnavailable)
+	defer proxy.Close()
+
+	pc.URL = proxy.URL
+	pc.Uploader.RetryBackoff = 10 * time.Millisecond
+
+	upload, file, err := pc.UploadReader("filename", bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to upload file")
+	require.Equal(t, int32(3), atomic.LoadInt32(uploads), "invalid number of attempts")
+
+	reader, err := file.Download()
+	require.NoError(t, err, "unable to download file")
+	defer func() { _ = reader.Close() }()
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(content), "invalid file content")
+	require.NotEmpty(t, upload.ID(), "missing upload id")
+}
+
+func TestUploadRetryAfterPartialRead(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	targetURL, err := url.Parse(pc.URL)
+	require.NoError(t, err, "invalid server url")
+
+	// The first attempt fails after part of the file has been sent
+	var uploads int32
+	reverseProxy := httputil.NewSingleHostReverseProxy(targetURL)
+	proxy := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" && strings.HasPrefix(req.URL.Path, "/file/") && atomic.AddInt32(&uploads, 1) == 1 {
+			_, _ = io.CopyN(io.Discard, req.Body, 64*1024)
+			http.Error(resp, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		reverseProxy.ServeHTTP(resp, req)
+	}))
+	defer proxy.Close()
+
+	pc.URL = proxy.URL
+	pc.Uploader.RetryBackoff = 10 * time.Millisecond
+
+	data := bytes.Repeat([]byte("data"), 256*1024)
+	_, file, err := pc.UploadReader("filename", bytes.NewReader(data))
+	require.NoError(t, err, "unable to upload file")
+	require.Equal(t, int32(2), atomic.LoadInt32(&uploads), "invalid number of attempts")
+
+	reader, err := file.Download()
+	require.NoError(t, err, "unable to download file")
+	defer func() { _ = reader.Close() }()
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.True(t, bytes.Equal(data, content), "invalid file content")
+
+	// Files that can't be read again are not sent again
+	atomic.StoreInt32(&uploads, 0)
+	_, _, err = pc.UploadReader("filename", bytes.NewBuffer(data))
+	require.Error(t, err, "missing error")
+	require.Equal(t, int32(1), atomic.LoadInt32(&uploads), "consumed files must not be sent again")
+}
+
+func TestUploadRetryGiveUp(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	proxy, uploads := newFlakyProxy(t, pc.URL, 10, http.StatusServiceUnavailable)
+	defer proxy.Close()
+
+	pc.URL = proxy.URL
+	pc.Uploader.RetryBackoff = 10 * time.Millisecond
+	pc.Uploader.FileRetries = 2
+
+	_, file, err := pc.UploadReader("filename", bytes.NewBufferString("data"))
+	require.Error(t, err, "missing error")
+	require.Contains(t, file.Error().Error(), "try again later", "invalid error")
+	require.Equal(t, int32(3), atomic.LoadInt32(uploads), "invalid number of attempts")
+}
+
+func TestUploadNoRetryOnClientError(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	proxy, uploads := newFlakyProxy(t, pc.URL, 10, http.StatusBadRequest)
+	defer proxy.Close()
+
+	pc.URL = proxy.URL
+	pc.Uploader.RetryBackoff = 10 * time.Millisecond
+
+	_, _, err = pc.UploadReader("filename", bytes.NewBufferString("data"))
+	require.Error(t, err, "missing error")
+	require.Equal(t, int32(1), atomic.LoadInt32(uploads), "client errors must not be retried")
+}
+
+func TestUploadContextCancel(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	upload := pc.NewUpload()
+	reader := NewLockedReader(bytes.NewBufferString("data"))
+	file := upload.AddFileFromReader("filename", reader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	err = upload.UploadContext(ctx)
+	require.Error(t, err, "missing error")
+	require.ErrorIs(t, file.Error(), context.Canceled, "invalid error")
+	reader.Unleash()
+}
+
+func TestUploadRateLimit(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	pc.Uploader.RateLimit = 10000
+
+	// The rate limit is shared by all the files
+	upload := pc.NewUpload()
+	for i := 0; i < 3; i++ {
+		upload.AddFileFromReader(fmt.Sprintf("file_%d", i), bytes.NewReader(make([]byte, 1000)))
+	}
+
+	start := time.Now()
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload files")
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond, "rate limit exceeded")
+}
@@ -0,0 +1,181 @@
+package clamav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+/*
+  clamd INSTREAM protocol :
+    - Send "zINSTREAM\0"
+    - Send the data in chunks, each chunk is prefixed by its length as a 4 bytes unsigned integer in network byte order
+    - Send a zero length chunk to mark the end of the stream
+    - Read the verdict terminated by "\0" :
+        "stream: OK"                      -> clean
+        "stream: <signature> FOUND"       -> infected
+        "<reason> ERROR"                  -> error ( ex : INSTREAM size limit exceeded )
+*/
+
+// Scanner scan data using a clamd daemon
+type Scanner struct {
+	network   string
+	address   string
+	timeout   time.Duration
+	chunkSize int
+}
+
+// Result of a scan
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// NewScanner create a new clamd scanner.
+// Address is either tcp://host:port, unix:///path/to/clamd.sock, host:port or /path/to/clamd.sock
+func NewScanner(address string, timeout time.Duration) (s *Scanner, err error) {
+	s = new(Scanner)
+	s.timeout = timeout
+	s.chunkSize = 64 * 1024
+
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		s.network = "tcp"
+		s.address = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		s.network = "unix"
+		s.address = strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "/"):
+		s.network = "unix"
+		s.address = address
+	case strings.Contains(address, "://"):
+		return nil, fmt.Errorf("invalid clamd address %s, expected tcp:// or unix://", address)
+	default:
+		s.network = "tcp"
+		s.address = address
+	}
+
+	if s.address == "" {
+		return nil, fmt.Errorf("missing clamd address")
+	}
+
+	return s, nil
+}
+
+func (s *Scanner) dial() (conn net.Conn, err error) {
+	conn, err = net.DialTimeout(s.network, s.address, s.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to clamd : %s", err)
+	}
+	return conn, nil
+}
+
+// readReply read a "\0" terminated reply from clamd
+func (s *Scanner) readReply(conn net.Conn) (reply string, err error) {
+	_ = conn.SetReadDeadline(time.Now().Add(s.timeout))
+	reply, err = bufio.NewReader(conn).ReadString(0)
+	if err != nil && (err != io.EOF || reply == "") {
+		return "", fmt.Errorf("unable to read clamd reply : %s", err)
+	}
+	return strings.TrimSpace(strings.TrimRight(reply, "\x00")), nil
+}
+
+// Ping check that clamd is reachable
+func (s *Scanner) Ping() (err error) {
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	_ = conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	_, err = conn.Write([]byte("zPING\x00"))
+	if err != nil {
+		return fmt.Errorf("unable to send clamd command : %s", err)
+	}
+
+	reply, err := s.readReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected clamd reply : %s", reply)
+	}
+
+	return nil
+}
+
+// Scan stream the data to clamd and return the verdict.
+// The timeout applies to each network operation so large files can be scanned.
+func (s *Scanner) Scan(reader io.Reader) (result *Result, err error) {
+	conn, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	write := func(b []byte) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		_, err := conn.Write(b)
+		return err
+	}
+
+	err = write([]byte("zINSTREAM\x00"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to send clamd command : %s", err)
+	}
+
+	buf := make([]byte, 4+s.chunkSize)
+	for {
+		n, errRead := io.ReadFull(reader, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			err = write(buf[:4+n])
+			if err != nil {
+				// clamd closes the connection when the stream is too big, try to get the reason
+				if reply, errReply := s.readReply(conn); errReply == nil && reply != "" {
+					return nil, fmt.Errorf("clamd error : %s", reply)
+				}
+				return nil, fmt.Errorf("unable to send data to clamd : %s", err)
+			}
+		}
+		if errRead == io.EOF || errRead == io.ErrUnexpectedEOF {
+			break
+		}
+		if errRead != nil {
+			return nil, fmt.Errorf("unable to read data to scan : %s", errRead)
+		}
+	}
+
+	// End of stream
+	err = write([]byte{0, 0, 0, 0})
+	if err != nil {
+		return nil, fmt.Errorf("unable to send data to clamd : %s", err)
+	}
+
+	reply, err := s.readReply(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseReply(reply)
+}
+
+func parseReply(reply string) (result *Result, err error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case reply == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.HasSuffix(reply, " ERROR"):
+		return nil, fmt.Errorf("clamd error : %s", strings.TrimSuffix(reply, " ERROR"))
+	default:
+		return nil, fmt.Errorf("unexpected clamd reply : %s", reply)
+	}
+}
@@ -0,0 +1,136 @@
+package clamav
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	clamd "github.com/root-gg/plik/server/clamav/testing"
+	"github.com/root-gg/plik/server/common"
+)
+
+func newTestScanner(t *testing.T) (scanner *Scanner, server *clamd.Server) {
+	server, err := clamd.NewServer()
+	require.NoError(t, err, "unable to start fake clamd")
+
+	scanner, err = NewScanner(server.Address(), time.Second)
+	require.NoError(t, err, "unable to create scanner")
+
+	return scanner, server
+}
+
+func TestNewScanner(t *testing.T) {
+	s, err := NewScanner("tcp://127.0.0.1:3310", time.Second)
+	require.NoError(t, err, "unable to create scanner")
+	require.Equal(t, "tcp", s.network, "invalid network")
+	require.Equal(t, "127.0.0.1:3310", s.address, "invalid address")
+
+	s, err = NewScanner("127.0.0.1:3310", time.Second)
+	require.NoError(t, err, "unable to create scanner")
+	require.Equal(t, "tcp", s.network, "invalid network")
+
+	s, err = NewScanner("unix:///var/run/clamav/clamd.ctl", time.Second)
+	require.NoError(t, err, "unable to create scanner")
+	require.Equal(t, "unix", s.network, "invalid network")
+	require.Equal(t, "/var/run/clamav/clamd.ctl", s.address, "invalid address")
+
+	s, err = NewScanner("/var/run/clamav/clamd.ctl", time.Second)
+	require.NoError(t, err, "unable to create scanner")
+	require.Equal(t, "unix", s.network, "invalid network")
+
+	_, err = NewScanner("http://127.0.0.1:3310", time.Second)
+	common.RequireError(t, err, "invalid clamd address")
+
+	_, err = NewScanner("tcp://", time.Second)
+	common.RequireError(t, err, "missing clamd address")
+}
+
+func TestPing(t *testing.T) {
+	scanner, server := newTestScanner(t)
+	defer server.Close()
+
+	err := scanner.Ping()
+	require.NoError(t, err, "unable to ping clamd")
+}
+
+func TestScanClean(t *testing.T) {
+	scanner, server := newTestScanner(t)
+	defer server.Close()
+
+	result, err := scanner.Scan(bytes.NewBufferString("clean data"))
+	require.NoError(t, err, "unable to scan")
+	require.False(t, result.Infected, "data should be clean")
+	require.Equal(t, 1, server.Scans(), "invalid scan count")
+}
+
+func TestScanEmpty(t *testing.T) {
+	scanner, server := newTestScanner(t)
+	defer server.Close()
+
+	result, err := scanner.Scan(&bytes.Buffer{})
+	require.NoError(t, err, "unable to scan")
+	require.False(t, result.Infected, "data should be clean")
+}
+
+func TestScanInfected(t *testing.T) {
+	scanner, server := newTestScanner(t)
+	defer server.Close()
+
+	// Spread the data over several chunks
+	scanner.chunkSize = 16
+	data := strings.Repeat("x", 100) + clamd.EICAR + strings.Repeat("x", 100)
+
+	result, err := scanner.Scan(bytes.NewBufferString(data))
+	require.NoError(t, err, "unable to scan")
+	require.True(t, result.Infected, "data should be infected")
+	require.Equal(t, clamd.EICARSignature, result.Signature, "invalid signature")
+}
+
+func TestScanError(t *testing.T) {
+	scanner, server := newTestScanner(t)
+	defer server.Close()
+
+	server.SetError("INSTREAM size limit exceeded.")
+	_, err := scanner.Scan(bytes.NewBufferString("data"))
+	common.RequireError(t, err, "clamd error : INSTREAM size limit exceeded.")
+}
+
+type errorReader struct{}
+
+func (errorReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read error")
+}
+
+func TestScanReadError(t *testing.T) {
+	scanner, server := newTestScanner(t)
+	defer server.Close()
+
+	_, err := scanner.Scan(errorReader{})
+	common.RequireError(t, err, "unable to read data to scan : read error")
+}
+
+func TestScanUnreachable(t *testing.T) {
+	scanner, server := newTestScanner(t)
+	server.Close()
+
+	_, err := scanner.Scan(bytes.NewBufferString("data"))
+	common.RequireError(t, err, "unable to connect to clamd")
+}
+
+func TestParseReply(t *testing.T) {
+	result, err := parseReply("stream: OK")
+	require.NoError(t, err, "unable to parse reply")
+	require.False(t, result.Infected, "invalid result")
+
+	result, err = parseReply("stream: Win.Test.EICAR_HDB-1 FOUND")
+	require.NoError(t, err, "unable to parse reply")
+	require.True(t, result.Infected, "invalid result")
+	require.Equal(t, "Win.Test.EICAR_HDB-1", result.Signature, "invalid signature")
+
+	_, err = parseReply("foo")
+	common.RequireError(t, err, "unexpected clamd reply : foo")
+}
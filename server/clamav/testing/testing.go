@@ -0,0 +1,118 @@
+package clamd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// EICAR is the standard antivirus test file content
+const EICAR = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// EICARSignature is the signature reported for the EICAR test file
+const EICARSignature = "Eicar-Test-Signature"
+
+// Server is a fake clamd daemon implementing the PING and INSTREAM commands.
+// Streams containing the EICAR test string are reported as infected.
+type Server struct {
+	listener net.Listener
+	mu       sync.Mutex
+	err      string
+	scans    int
+}
+
+// NewServer start a new fake clamd server listening on a random local TCP port
+func NewServer() (s *Server, err error) {
+	s = new(Server)
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+
+	return s, nil
+}
+
+// Address return the clamd address of the server
+func (s *Server) Address() string {
+	return "tcp://" + s.listener.Addr().String()
+}
+
+// Close stop the server
+func (s *Server) Close() {
+	_ = s.listener.Close()
+}
+
+// SetError make the server reply with an error to the following scans ( empty to disable )
+func (s *Server) SetError(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = reason
+}
+
+// Scans return the number of completed scans
+func (s *Server) Scans() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scans
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch strings.TrimRight(command, "\x00") {
+	case "zPING":
+		_, _ = conn.Write([]byte("PONG\x00"))
+	case "zINSTREAM":
+		data := &bytes.Buffer{}
+		for {
+			var size uint32
+			err = binary.Read(r, binary.BigEndian, &size)
+			if err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			_, err = io.CopyN(data, r, int64(size))
+			if err != nil {
+				return
+			}
+		}
+
+		s.mu.Lock()
+		s.scans++
+		reason := s.err
+		s.mu.Unlock()
+
+		switch {
+		case reason != "":
+			_, _ = conn.Write([]byte(reason + " ERROR\x00"))
+		case bytes.Contains(data.Bytes(), []byte(EICAR)):
+			_, _ = conn.Write([]byte("stream: " + EICARSignature + " FOUND\x00"))
+		default:
+			_, _ = conn.Write([]byte("stream: OK\x00"))
+		}
+	default:
+		_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+	}
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+type auditFlagParams struct {
+	user   string
+	upload string
+	action string
+	limit  int
+	after  string
+}
+
+var auditParams = auditFlagParams{}
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "List audit events",
+	Long: `List audit events, most recent first.
+
+Use the cursor printed after the events with --after to display the next page.`,
+	Run: listAuditEvents,
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().StringVar(&auditParams.user, "user", "", "filter by user ID ( provider:login )")
+	auditCmd.Flags().StringVar(&auditParams.upload, "upload", "", "filter by upload ID")
+	auditCmd.Flags().StringVar(&auditParams.action, "action", "", "filter by action [upload|download|delete|login|token_create|impersonate]")
+	auditCmd.Flags().IntVar(&auditParams.limit, "limit", 100, "maximum number of events to display")
+	auditCmd.Flags().StringVar(&auditParams.after, "after", "", "paging cursor")
+}
+
+func listAuditEvents(cmd *cobra.Command, args []string) {
+	initializeMetadataBackend()
+
+	if auditParams.action != "" {
+		err := common.ValidateAuditAction(auditParams.action)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if auditParams.limit <= 0 {
+		fmt.Println("invalid limit")
+		os.Exit(1)
+	}
+
+	pagingQuery := common.NewPagingQuery().WithLimit(auditParams.limit)
+	if auditParams.after != "" {
+		pagingQuery.WithAfterCursor(auditParams.after)
+	}
+
+	events, cursor, err := metadataBackend.GetAuditEvents(auditParams.user, auditParams.upload, auditParams.action, pagingQuery)
+	if err != nil {
+		fmt.Printf("Unable to get audit events : %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, event := range events {
+		fmt.Println(event.String())
+	}
+
+	if cursor != nil && cursor.After != nil {
+		fmt.Printf("next page : --after %s\n", *cursor.After)
+	}
+}
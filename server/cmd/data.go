@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/data/dedup"
+	"github.com/root-gg/plik/server/data/fallback"
+	"github.com/root-gg/plik/server/server"
+)
+
+type dataFlagParams struct {
+	from    string
+	to      string
+	restart bool
+}
+
+var dataParams = dataFlagParams{}
+
+// dataCmd represents all data command
+var dataCmd = &cobra.Command{
+	Use:   "data",
+	Short: "Manipulate the data backend",
+}
+
+// migrateDataCmd represents the "data migrate" command
+var migrateDataCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the files to another data backend",
+	Long: `Copy the uploaded files from a data backend to another and verify their md5sum.
+
+The data backends are read from the --from and --to configuration files, by default
+the files are migrated from the FallbackDataBackend to the DataBackend of the current configuration.
+
+To migrate without downtime configure the servers with the new data backend as DataBackend and the
+previous one as FallbackDataBackend first. The migration can be interrupted, it resumes from the
+last migrated file unless --restart is set.`,
+	Run: migrateData,
+}
+
+// demoteDataCmd represents the "data demote" command
+var demoteDataCmd = &cobra.Command{
+	Use:   "demote",
+	Short: "Move the cold files to the cold data backend",
+	Long: `Move the uploaded files matching the cold storage policy ( ColdStorageMinAgeStr, ColdStorageIdleTimeStr
+and ColdStorageMinSizeStr ) from the DataBackend to the ColdDataBackend.
+
+The cleaning routine does the same at each run when a ColdDataBackend is configured.`,
+	Run: demoteData,
+}
+
+func init() {
+	rootCmd.AddCommand(dataCmd)
+
+	dataCmd.AddCommand(migrateDataCmd)
+	migrateDataCmd.Flags().StringVar(&dataParams.from, "from", "", "configuration file of the data backend to migrate the files from")
+	migrateDataCmd.Flags().StringVar(&dataParams.to, "to", "", "configuration file of the data backend to migrate the files to")
+	migrateDataCmd.Flags().BoolVar(&dataParams.restart, "restart", false, "ignore the checkpoint of a previous migration")
+
+	dataCmd.AddCommand(demoteDataCmd)
+}
+
+func migrateData(cmd *cobra.Command, args []string) {
+	initializeMetadataBackend()
+
+	from := newDataBackendFromConfig(dataParams.from, config.FallbackDataBackend, config.FallbackDataBackendConfig)
+	to := newDataBackendFromConfig(dataParams.to, config.DataBackend, config.DataBackendConfig)
+
+	var backend data.Backend = fallback.NewBackend(to, from)
+	if config.Deduplication {
+		backend = dedup.NewBackend(backend, metadataBackend)
+	}
+
+	plik := server.NewPlikServer(config)
+	plik.WithMetadataBackend(metadataBackend)
+	plik.WithDataBackend(backend)
+
+	report := func(file *common.File, result string, err error) {
+		if err != nil {
+			fmt.Printf("%s %s %s : %s\n", file.UploadID, file.ID, result, err)
+		} else {
+			fmt.Printf("%s %s %s %s\n", file.UploadID, file.ID, result, humanize.Bytes(uint64(file.Size)))
+		}
+	}
+
+	stats, err := plik.MigrateData(&server.MigrateOptions{Restart: dataParams.restart}, report)
+	if err != nil {
+		fmt.Printf("Unable to migrate files : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d files migrated ( %s ), %d skipped, %d errors\n", stats.Migrated, humanize.Bytes(uint64(stats.Size)), stats.Skipped, stats.Errors)
+
+	if stats.Errors > 0 {
+		os.Exit(1)
+	}
+}
+
+func demoteData(cmd *cobra.Command, args []string) {
+	if config.ColdDataBackend == "" {
+		fmt.Println("Missing ColdDataBackend to move the files to")
+		os.Exit(1)
+	}
+
+	plik := server.NewPlikServer(config)
+
+	initializeMetadataBackend()
+	plik.WithMetadataBackend(metadataBackend)
+
+	initializeDataBackend()
+	plik.WithDataBackend(dataBackend)
+
+	report := func(file *common.File, result string, err error) {
+		if err != nil {
+			fmt.Printf("%s %s %s : %s\n", file.UploadID, file.ID, result, err)
+		} else {
+			fmt.Printf("%s %s %s %s\n", file.UploadID, file.ID, result, humanize.Bytes(uint64(file.Size)))
+		}
+	}
+
+	stats, err := plik.DemoteFiles(report)
+	if err != nil {
+		fmt.Printf("Unable to move files : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d files moved to the cold data backend ( %s ), %d errors\n", stats.Demoted, humanize.Bytes(uint64(stats.Size)), stats.Errors)
+
+	if stats.Errors > 0 {
+		os.Exit(1)
+	}
+}
+
+// newDataBackendFromConfig initialize the data backend of the configuration file at path
+// or the data backend passed as argument if path is empty
+func newDataBackendFromConfig(path string, impl string, params map[string]interface{}) data.Backend {
+	if path != "" {
+		cfg, err := common.LoadConfiguration(path)
+		if err != nil {
+			fmt.Printf("Unable to load config %s : %s\n", path, err)
+			os.Exit(1)
+		}
+		impl = cfg.DataBackend
+		params = cfg.DataBackendConfig
+	}
+
+	if impl == "" {
+		fmt.Println("Missing data backend to migrate the files from, set FallbackDataBackend or --from")
+		os.Exit(1)
+	}
+
+	backend, err := server.NewDataBackend(impl, params)
+	if err != nil {
+		fmt.Printf("Unable to initialize data backend : %s\n", err)
+		os.Exit(1)
+	}
+
+	return backend
+}
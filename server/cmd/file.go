@@ -9,13 +9,19 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data/dedup"
+	data_file "github.com/root-gg/plik/server/data/file"
+	"github.com/root-gg/plik/server/data/tiered"
+	"github.com/root-gg/plik/server/metadata"
 	"github.com/root-gg/plik/server/server"
 )
 
 type fileFlagParams struct {
-	uploadID string
-	fileID   string
-	human    bool
+	uploadID   string
+	fileID     string
+	human      bool
+	oldKey     string
+	oldKeyFile string
 }
 
 var fileParams = fileFlagParams{}
@@ -47,6 +53,18 @@ var deleteFilesCmd = &cobra.Command{
 	Run:   deleteFiles,
 }
 
+// rekeyFilesCmd represents the "file rekey" command
+var rekeyFilesCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Re-wrap file encryption keys with the current master key",
+	Long: `Re-wrap the data keys of the encrypted files with the master key configured in the file data backend.
+
+Use it to rotate the master key : set the new master key in the configuration and provide the previous one.
+Only the metadata is updated, the file contents are not rewritten.
+Files moved to the cold data backend are not re-wrapped.`,
+	Run: rekeyFiles,
+}
+
 func init() {
 	rootCmd.AddCommand(fileCmd)
 
@@ -59,6 +77,10 @@ func init() {
 
 	fileCmd.AddCommand(showFileCmd)
 	fileCmd.AddCommand(deleteFilesCmd)
+
+	fileCmd.AddCommand(rekeyFilesCmd)
+	rekeyFilesCmd.Flags().StringVar(&fileParams.oldKey, "old-key", "", "previous base64 encoded master key")
+	rekeyFilesCmd.Flags().StringVar(&fileParams.oldKeyFile, "old-key-file", "", "file containing the previous master key")
 }
 
 func listFiles(cmd *cobra.Command, args []string) {
@@ -210,3 +232,90 @@ func deleteFiles(cmd *cobra.Command, args []string) {
 	// Delete upload and files
 	plik.Clean()
 }
+
+func rekeyFiles(cmd *cobra.Command, args []string) {
+	if config.DataBackend != "file" {
+		fmt.Println("Encryption is only available for the file data backend")
+		os.Exit(1)
+	}
+
+	backend, err := data_file.NewBackend(data_file.NewConfig(config.DataBackendConfig))
+	if err != nil {
+		fmt.Printf("Unable to initialize data backend : %s\n", err)
+		os.Exit(1)
+	}
+
+	oldKeyConfig := &data_file.Config{EncryptionKey: fileParams.oldKey, EncryptionKeyFile: fileParams.oldKeyFile}
+	oldKey, err := oldKeyConfig.GetMasterKey()
+	if err != nil {
+		fmt.Printf("Invalid old master key : %s\n", err)
+		os.Exit(1)
+	}
+	if oldKey == nil {
+		fmt.Println("Missing old master key")
+		os.Exit(1)
+	}
+
+	rewrap := func(file *common.File) (changed bool, err error) {
+		return backend.RewrapDataKey(file, oldKey)
+	}
+
+	// The file backend details are wrapped in the tiered backend details
+	if config.ColdDataBackend != "" {
+		rewrapFile := rewrap
+		rewrap = func(file *common.File) (changed bool, err error) {
+			return tiered.UpdateHotBackendDetails(file, rewrapFile)
+		}
+	}
+
+	initializeMetadataBackend()
+
+	// The files are loaded by batches as they are updated while iterating
+	files := 0
+	err = metadataBackend.ForEachFileBatch(&common.File{}, "", metadata.BatchSize, func(file *common.File) error {
+		changed, err := rewrap(file)
+		if err != nil {
+			return fmt.Errorf("unable to re-wrap file %s data key : %s", file.ID, err)
+		}
+		if !changed {
+			return nil
+		}
+
+		err = metadataBackend.UpdateFileBackendDetails(file)
+		if err != nil {
+			return fmt.Errorf("unable to update file %s : %s", file.ID, err)
+		}
+		files++
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Unable to rekey files : %s\n", err)
+		os.Exit(1)
+	}
+
+	blobs := 0
+	err = metadataBackend.ForEachBlobBatch(metadata.BatchSize, func(blob *common.Blob) error {
+		file := dedup.GetBlobFile(blob)
+		changed, err := rewrap(file)
+		if err != nil {
+			return fmt.Errorf("unable to re-wrap blob %s data key : %s", blob.ID, err)
+		}
+		if !changed {
+			return nil
+		}
+
+		blob.BackendDetails = file.BackendDetails
+		err = metadataBackend.UpdateBlobBackendDetails(blob)
+		if err != nil {
+			return fmt.Errorf("unable to update blob %s : %s", blob.ID, err)
+		}
+		blobs++
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Unable to rekey blobs : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d files and %d blobs have been re-wrapped\n", files, blobs)
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/server"
+)
+
+type fsckFlagParams struct {
+	uploadID string
+	fileID   string
+	repair   bool
+	verbose  bool
+}
+
+var fsckParams = fsckFlagParams{}
+
+// fsckCmd represents the fsck command
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Verify the integrity of the uploaded files",
+	Long: `Re-read every uploaded file from the data backend and compare its size and digests to the metadata.
+
+Missing and corrupted files are reported. With --repair :
+  - missing files are marked as deleted
+  - corrupted files are removed
+  - the SHA-256 digest of files uploaded by older versions is computed and saved`,
+	Run: fsck,
+}
+
+func init() {
+	rootCmd.AddCommand(fsckCmd)
+
+	fsckCmd.Flags().StringVar(&fsckParams.uploadID, "upload", "", "only check the files of this upload")
+	fsckCmd.Flags().StringVar(&fsckParams.fileID, "file", "", "only check this file")
+	fsckCmd.Flags().BoolVar(&fsckParams.repair, "repair", false, "repair missing and corrupted files")
+	fsckCmd.Flags().BoolVar(&fsckParams.verbose, "verbose", false, "also report valid files")
+}
+
+func fsck(cmd *cobra.Command, args []string) {
+	plik := server.NewPlikServer(config)
+
+	initializeMetadataBackend()
+	plik.WithMetadataBackend(metadataBackend)
+
+	initializeDataBackend()
+	plik.WithDataBackend(dataBackend)
+
+	report := func(file *common.File, result string, err error) {
+		if result == server.FsckOK && !fsckParams.verbose {
+			return
+		}
+		if err != nil {
+			fmt.Printf("%s %s %s %s : %s\n", file.UploadID, file.ID, result, file.Name, err)
+		} else {
+			fmt.Printf("%s %s %s %s\n", file.UploadID, file.ID, result, file.Name)
+		}
+	}
+
+	options := &server.FsckOptions{UploadID: fsckParams.uploadID, FileID: fsckParams.fileID, Repair: fsckParams.repair}
+	stats, err := plik.Fsck(options, report)
+	if err != nil {
+		fmt.Printf("Unable to check files : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d files checked, %d missing, %d corrupted, %d errors\n", stats.Checked, stats.Missing, stats.Corrupted, stats.Errors)
+	if fsckParams.repair {
+		fmt.Printf("%d files repaired, %d digests backfilled\n", stats.Repaired, stats.Backfilled)
+	}
+
+	if stats.Errors > 0 || (!fsckParams.repair && stats.Missing+stats.Corrupted > 0) {
+		os.Exit(1)
+	}
+}
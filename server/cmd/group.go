@@ -0,0 +1,391 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dustin/go-humanize"
+	"github.com/root-gg/utils"
+	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+type groupFlagParams struct {
+	id           string
+	name         string
+	maxFileSize  string
+	maxGroupSize string
+	maxTTL       string
+	provider     string
+	login        string
+}
+
+var groupParams = groupFlagParams{}
+
+// groupCmd represents all group command
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manipulate groups",
+}
+
+// createGroupCmd represents the "group create" command
+var createGroupCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create group",
+	Run:   createGroup,
+}
+
+// listGroupsCmd represents the "group list" command
+var listGroupsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List groups",
+	Run:   listGroups,
+}
+
+// showGroupCmd represents the "group show" command
+var showGroupCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show group info and members",
+	Run:   showGroup,
+}
+
+// updateGroupCmd represents the "group update" command
+var updateGroupCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update group info",
+	Run:   updateGroup,
+}
+
+// deleteGroupCmd represents the "group delete" command
+var deleteGroupCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete group",
+	Run:   deleteGroup,
+}
+
+// addGroupMemberCmd represents the "group add-member" command
+var addGroupMemberCmd = &cobra.Command{
+	Use:   "add-member",
+	Short: "Add a user to a group",
+	Run:   addGroupMember,
+}
+
+// removeGroupMemberCmd represents the "group remove-member" command
+var removeGroupMemberCmd = &cobra.Command{
+	Use:   "remove-member",
+	Short: "Remove a user from a group",
+	Run:   removeGroupMember,
+}
+
+func init() {
+	rootCmd.AddCommand(groupCmd)
+
+	// Here you will define your flags and configuration settings.
+	groupCmd.PersistentFlags().StringVar(&groupParams.id, "id", "", "group id")
+
+	groupCmd.AddCommand(createGroupCmd)
+	createGroupCmd.Flags().StringVar(&groupParams.name, "name", "", "group name")
+	createGroupCmd.Flags().StringVar(&groupParams.maxFileSize, "max-file-size", "", "group max file size")
+	createGroupCmd.Flags().StringVar(&groupParams.maxGroupSize, "max-group-size", "", "group max group size")
+	createGroupCmd.Flags().StringVar(&groupParams.maxTTL, "max-ttl", "", "group max ttl")
+
+	groupCmd.AddCommand(updateGroupCmd)
+	updateGroupCmd.Flags().StringVar(&groupParams.name, "name", "", "group name")
+	updateGroupCmd.Flags().StringVar(&groupParams.maxFileSize, "max-file-size", "", "group max file size")
+	updateGroupCmd.Flags().StringVar(&groupParams.maxGroupSize, "max-group-size", "", "group max group size")
+	updateGroupCmd.Flags().StringVar(&groupParams.maxTTL, "max-ttl", "", "group max ttl")
+
+	groupCmd.AddCommand(addGroupMemberCmd)
+	addGroupMemberCmd.Flags().StringVar(&groupParams.provider, "provider", common.ProviderLocal, "user provider [local|google|ovh|oidc|ldap]")
+	addGroupMemberCmd.Flags().StringVar(&groupParams.login, "login", "", "user login")
+
+	groupCmd.AddCommand(removeGroupMemberCmd)
+	removeGroupMemberCmd.Flags().StringVar(&groupParams.provider, "provider", common.ProviderLocal, "user provider [local|google|ovh|oidc|ldap]")
+	removeGroupMemberCmd.Flags().StringVar(&groupParams.login, "login", "", "user login")
+
+	groupCmd.AddCommand(listGroupsCmd)
+	groupCmd.AddCommand(showGroupCmd)
+	groupCmd.AddCommand(deleteGroupCmd)
+}
+
+// getGroupFromFlags load the group matching the --id flag or exit
+func getGroupFromFlags() (group *common.Group) {
+	if groupParams.id == "" {
+		fmt.Println("missing group id")
+		os.Exit(1)
+	}
+
+	group, err := metadataBackend.GetGroup(groupParams.id)
+	if err != nil {
+		fmt.Printf("Unable to get group : %s\n", err)
+		os.Exit(1)
+	}
+	if group == nil {
+		fmt.Printf("Group %s not found\n", groupParams.id)
+		os.Exit(1)
+	}
+
+	return group
+}
+
+// setGroupLimitsFromFlags update the group limits from the command line flags
+func setGroupLimitsFromFlags(params *common.Group) {
+	if groupParams.maxFileSize == "-1" {
+		params.MaxFileSize = -1
+	} else if groupParams.maxFileSize != "" {
+		maxFileSize, err := humanize.ParseBytes(groupParams.maxFileSize)
+		if err != nil {
+			fmt.Printf("Unable to parse max-file-size\n")
+			os.Exit(1)
+		}
+		params.MaxFileSize = int64(maxFileSize)
+	}
+
+	if groupParams.maxGroupSize == "-1" {
+		params.MaxGroupSize = -1
+	} else if groupParams.maxGroupSize != "" {
+		maxGroupSize, err := humanize.ParseBytes(groupParams.maxGroupSize)
+		if err != nil {
+			fmt.Printf("Unable to parse max-group-size\n")
+			os.Exit(1)
+		}
+		params.MaxGroupSize = int64(maxGroupSize)
+	}
+
+	if groupParams.maxTTL != "" {
+		maxTTL, err := common.ParseTTL(groupParams.maxTTL)
+		if err != nil {
+			fmt.Printf("Unable to parse max-ttl : %s\n", err)
+			os.Exit(1)
+		}
+		params.MaxTTL = maxTTL
+	}
+}
+
+func createGroup(cmd *cobra.Command, args []string) {
+	if config.FeatureAuthentication == common.FeatureDisabled {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	if groupParams.id == "" {
+		fmt.Println("missing group id")
+		os.Exit(1)
+	}
+
+	group, err := metadataBackend.GetGroup(groupParams.id)
+	if err != nil {
+		fmt.Printf("Unable to get group : %s\n", err)
+		os.Exit(1)
+	}
+
+	if group != nil {
+		fmt.Println("Group already exists")
+		os.Exit(1)
+	}
+
+	params := &common.Group{ID: groupParams.id, Name: groupParams.name}
+	setGroupLimitsFromFlags(params)
+
+	group, err = common.CreateGroupFromParams(params)
+	if err != nil {
+		fmt.Printf("Unable to create group : %s\n", err)
+		os.Exit(1)
+	}
+
+	err = metadataBackend.CreateGroup(group)
+	if err != nil {
+		fmt.Printf("Unable to save group : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("group %s has been created\n", group.ID)
+}
+
+func showGroup(cmd *cobra.Command, args []string) {
+	if config.FeatureAuthentication == common.FeatureDisabled {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	utils.Dump(getGroupFromFlags())
+}
+
+func updateGroup(cmd *cobra.Command, args []string) {
+	if config.FeatureAuthentication == common.FeatureDisabled {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	group := getGroupFromFlags()
+
+	params := &common.Group{
+		Name:         group.Name,
+		MaxFileSize:  group.MaxFileSize,
+		MaxGroupSize: group.MaxGroupSize,
+		MaxTTL:       group.MaxTTL,
+	}
+
+	if cmd.Flags().Changed("name") {
+		params.Name = groupParams.name
+	}
+
+	setGroupLimitsFromFlags(params)
+	common.UpdateGroup(group, params)
+
+	err := metadataBackend.UpdateGroup(group)
+	if err != nil {
+		fmt.Printf("Unable to update group : %s\n", err)
+		os.Exit(1)
+	}
+
+	utils.Dump(group)
+}
+
+func listGroups(cmd *cobra.Command, args []string) {
+	if config.FeatureAuthentication == common.FeatureDisabled {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	f := func(group *common.Group) error {
+		fmt.Printf("%s %q max-file-size:%d max-group-size:%d max-ttl:%d\n", group.ID, group.Name, group.MaxFileSize, group.MaxGroupSize, group.MaxTTL)
+		return nil
+	}
+
+	err := metadataBackend.ForEachGroup(f)
+	if err != nil {
+		fmt.Printf("Unable to get groups : %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func deleteGroup(cmd *cobra.Command, args []string) {
+	if config.FeatureAuthentication == common.FeatureDisabled {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	if groupParams.id == "" {
+		fmt.Println("missing group id")
+		os.Exit(1)
+	}
+
+	// Ask confirmation
+	fmt.Printf("Do you really want to delete group %s ? Group uploads will be given back to their creators [y/N]\n", groupParams.id)
+	ok, err := common.AskConfirmation(false)
+	if err != nil {
+		fmt.Printf("Unable to ask for confirmation : %s", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(0)
+	}
+
+	deleted, err := metadataBackend.DeleteGroup(groupParams.id)
+	if err != nil {
+		fmt.Printf("Unable to delete group : %s\n", err)
+		os.Exit(1)
+	}
+
+	if !deleted {
+		fmt.Printf("group %s not found\n", groupParams.id)
+		os.Exit(1)
+	}
+
+	fmt.Printf("group %s has been deleted\n", groupParams.id)
+}
+
+func addGroupMember(cmd *cobra.Command, args []string) {
+	if config.FeatureAuthentication == common.FeatureDisabled {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	group := getGroupFromFlags()
+
+	if groupParams.login == "" {
+		fmt.Println("missing login")
+		os.Exit(1)
+	}
+
+	if !common.IsValidProvider(groupParams.provider) {
+		fmt.Println("invalid provider")
+		os.Exit(1)
+	}
+
+	userID := common.GetUserID(groupParams.provider, groupParams.login)
+	user, err := metadataBackend.GetUser(userID)
+	if err != nil {
+		fmt.Printf("Unable to get user : %s\n", err)
+		os.Exit(1)
+	}
+	if user == nil {
+		fmt.Printf("User %s not found\n", userID)
+		os.Exit(1)
+	}
+
+	if group.IsMember(user.ID) {
+		fmt.Printf("User %s is already a member of group %s\n", user.ID, group.ID)
+		os.Exit(1)
+	}
+
+	err = metadataBackend.AddGroupMember(group.ID, user.ID)
+	if err != nil {
+		fmt.Printf("Unable to add group member : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("user %s has been added to group %s\n", user.ID, group.ID)
+}
+
+func removeGroupMember(cmd *cobra.Command, args []string) {
+	if config.FeatureAuthentication == common.FeatureDisabled {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	if groupParams.id == "" {
+		fmt.Println("missing group id")
+		os.Exit(1)
+	}
+
+	if groupParams.login == "" {
+		fmt.Println("missing login")
+		os.Exit(1)
+	}
+
+	if !common.IsValidProvider(groupParams.provider) {
+		fmt.Println("invalid provider")
+		os.Exit(1)
+	}
+
+	userID := common.GetUserID(groupParams.provider, groupParams.login)
+	removed, err := metadataBackend.RemoveGroupMember(groupParams.id, userID)
+	if err != nil {
+		fmt.Printf("Unable to remove group member : %s\n", err)
+		os.Exit(1)
+	}
+
+	if !removed {
+		fmt.Printf("user %s is not a member of group %s\n", userID, groupParams.id)
+		os.Exit(1)
+	}
+
+	fmt.Printf("user %s has been removed from group %s\n", userID, groupParams.id)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/server"
+)
+
+type reconcileFlagParams struct {
+	delete bool
+}
+
+var reconcileParams = reconcileFlagParams{}
+
+// reconcileCmd represents the reconcile command
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Find orphan files in the data backend",
+	Long: `Compare the files stored in the data backend with the metadata.
+
+Orphan files ( stored in the data backend without metadata ) and missing files ( uploaded files
+whose content is not in the data backend ) are reported. With --delete :
+  - orphan files are deleted from the data backend
+  - missing files are marked as deleted`,
+	Run: reconcile,
+}
+
+func init() {
+	rootCmd.AddCommand(reconcileCmd)
+
+	reconcileCmd.Flags().BoolVar(&reconcileParams.delete, "delete", false, "delete orphan files and mark missing files as deleted")
+}
+
+func reconcile(cmd *cobra.Command, args []string) {
+	plik := server.NewPlikServer(config)
+
+	initializeMetadataBackend()
+	plik.WithMetadataBackend(metadataBackend)
+
+	initializeDataBackend()
+	plik.WithDataBackend(dataBackend)
+
+	report := func(file *common.File, result string, err error) {
+		if err != nil {
+			fmt.Printf("%s %s %s : %s\n", result, file.UploadID, file.ID, err)
+		} else {
+			fmt.Printf("%s %s %s %s\n", result, file.UploadID, file.ID, humanize.Bytes(uint64(file.Size)))
+		}
+	}
+
+	stats, err := plik.Reconcile(&server.ReconcileOptions{Delete: reconcileParams.delete}, report)
+	if err != nil {
+		fmt.Printf("Unable to reconcile metadata and data backends : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d files in the data backend, %d orphan files ( %s ), %d missing files\n",
+		stats.Files, stats.OrphanFiles, humanize.Bytes(uint64(stats.OrphanSize)), stats.MissingFiles)
+	if reconcileParams.delete {
+		fmt.Printf("%d orphan files deleted, %d missing files marked as deleted\n", stats.DeletedFiles, stats.RemovedMissingFiles)
+	}
+
+	if stats.Errors > 0 {
+		os.Exit(1)
+	}
+}
@@ -122,7 +122,11 @@ var dataBackend data.Backend
 func initializeDataBackend() {
 	var err error
 	initializeDataBackendOnce.Do(func() {
-		dataBackend, err = server.NewDataBackend(config.DataBackend, config.DataBackendConfig)
+		if config.Deduplication {
+			initializeMetadataBackend()
+		}
+
+		dataBackend, err = server.NewDataBackendStack(config, metadataBackend)
 		if err != nil {
 			fmt.Printf("unable to initialize data backend : %s\n", err)
 			os.Exit(1)
@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -14,6 +16,8 @@ type tokenFlagParams struct {
 	provider string
 	comment  string
 	token    string
+	scope    string
+	ttl      string
 }
 
 var tokenParams = tokenFlagParams{}
@@ -49,11 +53,13 @@ func init() {
 	rootCmd.AddCommand(tokenCmd)
 
 	// Here you will define your flags and configuration settings.
-	tokenCmd.PersistentFlags().StringVar(&tokenParams.provider, "provider", common.ProviderLocal, "user provider [local|google|ovh]")
+	tokenCmd.PersistentFlags().StringVar(&tokenParams.provider, "provider", common.ProviderLocal, "user provider [local|google|ovh|oidc|ldap]")
 	tokenCmd.PersistentFlags().StringVar(&tokenParams.login, "login", "", "user login")
 
 	tokenCmd.AddCommand(createTokenCmd)
 	createTokenCmd.Flags().StringVar(&tokenParams.comment, "comment", "", "token comment")
+	createTokenCmd.Flags().StringVar(&tokenParams.scope, "scope", "", "comma separated list of token scopes [upload|read|delete|admin|token] (default all but admin and token)")
+	createTokenCmd.Flags().StringVar(&tokenParams.ttl, "ttl", "", "token time to live (default never expires)")
 
 	tokenCmd.AddCommand(deleteTokenCmd)
 	deleteTokenCmd.Flags().StringVar(&tokenParams.token, "token", "", "token")
@@ -95,6 +101,25 @@ func createToken(cmd *cobra.Command, args []string) {
 	// Create token
 	token := user.NewToken()
 	token.Comment = tokenParams.comment
+	token.Scope = strings.ReplaceAll(tokenParams.scope, ",", " ")
+
+	if tokenParams.ttl != "" {
+		ttl, err := common.ParseTTL(tokenParams.ttl)
+		if err != nil {
+			fmt.Printf("Unable to parse ttl : %s\n", err)
+			os.Exit(1)
+		}
+		if ttl > 0 {
+			expireAt := time.Now().Add(time.Duration(ttl) * time.Second)
+			token.ExpireAt = &expireAt
+		}
+	}
+
+	err = token.Validate()
+	if err != nil {
+		fmt.Printf("Invalid token : %s\n", err)
+		os.Exit(1)
+	}
 
 	err = metadataBackend.CreateToken(token)
 	if err != nil {
@@ -102,7 +127,24 @@ func createToken(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	// Record the token creation in the audit log
+	if config.AuditLog {
+		event := common.NewAuditEvent(common.AuditTokenCreate)
+		event.UserID = user.ID
+		event.Details = "created from the command line"
+		err = metadataBackend.CreateAuditEvent(event)
+		if err != nil {
+			fmt.Printf("Unable to record audit event : %s\n", err)
+		}
+	}
+
 	fmt.Printf("Token created : %s\n", token.Token)
+	if token.Scope != "" {
+		fmt.Printf("Scope : %s\n", token.Scope)
+	}
+	if token.ExpireAt != nil {
+		fmt.Printf("Expire at : %s\n", token.ExpireAt.Format(time.RFC3339))
+	}
 }
 
 func listTokens(cmd *cobra.Command, args []string) {
@@ -126,7 +168,17 @@ func listTokens(cmd *cobra.Command, args []string) {
 			}
 		}
 
-		fmt.Printf("%s %s %s\n", token.UserID, token.Token, token.Comment)
+		scope := token.Scope
+		if scope == "" {
+			scope = "all"
+		}
+
+		expireAt := "never"
+		if token.ExpireAt != nil {
+			expireAt = token.ExpireAt.Format(time.RFC3339)
+		}
+
+		fmt.Printf("%s %s [%s] expire:%s %s\n", token.UserID, token.Token, scope, expireAt, token.Comment)
 
 		return nil
 	}
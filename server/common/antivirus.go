@@ -0,0 +1,22 @@
+package common
+
+import "fmt"
+
+// ScanPolicyReject delete the file and fail the upload
+const ScanPolicyReject = "reject"
+
+// ScanPolicyQuarantine keep the file in the quarantined status so it can't be downloaded and fail the upload
+const ScanPolicyQuarantine = "quarantine"
+
+// ScanPolicyAllow accept the file as if it was clean
+const ScanPolicyAllow = "allow"
+
+// ValidateScanPolicy return an error if the policy is not a valid antivirus scan policy
+func ValidateScanPolicy(policy string) error {
+	switch policy {
+	case ScanPolicyReject, ScanPolicyQuarantine, ScanPolicyAllow:
+		return nil
+	default:
+		return fmt.Errorf("invalid scan policy %s, expected %s, %s or %s", policy, ScanPolicyReject, ScanPolicyQuarantine, ScanPolicyAllow)
+	}
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditUpload      = "upload"
+	AuditDownload    = "download"
+	AuditDelete      = "delete"
+	AuditLogin       = "login"
+	AuditTokenCreate = "token_create"
+	AuditImpersonate = "impersonate"
+)
+
+// AuditActions is the list of all the audited actions
+var AuditActions = []string{AuditUpload, AuditDownload, AuditDelete, AuditLogin, AuditTokenCreate, AuditImpersonate}
+
+// Audit results
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent records who did what, from where and with which outcome
+// Tokens are only recorded by their fingerprint so the audit log never discloses them
+type AuditEvent struct {
+	ID               string    `json:"id" gorm:"primary_key"`
+	Action           string    `json:"action" gorm:"index:idx_audit_action"`
+	Result           string    `json:"result"`
+	Status           int       `json:"status,omitempty"`
+	UserID           string    `json:"user,omitempty" gorm:"index:idx_audit_user_id"`
+	TokenFingerprint string    `json:"tokenFingerprint,omitempty"`
+	SourceIP         string    `json:"sourceIP,omitempty"`
+	UserAgent        string    `json:"userAgent,omitempty"`
+	UploadID         string    `json:"uploadID,omitempty" gorm:"index:idx_audit_upload_id"`
+	FileID           string    `json:"fileID,omitempty"`
+	Details          string    `json:"details,omitempty" gorm:"type:text"`
+	CreatedAt        time.Time `json:"createdAt" gorm:"index:idx_audit_created_at"`
+}
+
+// TableName override the default gorm table name
+func (AuditEvent) TableName() string {
+	return "audit"
+}
+
+// NewAuditEvent create a new audit event
+func NewAuditEvent(action string) (event *AuditEvent) {
+	event = &AuditEvent{}
+	event.ID = GenerateRandomID(16)
+	event.Action = action
+	event.Result = AuditSuccess
+	return event
+}
+
+// SetStatus set the event result from the HTTP response status code
+func (event *AuditEvent) SetStatus(status int) {
+	event.Status = status
+	if status >= 400 {
+		event.Result = AuditFailure
+	} else {
+		event.Result = AuditSuccess
+	}
+}
+
+// String return a one line description of the audit event
+func (event *AuditEvent) String() string {
+	user := event.UserID
+	if user == "" {
+		user = "anonymous"
+	}
+
+	str := fmt.Sprintf("%s %s %s %s %s", event.CreatedAt.Format(time.RFC3339), event.Action, event.Result, user, event.SourceIP)
+	if event.UploadID != "" {
+		str += " upload:" + event.UploadID
+	}
+	if event.FileID != "" {
+		str += " file:" + event.FileID
+	}
+	if event.Details != "" {
+		str += " " + event.Details
+	}
+	return str
+}
+
+// ValidateAuditAction return an error if the action is not a valid audit action
+func ValidateAuditAction(action string) error {
+	for _, a := range AuditActions {
+		if a == action {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid audit action %s", action)
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAuditEvent(t *testing.T) {
+	event := NewAuditEvent(AuditDownload)
+	require.NotZero(t, event.ID, "missing audit event id")
+	require.Equal(t, AuditDownload, event.Action, "invalid audit event action")
+	require.Equal(t, AuditSuccess, event.Result, "invalid audit event result")
+}
+
+func TestAuditEventSetStatus(t *testing.T) {
+	event := NewAuditEvent(AuditDownload)
+
+	event.SetStatus(http.StatusNotFound)
+	require.Equal(t, http.StatusNotFound, event.Status, "invalid audit event status")
+	require.Equal(t, AuditFailure, event.Result, "invalid audit event result")
+
+	event.SetStatus(http.StatusPartialContent)
+	require.Equal(t, http.StatusPartialContent, event.Status, "invalid audit event status")
+	require.Equal(t, AuditSuccess, event.Result, "invalid audit event result")
+}
+
+func TestAuditEventString(t *testing.T) {
+	event := NewAuditEvent(AuditDownload)
+	event.SourceIP = "1.3.3.7"
+	event.UploadID = "upload"
+	event.FileID = "file"
+	event.CreatedAt = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, "2000-01-01T00:00:00Z download success anonymous 1.3.3.7 upload:upload file:file", event.String(), "invalid audit event string")
+
+	event.UserID = "local:user"
+	event.Details = "details"
+	require.Contains(t, event.String(), "local:user 1.3.3.7", "invalid audit event string")
+	require.Contains(t, event.String(), " details", "invalid audit event string")
+}
+
+func TestValidateAuditAction(t *testing.T) {
+	for _, action := range AuditActions {
+		require.NoError(t, ValidateAuditAction(action), "invalid audit action %s", action)
+	}
+	require.Error(t, ValidateAuditAction("foo"), "invalid audit action expected")
+}
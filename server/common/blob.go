@@ -0,0 +1,23 @@
+package common
+
+import (
+	"time"
+)
+
+// Blob is a piece of content stored only once in the data backend
+// and shared by all the files having the same content ( see data/dedup )
+type Blob struct {
+	ID             string `gorm:"primary_key"` // Hex encoded SHA-256 of the content
+	Size           int64
+	RefCount       int    // Number of files referencing this blob
+	DataID         string // Unique name of the blob content in the data backend ( the ID for older blobs )
+	BackendDetails string
+	CreatedAt      time.Time
+}
+
+// BlobReference links a file to the blob holding its content
+type BlobReference struct {
+	FileID    string `gorm:"primary_key"`
+	BlobID    string `gorm:"size:256;index"`
+	CreatedAt time.Time
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"net/mail"
 	"net/url"
 	"os"
 	"strconv"
@@ -45,6 +46,10 @@ type Configuration struct {
 	MaxTTLStr     string `json:"-"`
 	MaxTTL        int    `json:"maxTTL"`
 
+	AuditLog             bool   `json:"-"`
+	AuditLogRetentionStr string `json:"-"`
+	AuditLogRetention    int    `json:"-"`
+
 	SslEnabled bool   `json:"-"`
 	SslCert    string `json:"-"`
 	SslKey     string `json:"-"`
@@ -75,6 +80,7 @@ type Configuration struct {
 	FeatureClients        string `json:"feature_clients"`
 	FeatureGithub         string `json:"feature_github"`
 	FeatureText           string `json:"feature_text"`
+	FeatureMaxDownloads   string `json:"feature_max_downloads"`
 
 	// Deprecated Feature Flags
 	Authentication      bool `json:"authentication"`      // Deprecated: >1.3.6
@@ -92,17 +98,86 @@ type Configuration struct {
 	OvhAPIEndpoint       string   `json:"ovhApiEndpoint"`
 	OvhAPIKey            string   `json:"-"`
 	OvhAPISecret         string   `json:"-"`
+	OIDCAuthentication   bool     `json:"oidcAuthentication"`
+	OIDCProviderName     string   `json:"oidcProviderName"`
+	OIDCProviderURL      string   `json:"-"`
+	OIDCClientID         string   `json:"-"`
+	OIDCClientSecret     string   `json:"-"`
+	OIDCScopes           []string `json:"-"`
+	OIDCLoginClaim       string   `json:"-"`
+	OIDCNameClaim        string   `json:"-"`
+	OIDCEmailClaim       string   `json:"-"`
+	OIDCGroupsClaim      string   `json:"-"`
+	OIDCValidDomains     []string `json:"-"`
+	OIDCValidGroups      []string `json:"-"`
+	OIDCAdminClaim       string   `json:"-"`
+	OIDCAdminValue       string   `json:"-"`
+
+	LDAPAuthentication       bool   `json:"ldapAuthentication"`
+	LDAPURL                  string `json:"-"`
+	LDAPStartTLS             bool   `json:"-"`
+	LDAPInsecureSkipVerify   bool   `json:"-"`
+	LDAPBindDN               string `json:"-"`
+	LDAPBindPassword         string `json:"-"`
+	LDAPBaseDN               string `json:"-"`
+	LDAPUserFilter           string `json:"-"`
+	LDAPNameAttribute        string `json:"-"`
+	LDAPEmailAttribute       string `json:"-"`
+	LDAPGroupAttribute       string `json:"-"`
+	LDAPAdminGroup           string `json:"-"`
+	LDAPMaxFileSizeAttribute string `json:"-"`
+	LDAPMaxUserSizeAttribute string `json:"-"`
+	LDAPMaxTTLAttribute      string `json:"-"`
 
 	MetadataBackendConfig map[string]interface{} `json:"-"`
 
 	DataBackend       string                 `json:"-"`
 	DataBackendConfig map[string]interface{} `json:"-"`
+	Deduplication     bool                   `json:"-"`
+
+	FallbackDataBackend       string                 `json:"-"` // Files not found in the data backend are read from this one
+	FallbackDataBackendConfig map[string]interface{} `json:"-"`
+	DataMigration             bool                   `json:"-"` // Migrate the files from the fallback data backend in the background
+
+	ColdDataBackend        string                 `json:"-"` // Files matching the cold storage policy are moved to this data backend
+	ColdDataBackendConfig  map[string]interface{} `json:"-"`
+	ColdStorageMinAgeStr   string                 `json:"-"`
+	ColdStorageMinAge      int                    `json:"-"`
+	ColdStorageIdleTimeStr string                 `json:"-"`
+	ColdStorageIdleTime    int                    `json:"-"`
+	ColdStorageMinSizeStr  string                 `json:"-"`
+	ColdStorageMinSize     int64                  `json:"-"`
+
+	OrphanReconciliation string `json:"-"` // Reconcile the metadata and data backends when cleaning ( report / delete )
+
+	Webhooks []*WebhookConfig `json:"-"`
+
+	EmailNotifications     bool   `json:"emailNotifications"`
+	SMTPHost               string `json:"-"`
+	SMTPPort               int    `json:"-"`
+	SMTPUsername           string `json:"-"`
+	SMTPPassword           string `json:"-"`
+	SMTPTLS                bool   `json:"-"`
+	SMTPStartTLS           bool   `json:"-"`
+	SMTPInsecureSkipVerify bool   `json:"-"`
+	EmailFrom              string `json:"-"`
+	EmailTemplatesDir      string `json:"-"`
+	PublicURL              string `json:"-"`
+
+	ClamAVAddress        string `json:"-"`
+	ClamAVTimeout        string `json:"-"`
+	ClamAVInfectedPolicy string `json:"-"`
+	ClamAVErrorPolicy    string `json:"-"`
+
+	Processors []map[string]interface{} `json:"-"`
 
 	downloadDomainURL      *url.URL
 	downloadDomainURLAlias []*url.URL
 	uploadWhitelist        []*net.IPNet
+	publicURL              *url.URL
 	clean                  bool
 	sessionTimeout         int
+	clamAVTimeout          int
 }
 
 // NewConfiguration creates a new configuration
@@ -118,6 +193,18 @@ func NewConfiguration() (config *Configuration) {
 	config.EnhancedWebSecurity = false
 	config.SessionTimeout = "365d"
 
+	config.OIDCProviderName = "OpenID Connect"
+	config.OIDCScopes = []string{"openid", "profile", "email"}
+	config.OIDCLoginClaim = "preferred_username"
+	config.OIDCNameClaim = "name"
+	config.OIDCEmailClaim = "email"
+	config.OIDCGroupsClaim = "groups"
+
+	config.LDAPUserFilter = "(uid=%s)"
+	config.LDAPNameAttribute = "cn"
+	config.LDAPEmailAttribute = "mail"
+	config.LDAPGroupAttribute = "memberOf"
+
 	config.MaxFileSize = 10000000000 // 10GB
 	config.MaxUserSize = -1          // Default max size per user ( -1 for unlimited)
 	config.MaxFilePerUpload = 1000
@@ -125,6 +212,11 @@ func NewConfiguration() (config *Configuration) {
 	config.DefaultTTL = 2592000 // 30 days
 	config.MaxTTL = 2592000     // 30 days
 
+	config.AuditLog = true
+	config.AuditLogRetention = 7776000 // 90 days
+
+	config.ColdStorageIdleTime = 604800 // 7 days
+
 	// Deprecated feature flags default values to ensure backward compatibility <1.3.6
 	// New FeatureFlags default values are defined in feature_flags.go initialization functions
 	config.OneShot = true
@@ -134,6 +226,12 @@ func NewConfiguration() (config *Configuration) {
 
 	config.OvhAPIEndpoint = "https://eu.api.ovh.com/1.0"
 
+	config.SMTPPort = 25
+
+	config.ClamAVTimeout = "60s"
+	config.ClamAVInfectedPolicy = ScanPolicyReject
+	config.ClamAVErrorPolicy = ScanPolicyReject
+
 	config.DataBackend = "file"
 
 	config.WebappDirectory = "../webapp/dist"
@@ -208,6 +306,12 @@ func (config *Configuration) Initialize() (err error) {
 
 	config.GoogleAuthentication = config.FeatureAuthentication != FeatureDisabled && config.GoogleAPIClientID != "" && config.GoogleAPISecret != ""
 	config.OvhAuthentication = config.FeatureAuthentication != FeatureDisabled && config.OvhAPIKey != "" && config.OvhAPISecret != ""
+	config.OIDCAuthentication = config.FeatureAuthentication != FeatureDisabled && config.OIDCProviderURL != "" && config.OIDCClientID != ""
+	config.LDAPAuthentication = config.FeatureAuthentication != FeatureDisabled && config.LDAPURL != ""
+
+	if config.LDAPAuthentication && !strings.Contains(config.LDAPUserFilter, "%s") {
+		return fmt.Errorf("invalid LDAP user filter %s : missing %%s login placeholder", config.LDAPUserFilter)
+	}
 
 	if config.DownloadDomain != "" {
 		strings.Trim(config.DownloadDomain, "/ ")
@@ -263,6 +367,91 @@ func (config *Configuration) Initialize() (err error) {
 		return fmt.Errorf("DefaultTTL should not be more than MaxTTL")
 	}
 
+	if config.AuditLogRetentionStr != "" {
+		config.AuditLogRetention, err = ParseTTL(config.AuditLogRetentionStr)
+		if err != nil {
+			return fmt.Errorf("unable to parse AuditLogRetentionStr : %s", err)
+		}
+	}
+
+	for _, webhook := range config.Webhooks {
+		err = webhook.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	config.EmailNotifications = config.SMTPHost != ""
+	if config.EmailNotifications {
+		if config.EmailFrom == "" {
+			return fmt.Errorf("missing EmailFrom address to send email notifications")
+		}
+		if _, err := mail.ParseAddress(config.EmailFrom); err != nil {
+			return fmt.Errorf("invalid EmailFrom address %s : %s", config.EmailFrom, err)
+		}
+		if config.SMTPTLS && config.SMTPStartTLS {
+			return fmt.Errorf("SMTPTLS and SMTPStartTLS are mutually exclusive")
+		}
+	}
+
+	if config.PublicURL != "" {
+		if config.publicURL, err = url.Parse(strings.TrimSuffix(config.PublicURL, "/")); err != nil {
+			return fmt.Errorf("invalid public URL %s : %s", config.PublicURL, err)
+		}
+	}
+
+	switch config.OrphanReconciliation {
+	case "", ReconciliationReport, ReconciliationDelete:
+	default:
+		return fmt.Errorf("invalid OrphanReconciliation %s, expected %s or %s", config.OrphanReconciliation, ReconciliationReport, ReconciliationDelete)
+	}
+
+	if config.ColdStorageMinAgeStr != "" {
+		config.ColdStorageMinAge, err = ParseTTL(config.ColdStorageMinAgeStr)
+		if err != nil {
+			return fmt.Errorf("unable to parse ColdStorageMinAgeStr : %s", err)
+		}
+	}
+
+	if config.ColdStorageIdleTimeStr != "" {
+		config.ColdStorageIdleTime, err = ParseTTL(config.ColdStorageIdleTimeStr)
+		if err != nil {
+			return fmt.Errorf("unable to parse ColdStorageIdleTimeStr : %s", err)
+		}
+	}
+
+	if config.ColdStorageMinSizeStr != "" {
+		minSize, err := humanize.ParseBytes(config.ColdStorageMinSizeStr)
+		if err != nil {
+			return fmt.Errorf("unable to parse ColdStorageMinSizeStr : %s", err)
+		}
+		config.ColdStorageMinSize = int64(minSize)
+	}
+
+	if config.ColdStorageMinAge < 0 || config.ColdStorageIdleTime < 0 {
+		return fmt.Errorf("invalid negative value for ColdStorageMinAgeStr or ColdStorageIdleTimeStr")
+	}
+
+	if config.DataMigration && config.FallbackDataBackend == "" {
+		return fmt.Errorf("DataMigration requires a FallbackDataBackend to migrate the files from")
+	}
+
+	if config.ClamAVAddress != "" {
+		config.clamAVTimeout, err = ParseTTL(config.ClamAVTimeout)
+		if err != nil {
+			return fmt.Errorf("unable to parse ClamAVTimeout : %s", err)
+		}
+		if config.clamAVTimeout <= 0 {
+			return fmt.Errorf("invalid negative or zero value for ClamAVTimeout")
+		}
+		if err = ValidateScanPolicy(config.ClamAVInfectedPolicy); err != nil {
+			return fmt.Errorf("invalid ClamAVInfectedPolicy : %s", err)
+		}
+		if err = ValidateScanPolicy(config.ClamAVErrorPolicy); err != nil {
+			return fmt.Errorf("invalid ClamAVErrorPolicy : %s", err)
+		}
+	}
+
 	config.sessionTimeout, err = ParseTTL(config.SessionTimeout)
 	if err != nil {
 		return fmt.Errorf("unable to parse SessionTimeout : %s", err)
@@ -364,6 +553,18 @@ func (config *Configuration) GetServerURL() *url.URL {
 	return URL
 }
 
+// GetPublicURL return the URL users reach the web UI at, to build the links sent by email.
+// It defaults to the download domain then to the server URL
+func (config *Configuration) GetPublicURL() *url.URL {
+	if config.publicURL != nil {
+		return config.publicURL
+	}
+	if config.downloadDomainURL != nil {
+		return config.downloadDomainURL
+	}
+	return config.GetServerURL()
+}
+
 // GetTlsVersion is a helper to get the TLS version
 func (config *Configuration) GetTlsVersion() uint16 {
 	if config.TlsVersion == "tlsv10" {
@@ -395,6 +596,11 @@ func (config *Configuration) GetSessionTimeout() int {
 	return config.sessionTimeout
 }
 
+// GetClamAVTimeout return parsed clamd connection timeout
+func (config *Configuration) GetClamAVTimeout() time.Duration {
+	return time.Duration(config.clamAVTimeout) * time.Second
+}
+
 func (config *Configuration) String() string {
 	str := ""
 	if config.DownloadDomain != "" {
@@ -426,6 +632,17 @@ func (config *Configuration) String() string {
 	str += fmt.Sprintf("Upload comments : %s\n", config.FeatureComments)
 	str += fmt.Sprintf("Upload set TTL : %s\n", config.FeatureSetTTL)
 	str += fmt.Sprintf("Upload extend TTL : %s\n", config.FeatureExtendTTL)
+	str += fmt.Sprintf("Upload max downloads : %s\n", config.FeatureMaxDownloads)
+
+	if config.AuditLog {
+		if config.AuditLogRetention > 0 {
+			str += fmt.Sprintf("Audit log : enabled ( retention %s )\n", HumanDuration(time.Duration(config.AuditLogRetention)*time.Second))
+		} else {
+			str += "Audit log : enabled ( retention unlimited )\n"
+		}
+	} else {
+		str += "Audit log : disabled\n"
+	}
 
 	str += fmt.Sprintf("Authentication : %s\n", config.FeatureAuthentication)
 	if config.FeatureAuthentication != FeatureDisabled {
@@ -443,6 +660,61 @@ func (config *Configuration) String() string {
 		} else {
 			str += "OVH authentication : disabled\n"
 		}
+
+		if config.OIDCAuthentication {
+			str += fmt.Sprintf("OpenID Connect authentication : enabled ( %s )\n", config.OIDCProviderURL)
+		} else {
+			str += "OpenID Connect authentication : disabled\n"
+		}
+
+		if config.LDAPAuthentication {
+			str += fmt.Sprintf("LDAP authentication : enabled ( %s )\n", config.LDAPURL)
+		} else {
+			str += "LDAP authentication : disabled\n"
+		}
+	}
+
+	if config.EmailNotifications {
+		str += fmt.Sprintf("Email notifications : enabled ( %s:%d )\n", config.SMTPHost, config.SMTPPort)
+	} else {
+		str += "Email notifications : disabled\n"
+	}
+
+	if config.OrphanReconciliation != "" {
+		str += fmt.Sprintf("Orphan reconciliation : %s\n", config.OrphanReconciliation)
+	} else {
+		str += "Orphan reconciliation : disabled\n"
+	}
+
+	if config.ColdDataBackend != "" {
+		str += fmt.Sprintf("Cold data backend : %s ( files older than %s, idle for %s, larger than %s )\n", config.ColdDataBackend,
+			HumanDuration(time.Duration(config.ColdStorageMinAge)*time.Second),
+			HumanDuration(time.Duration(config.ColdStorageIdleTime)*time.Second),
+			humanize.Bytes(uint64(config.ColdStorageMinSize)))
+	}
+
+	if config.FallbackDataBackend != "" {
+		if config.DataMigration {
+			str += fmt.Sprintf("Fallback data backend : %s ( migration enabled )\n", config.FallbackDataBackend)
+		} else {
+			str += fmt.Sprintf("Fallback data backend : %s\n", config.FallbackDataBackend)
+		}
+	}
+
+	if config.ClamAVAddress != "" {
+		str += fmt.Sprintf("Antivirus scanning : enabled ( %s, infected : %s, error : %s )\n", config.ClamAVAddress, config.ClamAVInfectedPolicy, config.ClamAVErrorPolicy)
+	} else {
+		str += "Antivirus scanning : disabled\n"
+	}
+
+	if len(config.Processors) > 0 {
+		var processors []string
+		for _, params := range config.Processors {
+			processors = append(processors, fmt.Sprintf("%v", params["Type"]))
+		}
+		str += fmt.Sprintf("Content inspection : enabled ( %s )\n", strings.Join(processors, ", "))
+	} else {
+		str += "Content inspection : disabled\n"
 	}
 
 	return str
@@ -5,6 +5,7 @@ import (
 	"net"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/iancoleman/strcase"
 
@@ -75,13 +76,150 @@ func TestIsWhitelisted(t *testing.T) {
 
 func TestInitializeConfigAuthentication(t *testing.T) {
 	config := NewConfiguration()
+	config.FeatureAuthentication = FeatureEnabled
 	config.GoogleAPIClientID = "google_api_client_id"
 	config.GoogleAPISecret = "google_api_secret"
 	config.OvhAPIKey = "ovh_api_key"
 	config.OvhAPISecret = "ovh_api_secret"
+	config.OIDCProviderURL = "https://sso.root.gg/realms/plik"
+	config.OIDCClientID = "oidc_client_id"
 
 	err := config.Initialize()
 	require.NoError(t, err, "unable to initialize config")
+	require.True(t, config.GoogleAuthentication, "google authentication should be enabled")
+	require.True(t, config.OvhAuthentication, "ovh authentication should be enabled")
+	require.True(t, config.OIDCAuthentication, "oidc authentication should be enabled")
+	require.False(t, config.LDAPAuthentication, "ldap authentication should be disabled")
+}
+
+func TestInitializeConfigLDAPAuthentication(t *testing.T) {
+	config := NewConfiguration()
+	config.FeatureAuthentication = FeatureEnabled
+	config.LDAPURL = "ldaps://ldap.root.gg"
+
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.True(t, config.LDAPAuthentication, "ldap authentication should be enabled")
+
+	config.LDAPUserFilter = "(uid=plik)"
+	err = config.Initialize()
+	require.Error(t, err, "invalid ldap user filter expected")
+}
+
+func TestInitializeConfigEmailNotifications(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.False(t, config.EmailNotifications, "email notifications should be disabled")
+
+	config.SMTPHost = "smtp.root.gg"
+	err = config.Initialize()
+	RequireError(t, err, "missing EmailFrom")
+
+	config.EmailFrom = "invalid"
+	err = config.Initialize()
+	RequireError(t, err, "invalid EmailFrom")
+
+	config.EmailFrom = "Plik <plik@root.gg>"
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.True(t, config.EmailNotifications, "email notifications should be enabled")
+
+	config.SMTPTLS = true
+	config.SMTPStartTLS = true
+	err = config.Initialize()
+	RequireError(t, err, "mutually exclusive")
+}
+
+func TestInitializeConfigOrphanReconciliation(t *testing.T) {
+	config := NewConfiguration()
+
+	for _, value := range []string{"", ReconciliationReport, ReconciliationDelete} {
+		config.OrphanReconciliation = value
+		err := config.Initialize()
+		require.NoError(t, err, "unable to initialize config")
+	}
+
+	config.OrphanReconciliation = "foo"
+	err := config.Initialize()
+	RequireError(t, err, "invalid OrphanReconciliation foo, expected report or delete")
+}
+
+func TestInitializeConfigDataMigration(t *testing.T) {
+	config := NewConfiguration()
+	config.DataMigration = true
+	err := config.Initialize()
+	RequireError(t, err, "DataMigration requires a FallbackDataBackend")
+
+	config.FallbackDataBackend = "file"
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+}
+
+func TestInitializeConfigColdStorage(t *testing.T) {
+	config := NewConfiguration()
+	require.Equal(t, 604800, config.ColdStorageIdleTime, "invalid default cold storage idle time")
+
+	config.ColdStorageMinAgeStr = "1d"
+	config.ColdStorageIdleTimeStr = "2d"
+	config.ColdStorageMinSizeStr = "10MB"
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Equal(t, 86400, config.ColdStorageMinAge, "invalid cold storage min age")
+	require.Equal(t, 172800, config.ColdStorageIdleTime, "invalid cold storage idle time")
+	require.Equal(t, int64(10000000), config.ColdStorageMinSize, "invalid cold storage min size")
+
+	config.ColdStorageIdleTimeStr = "-1"
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value")
+
+	config.ColdStorageIdleTimeStr = "foo"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse ColdStorageIdleTimeStr")
+}
+
+func TestInitializeConfigClamAV(t *testing.T) {
+	config := NewConfiguration()
+	config.ClamAVAddress = "tcp://127.0.0.1:3310"
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Equal(t, time.Minute, config.GetClamAVTimeout(), "invalid clamav timeout")
+
+	config.ClamAVTimeout = "0"
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative or zero value for ClamAVTimeout")
+
+	config.ClamAVTimeout = "5m"
+	config.ClamAVInfectedPolicy = "foo"
+	err = config.Initialize()
+	RequireError(t, err, "invalid ClamAVInfectedPolicy")
+
+	config.ClamAVInfectedPolicy = ScanPolicyQuarantine
+	config.ClamAVErrorPolicy = "bar"
+	err = config.Initialize()
+	RequireError(t, err, "invalid ClamAVErrorPolicy")
+
+	config.ClamAVErrorPolicy = ScanPolicyAllow
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Equal(t, 5*time.Minute, config.GetClamAVTimeout(), "invalid clamav timeout")
+}
+
+func TestGetPublicURL(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Equal(t, "http://127.0.0.1:8080", config.GetPublicURL().String(), "invalid public url")
+
+	config.DownloadDomain = "https://dl.plik.root.gg"
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Equal(t, "https://dl.plik.root.gg", config.GetPublicURL().String(), "invalid public url")
+
+	config.PublicURL = "https://plik.root.gg/"
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Equal(t, "https://plik.root.gg", config.GetPublicURL().String(), "invalid public url")
 }
 
 func TestInitializeConfigDownloadDomain(t *testing.T) {
@@ -131,6 +269,20 @@ func TestInitializeTTLString(t *testing.T) {
 	require.Equal(t, 30*86400, config.MaxTTL, "invalid max TTL")
 }
 
+func TestInitializeAuditLogRetention(t *testing.T) {
+	config := NewConfiguration()
+	require.Equal(t, 90*86400, config.AuditLogRetention, "invalid default audit log retention")
+
+	config.AuditLogRetentionStr = "7d"
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize valid config")
+	require.Equal(t, 7*86400, config.AuditLogRetention, "invalid audit log retention")
+
+	config.AuditLogRetentionStr = "foo"
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
 func TestInitializeMaxFileSizeString(t *testing.T) {
 	config := NewConfiguration()
 	config.MaxFileSizeStr = "100 MB"
@@ -217,6 +369,8 @@ func TestString(t *testing.T) {
 	config.GoogleAuthentication = true
 	config.OvhAuthentication = true
 	config.OvhAPIEndpoint = "api.ovh.com"
+	config.OIDCAuthentication = true
+	config.LDAPAuthentication = true
 	require.NotEmpty(t, config.String())
 }
 
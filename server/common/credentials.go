@@ -0,0 +1,124 @@
+package common
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Upload basic auth credentials are saved in the upload metadata as an argon2id hash of the
+// base64("login:password") string sent in the Authorization header. Hashes are stored in the
+// PHC string format, so they can be told apart and verified without any extra metadata :
+//
+//	$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>      argon2id(credentials)
+//	$argon2id-md5$v=19$m=19456,t=2,p=1$<salt>$<hash>  argon2id(md5(credentials)), legacy hash upgraded by a migration
+//	<32 hex chars>                                    md5(credentials), legacy hash
+const (
+	credentialsHashPrefix       = "$argon2id$"
+	legacyCredentialsHashPrefix = "$argon2id-md5$"
+
+	argon2Memory  = 19 * 1024
+	argon2Time    = 2
+	argon2Threads = 1
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+var legacyCredentialsHashRegexp = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+// HashUploadCredentials return an argon2id hash ( with salt ) of the upload basic auth credentials
+func HashUploadCredentials(credentials string) (hash string, err error) {
+	return hashArgon2id(credentialsHashPrefix, credentials)
+}
+
+// CheckUploadCredentials check the upload basic auth credentials against the hash saved in the upload metadata
+// needRehash is true if the credentials are valid but the hash is using a legacy format and should be replaced
+// by a new HashUploadCredentials hash
+func CheckUploadCredentials(credentials string, hash string) (ok bool, needRehash bool) {
+	switch {
+	case strings.HasPrefix(hash, credentialsHashPrefix):
+		return checkArgon2id(credentialsHashPrefix, credentials, hash), false
+	case strings.HasPrefix(hash, legacyCredentialsHashPrefix):
+		ok = checkArgon2id(legacyCredentialsHashPrefix, md5sum(credentials), hash)
+		return ok, ok
+	case IsLegacyUploadCredentialsHash(hash):
+		ok = subtle.ConstantTimeCompare([]byte(md5sum(credentials)), []byte(hash)) == 1
+		return ok, ok
+	default:
+		return false, false
+	}
+}
+
+// IsLegacyUploadCredentialsHash return true if the hash is a plain md5 hash of the upload basic auth credentials
+func IsLegacyUploadCredentialsHash(hash string) bool {
+	return legacyCredentialsHashRegexp.MatchString(hash)
+}
+
+// UpgradeLegacyUploadCredentialsHash wraps a legacy md5 hash of the upload basic auth credentials into an argon2id hash
+// This allows to get rid of the md5 hashes without knowing the credentials, they are replaced by a
+// HashUploadCredentials hash on the next successful authentication
+func UpgradeLegacyUploadCredentialsHash(hash string) (string, error) {
+	if !IsLegacyUploadCredentialsHash(hash) {
+		return "", fmt.Errorf("not a legacy upload credentials hash")
+	}
+	return hashArgon2id(legacyCredentialsHashPrefix, hash)
+}
+
+func md5sum(str string) string {
+	sum := md5.Sum([]byte(str))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashArgon2id(prefix string, password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate salt : %s", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", prefix, argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func checkArgon2id(prefix string, password string, hash string) bool {
+	// v=19$m=19456,t=2,p=1$<salt>$<hash>
+	parts := strings.Split(strings.TrimPrefix(hash, prefix), "$")
+	if len(parts) != 4 {
+		return false
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[0], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return false
+	}
+
+	var memory, time uint32
+	var threads uint8
+	_, err = fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	if err != nil || memory == 0 || time == 0 || threads == 0 {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, otherKey) == 1
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/root-gg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashUploadCredentials(t *testing.T) {
+	credentials := EncodeAuthBasicHeader("login", "password")
+
+	hash, err := HashUploadCredentials(credentials)
+	require.NoError(t, err, "hash credentials error")
+	require.True(t, strings.HasPrefix(hash, "$argon2id$v=19$"), "invalid hash format %s", hash)
+
+	hash2, err := HashUploadCredentials(credentials)
+	require.NoError(t, err, "hash credentials error")
+	require.NotEqual(t, hash, hash2, "hashes should be salted")
+
+	ok, needRehash := CheckUploadCredentials(credentials, hash)
+	require.True(t, ok, "valid credentials should match")
+	require.False(t, needRehash, "hash should not need rehash")
+
+	ok, needRehash = CheckUploadCredentials(EncodeAuthBasicHeader("login", "invalid"), hash)
+	require.False(t, ok, "invalid credentials should not match")
+	require.False(t, needRehash, "hash should not need rehash")
+}
+
+func TestCheckUploadCredentialsLegacy(t *testing.T) {
+	credentials := EncodeAuthBasicHeader("login", "password")
+
+	hash, err := utils.Md5sum(credentials)
+	require.NoError(t, err, "md5sum error")
+	require.True(t, IsLegacyUploadCredentialsHash(hash), "hash should be a legacy hash")
+
+	ok, needRehash := CheckUploadCredentials(credentials, hash)
+	require.True(t, ok, "valid credentials should match")
+	require.True(t, needRehash, "legacy hash should need rehash")
+
+	ok, needRehash = CheckUploadCredentials(EncodeAuthBasicHeader("login", "invalid"), hash)
+	require.False(t, ok, "invalid credentials should not match")
+	require.False(t, needRehash, "hash should not need rehash on failure")
+}
+
+func TestUpgradeLegacyUploadCredentialsHash(t *testing.T) {
+	credentials := EncodeAuthBasicHeader("login", "password")
+
+	legacyHash, err := utils.Md5sum(credentials)
+	require.NoError(t, err, "md5sum error")
+
+	hash, err := UpgradeLegacyUploadCredentialsHash(legacyHash)
+	require.NoError(t, err, "upgrade hash error")
+	require.True(t, strings.HasPrefix(hash, "$argon2id-md5$v=19$"), "invalid hash format %s", hash)
+	require.NotContains(t, hash, legacyHash, "upgraded hash should not contain the legacy hash")
+
+	ok, needRehash := CheckUploadCredentials(credentials, hash)
+	require.True(t, ok, "valid credentials should match")
+	require.True(t, needRehash, "upgraded legacy hash should need rehash")
+
+	ok, _ = CheckUploadCredentials(EncodeAuthBasicHeader("login", "invalid"), hash)
+	require.False(t, ok, "invalid credentials should not match")
+
+	_, err = UpgradeLegacyUploadCredentialsHash(hash)
+	RequireError(t, err, "not a legacy upload credentials hash")
+}
+
+func TestCheckUploadCredentialsInvalidHash(t *testing.T) {
+	credentials := EncodeAuthBasicHeader("login", "password")
+
+	for _, hash := range []string{
+		"",
+		"bar",
+		"$argon2id$",
+		"$argon2id$v=19$m=19456,t=2,p=1$salt",
+		"$argon2id$v=18$m=19456,t=2,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=0,t=2,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=19456,t=2,p=1$!!!$aGFzaA",
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdHNhbHRzYWx0c2FsdA$",
+	} {
+		ok, needRehash := CheckUploadCredentials(credentials, hash)
+		require.False(t, ok, "invalid hash %q should not match", hash)
+		require.False(t, needRehash, "invalid hash %q should not need rehash", hash)
+	}
+}
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ReprDigest return the Repr-Digest HTTP header value ( RFC 9530 ) of a hex encoded SHA-256 digest
+func ReprDigest(sha256 string) (header string, err error) {
+	sum, err := hex.DecodeString(sha256)
+	if err != nil {
+		return "", fmt.Errorf("invalid sha256 digest : %s", err)
+	}
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum) + ":", nil
+}
+
+// InstanceDigest return the legacy Digest HTTP header value ( RFC 3230 ) of a hex encoded SHA-256 digest
+func InstanceDigest(sha256 string) (header string, err error) {
+	sum, err := hex.DecodeString(sha256)
+	if err != nil {
+		return "", fmt.Errorf("invalid sha256 digest : %s", err)
+	}
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum), nil
+}
+
+// ParseReprDigest return the hex encoded SHA-256 digest of a Repr-Digest HTTP header value
+// or an empty string if the header does not contain a SHA-256 digest
+func ParseReprDigest(header string) (sha256 string, err error) {
+	for _, member := range strings.Split(header, ",") {
+		algorithm, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || strings.ToLower(strings.TrimSpace(algorithm)) != "sha-256" {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			return "", fmt.Errorf("invalid sha-256 digest %s", value)
+		}
+
+		sum, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil || len(sum) != 32 {
+			return "", fmt.Errorf("invalid sha-256 digest %s", value)
+		}
+
+		return hex.EncodeToString(sum), nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReprDigest(t *testing.T) {
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte("plik")))
+
+	header, err := ReprDigest(sum)
+	require.NoError(t, err, "unable to get digest header")
+	require.Equal(t, "sha-256=:lgmGCShfI1BxUv84f+62mY5PPrDXzTdSxufAeeRTQtc=:", header, "invalid digest header")
+
+	header, err = InstanceDigest(sum)
+	require.NoError(t, err, "unable to get digest header")
+	require.Equal(t, "SHA-256=lgmGCShfI1BxUv84f+62mY5PPrDXzTdSxufAeeRTQtc=", header, "invalid digest header")
+
+	_, err = ReprDigest("foo")
+	RequireError(t, err, "invalid sha256 digest")
+}
+
+func TestParseReprDigest(t *testing.T) {
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte("plik")))
+
+	header, err := ReprDigest(sum)
+	require.NoError(t, err, "unable to get digest header")
+
+	parsed, err := ParseReprDigest(header)
+	require.NoError(t, err, "unable to parse digest header")
+	require.Equal(t, sum, parsed, "invalid digest")
+
+	parsed, err = ParseReprDigest("sha-512=:AAAA:, " + header)
+	require.NoError(t, err, "unable to parse digest header")
+	require.Equal(t, sum, parsed, "invalid digest")
+
+	parsed, err = ParseReprDigest("sha-512=:AAAA:")
+	require.NoError(t, err, "unable to parse digest header")
+	require.Equal(t, "", parsed, "invalid digest")
+
+	_, err = ParseReprDigest("sha-256=AAAA")
+	RequireError(t, err, "invalid sha-256 digest AAAA")
+
+	_, err = ParseReprDigest("sha-256=:AAAA:")
+	RequireError(t, err, "invalid sha-256 digest :AAAA:")
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// MaxEmailRecipients is the maximum number of recipients an upload can be sent to at once
+const MaxEmailRecipients = 20
+
+// MaxEmailMessageLength is the maximum length of the personal message added to an email notification
+const MaxEmailMessageLength = 4096
+
+// UploadNotification are the parameters to send an upload link by email
+type UploadNotification struct {
+	To              []string `json:"to"`
+	Message         string   `json:"message,omitempty"`
+	DownloadReceipt bool     `json:"downloadReceipt,omitempty"`
+}
+
+// Email is a rendered email waiting to be sent by the notifier.
+// Emails are persisted in the metadata backend ( outbox ) so they survive restarts.
+type Email struct {
+	ID          string `gorm:"primary_key"`
+	To          string
+	Subject     string
+	Text        string `gorm:"type:text"`
+	HTML        string `gorm:"type:text"`
+	Attempts    int
+	NextAttempt time.Time `gorm:"index"`
+	LastError   string    `gorm:"type:text"`
+	CreatedAt   time.Time
+}
+
+// ParseEmailRecipients validate a list of email addresses and return the bare addresses without duplicates
+func ParseEmailRecipients(recipients []string) (addresses []string, err error) {
+	seen := make(map[string]bool)
+	for _, recipient := range recipients {
+		recipient = strings.TrimSpace(recipient)
+		if recipient == "" {
+			continue
+		}
+
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email address %s", recipient)
+		}
+
+		key := strings.ToLower(address.Address)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		addresses = append(addresses, address.Address)
+	}
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("missing email recipients")
+	}
+	if len(addresses) > MaxEmailRecipients {
+		return nil, fmt.Errorf("too many email recipients, maximum is %d", MaxEmailRecipients)
+	}
+
+	return addresses, nil
+}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEmailRecipients(t *testing.T) {
+	addresses, err := ParseEmailRecipients([]string{"foo@root.gg", " Bar <bar@root.gg> ", "", "FOO@root.gg"})
+	require.NoError(t, err, "unable to parse email recipients")
+	require.Equal(t, []string{"foo@root.gg", "bar@root.gg"}, addresses, "invalid email recipients")
+}
+
+func TestParseEmailRecipientsInvalid(t *testing.T) {
+	_, err := ParseEmailRecipients(nil)
+	RequireError(t, err, "missing email recipients")
+
+	_, err = ParseEmailRecipients([]string{"", " "})
+	RequireError(t, err, "missing email recipients")
+
+	_, err = ParseEmailRecipients([]string{"foo@root.gg", "invalid"})
+	RequireError(t, err, "invalid email address invalid")
+
+	var recipients []string
+	for i := 0; i <= MaxEmailRecipients; i++ {
+		recipients = append(recipients, fmt.Sprintf("user%d@root.gg", i))
+	}
+	_, err = ParseEmailRecipients(recipients)
+	RequireError(t, err, "too many email recipients")
+}
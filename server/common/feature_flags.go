@@ -55,6 +55,7 @@ func (config *Configuration) initializeFeatureFlags() error {
 		config.initializeFeatureGithub,
 		config.initializeFeatureClients,
 		config.initializeFeatureText,
+		config.initializeFeatureMaxDownloads,
 	}
 
 	for _, initialization := range initializations {
@@ -254,3 +255,16 @@ func (config *Configuration) initializeFeatureText() error {
 
 	return nil
 }
+
+func (config *Configuration) initializeFeatureMaxDownloads() error {
+	if config.FeatureMaxDownloads == "" {
+		config.FeatureMaxDownloads = FeatureEnabled
+	}
+
+	err := ValidateCustomFeatureFlag(config.FeatureMaxDownloads, []string{FeatureDisabled, FeatureEnabled})
+	if err != nil {
+		return fmt.Errorf("Invalid value for FeatureMaxDownloads : %s", err)
+	}
+
+	return nil
+}
@@ -355,6 +355,26 @@ func Test_initializeFeatureText(t *testing.T) {
 	require.Equal(t, FeatureDefault, config.FeatureText)
 }
 
+func Test_initializeFeatureMaxDownloads(t *testing.T) {
+	config := NewConfiguration()
+	config.FeatureMaxDownloads = "invalid"
+	RequireError(t, config.initializeFeatureMaxDownloads(), "Invalid feature flag value")
+
+	config = NewConfiguration()
+	config.FeatureMaxDownloads = ""
+	require.NoError(t, config.initializeFeatureMaxDownloads())
+	require.Equal(t, FeatureEnabled, config.FeatureMaxDownloads)
+
+	config = NewConfiguration()
+	config.FeatureMaxDownloads = FeatureDisabled
+	require.NoError(t, config.initializeFeatureMaxDownloads())
+	require.Equal(t, FeatureDisabled, config.FeatureMaxDownloads)
+
+	config = NewConfiguration()
+	config.FeatureMaxDownloads = FeatureForced
+	RequireError(t, config.initializeFeatureMaxDownloads(), "Invalid feature flag value")
+}
+
 func Test_initializeFeatureFlags(t *testing.T) {
 	config := NewConfiguration()
 	require.NoError(t, config.initializeFeatureFlags())
@@ -369,6 +389,7 @@ func Test_initializeFeatureFlags(t *testing.T) {
 	require.NoError(t, ValidateFeatureFlag(config.FeatureGithub))
 	require.NoError(t, ValidateFeatureFlag(config.FeatureClients))
 	require.NoError(t, ValidateFeatureFlag(config.FeatureText))
+	require.NoError(t, ValidateFeatureFlag(config.FeatureMaxDownloads))
 
 	config = NewConfiguration()
 	config.FeatureOneShot = "invalid"
@@ -13,6 +13,9 @@ const FileUploading = "uploading"
 // FileUploaded when a file has been uploaded and is ready to be downloaded
 const FileUploaded = "uploaded"
 
+// FileQuarantined when the antivirus scan of a file did not pass and the file can't be downloaded
+const FileQuarantined = "quarantined"
+
 // FileRemoved when a file has been removed and can't be downloaded anymore but has not yet been deleted
 const FileRemoved = "removed"
 
@@ -28,12 +31,24 @@ type File struct {
 	Status string `json:"status"`
 
 	Md5       string `json:"fileMd5"`
+	Sha256    string `json:"fileSha256"`
 	Type      string `json:"fileType"`
 	Size      int64  `json:"fileSize"`
 	Reference string `json:"reference"`
 
 	BackendDetails string `json:"-"`
 
+	// Resumable upload progress, the number of bytes and chunks received so far and the chunk IDs in order
+	UploadOffset   int64    `json:"uploadOffset,omitempty"`
+	UploadChunks   int      `json:"-"`
+	UploadChunkIDs []string `json:"-" gorm:"serializer:json"`
+
+	Downloads      int        `json:"downloads,omitempty"`
+	LastDownloadAt *time.Time `json:"-"`
+
+	// Results of the content inspection processors by processor name
+	Processors map[string]string `json:"processors,omitempty" gorm:"serializer:json"`
+
 	CreatedAt time.Time `json:"createdAt"`
 }
 
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var groupIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,64}$`)
+
+// Group is a set of users sharing uploads and quotas
+type Group struct {
+	ID   string `json:"id" gorm:"primary_key"`
+	Name string `json:"name,omitempty"`
+
+	MaxFileSize  int64 `json:"maxFileSize"`
+	MaxGroupSize int64 `json:"maxGroupSize"`
+	MaxTTL       int   `json:"maxTTL"`
+
+	Members []*GroupMember `json:"members,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GroupMember links a user to a group
+type GroupMember struct {
+	GroupID string `json:"-" gorm:"primary_key;size:256;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT;"`
+	UserID  string `json:"user" gorm:"primary_key;size:256;index:idx_group_member_user_id"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsValidGroupID return true if the group ID string is valid
+func IsValidGroupID(ID string) bool {
+	return groupIDRegexp.MatchString(ID)
+}
+
+// IsMember return true if the user is a member of the group
+// Members must have been loaded from the metadata backend
+func (group *Group) IsMember(userID string) bool {
+	for _, member := range group.Members {
+		if member.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateGroupFromParams return a group object ready to be inserted in the metadata backend
+func CreateGroupFromParams(groupParams *Group) (group *Group, err error) {
+	if !IsValidGroupID(groupParams.ID) {
+		return nil, fmt.Errorf("invalid group id (3 to 64 letters, digits, dots, dashes or underscores)")
+	}
+
+	group = &Group{ID: groupParams.ID}
+	UpdateGroup(group, groupParams)
+
+	return group, nil
+}
+
+// UpdateGroup update a group object with the params
+//   - prevent to update group ID or members
+func UpdateGroup(group *Group, groupParams *Group) {
+	group.Name = groupParams.Name
+	group.MaxFileSize = groupParams.MaxFileSize
+	group.MaxGroupSize = groupParams.MaxGroupSize
+	group.MaxTTL = groupParams.MaxTTL
+}
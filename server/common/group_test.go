@@ -0,0 +1,47 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsValidGroupID(t *testing.T) {
+	require.True(t, IsValidGroupID("research"))
+	require.True(t, IsValidGroupID("team-1.ops_eu"))
+	require.False(t, IsValidGroupID(""))
+	require.False(t, IsValidGroupID("ab"))
+	require.False(t, IsValidGroupID("foo bar"))
+	require.False(t, IsValidGroupID("foo/bar"))
+}
+
+func TestGroup_IsMember(t *testing.T) {
+	group := &Group{ID: "research"}
+	require.False(t, group.IsMember("local:user"))
+
+	group.Members = append(group.Members, &GroupMember{GroupID: group.ID, UserID: "local:user"})
+	require.True(t, group.IsMember("local:user"))
+	require.False(t, group.IsMember("local:other"))
+}
+
+func TestCreateGroupFromParams(t *testing.T) {
+	params := &Group{}
+	_, err := CreateGroupFromParams(params)
+	require.Error(t, err, "missing error")
+
+	params.ID = "research"
+	params.Name = "Research department"
+	params.MaxFileSize = 1
+	params.MaxGroupSize = 2
+	params.MaxTTL = 3
+	params.Members = []*GroupMember{{UserID: "local:user"}}
+
+	group, err := CreateGroupFromParams(params)
+	require.NoError(t, err, "unable to create group")
+	require.Equal(t, params.ID, group.ID, "invalid group id")
+	require.Equal(t, params.Name, group.Name, "invalid group name")
+	require.Equal(t, params.MaxFileSize, group.MaxFileSize, "invalid max file size")
+	require.Equal(t, params.MaxGroupSize, group.MaxGroupSize, "invalid max group size")
+	require.Equal(t, params.MaxTTL, group.MaxTTL, "invalid max ttl")
+	require.Empty(t, group.Members, "members should not be set from params")
+}
@@ -24,11 +24,27 @@ type PlikMetrics struct {
 	serverStatsRefreshDuration prometheus.Histogram
 	cleaningDuration           prometheus.Histogram
 
-	cleaningRemovedUploads prometheus.Counter
-	cleaningDeletedFiles   prometheus.Counter
-	cleaningDeletedUploads prometheus.Counter
-	cleaningOrphanFiles    prometheus.Counter
-	cleaningOrphanTokens   prometheus.Counter
+	cleaningRemovedUploads        prometheus.Counter
+	cleaningDeletedFiles          prometheus.Counter
+	cleaningDeletedUploads        prometheus.Counter
+	cleaningOrphanFiles           prometheus.Counter
+	cleaningOrphanTokens          prometheus.Counter
+	cleaningExpiredTokens         prometheus.Counter
+	cleaningExpiredUploadRequests prometheus.Counter
+	cleaningAuditEvents           prometheus.Counter
+	cleaningDemotedFiles          prometheus.Counter
+
+	antivirusScans        *prometheus.CounterVec
+	antivirusScanDuration prometheus.Histogram
+
+	reconciliationDuration     prometheus.Histogram
+	reconciliationFiles        prometheus.Gauge
+	reconciliationOrphanFiles  prometheus.Gauge
+	reconciliationOrphanSize   prometheus.Gauge
+	reconciliationMissingFiles prometheus.Gauge
+	reconciliationDeletedFiles prometheus.Counter
+	reconciliationErrors       prometheus.Gauge
+	lastReconciliation         prometheus.Gauge
 
 	lastStatsRefresh prometheus.Gauge
 	lastCleaning     prometheus.Gauge
@@ -128,6 +144,92 @@ func NewPlikMetrics() (m *PlikMetrics) {
 	})
 	m.reg.MustRegister(m.cleaningOrphanTokens)
 
+	m.cleaningExpiredTokens = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "plik_cleaning_removed_expired_tokens",
+		Help: "Cleaning routine removed expired tokens",
+	})
+	m.reg.MustRegister(m.cleaningExpiredTokens)
+
+	m.cleaningExpiredUploadRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "plik_cleaning_removed_expired_upload_requests",
+		Help: "Cleaning routine removed expired upload requests",
+	})
+	m.reg.MustRegister(m.cleaningExpiredUploadRequests)
+
+	m.cleaningAuditEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "plik_cleaning_removed_audit_events",
+		Help: "Cleaning routine removed expired audit events",
+	})
+	m.reg.MustRegister(m.cleaningAuditEvents)
+
+	m.cleaningDemotedFiles = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "plik_cleaning_demoted_files",
+		Help: "Cleaning routine files moved to the cold data backend",
+	})
+	m.reg.MustRegister(m.cleaningDemotedFiles)
+
+	m.antivirusScans = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "plik_antivirus_scans_total",
+		Help: "Count of antivirus scans by verdict",
+	}, []string{"verdict"})
+	m.reg.MustRegister(m.antivirusScans)
+
+	m.antivirusScanDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "plik_antivirus_scan_duration_second",
+		Help:    "Duration of antivirus scans",
+		Buckets: prometheus.ExponentialBucketsRange((10 * time.Millisecond).Seconds(), (600 * time.Second).Seconds(), 20),
+	})
+	m.reg.MustRegister(m.antivirusScanDuration)
+
+	m.reconciliationDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "plik_reconciliation_duration_second",
+		Help:    "Duration of reconciliation runs",
+		Buckets: prometheus.ExponentialBucketsRange((10 * time.Millisecond).Seconds(), (3600 * time.Second).Seconds(), 20),
+	})
+	m.reg.MustRegister(m.reconciliationDuration)
+
+	m.reconciliationFiles = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "plik_reconciliation_data_files_count",
+		Help: "Number of files in the data backend at the last reconciliation",
+	})
+	m.reg.MustRegister(m.reconciliationFiles)
+
+	m.reconciliationOrphanFiles = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "plik_reconciliation_orphan_files_count",
+		Help: "Number of files in the data backend without metadata at the last reconciliation",
+	})
+	m.reg.MustRegister(m.reconciliationOrphanFiles)
+
+	m.reconciliationOrphanSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "plik_reconciliation_orphan_size_bytes",
+		Help: "Size of the files in the data backend without metadata at the last reconciliation",
+	})
+	m.reg.MustRegister(m.reconciliationOrphanSize)
+
+	m.reconciliationMissingFiles = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "plik_reconciliation_missing_files_count",
+		Help: "Number of uploaded files missing from the data backend at the last reconciliation",
+	})
+	m.reg.MustRegister(m.reconciliationMissingFiles)
+
+	m.reconciliationDeletedFiles = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "plik_reconciliation_deleted_orphan_files",
+		Help: "Reconciliation deleted orphan files",
+	})
+	m.reg.MustRegister(m.reconciliationDeletedFiles)
+
+	m.reconciliationErrors = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "plik_reconciliation_errors_count",
+		Help: "Number of errors at the last reconciliation",
+	})
+	m.reg.MustRegister(m.reconciliationErrors)
+
+	m.lastReconciliation = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "plik_last_reconciliation_timestamp",
+		Help: "Timestamp of the last reconciliation",
+	})
+	m.reg.MustRegister(m.lastReconciliation)
+
 	m.lastStatsRefresh = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "plik_last_stats_refresh_timestamp",
 		Help: "Timestamp of the last server stats refresh",
@@ -172,10 +274,32 @@ func (m *PlikMetrics) UpdateCleaningStatistics(stats *CleaningStats, elapsed tim
 	m.cleaningDeletedUploads.Add(float64(stats.DeletedUploads))
 	m.cleaningOrphanFiles.Add(float64(stats.OrphanFilesCleaned))
 	m.cleaningOrphanTokens.Add(float64(stats.OrphanTokensCleaned))
+	m.cleaningExpiredTokens.Add(float64(stats.ExpiredTokensCleaned))
+	m.cleaningExpiredUploadRequests.Add(float64(stats.ExpiredUploadRequestsCleaned))
+	m.cleaningAuditEvents.Add(float64(stats.AuditEventsCleaned))
+	m.cleaningDemotedFiles.Add(float64(stats.DemotedFiles))
 	m.lastCleaning.Set(float64(time.Now().Second()))
 	m.cleaningDuration.Observe(elapsed.Seconds())
 }
 
+// UpdateReconciliationStatistics update metrics about the reconciliation of the metadata and data backends
+func (m *PlikMetrics) UpdateReconciliationStatistics(stats *ReconciliationStats, elapsed time.Duration) {
+	m.reconciliationFiles.Set(float64(stats.Files))
+	m.reconciliationOrphanFiles.Set(float64(stats.OrphanFiles))
+	m.reconciliationOrphanSize.Set(float64(stats.OrphanSize))
+	m.reconciliationMissingFiles.Set(float64(stats.MissingFiles))
+	m.reconciliationDeletedFiles.Add(float64(stats.DeletedFiles))
+	m.reconciliationErrors.Set(float64(stats.Errors))
+	m.lastReconciliation.Set(float64(time.Now().Unix()))
+	m.reconciliationDuration.Observe(elapsed.Seconds())
+}
+
+// UpdateAntivirusMetrics update metrics about antivirus scans ( verdict is clean, infected or error )
+func (m *PlikMetrics) UpdateAntivirusMetrics(verdict string, elapsed time.Duration) {
+	m.antivirusScans.WithLabelValues(verdict).Add(1)
+	m.antivirusScanDuration.Observe(elapsed.Seconds())
+}
+
 // Register a set of collectors to the dedicated Prometheus registry
 // This can be used by modules to register dedicated metrics
 func (m *PlikMetrics) Register(collectors ...prometheus.Collector) {
@@ -32,6 +32,12 @@ func TestNewPlikMetrics(t *testing.T) {
 	require.NotNil(t, m.cleaningDeletedUploads)
 	require.NotNil(t, m.cleaningOrphanFiles)
 	require.NotNil(t, m.cleaningOrphanTokens)
+	require.NotNil(t, m.cleaningExpiredTokens)
+	require.NotNil(t, m.cleaningExpiredUploadRequests)
+	require.NotNil(t, m.cleaningAuditEvents)
+
+	require.NotNil(t, m.antivirusScans)
+	require.NotNil(t, m.antivirusScanDuration)
 
 	require.NotNil(t, m.lastStatsRefresh)
 	require.NotNil(t, m.lastCleaning)
@@ -104,11 +110,15 @@ func TestUpdateServerStatistics(t *testing.T) {
 func TestUpdateCleaningStatistics(t *testing.T) {
 	m := NewPlikMetrics()
 	stats := &CleaningStats{
-		RemovedUploads:      1,
-		DeletedFiles:        2,
-		DeletedUploads:      3,
-		OrphanFilesCleaned:  4,
-		OrphanTokensCleaned: 5,
+		RemovedUploads:               1,
+		DeletedFiles:                 2,
+		DeletedUploads:               3,
+		OrphanFilesCleaned:           4,
+		OrphanTokensCleaned:          5,
+		ExpiredTokensCleaned:         7,
+		ExpiredUploadRequestsCleaned: 8,
+		AuditEventsCleaned:           6,
+		DemotedFiles:                 9,
 	}
 	m.UpdateCleaningStatistics(stats, 1*time.Second)
 
@@ -134,6 +144,22 @@ func TestUpdateCleaningStatistics(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, float64(stats.OrphanTokensCleaned), *metric.GetCounter().Value)
 
+	err = m.cleaningExpiredTokens.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, float64(stats.ExpiredTokensCleaned), *metric.GetCounter().Value)
+
+	err = m.cleaningExpiredUploadRequests.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, float64(stats.ExpiredUploadRequestsCleaned), *metric.GetCounter().Value)
+
+	err = m.cleaningAuditEvents.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, float64(stats.AuditEventsCleaned), *metric.GetCounter().Value)
+
+	err = m.cleaningDemotedFiles.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, float64(stats.DemotedFiles), *metric.GetCounter().Value)
+
 	err = m.lastCleaning.Write(metric)
 	require.NoError(t, err)
 	require.NotZero(t, *metric.GetGauge().Value)
@@ -142,3 +168,69 @@ func TestUpdateCleaningStatistics(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, uint64(1), *metric.GetHistogram().SampleCount)
 }
+
+func TestUpdateReconciliationStatistics(t *testing.T) {
+	m := NewPlikMetrics()
+	stats := &ReconciliationStats{
+		Files:        10,
+		OrphanFiles:  2,
+		OrphanSize:   1024,
+		DeletedFiles: 2,
+		MissingFiles: 3,
+		Errors:       1,
+	}
+	m.UpdateReconciliationStatistics(stats, 1*time.Second)
+	m.UpdateReconciliationStatistics(stats, 1*time.Second)
+
+	metric := &dto.Metric{}
+
+	err := m.reconciliationFiles.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, float64(stats.Files), *metric.GetGauge().Value)
+
+	err = m.reconciliationOrphanFiles.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, float64(stats.OrphanFiles), *metric.GetGauge().Value)
+
+	err = m.reconciliationOrphanSize.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, float64(stats.OrphanSize), *metric.GetGauge().Value)
+
+	err = m.reconciliationMissingFiles.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, float64(stats.MissingFiles), *metric.GetGauge().Value)
+
+	err = m.reconciliationDeletedFiles.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, float64(2*stats.DeletedFiles), *metric.GetCounter().Value)
+
+	err = m.reconciliationErrors.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, float64(stats.Errors), *metric.GetGauge().Value)
+
+	err = m.lastReconciliation.Write(metric)
+	require.NoError(t, err)
+	require.NotZero(t, *metric.GetGauge().Value)
+
+	err = m.reconciliationDuration.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), *metric.GetHistogram().SampleCount)
+}
+
+func TestUpdateAntivirusMetrics(t *testing.T) {
+	m := NewPlikMetrics()
+	m.UpdateAntivirusMetrics("infected", time.Second)
+	m.UpdateAntivirusMetrics("infected", time.Second)
+
+	counter, err := m.antivirusScans.GetMetricWithLabelValues("infected")
+	require.NoError(t, err)
+
+	metric := &dto.Metric{}
+	err = counter.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, float64(2), *metric.GetCounter().Value)
+
+	err = m.antivirusScanDuration.Write(metric)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), *metric.GetHistogram().SampleCount)
+}
@@ -3,6 +3,9 @@ package common
 // AuthenticationSignatureKeySettingKey setting key for authentication_signature_key
 const AuthenticationSignatureKeySettingKey = "authentication_signature_key"
 
+// DataMigrationCheckpointSettingKey setting key for data_migration_checkpoint
+const DataMigrationCheckpointSettingKey = "data_migration_checkpoint"
+
 // Setting is a config object meant to be shard by all Plik instances using the metadata backend
 type Setting struct {
 	Key   string `gorm:"primary_key"`
@@ -21,11 +21,32 @@ type UserStats struct {
 
 // CleaningStats cleaning statistics
 type CleaningStats struct {
-	RemovedUploads      int
+	RemovedUploads               int
+	DeletedFiles                 int
+	DeletedUploads               int
+	OrphanFilesCleaned           int
+	OrphanTokensCleaned          int
+	ExpiredTokensCleaned         int
+	ExpiredUploadRequestsCleaned int
+	AuditEventsCleaned           int
+	DemotedFiles                 int
+}
+
+// ReconciliationReport only report the orphan and missing files found by the reconciliation
+const ReconciliationReport = "report"
+
+// ReconciliationDelete delete the orphan files found by the reconciliation and mark the missing files as deleted
+const ReconciliationDelete = "delete"
+
+// ReconciliationStats reconciliation statistics
+type ReconciliationStats struct {
+	Files               int // Files listed in the data backend
+	OrphanFiles         int
+	OrphanSize          int64
 	DeletedFiles        int
-	DeletedUploads      int
-	OrphanFilesCleaned  int
-	OrphanTokensCleaned int
+	MissingFiles        int
+	RemovedMissingFiles int
+	Errors              int
 }
 
 // Helpers to build the Server Stats
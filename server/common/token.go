@@ -1,20 +1,41 @@
 package common
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	uuid "github.com/nu7hatch/gouuid"
 )
 
+// Token scopes
+const (
+	TokenScopeUpload = "upload"
+	TokenScopeRead   = "read"
+	TokenScopeDelete = "delete"
+	TokenScopeAdmin  = "admin"
+	TokenScopeToken  = "token"
+)
+
+// TokenScopes is the list of all the token scopes
+var TokenScopes = []string{TokenScopeUpload, TokenScopeRead, TokenScopeDelete, TokenScopeAdmin, TokenScopeToken}
+
 // Token provide a very basic authentication mechanism
 type Token struct {
 	Token   string `json:"token" gorm:"primary_key"`
 	Comment string `json:"comment,omitempty"`
 
+	// Space separated list of scopes, an empty scope grants everything the user can do
+	// except the admin and token scopes that must always be granted explicitly
+	Scope string `json:"scope,omitempty"`
+
 	UserID string `json:"-" gorm:"size:256;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT;"`
 
-	CreatedAt time.Time `json:"createdAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpireAt   *time.Time `json:"expireAt,omitempty" gorm:"index:idx_token_expire_at"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
 }
 
 // NewToken create a new Token instance
@@ -32,3 +53,70 @@ func (t *Token) Initialize() {
 	}
 	t.Token = token.String()
 }
+
+// Fingerprint return a non secret identifier of the token
+func (t *Token) Fingerprint() string {
+	return TokenFingerprint(t.Token)
+}
+
+// TokenFingerprint return the first 16 hex characters of the SHA-256 of the token
+// It identifies the token in the audit log without disclosing it
+func TokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// GetScopes return the list of scopes of the token
+func (t *Token) GetScopes() []string {
+	return strings.Fields(t.Scope)
+}
+
+// HasScope return true if the token is allowed to perform actions of the given scope
+// Tokens without scope ( created before scopes were introduced ) are never granted the admin
+// and token scopes which must always be granted explicitly
+func (t *Token) HasScope(scope string) bool {
+	scopes := t.GetScopes()
+	if len(scopes) == 0 {
+		return scope != TokenScopeAdmin && scope != TokenScopeToken
+	}
+
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsExpired return true if the token expiration date is passed
+func (t *Token) IsExpired() bool {
+	return t.ExpireAt != nil && time.Now().After(*t.ExpireAt)
+}
+
+// Validate check and normalize the token scopes and expiration date
+func (t *Token) Validate() (err error) {
+	scopes := t.GetScopes()
+	for _, scope := range scopes {
+		if !IsValidTokenScope(scope) {
+			return fmt.Errorf("invalid token scope %s", scope)
+		}
+	}
+	t.Scope = strings.Join(scopes, " ")
+
+	if t.IsExpired() {
+		return fmt.Errorf("token expiration date is in the past")
+	}
+
+	return nil
+}
+
+// IsValidTokenScope return true if the scope string is valid
+func IsValidTokenScope(scope string) bool {
+	for _, s := range TokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
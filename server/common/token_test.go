@@ -2,6 +2,7 @@ package common
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -11,3 +12,60 @@ func TestNewToken(t *testing.T) {
 	require.NotNil(t, token, "invalid token")
 	require.NotZero(t, token.Token, "missing token")
 }
+
+func TestToken_HasScope(t *testing.T) {
+	token := NewToken()
+	for _, scope := range TokenScopes {
+		if scope == TokenScopeAdmin || scope == TokenScopeToken {
+			require.False(t, token.HasScope(scope), "token without scope should not have %s scope", scope)
+			continue
+		}
+		require.True(t, token.HasScope(scope), "token without scope should have every other scope")
+	}
+
+	token.Scope = "upload read"
+	require.True(t, token.HasScope(TokenScopeUpload), "token should have upload scope")
+	require.True(t, token.HasScope(TokenScopeRead), "token should have read scope")
+	require.False(t, token.HasScope(TokenScopeDelete), "token should not have delete scope")
+	require.False(t, token.HasScope(TokenScopeAdmin), "token should not have admin scope")
+	require.False(t, token.HasScope(TokenScopeToken), "token should not have token scope")
+}
+
+func TestToken_IsExpired(t *testing.T) {
+	token := NewToken()
+	require.False(t, token.IsExpired(), "token without expiration date should not be expired")
+
+	deadline := time.Now().Add(time.Hour)
+	token.ExpireAt = &deadline
+	require.False(t, token.IsExpired(), "token should not be expired")
+
+	deadline = time.Now().Add(-time.Hour)
+	token.ExpireAt = &deadline
+	require.True(t, token.IsExpired(), "token should be expired")
+}
+
+func TestToken_Validate(t *testing.T) {
+	token := NewToken()
+	require.NoError(t, token.Validate(), "unexpected validation error")
+
+	token.Scope = "  upload   read "
+	require.NoError(t, token.Validate(), "unexpected validation error")
+	require.Equal(t, "upload read", token.Scope, "invalid normalized scope")
+
+	token.Scope = "upload foo"
+	require.Error(t, token.Validate(), "missing invalid scope error")
+
+	token.Scope = ""
+	deadline := time.Now().Add(-time.Hour)
+	token.ExpireAt = &deadline
+	require.Error(t, token.Validate(), "missing expired token error")
+}
+
+func TestTokenFingerprint(t *testing.T) {
+	token := NewToken()
+	fingerprint := token.Fingerprint()
+	require.Len(t, fingerprint, 16, "invalid fingerprint length")
+	require.Equal(t, fingerprint, TokenFingerprint(token.Token), "invalid fingerprint")
+	require.NotContains(t, token.Token, fingerprint, "fingerprint must not disclose the token")
+	require.NotEqual(t, fingerprint, NewToken().Fingerprint(), "fingerprints should differ")
+}
@@ -27,6 +27,7 @@ type Upload struct {
 	UploadToken string `json:"uploadToken,omitempty"`
 	User        string `json:"user,omitempty" gorm:"index:idx_upload_user"`
 	Token       string `json:"token,omitempty" gorm:"index:idx_upload_user_token"`
+	Group       string `json:"group,omitempty" gorm:"index:idx_upload_group"`
 
 	IsAdmin bool `json:"admin" gorm:"-"`
 
@@ -34,6 +35,12 @@ type Upload struct {
 	OneShot   bool `json:"oneShot"`
 	Removable bool `json:"removable"`
 
+	MaxDownloads int `json:"maxDownloads,omitempty"` // Files are removed after MaxDownloads downloads ( 0 for unlimited )
+
+	DownloadReceipt bool `json:"downloadReceipt,omitempty"` // Email the upload owner on the first download
+
+	E2EE bool `json:"e2ee,omitempty" gorm:"column:e2ee"` // Files are encrypted client side, the keys are never sent to the server
+
 	ProtectedByPassword bool   `json:"protectedByPassword"`
 	Login               string `json:"login,omitempty"`
 	Password            string `json:"password,omitempty"`
@@ -101,11 +108,17 @@ func (upload *Upload) Sanitize(config *Configuration) {
 
 	if !upload.IsAdmin {
 		upload.UploadToken = ""
+		upload.Group = ""
 	}
 
 	upload.DownloadDomain = config.DownloadDomain
 	for _, file := range upload.Files {
 		file.Sanitize()
+
+		// Download counts are only visible to the upload owner
+		if !upload.IsAdmin {
+			file.Downloads = 0
+		}
 	}
 }
 
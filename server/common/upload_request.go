@@ -0,0 +1,114 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// UploadRequestTokenHeader is the HTTP header holding the upload request share token
+const UploadRequestTokenHeader = "X-UploadRequestToken"
+
+// UploadRequest is a public link allowing anyone to add files to an upload owned by the requester.
+// The share token only allows to add files, not to read or remove the upload.
+type UploadRequest struct {
+	ID       string `json:"id" gorm:"primary_key"`
+	Token    string `json:"token,omitempty"`
+	UserID   string `json:"-" gorm:"size:256;index:idx_upload_request_user_id"`
+	UploadID string `json:"uploadId,omitempty" gorm:"size:256;index:idx_upload_request_upload_id"`
+	Comment  string `json:"comment,omitempty"`
+
+	MaxFiles    int   `json:"maxFiles"`
+	MaxFileSize int64 `json:"maxFileSize"`
+	MaxSize     int64 `json:"maxSize"`
+	TTL         int   `json:"ttl" gorm:"-"`
+
+	Files int   `json:"files"`
+	Size  int64 `json:"size"` // Includes the size reserved for the files being added
+
+	// Size reserved for the file being added in the current request ( see metadata.ReserveUploadRequestFile )
+	Reserved int64 `json:"-" gorm:"-"`
+
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpireAt   *time.Time `json:"expireAt,omitempty" gorm:"index:idx_upload_request_expire_at"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// NewUploadRequest create a new upload request with random ID and share token
+func NewUploadRequest() (request *UploadRequest) {
+	request = &UploadRequest{}
+	request.ID = GenerateRandomID(16)
+	request.Token = GenerateRandomID(32)
+	return request
+}
+
+// CreateUploadRequestFromParams return an upload request object ready to be inserted in the metadata backend
+// maxFileSize is the requester max file size, the request can only lower this limit
+func CreateUploadRequestFromParams(params *UploadRequest, maxFileSize int64) (request *UploadRequest, err error) {
+	if params.MaxFiles < 0 {
+		return nil, fmt.Errorf("invalid max files")
+	}
+	if params.MaxSize < 0 {
+		return nil, fmt.Errorf("invalid max size")
+	}
+	if params.MaxFileSize < 0 {
+		return nil, fmt.Errorf("invalid max file size")
+	}
+	if len(params.Comment) > 1024 {
+		return nil, fmt.Errorf("comment is too long, maximum length is 1024 characters")
+	}
+
+	request = NewUploadRequest()
+	request.Comment = params.Comment
+	request.MaxFiles = params.MaxFiles
+	request.MaxSize = params.MaxSize
+	request.MaxFileSize = params.MaxFileSize
+	request.TTL = params.TTL
+
+	if maxFileSize > 0 && (request.MaxFileSize == 0 || request.MaxFileSize > maxFileSize) {
+		request.MaxFileSize = maxFileSize
+	}
+
+	return request, nil
+}
+
+// IsExpired return true if the upload request expiration date is passed
+func (request *UploadRequest) IsExpired() bool {
+	return request.ExpireAt != nil && time.Now().After(*request.ExpireAt)
+}
+
+// IsFull return true if the upload request can't accept more files
+func (request *UploadRequest) IsFull() bool {
+	if request.MaxFiles > 0 && request.Files >= request.MaxFiles {
+		return true
+	}
+	if request.MaxSize > 0 && request.Size >= request.MaxSize {
+		return true
+	}
+	return false
+}
+
+// GetMaxFileSize return the maximum size of the next file added through the upload request ( 0 means no limit )
+// Once a file has been reserved the size reserved for it is returned
+func (request *UploadRequest) GetMaxFileSize() int64 {
+	if request.Reserved > 0 {
+		return request.Reserved
+	}
+
+	maxFileSize := request.MaxFileSize
+	if request.MaxSize > 0 {
+		remaining := request.MaxSize - request.Size
+		if maxFileSize <= 0 || remaining < maxFileSize {
+			maxFileSize = remaining
+		}
+	}
+	if maxFileSize < 0 {
+		return 0
+	}
+	return maxFileSize
+}
+
+// Sanitize clear the information the senders don't need to know about
+func (request *UploadRequest) Sanitize() {
+	request.Token = ""
+	request.UploadID = ""
+}
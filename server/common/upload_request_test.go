@@ -0,0 +1,133 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUploadRequest(t *testing.T) {
+	request := NewUploadRequest()
+	require.Len(t, request.ID, 16, "invalid upload request id")
+	require.Len(t, request.Token, 32, "invalid upload request token")
+	require.NotEqual(t, request.ID, request.Token, "upload request id and token should differ")
+}
+
+func TestCreateUploadRequestFromParams(t *testing.T) {
+	params := &UploadRequest{
+		ID:       "foo",
+		Token:    "bar",
+		UserID:   "user",
+		UploadID: "upload",
+		Comment:  "please send me the files",
+		MaxFiles: 3,
+		MaxSize:  1000,
+		TTL:      60,
+		Files:    42,
+		Size:     42,
+	}
+
+	request, err := CreateUploadRequestFromParams(params, 0)
+	require.NoError(t, err, "unable to create upload request")
+	require.NotEqual(t, params.ID, request.ID, "upload request id should be generated")
+	require.NotEqual(t, params.Token, request.Token, "upload request token should be generated")
+	require.Empty(t, request.UserID, "user id should not be copied")
+	require.Empty(t, request.UploadID, "upload id should not be copied")
+	require.Equal(t, params.Comment, request.Comment, "invalid comment")
+	require.Equal(t, params.MaxFiles, request.MaxFiles, "invalid max files")
+	require.Equal(t, params.MaxSize, request.MaxSize, "invalid max size")
+	require.Equal(t, int64(0), request.MaxFileSize, "invalid max file size")
+	require.Equal(t, params.TTL, request.TTL, "invalid ttl")
+	require.Zero(t, request.Files, "files count should not be copied")
+	require.Zero(t, request.Size, "size should not be copied")
+
+	// Requester limit applies
+	request, err = CreateUploadRequestFromParams(&UploadRequest{}, 100)
+	require.NoError(t, err, "unable to create upload request")
+	require.Equal(t, int64(100), request.MaxFileSize, "invalid max file size")
+
+	request, err = CreateUploadRequestFromParams(&UploadRequest{MaxFileSize: 1000}, 100)
+	require.NoError(t, err, "unable to create upload request")
+	require.Equal(t, int64(100), request.MaxFileSize, "invalid max file size")
+
+	request, err = CreateUploadRequestFromParams(&UploadRequest{MaxFileSize: 10}, 100)
+	require.NoError(t, err, "unable to create upload request")
+	require.Equal(t, int64(10), request.MaxFileSize, "invalid max file size")
+
+	request, err = CreateUploadRequestFromParams(&UploadRequest{MaxFileSize: 10}, -1)
+	require.NoError(t, err, "unable to create upload request")
+	require.Equal(t, int64(10), request.MaxFileSize, "invalid max file size")
+}
+
+func TestCreateUploadRequestFromParamsInvalid(t *testing.T) {
+	_, err := CreateUploadRequestFromParams(&UploadRequest{MaxFiles: -1}, 0)
+	RequireError(t, err, "invalid max files")
+
+	_, err = CreateUploadRequestFromParams(&UploadRequest{MaxSize: -1}, 0)
+	RequireError(t, err, "invalid max size")
+
+	_, err = CreateUploadRequestFromParams(&UploadRequest{MaxFileSize: -1}, 0)
+	RequireError(t, err, "invalid max file size")
+
+	comment := make([]byte, 1025)
+	_, err = CreateUploadRequestFromParams(&UploadRequest{Comment: string(comment)}, 0)
+	RequireError(t, err, "comment is too long")
+}
+
+func TestUploadRequest_IsExpired(t *testing.T) {
+	request := &UploadRequest{}
+	require.False(t, request.IsExpired(), "upload request without expiration date should not expire")
+
+	deadline := time.Now().Add(time.Hour)
+	request.ExpireAt = &deadline
+	require.False(t, request.IsExpired(), "upload request should not be expired")
+
+	deadline = time.Now().Add(-time.Hour)
+	request.ExpireAt = &deadline
+	require.True(t, request.IsExpired(), "upload request should be expired")
+}
+
+func TestUploadRequest_IsFull(t *testing.T) {
+	request := &UploadRequest{}
+	require.False(t, request.IsFull(), "unlimited upload request should not be full")
+
+	request = &UploadRequest{MaxFiles: 2, Files: 1}
+	require.False(t, request.IsFull(), "upload request should not be full")
+	request.Files = 2
+	require.True(t, request.IsFull(), "upload request should be full")
+
+	request = &UploadRequest{MaxSize: 100, Size: 99}
+	require.False(t, request.IsFull(), "upload request should not be full")
+	request.Size = 100
+	require.True(t, request.IsFull(), "upload request should be full")
+}
+
+func TestUploadRequest_GetMaxFileSize(t *testing.T) {
+	request := &UploadRequest{}
+	require.Equal(t, int64(0), request.GetMaxFileSize(), "invalid max file size")
+
+	request = &UploadRequest{MaxFileSize: 10}
+	require.Equal(t, int64(10), request.GetMaxFileSize(), "invalid max file size")
+
+	request = &UploadRequest{MaxSize: 100, Size: 40}
+	require.Equal(t, int64(60), request.GetMaxFileSize(), "invalid max file size")
+
+	request = &UploadRequest{MaxFileSize: 10, MaxSize: 100, Size: 40}
+	require.Equal(t, int64(10), request.GetMaxFileSize(), "invalid max file size")
+
+	request = &UploadRequest{MaxFileSize: 100, MaxSize: 100, Size: 95}
+	require.Equal(t, int64(5), request.GetMaxFileSize(), "invalid max file size")
+
+	request = &UploadRequest{MaxSize: 100, Size: 120}
+	require.Equal(t, int64(0), request.GetMaxFileSize(), "invalid max file size")
+}
+
+func TestUploadRequest_Sanitize(t *testing.T) {
+	request := &UploadRequest{ID: "id", Token: "token", UploadID: "upload", Comment: "comment"}
+	request.Sanitize()
+	require.Equal(t, "id", request.ID, "invalid upload request id")
+	require.Empty(t, request.Token, "upload request token should be sanitized")
+	require.Empty(t, request.UploadID, "upload request upload id should be sanitized")
+	require.Equal(t, "comment", request.Comment, "invalid upload request comment")
+}
@@ -22,7 +22,8 @@ func TestUploadNewFile(t *testing.T) {
 
 func TestUploadSanitize(t *testing.T) {
 	upload := &Upload{}
-	upload.NewFile()
+	file := upload.NewFile()
+	file.Downloads = 1
 	upload.RemoteIP = "ip"
 	upload.Login = "login"
 	upload.Password = "password"
@@ -40,18 +41,21 @@ func TestUploadSanitize(t *testing.T) {
 	require.Zero(t, upload.UploadToken, "invalid sanitized upload")
 	require.Zero(t, upload.Token, "invalid sanitized upload")
 	require.Zero(t, upload.UploadToken, "invalid sanitized upload")
+	require.Zero(t, file.Downloads, "invalid sanitized upload")
 	require.Equal(t, config.DownloadDomain, upload.DownloadDomain, "invalid download domain")
 }
 
 func TestUploadSanitizeAdmin(t *testing.T) {
 	upload := &Upload{}
-	upload.NewFile()
+	file := upload.NewFile()
+	file.Downloads = 1
 	upload.UploadToken = "token"
 	upload.IsAdmin = true
 
 	upload.Sanitize(NewConfiguration())
 
 	require.Equal(t, "token", upload.UploadToken, "invalid sanitized upload")
+	require.Equal(t, 1, file.Downloads, "invalid sanitized upload")
 }
 
 func TestUpload_GetFile(t *testing.T) {
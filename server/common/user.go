@@ -14,6 +14,12 @@ const ProviderOVH = "ovh"
 // ProviderLocal for authentication
 const ProviderLocal = "local"
 
+// ProviderOIDC for authentication
+const ProviderOIDC = "oidc"
+
+// ProviderLDAP for authentication
+const ProviderLDAP = "ldap"
+
 // User is a Plik user
 type User struct {
 	ID       string `json:"id,omitempty"`
@@ -49,7 +55,7 @@ func GetUserID(provider string, providerID string) string {
 // IsValidProvider return true if the provider string is valid
 func IsValidProvider(provider string) bool {
 	switch provider {
-	case ProviderLocal, ProviderGoogle, ProviderOVH:
+	case ProviderLocal, ProviderGoogle, ProviderOVH, ProviderOIDC, ProviderLDAP:
 		return true
 	default:
 		return false
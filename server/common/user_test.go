@@ -28,6 +28,8 @@ func TestIsValidProvider(t *testing.T) {
 	require.True(t, IsValidProvider(ProviderLocal))
 	require.True(t, IsValidProvider(ProviderGoogle))
 	require.True(t, IsValidProvider(ProviderOVH))
+	require.True(t, IsValidProvider(ProviderOIDC))
+	require.True(t, IsValidProvider(ProviderLDAP))
 	require.False(t, IsValidProvider(""))
 	require.False(t, IsValidProvider("foo"))
 }
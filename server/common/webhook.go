@@ -0,0 +1,144 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Webhook event types
+const (
+	EventUploadCreated  = "upload_created"
+	EventFileUploaded   = "file_uploaded"
+	EventFileDownloaded = "file_downloaded"
+	EventUploadRemoved  = "upload_removed"
+	EventUploadExpired  = "upload_expired"
+
+	// A file has been added through an upload request, the upload owner is the requester
+	EventUploadRequestUsed = "upload_request_used"
+)
+
+// WebhookEvents is the list of all the available webhook event types
+var WebhookEvents = []string{EventUploadCreated, EventFileUploaded, EventFileDownloaded, EventUploadRemoved, EventUploadExpired, EventUploadRequestUsed}
+
+// WebhookSignatureHeader is the HTTP header holding the HMAC-SHA256 signature of the request body
+const WebhookSignatureHeader = "X-Plik-Signature"
+
+// WebhookConfig describes a webhook endpoint
+type WebhookConfig struct {
+	URL    string   // Endpoint to POST the events to
+	Secret string   // Secret used to sign the events ( HMAC-SHA256 )
+	Events []string // Events to send ( all if empty )
+}
+
+// Validate the webhook configuration
+func (webhook *WebhookConfig) Validate() (err error) {
+	if webhook.URL == "" {
+		return fmt.Errorf("missing webhook URL")
+	}
+
+	u, err := url.Parse(webhook.URL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL %s : %s", webhook.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid webhook URL %s : scheme must be http or https", webhook.URL)
+	}
+
+	for _, event := range webhook.Events {
+		if !IsValidWebhookEvent(event) {
+			return fmt.Errorf("invalid webhook event %s", event)
+		}
+	}
+
+	return nil
+}
+
+// Accept return true if the event has to be sent to the webhook
+func (webhook *WebhookConfig) Accept(event string) bool {
+	if len(webhook.Events) == 0 {
+		return true
+	}
+	for _, e := range webhook.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign return the signature of the payload to set in the WebhookSignatureHeader
+func (webhook *WebhookConfig) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsValidWebhookEvent return true if event is a known webhook event type
+func IsValidWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent is the JSON payload sent to the webhooks
+type WebhookEvent struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Date     time.Time `json:"date"`
+	SourceIP string    `json:"sourceIp,omitempty"`
+	Upload   *Upload   `json:"upload,omitempty"`
+	File     *File     `json:"file,omitempty"`
+}
+
+// NewWebhookEvent create a new webhook event.
+// Upload and file are copied and stripped from any secret.
+func NewWebhookEvent(eventType string, upload *Upload, file *File) (event *WebhookEvent) {
+	event = &WebhookEvent{}
+	event.ID = GenerateRandomID(32)
+	event.Type = eventType
+	event.Date = time.Now()
+
+	if upload != nil {
+		u := *upload
+		u.Files = nil
+		for _, file := range upload.Files {
+			f := *file
+			f.Sanitize()
+			u.Files = append(u.Files, &f)
+		}
+		u.UploadToken = ""
+		u.Token = ""
+		u.Login = ""
+		u.Password = ""
+		u.IsAdmin = false
+		event.Upload = &u
+	}
+
+	if file != nil {
+		f := *file
+		f.Sanitize()
+		event.File = &f
+	}
+
+	return event
+}
+
+// WebhookDelivery is a webhook event waiting to be sent to a webhook endpoint.
+// Deliveries are persisted in the metadata backend ( outbox ) so they survive restarts.
+type WebhookDelivery struct {
+	ID          string `gorm:"primary_key"`
+	URL         string
+	Event       string
+	Payload     string `gorm:"type:text"`
+	Attempts    int
+	NextAttempt time.Time `gorm:"index"`
+	LastError   string    `gorm:"type:text"`
+	CreatedAt   time.Time
+}
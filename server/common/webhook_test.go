@@ -0,0 +1,64 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookConfigValidate(t *testing.T) {
+	webhook := &WebhookConfig{}
+	require.Error(t, webhook.Validate(), "missing error with empty URL")
+
+	webhook.URL = "ftp://hooks.example.com"
+	require.Error(t, webhook.Validate(), "missing error with invalid scheme")
+
+	webhook.URL = "https://hooks.example.com"
+	require.NoError(t, webhook.Validate(), "invalid webhook config")
+
+	webhook.Events = []string{EventUploadCreated, "foo"}
+	require.Error(t, webhook.Validate(), "missing error with invalid event")
+}
+
+func TestWebhookConfigAccept(t *testing.T) {
+	webhook := &WebhookConfig{URL: "https://hooks.example.com"}
+	require.True(t, webhook.Accept(EventUploadCreated), "should accept all events")
+
+	webhook.Events = []string{EventUploadRemoved}
+	require.False(t, webhook.Accept(EventUploadCreated), "should not accept event")
+	require.True(t, webhook.Accept(EventUploadRemoved), "should accept event")
+}
+
+func TestWebhookConfigSign(t *testing.T) {
+	webhook := &WebhookConfig{Secret: "secret"}
+	require.Equal(t, "sha256=b82fcb791acec57859b989b430a826488ce2e479fdf92326bd0a2e8375a42ba4", webhook.Sign([]byte("payload")), "invalid signature")
+}
+
+func TestNewWebhookEvent(t *testing.T) {
+	upload := NewUpload()
+	upload.User = "user"
+	upload.Token = "token"
+	upload.Login = "login"
+	upload.Password = "password"
+	upload.IsAdmin = true
+	file := upload.NewFile()
+	file.BackendDetails = "details"
+
+	event := NewWebhookEvent(EventFileUploaded, upload, file)
+	require.NotEmpty(t, event.ID, "missing event id")
+	require.Equal(t, EventFileUploaded, event.Type, "invalid event type")
+	require.Equal(t, upload.ID, event.Upload.ID, "invalid upload id")
+	require.Equal(t, "user", event.Upload.User, "invalid upload user")
+	require.Empty(t, event.Upload.UploadToken, "upload token should have been removed")
+	require.Empty(t, event.Upload.Token, "upload token should have been removed")
+	require.Empty(t, event.Upload.Login, "upload login should have been removed")
+	require.Empty(t, event.Upload.Password, "upload password should have been removed")
+	require.False(t, event.Upload.IsAdmin, "upload admin should have been removed")
+	require.Len(t, event.Upload.Files, 1, "invalid upload files")
+	require.Empty(t, event.Upload.Files[0].BackendDetails, "file backend details should have been removed")
+	require.Equal(t, file.ID, event.File.ID, "invalid file id")
+	require.Empty(t, event.File.BackendDetails, "file backend details should have been removed")
+
+	require.NotEmpty(t, upload.UploadToken, "upload should not have been modified")
+	require.Equal(t, "details", file.BackendDetails, "file should not have been modified")
+}
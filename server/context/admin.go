@@ -3,6 +3,7 @@ package context
 import "github.com/root-gg/plik/server/common"
 
 // IsAdmin get context user admin status
+// A request authenticated with a token also needs the admin scope
 func (ctx *Context) IsAdmin() bool {
 	ctx.mu.RLock()
 	defer ctx.mu.RUnlock()
@@ -13,6 +14,11 @@ func (ctx *Context) IsAdmin() bool {
 		user = ctx.originalUser
 	}
 
+	// Admin rights must be granted to the token too
+	if ctx.token != nil && !ctx.token.HasScope(common.TokenScopeAdmin) {
+		return false
+	}
+
 	return user != nil && user.IsAdmin
 }
 
@@ -27,3 +27,17 @@ func TestContext_IsAdmin(t *testing.T) {
 	ctx.user.IsAdmin = false
 	require.True(t, ctx.IsAdmin())
 }
+
+func TestContext_IsAdminToken(t *testing.T) {
+	ctx := &Context{}
+	ctx.user = &common.User{IsAdmin: true}
+
+	ctx.token = &common.Token{}
+	require.False(t, ctx.IsAdmin(), "token without scope should not have admin rights")
+
+	ctx.token.Scope = common.TokenScopeUpload
+	require.False(t, ctx.IsAdmin(), "token without admin scope should not have admin rights")
+
+	ctx.token.Scope = common.TokenScopeUpload + " " + common.TokenScopeAdmin
+	require.True(t, ctx.IsAdmin(), "token with admin scope should have admin rights")
+}
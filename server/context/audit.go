@@ -0,0 +1,46 @@
+package context
+
+import (
+	"github.com/root-gg/plik/server/common"
+)
+
+// Audit complete an audit event with who did the current request and from where
+// then persist it to the metadata backend if the audit log is enabled.
+// Failing to record an event is only logged so the request is not aborted.
+func (ctx *Context) Audit(event *common.AuditEvent) {
+	if !ctx.GetConfig().AuditLog {
+		return
+	}
+
+	if user := ctx.GetUser(); user != nil && event.UserID == "" {
+		event.UserID = user.ID
+	}
+	if token := ctx.GetToken(); token != nil && event.TokenFingerprint == "" {
+		event.TokenFingerprint = token.Fingerprint()
+	}
+	if sourceIP := ctx.GetSourceIP(); sourceIP != nil {
+		event.SourceIP = sourceIP.String()
+	}
+	if req := ctx.GetReq(); req != nil {
+		event.UserAgent = req.UserAgent()
+	}
+	if upload := ctx.GetUpload(); upload != nil && event.UploadID == "" {
+		event.UploadID = upload.ID
+	}
+	if file := ctx.GetFile(); file != nil && event.FileID == "" {
+		event.FileID = file.ID
+	}
+
+	err := ctx.GetMetadataBackend().CreateAuditEvent(event)
+	if err != nil {
+		ctx.GetLogger().Warningf("Unable to record %s audit event : %s", event.Action, err)
+	}
+}
+
+// SetAuditUser set the user of the request audit event if any.
+// Login handlers use it to record which account is authenticating.
+func (ctx *Context) SetAuditUser(userID string) {
+	if event := ctx.GetAuditEvent(); event != nil {
+		event.UserID = userID
+	}
+}
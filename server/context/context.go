@@ -6,9 +6,13 @@ import (
 	"sync"
 
 	"github.com/root-gg/logger"
+	"github.com/root-gg/plik/server/clamav"
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/data"
 	"github.com/root-gg/plik/server/metadata"
+	"github.com/root-gg/plik/server/notification"
+	"github.com/root-gg/plik/server/processor"
+	"github.com/root-gg/plik/server/webhook"
 )
 
 // Context to be propagated throughout the middleware chain
@@ -20,6 +24,10 @@ type Context struct {
 	streamBackend       data.Backend
 	authenticator       *common.SessionAuthenticator
 	metrics             *common.PlikMetrics
+	webhookDispatcher   *webhook.Dispatcher
+	notifier            *notification.Notifier
+	scanner             *clamav.Scanner
+	processors          *processor.Chain
 	pagingQuery         *common.PagingQuery
 	sourceIP            net.IP
 	upload              *common.Upload
@@ -27,6 +35,9 @@ type Context struct {
 	user                *common.User
 	originalUser        *common.User
 	token               *common.Token
+	group               *common.Group
+	uploadRequest       *common.UploadRequest
+	auditEvent          *common.AuditEvent
 	isWhitelisted       *bool
 	isRedirectOnFailure bool
 	isQuick             bool
@@ -175,6 +186,70 @@ func (ctx *Context) SetMetrics(metrics *common.PlikMetrics) {
 	ctx.metrics = metrics
 }
 
+// GetWebhookDispatcher get webhookDispatcher from the context.
+func (ctx *Context) GetWebhookDispatcher() *webhook.Dispatcher {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.webhookDispatcher
+}
+
+// SetWebhookDispatcher set webhookDispatcher in the context
+func (ctx *Context) SetWebhookDispatcher(webhookDispatcher *webhook.Dispatcher) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.webhookDispatcher = webhookDispatcher
+}
+
+// GetNotifier get notifier from the context.
+func (ctx *Context) GetNotifier() *notification.Notifier {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.notifier
+}
+
+// SetNotifier set notifier in the context
+func (ctx *Context) SetNotifier(notifier *notification.Notifier) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.notifier = notifier
+}
+
+// GetScanner get scanner from the context.
+func (ctx *Context) GetScanner() *clamav.Scanner {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.scanner
+}
+
+// SetScanner set scanner in the context
+func (ctx *Context) SetScanner(scanner *clamav.Scanner) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.scanner = scanner
+}
+
+// GetProcessors get processors from the context.
+func (ctx *Context) GetProcessors() *processor.Chain {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.processors
+}
+
+// SetProcessors set processors in the context
+func (ctx *Context) SetProcessors(processors *processor.Chain) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.processors = processors
+}
+
 // GetPagingQuery get pagingQuery from the context.
 func (ctx *Context) GetPagingQuery() *common.PagingQuery {
 	ctx.mu.RLock()
@@ -275,6 +350,54 @@ func (ctx *Context) SetToken(token *common.Token) {
 	ctx.token = token
 }
 
+// GetGroup get group from the context.
+func (ctx *Context) GetGroup() *common.Group {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.group
+}
+
+// SetGroup set group in the context
+func (ctx *Context) SetGroup(group *common.Group) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.group = group
+}
+
+// GetUploadRequest get uploadRequest from the context.
+func (ctx *Context) GetUploadRequest() *common.UploadRequest {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.uploadRequest
+}
+
+// SetUploadRequest set uploadRequest in the context
+func (ctx *Context) SetUploadRequest(uploadRequest *common.UploadRequest) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.uploadRequest = uploadRequest
+}
+
+// GetAuditEvent get auditEvent from the context.
+func (ctx *Context) GetAuditEvent() *common.AuditEvent {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.auditEvent
+}
+
+// SetAuditEvent set auditEvent in the context
+func (ctx *Context) SetAuditEvent(auditEvent *common.AuditEvent) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.auditEvent = auditEvent
+}
+
 // IsRedirectOnFailure get isRedirectOnFailure from the context.
 func (ctx *Context) IsRedirectOnFailure() bool {
 	ctx.mu.RLock()
@@ -96,8 +96,16 @@ func (ctx *Context) Fail(message string, err error, status int) {
 	isRedirectOnFailure := ctx.isRedirectOnFailure
 	req := ctx.req
 	resp := ctx.resp
+	auditEvent := ctx.auditEvent
 	ctx.mu.Unlock()
 
+	// Record the failure in the audit event of the request if any
+	if auditEvent != nil {
+		auditEvent.Result = common.AuditFailure
+		auditEvent.Status = status
+		auditEvent.Details = message
+	}
+
 	// Generate log message
 	logMessage := fmt.Sprintf("%s -- %d", message, status)
 	if err != nil {
@@ -14,6 +14,10 @@ my $struct = [
 	'streamBackend', 'data.Backend', { panic => 1 },
 	'authenticator', '*common.SessionAuthenticator', { panic => 1 },
 	'metrics', '*common.PlikMetrics', { panic => 1 },
+	'webhookDispatcher', '*webhook.Dispatcher', {},
+	'notifier', '*notification.Notifier', {},
+	'scanner', '*clamav.Scanner', {},
+	'processors', '*processor.Chain', {},
 
     'pagingQuery',  '*common.PagingQuery', { panic => 1 },
 
@@ -24,6 +28,9 @@ my $struct = [
 	'user', '*common.User', {},
 	'originalUser', '*common.User', { internal => 1 },
 	'token', '*common.Token', {},
+	'group', '*common.Group', {},
+	'uploadRequest', '*common.UploadRequest', {},
+	'auditEvent', '*common.AuditEvent', {},
 
 	'isWhitelisted', '*bool', { internal => 1 },
 	'isRedirectOnFailure', 'bool', {},
@@ -119,9 +126,13 @@ import (
 	"sync"
 
 	"github.com/root-gg/logger"
+	"github.com/root-gg/plik/server/clamav"
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/data"
 	"github.com/root-gg/plik/server/metadata"
+	"github.com/root-gg/plik/server/notification"
+	"github.com/root-gg/plik/server/processor"
+	"github.com/root-gg/plik/server/webhook"
 )
 
 EOF
@@ -0,0 +1,72 @@
+package context
+
+import (
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/notification"
+)
+
+// SendDownloadReceipt email the upload owner the first time a file of the upload is downloaded.
+// Failing to send the receipt is only logged so the request is not aborted.
+func (ctx *Context) SendDownloadReceipt(upload *common.Upload, file *common.File) {
+	notifier := ctx.GetNotifier()
+	if notifier == nil || !upload.DownloadReceipt || upload.User == "" {
+		return
+	}
+
+	// Don't notify the owner of its own downloads
+	if user := ctx.GetUser(); user != nil && user.ID == upload.User {
+		return
+	}
+
+	log := ctx.GetLogger()
+
+	// Only the first download sends the receipt
+	claimed, err := ctx.GetMetadataBackend().ClaimUploadDownloadReceipt(upload.ID)
+	if err != nil {
+		log.Warningf("Unable to claim download receipt : %s", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	upload.DownloadReceipt = false
+
+	owner, err := ctx.GetMetadataBackend().GetUser(upload.User)
+	if err != nil {
+		log.Warningf("Unable to get upload owner : %s", err)
+		return
+	}
+	if owner == nil || owner.Email == "" {
+		return
+	}
+
+	err = notifier.Notify(notification.TemplateDownloadReceipt, []string{owner.Email}, &notification.Data{Upload: upload, File: file})
+	if err != nil {
+		log.Warningf("Unable to send download receipt : %s", err)
+	}
+}
+
+// NotifyUploadRequestUsed email the upload request owner when a file has been sent.
+// Failing to send the notification is only logged so the request is not aborted.
+func (ctx *Context) NotifyUploadRequestUsed(request *common.UploadRequest, upload *common.Upload, file *common.File) {
+	notifier := ctx.GetNotifier()
+	if notifier == nil {
+		return
+	}
+
+	log := ctx.GetLogger()
+
+	owner, err := ctx.GetMetadataBackend().GetUser(request.UserID)
+	if err != nil {
+		log.Warningf("Unable to get upload request owner : %s", err)
+		return
+	}
+	if owner == nil || owner.Email == "" {
+		return
+	}
+
+	err = notifier.Notify(notification.TemplateUploadRequestUsed, []string{owner.Email}, &notification.Data{Upload: upload, File: file, Request: request})
+	if err != nil {
+		log.Warningf("Unable to send upload request notification : %s", err)
+	}
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/dustin/go-humanize"
 	"github.com/root-gg/plik/server/common"
-	"github.com/root-gg/utils"
 )
 
 // CreateUpload from params and context (check configuration and default values, generate upload and file IDs, ... )
@@ -24,6 +23,12 @@ func (ctx *Context) CreateUpload(params *common.Upload) (upload *common.Upload,
 		return nil, err
 	}
 
+	// Set group, this needs to be done before checking the limits
+	err = ctx.setGroup(upload, params.Group)
+	if err != nil {
+		return nil, err
+	}
+
 	// Set user configurable parameters
 	err = ctx.setParams(upload, params)
 	if err != nil {
@@ -58,12 +63,24 @@ func (ctx *Context) CreateUpload(params *common.Upload) (upload *common.Upload,
 }
 
 func (ctx *Context) checkUserTotalUploadedSize(adding int64) error {
+	// Uploads owned by a group only count against the group quota
+	group := ctx.GetGroup()
+	if group != nil {
+		return ctx.checkGroupTotalUploadedSize(group, adding)
+	}
+
+	// Files added through an upload request count against the requester quota
+	request := ctx.GetUploadRequest()
+	if request != nil && ctx.GetUser() == nil {
+		return ctx.checkUploadRequestTotalUploadedSize(request, adding)
+	}
+
 	// Unlimited
 	if ctx.GetUserMaxSize() <= 0 {
 		return nil
 	}
 
-	stats, err := ctx.GetMetadataBackend().GetUserStatistics(ctx.GetUser().ID, nil)
+	stats, err := ctx.GetMetadataBackend().GetUserPersonalStatistics(ctx.GetUser().ID)
 	if err != nil {
 		// TODO handle this HTTPError
 		return common.NewHTTPError("unable to get user statistics", err, http.StatusInternalServerError)
@@ -77,18 +94,60 @@ func (ctx *Context) checkUserTotalUploadedSize(adding int64) error {
 	return nil
 }
 
-// CheckUserTotalUploadedSize checks if context user is over space quota
-func (ctx *Context) CheckUserTotalUploadedSize() error {
-	return ctx.checkUserTotalUploadedSize(0)
+func (ctx *Context) checkGroupTotalUploadedSize(group *common.Group, adding int64) error {
+	// Unlimited
+	if group.MaxGroupSize <= 0 {
+		return nil
+	}
+
+	stats, err := ctx.GetMetadataBackend().GetGroupStatistics(group.ID)
+	if err != nil {
+		return common.NewHTTPError("unable to get group statistics", err, http.StatusInternalServerError)
+	}
+
+	// Check group upload size
+	if stats.TotalSize+adding > group.MaxGroupSize {
+		return fmt.Errorf("maximum group upload size reached. (%s)", humanize.Bytes(uint64(group.MaxGroupSize)))
+	}
+
+	return nil
 }
 
-// CheckUserFreeSpaceForUpload checks if context user has enough space to add this upload
-func (ctx *Context) CheckUserFreeSpaceForUpload(upload *common.Upload) error {
+func (ctx *Context) checkUploadRequestTotalUploadedSize(request *common.UploadRequest, adding int64) error {
+	user, err := ctx.GetMetadataBackend().GetUser(request.UserID)
+	if err != nil {
+		return common.NewHTTPError("unable to get upload request owner", err, http.StatusInternalServerError)
+	}
+	if user == nil {
+		return fmt.Errorf("upload request owner not found")
+	}
+
 	// Unlimited
-	if ctx.GetUserMaxSize() <= 0 {
+	maxUserSize := ctx.getUserMaxSize(user)
+	if maxUserSize <= 0 {
 		return nil
 	}
 
+	stats, err := ctx.GetMetadataBackend().GetUserPersonalStatistics(user.ID)
+	if err != nil {
+		return common.NewHTTPError("unable to get user statistics", err, http.StatusInternalServerError)
+	}
+
+	// Check upload request owner upload size
+	if stats.TotalSize+adding > maxUserSize {
+		return fmt.Errorf("maximum user upload size reached. (%s)", humanize.Bytes(uint64(maxUserSize)))
+	}
+
+	return nil
+}
+
+// CheckUserTotalUploadedSize checks if context user is over space quota
+func (ctx *Context) CheckUserTotalUploadedSize() error {
+	return ctx.checkUserTotalUploadedSize(0)
+}
+
+// CheckUserFreeSpaceForUpload checks if context user (or group for uploads owned by a group) has enough space to add this upload
+func (ctx *Context) CheckUserFreeSpaceForUpload(upload *common.Upload) error {
 	// Compute upload size
 	uploadSize := int64(0)
 	if upload != nil {
@@ -99,6 +158,17 @@ func (ctx *Context) CheckUserFreeSpaceForUpload(upload *common.Upload) error {
 		}
 	}
 
+	// Uploads owned by a group only count against the group quota
+	group := ctx.GetGroup()
+	if group != nil {
+		return ctx.checkGroupTotalUploadedSize(group, uploadSize)
+	}
+
+	// Unlimited
+	if ctx.GetUserMaxSize() <= 0 {
+		return nil
+	}
+
 	// Check user user upload size
 	if uploadSize > ctx.GetUserMaxSize() {
 		return fmt.Errorf("maximum user upload size reached. (%s)", humanize.Bytes(uint64(ctx.GetUser().MaxUserSize)))
@@ -109,7 +179,10 @@ func (ctx *Context) CheckUserFreeSpaceForUpload(upload *common.Upload) error {
 
 // GetUserMaxSize return user max file size if configured or server default
 func (ctx *Context) GetUserMaxSize() int64 {
-	user := ctx.GetUser()
+	return ctx.getUserMaxSize(ctx.GetUser())
+}
+
+func (ctx *Context) getUserMaxSize(user *common.User) int64 {
 	if user == nil {
 		return -1
 	}
@@ -145,6 +218,33 @@ func (ctx *Context) setUser(upload *common.Upload) (err error) {
 	return nil
 }
 
+// setGroup make the upload owned by a group the context user is a member of
+func (ctx *Context) setGroup(upload *common.Upload, groupID string) (err error) {
+	if groupID == "" {
+		return nil
+	}
+
+	user := ctx.GetUser()
+	if user == nil {
+		return fmt.Errorf("anonymous uploads can't be owned by a group")
+	}
+
+	group, err := ctx.GetMetadataBackend().GetGroup(groupID)
+	if err != nil {
+		return common.NewHTTPError("unable to get group", err, http.StatusInternalServerError)
+	}
+
+	// Do not disclose the existence of groups the user is not a member of
+	if group == nil || !(group.IsMember(user.ID) || ctx.IsAdmin()) {
+		return fmt.Errorf("group %s not found", groupID)
+	}
+
+	upload.Group = group.ID
+	ctx.SetGroup(group)
+
+	return nil
+}
+
 func (ctx *Context) setParams(upload *common.Upload, params *common.Upload) (err error) {
 	config := ctx.GetConfig()
 
@@ -169,6 +269,22 @@ func (ctx *Context) setParams(upload *common.Upload, params *common.Upload) (err
 		upload.Stream = true
 	}
 
+	if params.MaxDownloads < 0 {
+		return fmt.Errorf("invalid max downloads %d", params.MaxDownloads)
+	}
+	if params.MaxDownloads > 0 && config.FeatureMaxDownloads == common.FeatureDisabled {
+		return fmt.Errorf("download limits are disabled")
+	}
+	upload.MaxDownloads = params.MaxDownloads
+
+	if params.DownloadReceipt && !config.EmailNotifications {
+		return fmt.Errorf("email notifications are disabled")
+	}
+	upload.DownloadReceipt = params.DownloadReceipt
+
+	// The files of end-to-end encrypted uploads can only be decrypted by the clients
+	upload.E2EE = params.E2EE
+
 	if config.FeatureComments == common.FeatureDisabled {
 		upload.Comments = ""
 	} else {
@@ -212,6 +328,12 @@ func (ctx *Context) setTTL(upload *common.Upload, params *common.Upload) (err er
 			maxTTL = user.MaxTTL
 		}
 
+		// Override maxTTL with group specific limit
+		group := ctx.GetGroup()
+		if group != nil && group.MaxTTL != 0 {
+			maxTTL = group.MaxTTL
+		}
+
 		if maxTTL > 0 {
 			if TTL <= 0 {
 				return fmt.Errorf("cannot set infinite TTL (maximum allowed is : %d)", maxTTL)
@@ -250,8 +372,8 @@ func (ctx *Context) setBasicAuth(upload *common.Upload, login string, password s
 
 	upload.ProtectedByPassword = true
 
-	// Save only the md5sum of this string to authenticate further requests
-	upload.Password, err = utils.Md5sum(common.EncodeAuthBasicHeader(upload.Login, password))
+	// Save only a salted hash of this string to authenticate further requests
+	upload.Password, err = common.HashUploadCredentials(common.EncodeAuthBasicHeader(upload.Login, password))
 	if err != nil {
 		return fmt.Errorf("unable to generate password hash : %s", err)
 	}
@@ -314,6 +436,26 @@ func (ctx *Context) CreateFile(upload *common.Upload, params *common.File) (file
 
 // GetMaxFileSize return the maximum allowed file size for the context
 func (ctx *Context) GetMaxFileSize() int64 {
+	maxFileSize := ctx.getMaxFileSize()
+
+	// Files added through an upload request are also limited by the request
+	request := ctx.GetUploadRequest()
+	if request != nil {
+		requestMaxFileSize := request.GetMaxFileSize()
+		if requestMaxFileSize > 0 && (maxFileSize <= 0 || requestMaxFileSize < maxFileSize) {
+			maxFileSize = requestMaxFileSize
+		}
+	}
+
+	return maxFileSize
+}
+
+func (ctx *Context) getMaxFileSize() int64 {
+	group := ctx.GetGroup()
+	if group != nil && group.MaxFileSize != 0 {
+		return group.MaxFileSize
+	}
+
 	user := ctx.GetUser()
 	if user != nil && user.MaxFileSize != 0 {
 		return user.MaxFileSize
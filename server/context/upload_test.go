@@ -1,7 +1,6 @@
 package context
 
 import (
-	"github.com/root-gg/utils"
 	"net"
 	"testing"
 	"time"
@@ -103,6 +102,60 @@ func TestUpload_OneShotForced(t *testing.T) {
 	require.True(t, upload.OneShot)
 }
 
+func TestUpload_MaxDownloadsDisabled(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.FeatureMaxDownloads = common.FeatureDisabled
+
+	upload, err := ctx.CreateUpload(&common.Upload{})
+	require.NoError(t, err)
+	require.NotNil(t, upload)
+	require.Equal(t, 0, upload.MaxDownloads)
+
+	upload, err = ctx.CreateUpload(&common.Upload{MaxDownloads: 3})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "download limits are disabled")
+	require.Nil(t, upload)
+}
+
+func TestUpload_MaxDownloadsEnabled(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.FeatureMaxDownloads = common.FeatureEnabled
+
+	upload, err := ctx.CreateUpload(&common.Upload{MaxDownloads: 3})
+	require.NoError(t, err)
+	require.NotNil(t, upload)
+	require.Equal(t, 3, upload.MaxDownloads)
+
+	upload, err = ctx.CreateUpload(&common.Upload{MaxDownloads: -1})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid max downloads")
+	require.Nil(t, upload)
+}
+
+func TestUpload_DownloadReceipt(t *testing.T) {
+	ctx := newTestContext()
+
+	upload, err := ctx.CreateUpload(&common.Upload{DownloadReceipt: true})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "email notifications are disabled")
+	require.Nil(t, upload)
+
+	ctx.config.EmailNotifications = true
+	upload, err = ctx.CreateUpload(&common.Upload{DownloadReceipt: true})
+	require.NoError(t, err)
+	require.NotNil(t, upload)
+	require.True(t, upload.DownloadReceipt)
+}
+
+func TestUpload_E2EE(t *testing.T) {
+	ctx := newTestContext()
+
+	upload, err := ctx.CreateUpload(&common.Upload{E2EE: true})
+	require.NoError(t, err)
+	require.NotNil(t, upload)
+	require.True(t, upload.E2EE)
+}
+
 func TestUpload_RemovableDisabled(t *testing.T) {
 	ctx := newTestContext()
 	ctx.config.FeatureRemovable = common.FeatureDisabled
@@ -221,11 +274,12 @@ func TestUpload_PasswordEnabled(t *testing.T) {
 	require.NotNil(t, upload)
 	require.True(t, upload.ProtectedByPassword)
 
-	md5sum, err := utils.Md5sum(common.EncodeAuthBasicHeader("login", "password"))
-	require.NoError(t, err)
-
 	require.Equal(t, "login", upload.Login)
-	require.Equal(t, md5sum, upload.Password)
+	require.False(t, common.IsLegacyUploadCredentialsHash(upload.Password), "upload password should not be a md5 hash")
+
+	ok, needRehash := common.CheckUploadCredentials(common.EncodeAuthBasicHeader("login", "password"), upload.Password)
+	require.True(t, ok, "invalid upload password hash")
+	require.False(t, needRehash, "invalid upload password hash")
 }
 
 func TestUpload_PasswordForced(t *testing.T) {
@@ -251,10 +305,8 @@ func TestUpload_PasswordDefaultLogin(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, upload)
 	require.Equal(t, "plik", upload.Login)
-	md5sum, err := utils.Md5sum(common.EncodeAuthBasicHeader("plik", "bar"))
-	require.NoError(t, err)
-
-	require.Equal(t, md5sum, upload.Password)
+	ok, _ := common.CheckUploadCredentials(common.EncodeAuthBasicHeader("plik", "bar"), upload.Password)
+	require.True(t, ok, "invalid upload password hash")
 	require.True(t, upload.ProtectedByPassword)
 }
 
@@ -772,3 +824,177 @@ func TestGetUserMaxSizeUserServerDefaultUnlimited(t *testing.T) {
 	maxUserSize := ctx.GetUserMaxSize()
 	require.Equal(t, int64(-1), maxUserSize)
 }
+
+func TestCreateUploadGroup(t *testing.T) {
+	ctx := newTestContext()
+	defer setupNewMetadataBackend(ctx)()
+	ctx.config.FeatureAuthentication = common.FeatureEnabled
+
+	group := &common.Group{ID: "group"}
+	err := ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+	err = ctx.GetMetadataBackend().AddGroupMember(group.ID, "member")
+	require.NoError(t, err, "unable to add group member")
+
+	_, err = ctx.CreateUpload(&common.Upload{Group: group.ID})
+	common.RequireError(t, err, "anonymous uploads can't be owned by a group")
+
+	ctx.user = &common.User{ID: "user"}
+	_, err = ctx.CreateUpload(&common.Upload{Group: group.ID})
+	common.RequireError(t, err, "group group not found")
+
+	_, err = ctx.CreateUpload(&common.Upload{Group: "missing"})
+	common.RequireError(t, err, "group missing not found")
+
+	ctx.user = &common.User{ID: "member"}
+	upload, err := ctx.CreateUpload(&common.Upload{Group: group.ID})
+	require.NoError(t, err, "unable to create upload")
+	require.Equal(t, group.ID, upload.Group, "invalid upload group")
+	require.NotNil(t, ctx.GetGroup(), "missing group from context")
+
+	ctx = newTestContext()
+	defer setupNewMetadataBackend(ctx)()
+	ctx.config.FeatureAuthentication = common.FeatureEnabled
+	err = ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+
+	ctx.user = &common.User{ID: "admin", IsAdmin: true}
+	upload, err = ctx.CreateUpload(&common.Upload{Group: group.ID})
+	require.NoError(t, err, "admin should be able to create upload for any group")
+	require.Equal(t, group.ID, upload.Group, "invalid upload group")
+}
+
+func TestSetTTLGroup(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.MaxTTL = 10
+	ctx.config.FeatureAuthentication = common.FeatureEnabled
+	ctx.user = &common.User{MaxTTL: 20}
+	ctx.group = &common.Group{MaxTTL: 100}
+	upload, err := ctx.CreateUpload(&common.Upload{TTL: 60})
+	require.NoError(t, err, "unable to set ttl")
+	require.Equal(t, 60, upload.TTL, "invalid TTL")
+
+	ctx.group.MaxTTL = 30
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 60})
+	common.RequireError(t, err, "invalid TTL")
+}
+
+func TestCreateWithFileTooBigGroup(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.FeatureAuthentication = common.FeatureEnabled
+	ctx.config.MaxFileSize = 1024
+	ctx.user = &common.User{MaxFileSize: 2048}
+	ctx.group = &common.Group{MaxFileSize: 100 * 1024}
+
+	params := &common.Upload{}
+	file := &common.File{Name: "foo", Size: 10 * 1024}
+	params.Files = append(params.Files, file)
+
+	_, err := ctx.CreateUpload(params)
+	require.NoError(t, err, "unable to create upload")
+
+	ctx.group.MaxFileSize = 1024
+	_, err = ctx.CreateUpload(params)
+	common.RequireError(t, err, "is too big")
+}
+
+func TestCheckGroupFreeSpaceForUpload(t *testing.T) {
+	ctx := newTestContext()
+	defer setupNewMetadataBackend(ctx)()
+	ctx.user = &common.User{ID: "test", MaxUserSize: 1024}
+	ctx.group = &common.Group{ID: "group", MaxGroupSize: 2048}
+
+	// Group uploads do not count against the user quota
+	upload := common.NewUpload()
+	upload.User = ctx.user.ID
+	upload.Group = ctx.group.ID
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	file.Size = 1500
+
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err)
+
+	params := &common.Upload{}
+	params.Files = append(params.Files, &common.File{Name: "foo", Size: 500})
+
+	err = ctx.CheckUserFreeSpaceForUpload(params)
+	require.NoError(t, err, "group should have enough free space")
+
+	err = ctx.CheckUserTotalUploadedSize()
+	require.NoError(t, err, "group should not be over quota")
+
+	params.Files = append(params.Files, &common.File{Name: "bar", Size: 500})
+	err = ctx.CheckUserFreeSpaceForUpload(params)
+	common.RequireError(t, err, "maximum group upload size reached")
+
+	// Personal uploads are not affected by the group uploads
+	ctx.group = nil
+	err = ctx.CheckUserFreeSpaceForUpload(&common.Upload{Files: []*common.File{{Name: "foo", Size: 1000}}})
+	require.NoError(t, err, "user should have enough free space")
+
+	// Unlimited group
+	ctx.group = &common.Group{ID: "group"}
+	err = ctx.CheckUserFreeSpaceForUpload(&common.Upload{Files: []*common.File{{Name: "foo", Size: 1e9}}})
+	require.NoError(t, err, "group should be unlimited")
+}
+
+func TestGetMaxFileSizeUploadRequest(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.MaxFileSize = 1000
+
+	ctx.uploadRequest = &common.UploadRequest{}
+	require.Equal(t, int64(1000), ctx.GetMaxFileSize(), "invalid max file size")
+
+	ctx.uploadRequest = &common.UploadRequest{MaxFileSize: 100}
+	require.Equal(t, int64(100), ctx.GetMaxFileSize(), "invalid max file size")
+
+	ctx.uploadRequest = &common.UploadRequest{MaxFileSize: 100, MaxSize: 500, Size: 450}
+	require.Equal(t, int64(50), ctx.GetMaxFileSize(), "invalid max file size")
+
+	ctx.uploadRequest = &common.UploadRequest{MaxFileSize: 5000}
+	require.Equal(t, int64(1000), ctx.GetMaxFileSize(), "invalid max file size")
+
+	ctx.config.MaxFileSize = -1
+	require.Equal(t, int64(5000), ctx.GetMaxFileSize(), "invalid max file size")
+}
+
+func TestCheckUploadRequestTotalUploadedSize(t *testing.T) {
+	ctx := newTestContext()
+	defer setupNewMetadataBackend(ctx)()
+
+	user := &common.User{ID: "test", MaxUserSize: 1024}
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err)
+
+	upload := common.NewUpload()
+	upload.User = user.ID
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	file.Size = 1000
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err)
+
+	// Files added through the upload request count against the requester quota
+	ctx.uploadRequest = &common.UploadRequest{UserID: user.ID, UploadID: upload.ID}
+
+	err = ctx.CheckUserTotalUploadedSize()
+	require.NoError(t, err, "requester should not be over quota")
+
+	err = ctx.checkUserTotalUploadedSize(100)
+	common.RequireError(t, err, "maximum user upload size reached")
+
+	// Unlimited requester
+	user.MaxUserSize = -1
+	err = ctx.GetMetadataBackend().UpdateUser(user)
+	require.NoError(t, err)
+
+	err = ctx.checkUserTotalUploadedSize(1e9)
+	require.NoError(t, err, "requester should be unlimited")
+
+	// Missing requester
+	ctx.uploadRequest = &common.UploadRequest{UserID: "missing"}
+	err = ctx.CheckUserTotalUploadedSize()
+	common.RequireError(t, err, "upload request owner not found")
+}
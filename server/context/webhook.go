@@ -0,0 +1,24 @@
+package context
+
+import (
+	"github.com/root-gg/plik/server/common"
+)
+
+// EmitWebhookEvent add an upload lifecycle event to the webhook outbox.
+// Failing to emit an event is only logged so the request is not aborted.
+func (ctx *Context) EmitWebhookEvent(eventType string, upload *common.Upload, file *common.File) {
+	dispatcher := ctx.GetWebhookDispatcher()
+	if dispatcher == nil {
+		return
+	}
+
+	event := common.NewWebhookEvent(eventType, upload, file)
+	if ctx.GetSourceIP() != nil {
+		event.SourceIP = ctx.GetSourceIP().String()
+	}
+
+	err := dispatcher.Emit(event)
+	if err != nil {
+		ctx.GetLogger().Warningf("Unable to emit %s webhook event : %s", eventType, err)
+	}
+}
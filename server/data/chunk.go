@@ -0,0 +1,113 @@
+package data
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// Resumable uploads are received as a sequence of chunks. Each chunk is persisted
+// as an independent object through the regular Backend interface using a derived
+// file ID so every data backend supports partial uploads without any specific code.
+// Once all the chunks have been received they are concatenated to the final file.
+
+// NewChunk return a file object describing the next chunk of a file with a unique ID.
+// Concurrent requests never overwrite each other's chunks, a chunk becomes part of the file
+// once its ID has been recorded in the file metadata ( see metadata.Backend.UpdateFileUploadOffset ).
+func NewChunk(file *common.File) *common.File {
+	return newChunk(file, fmt.Sprintf("%s.%d.%s", file.ID, file.UploadChunks, common.GenerateRandomID(8)))
+}
+
+// GetChunkIDs return the IDs of the chunks of a file in order
+func GetChunkIDs(file *common.File) (ids []string) {
+	for i := 0; i < file.UploadChunks; i++ {
+		ids = append(ids, GetChunk(file, i).ID)
+	}
+	return ids
+}
+
+// GetChunk return a file object describing the n-th chunk of a file.
+// Chunks share the file backend details ( encryption keys, ... ) so they can be read back.
+func GetChunk(file *common.File, n int) *common.File {
+	if n < len(file.UploadChunkIDs) {
+		return newChunk(file, file.UploadChunkIDs[n])
+	}
+
+	// Chunks received before the chunk IDs were recorded
+	return newChunk(file, fmt.Sprintf("%s.%d", file.ID, n))
+}
+
+func newChunk(file *common.File, id string) *common.File {
+	chunk := &common.File{}
+	chunk.ID = id
+	chunk.UploadID = file.UploadID
+	chunk.Name = file.Name
+	chunk.Status = file.Status
+	chunk.BackendDetails = file.BackendDetails
+	return chunk
+}
+
+// GetChunksReader return a reader over all the chunks of a file in order.
+// Chunks are opened lazily one after the other.
+func GetChunksReader(backend Backend, file *common.File) io.ReadCloser {
+	return &chunksReader{backend: backend, file: file}
+}
+
+// RemoveChunks delete all the chunks of a file from the data backend
+func RemoveChunks(backend Backend, file *common.File) (err error) {
+	for i := 0; i < file.UploadChunks; i++ {
+		err = backend.RemoveFile(GetChunk(file, i))
+		if err != nil {
+			return fmt.Errorf("unable to remove chunk %d : %s", i, err)
+		}
+	}
+	return nil
+}
+
+type chunksReader struct {
+	backend Backend
+	file    *common.File
+	current io.ReadCloser
+	next    int
+}
+
+func (r *chunksReader) Read(p []byte) (n int, err error) {
+	for {
+		if r.current == nil {
+			if r.next >= r.file.UploadChunks {
+				return 0, io.EOF
+			}
+
+			r.current, err = r.backend.GetFile(GetChunk(r.file, r.next))
+			if err != nil {
+				return 0, fmt.Errorf("unable to get chunk %d : %s", r.next, err)
+			}
+			r.next++
+		}
+
+		n, err = r.current.Read(p)
+		if err == io.EOF {
+			err = r.current.Close()
+			r.current = nil
+			if err != nil {
+				return n, err
+			}
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+
+		return n, err
+	}
+}
+
+func (r *chunksReader) Close() error {
+	if r.current != nil {
+		err := r.current.Close()
+		r.current = nil
+		return err
+	}
+	return nil
+}
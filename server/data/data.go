@@ -1,11 +1,16 @@
 package data
 
 import (
+	"errors"
+	"fmt"
 	"io"
 
 	"github.com/root-gg/plik/server/common"
 )
 
+// ErrFileNotFound is returned ( or wrapped ) by the data backends when the file does not exist
+var ErrFileNotFound = errors.New("file not found")
+
 // Backend interface describes methods that data backend
 // must implements to be compatible with Plik.
 type Backend interface {
@@ -14,3 +19,86 @@ type Backend interface {
 	// RemoveFile should not fail if the file is not found
 	RemoveFile(file *common.File) (err error)
 }
+
+// RangeBackend interface describes data backends able to read
+// a part of a file without reading it from the beginning.
+type RangeBackend interface {
+	// GetFileRange return length bytes of the file starting at offset ( -1 length to read until the end )
+	GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error)
+}
+
+// MoveBackend interface describes data backends able to rename
+// a file without reading its content again.
+type MoveBackend interface {
+	MoveFile(from *common.File, to *common.File) (err error)
+}
+
+// ListBackend interface describes data backends able to enumerate
+// the files they store.
+type ListBackend interface {
+	// ForEachFile execute f for each file stored in the data backend. Only the ID, the UploadID
+	// ( when the backend stores it ), the Size and the CreatedAt date are set. The files can be passed to RemoveFile.
+	ForEachFile(f func(file *common.File) error) (err error)
+}
+
+// GetFileRange return length bytes of the file starting at offset ( -1 length to read until the end )
+// If the backend does not implement RangeBackend the first offset bytes are read and discarded.
+func GetFileRange(backend Backend, file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	if rangeBackend, ok := backend.(RangeBackend); ok {
+		return rangeBackend.GetFileRange(file, offset, length)
+	}
+
+	reader, err = backend.GetFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		_, err = io.CopyN(io.Discard, reader, offset)
+		if err != nil {
+			_ = reader.Close()
+			return nil, fmt.Errorf("unable to skip to offset %d : %w", offset, err)
+		}
+	}
+
+	return LimitReadCloser(reader, length), nil
+}
+
+// MoveFile rename a file in the data backend ( the destination file is overwritten if it exists )
+// If the backend does not implement MoveBackend the file is copied then removed.
+func MoveFile(backend Backend, from *common.File, to *common.File) (err error) {
+	if moveBackend, ok := backend.(MoveBackend); ok {
+		return moveBackend.MoveFile(from, to)
+	}
+
+	reader, err := backend.GetFile(from)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+
+	err = backend.RemoveFile(to)
+	if err != nil {
+		return err
+	}
+
+	err = backend.AddFile(to, reader)
+	if err != nil {
+		return err
+	}
+
+	return backend.RemoveFile(from)
+}
+
+// LimitReadCloser return a ReadCloser that reads at most n bytes from reader ( -1 for no limit )
+func LimitReadCloser(reader io.ReadCloser, n int64) io.ReadCloser {
+	if n < 0 {
+		return reader
+	}
+	return &limitedReadCloser{Reader: io.LimitReader(reader, n), Closer: reader}
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,185 @@
+package dedup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+// Deduplication layer for data backends :
+//   - The content is first streamed to a staging file while computing its SHA-256
+//   - The staging file is then simply removed if the same content has already been stored
+//     or renamed to a blob data file named after the hash and the file ID before the blob
+//     is created in the metadata database, so a blob is never visible without its content
+//   - Blob reference counts are kept in the metadata database and the blob
+//     is deleted from the underlying data backend when the last reference goes away.
+//     As each blob has its own data file a blob created again concurrently is never affected.
+//
+// Files stored before the deduplication was enabled do not reference any blob
+// and are transparently read from / removed from the underlying data backend.
+
+// BlobUploadID is the pseudo upload ID used to store blobs in the underlying data backend
+const BlobUploadID = "blobs"
+
+// Ensure Dedup Data Backend implements data.Backend and data.RangeBackend interfaces
+var _ data.Backend = (*Backend)(nil)
+var _ data.RangeBackend = (*Backend)(nil)
+
+// Backend object
+type Backend struct {
+	backend  data.Backend
+	metadata *metadata.Backend
+}
+
+// NewBackend instantiate a new Dedup Data Backend
+// wrapping the data backend passed as argument
+func NewBackend(backend data.Backend, metadataBackend *metadata.Backend) (b *Backend) {
+	b = new(Backend)
+	b.backend = backend
+	b.metadata = metadataBackend
+	return b
+}
+
+// GetBackend return the underlying data backend
+func (b *Backend) GetBackend() data.Backend {
+	return b.backend
+}
+
+// GetFile implementation for Dedup Data Backend
+func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
+	blob, err := b.metadata.GetFileBlob(file.ID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file blob : %s", err)
+	}
+
+	if blob == nil {
+		return b.backend.GetFile(file)
+	}
+
+	return b.backend.GetFile(GetBlobFile(blob))
+}
+
+// GetFileRange implementation for Dedup Data Backend
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	blob, err := b.metadata.GetFileBlob(file.ID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file blob : %s", err)
+	}
+
+	if blob == nil {
+		return data.GetFileRange(b.backend, file, offset, length)
+	}
+
+	return data.GetFileRange(b.backend, GetBlobFile(blob), offset, length)
+}
+
+// AddFile implementation for Dedup Data Backend
+func (b *Backend) AddFile(file *common.File, reader io.Reader) (err error) {
+	staging := getStagingFile(file)
+
+	// The hash is computed while streaming the content to the staging file
+	hash := sha256.New()
+	counter := &byteCounter{}
+	err = b.backend.AddFile(staging, io.TeeReader(reader, io.MultiWriter(hash, counter)))
+	if err != nil {
+		_ = b.backend.RemoveFile(staging)
+		return err
+	}
+
+	blobID := hex.EncodeToString(hash.Sum(nil))
+
+	blob, err := b.metadata.ReferenceBlob(file.ID, blobID)
+	if err != nil {
+		_ = b.backend.RemoveFile(staging)
+		return fmt.Errorf("unable to add blob reference : %s", err)
+	}
+
+	if blob != nil {
+		// The same content has already been stored, a leftover staging file
+		// would only waste some space so the error is not reported
+		_ = b.backend.RemoveFile(staging)
+		return nil
+	}
+
+	blob = &common.Blob{}
+	blob.ID = blobID
+	blob.DataID = blobID + "." + file.ID
+	blob.Size = counter.size
+	blob.BackendDetails = staging.BackendDetails
+
+	blobFile := GetBlobFile(blob)
+	err = data.MoveFile(b.backend, staging, blobFile)
+	if err != nil {
+		_ = b.backend.RemoveFile(staging)
+		return fmt.Errorf("unable to move staging file to blob %s : %s", blob.ID, err)
+	}
+	blob.BackendDetails = blobFile.BackendDetails
+
+	created, err := b.metadata.AddBlobReference(file.ID, blob)
+	if err != nil {
+		_ = b.backend.RemoveFile(blobFile)
+		return fmt.Errorf("unable to add blob reference : %s", err)
+	}
+
+	if !created {
+		// The same content has been stored concurrently
+		_ = b.backend.RemoveFile(blobFile)
+	}
+
+	return nil
+}
+
+// RemoveFile implementation for Dedup Data Backend
+func (b *Backend) RemoveFile(file *common.File) (err error) {
+	blob, deleted, err := b.metadata.RemoveBlobReference(file.ID)
+	if err != nil {
+		return fmt.Errorf("unable to remove blob reference : %s", err)
+	}
+
+	if blob == nil {
+		return b.backend.RemoveFile(file)
+	}
+
+	if deleted {
+		return b.backend.RemoveFile(GetBlobFile(blob))
+	}
+
+	return nil
+}
+
+// GetBlobFile return a file object describing the blob in the underlying data backend
+func GetBlobFile(blob *common.Blob) *common.File {
+	file := &common.File{}
+	file.ID = blob.ID
+	if blob.DataID != "" {
+		file.ID = blob.DataID
+	}
+	file.UploadID = BlobUploadID
+	file.Size = blob.Size
+	file.BackendDetails = blob.BackendDetails
+	return file
+}
+
+func getStagingFile(file *common.File) *common.File {
+	staging := &common.File{}
+	staging.ID = file.ID + ".staging"
+	staging.UploadID = file.UploadID
+	staging.Name = file.Name
+	staging.Type = file.Type
+	staging.Size = file.Size
+	return staging
+}
+
+type byteCounter struct {
+	size int64
+}
+
+func (c *byteCounter) Write(p []byte) (n int, err error) {
+	c.size += int64(len(p))
+	return len(p), nil
+}
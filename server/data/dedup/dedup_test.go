@@ -0,0 +1,187 @@
+package dedup
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	data_test "github.com/root-gg/plik/server/data/testing"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+func newTestBackend(t *testing.T) (backend *Backend, dataBackend *data_test.Backend) {
+	config := common.NewConfiguration()
+	metadataBackendConfig := &metadata.Config{Driver: "sqlite3", ConnectionString: "/tmp/plik.dedup.test.db", EraseFirst: true}
+	metadataBackend, err := metadata.NewBackend(metadataBackendConfig, config.NewLogger())
+	require.NoError(t, err, "unable to create metadata backend")
+
+	dataBackend = data_test.NewBackend()
+	return NewBackend(dataBackend, metadataBackend), dataBackend
+}
+
+func readFile(t *testing.T, backend *Backend, file *common.File) string {
+	reader, err := backend.GetFile(file)
+	require.NoError(t, err, "unable to get file")
+	defer func() { _ = reader.Close() }()
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	return string(content)
+}
+
+func TestAddFileDedup(t *testing.T) {
+	backend, dataBackend := newTestBackend(t)
+
+	file1 := common.NewFile()
+	file1.UploadID = "upload1"
+	err := backend.AddFile(file1, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	file2 := common.NewFile()
+	file2.UploadID = "upload2"
+	err = backend.AddFile(file2, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	file3 := common.NewFile()
+	file3.UploadID = "upload3"
+	err = backend.AddFile(file3, bytes.NewBufferString("other data"))
+	require.NoError(t, err, "unable to add file")
+
+	require.Len(t, dataBackend.GetFiles(), 2, "invalid blob count")
+
+	require.Equal(t, "data", readFile(t, backend, file1), "invalid file content")
+	require.Equal(t, "data", readFile(t, backend, file2), "invalid file content")
+	require.Equal(t, "other data", readFile(t, backend, file3), "invalid file content")
+
+	blob, err := backend.metadata.GetFileBlob(file1.ID)
+	require.NoError(t, err, "unable to get file blob")
+	require.NotNil(t, blob, "missing file blob")
+	require.Equal(t, "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7", blob.ID, "invalid blob id")
+	require.Equal(t, int64(4), blob.Size, "invalid blob size")
+	require.Equal(t, 2, blob.RefCount, "invalid blob reference count")
+}
+
+func TestRemoveFileDedup(t *testing.T) {
+	backend, dataBackend := newTestBackend(t)
+
+	file1 := common.NewFile()
+	file1.UploadID = "upload1"
+	err := backend.AddFile(file1, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	file2 := common.NewFile()
+	file2.UploadID = "upload2"
+	err = backend.AddFile(file2, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	err = backend.RemoveFile(file1)
+	require.NoError(t, err, "unable to remove file")
+	require.Len(t, dataBackend.GetFiles(), 1, "blob should not have been removed")
+	require.Equal(t, "data", readFile(t, backend, file2), "invalid file content")
+
+	err = backend.RemoveFile(file2)
+	require.NoError(t, err, "unable to remove file")
+	require.Len(t, dataBackend.GetFiles(), 0, "blob should have been removed")
+
+	// Removing twice must not fail
+	err = backend.RemoveFile(file2)
+	require.NoError(t, err, "unable to remove file")
+}
+
+func TestGetFileRangeDedup(t *testing.T) {
+	backend, _ := newTestBackend(t)
+
+	file := common.NewFile()
+	file.UploadID = "upload1"
+	err := backend.AddFile(file, bytes.NewBufferString("data data data"))
+	require.NoError(t, err, "unable to add file")
+
+	reader, err := backend.GetFileRange(file, 5, 4)
+	require.NoError(t, err, "unable to get file range")
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(content), "invalid file content")
+}
+
+func TestNotDedupFile(t *testing.T) {
+	backend, dataBackend := newTestBackend(t)
+
+	// File stored before the deduplication was enabled
+	file := common.NewFile()
+	file.UploadID = "upload1"
+	err := dataBackend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	require.Equal(t, "data", readFile(t, backend, file), "invalid file content")
+
+	err = backend.RemoveFile(file)
+	require.NoError(t, err, "unable to remove file")
+	require.Len(t, dataBackend.GetFiles(), 0, "file should have been removed")
+}
+
+func TestAddFileDataBackendError(t *testing.T) {
+	backend, dataBackend := newTestBackend(t)
+	dataBackend.SetError(io.ErrUnexpectedEOF)
+
+	file := common.NewFile()
+	file.UploadID = "upload1"
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.Error(t, err, "missing error")
+
+	blob, err := backend.metadata.GetFileBlob(file.ID)
+	require.NoError(t, err, "unable to get file blob")
+	require.Nil(t, blob, "blob should not have been referenced")
+}
+
+// moveErrorBackend is a data backend failing to move files
+type moveErrorBackend struct {
+	*data_test.Backend
+}
+
+func (b *moveErrorBackend) MoveFile(from *common.File, to *common.File) (err error) {
+	return io.ErrUnexpectedEOF
+}
+
+func TestAddFileMoveError(t *testing.T) {
+	backend, dataBackend := newTestBackend(t)
+	backend.backend = &moveErrorBackend{Backend: dataBackend}
+
+	file := common.NewFile()
+	file.UploadID = "upload1"
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	common.RequireError(t, err, "unable to move staging file to blob")
+
+	blob, err := backend.metadata.GetBlob("3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7")
+	require.NoError(t, err, "unable to get blob")
+	require.Nil(t, blob, "blob must not be created without its content")
+	require.Len(t, dataBackend.GetFiles(), 0, "staging file should have been removed")
+}
+
+func TestRemoveFileBlobCreatedAgain(t *testing.T) {
+	backend, dataBackend := newTestBackend(t)
+
+	file1 := common.NewFile()
+	file1.UploadID = "upload1"
+	err := backend.AddFile(file1, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	// Remove the last reference but add the same content again
+	// before the blob content is deleted from the data backend
+	blob, deleted, err := backend.metadata.RemoveBlobReference(file1.ID)
+	require.NoError(t, err, "unable to remove blob reference")
+	require.True(t, deleted, "blob should have been deleted")
+
+	file2 := common.NewFile()
+	file2.UploadID = "upload2"
+	err = backend.AddFile(file2, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	err = dataBackend.RemoveFile(GetBlobFile(blob))
+	require.NoError(t, err, "unable to remove blob")
+
+	require.Equal(t, "data", readFile(t, backend, file2), "invalid file content")
+}
@@ -0,0 +1,162 @@
+package fallback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+)
+
+// Fallback layer for data backends :
+//   - New files are always added to the primary data backend
+//   - Files are read from the primary data backend first and from the fallback data backend
+//     if they are not found, this allows migrating the files to another data backend without downtime
+//   - Files are removed from both data backends
+//   - Files are listed from both data backends
+
+// Ensure Fallback Data Backend implements data.Backend, data.RangeBackend, data.MoveBackend and data.ListBackend interfaces
+var _ data.Backend = (*Backend)(nil)
+var _ data.RangeBackend = (*Backend)(nil)
+var _ data.MoveBackend = (*Backend)(nil)
+var _ data.ListBackend = (*Backend)(nil)
+
+// Backend object
+type Backend struct {
+	backend  data.Backend
+	fallback data.Backend
+}
+
+// NewBackend instantiate a new Fallback Data Backend
+// reading the files not found in backend from fallback
+func NewBackend(backend data.Backend, fallback data.Backend) (b *Backend) {
+	b = new(Backend)
+	b.backend = backend
+	b.fallback = fallback
+	return b
+}
+
+// GetBackend return the primary data backend
+func (b *Backend) GetBackend() data.Backend {
+	return b.backend
+}
+
+// GetFallback return the fallback data backend
+func (b *Backend) GetFallback() data.Backend {
+	return b.fallback
+}
+
+// GetFile implementation for Fallback Data Backend
+func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
+	return b.getFile(func(backend data.Backend) (io.ReadCloser, error) {
+		return backend.GetFile(file)
+	})
+}
+
+// GetFileRange implementation for Fallback Data Backend
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	return b.getFile(func(backend data.Backend) (io.ReadCloser, error) {
+		return data.GetFileRange(backend, file, offset, length)
+	})
+}
+
+func (b *Backend) getFile(open func(backend data.Backend) (io.ReadCloser, error)) (reader io.ReadCloser, err error) {
+	reader, err = open(b.backend)
+	if err != nil {
+		if errors.Is(err, data.ErrFileNotFound) {
+			return open(b.fallback)
+		}
+		return nil, err
+	}
+
+	// Some data backends only report missing files on the first read
+	return &fallbackReader{reader: reader, fallback: func() (io.ReadCloser, error) { return open(b.fallback) }}, nil
+}
+
+// AddFile implementation for Fallback Data Backend
+func (b *Backend) AddFile(file *common.File, reader io.Reader) (err error) {
+	return b.backend.AddFile(file, reader)
+}
+
+// MoveFile implementation for Fallback Data Backend
+// The files being moved have always been added to the primary data backend
+func (b *Backend) MoveFile(from *common.File, to *common.File) (err error) {
+	return data.MoveFile(b.backend, from, to)
+}
+
+// RemoveFile implementation for Fallback Data Backend
+func (b *Backend) RemoveFile(file *common.File) (err error) {
+	err = b.backend.RemoveFile(file)
+	if err != nil {
+		return err
+	}
+
+	return b.fallback.RemoveFile(file)
+}
+
+// ForEachFile implementation for Fallback Data Backend
+// Files stored in both data backends ( being migrated ) are only listed once
+func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
+	seen := make(map[string]bool)
+	for i, backend := range []data.Backend{b.backend, b.fallback} {
+		listBackend, ok := backend.(data.ListBackend)
+		if !ok {
+			return fmt.Errorf("data backend does not support listing files")
+		}
+
+		primary := i == 0
+		err = listBackend.ForEachFile(func(file *common.File) error {
+			if primary {
+				seen[file.ID] = true
+			} else if seen[file.ID] {
+				return nil
+			}
+			return f(file)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fallbackReader switch to the fallback data backend if the first read reports a missing file
+type fallbackReader struct {
+	reader   io.ReadCloser
+	fallback func() (io.ReadCloser, error)
+	err      error
+}
+
+func (r *fallbackReader) Read(p []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err = r.reader.Read(p)
+	if r.fallback == nil {
+		return n, err
+	}
+
+	open := r.fallback
+	r.fallback = nil
+
+	if n == 0 && errors.Is(err, data.ErrFileNotFound) {
+		_ = r.reader.Close()
+		r.reader, r.err = open()
+		if r.err != nil {
+			return 0, r.err
+		}
+		return r.reader.Read(p)
+	}
+
+	return n, err
+}
+
+func (r *fallbackReader) Close() error {
+	if r.err != nil {
+		return nil
+	}
+	return r.reader.Close()
+}
@@ -0,0 +1,148 @@
+package fallback
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+func newTestBackend() (backend *Backend, primary *data_test.Backend, fallback *data_test.Backend) {
+	primary = data_test.NewBackend()
+	fallback = data_test.NewBackend()
+	return NewBackend(primary, fallback), primary, fallback
+}
+
+func readFile(t *testing.T, reader io.ReadCloser, err error) string {
+	require.NoError(t, err, "unable to get file")
+	defer func() { _ = reader.Close() }()
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	return string(content)
+}
+
+func TestGetFile(t *testing.T) {
+	backend, primary, fallback := newTestBackend()
+
+	file1 := common.NewFile()
+	err := primary.AddFile(file1, bytes.NewBufferString("primary"))
+	require.NoError(t, err, "unable to add file")
+
+	file2 := common.NewFile()
+	err = fallback.AddFile(file2, bytes.NewBufferString("fallback"))
+	require.NoError(t, err, "unable to add file")
+
+	reader, err := backend.GetFile(file1)
+	require.Equal(t, "primary", readFile(t, reader, err), "invalid file content")
+
+	reader, err = backend.GetFile(file2)
+	require.Equal(t, "fallback", readFile(t, reader, err), "invalid file content")
+
+	reader, err = backend.GetFileRange(file2, 4, 2)
+	require.Equal(t, "ba", readFile(t, reader, err), "invalid file content")
+
+	_, err = backend.GetFile(common.NewFile())
+	require.ErrorIs(t, err, data.ErrFileNotFound, "missing error")
+}
+
+func TestGetFilePrimaryError(t *testing.T) {
+	backend, primary, fallback := newTestBackend()
+
+	file := common.NewFile()
+	err := fallback.AddFile(file, bytes.NewBufferString("fallback"))
+	require.NoError(t, err, "unable to add file")
+
+	primary.SetError(errors.New("primary error"))
+	_, err = backend.GetFile(file)
+	common.RequireError(t, err, "primary error")
+}
+
+// lazyBackend only reports missing files on the first read
+type lazyBackend struct {
+	*data_test.Backend
+}
+
+func (b *lazyBackend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
+	reader, err = b.Backend.GetFile(file)
+	if errors.Is(err, data.ErrFileNotFound) {
+		pipeReader, pipeWriter := io.Pipe()
+		_ = pipeWriter.CloseWithError(err)
+		return pipeReader, nil
+	}
+	return reader, err
+}
+
+func TestGetFileLazyNotFound(t *testing.T) {
+	primary := &lazyBackend{data_test.NewBackend()}
+	fallback := data_test.NewBackend()
+	backend := NewBackend(primary, fallback)
+
+	file := common.NewFile()
+	err := fallback.AddFile(file, bytes.NewBufferString("fallback"))
+	require.NoError(t, err, "unable to add file")
+
+	reader, err := backend.GetFile(file)
+	require.Equal(t, "fallback", readFile(t, reader, err), "invalid file content")
+
+	reader, err = backend.GetFile(common.NewFile())
+	require.NoError(t, err, "unable to get file")
+	_, err = io.ReadAll(reader)
+	require.ErrorIs(t, err, data.ErrFileNotFound, "missing error")
+	require.NoError(t, reader.Close(), "unable to close reader")
+}
+
+func TestAddFile(t *testing.T) {
+	backend, primary, fallback := newTestBackend()
+
+	file := common.NewFile()
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	require.Len(t, primary.GetFiles(), 1, "invalid primary file count")
+	require.Len(t, fallback.GetFiles(), 0, "invalid fallback file count")
+}
+
+func TestRemoveFile(t *testing.T) {
+	backend, primary, fallback := newTestBackend()
+
+	file := common.NewFile()
+	err := primary.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+	err = fallback.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	err = backend.RemoveFile(file)
+	require.NoError(t, err, "unable to remove file")
+
+	require.Len(t, primary.GetFiles(), 0, "invalid primary file count")
+	require.Len(t, fallback.GetFiles(), 0, "invalid fallback file count")
+}
+
+func TestForEachFile(t *testing.T) {
+	backend, primary, fallback := newTestBackend()
+
+	file1 := common.NewFile()
+	require.NoError(t, primary.AddFile(file1, bytes.NewBufferString("primary")), "unable to add file")
+	file2 := common.NewFile()
+	require.NoError(t, fallback.AddFile(file2, bytes.NewBufferString("fallback")), "unable to add file")
+
+	// File already copied to the primary data backend
+	file3 := common.NewFile()
+	require.NoError(t, primary.AddFile(file3, bytes.NewBufferString("migrated")), "unable to add file")
+	require.NoError(t, fallback.AddFile(file3, bytes.NewBufferString("migrated")), "unable to add file")
+
+	ids := make(map[string]int)
+	err := backend.ForEachFile(func(file *common.File) error {
+		ids[file.ID]++
+		return nil
+	})
+	require.NoError(t, err, "unable to list files")
+	require.Equal(t, map[string]int{file1.ID: 1, file2.ID: 1, file3.ID: 1}, ids, "invalid files")
+}
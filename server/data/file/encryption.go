@@ -0,0 +1,404 @@
+package file
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// Envelope encryption :
+//   - Each file is encrypted with a random 256 bits data key
+//   - The data key is wrapped ( AES-GCM ) by the master key and stored in the file backend details
+//   - Rotating the master key only requires to re-wrap the data keys ( see RewrapDataKey )
+//
+// Encrypted file format :
+//   - A random nonce prefix
+//   - The content split in chunks of encryptionChunkSize bytes, each sealed with AES-GCM
+//     using the nonce : prefix | chunk counter | last chunk flag
+//     so chunks can't be reordered or truncated and any chunk can be decrypted independently
+
+const encryptionChunkSize = 64 * 1024
+const noncePrefixSize = 7
+
+// BackendDetails for the file data backend
+type BackendDetails struct {
+	DataKey     string // Data encryption key wrapped by the master key ( base64 )
+	MasterKeyID string // ID of the master key used to wrap the data key
+}
+
+// ParseMasterKey decode a base64 encoded 256 bits master key
+func ParseMasterKey(str string) (key []byte, err error) {
+	key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(str))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode encryption key : %s", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid encryption key size %d, expected 32 bytes", len(key))
+	}
+	return key, nil
+}
+
+// GetMasterKey return the master key from the configuration ( nil if encryption is disabled )
+func (config *Config) GetMasterKey() (key []byte, err error) {
+	if config.EncryptionKey != "" && config.EncryptionKeyFile != "" {
+		return nil, fmt.Errorf("EncryptionKey and EncryptionKeyFile are mutually exclusive")
+	}
+
+	if config.EncryptionKeyFile != "" {
+		content, err := os.ReadFile(config.EncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read encryption key file : %s", err)
+		}
+		return ParseMasterKey(string(content))
+	}
+
+	if config.EncryptionKey != "" {
+		return ParseMasterKey(config.EncryptionKey)
+	}
+
+	return nil, nil
+}
+
+func getMasterKeyID(masterKey []byte) string {
+	hash := sha256.Sum256(masterKey)
+	return hex.EncodeToString(hash[:8])
+}
+
+func newGCM(key []byte) (aead cipher.AEAD, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func wrapKey(masterKey []byte, key []byte) (wrapped string, err error) {
+	aead, err := newGCM(masterKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, key, nil)), nil
+}
+
+func unwrapKey(masterKey []byte, wrapped string) (key []byte, err error) {
+	aead, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid wrapped key")
+	}
+
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+}
+
+func getBackendDetails(file *common.File) (backendDetails *BackendDetails, err error) {
+	backendDetails = &BackendDetails{}
+	if file.BackendDetails != "" {
+		err = json.Unmarshal([]byte(file.BackendDetails), backendDetails)
+		if err != nil {
+			return nil, fmt.Errorf("unable to deserialize backend details : %s", err)
+		}
+	}
+	return backendDetails, nil
+}
+
+func setBackendDetails(file *common.File, backendDetails *BackendDetails) (err error) {
+	backendDetailsJSON, err := json.Marshal(backendDetails)
+	if err != nil {
+		return fmt.Errorf("unable to serialize backend details : %s", err)
+	}
+
+	file.BackendDetails = string(backendDetailsJSON)
+	return nil
+}
+
+// Get the data key from the file backend details ( nil if the file is not encrypted )
+func (b *Backend) getDataKey(file *common.File) (key []byte, err error) {
+	backendDetails, err := getBackendDetails(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if backendDetails.DataKey == "" {
+		return nil, nil
+	}
+
+	if b.masterKey == nil {
+		return nil, fmt.Errorf("file is encrypted but no encryption key is configured")
+	}
+
+	if backendDetails.MasterKeyID != getMasterKeyID(b.masterKey) {
+		return nil, fmt.Errorf("file data key has been wrapped by another master key (%s)", backendDetails.MasterKeyID)
+	}
+
+	key, err = unwrapKey(b.masterKey, backendDetails.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unwrap data key : %s", err)
+	}
+
+	return key, nil
+}
+
+// Get the data key from the file backend details or generate one and store it in the file backend details
+func (b *Backend) getOrCreateDataKey(file *common.File) (key []byte, err error) {
+	key, err = b.getDataKey(file)
+	if err != nil || key != nil {
+		return key, err
+	}
+
+	key = make([]byte, 32)
+	_, err = io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return nil, err
+	}
+
+	backendDetails, err := getBackendDetails(file)
+	if err != nil {
+		return nil, err
+	}
+
+	backendDetails.DataKey, err = wrapKey(b.masterKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to wrap data key : %s", err)
+	}
+	backendDetails.MasterKeyID = getMasterKeyID(b.masterKey)
+
+	err = setBackendDetails(file, backendDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// RewrapDataKey wrap the file data key with the current master key instead of oldMasterKey.
+// Only the file backend details are updated, the file content is left untouched.
+// Files that are not encrypted or already use the current master key are ignored ( changed is false ).
+func (b *Backend) RewrapDataKey(file *common.File, oldMasterKey []byte) (changed bool, err error) {
+	if b.masterKey == nil {
+		return false, fmt.Errorf("no encryption key is configured")
+	}
+
+	backendDetails, err := getBackendDetails(file)
+	if err != nil {
+		return false, err
+	}
+
+	if backendDetails.DataKey == "" || backendDetails.MasterKeyID == getMasterKeyID(b.masterKey) {
+		return false, nil
+	}
+
+	if backendDetails.MasterKeyID != getMasterKeyID(oldMasterKey) {
+		return false, fmt.Errorf("file data key has been wrapped by an unknown master key (%s)", backendDetails.MasterKeyID)
+	}
+
+	key, err := unwrapKey(oldMasterKey, backendDetails.DataKey)
+	if err != nil {
+		return false, fmt.Errorf("unable to unwrap data key : %s", err)
+	}
+
+	backendDetails.DataKey, err = wrapKey(b.masterKey, key)
+	if err != nil {
+		return false, fmt.Errorf("unable to wrap data key : %s", err)
+	}
+	backendDetails.MasterKeyID = getMasterKeyID(b.masterKey)
+
+	err = setBackendDetails(file, backendDetails)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func getNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, noncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	if last {
+		nonce[noncePrefixSize+4] = 1
+	}
+	return nonce
+}
+
+// encryptWriter encrypts the content written to it, Close must be called to seal the last chunk
+type encryptWriter struct {
+	writer  io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buffer  []byte
+}
+
+func newEncryptWriter(writer io.Writer, key []byte) (w *encryptWriter, err error) {
+	w = &encryptWriter{writer: writer}
+
+	w.aead, err = newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	w.prefix = make([]byte, noncePrefixSize)
+	_, err = io.ReadFull(rand.Reader, w.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = writer.Write(w.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	w.buffer = make([]byte, 0, encryptionChunkSize)
+	return w, nil
+}
+
+func (w *encryptWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		// Only seal a full chunk once more data is available
+		// as the last chunk has to be flagged as such
+		if len(w.buffer) == encryptionChunkSize {
+			err = w.seal(false)
+			if err != nil {
+				return n, err
+			}
+		}
+
+		c := copy(w.buffer[len(w.buffer):encryptionChunkSize], p)
+		w.buffer = w.buffer[:len(w.buffer)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+func (w *encryptWriter) seal(last bool) (err error) {
+	_, err = w.writer.Write(w.aead.Seal(nil, getNonce(w.prefix, w.counter, last), w.buffer, nil))
+	if err != nil {
+		return err
+	}
+	w.counter++
+	w.buffer = w.buffer[:0]
+	return nil
+}
+
+func (w *encryptWriter) Close() (err error) {
+	return w.seal(true)
+}
+
+// decryptReader decrypts an encrypted file starting at the given chunk
+type decryptReader struct {
+	reader  *bufio.Reader
+	closer  io.Closer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	chunk   []byte
+	buffer  []byte
+	last    bool
+}
+
+// newDecryptReader return a reader over the decrypted content of fh starting at offset
+func newDecryptReader(fh *os.File, key []byte, offset int64) (r *decryptReader, err error) {
+	r = &decryptReader{closer: fh}
+
+	r.aead, err = newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	r.prefix = make([]byte, noncePrefixSize)
+	_, err = io.ReadFull(fh, r.prefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read encryption header : %s", err)
+	}
+
+	// Seek to the chunk containing the offset
+	chunk := offset / encryptionChunkSize
+	_, err = fh.Seek(noncePrefixSize+chunk*int64(encryptionChunkSize+r.aead.Overhead()), io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	r.counter = uint32(chunk)
+
+	r.reader = bufio.NewReaderSize(fh, encryptionChunkSize+r.aead.Overhead())
+	r.chunk = make([]byte, encryptionChunkSize+r.aead.Overhead())
+
+	// Discard the beginning of the chunk
+	skip := offset - chunk*encryptionChunkSize
+	if skip > 0 {
+		_, err = io.CopyN(io.Discard, r, skip)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func (r *decryptReader) Read(p []byte) (n int, err error) {
+	for len(r.buffer) == 0 {
+		if r.last {
+			return 0, io.EOF
+		}
+
+		err = r.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n = copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
+
+func (r *decryptReader) open() (err error) {
+	size, err := io.ReadFull(r.reader, r.chunk)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		r.last = true
+	} else if err != nil {
+		return err
+	} else if _, err = r.reader.Peek(1); err == io.EOF {
+		r.last = true
+	} else if err != nil {
+		return err
+	}
+
+	r.buffer, err = r.aead.Open(r.chunk[:0], getNonce(r.prefix, r.counter, r.last), r.chunk[:size], nil)
+	if err != nil {
+		return fmt.Errorf("unable to decrypt chunk %d : %s", r.counter, err)
+	}
+	r.counter++
+
+	return nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.closer.Close()
+}
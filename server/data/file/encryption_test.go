@@ -0,0 +1,245 @@
+package file
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func newMasterKey(t *testing.T) string {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err, "unable to generate master key")
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newEncryptedBackend(t *testing.T) (backend *Backend, cleanup func()) {
+	backend, cleanup = newBackend(t)
+	backend.Config.EncryptionKey = newMasterKey(t)
+
+	var err error
+	backend.masterKey, err = backend.Config.GetMasterKey()
+	require.NoError(t, err, "unable to get master key")
+
+	return backend, cleanup
+}
+
+func newTestContent(t *testing.T, size int) []byte {
+	content := make([]byte, size)
+	_, err := rand.Read(content)
+	require.NoError(t, err, "unable to generate content")
+	return content
+}
+
+func TestGetMasterKey(t *testing.T) {
+	config := &Config{}
+	key, err := config.GetMasterKey()
+	require.NoError(t, err, "unable to get master key")
+	require.Nil(t, key, "encryption should be disabled")
+
+	config.EncryptionKey = newMasterKey(t)
+	key, err = config.GetMasterKey()
+	require.NoError(t, err, "unable to get master key")
+	require.Len(t, key, 32, "invalid master key")
+
+	fh, err := os.CreateTemp("", "pliktest_key")
+	require.NoError(t, err, "unable to create key file")
+	defer os.Remove(fh.Name())
+	_, err = fh.WriteString(config.EncryptionKey + "\n")
+	require.NoError(t, err, "unable to write key file")
+	require.NoError(t, fh.Close(), "unable to close key file")
+
+	config.EncryptionKeyFile = fh.Name()
+	_, err = config.GetMasterKey()
+	require.Error(t, err, "missing error with both key and key file")
+
+	config.EncryptionKey = ""
+	key2, err := config.GetMasterKey()
+	require.NoError(t, err, "unable to get master key")
+	require.Equal(t, key, key2, "invalid master key")
+
+	_, err = (&Config{EncryptionKey: "short"}).GetMasterKey()
+	require.Error(t, err, "missing error with invalid key")
+}
+
+func TestEncryptedFile(t *testing.T) {
+	backend, clean := newEncryptedBackend(t)
+	defer clean()
+
+	for _, size := range []int{0, 1, encryptionChunkSize - 1, encryptionChunkSize, encryptionChunkSize + 1, 3*encryptionChunkSize + 42} {
+		upload := &common.Upload{}
+		file := upload.NewFile()
+		upload.InitializeForTests()
+
+		content := newTestContent(t, size)
+		err := backend.AddFile(file, bytes.NewReader(content))
+		require.NoError(t, err, "unable to add file")
+		require.NotEmpty(t, file.BackendDetails, "missing backend details")
+
+		_, path, err := backend.getPathCompat(file)
+		require.NoError(t, err, "unable to get file path")
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err, "unable to read file")
+		// Short contents may appear in the ciphertext by chance
+		if size > 16 {
+			require.NotContains(t, string(raw), string(content), "file is not encrypted")
+		}
+
+		reader, err := backend.GetFile(file)
+		require.NoError(t, err, "unable to get file")
+		read, err := io.ReadAll(reader)
+		require.NoError(t, err, "unable to read file")
+		require.NoError(t, reader.Close(), "unable to close file")
+		require.Equal(t, content, read, "invalid file content for size %d", size)
+	}
+}
+
+func TestEncryptedFileRange(t *testing.T) {
+	backend, clean := newEncryptedBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	content := newTestContent(t, 3*encryptionChunkSize+42)
+	err := backend.AddFile(file, bytes.NewReader(content))
+	require.NoError(t, err, "unable to add file")
+
+	ranges := [][2]int64{{0, 10}, {42, -1}, {encryptionChunkSize - 5, 10}, {2 * encryptionChunkSize, encryptionChunkSize}, {int64(len(content)) - 1, -1}}
+	for _, r := range ranges {
+		reader, err := backend.GetFileRange(file, r[0], r[1])
+		require.NoError(t, err, "unable to get file range")
+		read, err := io.ReadAll(reader)
+		require.NoError(t, err, "unable to read file")
+		require.NoError(t, reader.Close(), "unable to close file")
+
+		end := int64(len(content))
+		if r[1] >= 0 {
+			end = r[0] + r[1]
+		}
+		require.Equal(t, content[r[0]:end], read, "invalid file content for range %v", r)
+	}
+}
+
+func TestEncryptedFileTampered(t *testing.T) {
+	backend, clean := newEncryptedBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	content := newTestContent(t, 2*encryptionChunkSize)
+	err := backend.AddFile(file, bytes.NewReader(content))
+	require.NoError(t, err, "unable to add file")
+
+	_, path, err := backend.getPathCompat(file)
+	require.NoError(t, err, "unable to get file path")
+
+	// Truncate the last chunk
+	info, err := os.Stat(path)
+	require.NoError(t, err, "unable to stat file")
+	err = os.Truncate(path, info.Size()-encryptionChunkSize-16)
+	require.NoError(t, err, "unable to truncate file")
+
+	reader, err := backend.GetFile(file)
+	require.NoError(t, err, "unable to get file")
+	_, err = io.ReadAll(reader)
+	require.Error(t, err, "missing error with truncated file")
+}
+
+func TestEncryptedBackendPlainFile(t *testing.T) {
+	plainBackend, clean := newBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	err := plainBackend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	// Files uploaded before enabling encryption are still readable
+	backend, err := NewBackend(&Config{Directory: plainBackend.Config.Directory, EncryptionKey: newMasterKey(t)})
+	require.NoError(t, err, "unable to create backend")
+
+	reader, err := backend.GetFile(file)
+	require.NoError(t, err, "unable to get file")
+	read, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(read), "invalid file content")
+
+	// Encrypted files are not readable without the key
+	err = backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	_, err = plainBackend.GetFile(file)
+	require.Error(t, err, "missing error without encryption key")
+}
+
+func TestRewrapDataKey(t *testing.T) {
+	backend, clean := newEncryptedBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	oldKey := backend.masterKey
+	newBackend, err := NewBackend(&Config{Directory: backend.Config.Directory, EncryptionKey: newMasterKey(t)})
+	require.NoError(t, err, "unable to create backend")
+
+	_, err = newBackend.GetFile(file)
+	require.Error(t, err, "missing error with another master key")
+
+	_, err = newBackend.RewrapDataKey(file, newBackend.masterKey)
+	require.Error(t, err, "missing error with invalid old master key")
+
+	changed, err := newBackend.RewrapDataKey(file, oldKey)
+	require.NoError(t, err, "unable to rewrap data key")
+	require.True(t, changed, "data key should have been rewrapped")
+
+	changed, err = newBackend.RewrapDataKey(file, oldKey)
+	require.NoError(t, err, "unable to rewrap data key")
+	require.False(t, changed, "data key should not have been rewrapped")
+
+	reader, err := newBackend.GetFile(file)
+	require.NoError(t, err, "unable to get file")
+	read, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(read), "invalid file content")
+}
+
+func TestMoveEncryptedFile(t *testing.T) {
+	backend, clean := newEncryptedBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	to := &common.File{ID: "moved_" + file.ID, UploadID: file.UploadID}
+	err = backend.MoveFile(file, to)
+	require.NoError(t, err, "unable to move file")
+	require.Equal(t, file.BackendDetails, to.BackendDetails, "invalid backend details")
+
+	reader, err := backend.GetFile(to)
+	require.NoError(t, err, "unable to get file")
+	read, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(read), "invalid file content")
+}
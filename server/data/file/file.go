@@ -3,20 +3,27 @@ package file
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/data"
 	"github.com/root-gg/utils"
 )
 
-// Ensure File Data Backend implements data.Backend interface
+// Ensure File Data Backend implements data.Backend, data.RangeBackend, data.MoveBackend and data.ListBackend interfaces
 var _ data.Backend = (*Backend)(nil)
+var _ data.RangeBackend = (*Backend)(nil)
+var _ data.MoveBackend = (*Backend)(nil)
+var _ data.ListBackend = (*Backend)(nil)
 
 // Config describes configuration for File Databackend
 type Config struct {
-	Directory string
+	Directory         string
+	EncryptionKey     string // Base64 encoded 256 bits master key to enable at-rest encryption
+	EncryptionKeyFile string // Path of a file containing the base64 encoded master key
 }
 
 // NewConfig instantiate a new default configuration
@@ -31,14 +38,22 @@ func NewConfig(params map[string]interface{}) (config *Config) {
 // Backend object
 type Backend struct {
 	Config *Config
+
+	masterKey []byte
 }
 
 // NewBackend instantiate a new File Data Backend
 // from configuration passed as argument
-func NewBackend(config *Config) (b *Backend) {
+func NewBackend(config *Config) (b *Backend, err error) {
 	b = new(Backend)
 	b.Config = config
-	return
+
+	b.masterKey, err = config.GetMasterKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
 }
 
 // GetFile implementation for file data backend will search
@@ -49,16 +64,67 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 		return nil, err
 	}
 
+	key, err := b.getDataKey(file)
+	if err != nil {
+		return nil, err
+	}
+
 	// The file content will be piped directly
 	// to the client response body
-	reader, err = os.Open(path)
+	fh, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open file %s : %s", path, err)
 	}
 
+	if key == nil {
+		return fh, nil
+	}
+
+	reader, err = newDecryptReader(fh, key, 0)
+	if err != nil {
+		_ = fh.Close()
+		return nil, fmt.Errorf("unable to decrypt file %s : %s", path, err)
+	}
+
 	return reader, nil
 }
 
+// GetFileRange implementation for file data backend will seek
+// to the given offset of the file and return its reading filehandle
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	_, path, err := b.getPathCompat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := b.getDataKey(file)
+	if err != nil {
+		return nil, err
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file %s : %s", path, err)
+	}
+
+	if key != nil {
+		reader, err = newDecryptReader(fh, key, offset)
+		if err != nil {
+			_ = fh.Close()
+			return nil, fmt.Errorf("unable to decrypt file %s : %s", path, err)
+		}
+		return data.LimitReadCloser(reader, length), nil
+	}
+
+	_, err = fh.Seek(offset, io.SeekStart)
+	if err != nil {
+		_ = fh.Close()
+		return nil, fmt.Errorf("unable to seek file %s : %s", path, err)
+	}
+
+	return data.LimitReadCloser(fh, length), nil
+}
+
 // AddFile implementation for file data backend will creates a new file for the given upload
 // and save it on filesystem with the given file reader
 func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
@@ -67,6 +133,14 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 		return err
 	}
 
+	var key []byte
+	if b.masterKey != nil {
+		key, err = b.getOrCreateDataKey(file)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Create directory
 	err = os.MkdirAll(dir, 0777)
 	if err != nil {
@@ -80,13 +154,30 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 	}
 	defer out.Close()
 
+	var writer io.Writer = out
+	var encrypter *encryptWriter
+	if key != nil {
+		encrypter, err = newEncryptWriter(out, key)
+		if err != nil {
+			return fmt.Errorf("unable to encrypt file %s : %s", path, err)
+		}
+		writer = encrypter
+	}
+
 	// Copy file data from the client request body
 	// to the file system
-	_, err = io.Copy(out, fileReader)
+	_, err = io.Copy(writer, fileReader)
 	if err != nil {
 		return fmt.Errorf("unable to save file %s : %s", path, err)
 	}
 
+	if encrypter != nil {
+		err = encrypter.Close()
+		if err != nil {
+			return fmt.Errorf("unable to save file %s : %s", path, err)
+		}
+	}
+
 	return nil
 }
 
@@ -113,6 +204,84 @@ func (b *Backend) RemoveFile(file *common.File) (err error) {
 	return nil
 }
 
+// MoveFile implementation for file data backend will rename
+// the file on the filesystem
+func (b *Backend) MoveFile(from *common.File, to *common.File) (err error) {
+	_, fromPath, err := b.getPathCompat(from)
+	if err != nil {
+		return err
+	}
+
+	dir, toPath, err := b.getPath(to)
+	if err != nil {
+		return err
+	}
+
+	// Create directory
+	err = os.MkdirAll(dir, 0777)
+	if err != nil {
+		return fmt.Errorf("unable to create directory")
+	}
+
+	err = os.Rename(fromPath, toPath)
+	if err != nil {
+		return fmt.Errorf("unable to move %s to %s : %s", fromPath, toPath, err)
+	}
+
+	// The content is left untouched so the encryption keys are still valid
+	to.BackendDetails = from.BackendDetails
+
+	return nil
+}
+
+// ForEachFile implementation for file data backend will walk
+// the data directory, the upload ID is only known for the <1.3 layout
+func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
+	err = filepath.WalkDir(b.Config.Directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == b.Config.Directory {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(b.Config.Directory, path)
+		if err != nil {
+			return err
+		}
+
+		// <dir>/<2 first chars of the file id>/<file id>
+		// <dir>/<2 first chars of the upload id>/<upload id>/<file id> ( <1.3 layout )
+		file := &common.File{}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		switch len(parts) {
+		case 2:
+			file.ID = parts[1]
+		case 3:
+			file.UploadID = parts[1]
+			file.ID = parts[2]
+		default:
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		file.Size = info.Size()
+		file.CreatedAt = info.ModTime()
+
+		return f(file)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list files : %w", err)
+	}
+	return nil
+}
+
 func (b *Backend) getPath(file *common.File) (dir string, path string, err error) {
 	// To avoid too many files in the same directory
 	// data directory is split in two levels the
@@ -124,20 +293,27 @@ func (b *Backend) getPath(file *common.File) (dir string, path string, err error
 		return "", "", fmt.Errorf("file not initialized")
 	}
 
-	dir = fmt.Sprintf("%s/%s", b.Config.Directory, file.ID[:2])
-	path = fmt.Sprintf("%s/%s", dir, file.ID)
-
+	dir, path = b.getFilePath(file.ID)
 	return dir, path, nil
 }
 
-var errNoSuchFileOrDirectory = fmt.Errorf("no such file or directory")
+func (b *Backend) getFilePath(fileID string) (dir string, path string) {
+	dir = fmt.Sprintf("%s/%s", b.Config.Directory, fileID[:2])
+	path = fmt.Sprintf("%s/%s", dir, fileID)
+	return dir, path
+}
+
+var errNoSuchFileOrDirectory = fmt.Errorf("no such file or directory : %w", data.ErrFileNotFound)
 
 func (b *Backend) getPathCompat(file *common.File) (dir string, path string, err error) {
-	dir, path, err = b.getPath(file)
-	if err != nil {
-		return "", "", err
+	// The upload ID is not needed to access files stored with the current layout
+	// so the files returned by ForEachFile can be removed
+	if file == nil || len(file.ID) < 3 {
+		return "", "", fmt.Errorf("file not initialized")
 	}
 
+	dir, path = b.getFilePath(file.ID)
+
 	// Check file
 
 	info, err := os.Stat(path)
@@ -150,6 +326,10 @@ func (b *Backend) getPathCompat(file *common.File) (dir string, path string, err
 
 	// For compatibility with <1.3 implementations
 
+	if len(file.UploadID) < 3 {
+		return "", "", errNoSuchFileOrDirectory
+	}
+
 	dir = fmt.Sprintf("%s/%s/%s", b.Config.Directory, file.UploadID[:2], file.UploadID)
 	path = fmt.Sprintf("%s/%s", dir, file.ID)
 
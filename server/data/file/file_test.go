@@ -17,7 +17,8 @@ func newBackend(t *testing.T) (backend *Backend, cleanup func()) {
 	dir, err := os.MkdirTemp("", "pliktest")
 	require.NoError(t, err, "unable to create temp directory")
 
-	backend = NewBackend(&Config{Directory: dir})
+	backend, err = NewBackend(&Config{Directory: dir})
+	require.NoError(t, err, "unable to create file backend")
 	cleanup = func() {
 		err := os.RemoveAll(dir)
 		if err != nil {
@@ -145,6 +146,33 @@ func TestGetFile(t *testing.T) {
 	require.Equal(t, "data", string(read), "inavlid file content")
 }
 
+func TestGetFileRange(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	reader := bytes.NewBufferString("data data data")
+	err := backend.AddFile(file, reader)
+	require.NoError(t, err, "unable to add file")
+
+	fileReader, err := backend.GetFileRange(file, 5, 4)
+	require.NoError(t, err, "unable to get file range")
+
+	read, err := io.ReadAll(fileReader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(read), "invalid file content")
+
+	fileReader, err = backend.GetFileRange(file, 10, -1)
+	require.NoError(t, err, "unable to get file range")
+
+	read, err = io.ReadAll(fileReader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(read), "invalid file content")
+}
+
 func TestGetFileCompathPath(t *testing.T) {
 	backend, clean := newBackend(t)
 	defer clean()
@@ -311,3 +339,55 @@ func TestRemoveFileCompatPath(t *testing.T) {
 	_, err = os.Open(path)
 	require.Error(t, err, "able to open removed file")
 }
+
+func TestForEachFile(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	legacy := upload.NewFile()
+	upload.InitializeForTests()
+
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	dir := fmt.Sprintf("%s/%s/%s", backend.Config.Directory, legacy.UploadID[:2], legacy.UploadID)
+	err = os.MkdirAll(dir, 0777)
+	require.NoError(t, err, "error creating directories")
+	err = os.WriteFile(fmt.Sprintf("%s/%s", dir, legacy.ID), []byte("legacy"), 0644)
+	require.NoError(t, err, "error writing file")
+
+	files := make(map[string]*common.File)
+	err = backend.ForEachFile(func(f *common.File) error {
+		files[f.ID] = f
+		return nil
+	})
+	require.NoError(t, err, "unable to list files")
+	require.Len(t, files, 2, "invalid file count")
+
+	require.Equal(t, "", files[file.ID].UploadID, "invalid upload id")
+	require.Equal(t, int64(4), files[file.ID].Size, "invalid file size")
+	require.False(t, files[file.ID].CreatedAt.IsZero(), "missing file date")
+	require.Equal(t, legacy.UploadID, files[legacy.ID].UploadID, "invalid upload id")
+	require.Equal(t, int64(6), files[legacy.ID].Size, "invalid file size")
+
+	// Listed files can be removed
+	for _, f := range files {
+		err = backend.RemoveFile(f)
+		require.NoError(t, err, "unable to remove file")
+	}
+
+	err = backend.ForEachFile(func(f *common.File) error {
+		return fmt.Errorf("unexpected file %s", f.ID)
+	})
+	require.NoError(t, err, "unable to list files")
+}
+
+func TestForEachFileMissingDirectory(t *testing.T) {
+	backend, err := NewBackend(&Config{Directory: "/non/existing/directory"})
+	require.NoError(t, err, "unable to create file backend")
+
+	err = backend.ForEachFile(func(f *common.File) error { return nil })
+	require.NoError(t, err, "unable to list files")
+}
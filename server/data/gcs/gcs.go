@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 
 	"cloud.google.com/go/storage"
 	"github.com/root-gg/utils"
+	"google.golang.org/api/iterator"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/data"
@@ -14,6 +16,9 @@ import (
 
 // Ensure File Data Backend implements data.Backend interface
 var _ data.Backend = (*Backend)(nil)
+var _ data.RangeBackend = (*Backend)(nil)
+var _ data.MoveBackend = (*Backend)(nil)
+var _ data.ListBackend = (*Backend)(nil)
 
 // Config describes configuration for Google Cloud Storage data backend
 type Config struct {
@@ -57,6 +62,23 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 
 	// Get the object
 	reader, err = b.client.Bucket(b.Config.Bucket).Object(objectName).NewReader(context.Background())
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return nil, fmt.Errorf("Unable to get GCS object %s : %w", objectName, data.ErrFileNotFound)
+		}
+		return nil, fmt.Errorf("Unable to get GCS object %s : %s", objectName, err)
+	}
+
+	return reader, nil
+}
+
+// GetFileRange implementation for Google Cloud Storage Data Backend
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	// Get object name
+	objectName := b.getObjectName(file.UploadID, file.ID)
+
+	// Get the object, a negative length reads until the end
+	reader, err = b.client.Bucket(b.Config.Bucket).Object(objectName).NewRangeReader(context.Background(), offset, length)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to get GCS object %s : %s", objectName, err)
 	}
@@ -81,6 +103,20 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 	return nil
 }
 
+// MoveFile implementation for Google Cloud Storage Data Backend
+func (b *Backend) MoveFile(from *common.File, to *common.File) (err error) {
+	bucket := b.client.Bucket(b.Config.Bucket)
+	src := bucket.Object(b.getObjectName(from.UploadID, from.ID))
+	dst := bucket.Object(b.getObjectName(to.UploadID, to.ID))
+
+	_, err = dst.CopierFrom(src).Run(context.Background())
+	if err != nil {
+		return fmt.Errorf("Unable to copy GCS object %s to %s : %s", src.ObjectName(), dst.ObjectName(), err)
+	}
+
+	return b.RemoveFile(from)
+}
+
 // RemoveFile implementation for Google Cloud Storage Data Backend
 func (b *Backend) RemoveFile(file *common.File) (err error) {
 	// Get object name
@@ -100,6 +136,42 @@ func (b *Backend) RemoveFile(file *common.File) (err error) {
 	return nil
 }
 
+// ForEachFile implementation for Google Cloud Storage Data Backend
+func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
+	query := &storage.Query{}
+	if b.Config.Folder != "" {
+		query.Prefix = b.Config.Folder + "/"
+	}
+
+	it := b.client.Bucket(b.Config.Bucket).Objects(context.Background(), query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to list GCS objects : %s", err)
+		}
+
+		// <folder>/<upload id>.<file id>
+		uploadID, fileID, ok := strings.Cut(strings.TrimPrefix(attrs.Name, query.Prefix), ".")
+		if !ok {
+			continue
+		}
+
+		file := &common.File{}
+		file.ID = fileID
+		file.UploadID = uploadID
+		file.Size = attrs.Size
+		file.CreatedAt = attrs.Created
+
+		err = f(file)
+		if err != nil {
+			return err
+		}
+	}
+}
+
 func (b *Backend) getObjectName(uploadID string, fileID string) string {
 	if b.Config.Folder != "" {
 		return fmt.Sprintf("%s/%s.%s", b.Config.Folder, uploadID, fileID)
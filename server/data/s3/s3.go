@@ -1,9 +1,11 @@
 package s3
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -15,6 +17,9 @@ import (
 
 // Ensure Swift Data Backend implements data.Backend interface
 var _ data.Backend = (*Backend)(nil)
+var _ data.RangeBackend = (*Backend)(nil)
+var _ data.MoveBackend = (*Backend)(nil)
+var _ data.ListBackend = (*Backend)(nil)
 
 // Config describes configuration for Swift data backend
 type Config struct {
@@ -121,8 +126,62 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 		return nil, err
 	}
 
-	// This does only very basic checking and basically always return nil, error will happen when reading from the reader
-	return b.client.GetObject(context.TODO(), b.config.Bucket, b.getObjectName(file.ID), getOpts)
+	return b.getObject(file, getOpts)
+}
+
+// GetFileRange implementation for S3 Data Backend
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	if length == 0 {
+		return io.NopCloser(&bytes.Buffer{}), nil
+	}
+
+	getOpts := minio.GetObjectOptions{}
+
+	// Configure server side encryption
+	getOpts.ServerSideEncryption, err = b.getServerSideEncryption(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// SetRange(offset, 0) reads until the end of the object, SetRange(0, 0) reads the first byte only
+	if length > 0 {
+		err = getOpts.SetRange(offset, offset+length-1)
+	} else if offset > 0 {
+		err = getOpts.SetRange(offset, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return b.getObject(file, getOpts)
+}
+
+func (b *Backend) getObject(file *common.File, getOpts minio.GetObjectOptions) (reader io.ReadCloser, err error) {
+	object, err := b.client.GetObject(context.TODO(), b.config.Bucket, b.getObjectName(file.ID), getOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &objectReader{object: object, name: b.getObjectName(file.ID)}, nil
+}
+
+// objectReader report a missing object as data.ErrFileNotFound
+// The request is only sent on the first read so this is when a missing object is detected
+type objectReader struct {
+	object *minio.Object
+	name   string
+}
+
+func (r *objectReader) Read(p []byte) (n int, err error) {
+	n, err = r.object.Read(p)
+	if err != nil && minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		err = fmt.Errorf("unable to get s3 object %s : %w", r.name, data.ErrFileNotFound)
+	}
+	return n, err
+}
+
+func (r *objectReader) Close() error {
+	return r.object.Close()
 }
 
 // AddFile implementation for S3 Data Backend
@@ -165,6 +224,61 @@ func (b *Backend) RemoveFile(file *common.File) (err error) {
 	return nil
 }
 
+// MoveFile implementation for S3 Data Backend
+func (b *Backend) MoveFile(from *common.File, to *common.File) (err error) {
+	// The destination is encrypted using the same key than the source
+	to.BackendDetails = from.BackendDetails
+
+	src := minio.CopySrcOptions{Bucket: b.config.Bucket, Object: b.getObjectName(from.ID)}
+	src.Encryption, err = b.getServerSideEncryption(from)
+	if err != nil {
+		return err
+	}
+
+	dst := minio.CopyDestOptions{Bucket: b.config.Bucket, Object: b.getObjectName(to.ID)}
+	dst.Encryption, err = b.getServerSideEncryption(to)
+	if err != nil {
+		return err
+	}
+
+	// ComposeObject is able to copy objects larger than 5GiB using multipart uploads
+	_, err = b.client.ComposeObject(context.TODO(), dst, src)
+	if err != nil {
+		return fmt.Errorf("Unable to copy s3 object %s to %s : %s", src.Object, dst.Object, err)
+	}
+
+	return b.RemoveFile(from)
+}
+
+// ForEachFile implementation for S3 Data Backend
+func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
+	opts := minio.ListObjectsOptions{Recursive: true}
+	if b.config.Prefix != "" {
+		opts.Prefix = b.config.Prefix + "/"
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	for object := range b.client.ListObjects(ctx, b.config.Bucket, opts) {
+		if object.Err != nil {
+			return fmt.Errorf("unable to list s3 objects : %s", object.Err)
+		}
+
+		file := &common.File{}
+		file.ID = strings.TrimPrefix(object.Key, opts.Prefix)
+		file.Size = object.Size
+		file.CreatedAt = object.LastModified
+
+		err = f(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (b *Backend) getObjectName(name string) string {
 	if b.config.Prefix != "" {
 		return fmt.Sprintf("%s/%s", b.config.Prefix, name)
@@ -0,0 +1,97 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+const content = "0123456789"
+
+// newTestBackend return a backend connected to a fake S3 server serving content for every object
+// and the Range headers of the object requests it received
+func newTestBackend(t *testing.T) (backend *Backend, ranges func() []string) {
+	var lock sync.Mutex
+	var received []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Query().Has("location"):
+			_, _ = resp.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`))
+		case strings.Count(strings.Trim(req.URL.Path, "/"), "/") == 0:
+			// Bucket requests
+			resp.WriteHeader(http.StatusOK)
+		default:
+			lock.Lock()
+			received = append(received, req.Header.Get("Range"))
+			lock.Unlock()
+
+			resp.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			resp.Header().Set("ETag", `"etag"`)
+
+			var start, end int
+			_, err := fmt.Sscanf(req.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+			if err != nil {
+				resp.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				_, _ = resp.Write([]byte(content))
+				return
+			}
+
+			resp.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+			resp.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+			resp.WriteHeader(http.StatusPartialContent)
+			_, _ = resp.Write([]byte(content[start : end+1]))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	config := NewConfig(map[string]interface{}{
+		"Endpoint":        strings.TrimPrefix(server.URL, "http://"),
+		"AccessKeyID":     "access",
+		"SecretAccessKey": "secret",
+	})
+
+	backend, err := NewBackend(config)
+	require.NoError(t, err, "unable to create backend")
+
+	return backend, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, received...)
+	}
+}
+
+func TestGetFileRangeFirstByte(t *testing.T) {
+	backend, ranges := newTestBackend(t)
+
+	reader, err := backend.GetFileRange(&common.File{ID: "file"}, 0, 1)
+	require.NoError(t, err, "unable to get file range")
+	defer reader.Close()
+
+	result, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file range")
+	require.Equal(t, "0", string(result), "invalid file range")
+	require.Equal(t, []string{"bytes=0-0"}, ranges(), "invalid range header")
+}
+
+func TestGetFileRange(t *testing.T) {
+	backend, ranges := newTestBackend(t)
+
+	reader, err := backend.GetFileRange(&common.File{ID: "file"}, 2, 3)
+	require.NoError(t, err, "unable to get file range")
+	defer reader.Close()
+
+	result, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file range")
+	require.Equal(t, "234", string(result), "invalid file range")
+	require.Equal(t, []string{"bytes=2-4"}, ranges(), "invalid range header")
+}
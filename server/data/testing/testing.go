@@ -10,8 +10,9 @@ import (
 	"github.com/root-gg/plik/server/data"
 )
 
-// Ensure Testing Data Backend implements data.Backend interface
+// Ensure Testing Data Backend implements data.Backend and data.ListBackend interfaces
 var _ data.Backend = (*Backend)(nil)
+var _ data.ListBackend = (*Backend)(nil)
 
 // Backend object
 type Backend struct {
@@ -47,12 +48,15 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 		return io.NopCloser(bytes.NewBuffer(content)), nil
 	}
 
-	return nil, errors.New("file not found")
+	return nil, data.ErrFileNotFound
 }
 
 // AddFile implementation for testing data backend will creates a new file for the given upload
 // and save it on filesystem with the given file reader
 func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
+	// Read outside the lock as the reader might itself read from this backend ( resumable uploads chunks )
+	content, err := io.ReadAll(fileReader)
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -64,7 +68,6 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 		return errors.New("file exists")
 	}
 
-	content, err := io.ReadAll(fileReader)
 	if err != nil {
 		return err
 	}
@@ -89,6 +92,30 @@ func (b *Backend) RemoveFile(file *common.File) (err error) {
 	return nil
 }
 
+// ForEachFile implementation for testing data backend
+func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
+	// Copy the files to release the lock as f might remove them
+	b.mu.Lock()
+	if b.err != nil {
+		b.mu.Unlock()
+		return b.err
+	}
+	var files []*common.File
+	for id, content := range b.files {
+		files = append(files, &common.File{ID: id, Size: int64(len(content))})
+	}
+	b.mu.Unlock()
+
+	for _, file := range files {
+		err = f(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // SetError set the error that this backend will return on any subsequent method call
 func (b *Backend) SetError(err error) {
 	b.err = err
@@ -116,3 +116,28 @@ func TestRemoveFile(t *testing.T) {
 	require.Error(t, err, "unable to get file")
 	require.Equal(t, "file not found", err.Error(), "invalid error message")
 }
+
+func TestForEachFile(t *testing.T) {
+	backend := NewBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	var files []*common.File
+	err = backend.ForEachFile(func(f *common.File) error {
+		files = append(files, f)
+		return backend.RemoveFile(f)
+	})
+	require.NoError(t, err, "unable to list files")
+	require.Len(t, files, 1, "invalid file count")
+	require.Equal(t, file.ID, files[0].ID, "invalid file id")
+	require.Equal(t, int64(4), files[0].Size, "invalid file size")
+	require.Len(t, backend.GetFiles(), 0, "file should have been removed")
+
+	backend.SetError(errors.New("error"))
+	err = backend.ForEachFile(func(f *common.File) error { return nil })
+	require.Error(t, err, "missing error")
+}
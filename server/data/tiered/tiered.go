@@ -0,0 +1,236 @@
+package tiered
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+)
+
+// Tiered storage layer for data backends :
+//   - New files are added to the hot data backend
+//   - Files can be demoted to the cold data backend, the file is copied then the
+//     hot copy is removed once the new backend details have been saved
+//   - The tier of each file is recorded in its backend details along with
+//     the backend details of the underlying data backend
+//
+// Files stored before the tiered storage was enabled have no tier and live in the hot data backend.
+
+// Storage tiers
+const (
+	Hot  = "hot"
+	Cold = "cold"
+)
+
+// Ensure Tiered Data Backend implements data.Backend, data.RangeBackend, data.MoveBackend and data.ListBackend interfaces
+var _ data.Backend = (*Backend)(nil)
+var _ data.RangeBackend = (*Backend)(nil)
+var _ data.MoveBackend = (*Backend)(nil)
+var _ data.ListBackend = (*Backend)(nil)
+
+// BackendDetails for the tiered data backend
+type BackendDetails struct {
+	Tier    string `json:"tier"`
+	Details string `json:"details,omitempty"`
+}
+
+// Backend object
+type Backend struct {
+	hot  data.Backend
+	cold data.Backend
+}
+
+// NewBackend instantiate a new Tiered Data Backend
+// from the hot and cold data backends passed as argument
+func NewBackend(hot data.Backend, cold data.Backend) (b *Backend) {
+	b = new(Backend)
+	b.hot = hot
+	b.cold = cold
+	return b
+}
+
+// GetTier return the storage tier of a file
+func GetTier(file *common.File) string {
+	return getBackendDetails(file).Tier
+}
+
+// GetFile implementation for Tiered Data Backend
+func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
+	backend, f := b.resolve(file)
+	return backend.GetFile(f)
+}
+
+// GetFileRange implementation for Tiered Data Backend
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	backend, f := b.resolve(file)
+	return data.GetFileRange(backend, f, offset, length)
+}
+
+// AddFile implementation for Tiered Data Backend
+func (b *Backend) AddFile(file *common.File, reader io.Reader) (err error) {
+	f := getTierFile(file, "")
+	err = b.hot.AddFile(f, reader)
+	if err != nil {
+		return err
+	}
+	return setBackendDetails(file, Hot, f.BackendDetails)
+}
+
+// MoveFile implementation for Tiered Data Backend
+// The files being moved have always been added to the hot data backend
+func (b *Backend) MoveFile(from *common.File, to *common.File) (err error) {
+	_, f := b.resolve(from)
+	t := getTierFile(to, "")
+	err = data.MoveFile(b.hot, f, t)
+	if err != nil {
+		return err
+	}
+
+	return setBackendDetails(to, Hot, t.BackendDetails)
+}
+
+// RemoveFile implementation for Tiered Data Backend
+// The file is removed from both tiers as a demotion might have been interrupted
+func (b *Backend) RemoveFile(file *common.File) (err error) {
+	details := getBackendDetails(file)
+
+	err = b.hot.RemoveFile(getTierFile(file, details.Details))
+	if err != nil {
+		return err
+	}
+
+	return b.cold.RemoveFile(getTierFile(file, details.Details))
+}
+
+// ForEachFile implementation for Tiered Data Backend
+func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
+	for _, backend := range []data.Backend{b.hot, b.cold} {
+		listBackend, ok := backend.(data.ListBackend)
+		if !ok {
+			return fmt.Errorf("data backend does not support listing files")
+		}
+
+		err = listBackend.ForEachFile(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Demote copy a hot file to the cold data backend and return its new backend details
+// The hot copy must be removed with RemoveHotFile once the new backend details have been saved
+func (b *Backend) Demote(file *common.File) (backendDetails string, err error) {
+	details := getBackendDetails(file)
+	if details.Tier == Cold {
+		return "", fmt.Errorf("file is already in the cold data backend")
+	}
+
+	reader, err := b.hot.GetFile(getTierFile(file, details.Details))
+	if err != nil {
+		return "", fmt.Errorf("unable to get file from hot data backend : %s", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	cold := getTierFile(file, "")
+	err = b.cold.RemoveFile(cold)
+	if err != nil {
+		return "", fmt.Errorf("unable to remove file from cold data backend : %s", err)
+	}
+
+	counter := &byteCounter{}
+	err = b.cold.AddFile(cold, io.TeeReader(reader, counter))
+	if err == nil && counter.size != file.Size {
+		err = fmt.Errorf("size mismatch : expected %d, got %d", file.Size, counter.size)
+	}
+	if err != nil {
+		_ = b.cold.RemoveFile(cold)
+		return "", fmt.Errorf("unable to copy file to cold data backend : %s", err)
+	}
+
+	f := &common.File{}
+	err = setBackendDetails(f, Cold, cold.BackendDetails)
+	if err != nil {
+		return "", err
+	}
+
+	return f.BackendDetails, nil
+}
+
+// RemoveHotFile remove the hot copy of a demoted file
+// file must have the backend details it had before the demotion
+func (b *Backend) RemoveHotFile(file *common.File) (err error) {
+	details := getBackendDetails(file)
+	return b.hot.RemoveFile(getTierFile(file, details.Details))
+}
+
+// UpdateHotBackendDetails update the backend details of a file stored in the hot data backend.
+// update is given a file holding the hot data backend details only ( eg. file.Backend.RewrapDataKey )
+// and the updated details are wrapped again in the tiered backend details.
+// Files stored in the cold data backend are ignored ( changed is false )
+func UpdateHotBackendDetails(file *common.File, update func(file *common.File) (changed bool, err error)) (changed bool, err error) {
+	details := getBackendDetails(file)
+	if details.Tier != Hot {
+		return false, nil
+	}
+
+	f := getTierFile(file, details.Details)
+	changed, err = update(f)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	err = setBackendDetails(file, Hot, f.BackendDetails)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// resolve return the data backend a file lives in and the file to pass to this data backend
+func (b *Backend) resolve(file *common.File) (backend data.Backend, f *common.File) {
+	details := getBackendDetails(file)
+	if details.Tier == Cold {
+		return b.cold, getTierFile(file, details.Details)
+	}
+	return b.hot, getTierFile(file, details.Details)
+}
+
+// getTierFile return a copy of the file with the backend details of the underlying data backend
+func getTierFile(file *common.File, backendDetails string) *common.File {
+	f := *file
+	f.BackendDetails = backendDetails
+	return &f
+}
+
+func getBackendDetails(file *common.File) (details *BackendDetails) {
+	details = &BackendDetails{}
+	err := json.Unmarshal([]byte(file.BackendDetails), details)
+	if err != nil || (details.Tier != Hot && details.Tier != Cold) {
+		// Files added before the tiered storage was enabled live in the hot data backend
+		return &BackendDetails{Tier: Hot, Details: file.BackendDetails}
+	}
+	return details
+}
+
+func setBackendDetails(file *common.File, tier string, backendDetails string) (err error) {
+	details, err := json.Marshal(&BackendDetails{Tier: tier, Details: backendDetails})
+	if err != nil {
+		return fmt.Errorf("unable to serialize backend details : %s", err)
+	}
+	file.BackendDetails = string(details)
+	return nil
+}
+
+type byteCounter struct {
+	size int64
+}
+
+func (c *byteCounter) Write(p []byte) (n int, err error) {
+	c.size += int64(len(p))
+	return len(p), nil
+}
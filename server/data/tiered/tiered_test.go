@@ -0,0 +1,203 @@
+package tiered
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	data_file "github.com/root-gg/plik/server/data/file"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+func newTestBackend() (backend *Backend, hot *data_test.Backend, cold *data_test.Backend) {
+	hot = data_test.NewBackend()
+	cold = data_test.NewBackend()
+	return NewBackend(hot, cold), hot, cold
+}
+
+func readFile(t *testing.T, backend *Backend, file *common.File) string {
+	reader, err := backend.GetFile(file)
+	require.NoError(t, err, "unable to get file")
+	defer func() { _ = reader.Close() }()
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	return string(content)
+}
+
+func TestAddFile(t *testing.T) {
+	backend, hot, cold := newTestBackend()
+
+	file := common.NewFile()
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+	require.Equal(t, Hot, GetTier(file), "invalid tier")
+
+	require.Len(t, hot.GetFiles(), 1, "invalid hot file count")
+	require.Len(t, cold.GetFiles(), 0, "invalid cold file count")
+	require.Equal(t, "data", readFile(t, backend, file), "invalid file content")
+}
+
+func TestGetFileWithoutTier(t *testing.T) {
+	backend, hot, _ := newTestBackend()
+
+	file := common.NewFile()
+	file.BackendDetails = `{"foo":"bar"}`
+	err := hot.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	require.Equal(t, Hot, GetTier(file), "invalid tier")
+	require.Equal(t, "data", readFile(t, backend, file), "invalid file content")
+	require.Equal(t, `{"foo":"bar"}`, getBackendDetails(file).Details, "invalid backend details")
+}
+
+func TestDemote(t *testing.T) {
+	backend, hot, cold := newTestBackend()
+
+	file := common.NewFile()
+	file.Size = 4
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	backendDetails, err := backend.Demote(file)
+	require.NoError(t, err, "unable to demote file")
+	require.Len(t, hot.GetFiles(), 1, "hot file should not be removed yet")
+	require.Len(t, cold.GetFiles(), 1, "invalid cold file count")
+
+	err = backend.RemoveHotFile(file)
+	require.NoError(t, err, "unable to remove hot file")
+	require.Len(t, hot.GetFiles(), 0, "invalid hot file count")
+
+	file.BackendDetails = backendDetails
+	require.Equal(t, Cold, GetTier(file), "invalid tier")
+	require.Equal(t, "data", readFile(t, backend, file), "invalid file content")
+
+	reader, err := backend.GetFileRange(file, 1, 2)
+	require.NoError(t, err, "unable to get file range")
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file range")
+	require.Equal(t, "at", string(content), "invalid file range content")
+
+	_, err = backend.Demote(file)
+	common.RequireError(t, err, "already in the cold data backend")
+
+	err = backend.RemoveFile(file)
+	require.NoError(t, err, "unable to remove file")
+	require.Len(t, cold.GetFiles(), 0, "invalid cold file count")
+}
+
+func TestDemoteSizeMismatch(t *testing.T) {
+	backend, _, cold := newTestBackend()
+
+	file := common.NewFile()
+	file.Size = 10
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	_, err = backend.Demote(file)
+	common.RequireError(t, err, "size mismatch")
+	require.Len(t, cold.GetFiles(), 0, "invalid cold file count")
+}
+
+func TestMoveFile(t *testing.T) {
+	backend, hot, _ := newTestBackend()
+
+	from := common.NewFile()
+	err := backend.AddFile(from, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	to := common.NewFile()
+	err = backend.MoveFile(from, to)
+	require.NoError(t, err, "unable to move file")
+	require.Len(t, hot.GetFiles(), 1, "invalid hot file count")
+	require.Equal(t, Hot, GetTier(to), "invalid tier")
+	require.Equal(t, "data", readFile(t, backend, to), "invalid file content")
+}
+
+func TestForEachFile(t *testing.T) {
+	backend, hot, cold := newTestBackend()
+
+	require.NoError(t, hot.AddFile(common.NewFile(), bytes.NewBufferString("hot")), "unable to add file")
+	require.NoError(t, cold.AddFile(common.NewFile(), bytes.NewBufferString("cold")), "unable to add file")
+
+	count := 0
+	err := backend.ForEachFile(func(file *common.File) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err, "unable to list files")
+	require.Equal(t, 2, count, "invalid file count")
+}
+
+func newMasterKey(t *testing.T) string {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err, "unable to generate master key")
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestUpdateHotBackendDetailsRekey(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pliktest")
+	require.NoError(t, err, "unable to create temp directory")
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	oldConfig := &data_file.Config{Directory: dir, EncryptionKey: newMasterKey(t)}
+	hot, err := data_file.NewBackend(oldConfig)
+	require.NoError(t, err, "unable to create file backend")
+	cold := data_test.NewBackend()
+	backend := NewBackend(hot, cold)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	demoted := upload.NewFile()
+	demoted.Size = 4
+	upload.InitializeForTests()
+
+	for _, f := range []*common.File{file, demoted} {
+		err = backend.AddFile(f, bytes.NewBufferString("data"))
+		require.NoError(t, err, "unable to add file")
+	}
+
+	demoted.BackendDetails, err = backend.Demote(demoted)
+	require.NoError(t, err, "unable to demote file")
+
+	// Rotate the master key
+	oldKey, err := oldConfig.GetMasterKey()
+	require.NoError(t, err, "unable to get master key")
+	newHot, err := data_file.NewBackend(&data_file.Config{Directory: dir, EncryptionKey: newMasterKey(t)})
+	require.NoError(t, err, "unable to create file backend")
+	newBackend := NewBackend(newHot, cold)
+
+	_, err = newBackend.GetFile(file)
+	require.Error(t, err, "missing error with another master key")
+
+	rewrap := func(f *common.File) (changed bool, err error) {
+		return newHot.RewrapDataKey(f, oldKey)
+	}
+
+	// The file backend details are wrapped in the tiered backend details
+	changed, err := rewrap(file)
+	require.NoError(t, err, "unable to rewrap data key")
+	require.False(t, changed, "tiered backend details can't be rewrapped directly")
+
+	changed, err = UpdateHotBackendDetails(file, rewrap)
+	require.NoError(t, err, "unable to rewrap data key")
+	require.True(t, changed, "data key should have been rewrapped")
+	require.Equal(t, Hot, GetTier(file), "invalid tier")
+	require.Equal(t, "data", readFile(t, newBackend, file), "invalid file content")
+
+	changed, err = UpdateHotBackendDetails(file, rewrap)
+	require.NoError(t, err, "unable to rewrap data key")
+	require.False(t, changed, "data key should not have been rewrapped")
+
+	changed, err = UpdateHotBackendDetails(demoted, rewrap)
+	require.NoError(t, err, "unable to rewrap data key")
+	require.False(t, changed, "cold files should be ignored")
+	require.Equal(t, "data", readFile(t, newBackend, demoted), "invalid file content")
+}
@@ -1,22 +1,22 @@
 package handlers
 
 import (
-	"crypto/md5"
 	"fmt"
-	"github.com/dustin/go-humanize"
 	"io"
 	"net/http"
+	"time"
 
+	"github.com/root-gg/plik/server/clamav"
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/processor"
 )
 
-type preprocessOutputReturn struct {
-	size     int64
-	md5sum   string
-	mimeType string
-	err      error
+type scanOutputReturn struct {
+	result  *clamav.Result
+	err     error
+	elapsed time.Duration
 }
 
 // AddFile add a file to an existing upload.
@@ -30,8 +30,9 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		panic("missing upload from context")
 	}
 
-	// Check authorization
-	if !upload.IsAdmin {
+	// Check authorization, upload request senders are only allowed to add new files
+	request := ctx.GetUploadRequest()
+	if !upload.IsAdmin && (request == nil || ctx.GetFile() != nil) {
 		ctx.Forbidden("you are not allowed to add file to this upload")
 		return
 	}
@@ -86,6 +87,24 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 			return
 		}
 
+		// Reserve a slot in the upload request before adding the file
+		if request != nil {
+			ok, err := ctx.GetMetadataBackend().ReserveUploadRequestFile(request)
+			if err != nil {
+				ctx.InternalServerError("unable to update upload request", err)
+				return
+			}
+			if !ok {
+				ctx.BadRequest("upload request is full")
+				return
+			}
+			defer func() {
+				if file == nil || file.Status != common.FileUploaded {
+					releaseUploadRequestFile(ctx, request)
+				}
+			}()
+		}
+
 		// Create a new file object
 		file, err = ctx.CreateFile(upload, &common.File{Name: fileName})
 		if err != nil {
@@ -99,6 +118,9 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 			ctx.InternalServerError("unable to create file", err)
 			return
 		}
+
+		// Save file in the request context
+		ctx.SetFile(file)
 	} else {
 		if file.Name != fileName {
 			ctx.BadRequest("invalid file name")
@@ -122,55 +144,149 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	// Pipe file data from the request body to a preprocessing goroutine
-	//  - Guess content type
-	//  - Compute/Limit upload size
-	//  - Compute md5sum
-	preprocessReader, preprocessWriter := io.Pipe()
-	preprocessOutputCh := make(chan preprocessOutputReturn)
-	go preprocessor(ctx, fileReader, preprocessWriter, preprocessOutputCh)
+	if !saveFile(ctx, upload, file, fileReader) {
+		return
+	}
 
-	// Save file in the data backend
-	var backend data.Backend
-	if upload.Stream {
-		backend = ctx.GetStreamBackend()
+	if request != nil {
+		err = ctx.GetMetadataBackend().UpdateUploadRequestUsage(request, file.Size)
+		if err != nil {
+			log.Warningf("unable to update upload request usage : %s", err)
+		}
+
+		ctx.EmitWebhookEvent(common.EventUploadRequestUsed, upload, file)
+		ctx.NotifyUploadRequestUsed(request, upload, file)
+	}
+
+	// Remove all private information (ip, data backend details, ...) before
+	// sending metadata back to the client
+	file.Sanitize()
+
+	// Upload request senders must not learn the upload id so they never get the file url
+	if ctx.IsQuick() && request == nil {
+		// Do our best to print the file url in the response.
+		var url string
+		if ctx.GetConfig().GetDownloadDomain() != nil {
+			url = ctx.GetConfig().GetDownloadDomain().String()
+		} else {
+			url = ctx.GetConfig().GetServerURL().String()
+		}
+
+		url += fmt.Sprintf("/file/%s/%s/%s", upload.ID, file.ID, file.Name)
+
+		_, _ = resp.Write([]byte(url + "\n"))
 	} else {
-		backend = ctx.GetDataBackend()
+		common.WriteJSONResponse(resp, file)
 	}
+}
+
+// releaseUploadRequestFile free the upload request slot reserved for a file that could not be added
+func releaseUploadRequestFile(ctx *context.Context, request *common.UploadRequest) {
+	err := ctx.GetMetadataBackend().ReleaseUploadRequestFile(request)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to release upload request file : %s", err)
+	}
+}
+
+// saveFile stores the file data in the data backend and updates the file metadata.
+// The file status must have been set to common.FileUploading by the caller.
+// On failure the error response is sent and false is returned.
+func saveFile(ctx *context.Context, upload *common.Upload, file *common.File, fileReader io.Reader) (ok bool) {
+	log := ctx.GetLogger()
 
+	var scanWriter *io.PipeWriter
 	cleanup := func() {
+		if scanWriter != nil {
+			_ = scanWriter.CloseWithError(fmt.Errorf("upload failed"))
+		}
 		err := purge(ctx, file)
 		if err != nil {
 			log.Warningf(err.Error())
 		}
 	}
 
+	// Instantiate the content inspection pipeline
+	//  - Compute/Limit upload size
+	//  - Compute md5sum and SHA-256 digest
+	//  - Guess content type
+	//  - Processors configured in plikd.cfg
+	pipeline, err := ctx.GetProcessors().New(file, processor.NewSizeLimit(ctx.GetMaxFileSize()), processor.NewMD5(), processor.NewSHA256(), processor.NewContentType())
+	if err != nil {
+		handleHTTPError(ctx, err)
+		cleanup()
+		return false
+	}
+	defer func() { _ = pipeline.Close() }()
+
+	// Copy file data to an antivirus scanning goroutine
+	// Stream uploads can't wait for the verdict as the data is sent to the downloader as it comes
+	var scanOutputCh chan scanOutputReturn
+	if scanner := ctx.GetScanner(); scanner != nil && !upload.Stream {
+		var scanReader *io.PipeReader
+		scanReader, scanWriter = io.Pipe()
+		scanOutputCh = make(chan scanOutputReturn, 1)
+		go scan(scanner, scanReader, scanOutputCh)
+		fileReader = io.TeeReader(fileReader, scanWriter)
+	}
+
+	// Pipe file data from the request body through the processing pipeline to the data backend
+	preprocessReader, preprocessWriter := io.Pipe()
+	preprocessOutputCh := make(chan error, 1)
+	go preprocessor(ctx, fileReader, pipeline, preprocessWriter, preprocessOutputCh)
+
+	// Save file in the data backend
+	var backend data.Backend
+	if upload.Stream {
+		backend = ctx.GetStreamBackend()
+	} else {
+		backend = ctx.GetDataBackend()
+	}
+
 	err = backend.AddFile(file, preprocessReader)
 	if err != nil {
+		// Wait for the preprocessor goroutine to stop using the pipeline
+		_ = preprocessReader.CloseWithError(err)
+		<-preprocessOutputCh
+
 		ctx.InternalServerError("unable to save file", err)
 		cleanup()
-		return
+		return false
 	}
 
 	// Get preprocessor goroutine output
-	preprocessOutput := <-preprocessOutputCh
-	if preprocessOutput.err != nil {
+	err = <-preprocessOutputCh
+	if err != nil {
 		// TODO : file status is left to common.FileUploading we should set it to some common.FileUploadError
 		// TODO : or we can set it back to common.FileMissing if we are sure data backends will handle that
-		handleHTTPError(ctx, preprocessOutput.err)
+		handleHTTPError(ctx, err)
 		cleanup()
-		return
+		return false
 	}
 
-	// Fill-in file information
-	file.Type = preprocessOutput.mimeType
-	file.Size = preprocessOutput.size
-	file.Md5 = preprocessOutput.md5sum
+	// Fill-in file information and check the processors verdicts
+	err = pipeline.Finish(file)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		cleanup()
+		return false
+	}
+
+	// Wait for the antivirus verdict, the file is not downloadable until then
+	var scanErr error
+	if scanWriter != nil {
+		_ = scanWriter.Close()
+		scanErr = checkScan(ctx, file, <-scanOutputCh)
+		if scanErr != nil && file.Status != common.FileQuarantined {
+			handleHTTPError(ctx, scanErr)
+			cleanup()
+			return false
+		}
+	}
 
 	// Update file status
 	if upload.Stream {
 		file.Status = common.FileDeleted
-	} else {
+	} else if file.Status != common.FileQuarantined {
 		file.Status = common.FileUploaded
 	}
 
@@ -179,7 +295,13 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 	if err != nil {
 		ctx.InternalServerError("unable to update file metadata", err)
 		cleanup()
-		return
+		return false
+	}
+
+	if scanErr != nil {
+		// Quarantined files are kept for inspection but the upload fails
+		handleHTTPError(ctx, scanErr)
+		return false
 	}
 
 	// Check user total uploaded size (user stats only takes uploaded files into account)
@@ -187,43 +309,69 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 	if err != nil {
 		ctx.BadRequest(err.Error())
 		cleanup()
-		return
+		return false
 	}
 
-	// Remove all private information (ip, data backend details, ...) before
-	// sending metadata back to the client
-	file.Sanitize()
+	ctx.EmitWebhookEvent(common.EventFileUploaded, upload, file)
 
-	if ctx.IsQuick() {
-		// Do our best to print the file url in the response.
-		var url string
-		if ctx.GetConfig().GetDownloadDomain() != nil {
-			url = ctx.GetConfig().GetDownloadDomain().String()
-		} else {
-			url = ctx.GetConfig().GetServerURL().String()
-		}
+	return true
+}
 
-		url += fmt.Sprintf("/file/%s/%s/%s", upload.ID, file.ID, file.Name)
+// scan stream the file data to clamd
+func scan(scanner *clamav.Scanner, reader *io.PipeReader, outputCh chan scanOutputReturn) {
+	start := time.Now()
+	result, err := scanner.Scan(reader)
 
-		_, _ = resp.Write([]byte(url + "\n"))
-	} else {
-		common.WriteJSONResponse(resp, file)
+	// Drain the remaining data so the upload is not blocked if the scan stopped early
+	_, _ = io.Copy(io.Discard, reader)
+
+	outputCh <- scanOutputReturn{result: result, err: err, elapsed: time.Since(start)}
+}
+
+// checkScan apply the configured policy to the antivirus verdict.
+// The file status is set to quarantined if needed and an error is returned if the upload must fail.
+func checkScan(ctx *context.Context, file *common.File, output scanOutputReturn) (err error) {
+	log := ctx.GetLogger()
+	config := ctx.GetConfig()
+
+	var verdict, policy, message string
+	var status int
+	switch {
+	case output.err != nil:
+		verdict = "error"
+		policy = config.ClamAVErrorPolicy
+		message = "unable to scan file"
+		status = http.StatusServiceUnavailable
+		log.Warningf("unable to scan file : %s", output.err)
+	case output.result.Infected:
+		verdict = "infected"
+		policy = config.ClamAVInfectedPolicy
+		message = fmt.Sprintf("file is infected : %s", output.result.Signature)
+		status = http.StatusBadRequest
+		log.Warningf("file is infected : %s", output.result.Signature)
+	default:
+		verdict = "clean"
+		policy = common.ScanPolicyAllow
+	}
+
+	ctx.GetMetrics().UpdateAntivirusMetrics(verdict, output.elapsed)
+
+	switch policy {
+	case common.ScanPolicyAllow:
+		return nil
+	case common.ScanPolicyQuarantine:
+		file.Status = common.FileQuarantined
+		return common.NewHTTPError(message+", the file has been quarantined", output.err, status)
+	default:
+		return common.NewHTTPError(message, output.err, status)
 	}
 }
 
-// - Guess content type
-// - Compute/Limit upload size
-// - Compute md5sum
-func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.WriteCloser, outputCh chan preprocessOutputReturn) {
+// preprocessor forward the file data to the processing pipeline and to the data backend
+func preprocessor(ctx *context.Context, file io.Reader, pipeline *processor.Pipeline, preprocessWriter io.WriteCloser, outputCh chan error) {
 	log := ctx.GetLogger()
-	maxFileSize := ctx.GetMaxFileSize()
 
 	var err error
-	var totalBytes int64
-	var mimeType string
-	var md5sum string
-
-	md5Hash := md5.New()
 	buf := make([]byte, 1048)
 
 	eof := false
@@ -241,24 +389,9 @@ func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.Writ
 			break
 		}
 
-		// Detect the content-type using the 512 first bytes
-		if totalBytes == 0 {
-			mimeType = http.DetectContentType(buf[:bytesRead])
-		}
-
-		// Increment size
-		totalBytes += int64(bytesRead)
-
-		// Check upload max size limit
-		if maxFileSize > 0 && totalBytes > maxFileSize {
-			err = common.NewHTTPError(fmt.Sprintf("file too big (limit is set to %s)", humanize.Bytes(uint64(maxFileSize))), nil, http.StatusBadRequest)
-			break
-		}
-
-		// Compute md5sum
-		_, err = md5Hash.Write(buf[:bytesRead])
+		// Size limit, md5sum, content type and configured processors
+		_, err = pipeline.Write(buf[:bytesRead])
 		if err != nil {
-			err = fmt.Errorf(err.Error())
 			break
 		}
 
@@ -279,12 +412,6 @@ func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.Writ
 		log.Warningf("unable to close preprocessWriter : %s", err)
 	}
 
-	if err != nil {
-		outputCh <- preprocessOutputReturn{err: err}
-	} else {
-		md5sum = fmt.Sprintf("%x", md5Hash.Sum(nil))
-		outputCh <- preprocessOutputReturn{size: totalBytes, md5sum: md5sum, mimeType: mimeType}
-	}
-
+	outputCh <- err
 	close(outputCh)
 }
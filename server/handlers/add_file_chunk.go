@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/data"
+)
+
+/*
+  Resumable upload protocol :
+    - Files must have been declared at upload creation to have a file ID
+    - HEAD  /chunk/{uploadID}/{fileID}/{filename} : return the current offset in the Upload-Offset header
+    - PATCH /chunk/{uploadID}/{fileID}/{filename} : append the request body at the offset given in the Upload-Offset header
+    - POST  /chunk/{uploadID}/{fileID}/{filename} : commit the received chunks, the file status is set to uploaded
+
+  Each chunk is persisted in the data backend so an interrupted upload can be resumed from the last
+  acknowledged offset. Chunks must be sent sequentially, a chunk with a wrong offset is rejected with
+  a 409 Conflict status and the client must query the current offset before resuming.
+
+  Each chunk is stored under a unique ID and only becomes part of the file once the offset compare-and-swap
+  recording its ID in the metadata succeeds, so concurrent requests at the same offset can't overwrite each other.
+*/
+
+// GetFileUploadOffset return the number of bytes already received for a resumable file upload
+func GetFileUploadOffset(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	_, file, ok := getChunkUploadAndFile(ctx)
+	if !ok {
+		return
+	}
+
+	if file.Status != common.FileMissing && file.Status != common.FileUploading {
+		ctx.BadRequest("invalid file status %s", file.Status)
+		return
+	}
+
+	resp.Header().Set("Upload-Offset", strconv.FormatInt(file.UploadOffset, 10))
+	resp.Header().Set("Cache-Control", "no-store")
+}
+
+// AddFileChunk append a chunk of data to a resumable file upload
+func AddFileChunk(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+
+	_, file, ok := getChunkUploadAndFile(ctx)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(req.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		ctx.InvalidParameter("Upload-Offset header")
+		return
+	}
+
+	switch file.Status {
+	case common.FileMissing:
+		if offset != 0 {
+			ctx.Fail(fmt.Sprintf("invalid upload offset %d, expected %d", offset, 0), nil, http.StatusConflict)
+			return
+		}
+
+		// Update file status
+		err = ctx.GetMetadataBackend().UpdateFileStatus(file, common.FileMissing, common.FileUploading)
+		if err != nil {
+			ctx.InternalServerError("unable to update file status", err)
+			return
+		}
+	case common.FileUploading:
+		if offset != file.UploadOffset {
+			ctx.Fail(fmt.Sprintf("invalid upload offset %d, expected %d", offset, file.UploadOffset), nil, http.StatusConflict)
+			return
+		}
+	default:
+		ctx.BadRequest("invalid file status %s", file.Status)
+		return
+	}
+
+	// Limit the chunk size to the remaining allowed file size
+	reader := &chunkReader{reader: req.Body, limit: -1}
+	maxFileSize := ctx.GetMaxFileSize()
+	if maxFileSize > 0 {
+		reader.limit = maxFileSize - offset
+	}
+
+	backend := ctx.GetDataBackend()
+	chunk := data.NewChunk(file)
+
+	err = backend.AddFile(chunk, reader)
+	if err != nil || reader.size == 0 {
+		if e := backend.RemoveFile(chunk); e != nil {
+			log.Warningf("unable to remove chunk %s : %s", chunk.ID, e)
+		}
+
+		if reader.overflow {
+			ctx.BadRequest("file too big (limit is set to %s)", humanize.Bytes(uint64(maxFileSize)))
+			return
+		}
+		if err != nil {
+			ctx.InternalServerError("unable to save file chunk", err)
+			return
+		}
+	} else {
+		// The data backend might have generated backend details ( encryption keys, ... ) for the first chunk
+		// they must be persisted to read back the chunks and shared by all the following chunks
+		file.BackendDetails = chunk.BackendDetails
+
+		err = ctx.GetMetadataBackend().UpdateFileUploadOffset(file, offset, offset+reader.size, append(data.GetChunkIDs(file), chunk.ID))
+		if err != nil {
+			// Another request stored a chunk at this offset concurrently, this chunk is not part of the file
+			if e := backend.RemoveFile(chunk); e != nil {
+				log.Warningf("unable to remove chunk %s : %s", chunk.ID, e)
+			}
+			ctx.Fail(fmt.Sprintf("unable to update upload offset : %s", err), nil, http.StatusConflict)
+			return
+		}
+	}
+
+	resp.Header().Set("Upload-Offset", strconv.FormatInt(file.UploadOffset, 10))
+
+	file.Sanitize()
+	common.WriteJSONResponse(resp, file)
+}
+
+// CommitFileChunks assemble all the chunks of a resumable file upload into the final file
+func CommitFileChunks(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+
+	upload, file, ok := getChunkUploadAndFile(ctx)
+	if !ok {
+		return
+	}
+
+	switch file.Status {
+	case common.FileMissing:
+		// Nothing has been sent, this is an empty file
+		err := ctx.GetMetadataBackend().UpdateFileStatus(file, common.FileMissing, common.FileUploading)
+		if err != nil {
+			ctx.InternalServerError("unable to update file status", err)
+			return
+		}
+	case common.FileUploading:
+	default:
+		ctx.BadRequest("invalid file status %s, expected %s", file.Status, common.FileUploading)
+		return
+	}
+
+	backend := ctx.GetDataBackend()
+
+	reader := data.GetChunksReader(backend, file)
+	defer func() { _ = reader.Close() }()
+
+	if !saveFile(ctx, upload, file, reader) {
+		return
+	}
+
+	// Chunks are not needed anymore
+	err := data.RemoveChunks(backend, file)
+	if err != nil {
+		log.Warningf("unable to remove file chunks : %s", err)
+	}
+
+	file.Sanitize()
+	common.WriteJSONResponse(resp, file)
+}
+
+func getChunkUploadAndFile(ctx *context.Context) (upload *common.Upload, file *common.File, ok bool) {
+	log := ctx.GetLogger()
+
+	// Get upload from context
+	upload = ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	// Get file from context
+	file = ctx.GetFile()
+	if file == nil {
+		panic("missing file from context")
+	}
+
+	// Check authorization
+	if !upload.IsAdmin {
+		ctx.Forbidden("you are not allowed to add file to this upload")
+		return nil, nil, false
+	}
+
+	if upload.Stream {
+		ctx.BadRequest("resumable uploads are not available for stream uploads")
+		return nil, nil, false
+	}
+
+	// Update request logger prefix
+	prefix := fmt.Sprintf("%s[%s]", log.Prefix, file.Name)
+	log.SetPrefix(prefix)
+
+	return upload, file, true
+}
+
+// chunkReader count the bytes read and fails if the limit is exceeded ( -1 for unlimited )
+type chunkReader struct {
+	reader   io.Reader
+	size     int64
+	limit    int64
+	overflow bool
+}
+
+func (r *chunkReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.size += int64(n)
+	if r.limit >= 0 && r.size > r.limit {
+		r.overflow = true
+		return n, fmt.Errorf("file too big")
+	}
+	return n, err
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/data"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+func getChunkRequest(t *testing.T, method string, upload *common.Upload, file *common.File, offset int64, reader io.Reader) (req *http.Request) {
+	req, err := http.NewRequest(method, "/chunk/"+upload.ID+"/"+file.ID+"/"+file.Name, reader)
+	require.NoError(t, err, "unable to create new request")
+
+	if offset >= 0 {
+		req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	}
+
+	return req
+}
+
+func addTestChunk(t *testing.T, ctx *context.Context, upload *common.Upload, file *common.File, offset int64, chunk string) (rr *httptest.ResponseRecorder) {
+	req := getChunkRequest(t, "PATCH", upload, file, offset, bytes.NewBufferString(chunk))
+	rr = ctx.NewRecorder(req)
+	AddFileChunk(ctx, rr, req)
+	return rr
+}
+
+func TestAddFileChunks(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestChunk(t, ctx, upload, file, 0, content[:5])
+	context.TestOK(t, rr)
+	require.Equal(t, "5", rr.Header().Get("Upload-Offset"), "invalid upload offset")
+	require.Equal(t, common.FileUploading, file.Status, "invalid file status")
+
+	rr = addTestChunk(t, ctx, upload, file, 5, content[5:])
+	context.TestOK(t, rr)
+	require.Equal(t, strconv.Itoa(len(content)), rr.Header().Get("Upload-Offset"), "invalid upload offset")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, int64(len(content)), f.UploadOffset, "invalid upload offset")
+	require.Equal(t, 2, f.UploadChunks, "invalid upload chunks")
+
+	req := getChunkRequest(t, "HEAD", upload, file, -1, nil)
+	rr = ctx.NewRecorder(req)
+	GetFileUploadOffset(ctx, rr, req)
+	context.TestOK(t, rr)
+	require.Equal(t, strconv.Itoa(len(content)), rr.Header().Get("Upload-Offset"), "invalid upload offset")
+
+	req = getChunkRequest(t, "POST", upload, file, -1, nil)
+	rr = ctx.NewRecorder(req)
+	CommitFileChunks(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var fileResult = &common.File{}
+	err = json.Unmarshal(respBody, fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.Equal(t, file.ID, fileResult.ID, "invalid file id")
+	require.Equal(t, common.FileUploaded, fileResult.Status, "invalid file status")
+	require.Equal(t, contentMD5, fileResult.Md5, "invalid file md5")
+	require.Equal(t, int64(len(content)), fileResult.Size, "invalid file size")
+
+	reader, err := ctx.GetDataBackend().GetFile(file)
+	require.NoError(t, err, "unable to get file")
+	result, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, content, string(result), "invalid file content")
+
+	_, err = ctx.GetDataBackend().GetFile(data.GetChunk(file, 0))
+	require.Error(t, err, "chunk should have been removed")
+}
+
+func TestAddFileChunkInvalidOffset(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestChunk(t, ctx, upload, file, 3, content)
+	context.TestFail(t, rr, http.StatusConflict, "invalid upload offset 3, expected 0")
+
+	rr = addTestChunk(t, ctx, upload, file, 0, content[:5])
+	context.TestOK(t, rr)
+
+	rr = addTestChunk(t, ctx, upload, file, 0, content[:5])
+	context.TestFail(t, rr, http.StatusConflict, "invalid upload offset 0, expected 5")
+}
+
+func TestAddFileChunkConcurrent(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestChunk(t, ctx, upload, file, 0, content[:5])
+	context.TestOK(t, rr)
+
+	// Two requests load the file at the same offset, the second one loses the compare-and-swap
+	first, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	second, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+
+	ctx.SetFile(first)
+	rr = addTestChunk(t, ctx, upload, first, 5, content[5:])
+	context.TestOK(t, rr)
+
+	ctx.SetFile(second)
+	rr = addTestChunk(t, ctx, upload, second, 5, strings.Repeat("x", len(content)-5))
+	context.TestFail(t, rr, http.StatusConflict, "unable to update upload offset : invalid file upload offset")
+
+	// The chunk of the first request has not been overwritten
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, 2, f.UploadChunks, "invalid upload chunks")
+
+	reader := data.GetChunksReader(ctx.GetDataBackend(), f)
+	defer func() { _ = reader.Close() }()
+	result, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read chunks")
+	require.Equal(t, content, string(result), "invalid chunks content")
+
+	// The chunk of the second request has been removed
+	_, err = ctx.GetDataBackend().GetFile(data.GetChunk(second, 1))
+	require.Error(t, err, "chunk should have been removed")
+}
+
+func TestAddFileChunkMissingOffset(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestChunk(t, ctx, upload, file, -1, content)
+	context.TestBadRequest(t, rr, "invalid Upload-Offset header")
+}
+
+func TestAddFileChunkStatusUploaded(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestChunk(t, ctx, upload, file, 0, content)
+	context.TestBadRequest(t, rr, "invalid file status uploaded")
+}
+
+func TestAddFileChunkNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: false}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestChunk(t, ctx, upload, file, 0, content)
+	context.TestForbidden(t, rr, "you are not allowed to add file to this upload")
+}
+
+func TestAddFileChunkStream(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	upload.Stream = true
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestChunk(t, ctx, upload, file, 0, content)
+	context.TestBadRequest(t, rr, "resumable uploads are not available for stream uploads")
+}
+
+func TestAddFileChunkTooBig(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().MaxFileSize = 10
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestChunk(t, ctx, upload, file, 0, content[:5])
+	context.TestOK(t, rr)
+
+	rr = addTestChunk(t, ctx, upload, file, 5, content[5:])
+	context.TestBadRequest(t, rr, "file too big")
+
+	require.Len(t, ctx.GetDataBackend().(*data_test.Backend).GetFiles(), 1, "invalid chunk count")
+}
+
+func TestCommitFileChunksEmpty(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	req := getChunkRequest(t, "POST", upload, file, -1, nil)
+	rr := ctx.NewRecorder(req)
+	CommitFileChunks(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, common.FileUploaded, file.Status, "invalid file status")
+	require.Equal(t, int64(0), file.Size, "invalid file size")
+}
+
+func TestCommitFileChunksStatusUploaded(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	req := getChunkRequest(t, "POST", upload, file, -1, nil)
+	rr := ctx.NewRecorder(req)
+	CommitFileChunks(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid file status uploaded, expected uploading")
+}
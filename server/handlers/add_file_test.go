@@ -1,19 +1,25 @@
 package handlers
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
 
+	"github.com/root-gg/plik/server/clamav"
+	clamd "github.com/root-gg/plik/server/clamav/testing"
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/processor"
 )
 
 var content = "data data data"
@@ -663,3 +669,198 @@ func TestAddFileMaxUserSizeOK(t *testing.T) {
 func TestAddFileMaxUserSizeKO(t *testing.T) {
 	testAddFileMaxUserSize(t, false)
 }
+
+func newTestingScanner(t *testing.T, ctx *context.Context) (server *clamd.Server) {
+	server, err := clamd.NewServer()
+	require.NoError(t, err, "unable to start clamd server")
+
+	scanner, err := clamav.NewScanner(server.Address(), time.Second)
+	require.NoError(t, err, "unable to create scanner")
+
+	ctx.SetScanner(scanner)
+	ctx.SetMetrics(common.NewPlikMetrics())
+
+	return server
+}
+
+func addTestFileWithScan(t *testing.T, ctx *context.Context, upload *common.Upload, data string) (file *common.File, rr *httptest.ResponseRecorder) {
+	file = upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBufferString(data))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr = ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+
+	return file, rr
+}
+
+func TestAddFileScanClean(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	server := newTestingScanner(t, ctx)
+	defer server.Close()
+
+	upload := &common.Upload{IsAdmin: true}
+	file, rr := addTestFileWithScan(t, ctx, upload, content)
+	context.TestOK(t, rr)
+
+	var fileResult = &common.File{}
+	err := json.Unmarshal(rr.Body.Bytes(), fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, common.FileUploaded, fileResult.Status, "invalid file status")
+	require.Equal(t, 1, server.Scans(), "invalid scan count")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+}
+
+func TestAddFileScanInfectedReject(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ClamAVInfectedPolicy = common.ScanPolicyReject
+	ctx := newTestingContext(config)
+	server := newTestingScanner(t, ctx)
+	defer server.Close()
+
+	upload := &common.Upload{IsAdmin: true}
+	file, rr := addTestFileWithScan(t, ctx, upload, clamd.EICAR)
+	context.TestBadRequest(t, rr, "file is infected : "+clamd.EICARSignature)
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileDeleted, f.Status, "invalid file status")
+
+	_, err = ctx.GetDataBackend().GetFile(file)
+	require.Error(t, err, "file data should have been removed")
+}
+
+func TestAddFileScanInfectedQuarantine(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ClamAVInfectedPolicy = common.ScanPolicyQuarantine
+	ctx := newTestingContext(config)
+	server := newTestingScanner(t, ctx)
+	defer server.Close()
+
+	upload := &common.Upload{IsAdmin: true}
+	file, rr := addTestFileWithScan(t, ctx, upload, clamd.EICAR)
+	context.TestBadRequest(t, rr, "file is infected : "+clamd.EICARSignature+", the file has been quarantined")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileQuarantined, f.Status, "invalid file status")
+
+	_, err = ctx.GetDataBackend().GetFile(file)
+	require.NoError(t, err, "file data should have been kept")
+}
+
+func TestAddFileScanErrorReject(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ClamAVErrorPolicy = common.ScanPolicyReject
+	ctx := newTestingContext(config)
+	server := newTestingScanner(t, ctx)
+	defer server.Close()
+	server.SetError("INSTREAM size limit exceeded")
+
+	upload := &common.Upload{IsAdmin: true}
+	file, rr := addTestFileWithScan(t, ctx, upload, content)
+	context.TestFail(t, rr, http.StatusServiceUnavailable, "unable to scan file")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileDeleted, f.Status, "invalid file status")
+}
+
+func TestAddFileScanErrorAllow(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ClamAVErrorPolicy = common.ScanPolicyAllow
+	ctx := newTestingContext(config)
+	server := newTestingScanner(t, ctx)
+	server.Close()
+
+	upload := &common.Upload{IsAdmin: true}
+	file, rr := addTestFileWithScan(t, ctx, upload, content)
+	context.TestOK(t, rr)
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+}
+
+func TestAddStreamFileNotScanned(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	server := newTestingScanner(t, ctx)
+	defer server.Close()
+
+	upload := &common.Upload{IsAdmin: true, Stream: true}
+	_, rr := addTestFileWithScan(t, ctx, upload, clamd.EICAR)
+	context.TestOK(t, rr)
+	require.Equal(t, 0, server.Scans(), "stream uploads should not be scanned")
+}
+
+func TestAddFileProcessorRejected(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	chain, err := processor.NewChain([]map[string]interface{}{{"Type": "filename", "Deny": []interface{}{`\.exe$`}}})
+	require.NoError(t, err, "unable to create processor chain")
+	ctx.SetProcessors(chain)
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "setup.exe"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBufferString(content))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestBadRequest(t, rr, "file name setup.exe is not allowed")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileDeleted, f.Status, "invalid file status")
+}
+
+func TestAddFileProcessorResults(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	chain, err := processor.NewChain([]map[string]interface{}{{"Type": "archive", "TempDirectory": t.TempDir()}})
+	require.NoError(t, err, "unable to create processor chain")
+	ctx.SetProcessors(chain)
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	_, err = zw.Create("file.txt")
+	require.NoError(t, err, "unable to create zip entry")
+	require.NoError(t, zw.Close(), "unable to close zip")
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file.zip"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, buf)
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var fileResult = &common.File{}
+	err = json.Unmarshal(rr.Body.Bytes(), fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, "application/zip", fileResult.Type, "invalid file type")
+	require.Equal(t, map[string]string{"archive": "1"}, fileResult.Processors, "invalid processor results")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, map[string]string{"archive": "1"}, f.Processors, "invalid processor results")
+}
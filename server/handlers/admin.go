@@ -87,3 +87,36 @@ func GetServerStatistics(ctx *context.Context, resp http.ResponseWriter, req *ht
 
 	common.WriteJSONResponse(resp, stats)
 }
+
+// GetAuditEvents return audit events
+func GetAuditEvents(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Double check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	pagingQuery := ctx.GetPagingQuery()
+
+	user := req.URL.Query().Get("user")
+	upload := req.URL.Query().Get("upload")
+	action := req.URL.Query().Get("action")
+
+	if action != "" {
+		err := common.ValidateAuditAction(action)
+		if err != nil {
+			ctx.InvalidParameter("action : %s", action)
+			return
+		}
+	}
+
+	// Get audit events
+	events, cursor, err := ctx.GetMetadataBackend().GetAuditEvents(user, upload, action, pagingQuery)
+	if err != nil {
+		ctx.InternalServerError("unable to get audit events : %s", err)
+		return
+	}
+
+	pagingResponse := common.NewPagingResponse(events, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"testing"
 
@@ -405,3 +406,91 @@ func TestGetServerStatisticsMetadataBackendError(t *testing.T) {
 
 	context.TestInternalServerError(t, rr, "database is closed")
 }
+
+func createTestAuditEvents(t *testing.T, ctx *context.Context) {
+	for _, action := range []string{common.AuditUpload, common.AuditDownload, common.AuditDownload} {
+		event := common.NewAuditEvent(action)
+		event.UserID = "user"
+		event.UploadID = "upload"
+		err := ctx.GetMetadataBackend().CreateAuditEvent(event)
+		require.NoError(t, err, "unable to create audit event")
+	}
+
+	event := common.NewAuditEvent(common.AuditLogin)
+	event.UserID = "admin"
+	err := ctx.GetMetadataBackend().CreateAuditEvent(event)
+	require.NoError(t, err, "unable to create audit event")
+}
+
+func getTestAuditEventsResponse(t *testing.T, ctx *context.Context, params map[string]string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/audit", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	query := req.URL.Query()
+	for key, value := range params {
+		query.Add(key, value)
+	}
+	req.URL.RawQuery = query.Encode()
+
+	ctx.SetPagingQuery(&common.PagingQuery{})
+	rr := ctx.NewRecorder(req)
+	GetAuditEvents(ctx, rr, req)
+	return rr
+}
+
+func TestGetAuditEvents(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+	createTestAuditEvents(t, ctx)
+
+	tests := []struct {
+		params map[string]string
+		count  int
+	}{
+		{nil, 4},
+		{map[string]string{"user": "user"}, 3},
+		{map[string]string{"upload": "upload", "action": common.AuditDownload}, 2},
+		{map[string]string{"action": common.AuditLogin}, 1},
+	}
+
+	for _, test := range tests {
+		rr := getTestAuditEventsResponse(t, ctx, test.params)
+		context.TestOK(t, rr)
+
+		respBody, err := io.ReadAll(rr.Body)
+		require.NoError(t, err, "unable to read response body")
+
+		var response common.PagingResponse
+		err = json.Unmarshal(respBody, &response)
+		require.NoError(t, err, "unable to unmarshal response body %s", respBody)
+		require.Equal(t, test.count, len(response.Results), "invalid audit event count for %v", test.params)
+	}
+}
+
+func TestGetAuditEventsInvalidAction(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	rr := getTestAuditEventsResponse(t, ctx, map[string]string{"action": "foo"})
+	context.TestInvalidParameter(t, rr, "action : foo")
+}
+
+func TestGetAuditEventsNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+	ctx.GetUser().IsAdmin = false
+
+	rr := getTestAuditEventsResponse(t, ctx, nil)
+	context.TestForbidden(t, rr, "you need administrator privileges")
+}
+
+func TestGetAuditEventsMetadataBackendError(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	err := ctx.GetMetadataBackend().Shutdown()
+	require.NoError(t, err, "unable to shutdown metadata backend")
+
+	rr := getTestAuditEventsResponse(t, ctx, nil)
+	context.TestInternalServerError(t, rr, "database is closed")
+}
@@ -58,6 +58,8 @@ func CreateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	ctx.EmitWebhookEvent(common.EventUploadCreated, upload, nil)
+
 	// You are admin of your own uploads
 	upload.IsAdmin = true
 
@@ -12,6 +12,7 @@ import (
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/webhook"
 )
 
 func createTestUpload(t *testing.T, ctx *context.Context, upload *common.Upload) {
@@ -294,3 +295,27 @@ func TestCreateUploadMaxUserSizeOK(t *testing.T) {
 func TestCreateUploadMaxUserSizeKO(t *testing.T) {
 	testCreateUploadMaxUserSize(t, false)
 }
+
+func TestCreateUploadWebhookEvent(t *testing.T) {
+	config := common.NewConfiguration()
+	config.Webhooks = []*common.WebhookConfig{{URL: "http://127.0.0.1:1/hook", Events: []string{common.EventUploadCreated}}}
+	ctx := newTestingContext(config)
+	ctx.SetWebhookDispatcher(webhook.NewDispatcher(config, ctx.GetMetadataBackend()))
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var deliveries []*common.WebhookDelivery
+	err = ctx.GetMetadataBackend().ForEachWebhookDelivery(func(delivery *common.WebhookDelivery) error {
+		deliveries = append(deliveries, delivery)
+		return nil
+	})
+	require.NoError(t, err, "unable to get webhook deliveries")
+	require.Len(t, deliveries, 1, "invalid webhook delivery count")
+	require.Equal(t, common.EventUploadCreated, deliveries[0].Event, "invalid webhook event")
+	require.Contains(t, deliveries[0].Payload, ctx.GetUpload().ID, "invalid webhook payload")
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/metadata"
 )
 
 // GetArchive download all file of the upload in a zip archive
@@ -41,8 +42,8 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 	resp.Header().Set("X-Frame-Options", "DENY")
 	resp.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'none'; style-src 'none'; img-src 'none'; connect-src 'none'; font-src 'none'; object-src 'none'; media-src 'none'; child-src 'none'; form-action 'none'; frame-ancestors 'none'; plugin-types ''; sandbox ''")
 
-	/* Additional header for disabling cache if the upload is OneShot */
-	if upload.OneShot {
+	/* Additional header for disabling cache if the upload is OneShot or has a download limit */
+	if upload.OneShot || upload.MaxDownloads > 0 {
 		resp.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate") // HTTP 1.1
 		resp.Header().Set("Pragma", "no-cache")                                   // HTTP 1.0
 		resp.Header().Set("Expires", "0")                                         // Proxies
@@ -88,12 +89,18 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 				return nil
 			}
 
+			maxDownloads := upload.MaxDownloads
 			if upload.OneShot {
-				// Update file status
-				err := ctx.GetMetadataBackend().UpdateFileStatus(file, file.Status, common.FileRemoved)
-				if err != nil {
-					return fmt.Errorf("unable to update file status : %s", err)
-				}
+				maxDownloads = 1
+			}
+
+			// Count the download, the file status is set to removed once the limit is reached
+			err := ctx.GetMetadataBackend().IncrementFileDownloads(file, maxDownloads)
+			if err == metadata.ErrDownloadLimitReached {
+				// Another request has downloaded the file concurrently
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("unable to update file download count : %s", err)
 			}
 
 			files = append(files, file)
@@ -225,6 +225,59 @@ func TestGetArchiveOneShot(t *testing.T) {
 
 }
 
+func TestGetArchiveMaxDownloads(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	upload.MaxDownloads = 2
+	file1 := upload.NewFile()
+	file1.Name = "file1"
+	file1.Status = common.FileUploaded
+	file2 := upload.NewFile()
+	file2.Name = "file2"
+	file2.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	for _, file := range upload.Files {
+		err := createTestFile(ctx, file, bytes.NewBufferString("data"))
+		require.NoError(t, err, "unable to create test file")
+	}
+
+	// file2 has already been downloaded the maximum number of times
+	for i := 0; i < upload.MaxDownloads; i++ {
+		err := ctx.GetMetadataBackend().IncrementFileDownloads(&common.File{ID: file2.ID}, upload.MaxDownloads)
+		require.NoError(t, err, "unable to increment file downloads")
+	}
+
+	req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/"+"archive.zip", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"filename": "archive.zip",
+	}
+	req = mux.SetURLVars(req, vars)
+
+	rr := ctx.NewRecorder(req)
+	GetArchive(ctx, rr, req)
+
+	context.TestOK(t, rr)
+	require.NotEmpty(t, rr.Header().Get("Cache-Control"), "missing cache control header")
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	z, err := zip.NewReader(bytes.NewReader(respBody), int64(len(respBody)))
+	require.NoError(t, err, "unable to unzip response body")
+	require.Equal(t, 1, len(z.File), "invalid archive file count")
+	require.Equal(t, file1.Name, z.File[0].Name, "invalid archived file name")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file1.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, 1, f.Downloads, "invalid file download count")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+}
+
 func TestGetArchiveNoArchiveName(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/metadata"
 )
 
 // GetFile download a file
@@ -45,14 +47,9 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		}
 	}
 
-	if req.Method == "GET" && upload.OneShot {
-		// Update file status
-		// For streaming upload the status is set to deleted by the add_file handler
-		err := ctx.GetMetadataBackend().UpdateFileStatus(file, file.Status, common.FileRemoved)
-		if err != nil {
-			ctx.InternalServerError("unable to update file status", err)
-		}
-	}
+	// OneShot and MaxDownloads files are removed once downloaded the maximum number of times
+	// For streaming upload the status is set to deleted by the add_file handler
+	limited := upload.OneShot || upload.MaxDownloads > 0
 
 	// Avoid rendering HTML in browser
 	if strings.Contains(file.Type, "html") {
@@ -76,16 +73,12 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 	}
 
 	/* Additional header for disabling cache if the upload is OneShot */
-	if upload.OneShot || upload.Stream { // If this is a one shot or stream upload we have to ensure it's downloaded only once.
+	if limited || upload.Stream { // If this is a one shot or stream upload we have to ensure it's downloaded only once.
 		resp.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate") // HTTP 1.1
 		resp.Header().Set("Pragma", "no-cache")                                   // HTTP 1.0
 		resp.Header().Set("Expires", "0")                                         // Proxies
 	}
 
-	if file.Size > 0 {
-		resp.Header().Set("Content-Length", strconv.Itoa(int(file.Size)))
-	}
-
 	// If "dl" GET params is set
 	// -> Set Content-Disposition header
 	// -> The client should download file instead of displaying it
@@ -96,6 +89,61 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		resp.Header().Set("Content-Disposition", fmt.Sprintf(`filename="%s"`, file.Name))
 	}
 
+	// Digests of the whole file content, they also apply to partial responses
+	if file.Sha256 != "" {
+		if digest, err := common.ReprDigest(file.Sha256); err == nil {
+			resp.Header().Set("Repr-Digest", digest)
+		}
+		if digest, err := common.InstanceDigest(file.Sha256); err == nil {
+			resp.Header().Set("Digest", digest)
+		}
+	}
+
+	status := http.StatusOK
+	offset := int64(0)
+	length := file.Size
+
+	// OneShot, MaxDownloads and Stream files can only be downloaded a limited number of times
+	// so they can't be cached nor resumed
+	if !limited && !upload.Stream {
+		resp.Header().Set("Accept-Ranges", "bytes")
+
+		etag := ""
+		if file.Md5 != "" {
+			etag = fmt.Sprintf(`"%s"`, file.Md5)
+			resp.Header().Set("ETag", etag)
+
+			if matchETag(req.Header.Get("If-None-Match"), etag) {
+				resp.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		// Range is ignored if If-Range does not match the current version of the file
+		rangeHeader := req.Header.Get("Range")
+		ifRange := req.Header.Get("If-Range")
+		if rangeHeader != "" && file.Size > 0 && (ifRange == "" || (etag != "" && ifRange == etag)) {
+			start, end, err := parseRange(rangeHeader, file.Size)
+			if err == errRangeNotSatisfiable {
+				resp.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", file.Size))
+				ctx.Fail(fmt.Sprintf("requested range not satisfiable : %s", rangeHeader), nil, http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+
+			// Invalid or multiple ranges are ignored and the whole file is sent
+			if err == nil {
+				status = http.StatusPartialContent
+				offset = start
+				length = end - start + 1
+				resp.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, file.Size))
+			}
+		}
+	}
+
+	if length > 0 {
+		resp.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	}
+
 	// HEAD Request => Do not print file, user just wants http headers
 	// GET  Request => Print file content
 	if req.Method == "GET" {
@@ -107,18 +155,48 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 			backend = ctx.GetDataBackend()
 		}
 
-		fileReader, err := backend.GetFile(file)
+		var fileReader io.ReadCloser
+		var err error
+		if status == http.StatusPartialContent {
+			fileReader, err = data.GetFileRange(backend, file, offset, length)
+		} else {
+			fileReader, err = backend.GetFile(file)
+		}
 		if err != nil {
 			ctx.InternalServerError("unable to get file from data backend", err)
 			return
 		}
 		defer func() { _ = fileReader.Close() }()
 
+		// Count the download, range requests resuming a download are not accounted as a new download
+		if !upload.Stream && offset == 0 {
+			maxDownloads := upload.MaxDownloads
+			if upload.OneShot {
+				maxDownloads = 1
+			}
+
+			err = ctx.GetMetadataBackend().IncrementFileDownloads(file, maxDownloads)
+			if err == metadata.ErrDownloadLimitReached {
+				ctx.NotFound("file %s (%s) is not available : %s", file.Name, file.ID, err)
+				return
+			} else if err != nil {
+				ctx.InternalServerError("unable to update file download count", err)
+				return
+			}
+		}
+
+		resp.WriteHeader(status)
+
 		// File is piped directly to http response body without buffering
 		_, err = io.Copy(resp, fileReader)
 		if err != nil {
 			log.Warningf("error while copying file to response : %s", err)
+		} else if offset == 0 {
+			ctx.EmitWebhookEvent(common.EventFileDownloaded, upload, file)
+			ctx.SendDownloadReceipt(upload, file)
 		}
+	} else {
+		resp.WriteHeader(status)
 	}
 
 	if file.Status == common.FileRemoved {
@@ -129,3 +207,73 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		}
 	}
 }
+
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// parseRange parse a single byte range HTTP Range header and return the first and last byte offsets
+func parseRange(header string, size int64) (start int64, end int64, err error) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return 0, 0, fmt.Errorf("invalid range unit")
+	}
+
+	spec := strings.TrimSpace(strings.TrimPrefix(header, "bytes="))
+	if strings.Contains(spec, ",") {
+		return 0, 0, fmt.Errorf("multiple ranges are not supported")
+	}
+
+	i := strings.Index(spec, "-")
+	if i < 0 {
+		return 0, 0, fmt.Errorf("invalid range")
+	}
+
+	first := strings.TrimSpace(spec[:i])
+	last := strings.TrimSpace(spec[i+1:])
+
+	if first == "" {
+		// Suffix range, the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("invalid range")
+		}
+		if n == 0 {
+			return 0, 0, errRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid range")
+	}
+
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, fmt.Errorf("invalid range")
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+
+	if start >= size {
+		return 0, 0, errRangeNotSatisfiable
+	}
+
+	return start, end, nil
+}
+
+// matchETag return true if the etag is listed in the If-None-Match HTTP header
+func matchETag(header string, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.TrimPrefix(value, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-
+	"net/http/httptest"
 	"strconv"
 	"testing"
 
@@ -245,6 +245,8 @@ func TestGetHtmlFile(t *testing.T) {
 	file := upload.NewFile()
 	file.Type = "html"
 	file.Status = "uploaded"
+	createTestUpload(t, ctx, upload)
+
 	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
 	require.NoError(t, err, "unable to create test file")
 
@@ -270,6 +272,8 @@ func TestGetFileNoType(t *testing.T) {
 
 	file := upload.NewFile()
 	file.Status = "uploaded"
+	createTestUpload(t, ctx, upload)
+
 	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
 	require.NoError(t, err, "unable to create test file")
 
@@ -360,3 +364,255 @@ func TestGetFileInvalidStatusStreaming(t *testing.T) {
 	GetFile(ctx, rr, req)
 	context.TestNotFound(t, rr, "is not available")
 }
+
+func getTestRangeFile(t *testing.T, ctx *context.Context, content string) (upload *common.Upload, file *common.File) {
+	upload = &common.Upload{}
+	file = upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Md5 = "12345"
+	file.Size = int64(len(content))
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBufferString(content))
+	require.NoError(t, err, "unable to create test file")
+
+	return upload, file
+}
+
+func getTestFileWithHeader(t *testing.T, ctx *context.Context, upload *common.Upload, file *common.File, header map[string]string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	return rr
+}
+
+func TestGetFileRange(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := getTestRangeFile(t, ctx, "data data data")
+
+	rr := getTestFileWithHeader(t, ctx, upload, file, map[string]string{"Range": "bytes=5-8"})
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status")
+	require.Equal(t, "bytes 5-8/14", rr.Header().Get("Content-Range"), "invalid response content range")
+	require.Equal(t, "4", rr.Header().Get("Content-Length"), "invalid response content length")
+	require.Equal(t, "bytes", rr.Header().Get("Accept-Ranges"), "invalid response accept ranges")
+	require.Equal(t, `"12345"`, rr.Header().Get("ETag"), "invalid response etag")
+	require.Equal(t, "data", rr.Body.String(), "invalid file content")
+
+	rr = getTestFileWithHeader(t, ctx, upload, file, map[string]string{"Range": "bytes=10-"})
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status")
+	require.Equal(t, "bytes 10-13/14", rr.Header().Get("Content-Range"), "invalid response content range")
+	require.Equal(t, "data", rr.Body.String(), "invalid file content")
+
+	rr = getTestFileWithHeader(t, ctx, upload, file, map[string]string{"Range": "bytes=-3"})
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status")
+	require.Equal(t, "bytes 11-13/14", rr.Header().Get("Content-Range"), "invalid response content range")
+	require.Equal(t, "ata", rr.Body.String(), "invalid file content")
+}
+
+func TestGetFileDigest(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := getTestRangeFile(t, ctx, "plik")
+
+	rr := getTestFileWithHeader(t, ctx, upload, file, nil)
+	context.TestOK(t, rr)
+	require.Empty(t, rr.Header().Get("Repr-Digest"), "unexpected digest header")
+	require.Empty(t, rr.Header().Get("Digest"), "unexpected digest header")
+
+	file.Sha256 = "96098609285f23507152ff387feeb6998e4f3eb0d7cd3752c6e7c079e45342d7"
+	err := ctx.GetMetadataBackend().UpdateFile(file, common.FileUploaded)
+	require.NoError(t, err, "unable to update file")
+
+	rr = getTestFileWithHeader(t, ctx, upload, file, nil)
+	context.TestOK(t, rr)
+	require.Equal(t, "sha-256=:lgmGCShfI1BxUv84f+62mY5PPrDXzTdSxufAeeRTQtc=:", rr.Header().Get("Repr-Digest"), "invalid repr digest header")
+	require.Equal(t, "SHA-256=lgmGCShfI1BxUv84f+62mY5PPrDXzTdSxufAeeRTQtc=", rr.Header().Get("Digest"), "invalid digest header")
+
+	rr = getTestFileWithHeader(t, ctx, upload, file, map[string]string{"Range": "bytes=0-1"})
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status")
+	require.Equal(t, "sha-256=:lgmGCShfI1BxUv84f+62mY5PPrDXzTdSxufAeeRTQtc=:", rr.Header().Get("Repr-Digest"), "invalid repr digest header")
+}
+
+func TestGetFileRangeNotSatisfiable(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := getTestRangeFile(t, ctx, "data")
+
+	rr := getTestFileWithHeader(t, ctx, upload, file, map[string]string{"Range": "bytes=10-"})
+	context.TestFail(t, rr, http.StatusRequestedRangeNotSatisfiable, "requested range not satisfiable")
+	require.Equal(t, "bytes */4", rr.Header().Get("Content-Range"), "invalid response content range")
+}
+
+func TestGetFileRangeInvalid(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := getTestRangeFile(t, ctx, "data")
+
+	rr := getTestFileWithHeader(t, ctx, upload, file, map[string]string{"Range": "bytes=0-1,2-3"})
+	context.TestOK(t, rr)
+	require.Equal(t, "data", rr.Body.String(), "invalid file content")
+}
+
+func TestGetFileIfRange(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := getTestRangeFile(t, ctx, "data")
+
+	rr := getTestFileWithHeader(t, ctx, upload, file, map[string]string{"Range": "bytes=2-", "If-Range": `"12345"`})
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status")
+	require.Equal(t, "ta", rr.Body.String(), "invalid file content")
+
+	rr = getTestFileWithHeader(t, ctx, upload, file, map[string]string{"Range": "bytes=2-", "If-Range": `"6789"`})
+	context.TestOK(t, rr)
+	require.Equal(t, "data", rr.Body.String(), "invalid file content")
+}
+
+func TestGetFileIfNoneMatch(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := getTestRangeFile(t, ctx, "data")
+
+	rr := getTestFileWithHeader(t, ctx, upload, file, map[string]string{"If-None-Match": `"12345"`})
+	require.Equal(t, http.StatusNotModified, rr.Code, "invalid response status")
+	require.Empty(t, rr.Body.String(), "invalid response body")
+
+	rr = getTestFileWithHeader(t, ctx, upload, file, map[string]string{"If-None-Match": `"6789"`})
+	context.TestOK(t, rr)
+	require.Equal(t, "data", rr.Body.String(), "invalid file content")
+}
+
+func TestGetOneShotFileRange(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	upload.OneShot = true
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Md5 = "12345"
+	file.Size = 4
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to create test file")
+
+	rr := getTestFileWithHeader(t, ctx, upload, file, map[string]string{"Range": "bytes=2-"})
+	context.TestOK(t, rr)
+	require.Empty(t, rr.Header().Get("ETag"), "invalid response etag")
+	require.Equal(t, "data", rr.Body.String(), "invalid file content")
+}
+
+func TestGetFileMaxDownloads(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	upload.MaxDownloads = 2
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Md5 = "12345"
+	file.Size = 4
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to create test file")
+
+	rr := getTestFileWithHeader(t, ctx, upload, file, map[string]string{"Range": "bytes=2-"})
+	context.TestOK(t, rr)
+	require.Empty(t, rr.Header().Get("ETag"), "invalid response etag")
+	require.NotEmpty(t, rr.Header().Get("Cache-Control"), "missing cache control header")
+	require.Equal(t, "data", rr.Body.String(), "invalid file content")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, 1, f.Downloads, "invalid file download count")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+
+	rr = getTestFileWithHeader(t, ctx, upload, file, nil)
+	context.TestOK(t, rr)
+	require.Equal(t, "data", rr.Body.String(), "invalid file content")
+
+	f, err = ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, 2, f.Downloads, "invalid file download count")
+	require.Equal(t, common.FileDeleted, f.Status, "invalid file status")
+
+	rr = getTestFileWithHeader(t, ctx, upload, file, nil)
+	context.TestNotFound(t, rr, "is not available")
+}
+
+func TestGetFileDownloadLimitReached(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	upload.MaxDownloads = 1
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to create test file")
+
+	// Simulate a concurrent download
+	err = ctx.GetMetadataBackend().IncrementFileDownloads(&common.File{ID: file.ID}, upload.MaxDownloads)
+	require.NoError(t, err, "unable to increment file downloads")
+
+	rr := getTestFileWithHeader(t, ctx, upload, file, nil)
+	context.TestNotFound(t, rr, "download limit reached")
+}
+
+func TestGetFileDownloadCount(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := getTestRangeFile(t, ctx, "data data data")
+
+	rr := getTestFileWithHeader(t, ctx, upload, file, nil)
+	context.TestOK(t, rr)
+
+	// Resuming a download is not accounted as a new download
+	rr = getTestFileWithHeader(t, ctx, upload, file, map[string]string{"Range": "bytes=5-"})
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, 1, f.Downloads, "invalid file download count")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+}
+
+func TestGetFileDownloadReceipt(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	newTestingNotifier(t, ctx)
+
+	owner := common.NewUser(common.ProviderLocal, "owner")
+	owner.Email = "owner@root.gg"
+	err := ctx.GetMetadataBackend().CreateUser(owner)
+	require.NoError(t, err, "unable to create user")
+
+	data := "data"
+
+	upload := &common.Upload{User: owner.ID, DownloadReceipt: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Size = int64(len(data))
+	createTestUpload(t, ctx, upload)
+
+	err = createTestFile(ctx, file, bytes.NewBuffer([]byte(data)))
+	require.NoError(t, err, "unable to create test file")
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetFile(ctx, rr, req)
+		context.TestOK(t, rr)
+	}
+
+	emails := getTestEmails(t, ctx)
+	require.Len(t, emails, 1, "invalid email count")
+	require.Equal(t, owner.Email, emails[0].To, "invalid email recipient")
+	require.Contains(t, emails[0].Text, file.Name, "missing file name")
+}
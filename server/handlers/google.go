@@ -179,7 +179,10 @@ func GoogleCallback(ctx *context.Context, resp http.ResponseWriter, req *http.Re
 	}
 
 	// Get user from metadata backend
-	user, err := ctx.GetMetadataBackend().GetUser(common.GetUserID(common.ProviderGoogle, userInfo.Email))
+	userID := common.GetUserID(common.ProviderGoogle, userInfo.Email)
+	ctx.SetAuditUser(userID)
+
+	user, err := ctx.GetMetadataBackend().GetUser(userID)
 	if err != nil {
 		ctx.InternalServerError("unable to get user from metadata backend", err)
 		return
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func readGroupParams(ctx *context.Context, resp http.ResponseWriter, req *http.Request) (groupParams *common.Group, ok bool) {
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest("unable to read request body : %s", err)
+		return nil, false
+	}
+
+	if len(body) == 0 {
+		ctx.BadRequest("unable to deserialize group : missing")
+		return nil, false
+	}
+
+	// Deserialize json body
+	groupParams = &common.Group{}
+	err = json.Unmarshal(body, groupParams)
+	if err != nil {
+		ctx.BadRequest("unable to deserialize group : %s", err)
+		return nil, false
+	}
+
+	return groupParams, true
+}
+
+// CreateGroup create a new group
+func CreateGroup(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Double check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	groupParams, ok := readGroupParams(ctx, resp, req)
+	if !ok {
+		return
+	}
+
+	// Create group from group params
+	group, err := common.CreateGroupFromParams(groupParams)
+	if err != nil {
+		ctx.BadRequest("unable to create group : %s", err)
+		return
+	}
+
+	existing, err := ctx.GetMetadataBackend().GetGroup(group.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get group : %s", err)
+		return
+	}
+	if existing != nil {
+		ctx.BadRequest("group %s already exists", group.ID)
+		return
+	}
+
+	err = ctx.GetMetadataBackend().CreateGroup(group)
+	if err != nil {
+		ctx.InternalServerError("unable to save group : %s", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, group)
+}
+
+// GetGroups return all the groups
+func GetGroups(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Double check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	pagingQuery := ctx.GetPagingQuery()
+
+	groups, cursor, err := ctx.GetMetadataBackend().GetGroups(true, pagingQuery)
+	if err != nil {
+		ctx.InternalServerError("unable to get groups : %s", err)
+		return
+	}
+
+	pagingResponse := common.NewPagingResponse(groups, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
+// GetGroup return the group and its members
+func GetGroup(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group := ctx.GetGroup()
+	if group == nil {
+		ctx.InternalServerError("missing group from context", nil)
+		return
+	}
+
+	common.WriteJSONResponse(resp, group)
+}
+
+// UpdateGroup edit an existing group
+func UpdateGroup(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Double check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	group := ctx.GetGroup()
+	if group == nil {
+		ctx.InternalServerError("missing group from context", nil)
+		return
+	}
+
+	groupParams, ok := readGroupParams(ctx, resp, req)
+	if !ok {
+		return
+	}
+
+	if groupParams.ID != "" && groupParams.ID != group.ID {
+		ctx.BadRequest("group id mismatch")
+		return
+	}
+
+	common.UpdateGroup(group, groupParams)
+
+	err := ctx.GetMetadataBackend().UpdateGroup(group)
+	if err != nil {
+		ctx.InternalServerError("unable to update group : %s", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, group)
+}
+
+// DeleteGroup remove a group, the group uploads are given back to the users who created them
+func DeleteGroup(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Double check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	group := ctx.GetGroup()
+	if group == nil {
+		ctx.InternalServerError("missing group from context", nil)
+		return
+	}
+
+	deleted, err := ctx.GetMetadataBackend().DeleteGroup(group.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete group : %s", err)
+		return
+	}
+
+	if !deleted {
+		ctx.NotFound("group not found")
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// AddGroupMember add a user to a group
+func AddGroupMember(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Double check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	group := ctx.GetGroup()
+	if group == nil {
+		ctx.InternalServerError("missing group from context", nil)
+		return
+	}
+
+	// Get the user id from the url params
+	vars := mux.Vars(req)
+	userID := vars["userID"]
+	if userID == "" {
+		ctx.MissingParameter("user id")
+		return
+	}
+
+	user, err := ctx.GetMetadataBackend().GetUser(userID)
+	if err != nil {
+		ctx.InternalServerError("unable to get user : %s", err)
+		return
+	}
+	if user == nil {
+		ctx.NotFound("user not found")
+		return
+	}
+
+	if group.IsMember(user.ID) {
+		ctx.BadRequest("user %s is already a member of group %s", user.ID, group.ID)
+		return
+	}
+
+	err = ctx.GetMetadataBackend().AddGroupMember(group.ID, user.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to add group member : %s", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// RemoveGroupMember remove a user from a group
+func RemoveGroupMember(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Double check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	group := ctx.GetGroup()
+	if group == nil {
+		ctx.InternalServerError("missing group from context", nil)
+		return
+	}
+
+	// Get the user id from the url params
+	vars := mux.Vars(req)
+	userID := vars["userID"]
+	if userID == "" {
+		ctx.MissingParameter("user id")
+		return
+	}
+
+	removed, err := ctx.GetMetadataBackend().RemoveGroupMember(group.ID, userID)
+	if err != nil {
+		ctx.InternalServerError("unable to remove group member : %s", err)
+		return
+	}
+
+	if !removed {
+		ctx.NotFound("group member not found")
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// GetGroupUploads return the uploads owned by a group
+func GetGroupUploads(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group := ctx.GetGroup()
+	if group == nil {
+		ctx.InternalServerError("missing group from context", nil)
+		return
+	}
+
+	pagingQuery := ctx.GetPagingQuery()
+
+	uploads, cursor, err := ctx.GetMetadataBackend().GetGroupUploads(group.ID, true, pagingQuery)
+	if err != nil {
+		ctx.InternalServerError("unable to get group uploads : %s", err)
+		return
+	}
+
+	pagingResponse := common.NewPagingResponse(uploads, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
+// GetGroupStatistics return the group statistics
+func GetGroupStatistics(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group := ctx.GetGroup()
+	if group == nil {
+		ctx.InternalServerError("missing group from context", nil)
+		return
+	}
+
+	stats, err := ctx.GetMetadataBackend().GetGroupStatistics(group.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get group statistics : %s", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, stats)
+}
+
+// GetUserGroups return the groups the user is a member of
+func GetUserGroups(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	groups, err := ctx.GetMetadataBackend().GetUserGroups(user.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get user groups : %s", err)
+		return
+	}
+
+	if groups == nil {
+		groups = []*common.Group{}
+	}
+
+	common.WriteJSONResponse(resp, groups)
+}
@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createTestGroup(t *testing.T, ctx *context.Context, ID string, members ...string) (group *common.Group) {
+	group = &common.Group{ID: ID}
+	err := ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+
+	for _, member := range members {
+		err = ctx.GetMetadataBackend().AddGroupMember(group.ID, member)
+		require.NoError(t, err, "unable to add group member")
+	}
+
+	group, err = ctx.GetMetadataBackend().GetGroup(ID)
+	require.NoError(t, err, "unable to get group")
+	return group
+}
+
+func TestCreateGroup(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	groupParams := &common.Group{ID: "research", Name: "Research", MaxGroupSize: 42}
+	reqBody, err := json.Marshal(groupParams)
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/group", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateGroup(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var result = &common.Group{}
+	err = json.Unmarshal(respBody, result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, groupParams.ID, result.ID, "invalid group id")
+	require.Equal(t, groupParams.Name, result.Name, "invalid group name")
+	require.Equal(t, groupParams.MaxGroupSize, result.MaxGroupSize, "invalid group max size")
+
+	group, err := ctx.GetMetadataBackend().GetGroup(groupParams.ID)
+	require.NoError(t, err, "unable to get group")
+	require.NotNil(t, group, "missing group")
+
+	// Already exists
+	req, err = http.NewRequest("POST", "/group", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	CreateGroup(ctx, rr, req)
+	context.TestBadRequest(t, rr, "group research already exists")
+}
+
+func TestCreateGroupInvalid(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	req, err := http.NewRequest("POST", "/group", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateGroup(ctx, rr, req)
+	context.TestBadRequest(t, rr, "unable to deserialize group : missing")
+
+	req, err = http.NewRequest("POST", "/group", bytes.NewBufferString(`{"id":"a b"}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	CreateGroup(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid group id")
+}
+
+func TestCreateGroupNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+
+	req, err := http.NewRequest("POST", "/group", bytes.NewBufferString(`{"id":"research"}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateGroup(ctx, rr, req)
+	context.TestForbidden(t, rr, "you need administrator privileges")
+}
+
+func TestGetGroups(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	createTestGroup(t, ctx, "group1", "user1")
+	createTestGroup(t, ctx, "group2")
+
+	req, err := http.NewRequest("GET", "/groups", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	ctx.SetPagingQuery(&common.PagingQuery{})
+	rr := ctx.NewRecorder(req)
+	GetGroups(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var response common.PagingResponse
+	err = json.Unmarshal(respBody, &response)
+	require.NoError(t, err, "unable to unmarshal response body %s", respBody)
+	require.Equal(t, 2, len(response.Results), "invalid group count")
+}
+
+func TestGetGroup(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetGroup(createTestGroup(t, ctx, "group", "user1"))
+
+	req, err := http.NewRequest("GET", "/group/group", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetGroup(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var result = &common.Group{}
+	err = json.Unmarshal(respBody, result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, "group", result.ID, "invalid group id")
+	require.Len(t, result.Members, 1, "invalid group members")
+	require.Equal(t, "user1", result.Members[0].UserID, "invalid group member")
+}
+
+func TestUpdateGroup(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+	ctx.SetGroup(createTestGroup(t, ctx, "group"))
+
+	req, err := http.NewRequest("POST", "/group/group", bytes.NewBufferString(`{"name":"Group","maxFileSize":1,"maxGroupSize":2,"maxTTL":3}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	UpdateGroup(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	group, err := ctx.GetMetadataBackend().GetGroup("group")
+	require.NoError(t, err, "unable to get group")
+	require.Equal(t, "Group", group.Name, "invalid group name")
+	require.Equal(t, int64(1), group.MaxFileSize, "invalid group max file size")
+	require.Equal(t, int64(2), group.MaxGroupSize, "invalid group max size")
+	require.Equal(t, 3, group.MaxTTL, "invalid group max ttl")
+
+	req, err = http.NewRequest("POST", "/group/group", bytes.NewBufferString(`{"id":"other"}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	UpdateGroup(ctx, rr, req)
+	context.TestBadRequest(t, rr, "group id mismatch")
+}
+
+func TestDeleteGroup(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+	ctx.SetGroup(createTestGroup(t, ctx, "group", "user1"))
+
+	req, err := http.NewRequest("DELETE", "/group/group", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	DeleteGroup(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	group, err := ctx.GetMetadataBackend().GetGroup("group")
+	require.NoError(t, err, "unable to get group")
+	require.Nil(t, group, "group should have been deleted")
+
+	rr = ctx.NewRecorder(req)
+	DeleteGroup(ctx, rr, req)
+	context.TestNotFound(t, rr, "group not found")
+}
+
+func TestAddGroupMember(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+	ctx.SetGroup(createTestGroup(t, ctx, "group"))
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	req, err := http.NewRequest("POST", "/group/group/member/"+user.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": "group", "userID": user.ID})
+
+	rr := ctx.NewRecorder(req)
+	AddGroupMember(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	group, err := ctx.GetMetadataBackend().GetGroup("group")
+	require.NoError(t, err, "unable to get group")
+	require.True(t, group.IsMember(user.ID), "user should be a group member")
+
+	ctx.SetGroup(group)
+	rr = ctx.NewRecorder(req)
+	AddGroupMember(ctx, rr, req)
+	context.TestBadRequest(t, rr, "is already a member of group group")
+
+	req = mux.SetURLVars(req, map[string]string{"groupID": "group", "userID": "local:missing"})
+	rr = ctx.NewRecorder(req)
+	AddGroupMember(ctx, rr, req)
+	context.TestNotFound(t, rr, "user not found")
+
+	req = mux.SetURLVars(req, map[string]string{"groupID": "group"})
+	rr = ctx.NewRecorder(req)
+	AddGroupMember(ctx, rr, req)
+	context.TestMissingParameter(t, rr, "user id")
+}
+
+func TestRemoveGroupMember(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+	ctx.SetGroup(createTestGroup(t, ctx, "group", "user1"))
+
+	req, err := http.NewRequest("DELETE", "/group/group/member/user1", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": "group", "userID": "user1"})
+
+	rr := ctx.NewRecorder(req)
+	RemoveGroupMember(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	group, err := ctx.GetMetadataBackend().GetGroup("group")
+	require.NoError(t, err, "unable to get group")
+	require.False(t, group.IsMember("user1"), "user should not be a group member")
+
+	rr = ctx.NewRecorder(req)
+	RemoveGroupMember(ctx, rr, req)
+	context.TestNotFound(t, rr, "group member not found")
+}
+
+func TestGetGroupUploads(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(&common.User{ID: "user1"})
+	ctx.SetGroup(createTestGroup(t, ctx, "group", "user1", "user2"))
+
+	for i := 0; i < 3; i++ {
+		upload := &common.Upload{User: "user2", Group: "group"}
+		file := upload.NewFile()
+		file.Status = common.FileUploaded
+		file.Size = 10
+		upload.InitializeForTests()
+		err := ctx.GetMetadataBackend().CreateUpload(upload)
+		require.NoError(t, err, "unable to create upload")
+	}
+
+	upload := &common.Upload{User: "user2"}
+	upload.InitializeForTests()
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	req, err := http.NewRequest("GET", "/group/group/uploads", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	ctx.SetPagingQuery(&common.PagingQuery{})
+	rr := ctx.NewRecorder(req)
+	GetGroupUploads(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var response common.PagingResponse
+	err = json.Unmarshal(respBody, &response)
+	require.NoError(t, err, "unable to unmarshal response body %s", respBody)
+	require.Equal(t, 3, len(response.Results), "invalid upload count")
+
+	req, err = http.NewRequest("GET", "/group/group/stats", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	GetGroupStatistics(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err = io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var stats = &common.UserStats{}
+	err = json.Unmarshal(respBody, stats)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, 3, stats.Uploads, "invalid uploads count")
+	require.Equal(t, int64(30), stats.TotalSize, "invalid total size")
+}
+
+func TestGetUserGroups(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(&common.User{ID: "user1"})
+
+	req, err := http.NewRequest("GET", "/me/groups", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetUserGroups(ctx, rr, req)
+	context.TestOK(t, rr)
+	require.Equal(t, "[]", rr.Body.String(), "invalid response body")
+
+	createTestGroup(t, ctx, "group1", "user1")
+	createTestGroup(t, ctx, "group2", "user2")
+
+	rr = ctx.NewRecorder(req)
+	GetUserGroups(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var groups []*common.Group
+	err = json.Unmarshal(rr.Body.Bytes(), &groups)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Len(t, groups, 1, "invalid groups count")
+	require.Equal(t, "group1", groups[0].ID, "invalid group")
+}
+
+func TestGetUserGroupsNoUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("GET", "/me/groups", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetUserGroups(ctx, rr, req)
+	context.TestUnauthorized(t, rr, "missing user, please login first")
+}
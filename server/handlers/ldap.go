@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+var errLDAPInvalidCredentials = errors.New("invalid credentials")
+
+// ldapLogin authenticate the user against the LDAP directory then create
+// or update the matching Plik user from the directory attributes
+func ldapLogin(ctx *context.Context, login string, password string) (user *common.User, err error) {
+	config := ctx.GetConfig()
+
+	ldapUser, err := ldapAuthenticate(config, login, password)
+	if err != nil {
+		if err == errLDAPInvalidCredentials {
+			return nil, common.NewHTTPError("invalid credentials", nil, http.StatusForbidden)
+		}
+		return nil, common.NewHTTPError("unable to authenticate LDAP user", err, http.StatusInternalServerError)
+	}
+
+	ctx.SetAuditUser(ldapUser.ID)
+
+	user, err = ctx.GetMetadataBackend().GetUser(ldapUser.ID)
+	if err != nil {
+		return nil, common.NewHTTPError("unable to get user from metadata backend", err, http.StatusInternalServerError)
+	}
+
+	if user == nil {
+		if !ctx.IsWhitelisted() {
+			return nil, common.NewHTTPError("unable to create user from untrusted source IP address", nil, http.StatusForbidden)
+		}
+
+		err = ctx.GetMetadataBackend().CreateUser(ldapUser)
+		if err != nil {
+			return nil, common.NewHTTPError("unable to create user", err, http.StatusInternalServerError)
+		}
+
+		return ldapUser, nil
+	}
+
+	// Keep the user in sync with the directory
+	user.Name = ldapUser.Name
+	user.Email = ldapUser.Email
+	user.IsAdmin = ldapUser.IsAdmin
+	if config.LDAPMaxFileSizeAttribute != "" {
+		user.MaxFileSize = ldapUser.MaxFileSize
+	}
+	if config.LDAPMaxUserSizeAttribute != "" {
+		user.MaxUserSize = ldapUser.MaxUserSize
+	}
+	if config.LDAPMaxTTLAttribute != "" {
+		user.MaxTTL = ldapUser.MaxTTL
+	}
+
+	err = ctx.GetMetadataBackend().UpdateUser(user)
+	if err != nil {
+		return nil, common.NewHTTPError("unable to update user", err, http.StatusInternalServerError)
+	}
+
+	return user, nil
+}
+
+// ldapAuthenticate search the user in the directory and bind as this user to check the password.
+// It returns a user built from the directory attributes that is not yet saved in the metadata backend.
+func ldapAuthenticate(config *common.Configuration, login string, password string) (user *common.User, err error) {
+	// An empty password would result in an unauthenticated bind that always succeeds
+	if login == "" || password == "" {
+		return nil, errLDAPInvalidCredentials
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.LDAPInsecureSkipVerify}
+
+	conn, err := ldap.DialURL(config.LDAPURL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to LDAP server : %s", err)
+	}
+	defer conn.Close()
+
+	if config.LDAPStartTLS {
+		err = conn.StartTLS(tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to start TLS : %s", err)
+		}
+	}
+
+	// Bind with the service account to search the user
+	if config.LDAPBindDN != "" {
+		err = conn.Bind(config.LDAPBindDN, config.LDAPBindPassword)
+		if err != nil {
+			return nil, fmt.Errorf("unable to bind LDAP service account : %s", err)
+		}
+	}
+
+	attributes := []string{"dn"}
+	for _, attribute := range []string{
+		config.LDAPNameAttribute,
+		config.LDAPEmailAttribute,
+		config.LDAPGroupAttribute,
+		config.LDAPMaxFileSizeAttribute,
+		config.LDAPMaxUserSizeAttribute,
+		config.LDAPMaxTTLAttribute,
+	} {
+		if attribute != "" {
+			attributes = append(attributes, attribute)
+		}
+	}
+
+	search := ldap.NewSearchRequest(
+		config.LDAPBaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(config.LDAPUserFilter, ldap.EscapeFilter(login)),
+		attributes,
+		nil,
+	)
+
+	result, err := conn.Search(search)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search LDAP user : %s", err)
+	}
+
+	// The login must match exactly one user
+	if len(result.Entries) != 1 {
+		return nil, errLDAPInvalidCredentials
+	}
+	entry := result.Entries[0]
+
+	// Bind as the user to check the password
+	err = conn.Bind(entry.DN, password)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, errLDAPInvalidCredentials
+		}
+		return nil, fmt.Errorf("unable to bind LDAP user : %s", err)
+	}
+
+	return newUserFromLDAPEntry(config, login, entry)
+}
+
+// newUserFromLDAPEntry map the directory attributes to a Plik user
+func newUserFromLDAPEntry(config *common.Configuration, login string, entry *ldap.Entry) (user *common.User, err error) {
+	user = common.NewUser(common.ProviderLDAP, login)
+	user.Login = login
+	user.Name = entry.GetAttributeValue(config.LDAPNameAttribute)
+	user.Email = entry.GetAttributeValue(config.LDAPEmailAttribute)
+
+	if config.LDAPAdminGroup != "" && config.LDAPGroupAttribute != "" {
+		for _, group := range entry.GetAttributeValues(config.LDAPGroupAttribute) {
+			if strings.EqualFold(group, config.LDAPAdminGroup) {
+				user.IsAdmin = true
+			}
+		}
+	}
+
+	if config.LDAPMaxFileSizeAttribute != "" {
+		user.MaxFileSize, err = parseLDAPSize(entry.GetAttributeValue(config.LDAPMaxFileSizeAttribute))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s attribute : %s", config.LDAPMaxFileSizeAttribute, err)
+		}
+	}
+
+	if config.LDAPMaxUserSizeAttribute != "" {
+		user.MaxUserSize, err = parseLDAPSize(entry.GetAttributeValue(config.LDAPMaxUserSizeAttribute))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s attribute : %s", config.LDAPMaxUserSizeAttribute, err)
+		}
+	}
+
+	if config.LDAPMaxTTLAttribute != "" {
+		if value := entry.GetAttributeValue(config.LDAPMaxTTLAttribute); value != "" {
+			user.MaxTTL, err = common.ParseTTL(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s attribute : %s", config.LDAPMaxTTLAttribute, err)
+			}
+		}
+	}
+
+	return user, nil
+}
+
+// parseLDAPSize parse a human readable size, an empty value means the default and -1 unlimited
+func parseLDAPSize(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if value == "-1" || value == "unlimited" {
+		return -1, nil
+	}
+
+	size, err := humanize.ParseBytes(value)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(size), nil
+}
@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+type ldapTestEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// ldapTestServer is a minimal in-process LDAP server that only
+// answers simple bind and search requests from a static directory
+type ldapTestServer struct {
+	listener net.Listener
+	entries  []*ldapTestEntry
+	mu       sync.Mutex
+}
+
+func newLDAPTestServer(t *testing.T, entries ...*ldapTestEntry) (server *ldapTestServer) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "unable to start ldap test server")
+
+	server = &ldapTestServer{listener: listener, entries: entries}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (server *ldapTestServer) URL() string {
+	return "ldap://" + server.listener.Addr().String()
+}
+
+func (server *ldapTestServer) Close() {
+	_ = server.listener.Close()
+}
+
+func (server *ldapTestServer) setAttribute(dn string, name string, values ...string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	for _, entry := range server.entries {
+		if entry.dn == dn {
+			entry.attributes[name] = values
+		}
+	}
+}
+
+func (server *ldapTestServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		messageID := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := request.Children[1].Value.(string)
+			password := request.Children[2].Data.String()
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			for _, entry := range server.getEntries() {
+				if entry.dn == dn && entry.password == password {
+					code = ldap.LDAPResultSuccess
+				}
+			}
+			server.write(conn, messageID, ldap.ApplicationBindResponse, code)
+		case ldap.ApplicationSearchRequest:
+			filter, err := ldap.DecompileFilter(request.Children[6])
+			if err != nil {
+				server.write(conn, messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError)
+				continue
+			}
+			for _, entry := range server.getEntries() {
+				if server.match(entry, filter) {
+					server.writeEntry(conn, messageID, entry)
+				}
+			}
+			server.write(conn, messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)
+		default:
+			return
+		}
+	}
+}
+
+func (server *ldapTestServer) getEntries() []*ldapTestEntry {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.entries
+}
+
+// Only simple equality filters like (uid=login) are supported
+func (server *ldapTestServer) match(entry *ldapTestEntry, filter string) bool {
+	components := strings.SplitN(strings.Trim(filter, "()"), "=", 2)
+	if len(components) != 2 {
+		return false
+	}
+
+	for _, value := range entry.attributes[components[0]] {
+		if value == components[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (server *ldapTestServer) write(conn net.Conn, messageID int64, tag ber.Tag, code uint16) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	packet.AppendChild(response)
+
+	_, _ = conn.Write(packet.Bytes())
+}
+
+func (server *ldapTestServer) writeEntry(conn net.Conn, messageID int64, entry *ldapTestEntry) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "Object Name"))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range entry.attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	response.AppendChild(attributes)
+	packet.AppendChild(response)
+
+	_, _ = conn.Write(packet.Bytes())
+}
+
+const ldapTestServiceDN = "cn=plik,ou=services,dc=root,dc=gg"
+const ldapTestUserDN = "uid=plik,ou=users,dc=root,dc=gg"
+
+func newLDAPTestDirectory(t *testing.T) *ldapTestServer {
+	return newLDAPTestServer(t,
+		&ldapTestEntry{
+			dn:         ldapTestServiceDN,
+			password:   "service_password",
+			attributes: map[string][]string{"cn": {"plik"}},
+		},
+		&ldapTestEntry{
+			dn:       ldapTestUserDN,
+			password: "password",
+			attributes: map[string][]string{
+				"uid":      {"plik"},
+				"cn":       {"Plik Root"},
+				"mail":     {"plik@root.gg"},
+				"memberOf": {"cn=users,ou=groups,dc=root,dc=gg", "CN=Admins,OU=Groups,DC=root,DC=gg"},
+				"quota":    {"1GB"},
+				"ttl":      {"30d"},
+			},
+		},
+	)
+}
+
+func newLDAPTestingContext(server *ldapTestServer) *context.Context {
+	ctx := newTestingContext(common.NewConfiguration())
+	config := ctx.GetConfig()
+	config.FeatureAuthentication = common.FeatureEnabled
+	config.LDAPAuthentication = true
+	config.LDAPURL = server.URL()
+	config.LDAPBindDN = ldapTestServiceDN
+	config.LDAPBindPassword = "service_password"
+	config.LDAPBaseDN = "dc=root,dc=gg"
+	config.LDAPAdminGroup = "cn=admins,ou=groups,dc=root,dc=gg"
+	config.LDAPMaxUserSizeAttribute = "quota"
+	config.LDAPMaxTTLAttribute = "ttl"
+	return ctx
+}
+
+func ldapTestLogin(t *testing.T, ctx *context.Context, login string, password string) *http.Response {
+	loginParams := &LoginParams{Login: login, Password: password}
+	body, err := json.Marshal(loginParams)
+	require.NoError(t, err, "unable to marshal login params")
+
+	req, err := http.NewRequest("POST", "/auth/local/login", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	LocalLogin(ctx, rr, req)
+	return rr.Result()
+}
+
+func TestLocalLoginLDAP(t *testing.T) {
+	server := newLDAPTestDirectory(t)
+	defer server.Close()
+	ctx := newLDAPTestingContext(server)
+
+	resp := ldapTestLogin(t, ctx, "plik", "password")
+	require.Equal(t, http.StatusOK, resp.StatusCode, "handler returned wrong status code")
+
+	var sessionCookie string
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "plik-session" {
+			sessionCookie = cookie.Value
+		}
+	}
+	require.NotEqual(t, "", sessionCookie, "missing plik session cookie")
+
+	user, err := ctx.GetMetadataBackend().GetUser("ldap:plik")
+	require.NoError(t, err, "unable to get user")
+	require.NotNil(t, user, "missing user")
+	require.Equal(t, common.ProviderLDAP, user.Provider, "invalid user provider")
+	require.Equal(t, "plik", user.Login, "invalid user login")
+	require.Equal(t, "Plik Root", user.Name, "invalid user name")
+	require.Equal(t, "plik@root.gg", user.Email, "invalid user email")
+	require.True(t, user.IsAdmin, "user should be admin")
+	require.Equal(t, int64(0), user.MaxFileSize, "invalid user max file size")
+	require.Equal(t, int64(1000000000), user.MaxUserSize, "invalid user max user size")
+	require.Equal(t, 30*86400, user.MaxTTL, "invalid user max ttl")
+	require.Empty(t, user.Password, "ldap user should not have a local password")
+}
+
+func TestLocalLoginLDAPUpdateUser(t *testing.T) {
+	server := newLDAPTestDirectory(t)
+	defer server.Close()
+	ctx := newLDAPTestingContext(server)
+
+	resp := ldapTestLogin(t, ctx, "plik", "password")
+	require.Equal(t, http.StatusOK, resp.StatusCode, "handler returned wrong status code")
+
+	server.setAttribute(ldapTestUserDN, "cn", "Plik")
+	server.setAttribute(ldapTestUserDN, "memberOf", "cn=users,ou=groups,dc=root,dc=gg")
+	server.setAttribute(ldapTestUserDN, "quota")
+
+	resp = ldapTestLogin(t, ctx, "plik", "password")
+	require.Equal(t, http.StatusOK, resp.StatusCode, "handler returned wrong status code")
+
+	user, err := ctx.GetMetadataBackend().GetUser("ldap:plik")
+	require.NoError(t, err, "unable to get user")
+	require.NotNil(t, user, "missing user")
+	require.Equal(t, "Plik", user.Name, "invalid user name")
+	require.False(t, user.IsAdmin, "user should not be admin anymore")
+	require.Equal(t, int64(0), user.MaxUserSize, "invalid user max user size")
+}
+
+func TestLocalLoginLDAPInvalidPassword(t *testing.T) {
+	server := newLDAPTestDirectory(t)
+	defer server.Close()
+	ctx := newLDAPTestingContext(server)
+
+	resp := ldapTestLogin(t, ctx, "plik", "invalid")
+	require.Equal(t, http.StatusForbidden, resp.StatusCode, "handler returned wrong status code")
+
+	user, err := ctx.GetMetadataBackend().GetUser("ldap:plik")
+	require.NoError(t, err, "unable to get user")
+	require.Nil(t, user, "user should not have been created")
+}
+
+func TestLocalLoginLDAPUnknownUser(t *testing.T) {
+	server := newLDAPTestDirectory(t)
+	defer server.Close()
+	ctx := newLDAPTestingContext(server)
+
+	resp := ldapTestLogin(t, ctx, "unknown", "password")
+	require.Equal(t, http.StatusForbidden, resp.StatusCode, "handler returned wrong status code")
+}
+
+func TestLocalLoginLDAPLocalUserFirst(t *testing.T) {
+	server := newLDAPTestDirectory(t)
+	defer server.Close()
+	ctx := newLDAPTestingContext(server)
+
+	user, err := common.CreateUserFromParams(&common.User{Provider: common.ProviderLocal, Login: "plik", Password: "local_password"})
+	require.NoError(t, err, "unable to create user")
+	err = ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to save user")
+
+	resp := ldapTestLogin(t, ctx, "plik", "local_password")
+	require.Equal(t, http.StatusOK, resp.StatusCode, "handler returned wrong status code")
+
+	ldapUser, err := ctx.GetMetadataBackend().GetUser("ldap:plik")
+	require.NoError(t, err, "unable to get user")
+	require.Nil(t, ldapUser, "local user should not fallback to ldap")
+
+	// A wrong local password fallback to the directory
+	resp = ldapTestLogin(t, ctx, "plik", "password")
+	require.Equal(t, http.StatusOK, resp.StatusCode, "handler returned wrong status code")
+
+	ldapUser, err = ctx.GetMetadataBackend().GetUser("ldap:plik")
+	require.NoError(t, err, "unable to get user")
+	require.NotNil(t, ldapUser, "missing ldap user")
+}
+
+func TestLocalLoginLDAPNotWhitelisted(t *testing.T) {
+	server := newLDAPTestDirectory(t)
+	defer server.Close()
+	ctx := newLDAPTestingContext(server)
+	ctx.SetWhitelisted(false)
+
+	resp := ldapTestLogin(t, ctx, "plik", "password")
+	require.Equal(t, http.StatusForbidden, resp.StatusCode, "handler returned wrong status code")
+}
+
+func TestLocalLoginLDAPInvalidServiceAccount(t *testing.T) {
+	server := newLDAPTestDirectory(t)
+	defer server.Close()
+	ctx := newLDAPTestingContext(server)
+	ctx.GetConfig().LDAPBindPassword = "invalid"
+
+	resp := ldapTestLogin(t, ctx, "plik", "password")
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode, "handler returned wrong status code")
+}
+
+func TestLocalLoginLDAPServerUnreachable(t *testing.T) {
+	server := newLDAPTestDirectory(t)
+	ctx := newLDAPTestingContext(server)
+	server.Close()
+
+	resp := ldapTestLogin(t, ctx, "plik", "password")
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode, "handler returned wrong status code")
+}
+
+func TestLocalLoginLDAPInvalidAttribute(t *testing.T) {
+	server := newLDAPTestDirectory(t)
+	defer server.Close()
+	ctx := newLDAPTestingContext(server)
+	server.setAttribute(ldapTestUserDN, "quota", "invalid")
+
+	resp := ldapTestLogin(t, ctx, "plik", "password")
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode, "handler returned wrong status code")
+}
+
+func TestLDAPAuthenticateEmptyPassword(t *testing.T) {
+	config := common.NewConfiguration()
+	config.LDAPURL = "ldap://127.0.0.1:1"
+
+	_, err := ldapAuthenticate(config, "plik", "")
+	require.Equal(t, errLDAPInvalidCredentials, err, "invalid error")
+}
+
+func TestLDAPAuthenticateFilterInjection(t *testing.T) {
+	server := newLDAPTestDirectory(t)
+	defer server.Close()
+	ctx := newLDAPTestingContext(server)
+
+	_, err := ldapAuthenticate(ctx.GetConfig(), "*", "password")
+	require.Equal(t, errLDAPInvalidCredentials, err, fmt.Sprintf("invalid error %v", err))
+}
+
+func TestParseLDAPSize(t *testing.T) {
+	size, err := parseLDAPSize("")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), size)
+
+	size, err = parseLDAPSize("-1")
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), size)
+
+	size, err = parseLDAPSize("10MB")
+	require.NoError(t, err)
+	require.Equal(t, int64(10000000), size)
+
+	_, err = parseLDAPSize("foo")
+	require.Error(t, err)
+}
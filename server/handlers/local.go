@@ -15,7 +15,7 @@ type LoginParams struct {
 	Password string `json:"password"`
 }
 
-// LocalLogin handler to authenticate local users
+// LocalLogin handler to authenticate local users and LDAP users if enabled
 func LocalLogin(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
 	config := ctx.GetConfig()
 
@@ -51,20 +51,27 @@ func LocalLogin(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 	}
 
 	// Get user from metadata backend
-	user, err := ctx.GetMetadataBackend().GetUser(common.GetUserID(common.ProviderLocal, loginParams.Login))
+	userID := common.GetUserID(common.ProviderLocal, loginParams.Login)
+	ctx.SetAuditUser(userID)
+
+	user, err := ctx.GetMetadataBackend().GetUser(userID)
 	if err != nil {
 		ctx.InternalServerError("unable to get user from metadata backend", err)
 		return
 	}
 
-	if user == nil {
-		ctx.Forbidden("invalid credentials")
-		return
-	}
+	if user == nil || !common.CheckPasswordHash(loginParams.Password, user.Password) {
+		if !config.LDAPAuthentication {
+			ctx.Forbidden("invalid credentials")
+			return
+		}
 
-	if !common.CheckPasswordHash(loginParams.Password, user.Password) {
-		ctx.Forbidden("invalid credentials")
-		return
+		// Fallback to the LDAP directory
+		user, err = ldapLogin(ctx, loginParams.Login, loginParams.Password)
+		if err != nil {
+			handleHTTPError(ctx, err)
+			return
+		}
 	}
 
 	// Set Plik session cookie and xsrf cookie
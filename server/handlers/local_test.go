@@ -137,3 +137,22 @@ func TestLocalLoginInvalidPassword(t *testing.T) {
 
 	context.TestForbidden(t, rr, "invalid credentials")
 }
+
+func TestLocalLoginAuditUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	event := common.NewAuditEvent(common.AuditLogin)
+	ctx.SetAuditEvent(event)
+
+	credentials, _ := utils.ToJson(struct{ Login, Password string }{"user", "invalid"})
+	req, err := http.NewRequest("GET", "/auth/local/login", bytes.NewBuffer(credentials))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	LocalLogin(ctx, rr, req)
+
+	context.TestForbidden(t, rr, "invalid credentials")
+	require.Equal(t, common.GetUserID(common.ProviderLocal, "user"), event.UserID, "invalid audit event user")
+	require.Equal(t, common.AuditFailure, event.Result, "invalid audit event result")
+}
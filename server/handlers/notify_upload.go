@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/notification"
+)
+
+// NotifyUpload send the upload link by email
+func NotifyUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	notifier := ctx.GetNotifier()
+	if notifier == nil {
+		ctx.BadRequest("email notifications are disabled")
+		return
+	}
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		ctx.InternalServerError("missing upload from context", nil)
+		return
+	}
+
+	// Check authorization
+	if !upload.IsAdmin {
+		ctx.Forbidden("you are not allowed to share this upload")
+		return
+	}
+
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest("unable to read request body : %s", err)
+		return
+	}
+
+	// Deserialize json body
+	params := &common.UploadNotification{}
+	err = json.Unmarshal(body, params)
+	if err != nil {
+		ctx.BadRequest("unable to deserialize request body : %s", err)
+		return
+	}
+
+	recipients, err := common.ParseEmailRecipients(params.To)
+	if err != nil {
+		ctx.BadRequest(err.Error())
+		return
+	}
+
+	if len(params.Message) > common.MaxEmailMessageLength {
+		ctx.BadRequest("message is too long, maximum is %d characters", common.MaxEmailMessageLength)
+		return
+	}
+
+	if params.DownloadReceipt && user.Email == "" {
+		ctx.BadRequest("missing email address to send the download receipt to")
+		return
+	}
+
+	// Load the files to list them in the email
+	files, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload files", err)
+		return
+	}
+	upload.Files = nil
+	for _, file := range files {
+		if file.Status == common.FileUploaded {
+			upload.Files = append(upload.Files, file)
+		}
+	}
+
+	err = notifier.Notify(notification.TemplateUpload, recipients, &notification.Data{Upload: upload, User: user, Message: params.Message})
+	if err != nil {
+		ctx.InternalServerError("unable to send email notification", err)
+		return
+	}
+
+	if params.DownloadReceipt && !upload.DownloadReceipt {
+		upload.DownloadReceipt = true
+		err = ctx.GetMetadataBackend().UpdateUploadDownloadReceipt(upload)
+		if err != nil {
+			ctx.InternalServerError("unable to update upload download receipt", err)
+			return
+		}
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/notification"
+)
+
+func newTestingNotifier(t *testing.T, ctx *context.Context) {
+	config := ctx.GetConfig()
+	config.SMTPHost = "127.0.0.1"
+	config.EmailFrom = "plik@root.gg"
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	notifier, err := notification.NewNotifier(config, ctx.GetMetadataBackend())
+	require.NoError(t, err, "unable to create notifier")
+	ctx.SetNotifier(notifier)
+}
+
+func getTestEmails(t *testing.T, ctx *context.Context) (emails []*common.Email) {
+	err := ctx.GetMetadataBackend().ForEachEmail(func(email *common.Email) error {
+		emails = append(emails, email)
+		return nil
+	})
+	require.NoError(t, err, "unable to get emails")
+	return emails
+}
+
+func newNotifyUploadRequest(t *testing.T, params *common.UploadNotification) *http.Request {
+	body, err := json.Marshal(params)
+	require.NoError(t, err, "unable to marshal params")
+
+	req, err := http.NewRequest("POST", "/upload/id/notify", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	return req
+}
+
+func TestNotifyUpload(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	newTestingNotifier(t, ctx)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	user.Name = "Foo Bar"
+	user.Email = "user@root.gg"
+	ctx.SetUser(user)
+
+	upload := &common.Upload{IsAdmin: true, User: user.ID}
+	file := upload.NewFile()
+	file.Name = "file.txt"
+	file.Status = common.FileUploaded
+	removed := upload.NewFile()
+	removed.Name = "removed.txt"
+	removed.Status = common.FileRemoved
+	createTestUpload(t, ctx, upload)
+
+	req := newNotifyUploadRequest(t, &common.UploadNotification{To: []string{"a@root.gg", "b@root.gg"}, Message: "hello", DownloadReceipt: true})
+
+	rr := ctx.NewRecorder(req)
+	NotifyUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.Equal(t, "ok", string(respBody), "invalid response body")
+
+	emails := getTestEmails(t, ctx)
+	require.Len(t, emails, 2, "invalid email count")
+	require.ElementsMatch(t, []string{"a@root.gg", "b@root.gg"}, []string{emails[0].To, emails[1].To}, "invalid email recipients")
+	require.Contains(t, emails[0].Subject, "Foo Bar", "invalid email subject")
+	require.Contains(t, emails[0].Text, "hello", "missing message")
+	require.Contains(t, emails[0].Text, "file.txt", "missing file")
+	require.NotContains(t, emails[0].Text, "removed.txt", "removed file should not be listed")
+
+	result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.True(t, result.DownloadReceipt, "invalid upload download receipt")
+}
+
+func TestNotifyUploadDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req := newNotifyUploadRequest(t, &common.UploadNotification{To: []string{"a@root.gg"}})
+
+	rr := ctx.NewRecorder(req)
+	NotifyUpload(ctx, rr, req)
+	context.TestBadRequest(t, rr, "email notifications are disabled")
+}
+
+func TestNotifyUploadNoUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	newTestingNotifier(t, ctx)
+
+	createTestUpload(t, ctx, &common.Upload{IsAdmin: true})
+
+	req := newNotifyUploadRequest(t, &common.UploadNotification{To: []string{"a@root.gg"}})
+
+	rr := ctx.NewRecorder(req)
+	NotifyUpload(ctx, rr, req)
+	context.TestUnauthorized(t, rr, "missing user, please login first")
+}
+
+func TestNotifyUploadNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	newTestingNotifier(t, ctx)
+
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+	createTestUpload(t, ctx, &common.Upload{})
+
+	req := newNotifyUploadRequest(t, &common.UploadNotification{To: []string{"a@root.gg"}})
+
+	rr := ctx.NewRecorder(req)
+	NotifyUpload(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not allowed to share this upload")
+}
+
+func TestNotifyUploadInvalidParams(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	newTestingNotifier(t, ctx)
+
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+	createTestUpload(t, ctx, &common.Upload{IsAdmin: true})
+
+	req := newNotifyUploadRequest(t, &common.UploadNotification{})
+	rr := ctx.NewRecorder(req)
+	NotifyUpload(ctx, rr, req)
+	context.TestBadRequest(t, rr, "missing email recipients")
+
+	req = newNotifyUploadRequest(t, &common.UploadNotification{To: []string{"invalid"}})
+	rr = ctx.NewRecorder(req)
+	NotifyUpload(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid email address invalid")
+
+	req = newNotifyUploadRequest(t, &common.UploadNotification{To: []string{"a@root.gg"}, Message: string(make([]byte, common.MaxEmailMessageLength+1))})
+	rr = ctx.NewRecorder(req)
+	NotifyUpload(ctx, rr, req)
+	context.TestBadRequest(t, rr, "message is too long")
+
+	req = newNotifyUploadRequest(t, &common.UploadNotification{To: []string{"a@root.gg"}, DownloadReceipt: true})
+	rr = ctx.NewRecorder(req)
+	NotifyUpload(ctx, rr, req)
+	context.TestBadRequest(t, rr, "missing email address to send the download receipt to")
+
+	require.Len(t, getTestEmails(t, ctx), 0, "invalid email count")
+}
+
+func TestNotifyUploadInvalidBody(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	newTestingNotifier(t, ctx)
+
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+	createTestUpload(t, ctx, &common.Upload{IsAdmin: true})
+
+	req, err := http.NewRequest("POST", "/upload/id/notify", bytes.NewBuffer([]byte("foo")))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	NotifyUpload(ctx, rr, req)
+	context.TestBadRequest(t, rr, "unable to deserialize request body")
+}
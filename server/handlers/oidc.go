@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+const oidcSessionCookieName = "plik-oidc-session"
+
+// OpenID Connect providers are cached by issuer URL to avoid doing
+// the discovery and fetching the signing keys at every login
+var oidcProviders sync.Map
+
+func getOIDCProvider(issuerURL string) (provider *oidc.Provider, err error) {
+	if p, ok := oidcProviders.Load(issuerURL); ok {
+		return p.(*oidc.Provider), nil
+	}
+
+	// The provider keeps the context to refresh the signing keys so it must not be bound to the request
+	provider, err = oidc.NewProvider(gocontext.Background(), issuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProviders.Store(issuerURL, provider)
+	return provider, nil
+}
+
+func getOIDCConfig(config *common.Configuration, provider *oidc.Provider, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     config.OIDCClientID,
+		ClientSecret: config.OIDCClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       config.OIDCScopes,
+		Endpoint:     provider.Endpoint(),
+	}
+}
+
+// PKCE S256 code challenge ( RFC 7636 )
+func getPKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// OIDCLogin return OpenID Connect provider user consent URL.
+func OIDCLogin(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+
+	if config.FeatureAuthentication == common.FeatureDisabled {
+		ctx.BadRequest("authentication is disabled")
+		return
+	}
+
+	if !config.OIDCAuthentication {
+		ctx.BadRequest("OpenID Connect authentication is disabled")
+		return
+	}
+
+	// Get redirection URL from the referrer header
+	redirectURL, err := getRedirectURL(ctx, "/auth/oidc/callback")
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	provider, err := getOIDCProvider(config.OIDCProviderURL)
+	if err != nil {
+		ctx.InternalServerError("unable to discover OpenID Connect provider", err)
+		return
+	}
+
+	state := common.GenerateRandomID(32)
+	nonce := common.GenerateRandomID(32)
+	verifier := common.GenerateRandomID(64)
+
+	// Generate session jwt
+	session := jwt.New(jwt.SigningMethodHS256)
+	session.Claims.(jwt.MapClaims)["state"] = state
+	session.Claims.(jwt.MapClaims)["nonce"] = nonce
+	session.Claims.(jwt.MapClaims)["verifier"] = verifier
+	session.Claims.(jwt.MapClaims)["redirectURL"] = redirectURL
+	session.Claims.(jwt.MapClaims)["expire"] = time.Now().Add(time.Minute * 5).Unix()
+
+	sessionString, err := session.SignedString([]byte(ctx.GetAuthenticator().SignatureKey))
+	if err != nil {
+		ctx.InternalServerError("unable to sign OpenID Connect session cookie", err)
+		return
+	}
+
+	// Store temporary session jwt in secure cookie
+	oidcAuthCookie := &http.Cookie{}
+	oidcAuthCookie.HttpOnly = true
+	oidcAuthCookie.Secure = true
+	oidcAuthCookie.Name = oidcSessionCookieName
+	oidcAuthCookie.Value = sessionString
+	oidcAuthCookie.MaxAge = int((5 * time.Minute).Seconds())
+	oidcAuthCookie.Path = "/"
+	http.SetCookie(resp, oidcAuthCookie)
+
+	// Redirect user to the provider consent page
+	url := getOIDCConfig(config, provider, redirectURL).AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", getPKCEChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"))
+
+	_, _ = resp.Write([]byte(url))
+}
+
+// Remove temporary session cookie
+func cleanOIDCAuthSessionCookie(resp http.ResponseWriter) {
+	oidcAuthCookie := &http.Cookie{}
+	oidcAuthCookie.HttpOnly = true
+	oidcAuthCookie.Secure = true
+	oidcAuthCookie.Name = oidcSessionCookieName
+	oidcAuthCookie.Value = ""
+	oidcAuthCookie.MaxAge = -1
+	oidcAuthCookie.Path = "/"
+	http.SetCookie(resp, oidcAuthCookie)
+}
+
+func parseOIDCSession(value string, signatureKey string) (claims jwt.MapClaims, err error) {
+	session, err := jwt.Parse(value, func(token *jwt.Token) (interface{}, error) {
+		// Verify signing algorithm
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected siging method : %v", token.Header["alg"])
+		}
+
+		// Verify expiration data
+		if expire, ok := token.Claims.(jwt.MapClaims)["expire"]; ok {
+			if _, ok = expire.(float64); ok {
+				if time.Now().Unix() > (int64)(expire.(float64)) {
+					return nil, fmt.Errorf("session has expired")
+				}
+			} else {
+				return nil, fmt.Errorf("invalid expiration date")
+			}
+		} else {
+			return nil, fmt.Errorf("missing expiration date")
+		}
+
+		return []byte(signatureKey), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims = session.Claims.(jwt.MapClaims)
+	for _, key := range []string{"state", "nonce", "verifier", "redirectURL"} {
+		if _, ok := claims[key].(string); !ok {
+			return nil, fmt.Errorf("missing %s", key)
+		}
+	}
+
+	return claims, nil
+}
+
+// OIDCCallback authenticate OpenID Connect user.
+func OIDCCallback(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+
+	if config.FeatureAuthentication == common.FeatureDisabled {
+		ctx.BadRequest("authentication is disabled")
+		return
+	}
+
+	if !config.OIDCAuthentication {
+		ctx.BadRequest("OpenID Connect authentication is disabled")
+		return
+	}
+
+	code := req.URL.Query().Get("code")
+	if code == "" {
+		ctx.MissingParameter("oauth2 authorization code")
+		return
+	}
+
+	state := req.URL.Query().Get("state")
+	if state == "" {
+		ctx.MissingParameter("oauth2 authorization state")
+		return
+	}
+
+	oidcAuthCookie, err := req.Cookie(oidcSessionCookieName)
+	if err != nil {
+		ctx.MissingParameter("OpenID Connect session cookie")
+		return
+	}
+
+	cleanOIDCAuthSessionCookie(resp)
+
+	session, err := parseOIDCSession(oidcAuthCookie.Value, ctx.GetAuthenticator().SignatureKey)
+	if err != nil {
+		ctx.InvalidParameter("OpenID Connect session cookie : %s", err)
+		return
+	}
+
+	if session["state"].(string) != state {
+		ctx.InvalidParameter("oauth2 state : mismatch")
+		return
+	}
+
+	provider, err := getOIDCProvider(config.OIDCProviderURL)
+	if err != nil {
+		ctx.InternalServerError("unable to discover OpenID Connect provider", err)
+		return
+	}
+
+	conf := getOIDCConfig(config, provider, session["redirectURL"].(string))
+	token, err := conf.Exchange(req.Context(), code, oauth2.SetAuthURLParam("code_verifier", session["verifier"].(string)))
+	if err != nil {
+		ctx.InternalServerError("unable to get token from OpenID Connect provider", err)
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		ctx.InternalServerError("missing id token in OpenID Connect provider response", nil)
+		return
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: config.OIDCClientID}).Verify(req.Context(), rawIDToken)
+	if err != nil {
+		ctx.InvalidParameter("OpenID Connect id token : %s", err)
+		return
+	}
+
+	if idToken.Nonce != session["nonce"].(string) {
+		ctx.InvalidParameter("OpenID Connect id token : invalid nonce")
+		return
+	}
+
+	claims := make(map[string]interface{})
+	err = idToken.Claims(&claims)
+	if err != nil {
+		ctx.InternalServerError("unable to parse OpenID Connect id token claims", err)
+		return
+	}
+
+	login := getOIDCClaimString(claims, config.OIDCLoginClaim)
+	if login == "" {
+		ctx.Forbidden("missing %s claim in OpenID Connect id token", config.OIDCLoginClaim)
+		return
+	}
+
+	email := getOIDCClaimString(claims, config.OIDCEmailClaim)
+	emailVerified, _ := getOIDCClaim(claims, "email_verified").(bool)
+	err = checkOIDCValidDomain(config, email, emailVerified)
+	if err != nil {
+		ctx.Forbidden(err.Error())
+		return
+	}
+
+	err = checkOIDCValidGroups(config, getOIDCClaimStrings(claims, config.OIDCGroupsClaim))
+	if err != nil {
+		ctx.Forbidden(err.Error())
+		return
+	}
+
+	// Get user from metadata backend
+	userID := common.GetUserID(common.ProviderOIDC, login)
+	ctx.SetAuditUser(userID)
+
+	user, err := ctx.GetMetadataBackend().GetUser(userID)
+	if err != nil {
+		ctx.InternalServerError("unable to get user from metadata backend", err)
+		return
+	}
+
+	if user == nil {
+		if !ctx.IsWhitelisted() {
+			ctx.Forbidden("unable to create user from untrusted source IP address")
+			return
+		}
+
+		// Create new user
+		user = common.NewUser(common.ProviderOIDC, login)
+		user.Login = login
+		user.Name = getOIDCClaimString(claims, config.OIDCNameClaim)
+		user.Email = email
+		user.IsAdmin = isOIDCAdmin(config, claims)
+
+		// Save user to metadata backend
+		err = ctx.GetMetadataBackend().CreateUser(user)
+		if err != nil {
+			ctx.InternalServerError("unable to create user", err)
+			return
+		}
+	} else if config.OIDCAdminClaim != "" {
+		// Keep admin rights in sync with the provider
+		isAdmin := isOIDCAdmin(config, claims)
+		if user.IsAdmin != isAdmin {
+			user.IsAdmin = isAdmin
+			err = ctx.GetMetadataBackend().UpdateUser(user)
+			if err != nil {
+				ctx.InternalServerError("unable to update user", err)
+				return
+			}
+		}
+	}
+
+	// Set Plik session cookie and xsrf cookie
+	sessionCookie, xsrfCookie, err := ctx.GetAuthenticator().GenAuthCookies(user)
+	if err != nil {
+		ctx.InternalServerError("unable to generate session cookies", err)
+		return
+	}
+	http.SetCookie(resp, sessionCookie)
+	http.SetCookie(resp, xsrfCookie)
+
+	http.Redirect(resp, req, config.Path+"/#/login", http.StatusMovedPermanently)
+}
+
+// getOIDCClaim return the value of a claim.
+// Nested claims like "realm_access.roles" are looked up using a dotted path.
+func getOIDCClaim(claims map[string]interface{}, name string) interface{} {
+	if name == "" {
+		return nil
+	}
+
+	if value, ok := claims[name]; ok {
+		return value
+	}
+
+	var value interface{} = claims
+	for _, key := range strings.Split(name, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+
+	return value
+}
+
+func getOIDCClaimString(claims map[string]interface{}, name string) string {
+	if value, ok := getOIDCClaim(claims, name).(string); ok {
+		return value
+	}
+	return ""
+}
+
+func getOIDCClaimStrings(claims map[string]interface{}, name string) (values []string) {
+	switch value := getOIDCClaim(claims, name).(type) {
+	case string:
+		values = append(values, value)
+	case []interface{}:
+		for _, v := range value {
+			if str, ok := v.(string); ok {
+				values = append(values, str)
+			}
+		}
+	}
+	return values
+}
+
+// checkOIDCValidDomain check that the email domain is whitelisted
+// The email must have been verified by the provider as users may be able to set any address
+func checkOIDCValidDomain(config *common.Configuration, email string, verified bool) error {
+	if len(config.OIDCValidDomains) == 0 {
+		return nil
+	}
+
+	if !verified {
+		return fmt.Errorf("unverified email address")
+	}
+
+	components := strings.Split(email, "@")
+	if len(components) == 2 {
+		for _, validDomain := range config.OIDCValidDomains {
+			if components[1] == validDomain {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("unauthorized domain name")
+}
+
+func checkOIDCValidGroups(config *common.Configuration, groups []string) error {
+	if len(config.OIDCValidGroups) == 0 {
+		return nil
+	}
+
+	for _, group := range groups {
+		for _, validGroup := range config.OIDCValidGroups {
+			if group == validGroup {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("unauthorized group")
+}
+
+// isOIDCAdmin return true if the admin claim grants admin rights.
+// A boolean claim is used as is, a string or list claim must match the admin value.
+func isOIDCAdmin(config *common.Configuration, claims map[string]interface{}) bool {
+	if config.OIDCAdminClaim == "" {
+		return false
+	}
+
+	if value, ok := getOIDCClaim(claims, config.OIDCAdminClaim).(bool); ok {
+		return value
+	}
+
+	if config.OIDCAdminValue == "" {
+		return false
+	}
+
+	for _, value := range getOIDCClaimStrings(claims, config.OIDCAdminClaim) {
+		if value == config.OIDCAdminValue {
+			return true
+		}
+	}
+
+	return false
+}
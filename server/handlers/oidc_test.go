@@ -0,0 +1,485 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+var oidcTestKey *rsa.PrivateKey
+var oidcTestKeyOnce sync.Once
+
+// fakeOIDCIssuer is a minimal OpenID Connect provider
+// that issues an id token with the configured claims
+type fakeOIDCIssuer struct {
+	server   *httptest.Server
+	clientID string
+	claims   jwt.MapClaims
+	nonce    string
+	verifier string
+}
+
+func newFakeOIDCIssuer(t *testing.T, clientID string, claims jwt.MapClaims) (issuer *fakeOIDCIssuer) {
+	oidcTestKeyOnce.Do(func() {
+		var err error
+		oidcTestKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err, "unable to generate rsa key")
+	})
+
+	issuer = &fakeOIDCIssuer{clientID: clientID, claims: claims}
+
+	handler := func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(resp).Encode(map[string]interface{}{
+				"issuer":                                issuer.server.URL,
+				"authorization_endpoint":                issuer.server.URL + "/auth",
+				"token_endpoint":                        issuer.server.URL + "/token",
+				"jwks_uri":                              issuer.server.URL + "/keys",
+				"id_token_signing_alg_values_supported": []string{"RS256"},
+			})
+		case "/keys":
+			_ = json.NewEncoder(resp).Encode(map[string]interface{}{
+				"keys": []map[string]string{{
+					"kty": "RSA",
+					"kid": "test",
+					"alg": "RS256",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(oidcTestKey.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(oidcTestKey.E)).Bytes()),
+				}},
+			})
+		case "/token":
+			if req.FormValue("code_verifier") != issuer.verifier {
+				resp.WriteHeader(http.StatusBadRequest)
+				_, _ = resp.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+
+			claims := jwt.MapClaims{
+				"iss":   issuer.server.URL,
+				"aud":   issuer.clientID,
+				"sub":   "subject",
+				"iat":   time.Now().Unix(),
+				"exp":   time.Now().Add(time.Hour).Unix(),
+				"nonce": issuer.nonce,
+			}
+			for k, v := range issuer.claims {
+				claims[k] = v
+			}
+
+			idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+			idToken.Header["kid"] = "test"
+			rawIDToken, err := idToken.SignedString(oidcTestKey)
+			require.NoError(t, err, "unable to sign id token")
+
+			_ = json.NewEncoder(resp).Encode(map[string]interface{}{
+				"access_token": "access_token",
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+				"id_token":     rawIDToken,
+			})
+		default:
+			resp.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	issuer.server = httptest.NewServer(http.HandlerFunc(handler))
+	return issuer
+}
+
+func newOIDCTestingContext(issuer *fakeOIDCIssuer) *context.Context {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.GetConfig().OIDCAuthentication = true
+	ctx.GetConfig().OIDCProviderURL = issuer.server.URL
+	ctx.GetConfig().OIDCClientID = issuer.clientID
+	ctx.GetConfig().OIDCClientSecret = "oidc_client_secret"
+	return ctx
+}
+
+// oidcLogin call OIDCLogin and return the session cookie and the oauth2 state
+func oidcLogin(t *testing.T, ctx *context.Context, issuer *fakeOIDCIssuer) (cookie *http.Cookie, state string) {
+	req, err := http.NewRequest("GET", "/auth/oidc/login", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("referer", "https://plik.root.gg")
+
+	rr := ctx.NewRecorder(req)
+	OIDCLogin(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	URL, err := url.Parse(string(respBody))
+	require.NoError(t, err, "unable to parse consent url")
+	require.Equal(t, issuer.server.URL+"/auth", URL.Scheme+"://"+URL.Host+URL.Path, "invalid consent url")
+	require.Equal(t, issuer.clientID, URL.Query().Get("client_id"), "invalid client id")
+	require.Equal(t, "https://plik.root.gg/auth/oidc/callback", URL.Query().Get("redirect_uri"), "invalid redirect url")
+	require.Equal(t, "S256", URL.Query().Get("code_challenge_method"), "invalid code challenge method")
+	require.NotEmpty(t, URL.Query().Get("state"), "missing state")
+	require.NotEmpty(t, URL.Query().Get("nonce"), "missing nonce")
+
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == oidcSessionCookieName {
+			cookie = c
+		}
+	}
+	require.NotNil(t, cookie, "missing oidc session cookie")
+	require.True(t, cookie.HttpOnly, "oidc session cookie should be http only")
+
+	session, err := parseOIDCSession(cookie.Value, ctx.GetAuthenticator().SignatureKey)
+	require.NoError(t, err, "invalid oidc session cookie")
+	require.Equal(t, URL.Query().Get("state"), session["state"], "invalid session state")
+	require.Equal(t, URL.Query().Get("nonce"), session["nonce"], "invalid session nonce")
+	require.Equal(t, URL.Query().Get("code_challenge"), getPKCEChallenge(session["verifier"].(string)), "invalid code challenge")
+
+	issuer.nonce = session["nonce"].(string)
+	issuer.verifier = session["verifier"].(string)
+
+	return cookie, URL.Query().Get("state")
+}
+
+func oidcCallback(t *testing.T, ctx *context.Context, cookie *http.Cookie, state string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/auth/oidc/callback?code=code&state="+url.QueryEscape(state), bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	rr := ctx.NewRecorder(req)
+	OIDCCallback(ctx, rr, req)
+	return rr
+}
+
+func TestOIDCLoginAuthDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureDisabled
+
+	req, err := http.NewRequest("GET", "/auth/oidc/login", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	OIDCLogin(ctx, rr, req)
+	context.TestBadRequest(t, rr, "authentication is disabled")
+}
+
+func TestOIDCLoginOIDCAuthDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.GetConfig().OIDCAuthentication = false
+
+	req, err := http.NewRequest("GET", "/auth/oidc/login", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	OIDCLogin(ctx, rr, req)
+	context.TestBadRequest(t, rr, "OpenID Connect authentication is disabled")
+}
+
+func TestOIDCLoginMissingReferer(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", nil)
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+
+	req, err := http.NewRequest("GET", "/auth/oidc/login", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	OIDCLogin(ctx, rr, req)
+	context.TestBadRequest(t, rr, "missing referer header")
+}
+
+func TestOIDCLoginDiscoveryError(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.GetConfig().OIDCAuthentication = true
+	ctx.GetConfig().OIDCProviderURL = "http://127.0.0.1:1/invalid"
+
+	req, err := http.NewRequest("GET", "/auth/oidc/login", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("referer", "https://plik.root.gg")
+
+	rr := ctx.NewRecorder(req)
+	OIDCLogin(ctx, rr, req)
+	context.TestInternalServerError(t, rr, "unable to discover OpenID Connect provider")
+}
+
+func TestOIDCCallbackCreateUser(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", jwt.MapClaims{
+		"preferred_username": "plik",
+		"name":               "Plik",
+		"email":              "plik@root.gg",
+	})
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+
+	cookie, state := oidcLogin(t, ctx, issuer)
+	rr := oidcCallback(t, ctx, cookie, state)
+	require.Equal(t, http.StatusMovedPermanently, rr.Code, "handler returned wrong status code")
+
+	var sessionCookie string
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == "plik-session" {
+			sessionCookie = cookie.Value
+		}
+	}
+	require.NotEqual(t, "", sessionCookie, "missing plik session cookie")
+
+	user, err := ctx.GetMetadataBackend().GetUser("oidc:plik")
+	require.NoError(t, err, "unable to get user")
+	require.NotNil(t, user, "missing user")
+	require.Equal(t, common.ProviderOIDC, user.Provider, "invalid user provider")
+	require.Equal(t, "plik", user.Login, "invalid user login")
+	require.Equal(t, "Plik", user.Name, "invalid user name")
+	require.Equal(t, "plik@root.gg", user.Email, "invalid user email")
+	require.False(t, user.IsAdmin, "user should not be admin")
+}
+
+func TestOIDCCallbackCustomClaims(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", jwt.MapClaims{
+		"uid":            "plik",
+		"display_name":   "Plik",
+		"mail":           "plik@root.gg",
+		"email_verified": true,
+		"realm_access":   map[string]interface{}{"roles": []string{"users", "plik-admins"}},
+	})
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+	ctx.GetConfig().OIDCLoginClaim = "uid"
+	ctx.GetConfig().OIDCNameClaim = "display_name"
+	ctx.GetConfig().OIDCEmailClaim = "mail"
+	ctx.GetConfig().OIDCGroupsClaim = "realm_access.roles"
+	ctx.GetConfig().OIDCValidGroups = []string{"users"}
+	ctx.GetConfig().OIDCValidDomains = []string{"root.gg"}
+	ctx.GetConfig().OIDCAdminClaim = "realm_access.roles"
+	ctx.GetConfig().OIDCAdminValue = "plik-admins"
+
+	cookie, state := oidcLogin(t, ctx, issuer)
+	rr := oidcCallback(t, ctx, cookie, state)
+	require.Equal(t, http.StatusMovedPermanently, rr.Code, "handler returned wrong status code")
+
+	user, err := ctx.GetMetadataBackend().GetUser("oidc:plik")
+	require.NoError(t, err, "unable to get user")
+	require.NotNil(t, user, "missing user")
+	require.Equal(t, "Plik", user.Name, "invalid user name")
+	require.Equal(t, "plik@root.gg", user.Email, "invalid user email")
+	require.True(t, user.IsAdmin, "user should be admin")
+
+	// Admin rights are revoked by the provider
+	issuer.claims["realm_access"] = map[string]interface{}{"roles": []string{"users"}}
+
+	cookie, state = oidcLogin(t, ctx, issuer)
+	rr = oidcCallback(t, ctx, cookie, state)
+	require.Equal(t, http.StatusMovedPermanently, rr.Code, "handler returned wrong status code")
+
+	user, err = ctx.GetMetadataBackend().GetUser("oidc:plik")
+	require.NoError(t, err, "unable to get user")
+	require.False(t, user.IsAdmin, "user should not be admin anymore")
+}
+
+func TestOIDCCallbackInvalidGroup(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", jwt.MapClaims{
+		"preferred_username": "plik",
+		"groups":             []string{"guests"},
+	})
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+	ctx.GetConfig().OIDCValidGroups = []string{"users"}
+
+	cookie, state := oidcLogin(t, ctx, issuer)
+	rr := oidcCallback(t, ctx, cookie, state)
+	context.TestForbidden(t, rr, "unauthorized group")
+
+	user, err := ctx.GetMetadataBackend().GetUser("oidc:plik")
+	require.NoError(t, err, "unable to get user")
+	require.Nil(t, user, "user should not have been created")
+}
+
+func TestOIDCCallbackInvalidDomain(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", jwt.MapClaims{
+		"preferred_username": "plik",
+		"email":              "plik@evil.com",
+		"email_verified":     true,
+	})
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+	ctx.GetConfig().OIDCValidDomains = []string{"root.gg"}
+
+	cookie, state := oidcLogin(t, ctx, issuer)
+	rr := oidcCallback(t, ctx, cookie, state)
+	context.TestForbidden(t, rr, "unauthorized domain name")
+}
+
+func TestOIDCCallbackUnverifiedEmail(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", jwt.MapClaims{
+		"preferred_username": "plik",
+		"email":              "plik@root.gg",
+		"email_verified":     false,
+	})
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+	ctx.GetConfig().OIDCValidDomains = []string{"root.gg"}
+
+	cookie, state := oidcLogin(t, ctx, issuer)
+	rr := oidcCallback(t, ctx, cookie, state)
+	context.TestForbidden(t, rr, "unverified email address")
+
+	user, err := ctx.GetMetadataBackend().GetUser("oidc:plik")
+	require.NoError(t, err, "unable to get user")
+	require.Nil(t, user, "user should not have been created")
+
+	// Missing email_verified claim
+	delete(issuer.claims, "email_verified")
+
+	cookie, state = oidcLogin(t, ctx, issuer)
+	rr = oidcCallback(t, ctx, cookie, state)
+	context.TestForbidden(t, rr, "unverified email address")
+}
+
+func TestOIDCCallbackMissingLoginClaim(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", jwt.MapClaims{})
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+
+	cookie, state := oidcLogin(t, ctx, issuer)
+	rr := oidcCallback(t, ctx, cookie, state)
+	context.TestForbidden(t, rr, "missing preferred_username claim in OpenID Connect id token")
+}
+
+func TestOIDCCallbackCreateUserNotWhitelisted(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", jwt.MapClaims{"preferred_username": "plik"})
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+	ctx.SetWhitelisted(false)
+
+	cookie, state := oidcLogin(t, ctx, issuer)
+	rr := oidcCallback(t, ctx, cookie, state)
+	context.TestForbidden(t, rr, "unable to create user from untrusted source IP address")
+}
+
+func TestOIDCCallbackInvalidAudience(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", jwt.MapClaims{"preferred_username": "plik", "aud": "other"})
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+
+	cookie, state := oidcLogin(t, ctx, issuer)
+	rr := oidcCallback(t, ctx, cookie, state)
+	context.TestInvalidParameter(t, rr, "OpenID Connect id token")
+}
+
+func TestOIDCCallbackInvalidNonce(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", jwt.MapClaims{"preferred_username": "plik"})
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+
+	cookie, state := oidcLogin(t, ctx, issuer)
+	issuer.nonce = "invalid"
+	rr := oidcCallback(t, ctx, cookie, state)
+	context.TestInvalidParameter(t, rr, "OpenID Connect id token : invalid nonce")
+}
+
+func TestOIDCCallbackInvalidVerifier(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", jwt.MapClaims{"preferred_username": "plik"})
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+
+	cookie, state := oidcLogin(t, ctx, issuer)
+	issuer.verifier = "invalid"
+	rr := oidcCallback(t, ctx, cookie, state)
+	context.TestInternalServerError(t, rr, "unable to get token from OpenID Connect provider")
+}
+
+func TestOIDCCallbackStateMismatch(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", nil)
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+
+	cookie, _ := oidcLogin(t, ctx, issuer)
+	rr := oidcCallback(t, ctx, cookie, "invalid")
+	context.TestInvalidParameter(t, rr, "oauth2 state : mismatch")
+}
+
+func TestOIDCCallbackMissingSessionCookie(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", nil)
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+
+	rr := oidcCallback(t, ctx, nil, "state")
+	context.TestMissingParameter(t, rr, "OpenID Connect session cookie")
+}
+
+func TestOIDCCallbackInvalidSessionCookie(t *testing.T) {
+	issuer := newFakeOIDCIssuer(t, "plik", nil)
+	defer issuer.server.Close()
+	ctx := newOIDCTestingContext(issuer)
+
+	session := jwt.New(jwt.SigningMethodHS256)
+	session.Claims.(jwt.MapClaims)["state"] = "state"
+	session.Claims.(jwt.MapClaims)["expire"] = time.Now().Add(-time.Minute).Unix()
+	sessionString, err := session.SignedString([]byte(ctx.GetAuthenticator().SignatureKey))
+	require.NoError(t, err, "unable to sign session")
+
+	rr := oidcCallback(t, ctx, &http.Cookie{Name: oidcSessionCookieName, Value: sessionString}, "state")
+	context.TestInvalidParameter(t, rr, "OpenID Connect session cookie")
+}
+
+func TestOIDCCallbackMissingCode(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.GetConfig().OIDCAuthentication = true
+
+	req, err := http.NewRequest("GET", "/auth/oidc/callback", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	OIDCCallback(ctx, rr, req)
+	context.TestMissingParameter(t, rr, "oauth2 authorization code")
+}
+
+func TestGetOIDCClaim(t *testing.T) {
+	claims := map[string]interface{}{
+		"login":                      "plik",
+		"https://root.gg/groups":     []interface{}{"users", 42, "admins"},
+		"realm_access":               map[string]interface{}{"roles": []interface{}{"admins"}},
+		"admin":                      true,
+		"resource_access.plik.roles": "flat",
+	}
+
+	require.Equal(t, "plik", getOIDCClaimString(claims, "login"))
+	require.Equal(t, "", getOIDCClaimString(claims, "missing"))
+	require.Equal(t, "", getOIDCClaimString(claims, ""))
+	require.Equal(t, []string{"users", "admins"}, getOIDCClaimStrings(claims, "https://root.gg/groups"))
+	require.Equal(t, []string{"admins"}, getOIDCClaimStrings(claims, "realm_access.roles"))
+	require.Equal(t, []string{"flat"}, getOIDCClaimStrings(claims, "resource_access.plik.roles"))
+	require.Nil(t, getOIDCClaimStrings(claims, "login.missing"))
+
+	config := common.NewConfiguration()
+	require.False(t, isOIDCAdmin(config, claims))
+
+	config.OIDCAdminClaim = "admin"
+	require.True(t, isOIDCAdmin(config, claims))
+
+	config.OIDCAdminClaim = "realm_access.roles"
+	require.False(t, isOIDCAdmin(config, claims))
+
+	config.OIDCAdminValue = "admins"
+	require.True(t, isOIDCAdmin(config, claims))
+}
@@ -245,7 +245,10 @@ func OvhCallback(ctx *context.Context, resp http.ResponseWriter, req *http.Reque
 	}
 
 	// Get user from metadata backend
-	user, err := ctx.GetMetadataBackend().GetUser(common.GetUserID(common.ProviderOVH, userInfo.Nichandle))
+	userID := common.GetUserID(common.ProviderOVH, userInfo.Nichandle)
+	ctx.SetAuditUser(userID)
+
+	user, err := ctx.GetMetadataBackend().GetUser(userID)
 	if err != nil {
 		ctx.InternalServerError("unable to get user from metadata backend", err)
 		return
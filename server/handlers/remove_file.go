@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/data"
 )
 
 // RemoveFile remove a file from an existing upload
@@ -62,6 +63,11 @@ func purge(ctx *context.Context, file *common.File) (err error) {
 		return fmt.Errorf("unable to delete file %s from upload %s : %s", file.ID, file.UploadID, err)
 	}
 
+	err = data.RemoveChunks(ctx.GetDataBackend(), file)
+	if err != nil {
+		return fmt.Errorf("unable to delete file %s chunks from upload %s : %s", file.ID, file.UploadID, err)
+	}
+
 	err = ctx.GetMetadataBackend().UpdateFileStatus(file, common.FileRemoved, common.FileDeleted)
 	if err != nil {
 		return fmt.Errorf("unable to update file status for deleted file %s from upload %s : %s", file.ID, file.UploadID, err)
@@ -49,6 +49,36 @@ func CreateToken(ctx *context.Context, resp http.ResponseWriter, req *http.Reque
 	// Generate token uuid and set creation date
 	token.Initialize()
 	token.UserID = user.ID
+	token.LastUsedAt = nil
+
+	// Check scopes and expiration date
+	err = token.Validate()
+	if err != nil {
+		ctx.BadRequest(err.Error())
+		return
+	}
+
+	// A token can't create a token granting more than itself
+	if parent := ctx.GetToken(); parent != nil {
+		if token.Scope == "" {
+			token.Scope = parent.Scope
+		}
+		for _, scope := range token.GetScopes() {
+			if !parent.HasScope(scope) {
+				ctx.Forbidden("token does not have the %s scope", scope)
+				return
+			}
+		}
+
+		if parent.ExpireAt != nil {
+			if token.ExpireAt == nil {
+				token.ExpireAt = parent.ExpireAt
+			} else if token.ExpireAt.After(*parent.ExpireAt) {
+				ctx.Forbidden("token can't expire after the token creating it")
+				return
+			}
+		}
+	}
 
 	// Save token
 	err = ctx.GetMetadataBackend().CreateToken(token)
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"time"
 
 	"testing"
@@ -88,6 +89,167 @@ func TestCreateTokenWithForbiddenOptions(t *testing.T) {
 	require.Equal(t, token.Comment, tokenResult.Comment, "invalid token comment")
 }
 
+func TestCreateTokenWithScopeAndExpiration(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to add user")
+	ctx.SetUser(user)
+
+	expireAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := common.NewToken()
+	token.Scope = " upload  read "
+	token.ExpireAt = &expireAt
+	token.LastUsedAt = &expireAt
+
+	reqBody, err := json.Marshal(token)
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/me/token", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateToken(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var tokenResult = &common.Token{}
+	err = json.Unmarshal(respBody, tokenResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.Equal(t, "upload read", tokenResult.Scope, "invalid token scope")
+	require.NotNil(t, tokenResult.ExpireAt, "missing token expiration date")
+	require.True(t, expireAt.Equal(*tokenResult.ExpireAt), "invalid token expiration date")
+	require.Nil(t, tokenResult.LastUsedAt, "token last usage date should not be set")
+}
+
+func TestCreateTokenFromTokenScope(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to add user")
+	ctx.SetUser(user)
+	ctx.SetToken(&common.Token{Scope: "read admin"})
+
+	createToken := func(token *common.Token) *httptest.ResponseRecorder {
+		reqBody, err := json.Marshal(token)
+		require.NoError(t, err, "unable to marshal request body")
+
+		req, err := http.NewRequest("POST", "/me/token", bytes.NewBuffer(reqBody))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		CreateToken(ctx, rr, req)
+		return rr
+	}
+
+	rr := createToken(&common.Token{Scope: "read upload"})
+	context.TestForbidden(t, rr, "token does not have the upload scope")
+
+	rr = createToken(&common.Token{Scope: "read"})
+	context.TestOK(t, rr)
+
+	// Tokens without scope inherit the scopes of the token creating them
+	rr = createToken(&common.Token{})
+	context.TestOK(t, rr)
+
+	var tokenResult = &common.Token{}
+	err = json.Unmarshal(rr.Body.Bytes(), tokenResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, "read admin", tokenResult.Scope, "invalid token scope")
+}
+
+func TestCreateTokenFromTokenExpiration(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to add user")
+	ctx.SetUser(user)
+
+	deadline := time.Now().Add(time.Hour).Truncate(time.Second)
+	ctx.SetToken(&common.Token{Scope: "admin", ExpireAt: &deadline})
+
+	createToken := func(token *common.Token) *httptest.ResponseRecorder {
+		reqBody, err := json.Marshal(token)
+		require.NoError(t, err, "unable to marshal request body")
+
+		req, err := http.NewRequest("POST", "/me/token", bytes.NewBuffer(reqBody))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		CreateToken(ctx, rr, req)
+		return rr
+	}
+
+	later := deadline.Add(time.Minute)
+	rr := createToken(&common.Token{ExpireAt: &later})
+	context.TestForbidden(t, rr, "token can't expire after the token creating it")
+
+	sooner := deadline.Add(-time.Minute)
+	rr = createToken(&common.Token{ExpireAt: &sooner})
+	context.TestOK(t, rr)
+
+	// Tokens without expiration date expire with the token creating them
+	rr = createToken(&common.Token{})
+	context.TestOK(t, rr)
+
+	var tokenResult = &common.Token{}
+	err = json.Unmarshal(rr.Body.Bytes(), tokenResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.NotNil(t, tokenResult.ExpireAt, "missing token expiration date")
+	require.True(t, deadline.Equal(*tokenResult.ExpireAt), "invalid token expiration date")
+}
+
+func TestCreateTokenInvalidScope(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to add user")
+	ctx.SetUser(user)
+
+	token := common.NewToken()
+	token.Scope = "upload foo"
+
+	reqBody, err := json.Marshal(token)
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/me/token", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateToken(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid token scope foo")
+}
+
+func TestCreateTokenExpired(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to add user")
+	ctx.SetUser(user)
+
+	expireAt := time.Now().Add(-time.Hour)
+	token := common.NewToken()
+	token.ExpireAt = &expireAt
+
+	reqBody, err := json.Marshal(token)
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/me/token", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateToken(ctx, rr, req)
+	context.TestBadRequest(t, rr, "token expiration date is in the past")
+}
+
 func TestCreateTokenMissingUser(t *testing.T) {
 	config := common.NewConfiguration()
 	ctx := newTestingContext(config)
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// CreateUploadRequest create a new upload request link and the upload the files will be added to
+func CreateUploadRequest(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	if !ctx.IsWhitelisted() {
+		ctx.Forbidden("untrusted source IP address")
+		return
+	}
+
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest("unable to read request body : %s", err)
+		return
+	}
+
+	// Deserialize json body
+	params := &common.UploadRequest{}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, params)
+		if err != nil {
+			ctx.BadRequest("unable to deserialize request body : %s", err)
+			return
+		}
+	}
+
+	request, err := common.CreateUploadRequestFromParams(params, ctx.GetMaxFileSize())
+	if err != nil {
+		ctx.BadRequest("unable to create upload request : %s", err)
+		return
+	}
+
+	// The files sent through the upload request are added to an upload owned by the requester
+	// so the requester limits ( ttl, quota, ... ) apply
+	upload, err := ctx.CreateUpload(&common.Upload{TTL: request.TTL, Comments: request.Comment})
+	if err != nil {
+		ctx.BadRequest("unable to create upload : %s", err)
+		return
+	}
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	if err != nil {
+		ctx.InternalServerError("create upload error", err)
+		return
+	}
+
+	ctx.EmitWebhookEvent(common.EventUploadCreated, upload, nil)
+
+	request.UserID = user.ID
+	request.UploadID = upload.ID
+	request.TTL = upload.TTL
+	request.ExpireAt = upload.ExpireAt
+
+	err = ctx.GetMetadataBackend().CreateUploadRequest(request)
+	if err != nil {
+		ctx.InternalServerError("unable to create upload request", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, request)
+}
+
+// GetUploadRequest return the public information of an upload request to the senders
+func GetUploadRequest(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get upload request from context
+	request := ctx.GetUploadRequest()
+	if request == nil {
+		panic("missing upload request from context")
+	}
+
+	// Senders must not be able to access the upload
+	request.Sanitize()
+
+	common.WriteJSONResponse(resp, request)
+}
+
+// GetUserUploadRequests return the upload requests of the user
+func GetUserUploadRequests(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	pagingQuery := ctx.GetPagingQuery()
+
+	requests, cursor, err := ctx.GetMetadataBackend().GetUploadRequests(user.ID, pagingQuery)
+	if err != nil {
+		ctx.InternalServerError("unable to get user upload requests", err)
+		return
+	}
+
+	pagingResponse := common.NewPagingResponse(requests, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
+// DeleteUploadRequest remove an upload request link, the files already received are kept
+func DeleteUploadRequest(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	// Get upload request to remove from URL params
+	vars := mux.Vars(req)
+	requestID, ok := vars["requestID"]
+	if !ok || requestID == "" {
+		ctx.MissingParameter("upload request id")
+		return
+	}
+
+	request, err := ctx.GetMetadataBackend().GetUploadRequest(requestID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload request", err)
+		return
+	}
+
+	if request == nil || request.UserID != user.ID {
+		ctx.NotFound("upload request not found")
+		return
+	}
+
+	_, err = ctx.GetMetadataBackend().DeleteUploadRequest(request.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete upload request", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createTestUploadRequest(t *testing.T, ctx *context.Context, request *common.UploadRequest) (user *common.User, upload *common.Upload) {
+	user = common.NewUser(common.ProviderLocal, "requester")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "create user error")
+
+	upload = &common.Upload{User: user.ID}
+	upload.InitializeForTests()
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "create upload error")
+
+	if request.ID == "" {
+		request.ID = common.GenerateRandomID(16)
+		request.Token = common.GenerateRandomID(32)
+	}
+	request.UserID = user.ID
+	request.UploadID = upload.ID
+	err = ctx.GetMetadataBackend().CreateUploadRequest(request)
+	require.NoError(t, err, "create upload request error")
+
+	ctx.SetUpload(upload)
+	ctx.SetUploadRequest(request)
+
+	return user, upload
+}
+
+func getUploadRequestFileRequest(t *testing.T, request *common.UploadRequest, name string, data string) *http.Request {
+	reader, contentType, err := getMultipartFormData(name, bytes.NewBuffer([]byte(data)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/request/"+request.ID+"/file", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	return req
+}
+
+func TestCreateUploadRequest(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+
+	params := &common.UploadRequest{Comment: "please send me the files", MaxFiles: 2, MaxSize: 1000, TTL: 3600}
+	body, err := json.Marshal(params)
+	require.NoError(t, err, "unable to marshal upload request params")
+
+	req, err := http.NewRequest("POST", "/me/request", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadRequest(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var result = &common.UploadRequest{}
+	err = json.Unmarshal(respBody, result)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.NotEmpty(t, result.ID, "missing upload request id")
+	require.NotEmpty(t, result.Token, "missing upload request token")
+	require.NotEmpty(t, result.UploadID, "missing upload request upload id")
+	require.Equal(t, params.Comment, result.Comment, "invalid comment")
+	require.Equal(t, params.MaxFiles, result.MaxFiles, "invalid max files")
+	require.Equal(t, params.MaxSize, result.MaxSize, "invalid max size")
+	require.Equal(t, params.TTL, result.TTL, "invalid ttl")
+	require.NotNil(t, result.ExpireAt, "missing expiration date")
+
+	request, err := ctx.GetMetadataBackend().GetUploadRequest(result.ID)
+	require.NoError(t, err, "unable to get upload request")
+	require.NotNil(t, request, "missing upload request")
+	require.Equal(t, user.ID, request.UserID, "invalid upload request user")
+
+	upload, err := ctx.GetMetadataBackend().GetUpload(result.UploadID)
+	require.NoError(t, err, "unable to get upload")
+	require.NotNil(t, upload, "missing upload")
+	require.Equal(t, user.ID, upload.User, "invalid upload user")
+	require.Equal(t, params.Comment, upload.Comments, "invalid upload comments")
+}
+
+func TestCreateUploadRequestNoUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("POST", "/me/request", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadRequest(ctx, rr, req)
+	context.TestUnauthorized(t, rr, "missing user, please login first")
+}
+
+func TestCreateUploadRequestInvalidParams(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+
+	req, err := http.NewRequest("POST", "/me/request", bytes.NewBuffer([]byte(`{"maxFiles":-1}`)))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadRequest(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid max files")
+}
+
+func TestCreateUploadRequestInvalidTTL(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxTTL = 60
+	ctx := newTestingContext(config)
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+
+	req, err := http.NewRequest("POST", "/me/request", bytes.NewBuffer([]byte(`{"ttl":3600}`)))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadRequest(ctx, rr, req)
+	context.TestBadRequest(t, rr, "unable to create upload")
+}
+
+func TestGetUploadRequest(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	request := &common.UploadRequest{Comment: "comment"}
+	createTestUploadRequest(t, ctx, request)
+
+	req, err := http.NewRequest("GET", "/request/"+request.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetUploadRequest(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var result = &common.UploadRequest{}
+	err = json.Unmarshal(respBody, result)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.Equal(t, request.ID, result.ID, "invalid upload request id")
+	require.Equal(t, "comment", result.Comment, "invalid upload request comment")
+	require.Empty(t, result.Token, "upload request token should not be disclosed")
+	require.Empty(t, result.UploadID, "upload request upload id should not be disclosed")
+}
+
+func TestGetUserUploadRequests(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user, _ := createTestUploadRequest(t, ctx, &common.UploadRequest{})
+
+	other := common.NewUploadRequest()
+	other.UserID = "other"
+	err := ctx.GetMetadataBackend().CreateUploadRequest(other)
+	require.NoError(t, err, "create upload request error")
+
+	ctx.SetUser(user)
+	ctx.SetPagingQuery(common.NewPagingQuery().WithLimit(10))
+
+	req, err := http.NewRequest("GET", "/me/request", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetUserUploadRequests(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var response common.PagingResponse
+	err = json.Unmarshal(respBody, &response)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Len(t, response.Results, 1, "invalid upload requests count")
+}
+
+func TestDeleteUploadRequest(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	request := &common.UploadRequest{}
+	user, upload := createTestUploadRequest(t, ctx, request)
+	ctx.SetUser(user)
+
+	req, err := http.NewRequest("DELETE", "/me/request/"+request.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"requestID": request.ID})
+
+	rr := ctx.NewRecorder(req)
+	DeleteUploadRequest(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetUploadRequest(request.ID)
+	require.NoError(t, err, "unable to get upload request")
+	require.Nil(t, result, "upload request should have been deleted")
+
+	// The upload is kept
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.NotNil(t, u, "upload should not have been deleted")
+}
+
+func TestDeleteUploadRequestNotOwner(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	request := &common.UploadRequest{}
+	createTestUploadRequest(t, ctx, request)
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "other"))
+
+	req, err := http.NewRequest("DELETE", "/me/request/"+request.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"requestID": request.ID})
+
+	rr := ctx.NewRecorder(req)
+	DeleteUploadRequest(ctx, rr, req)
+	context.TestNotFound(t, rr, "upload request not found")
+}
+
+func TestAddFileUploadRequest(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	request := &common.UploadRequest{MaxFiles: 2}
+	_, upload := createTestUploadRequest(t, ctx, request)
+
+	req := getUploadRequestFileRequest(t, request, "file", content)
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var fileResult = &common.File{}
+	err = json.Unmarshal(respBody, fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, common.FileUploaded, fileResult.Status, "invalid file status")
+
+	files, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+	require.NoError(t, err, "unable to get upload files")
+	require.Len(t, files, 1, "invalid upload files count")
+
+	result, err := ctx.GetMetadataBackend().GetUploadRequest(request.ID)
+	require.NoError(t, err, "unable to get upload request")
+	require.Equal(t, 1, result.Files, "invalid upload request files")
+	require.Equal(t, int64(len(content)), result.Size, "invalid upload request size")
+	require.NotNil(t, result.LastUsedAt, "missing upload request last usage date")
+}
+
+func TestAddFileUploadRequestNotification(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	newTestingNotifier(t, ctx)
+
+	request := &common.UploadRequest{Comment: "your documents please"}
+	user, _ := createTestUploadRequest(t, ctx, request)
+
+	user.Email = "requester@root.gg"
+	err := ctx.GetMetadataBackend().UpdateUser(user)
+	require.NoError(t, err, "unable to update user")
+
+	req := getUploadRequestFileRequest(t, request, "file", content)
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	emails := getTestEmails(t, ctx)
+	require.Len(t, emails, 1, "invalid email count")
+	require.Equal(t, user.Email, emails[0].To, "invalid email recipient")
+	require.Contains(t, emails[0].Text, request.Comment, "missing upload request comment")
+	require.NotContains(t, emails[0].Text, request.Token, "upload request token should not be sent")
+}
+
+func TestAddFileUploadRequestQuick(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetQuick(true)
+
+	request := &common.UploadRequest{}
+	_, upload := createTestUploadRequest(t, ctx, request)
+
+	req := getUploadRequestFileRequest(t, request, "file", content)
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := io.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.NotContains(t, string(respBody), upload.ID, "upload id should not be disclosed")
+}
+
+func TestAddFileUploadRequestFull(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	request := &common.UploadRequest{MaxFiles: 1, Files: 1}
+	createTestUploadRequest(t, ctx, request)
+
+	req := getUploadRequestFileRequest(t, request, "file", content)
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestBadRequest(t, rr, "upload request is full")
+}
+
+func TestAddFileUploadRequestTooBig(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	request := &common.UploadRequest{MaxFileSize: 5}
+	createTestUploadRequest(t, ctx, request)
+
+	req := getUploadRequestFileRequest(t, request, "file", content)
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestBadRequest(t, rr, "file too big")
+
+	// The reserved slot is released
+	result, err := ctx.GetMetadataBackend().GetUploadRequest(request.ID)
+	require.NoError(t, err, "unable to get upload request")
+	require.Equal(t, 0, result.Files, "invalid upload request files")
+	require.Equal(t, int64(0), result.Size, "invalid upload request size")
+}
+
+func TestAddFileUploadRequestExistingFile(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	request := &common.UploadRequest{}
+	_, upload := createTestUploadRequest(t, ctx, request)
+
+	file := upload.NewFile()
+	file.Name = "file"
+	err := ctx.GetMetadataBackend().CreateFile(file)
+	require.NoError(t, err, "unable to create file")
+	ctx.SetFile(file)
+
+	req := getUploadRequestFileRequest(t, request, "file", content)
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not allowed to add file to this upload")
+}
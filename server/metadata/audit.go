@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CreateAuditEvent persist a new audit event to the database
+func (b *Backend) CreateAuditEvent(event *common.AuditEvent) (err error) {
+	return b.db.Create(event).Error
+}
+
+// GetAuditEvents return audit events from DB
+// userID, uploadID and action are optional filters
+func (b *Backend) GetAuditEvents(userID string, uploadID string, action string, pagingQuery *common.PagingQuery) (events []*common.AuditEvent, cursor *paginator.Cursor, err error) {
+	if pagingQuery == nil {
+		return nil, nil, fmt.Errorf("missing paging query")
+	}
+
+	stmt := b.db.Model(&common.AuditEvent{}).Where(&common.AuditEvent{UserID: userID, UploadID: uploadID, Action: action})
+
+	p := pagingQuery.Paginator()
+	p.SetKeys("CreatedAt", "ID")
+
+	result, c, err := p.Paginate(stmt, &events)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	return events, &c, err
+}
+
+// DeleteAuditEventsBefore remove the audit events older than the given date
+func (b *Backend) DeleteAuditEventsBefore(date time.Time) (deleted int, err error) {
+	result := b.db.Where("created_at < ?", date).Delete(&common.AuditEvent{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+// ForEachAuditEvent execute f for every audit event in the database
+func (b *Backend) ForEachAuditEvent(f func(event *common.AuditEvent) error) (err error) {
+	rows, err := b.db.Model(&common.AuditEvent{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		event := &common.AuditEvent{}
+		err = b.db.ScanRows(rows, event)
+		if err != nil {
+			return err
+		}
+		err = f(event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func createAuditEvent(t *testing.T, b *Backend, action string, userID string, uploadID string, date time.Time) *common.AuditEvent {
+	event := common.NewAuditEvent(action)
+	event.UserID = userID
+	event.UploadID = uploadID
+	event.SourceIP = "1.3.3.7"
+	event.CreatedAt = date
+
+	err := b.CreateAuditEvent(event)
+	require.NoError(t, err, "create audit event error")
+
+	return event
+}
+
+func TestBackend_CreateAuditEvent(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	event := createAuditEvent(t, b, common.AuditLogin, "user", "", time.Now())
+	require.NotZero(t, event.ID, "missing audit event id")
+	require.Equal(t, common.AuditSuccess, event.Result, "invalid audit event result")
+}
+
+func TestBackend_GetAuditEvents(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	createAuditEvent(t, b, common.AuditUpload, "user1", "upload1", time.Now())
+	createAuditEvent(t, b, common.AuditDownload, "", "upload1", time.Now())
+	createAuditEvent(t, b, common.AuditDownload, "user2", "upload1", time.Now())
+	createAuditEvent(t, b, common.AuditLogin, "user1", "", time.Now())
+
+	events, cursor, err := b.GetAuditEvents("", "", "", common.NewPagingQuery().WithLimit(100))
+	require.NoError(t, err, "get audit events error")
+	require.NotNil(t, cursor, "invalid nil cursor")
+	require.Len(t, events, 4, "invalid audit events count")
+
+	events, _, err = b.GetAuditEvents("user1", "", "", common.NewPagingQuery().WithLimit(100))
+	require.NoError(t, err, "get audit events error")
+	require.Len(t, events, 2, "invalid audit events count")
+
+	events, _, err = b.GetAuditEvents("", "upload1", common.AuditDownload, common.NewPagingQuery().WithLimit(100))
+	require.NoError(t, err, "get audit events error")
+	require.Len(t, events, 2, "invalid audit events count")
+
+	events, cursor, err = b.GetAuditEvents("", "", "", common.NewPagingQuery().WithLimit(3))
+	require.NoError(t, err, "get audit events error")
+	require.Len(t, events, 3, "invalid audit events count")
+	require.NotNil(t, cursor.After, "missing after cursor")
+
+	_, _, err = b.GetAuditEvents("", "", "", nil)
+	require.Error(t, err, "get audit events error expected")
+}
+
+func TestBackend_DeleteAuditEventsBefore(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	createAuditEvent(t, b, common.AuditLogin, "user", "", time.Now().Add(-48*time.Hour))
+	createAuditEvent(t, b, common.AuditLogin, "user", "", time.Now().Add(-36*time.Hour))
+	event := createAuditEvent(t, b, common.AuditLogin, "user", "", time.Now())
+
+	deleted, err := b.DeleteAuditEventsBefore(time.Now().Add(-24 * time.Hour))
+	require.NoError(t, err, "delete audit events error")
+	require.Equal(t, 2, deleted, "invalid deleted audit events count")
+
+	var events []*common.AuditEvent
+	err = b.ForEachAuditEvent(func(event *common.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	require.NoError(t, err, "for each audit event error")
+	require.Len(t, events, 1, "invalid audit events count")
+	require.Equal(t, event.ID, events[0].ID, "invalid audit event")
+}
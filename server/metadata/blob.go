@@ -0,0 +1,254 @@
+package metadata
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CreateBlob create a new blob in DB
+func (b *Backend) CreateBlob(blob *common.Blob) (err error) {
+	return b.db.Create(blob).Error
+}
+
+// CreateBlobReference create a new blob reference in DB
+func (b *Backend) CreateBlobReference(reference *common.BlobReference) (err error) {
+	return b.db.Create(reference).Error
+}
+
+// GetBlob return a blob from the DB ( return nil and no error if not found )
+func (b *Backend) GetBlob(blobID string) (blob *common.Blob, err error) {
+	blob = &common.Blob{}
+	err = b.db.Where(&common.Blob{ID: blobID}).Take(blob).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return blob, nil
+}
+
+// GetFileBlob return the blob referenced by a file ( return nil and no error if not found )
+func (b *Backend) GetFileBlob(fileID string) (blob *common.Blob, err error) {
+	reference := &common.BlobReference{}
+	err = b.db.Where(&common.BlobReference{FileID: fileID}).Take(reference).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return b.GetBlob(reference.BlobID)
+}
+
+// AddBlobReference link a file to a blob and increment the blob reference count.
+// The blob is created if it does not exist yet, in this case created is true.
+// The blob content must already be stored in the data backend under blob.DataID so that
+// the blob is never visible without its content. If created is false the blob was
+// already stored and the caller is responsible for deleting its own copy of the content.
+func (b *Backend) AddBlobReference(fileID string, blob *common.Blob) (created bool, err error) {
+	err = b.db.Transaction(func(tx *gorm.DB) (err error) {
+		created = false
+
+		found, err := referenceBlob(tx, fileID, blob.ID)
+		if err != nil || found {
+			return err
+		}
+
+		blob.RefCount = 1
+		err = tx.Create(blob).Error
+		if err != nil {
+			return fmt.Errorf("unable to create blob : %s", err)
+		}
+
+		err = tx.Create(&common.BlobReference{FileID: fileID, BlobID: blob.ID}).Error
+		if err != nil {
+			return fmt.Errorf("unable to create blob reference : %s", err)
+		}
+		created = true
+
+		return nil
+	})
+
+	return created, err
+}
+
+// ReferenceBlob link a file to an existing blob and increment the blob reference count.
+// A nil blob is returned if the blob does not exist.
+func (b *Backend) ReferenceBlob(fileID string, blobID string) (blob *common.Blob, err error) {
+	err = b.db.Transaction(func(tx *gorm.DB) (err error) {
+		blob = nil
+
+		found, err := referenceBlob(tx, fileID, blobID)
+		if err != nil || !found {
+			return err
+		}
+
+		blob = &common.Blob{}
+		err = tx.Where(&common.Blob{ID: blobID}).Take(blob).Error
+		if err != nil {
+			return fmt.Errorf("unable to get blob %s : %s", blobID, err)
+		}
+
+		return nil
+	})
+
+	return blob, err
+}
+
+// referenceBlob increment the reference count of a blob and link the file to it.
+// Nothing is changed and found is false if the blob does not exist.
+func referenceBlob(tx *gorm.DB, fileID string, blobID string) (found bool, err error) {
+	result := tx.Model(&common.Blob{}).Where(&common.Blob{ID: blobID}).Update("ref_count", gorm.Expr("ref_count + 1"))
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to update blob references : %s", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	err = tx.Create(&common.BlobReference{FileID: fileID, BlobID: blobID}).Error
+	if err != nil {
+		return false, fmt.Errorf("unable to create blob reference : %s", err)
+	}
+
+	return true, nil
+}
+
+// UpdateBlobBackendDetails update a blob backend details in DB
+func (b *Backend) UpdateBlobBackendDetails(blob *common.Blob) (err error) {
+	result := b.db.Model(&common.Blob{}).Where(&common.Blob{ID: blob.ID}).Update("backend_details", blob.BackendDetails)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("blob not found")
+	}
+
+	return nil
+}
+
+// RemoveBlobReference unlink a file from its blob and decrement the blob reference count.
+// When the last reference goes away the blob is deleted from the DB and deleted is true,
+// the caller is then responsible for deleting the blob content from the data backend.
+// A nil blob is returned if the file was not referencing any blob.
+func (b *Backend) RemoveBlobReference(fileID string) (blob *common.Blob, deleted bool, err error) {
+	err = b.db.Transaction(func(tx *gorm.DB) (err error) {
+		blob = nil
+		deleted = false
+
+		reference := &common.BlobReference{}
+		err = tx.Where(&common.BlobReference{FileID: fileID}).Take(reference).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		result := tx.Delete(&common.BlobReference{FileID: fileID})
+		if result.Error != nil {
+			return fmt.Errorf("unable to delete blob reference : %s", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			// The reference has been removed concurrently
+			return nil
+		}
+
+		err = tx.Model(&common.Blob{}).Where(&common.Blob{ID: reference.BlobID}).Update("ref_count", gorm.Expr("ref_count - 1")).Error
+		if err != nil {
+			return fmt.Errorf("unable to update blob references : %s", err)
+		}
+
+		blob = &common.Blob{}
+		err = tx.Where(&common.Blob{ID: reference.BlobID}).Take(blob).Error
+		if err != nil {
+			return fmt.Errorf("unable to get blob %s : %s", reference.BlobID, err)
+		}
+
+		// The reference count is checked by the delete statement itself so that
+		// a blob referenced again concurrently is never deleted
+		result = tx.Where("id = ? AND ref_count <= 0", blob.ID).Delete(&common.Blob{})
+		if result.Error != nil {
+			return fmt.Errorf("unable to delete blob : %s", result.Error)
+		}
+		deleted = result.RowsAffected > 0
+
+		return nil
+	})
+
+	return blob, deleted, err
+}
+
+// ForEachBlob execute f for every blob in the database
+func (b *Backend) ForEachBlob(f func(blob *common.Blob) error) (err error) {
+	rows, err := b.db.Model(&common.Blob{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		blob := &common.Blob{}
+		err = b.db.ScanRows(rows, blob)
+		if err != nil {
+			return err
+		}
+		err = f(blob)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForEachBlobBatch execute f for every blob in the database, ordered by ID.
+// The blobs are loaded by batches of batchSize so unlike ForEachBlob f can update the database
+func (b *Backend) ForEachBlobBatch(batchSize int, f func(blob *common.Blob) error) (err error) {
+	after := ""
+	for {
+		var blobs []*common.Blob
+		err = b.db.Where("id > ?", after).Order("id").Limit(batchSize).Find(&blobs).Error
+		if err != nil {
+			return err
+		}
+
+		for _, blob := range blobs {
+			err = f(blob)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(blobs) < batchSize {
+			return nil
+		}
+		after = blobs[len(blobs)-1].ID
+	}
+}
+
+// ForEachBlobReference execute f for every blob reference in the database
+func (b *Backend) ForEachBlobReference(f func(reference *common.BlobReference) error) (err error) {
+	rows, err := b.db.Model(&common.BlobReference{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		reference := &common.BlobReference{}
+		err = b.db.ScanRows(rows, reference)
+		if err != nil {
+			return err
+		}
+		err = f(reference)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package metadata
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestBackend_AddBlobReference(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	blob := &common.Blob{ID: "hash", Size: 42}
+	created, err := b.AddBlobReference("file1", blob)
+	require.NoError(t, err, "add blob reference error")
+	require.True(t, created, "blob should have been created")
+
+	created, err = b.AddBlobReference("file2", &common.Blob{ID: "hash", Size: 42})
+	require.NoError(t, err, "add blob reference error")
+	require.False(t, created, "blob should not have been created")
+
+	blob, err = b.GetBlob("hash")
+	require.NoError(t, err, "get blob error")
+	require.NotNil(t, blob, "missing blob")
+	require.Equal(t, 2, blob.RefCount, "invalid blob reference count")
+	require.Equal(t, int64(42), blob.Size, "invalid blob size")
+
+	_, err = b.AddBlobReference("file1", &common.Blob{ID: "hash", Size: 42})
+	require.Error(t, err, "add blob reference error expected")
+}
+
+func TestBackend_UpdateBlobBackendDetails(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	blob := &common.Blob{ID: "hash"}
+	_, err := b.AddBlobReference("file1", blob)
+	require.NoError(t, err, "add blob reference error")
+
+	blob.BackendDetails = "details"
+	err = b.UpdateBlobBackendDetails(blob)
+	require.NoError(t, err, "update blob backend details error")
+
+	blob, err = b.GetBlob("hash")
+	require.NoError(t, err, "get blob error")
+	require.NotNil(t, blob, "missing blob")
+	require.Equal(t, "details", blob.BackendDetails, "invalid blob backend details")
+	require.Equal(t, 1, blob.RefCount, "invalid blob reference count")
+
+	err = b.UpdateBlobBackendDetails(&common.Blob{ID: "missing"})
+	require.Error(t, err, "update blob backend details error expected")
+}
+
+func TestBackend_GetFileBlob(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	blob, err := b.GetFileBlob("file1")
+	require.NoError(t, err, "get file blob error")
+	require.Nil(t, blob, "blob should be nil")
+
+	_, err = b.AddBlobReference("file1", &common.Blob{ID: "hash"})
+	require.NoError(t, err, "add blob reference error")
+
+	blob, err = b.GetFileBlob("file1")
+	require.NoError(t, err, "get file blob error")
+	require.NotNil(t, blob, "missing blob")
+	require.Equal(t, "hash", blob.ID, "invalid blob id")
+}
+
+func TestBackend_RemoveBlobReference(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	blob, deleted, err := b.RemoveBlobReference("file1")
+	require.NoError(t, err, "remove blob reference error")
+	require.Nil(t, blob, "blob should be nil")
+	require.False(t, deleted, "blob should not have been deleted")
+
+	_, err = b.AddBlobReference("file1", &common.Blob{ID: "hash"})
+	require.NoError(t, err, "add blob reference error")
+	_, err = b.AddBlobReference("file2", &common.Blob{ID: "hash"})
+	require.NoError(t, err, "add blob reference error")
+
+	blob, deleted, err = b.RemoveBlobReference("file1")
+	require.NoError(t, err, "remove blob reference error")
+	require.NotNil(t, blob, "missing blob")
+	require.Equal(t, 1, blob.RefCount, "invalid blob reference count")
+	require.False(t, deleted, "blob should not have been deleted")
+
+	blob, deleted, err = b.RemoveBlobReference("file2")
+	require.NoError(t, err, "remove blob reference error")
+	require.NotNil(t, blob, "missing blob")
+	require.True(t, deleted, "blob should have been deleted")
+
+	blob, err = b.GetBlob("hash")
+	require.NoError(t, err, "get blob error")
+	require.Nil(t, blob, "blob should have been deleted")
+}
+
+func TestBackend_ForEachBlob(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	_, err := b.AddBlobReference("file1", &common.Blob{ID: "hash"})
+	require.NoError(t, err, "add blob reference error")
+
+	count := 0
+	err = b.ForEachBlob(func(blob *common.Blob) error {
+		count++
+		require.Equal(t, "hash", blob.ID, "invalid blob id")
+		return nil
+	})
+	require.NoError(t, err, "for each blob error")
+	require.Equal(t, 1, count, "invalid blob count")
+
+	count = 0
+	err = b.ForEachBlobReference(func(reference *common.BlobReference) error {
+		count++
+		require.Equal(t, "file1", reference.FileID, "invalid blob reference file id")
+		return nil
+	})
+	require.NoError(t, err, "for each blob reference error")
+	require.Equal(t, 1, count, "invalid blob reference count")
+}
+
+func TestBackend_ReferenceBlob(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	blob, err := b.ReferenceBlob("file1", "hash")
+	require.NoError(t, err, "reference blob error")
+	require.Nil(t, blob, "blob should be nil")
+
+	reference, err := b.GetFileBlob("file1")
+	require.NoError(t, err, "get file blob error")
+	require.Nil(t, reference, "missing blobs must not be referenced")
+
+	_, err = b.AddBlobReference("file1", &common.Blob{ID: "hash", DataID: "hash.data"})
+	require.NoError(t, err, "add blob reference error")
+
+	blob, err = b.ReferenceBlob("file2", "hash")
+	require.NoError(t, err, "reference blob error")
+	require.NotNil(t, blob, "missing blob")
+	require.Equal(t, "hash.data", blob.DataID, "invalid blob data id")
+	require.Equal(t, 2, blob.RefCount, "invalid blob reference count")
+
+	_, err = b.ReferenceBlob("file2", "hash")
+	require.Error(t, err, "reference blob error expected")
+
+	blob, err = b.GetBlob("hash")
+	require.NoError(t, err, "get blob error")
+	require.Equal(t, 2, blob.RefCount, "failed references must not change the reference count")
+}
+
+func TestBackend_RemoveBlobReferenceTwice(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	_, err := b.AddBlobReference("file1", &common.Blob{ID: "hash"})
+	require.NoError(t, err, "add blob reference error")
+	_, err = b.AddBlobReference("file2", &common.Blob{ID: "hash"})
+	require.NoError(t, err, "add blob reference error")
+
+	_, _, err = b.RemoveBlobReference("file1")
+	require.NoError(t, err, "remove blob reference error")
+
+	blob, deleted, err := b.RemoveBlobReference("file1")
+	require.NoError(t, err, "remove blob reference error")
+	require.Nil(t, blob, "blob should be nil")
+	require.False(t, deleted, "blob should not have been deleted")
+
+	blob, err = b.GetBlob("hash")
+	require.NoError(t, err, "get blob error")
+	require.NotNil(t, blob, "missing blob")
+	require.Equal(t, 1, blob.RefCount, "invalid blob reference count")
+}
+
+func TestBackend_ForEachBlobBatch(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	for i := 0; i < 5; i++ {
+		_, err := b.AddBlobReference(fmt.Sprintf("file%d", i), &common.Blob{ID: fmt.Sprintf("hash%d", i)})
+		require.NoError(t, err, "add blob reference error")
+	}
+
+	var ids []string
+	err := b.ForEachBlobBatch(2, func(blob *common.Blob) error {
+		ids = append(ids, blob.ID)
+
+		// The database can be updated while iterating
+		blob.BackendDetails = "details"
+		return b.UpdateBlobBackendDetails(blob)
+	})
+	require.NoError(t, err, "for each blob batch error")
+	require.Equal(t, []string{"hash0", "hash1", "hash2", "hash3", "hash4"}, ids, "invalid blobs")
+
+	err = b.ForEachBlobBatch(2, func(blob *common.Blob) error {
+		return fmt.Errorf("expected")
+	})
+	require.Errorf(t, err, "expected")
+}
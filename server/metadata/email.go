@@ -0,0 +1,84 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CreateEmail add an email to the outbox
+func (b *Backend) CreateEmail(email *common.Email) (err error) {
+	return b.db.Create(email).Error
+}
+
+// GetPendingEmails return at most limit emails that are due for an attempt
+func (b *Backend) GetPendingEmails(limit int) (emails []*common.Email, err error) {
+	err = b.db.Where("next_attempt <= ?", time.Now()).Order("next_attempt").Limit(limit).Find(&emails).Error
+	if err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// ClaimEmail reserve an email until the given date so no other instance tries to send it
+// concurrently. The number of attempts ensure that the email has not been claimed since loaded.
+func (b *Backend) ClaimEmail(email *common.Email, until time.Time) (err error) {
+	result := b.db.Model(&common.Email{}).
+		Where("id = ? AND attempts = ?", email.ID, email.Attempts).
+		Updates(map[string]interface{}{"attempts": email.Attempts + 1, "next_attempt": until})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("email already claimed")
+	}
+
+	email.Attempts++
+	email.NextAttempt = until
+
+	return nil
+}
+
+// RetryEmail schedule the next attempt of an email that could not be sent
+func (b *Backend) RetryEmail(email *common.Email, next time.Time, lastError string) (err error) {
+	err = b.db.Model(&common.Email{}).
+		Where("id = ?", email.ID).
+		Updates(map[string]interface{}{"next_attempt": next, "last_error": lastError}).Error
+	if err != nil {
+		return err
+	}
+
+	email.NextAttempt = next
+	email.LastError = lastError
+
+	return nil
+}
+
+// DeleteEmail remove an email from the outbox
+func (b *Backend) DeleteEmail(emailID string) (err error) {
+	return b.db.Delete(&common.Email{ID: emailID}).Error
+}
+
+// ForEachEmail execute f for every email in the database
+func (b *Backend) ForEachEmail(f func(email *common.Email) error) (err error) {
+	rows, err := b.db.Model(&common.Email{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		email := &common.Email{}
+		err = b.db.ScanRows(rows, email)
+		if err != nil {
+			return err
+		}
+		err = f(email)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func createEmail(t *testing.T, b *Backend, next time.Time) *common.Email {
+	email := &common.Email{}
+	email.ID = common.GenerateRandomID(32)
+	email.To = "user@root.gg"
+	email.Subject = "subject"
+	email.Text = "text"
+	email.NextAttempt = next
+
+	err := b.CreateEmail(email)
+	require.NoError(t, err, "create email error")
+
+	return email
+}
+
+func TestBackend_GetPendingEmails(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	email1 := createEmail(t, b, time.Now().Add(-time.Minute))
+	email2 := createEmail(t, b, time.Now().Add(-time.Hour))
+	createEmail(t, b, time.Now().Add(time.Hour))
+
+	emails, err := b.GetPendingEmails(10)
+	require.NoError(t, err, "get pending emails error")
+	require.Len(t, emails, 2, "invalid pending emails count")
+	require.Equal(t, email2.ID, emails[0].ID, "invalid pending email order")
+	require.Equal(t, email1.ID, emails[1].ID, "invalid pending email order")
+
+	emails, err = b.GetPendingEmails(1)
+	require.NoError(t, err, "get pending emails error")
+	require.Len(t, emails, 1, "invalid pending emails count")
+}
+
+func TestBackend_ClaimEmail(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	email := createEmail(t, b, time.Now())
+
+	emails, err := b.GetPendingEmails(10)
+	require.NoError(t, err, "get pending emails error")
+	require.Len(t, emails, 1, "invalid pending emails count")
+
+	err = b.ClaimEmail(email, time.Now().Add(time.Minute))
+	require.NoError(t, err, "claim email error")
+	require.Equal(t, 1, email.Attempts, "invalid email attempts")
+
+	// Already claimed by another instance
+	err = b.ClaimEmail(emails[0], time.Now().Add(time.Minute))
+	require.Error(t, err, "claim email error expected")
+
+	emails, err = b.GetPendingEmails(10)
+	require.NoError(t, err, "get pending emails error")
+	require.Len(t, emails, 0, "invalid pending emails count")
+}
+
+func TestBackend_RetryEmail(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	email := createEmail(t, b, time.Now().Add(time.Hour))
+
+	err := b.RetryEmail(email, time.Now().Add(-time.Minute), "error")
+	require.NoError(t, err, "retry email error")
+
+	emails, err := b.GetPendingEmails(10)
+	require.NoError(t, err, "get pending emails error")
+	require.Len(t, emails, 1, "invalid pending emails count")
+	require.Equal(t, "error", emails[0].LastError, "invalid email last error")
+}
+
+func TestBackend_DeleteEmail(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	email := createEmail(t, b, time.Now())
+
+	err := b.DeleteEmail(email.ID)
+	require.NoError(t, err, "delete email error")
+
+	count := 0
+	err = b.ForEachEmail(func(email *common.Email) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err, "for each email error")
+	require.Equal(t, 0, count, "invalid email count")
+}
@@ -19,6 +19,14 @@ const (
 	metadataTypeUser
 	metadataTypeToken
 	metadataTypeSetting
+	metadataTypeBlob
+	metadataTypeBlobReference
+	metadataTypeWebhookDelivery
+	metadataTypeAuditEvent
+	metadataTypeGroup
+	metadataTypeGroupMember
+	metadataTypeUploadRequest
+	metadataTypeEmail
 )
 
 type object struct {
@@ -50,6 +58,14 @@ func newExporter(path string) (e *exporter, err error) {
 	gob.Register(&common.User{})
 	gob.Register(&common.Token{})
 	gob.Register(&common.Setting{})
+	gob.Register(&common.Blob{})
+	gob.Register(&common.BlobReference{})
+	gob.Register(&common.WebhookDelivery{})
+	gob.Register(&common.AuditEvent{})
+	gob.Register(&common.Group{})
+	gob.Register(&common.GroupMember{})
+	gob.Register(&common.UploadRequest{})
+	gob.Register(&common.Email{})
 	e.encoder = gob.NewEncoder(e.compressor)
 
 	return e, nil
@@ -80,6 +96,46 @@ func (e *exporter) addSetting(setting *common.Setting) (err error) {
 	return e.encoder.Encode(obj)
 }
 
+func (e *exporter) addBlob(blob *common.Blob) (err error) {
+	obj := &object{Type: metadataTypeBlob, Object: blob}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addBlobReference(reference *common.BlobReference) (err error) {
+	obj := &object{Type: metadataTypeBlobReference, Object: reference}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addWebhookDelivery(delivery *common.WebhookDelivery) (err error) {
+	obj := &object{Type: metadataTypeWebhookDelivery, Object: delivery}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addAuditEvent(event *common.AuditEvent) (err error) {
+	obj := &object{Type: metadataTypeAuditEvent, Object: event}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addGroup(group *common.Group) (err error) {
+	obj := &object{Type: metadataTypeGroup, Object: group}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addGroupMember(member *common.GroupMember) (err error) {
+	obj := &object{Type: metadataTypeGroupMember, Object: member}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addUploadRequest(request *common.UploadRequest) (err error) {
+	obj := &object{Type: metadataTypeUploadRequest, Object: request}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addEmail(email *common.Email) (err error) {
+	obj := &object{Type: metadataTypeEmail, Object: email}
+	return e.encoder.Encode(obj)
+}
+
 func (e *exporter) close() (err error) {
 	err = e.compressor.Close()
 	if err != nil {
@@ -121,6 +177,26 @@ func (b *Backend) Export(path string) (err error) {
 	}
 	fmt.Printf("exported %d tokens\n", count)
 
+	count = 0
+	err = b.ForEachGroup(func(group *common.Group) error {
+		count++
+		return e.addGroup(group)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d groups\n", count)
+
+	count = 0
+	err = b.ForEachGroupMember(func(member *common.GroupMember) error {
+		count++
+		return e.addGroupMember(member)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d group members\n", count)
+
 	count = 0
 	// Need to export "soft deleted" uploads too else some removed/deleted files will have broken foreign keys
 	err = b.ForEachUploadUnscoped(func(upload *common.Upload) error {
@@ -152,5 +228,65 @@ func (b *Backend) Export(path string) (err error) {
 	}
 	fmt.Printf("exported %d settings\n", count)
 
+	count = 0
+	err = b.ForEachBlob(func(blob *common.Blob) error {
+		count++
+		return e.addBlob(blob)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d blobs\n", count)
+
+	count = 0
+	err = b.ForEachBlobReference(func(reference *common.BlobReference) error {
+		count++
+		return e.addBlobReference(reference)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d blob references\n", count)
+
+	count = 0
+	err = b.ForEachWebhookDelivery(func(delivery *common.WebhookDelivery) error {
+		count++
+		return e.addWebhookDelivery(delivery)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d webhook deliveries\n", count)
+
+	count = 0
+	err = b.ForEachAuditEvent(func(event *common.AuditEvent) error {
+		count++
+		return e.addAuditEvent(event)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d audit events\n", count)
+
+	count = 0
+	err = b.ForEachUploadRequest(func(request *common.UploadRequest) error {
+		count++
+		return e.addUploadRequest(request)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d upload requests\n", count)
+
+	count = 0
+	err = b.ForEachEmail(func(email *common.Email) error {
+		count++
+		return e.addEmail(email)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d emails\n", count)
+
 	return nil
 }
@@ -3,6 +3,7 @@ package metadata
 import (
 	"testing"
 
+	"github.com/root-gg/utils"
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
@@ -41,6 +42,36 @@ func TestBackend_Export(t *testing.T) {
 	require.NoError(t, err, "import error %s", err)
 }
 
+func TestBackend_ImportLegacyUploadPassword(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	credentials := common.EncodeAuthBasicHeader("login", "password")
+	legacyHash, err := utils.Md5sum(credentials)
+	require.NoError(t, err, "md5sum error")
+
+	upload := &common.Upload{ProtectedByPassword: true, Login: "login", Password: legacyHash}
+	createUpload(t, b, upload)
+
+	path := "/tmp/plik.metadata.test.snappy.gob"
+	err = b.Export(path)
+	require.NoError(t, err, "export error %s", err)
+
+	shutdownTestMetadataBackend(b)
+	b = newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	err = b.Import(path, &ImportOptions{})
+	require.NoError(t, err, "import error %s", err)
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.False(t, common.IsLegacyUploadCredentialsHash(result.Password), "legacy hash should have been upgraded")
+
+	ok, _ := common.CheckUploadCredentials(credentials, result.Password)
+	require.True(t, ok, "upgraded hash should match the credentials")
+}
+
 func TestBackend_ExportRemovedFiles(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
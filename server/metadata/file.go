@@ -1,13 +1,19 @@
 package metadata
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 
 	"github.com/root-gg/plik/server/common"
 )
 
+// ErrDownloadLimitReached is returned when a file has already been downloaded the maximum number of times
+var ErrDownloadLimitReached = errors.New("download limit reached")
+
 // CreateFile persist a new file to the database
 func (b *Backend) CreateFile(file *common.File) (err error) {
 	return b.db.Create(file).Error
@@ -63,6 +69,82 @@ func (b *Backend) UpdateFileStatus(file *common.File, oldStatus string, newStatu
 	return nil
 }
 
+// UpdateFileUploadOffset update a resumable file upload progress, chunk IDs and backend details in DB.
+// oldOffset ensure that no other chunk has been received since loaded
+func (b *Backend) UpdateFileUploadOffset(file *common.File, oldOffset int64, newOffset int64, chunkIDs []string) error {
+	// Map updates bypass the json serializer of the column
+	ids, err := json.Marshal(chunkIDs)
+	if err != nil {
+		return err
+	}
+
+	result := b.db.Model(&common.File{}).
+		Where("id = ? AND status = ? AND upload_offset = ?", file.ID, common.FileUploading, oldOffset).
+		Updates(map[string]interface{}{
+			"upload_offset":    newOffset,
+			"upload_chunks":    len(chunkIDs),
+			"upload_chunk_ids": string(ids),
+			"backend_details":  file.BackendDetails,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("invalid file upload offset")
+	}
+
+	file.UploadOffset = newOffset
+	file.UploadChunks = len(chunkIDs)
+	file.UploadChunkIDs = chunkIDs
+
+	return nil
+}
+
+// IncrementFileDownloads atomically increment the download counter of an uploaded file and update its last download date.
+// If maxDownloads is more than 0 an error is returned when the limit has already been reached
+// and the file status is set to removed when the last allowed download starts.
+// The file is reloaded from the database to reflect the new counter and status.
+func (b *Backend) IncrementFileDownloads(file *common.File, maxDownloads int) (err error) {
+	return b.db.Transaction(func(tx *gorm.DB) (err error) {
+		stmt := tx.Model(&common.File{}).Where("id = ? AND status = ?", file.ID, common.FileUploaded)
+		if maxDownloads > 0 {
+			stmt = stmt.Where("downloads < ?", maxDownloads)
+		}
+
+		result := stmt.Updates(map[string]interface{}{"downloads": gorm.Expr("downloads + 1"), "last_download_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(1) {
+			return ErrDownloadLimitReached
+		}
+
+		if maxDownloads > 0 {
+			err = tx.Model(&common.File{}).
+				Where("id = ? AND status = ? AND downloads >= ?", file.ID, common.FileUploaded, maxDownloads).
+				Update("status", common.FileRemoved).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Where(&common.File{ID: file.ID}).Take(file).Error
+	})
+}
+
+// UpdateFileBackendDetails update a file backend details in DB
+func (b *Backend) UpdateFileBackendDetails(file *common.File) error {
+	result := b.db.Model(&common.File{}).Where(&common.File{ID: file.ID}).Update("backend_details", file.BackendDetails)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("file not found")
+	}
+
+	return nil
+}
+
 // RemoveFile change the file status to removed
 // The file will then be deleted from the data backend by the server and the status changed to deleted.
 func (b *Backend) RemoveFile(file *common.File) error {
@@ -70,8 +152,8 @@ func (b *Backend) RemoveFile(file *common.File) error {
 	case common.FileMissing, "":
 		// Missing files were never uploaded, even partially it is safe to update the status to deleted directly
 		return b.UpdateFileStatus(file, file.Status, common.FileDeleted)
-	case common.FileUploaded, common.FileUploading:
-		// Uploaded, Uploading files have been at least partially uploaded
+	case common.FileUploaded, common.FileUploading, common.FileQuarantined:
+		// Uploaded, Uploading, Quarantined files have been at least partially uploaded
 		// by setting the status to Removed we mark the files as ready to be deleted from the Data backend
 		// which will occur during the next cleaning cycle
 		return b.UpdateFileStatus(file, file.Status, common.FileRemoved)
@@ -140,6 +222,34 @@ func (b *Backend) CountUploadFiles(uploadID string) (count int, err error) {
 	return int(c), nil
 }
 
+// BatchSize is the default number of rows loaded at once by the ForEach*Batch functions
+const BatchSize = 1000
+
+// ForEachFileBatch execute f for every file in the database matching filter with an ID greater than after, ordered by ID.
+// The files are loaded by batches of batchSize so unlike ForEachFile f can update the database
+// and the memory usage does not depend on the number of files
+func (b *Backend) ForEachFileBatch(filter *common.File, after string, batchSize int, f func(file *common.File) error) (err error) {
+	for {
+		var files []*common.File
+		err = b.db.Where(filter).Where("id > ?", after).Order("id").Limit(batchSize).Find(&files).Error
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			err = f(file)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(files) < batchSize {
+			return nil
+		}
+		after = files[len(files)-1].ID
+	}
+}
+
 // ForEachFile execute f for every file in the database
 func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
 	stmt := b.db.Model(&common.File{})
@@ -92,6 +92,7 @@ func TestBackend_UpdateFile(t *testing.T) {
 	file.Status = common.FileUploaded
 	file.Name = "name"
 	file.Md5 = "md5"
+	file.Processors = map[string]string{"archive": "1"}
 	err := b.UpdateFile(file, common.FileMissing)
 	require.NoError(t, err, "update file error")
 
@@ -103,6 +104,7 @@ func TestBackend_UpdateFile(t *testing.T) {
 	require.Equal(t, file.Name, result.Name, "invalid file name")
 	require.Equal(t, file.Md5, result.Md5, "invalid file md5")
 	require.Equal(t, file.Status, result.Status, "invalid file md5")
+	require.Equal(t, file.Processors, result.Processors, "invalid file processors")
 
 	err = b.UpdateFile(file, common.FileMissing)
 	require.Error(t, err, "update file error expected")
@@ -128,6 +130,108 @@ func TestBackend_UpdateFileStatus(t *testing.T) {
 	require.Error(t, err, "update file status error expected")
 }
 
+func TestBackend_UpdateFileUploadOffset(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploading
+	createUpload(t, b, upload)
+
+	err := b.UpdateFileUploadOffset(file, 0, 10, []string{"chunk0"})
+	require.NoError(t, err, "update file upload offset error")
+	require.Equal(t, int64(10), file.UploadOffset, "invalid file upload offset")
+	require.Equal(t, 1, file.UploadChunks, "invalid file upload chunks")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.NotNil(t, f, "missing file")
+	require.Equal(t, int64(10), f.UploadOffset, "invalid file upload offset")
+	require.Equal(t, 1, f.UploadChunks, "invalid file upload chunks")
+
+	file.BackendDetails = "details"
+	err = b.UpdateFileUploadOffset(file, 10, 20, []string{"chunk0", "chunk1"})
+	require.NoError(t, err, "update file upload offset error")
+
+	f, err = b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, "details", f.BackendDetails, "invalid file backend details")
+	require.Equal(t, []string{"chunk0", "chunk1"}, f.UploadChunkIDs, "invalid file upload chunk ids")
+
+	err = b.UpdateFileUploadOffset(file, 0, 20, []string{"chunk0", "chunk1"})
+	require.Error(t, err, "update file upload offset error expected")
+}
+
+func TestBackend_IncrementFileDownloads(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	err := b.IncrementFileDownloads(file, 0)
+	require.NoError(t, err, "increment file downloads error")
+	require.Equal(t, 1, file.Downloads, "invalid file downloads")
+	require.Equal(t, common.FileUploaded, file.Status, "invalid file status")
+	require.NotNil(t, file.LastDownloadAt, "missing last download date")
+
+	err = b.IncrementFileDownloads(file, 3)
+	require.NoError(t, err, "increment file downloads error")
+	require.Equal(t, 2, file.Downloads, "invalid file downloads")
+	require.Equal(t, common.FileUploaded, file.Status, "invalid file status")
+
+	err = b.IncrementFileDownloads(file, 3)
+	require.NoError(t, err, "increment file downloads error")
+	require.Equal(t, 3, file.Downloads, "invalid file downloads")
+	require.Equal(t, common.FileRemoved, file.Status, "invalid file status")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.NotNil(t, f, "missing file")
+	require.Equal(t, 3, f.Downloads, "invalid file downloads")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+
+	err = b.IncrementFileDownloads(file, 3)
+	require.Equal(t, ErrDownloadLimitReached, err, "download limit reached error expected")
+}
+
+func TestBackend_IncrementFileDownloads_NotUploaded(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploading
+	createUpload(t, b, upload)
+
+	err := b.IncrementFileDownloads(file, 0)
+	require.Equal(t, ErrDownloadLimitReached, err, "download limit reached error expected")
+}
+
+func TestBackend_UpdateFileBackendDetails(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	createUpload(t, b, upload)
+
+	file.BackendDetails = "details"
+	err := b.UpdateFileBackendDetails(file)
+	require.NoError(t, err, "update file backend details error")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.NotNil(t, f, "missing file")
+	require.Equal(t, "details", f.BackendDetails, "invalid file backend details")
+
+	err = b.UpdateFileBackendDetails(&common.File{ID: "missing"})
+	require.Error(t, err, "update file backend details error expected")
+}
+
 func TestBackend_RemoveFile(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
@@ -173,6 +277,18 @@ func TestBackend_RemoveFile(t *testing.T) {
 	require.NoError(t, err, "get file error")
 	require.NotNil(t, f, "missing file")
 	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+
+	// File status Quarantined
+	err = b.UpdateFileStatus(file, common.FileRemoved, common.FileQuarantined)
+	require.NoError(t, err, "update file status error")
+
+	err = b.RemoveFile(file)
+	require.NoError(t, err, "remove file error")
+
+	f, err = b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.NotNil(t, f, "missing file")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
 }
 
 func TestBackend_ForEachUploadFiles(t *testing.T) {
@@ -267,3 +383,51 @@ func TestBackend_ForEachFile(t *testing.T) {
 	err = b.ForEachFile(f)
 	require.Errorf(t, err, "expected")
 }
+
+func TestBackend_ForEachFileBatch(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	for i := 0; i < 5; i++ {
+		file := upload.NewFile()
+		file.Status = common.FileUploaded
+	}
+	upload.NewFile().Status = common.FileRemoved
+	createUpload(t, b, upload)
+
+	var ids []string
+	f := func(file *common.File) error {
+		ids = append(ids, file.ID)
+
+		// The database can be updated while iterating
+		return b.UpdateFileStatus(file, common.FileUploaded, common.FileRemoved)
+	}
+	err := b.ForEachFileBatch(&common.File{Status: common.FileUploaded}, "", 2, f)
+	require.NoError(t, err, "for each file batch error : %s", err)
+	require.Len(t, ids, 5, "invalid file count")
+	require.IsIncreasing(t, ids, "files should be ordered by id")
+
+	// Resume after the third file, all the files are now removed
+	after := ids[2]
+	ids = nil
+	err = b.ForEachFileBatch(&common.File{Status: common.FileRemoved}, after, 2, func(file *common.File) error {
+		require.Greater(t, file.ID, after, "files should be after the given id")
+		ids = append(ids, file.ID)
+		return nil
+	})
+	require.NoError(t, err, "for each file batch error : %s", err)
+
+	expected := 0
+	for _, file := range upload.Files {
+		if file.ID > after {
+			expected++
+		}
+	}
+	require.Len(t, ids, expected, "invalid file count")
+
+	err = b.ForEachFileBatch(&common.File{}, "", 2, func(file *common.File) error {
+		return fmt.Errorf("expected")
+	})
+	require.Errorf(t, err, "expected")
+}
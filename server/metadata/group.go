@@ -0,0 +1,175 @@
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+	"gorm.io/gorm"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CreateGroup create a new group in DB
+func (b *Backend) CreateGroup(group *common.Group) (err error) {
+	return b.db.Create(group).Error
+}
+
+// UpdateGroup update group info in DB
+func (b *Backend) UpdateGroup(group *common.Group) (err error) {
+	result := b.db.Omit("Members").Save(group)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("no group updated")
+	}
+
+	return nil
+}
+
+// GetGroup return a group and its members from DB ( return nil and no error if not found )
+func (b *Backend) GetGroup(ID string) (group *common.Group, err error) {
+	group = &common.Group{}
+	err = b.db.Preload("Members").Where(&common.Group{ID: ID}).Take(group).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return group, err
+}
+
+// GetGroups return all groups
+func (b *Backend) GetGroups(withMembers bool, pagingQuery *common.PagingQuery) (groups []*common.Group, cursor *paginator.Cursor, err error) {
+	if pagingQuery == nil {
+		return nil, nil, fmt.Errorf("missing paging query")
+	}
+
+	p := pagingQuery.Paginator()
+	p.SetKeys("CreatedAt", "ID")
+
+	stmt := b.db.Model(&common.Group{})
+
+	if withMembers {
+		stmt = stmt.Preload("Members")
+	}
+
+	result, c, err := p.Paginate(stmt, &groups)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	return groups, &c, err
+}
+
+// GetUserGroups return all the groups a user is a member of
+func (b *Backend) GetUserGroups(userID string) (groups []*common.Group, err error) {
+	err = b.db.
+		Where("id IN (?)", b.db.Model(&common.GroupMember{}).Select("group_id").Where(&common.GroupMember{UserID: userID})).
+		Order("id").
+		Find(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// DeleteGroup delete a group and its members from the DB
+// Uploads owned by the group are given back to the users who created them
+func (b *Backend) DeleteGroup(groupID string) (deleted bool, err error) {
+	err = b.db.Transaction(func(tx *gorm.DB) (err error) {
+		err = tx.Model(&common.Upload{}).Where(&common.Upload{Group: groupID}).Update("group", "").Error
+		if err != nil {
+			return fmt.Errorf("unable to update group uploads metadata : %s", err)
+		}
+
+		err = tx.Where(&common.GroupMember{GroupID: groupID}).Delete(&common.GroupMember{}).Error
+		if err != nil {
+			return fmt.Errorf("unable to delete group members metadata : %s", err)
+		}
+
+		result := tx.Where(&common.Group{ID: groupID}).Delete(&common.Group{})
+		if result.Error != nil {
+			return fmt.Errorf("unable to delete group metadata : %s", result.Error)
+		}
+
+		if result.RowsAffected > 0 {
+			deleted = true
+		}
+
+		return nil
+	})
+
+	return deleted, err
+}
+
+// CreateGroupMember create a new group member in DB
+func (b *Backend) CreateGroupMember(member *common.GroupMember) (err error) {
+	return b.db.Create(member).Error
+}
+
+// AddGroupMember add a user to a group
+func (b *Backend) AddGroupMember(groupID string, userID string) (err error) {
+	return b.CreateGroupMember(&common.GroupMember{GroupID: groupID, UserID: userID})
+}
+
+// RemoveGroupMember remove a user from a group
+func (b *Backend) RemoveGroupMember(groupID string, userID string) (removed bool, err error) {
+	result := b.db.Where(&common.GroupMember{GroupID: groupID, UserID: userID}).Delete(&common.GroupMember{})
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to delete group member metadata : %s", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// ForEachGroup execute f for every group in the database
+func (b *Backend) ForEachGroup(f func(group *common.Group) error) (err error) {
+	rows, err := b.db.Model(&common.Group{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		group := &common.Group{}
+		err = b.db.ScanRows(rows, group)
+		if err != nil {
+			return err
+		}
+		err = f(group)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForEachGroupMember execute f for every group member in the database
+func (b *Backend) ForEachGroupMember(f func(member *common.GroupMember) error) (err error) {
+	rows, err := b.db.Model(&common.GroupMember{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		member := &common.GroupMember{}
+		err = b.db.ScanRows(rows, member)
+		if err != nil {
+			return err
+		}
+		err = f(member)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func createGroup(t *testing.T, b *Backend, group *common.Group) {
+	err := b.CreateGroup(group)
+	require.NoError(t, err, "create group error", err)
+}
+
+func TestBackend_CreateGroup(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	group := &common.Group{ID: "group"}
+	createGroup(t, b, group)
+	require.NotZero(t, group.CreatedAt, "missing creation date")
+
+	err := b.CreateGroup(&common.Group{ID: "group"})
+	require.Error(t, err, "create group error")
+}
+
+func TestBackend_UpdateGroup(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	group := &common.Group{ID: "group", Name: "foo"}
+	createGroup(t, b, group)
+
+	group.Name = "bar"
+	group.MaxGroupSize = 42
+	err := b.UpdateGroup(group)
+	require.NoError(t, err, "update group error")
+
+	result, err := b.GetGroup(group.ID)
+	require.NoError(t, err, "get group error")
+	require.Equal(t, group.Name, result.Name, "invalid group name")
+	require.Equal(t, group.MaxGroupSize, result.MaxGroupSize, "invalid group max size")
+}
+
+func TestBackend_GetGroup(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	group, err := b.GetGroup("group")
+	require.NoError(t, err, "get group error")
+	require.Nil(t, group, "group should not exist")
+
+	createGroup(t, b, &common.Group{ID: "group"})
+	err = b.AddGroupMember("group", "user1")
+	require.NoError(t, err, "add group member error")
+
+	group, err = b.GetGroup("group")
+	require.NoError(t, err, "get group error")
+	require.NotNil(t, group, "missing group")
+	require.Len(t, group.Members, 1, "invalid group members")
+	require.True(t, group.IsMember("user1"), "user should be a group member")
+}
+
+func TestBackend_GetGroups(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	for _, ID := range []string{"group1", "group2", "group3"} {
+		createGroup(t, b, &common.Group{ID: ID})
+		err := b.AddGroupMember(ID, "user1")
+		require.NoError(t, err, "add group member error")
+	}
+
+	groups, cursor, err := b.GetGroups(false, common.NewPagingQuery().WithLimit(2))
+	require.NoError(t, err, "get groups error")
+	require.Len(t, groups, 2, "invalid groups length")
+	require.NotNil(t, cursor.After, "missing cursor")
+	require.Empty(t, groups[0].Members, "members should not be loaded")
+
+	groups, _, err = b.GetGroups(true, common.NewPagingQuery().WithLimit(10))
+	require.NoError(t, err, "get groups error")
+	require.Len(t, groups, 3, "invalid groups length")
+	require.Len(t, groups[0].Members, 1, "members should be loaded")
+}
+
+func TestBackend_GetUserGroups(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	createGroup(t, b, &common.Group{ID: "group1"})
+	createGroup(t, b, &common.Group{ID: "group2"})
+	createGroup(t, b, &common.Group{ID: "group3"})
+
+	err := b.AddGroupMember("group1", "user1")
+	require.NoError(t, err, "add group member error")
+	err = b.AddGroupMember("group3", "user1")
+	require.NoError(t, err, "add group member error")
+	err = b.AddGroupMember("group2", "user2")
+	require.NoError(t, err, "add group member error")
+
+	groups, err := b.GetUserGroups("user1")
+	require.NoError(t, err, "get user groups error")
+	require.Len(t, groups, 2, "invalid groups length")
+	require.Equal(t, "group1", groups[0].ID, "invalid group")
+	require.Equal(t, "group3", groups[1].ID, "invalid group")
+
+	groups, err = b.GetUserGroups("user3")
+	require.NoError(t, err, "get user groups error")
+	require.Len(t, groups, 0, "invalid groups length")
+}
+
+func TestBackend_AddGroupMember(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	createGroup(t, b, &common.Group{ID: "group"})
+
+	err := b.AddGroupMember("group", "user1")
+	require.NoError(t, err, "add group member error")
+
+	err = b.AddGroupMember("group", "user1")
+	require.Error(t, err, "user should already be a group member")
+}
+
+func TestBackend_RemoveGroupMember(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	createGroup(t, b, &common.Group{ID: "group"})
+	err := b.AddGroupMember("group", "user1")
+	require.NoError(t, err, "add group member error")
+
+	removed, err := b.RemoveGroupMember("group", "user1")
+	require.NoError(t, err, "remove group member error")
+	require.True(t, removed, "group member should have been removed")
+
+	removed, err = b.RemoveGroupMember("group", "user1")
+	require.NoError(t, err, "remove group member error")
+	require.False(t, removed, "group member should not have been removed")
+}
+
+func TestBackend_DeleteGroup(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	createGroup(t, b, &common.Group{ID: "group"})
+	err := b.AddGroupMember("group", "user1")
+	require.NoError(t, err, "add group member error")
+
+	upload := &common.Upload{User: "user1", Group: "group"}
+	createUpload(t, b, upload)
+
+	deleted, err := b.DeleteGroup("group")
+	require.NoError(t, err, "delete group error")
+	require.True(t, deleted, "group should have been deleted")
+
+	group, err := b.GetGroup("group")
+	require.NoError(t, err, "get group error")
+	require.Nil(t, group, "group should not exist")
+
+	groups, err := b.GetUserGroups("user1")
+	require.NoError(t, err, "get user groups error")
+	require.Len(t, groups, 0, "group members should have been deleted")
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, "", result.Group, "upload should not belong to the group anymore")
+	require.Equal(t, "user1", result.User, "upload should still belong to the user")
+
+	deleted, err = b.DeleteGroup("group")
+	require.NoError(t, err, "delete group error")
+	require.False(t, deleted, "group should not have been deleted")
+}
+
+func TestBackend_ForEachGroup(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	createGroup(t, b, &common.Group{ID: "group1"})
+	createGroup(t, b, &common.Group{ID: "group2"})
+	err := b.AddGroupMember("group1", "user1")
+	require.NoError(t, err, "add group member error")
+
+	groups := 0
+	err = b.ForEachGroup(func(group *common.Group) error {
+		groups++
+		return nil
+	})
+	require.NoError(t, err, "for each group error")
+	require.Equal(t, 2, groups, "invalid groups count")
+
+	members := 0
+	err = b.ForEachGroupMember(func(member *common.GroupMember) error {
+		members++
+		require.Equal(t, "group1", member.GroupID, "invalid member group")
+		require.Equal(t, "user1", member.UserID, "invalid member user")
+		return nil
+	})
+	require.NoError(t, err, "for each group member error")
+	require.Equal(t, 1, members, "invalid group members count")
+}
@@ -42,6 +42,14 @@ func newImporter(path string) (i *importer, err error) {
 	gob.Register(&common.User{})
 	gob.Register(&common.Token{})
 	gob.Register(&common.Setting{})
+	gob.Register(&common.Blob{})
+	gob.Register(&common.BlobReference{})
+	gob.Register(&common.WebhookDelivery{})
+	gob.Register(&common.AuditEvent{})
+	gob.Register(&common.Group{})
+	gob.Register(&common.GroupMember{})
+	gob.Register(&common.UploadRequest{})
+	gob.Register(&common.Email{})
 	i.decoder = gob.NewDecoder(i.decompressor)
 
 	return i, nil
@@ -60,8 +68,8 @@ func (b *Backend) Import(path string, options *ImportOptions) (err error) {
 
 	defer func() { _ = i.close() }()
 
-	var uploads, files, users, tokens, settings int
-	var uploadErrors, fileErrors, userErrors, tokenErrors, settingErrors int
+	var uploads, files, users, tokens, settings, blobs, blobReferences, webhookDeliveries, auditEvents, groups, groupMembers, uploadRequests, emails int
+	var uploadErrors, fileErrors, userErrors, tokenErrors, settingErrors, blobErrors, blobReferenceErrors, webhookDeliveryErrors, auditEventErrors, groupErrors, groupMemberErrors, uploadRequestErrors, emailErrors int
 
 	for {
 		obj := &object{}
@@ -74,7 +82,17 @@ func (b *Backend) Import(path string, options *ImportOptions) (err error) {
 
 		switch obj.Type {
 		case metadataTypeUpload:
-			err = b.CreateUpload(obj.Object.(*common.Upload))
+			upload := obj.Object.(*common.Upload)
+
+			// Exports made before 0012-upload-password-hash contain legacy md5 upload password hashes
+			if common.IsLegacyUploadCredentialsHash(upload.Password) {
+				upload.Password, err = common.UpgradeLegacyUploadCredentialsHash(upload.Password)
+				if err != nil {
+					return err
+				}
+			}
+
+			err = b.CreateUpload(upload)
 			if err != nil {
 				utils.Dump(obj)
 				if options.IgnoreErrors {
@@ -134,6 +152,102 @@ func (b *Backend) Import(path string, options *ImportOptions) (err error) {
 			} else {
 				settings++
 			}
+		case metadataTypeBlob:
+			err = b.CreateBlob(obj.Object.(*common.Blob))
+			if err != nil {
+				utils.Dump(obj)
+				fmt.Printf("Unable to load blob : %s\n", err)
+				if !options.IgnoreErrors {
+					return err
+				}
+				blobErrors++
+			} else {
+				blobs++
+			}
+		case metadataTypeBlobReference:
+			err = b.CreateBlobReference(obj.Object.(*common.BlobReference))
+			if err != nil {
+				utils.Dump(obj)
+				fmt.Printf("Unable to load blob reference : %s\n", err)
+				if !options.IgnoreErrors {
+					return err
+				}
+				blobReferenceErrors++
+			} else {
+				blobReferences++
+			}
+		case metadataTypeWebhookDelivery:
+			err = b.CreateWebhookDelivery(obj.Object.(*common.WebhookDelivery))
+			if err != nil {
+				utils.Dump(obj)
+				fmt.Printf("Unable to load webhook delivery : %s\n", err)
+				if !options.IgnoreErrors {
+					return err
+				}
+				webhookDeliveryErrors++
+			} else {
+				webhookDeliveries++
+			}
+		case metadataTypeAuditEvent:
+			err = b.CreateAuditEvent(obj.Object.(*common.AuditEvent))
+			if err != nil {
+				utils.Dump(obj)
+				fmt.Printf("Unable to load audit event : %s\n", err)
+				if !options.IgnoreErrors {
+					return err
+				}
+				auditEventErrors++
+			} else {
+				auditEvents++
+			}
+		case metadataTypeGroup:
+			err = b.CreateGroup(obj.Object.(*common.Group))
+			if err != nil {
+				utils.Dump(obj)
+				fmt.Printf("Unable to load group : %s\n", err)
+				if !options.IgnoreErrors {
+					return err
+				}
+				groupErrors++
+			} else {
+				groups++
+			}
+		case metadataTypeGroupMember:
+			err = b.CreateGroupMember(obj.Object.(*common.GroupMember))
+			if err != nil {
+				utils.Dump(obj)
+				fmt.Printf("Unable to load group member : %s\n", err)
+				if !options.IgnoreErrors {
+					return err
+				}
+				groupMemberErrors++
+			} else {
+				groupMembers++
+			}
+		case metadataTypeUploadRequest:
+			err = b.CreateUploadRequest(obj.Object.(*common.UploadRequest))
+			if err != nil {
+				utils.Dump(obj)
+				fmt.Printf("Unable to load upload request : %s\n", err)
+				if !options.IgnoreErrors {
+					return err
+				}
+				uploadRequestErrors++
+			} else {
+				uploadRequests++
+			}
+		case metadataTypeEmail:
+			err = b.CreateEmail(obj.Object.(*common.Email))
+			if err != nil {
+				utils.Dump(obj)
+				fmt.Printf("Unable to load email : %s\n", err)
+				if !options.IgnoreErrors {
+					return err
+				}
+				emailErrors++
+			} else {
+				emails++
+			}
 		default:
 			return fmt.Errorf("invalid object type")
 		}
@@ -144,6 +258,14 @@ func (b *Backend) Import(path string, options *ImportOptions) (err error) {
 	fmt.Printf("imported %d out of %d users\n", users, users+userErrors)
 	fmt.Printf("imported %d out of %d tokens\n", tokens, tokens+tokenErrors)
 	fmt.Printf("imported %d out of %d settings\n", settings, settings+settingErrors)
+	fmt.Printf("imported %d out of %d blobs\n", blobs, blobs+blobErrors)
+	fmt.Printf("imported %d out of %d blob references\n", blobReferences, blobReferences+blobReferenceErrors)
+	fmt.Printf("imported %d out of %d webhook deliveries\n", webhookDeliveries, webhookDeliveries+webhookDeliveryErrors)
+	fmt.Printf("imported %d out of %d audit events\n", auditEvents, auditEvents+auditEventErrors)
+	fmt.Printf("imported %d out of %d groups\n", groups, groups+groupErrors)
+	fmt.Printf("imported %d out of %d group members\n", groupMembers, groupMembers+groupMemberErrors)
+	fmt.Printf("imported %d out of %d upload requests\n", uploadRequests, uploadRequests+uploadRequestErrors)
+	fmt.Printf("imported %d out of %d emails\n", emails, emails+emailErrors)
 
 	return nil
 }
@@ -138,7 +138,7 @@ func NewBackend(config *Config, log *logger.Logger) (b *Backend, err error) {
 
 	// For testing
 	if config.EraseFirst {
-		err = b.db.Migrator().DropTable("files", "uploads", "tokens", "users", "settings", "migrations")
+		err = b.db.Migrator().DropTable("files", "uploads", "tokens", "users", "settings", "blobs", "blob_references", "webhook_deliveries", "audit", "group_members", "groups", "upload_requests", "emails", "migrations")
 		if err != nil {
 			return nil, fmt.Errorf("unable to drop tables : %s", err)
 		}
@@ -180,6 +180,14 @@ func (b *Backend) initializeSchema() (err error) {
 				&common.User{},
 				&common.Token{},
 				&common.Setting{},
+				&common.Blob{},
+				&common.BlobReference{},
+				&common.WebhookDelivery{},
+				&common.AuditEvent{},
+				&common.Group{},
+				&common.GroupMember{},
+				&common.UploadRequest{},
+				&common.Email{},
 			)
 
 			return err
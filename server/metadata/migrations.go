@@ -5,6 +5,8 @@ import (
 
 	"github.com/go-gormigrate/gormigrate/v2"
 	"gorm.io/gorm"
+
+	"github.com/root-gg/plik/server/common"
 )
 
 func (b *Backend) getMigrations() []*gormigrate.Migration {
@@ -156,6 +158,436 @@ func (b *Backend) getMigrations() []*gormigrate.Migration {
 				b.log.Criticalf("Something went wrong. Please check database status manually")
 				return nil
 			},
+		}, {
+			ID: "0005-resumable-upload",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					UploadOffset int64 `json:"uploadOffset,omitempty"`
+					UploadChunks int   `json:"-"`
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0005-resumable-upload")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0006-dedup",
+			Migrate: func(tx *gorm.DB) error {
+				type Blob struct {
+					ID             string `gorm:"primary_key"`
+					Size           int64
+					RefCount       int
+					BackendDetails string
+					CreatedAt      time.Time
+				}
+
+				type BlobReference struct {
+					FileID    string `gorm:"primary_key"`
+					BlobID    string `gorm:"size:256;index"`
+					CreatedAt time.Time
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0006-dedup")
+				return b.setupTxForMigration(tx).AutoMigrate(&Blob{}, &BlobReference{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0007-webhooks",
+			Migrate: func(tx *gorm.DB) error {
+				type WebhookDelivery struct {
+					ID          string `gorm:"primary_key"`
+					URL         string
+					Event       string
+					Payload     string `gorm:"type:text"`
+					Attempts    int
+					NextAttempt time.Time `gorm:"index"`
+					LastError   string    `gorm:"type:text"`
+					CreatedAt   time.Time
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0007-webhooks")
+				return b.setupTxForMigration(tx).AutoMigrate(&WebhookDelivery{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0008-max-downloads",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					MaxDownloads int `json:"maxDownloads,omitempty"`
+				}
+
+				type File struct {
+					Downloads int `json:"downloads,omitempty"`
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0008-max-downloads")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{}, &File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0009-audit",
+			Migrate: func(tx *gorm.DB) error {
+				type AuditEvent struct {
+					ID        string `gorm:"primary_key"`
+					Action    string `gorm:"index:idx_audit_action"`
+					Result    string
+					Status    int
+					UserID    string `gorm:"index:idx_audit_user_id"`
+					Token     string
+					SourceIP  string
+					UserAgent string
+					UploadID  string `gorm:"index:idx_audit_upload_id"`
+					FileID    string
+					Details   string    `gorm:"type:text"`
+					CreatedAt time.Time `gorm:"index:idx_audit_created_at"`
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0009-audit")
+				return b.setupTxForMigration(tx).Table("audit").AutoMigrate(&AuditEvent{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0010-token-scopes",
+			Migrate: func(tx *gorm.DB) error {
+				type Token struct {
+					Scope      string
+					ExpireAt   *time.Time `gorm:"index:idx_token_expire_at"`
+					LastUsedAt *time.Time
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0010-token-scopes")
+				return b.setupTxForMigration(tx).AutoMigrate(&Token{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0011-groups",
+			Migrate: func(tx *gorm.DB) error {
+				type GroupMember struct {
+					GroupID   string `gorm:"primary_key;size:256;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT;"`
+					UserID    string `gorm:"primary_key;size:256;index:idx_group_member_user_id"`
+					CreatedAt time.Time
+				}
+
+				type Group struct {
+					ID           string `gorm:"primary_key"`
+					Name         string
+					MaxFileSize  int64
+					MaxGroupSize int64
+					MaxTTL       int
+					Members      []*GroupMember
+					CreatedAt    time.Time
+				}
+
+				type Upload struct {
+					Group string `gorm:"index:idx_upload_group"`
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0011-groups")
+				return b.setupTxForMigration(tx).AutoMigrate(&Group{}, &GroupMember{}, &Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0012-upload-password-hash",
+			Migrate: func(tx *gorm.DB) error {
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0012-upload-password-hash")
+
+				// Upload passwords were saved as md5(base64("login:password")), those hashes
+				// are wrapped into argon2id and replaced on the next successful authentication
+				upgraded, err := b.upgradeLegacyUploadPasswords(tx)
+				if err != nil {
+					return err
+				}
+				if upgraded > 0 {
+					b.log.Warningf("upgraded %d legacy upload password hashes", upgraded)
+				}
+
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0013-upload-requests",
+			Migrate: func(tx *gorm.DB) error {
+				type UploadRequest struct {
+					ID          string `gorm:"primary_key"`
+					Token       string
+					UserID      string `gorm:"size:256;index:idx_upload_request_user_id"`
+					UploadID    string `gorm:"size:256;index:idx_upload_request_upload_id"`
+					Comment     string
+					MaxFiles    int
+					MaxFileSize int64
+					MaxSize     int64
+					Files       int
+					Size        int64
+					CreatedAt   time.Time
+					ExpireAt    *time.Time `gorm:"index:idx_upload_request_expire_at"`
+					LastUsedAt  *time.Time
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0013-upload-requests")
+				return b.setupTxForMigration(tx).AutoMigrate(&UploadRequest{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0014-email-notifications",
+			Migrate: func(tx *gorm.DB) error {
+				type Email struct {
+					ID          string `gorm:"primary_key"`
+					To          string
+					Subject     string
+					Text        string `gorm:"type:text"`
+					HTML        string `gorm:"type:text"`
+					Attempts    int
+					NextAttempt time.Time `gorm:"index"`
+					LastError   string    `gorm:"type:text"`
+					CreatedAt   time.Time
+				}
+
+				type Upload struct {
+					DownloadReceipt bool
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0014-email-notifications")
+				return b.setupTxForMigration(tx).AutoMigrate(&Email{}, &Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0015-processors",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					Processors map[string]string `gorm:"serializer:json"`
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0015-processors")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0016-sha256",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					Sha256 string
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0016-sha256")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0017-last-download",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					LastDownloadAt *time.Time
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0017-last-download")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0018-e2ee",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					E2EE bool `gorm:"column:e2ee"`
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0018-e2ee")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0019-upload-chunk-ids",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					UploadChunkIDs []string `gorm:"serializer:json"`
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0019-upload-chunk-ids")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0020-blob-data-id",
+			Migrate: func(tx *gorm.DB) error {
+				type Blob struct {
+					DataID string
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0020-blob-data-id")
+				return b.setupTxForMigration(tx).AutoMigrate(&Blob{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0021-audit-token-fingerprint",
+			Migrate: func(tx *gorm.DB) error {
+				type AuditEvent struct {
+					TokenFingerprint string
+				}
+
+				_, _, err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0021-audit-token-fingerprint")
+				err = b.setupTxForMigration(tx).Table("audit").AutoMigrate(&AuditEvent{})
+				if err != nil {
+					return err
+				}
+
+				if !tx.Migrator().HasColumn("audit", "token") {
+					return nil
+				}
+
+				// Replace the tokens recorded in the audit log by their fingerprint
+				var tokens []string
+				err = tx.Table("audit").Where("token <> ''").Distinct().Pluck("token", &tokens).Error
+				if err != nil {
+					return err
+				}
+
+				for _, token := range tokens {
+					err = tx.Table("audit").Where("token = ?", token).Update("token_fingerprint", common.TokenFingerprint(token)).Error
+					if err != nil {
+						return err
+					}
+				}
+
+				return tx.Table("audit").Migrator().DropColumn(&AuditEvent{}, "token")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
 		},
 	}
 
@@ -60,9 +60,23 @@ func generateTestData(t *testing.T, b *Backend) {
 	userToken := user.NewToken()
 	userToken.Token = "8cbaeacd-6a3e-4636-4200-607a6e240688"
 	userToken.Comment = "user token"
+	userToken.Scope = "upload read"
+	tokenDeadline := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	userToken.ExpireAt = &tokenDeadline
 	err = b.CreateToken(userToken)
 	require.NoError(t, err, "unable to create admin token")
 
+	group := &common.Group{ID: "research"}
+	group.Name = "Research department"
+	group.MaxFileSize = 42
+	group.MaxGroupSize = 4242
+	group.MaxTTL = 3600
+	err = b.CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+
+	err = b.AddGroupMember(group.ID, user.ID)
+	require.NoError(t, err, "unable to add group member")
+
 	// Anonymous Upload
 	upload := &common.Upload{}
 	upload.ID = "UPLOAD1XXXXXXXXX"
@@ -72,6 +86,8 @@ func generateTestData(t *testing.T, b *Backend) {
 	upload.IsAdmin = true
 	upload.OneShot = true
 	upload.Removable = true
+	upload.MaxDownloads = 3
+	upload.DownloadReceipt = true
 	upload.Comments = "愛 الحب 사랑 αγάπη любовь प्यार Սեր माया"
 	upload.Login = "foo"
 	upload.Password = "bar"
@@ -86,6 +102,7 @@ func generateTestData(t *testing.T, b *Backend) {
 	file.Md5 = "ccea80b85af4f156af9d4d3b94e91a5e"
 	file.Name = "愛愛愛"
 	file.BackendDetails = "{foo:\"bar\"}"
+	file.Downloads = 1
 	file.Reference = "1"
 	file.Type = "application/awesome"
 	file.Status = common.FileUploaded
@@ -112,6 +129,7 @@ func generateTestData(t *testing.T, b *Backend) {
 	upload3.UploadToken = "UPLOADTOKENXXXXXXXXXXXXXXXXXXXXX"
 	upload3.User = user.ID
 	upload3.Token = userToken.Token
+	upload3.Group = group.ID
 	file3 := upload3.NewFile()
 	file3.ID = "FILE3XXXXXXXXXXX"
 	file3.Name = "filename"
@@ -132,6 +150,69 @@ func generateTestData(t *testing.T, b *Backend) {
 
 	err = b.CreateUpload(upload4)
 	require.NoError(t, err, "unable to save upload metadata")
+
+	// Deduplicated file content
+	blob := &common.Blob{}
+	blob.ID = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	blob.Size = 42
+	blob.BackendDetails = "{foo:\"bar\"}"
+	_, err = b.AddBlobReference(file.ID, blob)
+	require.NoError(t, err, "unable to save blob metadata")
+
+	// Pending webhook delivery
+	delivery := &common.WebhookDelivery{}
+	delivery.ID = "DELIVERY1XXXXXXXXXXXXXXXXXXXXXXX"
+	delivery.URL = "https://hooks.example.com/plik"
+	delivery.Event = common.EventUploadCreated
+	delivery.Payload = "{\"type\":\"upload_created\"}"
+	delivery.NextAttempt = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = b.CreateWebhookDelivery(delivery)
+	require.NoError(t, err, "unable to save webhook delivery metadata")
+
+	// Audit event
+	event := common.NewAuditEvent(common.AuditDownload)
+	event.ID = "AUDIT1XXXXXXXXXX"
+	event.UserID = user.ID
+	event.TokenFingerprint = userToken.Fingerprint()
+	event.SourceIP = "1.3.3.7"
+	event.UserAgent = "curl/7.0"
+	event.UploadID = upload.ID
+	event.FileID = file.ID
+	event.SetStatus(200)
+	event.CreatedAt = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = b.CreateAuditEvent(event)
+	require.NoError(t, err, "unable to save audit event metadata")
+
+	// Upload request
+	request := &common.UploadRequest{}
+	request.ID = "REQUEST1XXXXXXXX"
+	request.Token = "REQUESTTOKENXXXXXXXXXXXXXXXXXXXX"
+	request.UserID = user.ID
+	request.UploadID = upload2.ID
+	request.Comment = "愛 الحب 사랑 αγάπη любовь प्यार Սեր माया"
+	request.MaxFiles = 10
+	request.MaxFileSize = 42
+	request.MaxSize = 4242
+	request.Files = 1
+	request.Size = 42
+	request.CreatedAt = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	requestDeadline := time.Date(2000, 1, 1, 1, 0, 0, 0, time.UTC)
+	request.ExpireAt = &requestDeadline
+	request.LastUsedAt = &requestDeadline
+	err = b.CreateUploadRequest(request)
+	require.NoError(t, err, "unable to save upload request metadata")
+
+	// Pending email
+	email := &common.Email{}
+	email.ID = "EMAIL1XXXXXXXXXXXXXXXXXXXXXXXXXX"
+	email.To = "plik@root.gg"
+	email.Subject = "愛 الحب 사랑 αγάπη любовь प्यार Սեր माया"
+	email.Text = "text"
+	email.HTML = "<p>html</p>"
+	email.NextAttempt = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	email.CreatedAt = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = b.CreateEmail(email)
+	require.NoError(t, err, "unable to save email metadata")
 }
 
 func loadSQLDump(t *testing.T, path string) {
@@ -444,3 +525,31 @@ func TestMigrationsFromSQLDumps(t *testing.T) {
 		shutdownTestMetadataBackend(b)
 	}
 }
+
+func TestMigrationAuditTokenFingerprint(t *testing.T) {
+	path := "dumps/" + getTestBackend() + "/0020-blob-data-id." + getTestBackend() + ".dump"
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	loadSQLDump(t, path)
+
+	testConfig := &Config{}
+	*testConfig = *metadataBackendConfig
+	testConfig.Debug = false
+	testConfig.EraseFirst = false
+	b, err := NewBackend(testConfig, logger.NewLogger())
+	require.NoError(t, err, "unable to create metadata backend")
+	defer shutdownTestMetadataBackend(b)
+
+	require.False(t, b.db.Migrator().HasColumn("audit", "token"), "audit token column should have been removed")
+
+	var events []*common.AuditEvent
+	err = b.ForEachAuditEvent(func(event *common.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	require.NoError(t, err, "unable to get audit events")
+	require.Len(t, events, 1, "invalid audit events count")
+	require.Equal(t, common.TokenFingerprint("8cbaeacd-6a3e-4636-4200-607a6e240688"), events[0].TokenFingerprint, "invalid audit event token fingerprint")
+}
@@ -1,10 +1,14 @@
 package metadata
 
-import "github.com/root-gg/plik/server/common"
+import (
+	"gorm.io/gorm/clause"
+
+	"github.com/root-gg/plik/server/common"
+)
 
 // GetUploadStatistics return statistics about uploads
-// for userID and tokenStr params : nil doesn't activate the filter, empty string enables the filter with an empty value to generate statistics about anonymous upload
-func (b *Backend) GetUploadStatistics(userID *string, tokenStr *string) (uploads int, files int, size int64, err error) {
+// for userID, tokenStr and groupID params : nil doesn't activate the filter, empty string enables the filter with an empty value to generate statistics about anonymous upload
+func (b *Backend) GetUploadStatistics(userID *string, tokenStr *string, groupID *string) (uploads int, files int, size int64, err error) {
 
 	// Count uploads
 	stmt := b.db.Model(&common.Upload{})
@@ -14,6 +18,10 @@ func (b *Backend) GetUploadStatistics(userID *string, tokenStr *string) (uploads
 	if tokenStr != nil {
 		stmt = stmt.Where("uploads.token = ?", tokenStr)
 	}
+	if groupID != nil {
+		// group is a reserved SQL keyword so let gorm quote the column name
+		stmt = stmt.Where(clause.Eq{Column: clause.Column{Table: "uploads", Name: "group"}, Value: *groupID})
+	}
 
 	var uploadsCount int64 // Gorm V2 requires int64 for counts
 	err = stmt.Count(&uploadsCount).Error
@@ -23,7 +31,7 @@ func (b *Backend) GetUploadStatistics(userID *string, tokenStr *string) (uploads
 
 	// Count files
 	stmt = b.db.Model(&common.File{}).Select("count(files.id), coalesce(sum(size),0)").Where("files.status = ?", common.FileUploaded)
-	if userID != nil || tokenStr != nil {
+	if userID != nil || tokenStr != nil || groupID != nil {
 		stmt = stmt.Joins("join uploads on uploads.id = files.upload_id")
 		if userID != nil {
 			stmt = stmt.Where("uploads.user = ?", userID)
@@ -31,6 +39,9 @@ func (b *Backend) GetUploadStatistics(userID *string, tokenStr *string) (uploads
 		if tokenStr != nil {
 			stmt = stmt.Where("uploads.token = ?", tokenStr)
 		}
+		if groupID != nil {
+			stmt = stmt.Where(clause.Eq{Column: clause.Column{Table: "uploads", Name: "group"}, Value: *groupID})
+		}
 	}
 
 	err = stmt.Row().Scan(&files, &size)
@@ -44,7 +55,40 @@ func (b *Backend) GetUploadStatistics(userID *string, tokenStr *string) (uploads
 // GetUserStatistics return statistics about user uploads
 // for tokenStr params : nil doesn't activate the filter, empty string enables the filter with an empty value to generate statistics about upload without a token
 func (b *Backend) GetUserStatistics(userID string, tokenStr *string) (stats *common.UserStats, err error) {
-	uploads, files, size, err := b.GetUploadStatistics(&userID, tokenStr)
+	uploads, files, size, err := b.GetUploadStatistics(&userID, tokenStr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stats = &common.UserStats{
+		Uploads:   uploads,
+		Files:     files,
+		TotalSize: size,
+	}
+
+	return stats, nil
+}
+
+// GetUserPersonalStatistics return statistics about user uploads that are not owned by a group
+func (b *Backend) GetUserPersonalStatistics(userID string) (stats *common.UserStats, err error) {
+	noGroup := ""
+	uploads, files, size, err := b.GetUploadStatistics(&userID, nil, &noGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	stats = &common.UserStats{
+		Uploads:   uploads,
+		Files:     files,
+		TotalSize: size,
+	}
+
+	return stats, nil
+}
+
+// GetGroupStatistics return statistics about group uploads
+func (b *Backend) GetGroupStatistics(groupID string) (stats *common.UserStats, err error) {
+	uploads, files, size, err := b.GetUploadStatistics(nil, nil, &groupID)
 	if err != nil {
 		return nil, err
 	}
@@ -65,13 +109,13 @@ func (b *Backend) GetServerStatistics() (stats *common.ServerStats, err error) {
 		return nil, err
 	}
 
-	uploads, files, size, err := b.GetUploadStatistics(nil, nil)
+	uploads, files, size, err := b.GetUploadStatistics(nil, nil, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	anonID := ""
-	anonUploads, _, anonSize, err := b.GetUploadStatistics(&anonID, nil)
+	anonUploads, _, anonSize, err := b.GetUploadStatistics(&anonID, nil, nil)
 	if err != nil {
 		return nil, err
 	}
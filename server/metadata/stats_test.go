@@ -23,7 +23,7 @@ func TestBackend_GetUploadStatistics(t *testing.T) {
 		createUpload(t, b, upload)
 	}
 
-	uploads, files, totalSize, err := b.GetUploadStatistics(nil, nil)
+	uploads, files, totalSize, err := b.GetUploadStatistics(nil, nil, nil)
 	require.NoError(t, err, "unexpected error")
 	require.Equal(t, 100, uploads, "invalid upload count")
 	require.Equal(t, 1000, files, "invalid file count")
@@ -39,7 +39,7 @@ func TestBackend_GetUploadNoFiles(t *testing.T) {
 		createUpload(t, b, upload)
 	}
 
-	uploads, files, totalSize, err := b.GetUploadStatistics(nil, nil)
+	uploads, files, totalSize, err := b.GetUploadStatistics(nil, nil, nil)
 	require.NoError(t, err, "unexpected error")
 	require.Equal(t, 100, uploads, "invalid upload count")
 	require.Equal(t, 0, files, "invalid file count")
@@ -72,7 +72,7 @@ func TestBackend_GetUploadStatistics_User(t *testing.T) {
 		createUpload(t, b, upload)
 	}
 
-	uploads, files, totalSize, err := b.GetUploadStatistics(&userID, nil)
+	uploads, files, totalSize, err := b.GetUploadStatistics(&userID, nil, nil)
 	require.NoError(t, err, "unexpected error")
 	require.Equal(t, 100, uploads, "invalid upload count")
 	require.Equal(t, 1000, files, "invalid file count")
@@ -109,7 +109,7 @@ func TestBackend_GetUploadStatistics_Token(t *testing.T) {
 		createUpload(t, b, upload)
 	}
 
-	uploads, files, totalSize, err := b.GetUploadStatistics(&userID, &tokenStr)
+	uploads, files, totalSize, err := b.GetUploadStatistics(&userID, &tokenStr, nil)
 	require.NoError(t, err, "unexpected error")
 	require.Equal(t, 50, uploads, "invalid upload count")
 	require.Equal(t, 500, files, "invalid file count")
@@ -148,7 +148,7 @@ func TestBackend_GetUploadStatistics_Anonymous(t *testing.T) {
 
 	userID = ""
 	tokenStr = ""
-	uploads, files, totalSize, err := b.GetUploadStatistics(&userID, &tokenStr)
+	uploads, files, totalSize, err := b.GetUploadStatistics(&userID, &tokenStr, nil)
 	require.NoError(t, err, "unexpected error")
 	require.Equal(t, 100, uploads, "invalid upload count")
 	require.Equal(t, 1000, files, "invalid file count")
@@ -176,6 +176,40 @@ func TestBackend_GetUserStatistics(t *testing.T) {
 	require.Equal(t, int64(2000), stats.TotalSize, "invalid file size")
 }
 
+func TestBackend_GetGroupStatistics(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	for i := 1; i <= 10; i++ {
+		upload := &common.Upload{User: "user_id", Comments: fmt.Sprintf("%d", i)}
+		if i%2 == 0 {
+			upload.Group = "group"
+		}
+		for j := 1; j <= 10; j++ {
+			file := upload.NewFile()
+			file.Size = 2
+			file.Status = common.FileUploaded
+		}
+		createUpload(t, b, upload)
+	}
+
+	stats, err := b.GetGroupStatistics("group")
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, 5, stats.Uploads, "invalid upload count")
+	require.Equal(t, 50, stats.Files, "invalid file count")
+	require.Equal(t, int64(100), stats.TotalSize, "invalid file size")
+
+	stats, err = b.GetUserPersonalStatistics("user_id")
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, 5, stats.Uploads, "invalid upload count")
+	require.Equal(t, 50, stats.Files, "invalid file count")
+	require.Equal(t, int64(100), stats.TotalSize, "invalid file size")
+
+	stats, err = b.GetUserStatistics("user_id", nil)
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, 10, stats.Uploads, "invalid upload count")
+}
+
 func TestBackend_GetServerStatistics(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
 	"gorm.io/gorm"
@@ -57,6 +58,28 @@ func (b *Backend) DeleteToken(tokenStr string) (deleted bool, err error) {
 	return result.RowsAffected > 0, err
 }
 
+// TouchToken update the token last usage date
+func (b *Backend) TouchToken(token *common.Token) (err error) {
+	now := time.Now()
+	err = b.db.Model(token).UpdateColumn("last_used_at", &now).Error
+	if err != nil {
+		return err
+	}
+
+	token.LastUsedAt = &now
+	return nil
+}
+
+// DeleteExpiredTokens remove the tokens whose expiration date is passed
+func (b *Backend) DeleteExpiredTokens() (deleted int, err error) {
+	result := b.db.Where("expire_at IS NOT NULL AND expire_at < ?", time.Now()).Delete(&common.Token{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("unable to delete expired tokens : %s", result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
+
 // CountUserTokens count how many token a user has
 func (b *Backend) CountUserTokens(userID string) (count int, err error) {
 	var c int64 // Gorm V2 needs int64 for counts
@@ -3,6 +3,7 @@ package metadata
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -128,3 +129,53 @@ func TestBackend_ForEachToken(t *testing.T) {
 	err = b.ForEachToken(f)
 	require.Errorf(t, err, "expected")
 }
+
+func TestBackend_TouchToken(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	createUser(t, b, user)
+	require.Nil(t, token.LastUsedAt, "invalid token last usage date")
+
+	err := b.TouchToken(token)
+	require.NoError(t, err, "touch token error")
+	require.NotNil(t, token.LastUsedAt, "missing token last usage date")
+
+	result, err := b.GetToken(token.Token)
+	require.NoError(t, err, "get token error")
+	require.NotNil(t, result.LastUsedAt, "missing token last usage date")
+}
+
+func TestBackend_DeleteExpiredTokens(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+
+	expired := user.NewToken()
+	deadline := time.Now().Add(-time.Hour)
+	expired.ExpireAt = &deadline
+
+	valid := user.NewToken()
+	deadline2 := time.Now().Add(time.Hour)
+	valid.ExpireAt = &deadline2
+
+	createUser(t, b, user)
+
+	deleted, err := b.DeleteExpiredTokens()
+	require.NoError(t, err, "delete expired tokens error")
+	require.Equal(t, 1, deleted, "invalid deleted tokens count")
+
+	result, err := b.GetToken(expired.Token)
+	require.NoError(t, err, "get token error")
+	require.Nil(t, result, "expired token should have been deleted")
+
+	for _, tokenStr := range []string{token.Token, valid.Token} {
+		result, err = b.GetToken(tokenStr)
+		require.NoError(t, err, "get token error")
+		require.NotNil(t, result, "token should not have been deleted")
+	}
+}
@@ -21,6 +21,62 @@ func (b *Backend) UpdateUploadExpirationDate(upload *common.Upload) (err error)
 	return b.db.Model(upload).Update("expire_at", upload.ExpireAt).Error
 }
 
+// UpdateUploadPassword updates an upload basic auth credentials hash in DB
+func (b *Backend) UpdateUploadPassword(upload *common.Upload) (err error) {
+	return b.db.Model(upload).Update("password", upload.Password).Error
+}
+
+// UpdateUploadDownloadReceipt updates an upload download receipt flag in DB
+func (b *Backend) UpdateUploadDownloadReceipt(upload *common.Upload) (err error) {
+	return b.db.Model(upload).Update("download_receipt", upload.DownloadReceipt).Error
+}
+
+// ClaimUploadDownloadReceipt atomically clears the download receipt flag of an upload.
+// It returns true only for the caller that cleared the flag so the receipt is sent once.
+func (b *Backend) ClaimUploadDownloadReceipt(uploadID string) (claimed bool, err error) {
+	result := b.db.Model(&common.Upload{}).
+		Where("id = ? AND download_receipt = ?", uploadID, true).
+		Update("download_receipt", false)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == int64(1), nil
+}
+
+// upgradeLegacyUploadPasswords wraps the legacy md5 upload basic auth credentials hashes into argon2id hashes
+func (b *Backend) upgradeLegacyUploadPasswords(tx *gorm.DB) (upgraded int, err error) {
+	type Upload struct {
+		ID       string
+		Password string
+	}
+
+	var uploads []*Upload
+	err = tx.Unscoped().Model(&common.Upload{}).Select("id", "password").Where(&common.Upload{ProtectedByPassword: true}).Find(&uploads).Error
+	if err != nil {
+		return 0, fmt.Errorf("unable to get password protected uploads : %s", err)
+	}
+
+	for _, upload := range uploads {
+		if !common.IsLegacyUploadCredentialsHash(upload.Password) {
+			continue
+		}
+
+		hash, err := common.UpgradeLegacyUploadCredentialsHash(upload.Password)
+		if err != nil {
+			return upgraded, err
+		}
+
+		err = tx.Unscoped().Model(&common.Upload{}).Where(&common.Upload{ID: upload.ID}).Update("password", hash).Error
+		if err != nil {
+			return upgraded, fmt.Errorf("unable to update upload %s password : %s", upload.ID, err)
+		}
+
+		upgraded++
+	}
+
+	return upgraded, nil
+}
+
 // GetUpload return an upload from the DB ( return nil and no error if not found )
 func (b *Backend) GetUpload(ID string) (upload *common.Upload, err error) {
 	upload = &common.Upload{}
@@ -50,13 +106,23 @@ func getUploadsWhereClause(userID string, tokenStr string) *common.Upload {
 // userID and tokenStr are filters
 // set withFiles to also fetch the files
 func (b *Backend) GetUploads(userID string, tokenStr string, withFiles bool, pagingQuery *common.PagingQuery) (uploads []*common.Upload, cursor *paginator.Cursor, err error) {
+	return b.getUploads(getUploadsWhereClause(userID, tokenStr), withFiles, pagingQuery)
+}
+
+// GetGroupUploads return the uploads owned by a group from DB
+// set withFiles to also fetch the files
+func (b *Backend) GetGroupUploads(groupID string, withFiles bool, pagingQuery *common.PagingQuery) (uploads []*common.Upload, cursor *paginator.Cursor, err error) {
+	return b.getUploads(&common.Upload{Group: groupID}, withFiles, pagingQuery)
+}
+
+func (b *Backend) getUploads(whereClause *common.Upload, withFiles bool, pagingQuery *common.PagingQuery) (uploads []*common.Upload, cursor *paginator.Cursor, err error) {
 	if pagingQuery == nil {
 		return nil, nil, fmt.Errorf("missing paging query")
 	}
 
 	stmt := b.db.
 		Model(&common.Upload{}).
-		Where(getUploadsWhereClause(userID, tokenStr))
+		Where(whereClause)
 
 	if withFiles {
 		stmt = stmt.Preload("Files")
@@ -167,8 +233,9 @@ func (b *Backend) RemoveUpload(uploadID string) (err error) {
 	return err
 }
 
-// RemoveExpiredUploads soft delete all expired uploads and remove all their files
-func (b *Backend) RemoveExpiredUploads() (removed int, err error) {
+// RemoveExpiredUploads soft delete all expired uploads and remove all their files.
+// If not nil f is called for each removed upload.
+func (b *Backend) RemoveExpiredUploads(f func(upload *common.Upload)) (removed int, err error) {
 	rows, err := b.db.Model(&common.Upload{}).Where("expire_at < ?", time.Now()).Rows()
 	if err != nil {
 		return 0, fmt.Errorf("unable to fetch expired uploads : %s", err)
@@ -189,6 +256,10 @@ func (b *Backend) RemoveExpiredUploads() (removed int, err error) {
 			continue
 		}
 
+		if f != nil {
+			f(upload)
+		}
+
 		removed++
 	}
 
@@ -338,7 +409,7 @@ func (b *Backend) removeUploadFiles(tx *gorm.DB, uploadID string) (err error) {
 
 	err = tx.Model(&common.File{}).
 		Where(&common.File{UploadID: uploadID}).
-		Where(tx.Where(&common.File{Status: common.FileUploading}).Or(&common.File{Status: common.FileUploaded})).
+		Where(tx.Where(&common.File{Status: common.FileUploading}).Or(&common.File{Status: common.FileUploaded}).Or(&common.File{Status: common.FileQuarantined})).
 		Update("status", common.FileRemoved).Error
 
 	if err != nil {
@@ -0,0 +1,171 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+	"gorm.io/gorm"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CreateUploadRequest create a new upload request in DB
+func (b *Backend) CreateUploadRequest(request *common.UploadRequest) (err error) {
+	return b.db.Create(request).Error
+}
+
+// GetUploadRequest return an upload request from the DB ( return nil and no error if not found )
+func (b *Backend) GetUploadRequest(ID string) (request *common.UploadRequest, err error) {
+	request = &common.UploadRequest{}
+	err = b.db.Where(&common.UploadRequest{ID: ID}).Take(request).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return request, err
+}
+
+// GetUploadRequests return all the upload requests of a user
+func (b *Backend) GetUploadRequests(userID string, pagingQuery *common.PagingQuery) (requests []*common.UploadRequest, cursor *paginator.Cursor, err error) {
+	if pagingQuery == nil {
+		return nil, nil, fmt.Errorf("missing paging query")
+	}
+
+	stmt := b.db.Model(&common.UploadRequest{}).Where(&common.UploadRequest{UserID: userID})
+
+	p := pagingQuery.Paginator()
+	p.SetKeys("CreatedAt", "ID")
+
+	result, c, err := p.Paginate(stmt, &requests)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	return requests, &c, err
+}
+
+// ReserveUploadRequestFile increment the upload request file count if the upload request is not full
+// and reserve the maximum size of the file in the same update ( see UploadRequest.GetMaxFileSize ).
+// This must be done before adding the file to avoid exceeding the limits with concurrent uploads
+func (b *Backend) ReserveUploadRequestFile(request *common.UploadRequest) (ok bool, err error) {
+	for {
+		var reserved int64
+		if request.MaxSize > 0 {
+			reserved = request.GetMaxFileSize()
+		}
+
+		result := b.db.Model(&common.UploadRequest{}).
+			Where(&common.UploadRequest{ID: request.ID}).
+			Where("max_files = 0 OR files < max_files").
+			Where("max_size = 0 OR (size < max_size AND size + ? <= max_size)", reserved).
+			Updates(map[string]interface{}{"files": gorm.Expr("files + 1"), "size": gorm.Expr("size + ?", reserved)})
+		if result.Error != nil {
+			return false, fmt.Errorf("unable to update upload request metadata : %s", result.Error)
+		}
+
+		if result.RowsAffected > 0 {
+			request.Files++
+			request.Size += reserved
+			request.Reserved = reserved
+			return true, nil
+		}
+
+		// The reservation fails if other files have been added or reserved meanwhile,
+		// try again with the up to date usage until the upload request is full
+		current, err := b.GetUploadRequest(request.ID)
+		if err != nil {
+			return false, fmt.Errorf("unable to get upload request metadata : %s", err)
+		}
+		if current == nil || current.IsFull() || (current.Files == request.Files && current.Size == request.Size) {
+			return false, nil
+		}
+
+		request.Files = current.Files
+		request.Size = current.Size
+	}
+}
+
+// ReleaseUploadRequestFile decrement the upload request file count and free the reserved size if adding the file has failed
+func (b *Backend) ReleaseUploadRequestFile(request *common.UploadRequest) (err error) {
+	err = b.db.Model(&common.UploadRequest{}).
+		Where(&common.UploadRequest{ID: request.ID}).
+		Where("files > 0").
+		Updates(map[string]interface{}{"files": gorm.Expr("files - 1"), "size": gorm.Expr("size - ?", request.Reserved)}).Error
+	if err != nil {
+		return fmt.Errorf("unable to update upload request metadata : %s", err)
+	}
+
+	request.Files--
+	request.Size -= request.Reserved
+	request.Reserved = 0
+	return nil
+}
+
+// UpdateUploadRequestUsage replace the size reserved for a file successfully added through the upload request by its actual size
+func (b *Backend) UpdateUploadRequestUsage(request *common.UploadRequest, size int64) (err error) {
+	now := time.Now()
+	err = b.db.Model(&common.UploadRequest{}).
+		Where(&common.UploadRequest{ID: request.ID}).
+		Updates(map[string]interface{}{"size": gorm.Expr("size + ?", size-request.Reserved), "last_used_at": now}).Error
+	if err != nil {
+		return fmt.Errorf("unable to update upload request metadata : %s", err)
+	}
+
+	request.Size += size - request.Reserved
+	request.Reserved = 0
+	request.LastUsedAt = &now
+	return nil
+}
+
+// DeleteUploadRequest delete an upload request from the DB
+// The upload and the files already received are not removed
+func (b *Backend) DeleteUploadRequest(ID string) (deleted bool, err error) {
+	result := b.db.Where(&common.UploadRequest{ID: ID}).Delete(&common.UploadRequest{})
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to delete upload request metadata : %s", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteExpiredUploadRequests delete the expired upload requests and the upload requests whose upload has been purged
+func (b *Backend) DeleteExpiredUploadRequests() (deleted int, err error) {
+	result := b.db.
+		Where("expire_at IS NOT NULL AND expire_at < ?", time.Now()).
+		Or("upload_id NOT IN (?)", b.db.Unscoped().Model(&common.Upload{}).Select("id")).
+		Delete(&common.UploadRequest{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("unable to delete expired upload requests : %s", result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+// ForEachUploadRequest execute f for every upload request in the database
+func (b *Backend) ForEachUploadRequest(f func(request *common.UploadRequest) error) (err error) {
+	rows, err := b.db.Model(&common.UploadRequest{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		request := &common.UploadRequest{}
+		err = b.db.ScanRows(rows, request)
+		if err != nil {
+			return err
+		}
+		err = f(request)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,356 @@
+package metadata
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func createUploadRequest(t *testing.T, b *Backend, request *common.UploadRequest) {
+	if request.ID == "" {
+		request.ID = common.GenerateRandomID(16)
+	}
+	err := b.CreateUploadRequest(request)
+	require.NoError(t, err, "create upload request error", err)
+}
+
+func TestBackend_CreateUploadRequest(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	request := common.NewUploadRequest()
+	createUploadRequest(t, b, request)
+	require.NotZero(t, request.CreatedAt, "missing creation date")
+
+	err := b.CreateUploadRequest(&common.UploadRequest{ID: request.ID})
+	require.Error(t, err, "create upload request error")
+}
+
+func TestBackend_GetUploadRequest(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	request, err := b.GetUploadRequest("request")
+	require.NoError(t, err, "get upload request error")
+	require.Nil(t, request, "upload request should not exist")
+
+	request = common.NewUploadRequest()
+	request.UserID = "user"
+	request.Comment = "comment"
+	createUploadRequest(t, b, request)
+
+	result, err := b.GetUploadRequest(request.ID)
+	require.NoError(t, err, "get upload request error")
+	require.NotNil(t, result, "missing upload request")
+	require.Equal(t, request.Token, result.Token, "invalid upload request token")
+	require.Equal(t, request.UserID, result.UserID, "invalid upload request user")
+	require.Equal(t, request.Comment, result.Comment, "invalid upload request comment")
+}
+
+func TestBackend_GetUploadRequests(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	for i := 0; i < 3; i++ {
+		createUploadRequest(t, b, &common.UploadRequest{UserID: "user1"})
+	}
+	createUploadRequest(t, b, &common.UploadRequest{UserID: "user2"})
+
+	requests, cursor, err := b.GetUploadRequests("user1", common.NewPagingQuery().WithLimit(2))
+	require.NoError(t, err, "get upload requests error")
+	require.Len(t, requests, 2, "invalid upload requests length")
+	require.NotNil(t, cursor.After, "missing cursor")
+
+	requests, _, err = b.GetUploadRequests("user1", common.NewPagingQuery().WithLimit(10))
+	require.NoError(t, err, "get upload requests error")
+	require.Len(t, requests, 3, "invalid upload requests length")
+
+	_, _, err = b.GetUploadRequests("user1", nil)
+	require.Error(t, err, "missing paging query error expected")
+}
+
+func TestBackend_ReserveUploadRequestFile(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	request := &common.UploadRequest{MaxFiles: 2}
+	createUploadRequest(t, b, request)
+
+	for i := 0; i < 2; i++ {
+		ok, err := b.ReserveUploadRequestFile(request)
+		require.NoError(t, err, "reserve upload request file error")
+		require.True(t, ok, "upload request file should have been reserved")
+	}
+
+	ok, err := b.ReserveUploadRequestFile(request)
+	require.NoError(t, err, "reserve upload request file error")
+	require.False(t, ok, "upload request should be full")
+	require.Equal(t, 2, request.Files, "invalid upload request files")
+
+	err = b.ReleaseUploadRequestFile(request)
+	require.NoError(t, err, "release upload request file error")
+	require.Equal(t, 1, request.Files, "invalid upload request files")
+
+	result, err := b.GetUploadRequest(request.ID)
+	require.NoError(t, err, "get upload request error")
+	require.Equal(t, 1, result.Files, "invalid upload request files")
+
+	ok, err = b.ReserveUploadRequestFile(request)
+	require.NoError(t, err, "reserve upload request file error")
+	require.True(t, ok, "upload request file should have been reserved")
+}
+
+func TestBackend_ReserveUploadRequestFileMaxSize(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	request := &common.UploadRequest{MaxSize: 100}
+	createUploadRequest(t, b, request)
+
+	ok, err := b.ReserveUploadRequestFile(request)
+	require.NoError(t, err, "reserve upload request file error")
+	require.True(t, ok, "upload request file should have been reserved")
+
+	err = b.UpdateUploadRequestUsage(request, 100)
+	require.NoError(t, err, "update upload request usage error")
+
+	ok, err = b.ReserveUploadRequestFile(request)
+	require.NoError(t, err, "reserve upload request file error")
+	require.False(t, ok, "upload request should be full")
+}
+
+func TestBackend_ReserveUploadRequestFileConcurrent(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	request := &common.UploadRequest{MaxFiles: 5}
+	createUploadRequest(t, b, request)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errors []error
+	reserved := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := &common.UploadRequest{ID: request.ID}
+			ok, err := b.ReserveUploadRequestFile(r)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errors = append(errors, err)
+			}
+			if ok {
+				reserved++
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Empty(t, errors, "reserve upload request file error")
+	require.Equal(t, 5, reserved, "invalid reserved files count")
+}
+
+func TestBackend_ReserveUploadRequestFileSize(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	request := &common.UploadRequest{MaxSize: 100}
+	createUploadRequest(t, b, request)
+
+	// The whole remaining size is reserved for the file
+	ok, err := b.ReserveUploadRequestFile(request)
+	require.NoError(t, err, "reserve upload request file error")
+	require.True(t, ok, "upload request file should have been reserved")
+	require.Equal(t, int64(100), request.Reserved, "invalid reserved size")
+	require.Equal(t, int64(100), request.GetMaxFileSize(), "invalid max file size")
+
+	ok, err = b.ReserveUploadRequestFile(&common.UploadRequest{ID: request.ID, MaxSize: 100})
+	require.NoError(t, err, "reserve upload request file error")
+	require.False(t, ok, "upload request should be full")
+
+	// The unused reserved size is freed once the file is added
+	err = b.UpdateUploadRequestUsage(request, 40)
+	require.NoError(t, err, "update upload request usage error")
+	require.Equal(t, int64(40), request.Size, "invalid upload request size")
+
+	other := &common.UploadRequest{ID: request.ID, MaxSize: 100}
+	ok, err = b.ReserveUploadRequestFile(other)
+	require.NoError(t, err, "reserve upload request file error")
+	require.True(t, ok, "upload request file should have been reserved")
+	require.Equal(t, int64(60), other.Reserved, "invalid reserved size")
+
+	err = b.ReleaseUploadRequestFile(other)
+	require.NoError(t, err, "release upload request file error")
+
+	result, err := b.GetUploadRequest(request.ID)
+	require.NoError(t, err, "get upload request error")
+	require.Equal(t, 1, result.Files, "invalid upload request files")
+	require.Equal(t, int64(40), result.Size, "invalid upload request size")
+}
+
+func TestBackend_ReserveUploadRequestFileSizeConcurrent(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	request := &common.UploadRequest{MaxSize: 100, MaxFileSize: 30}
+	createUploadRequest(t, b, request)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errors []error
+	reserved := 0
+	var reservedSize int64
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := &common.UploadRequest{ID: request.ID, MaxSize: request.MaxSize, MaxFileSize: request.MaxFileSize}
+			ok, err := b.ReserveUploadRequestFile(r)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errors = append(errors, err)
+			}
+			if ok {
+				reserved++
+				reservedSize += r.Reserved
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 3 files of 30 bytes and a last one of 10 bytes
+	require.Empty(t, errors, "reserve upload request file error")
+	require.Equal(t, 4, reserved, "invalid reserved files count")
+	require.Equal(t, int64(100), reservedSize, "invalid reserved size")
+
+	result, err := b.GetUploadRequest(request.ID)
+	require.NoError(t, err, "get upload request error")
+	require.Equal(t, int64(100), result.Size, "invalid upload request size")
+}
+
+func TestBackend_UpdateUploadRequestUsage(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	request := &common.UploadRequest{}
+	createUploadRequest(t, b, request)
+
+	err := b.UpdateUploadRequestUsage(request, 42)
+	require.NoError(t, err, "update upload request usage error")
+	err = b.UpdateUploadRequestUsage(request, 42)
+	require.NoError(t, err, "update upload request usage error")
+	require.Equal(t, int64(84), request.Size, "invalid upload request size")
+	require.NotNil(t, request.LastUsedAt, "missing upload request last usage date")
+
+	result, err := b.GetUploadRequest(request.ID)
+	require.NoError(t, err, "get upload request error")
+	require.Equal(t, int64(84), result.Size, "invalid upload request size")
+	require.NotNil(t, result.LastUsedAt, "missing upload request last usage date")
+}
+
+func TestBackend_DeleteUploadRequest(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	request := &common.UploadRequest{}
+	createUploadRequest(t, b, request)
+
+	deleted, err := b.DeleteUploadRequest(request.ID)
+	require.NoError(t, err, "delete upload request error")
+	require.True(t, deleted, "upload request should have been deleted")
+
+	result, err := b.GetUploadRequest(request.ID)
+	require.NoError(t, err, "get upload request error")
+	require.Nil(t, result, "upload request should not exist")
+
+	deleted, err = b.DeleteUploadRequest(request.ID)
+	require.NoError(t, err, "delete upload request error")
+	require.False(t, deleted, "upload request should not have been deleted")
+}
+
+func TestBackend_DeleteExpiredUploadRequests(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	createUpload(t, b, upload)
+
+	removedUpload := &common.Upload{}
+	createUpload(t, b, removedUpload)
+	err := b.RemoveUpload(removedUpload.ID)
+	require.NoError(t, err, "remove upload error")
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	expired := &common.UploadRequest{UploadID: upload.ID, ExpireAt: &past}
+	createUploadRequest(t, b, expired)
+
+	valid := &common.UploadRequest{UploadID: upload.ID, ExpireAt: &future}
+	createUploadRequest(t, b, valid)
+
+	// The upload is soft deleted but not purged yet
+	removed := &common.UploadRequest{UploadID: removedUpload.ID, ExpireAt: &future}
+	createUploadRequest(t, b, removed)
+
+	orphan := &common.UploadRequest{UploadID: "purged", ExpireAt: &future}
+	createUploadRequest(t, b, orphan)
+
+	deleted, err := b.DeleteExpiredUploadRequests()
+	require.NoError(t, err, "delete expired upload requests error")
+	require.Equal(t, 2, deleted, "invalid deleted upload requests count")
+
+	for _, request := range []*common.UploadRequest{valid, removed} {
+		result, err := b.GetUploadRequest(request.ID)
+		require.NoError(t, err, "get upload request error")
+		require.NotNil(t, result, "upload request should not have been deleted")
+	}
+
+	for _, request := range []*common.UploadRequest{expired, orphan} {
+		result, err := b.GetUploadRequest(request.ID)
+		require.NoError(t, err, "get upload request error")
+		require.Nil(t, result, "upload request should have been deleted")
+	}
+}
+
+func TestBackend_ForEachUploadRequest(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	createUploadRequest(t, b, &common.UploadRequest{})
+	createUploadRequest(t, b, &common.UploadRequest{})
+
+	count := 0
+	err := b.ForEachUploadRequest(func(request *common.UploadRequest) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err, "for each upload request error")
+	require.Equal(t, 2, count, "invalid upload requests count")
+}
+
+func TestBackend_DeleteUserUploadRequests(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	createUser(t, b, user)
+
+	request := &common.UploadRequest{UserID: user.ID}
+	createUploadRequest(t, b, request)
+
+	deleted, err := b.DeleteUser(user.ID)
+	require.NoError(t, err, "delete user error")
+	require.True(t, deleted, "user should have been deleted")
+
+	result, err := b.GetUploadRequest(request.ID)
+	require.NoError(t, err, "get upload request error")
+	require.Nil(t, result, "upload request should have been deleted")
+}
@@ -7,6 +7,7 @@ import (
 
 	"gorm.io/gorm"
 
+	"github.com/root-gg/utils"
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
@@ -51,6 +52,95 @@ func TestBackend_GetUpload(t *testing.T) {
 	require.Equal(t, upload.UploadToken, result.UploadToken, "invalid upload token")
 }
 
+func TestBackend_UpdateUploadPassword(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{ProtectedByPassword: true, Password: "foo"}
+	createUpload(t, b, upload)
+
+	upload.Password = "bar"
+	err := b.UpdateUploadPassword(upload)
+	require.NoError(t, err, "update upload password error")
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, "bar", result.Password, "invalid upload password")
+}
+
+func TestBackend_ClaimUploadDownloadReceipt(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	createUpload(t, b, upload)
+
+	claimed, err := b.ClaimUploadDownloadReceipt(upload.ID)
+	require.NoError(t, err, "claim download receipt error")
+	require.False(t, claimed, "download receipt should not be claimed")
+
+	upload.DownloadReceipt = true
+	err = b.UpdateUploadDownloadReceipt(upload)
+	require.NoError(t, err, "update download receipt error")
+
+	claimed, err = b.ClaimUploadDownloadReceipt(upload.ID)
+	require.NoError(t, err, "claim download receipt error")
+	require.True(t, claimed, "download receipt should be claimed")
+
+	claimed, err = b.ClaimUploadDownloadReceipt(upload.ID)
+	require.NoError(t, err, "claim download receipt error")
+	require.False(t, claimed, "download receipt should be claimed only once")
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.False(t, result.DownloadReceipt, "invalid upload download receipt")
+}
+
+func TestBackend_UpgradeLegacyUploadPasswords(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	credentials := common.EncodeAuthBasicHeader("login", "password")
+	legacyHash, err := utils.Md5sum(credentials)
+	require.NoError(t, err, "md5sum error")
+
+	hash, err := common.HashUploadCredentials(credentials)
+	require.NoError(t, err, "hash credentials error")
+
+	legacy := &common.Upload{ProtectedByPassword: true, Password: legacyHash}
+	createUpload(t, b, legacy)
+
+	removed := &common.Upload{ProtectedByPassword: true, Password: legacyHash}
+	createUpload(t, b, removed)
+	err = b.RemoveUpload(removed.ID)
+	require.NoError(t, err, "remove upload error")
+
+	upToDate := &common.Upload{ProtectedByPassword: true, Password: hash}
+	createUpload(t, b, upToDate)
+
+	unprotected := &common.Upload{}
+	createUpload(t, b, unprotected)
+
+	upgraded, err := b.upgradeLegacyUploadPasswords(b.db)
+	require.NoError(t, err, "upgrade legacy upload passwords error")
+	require.Equal(t, 2, upgraded, "invalid upgraded count")
+
+	result, err := b.GetUpload(legacy.ID)
+	require.NoError(t, err, "get upload error")
+	require.False(t, common.IsLegacyUploadCredentialsHash(result.Password), "legacy hash should have been upgraded")
+	ok, needRehash := common.CheckUploadCredentials(credentials, result.Password)
+	require.True(t, ok, "upgraded hash should match the credentials")
+	require.True(t, needRehash, "upgraded hash should need rehash")
+
+	result, err = b.GetUpload(upToDate.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, hash, result.Password, "up to date hash should not have changed")
+
+	upgraded, err = b.upgradeLegacyUploadPasswords(b.db)
+	require.NoError(t, err, "upgrade legacy upload passwords error")
+	require.Equal(t, 0, upgraded, "invalid upgraded count")
+}
+
 func TestBackend_GetUpload_NotFound(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
@@ -463,9 +553,13 @@ func TestBackend_DeleteExpiredUploads(t *testing.T) {
 	err = b.db.Save(upload3).Error
 	require.NoError(t, err, "update upload error")
 
-	removed, err := b.RemoveExpiredUploads()
+	var expired []string
+	removed, err := b.RemoveExpiredUploads(func(upload *common.Upload) {
+		expired = append(expired, upload.ID)
+	})
 	require.Nil(t, err, "delete expired upload error")
 	require.Equal(t, 1, removed, "removed expired upload count mismatch")
+	require.Equal(t, []string{upload3.ID}, expired, "invalid expired uploads")
 }
 
 func TestBackend_PurgeDeletedUploads(t *testing.T) {
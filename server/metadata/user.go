@@ -136,6 +136,18 @@ func (b *Backend) DeleteUser(userID string) (deleted bool, err error) {
 			return fmt.Errorf("unable to delete tokens metadata : %s", err)
 		}
 
+		// Delete user upload requests
+		err = tx.Where(&common.UploadRequest{UserID: userID}).Delete(&common.UploadRequest{}).Error
+		if err != nil {
+			return fmt.Errorf("unable to delete upload requests metadata : %s", err)
+		}
+
+		// Delete user group memberships
+		err = tx.Where(&common.GroupMember{UserID: userID}).Delete(&common.GroupMember{}).Error
+		if err != nil {
+			return fmt.Errorf("unable to delete group members metadata : %s", err)
+		}
+
 		// Delete user
 		result := tx.Where(&common.User{ID: userID}).Delete(common.User{})
 		if result.Error != nil {
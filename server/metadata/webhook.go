@@ -0,0 +1,85 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CreateWebhookDelivery add a webhook delivery to the outbox
+func (b *Backend) CreateWebhookDelivery(delivery *common.WebhookDelivery) (err error) {
+	return b.db.Create(delivery).Error
+}
+
+// GetPendingWebhookDeliveries return at most limit webhook deliveries that are due for an attempt
+func (b *Backend) GetPendingWebhookDeliveries(limit int) (deliveries []*common.WebhookDelivery, err error) {
+	err = b.db.Where("next_attempt <= ?", time.Now()).Order("next_attempt").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ClaimWebhookDelivery reserve a webhook delivery until the given date so no other
+// instance tries to send it concurrently. The number of attempts ensure that the delivery
+// has not been claimed since loaded.
+func (b *Backend) ClaimWebhookDelivery(delivery *common.WebhookDelivery, until time.Time) (err error) {
+	result := b.db.Model(&common.WebhookDelivery{}).
+		Where("id = ? AND attempts = ?", delivery.ID, delivery.Attempts).
+		Updates(map[string]interface{}{"attempts": delivery.Attempts + 1, "next_attempt": until})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("webhook delivery already claimed")
+	}
+
+	delivery.Attempts++
+	delivery.NextAttempt = until
+
+	return nil
+}
+
+// RetryWebhookDelivery schedule the next attempt of a failed webhook delivery
+func (b *Backend) RetryWebhookDelivery(delivery *common.WebhookDelivery, next time.Time, lastError string) (err error) {
+	err = b.db.Model(&common.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(map[string]interface{}{"next_attempt": next, "last_error": lastError}).Error
+	if err != nil {
+		return err
+	}
+
+	delivery.NextAttempt = next
+	delivery.LastError = lastError
+
+	return nil
+}
+
+// DeleteWebhookDelivery remove a webhook delivery from the outbox
+func (b *Backend) DeleteWebhookDelivery(deliveryID string) (err error) {
+	return b.db.Delete(&common.WebhookDelivery{ID: deliveryID}).Error
+}
+
+// ForEachWebhookDelivery execute f for every webhook delivery in the database
+func (b *Backend) ForEachWebhookDelivery(f func(delivery *common.WebhookDelivery) error) (err error) {
+	rows, err := b.db.Model(&common.WebhookDelivery{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		delivery := &common.WebhookDelivery{}
+		err = b.db.ScanRows(rows, delivery)
+		if err != nil {
+			return err
+		}
+		err = f(delivery)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
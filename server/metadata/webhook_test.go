@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func createWebhookDelivery(t *testing.T, b *Backend, next time.Time) *common.WebhookDelivery {
+	delivery := &common.WebhookDelivery{}
+	delivery.ID = common.GenerateRandomID(32)
+	delivery.URL = "https://hooks.example.com"
+	delivery.Event = common.EventUploadCreated
+	delivery.Payload = "{}"
+	delivery.NextAttempt = next
+
+	err := b.CreateWebhookDelivery(delivery)
+	require.NoError(t, err, "create webhook delivery error")
+
+	return delivery
+}
+
+func TestBackend_GetPendingWebhookDeliveries(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	delivery1 := createWebhookDelivery(t, b, time.Now().Add(-time.Minute))
+	delivery2 := createWebhookDelivery(t, b, time.Now().Add(-time.Hour))
+	createWebhookDelivery(t, b, time.Now().Add(time.Hour))
+
+	deliveries, err := b.GetPendingWebhookDeliveries(10)
+	require.NoError(t, err, "get pending webhook deliveries error")
+	require.Len(t, deliveries, 2, "invalid pending webhook deliveries count")
+	require.Equal(t, delivery2.ID, deliveries[0].ID, "invalid pending webhook delivery order")
+	require.Equal(t, delivery1.ID, deliveries[1].ID, "invalid pending webhook delivery order")
+
+	deliveries, err = b.GetPendingWebhookDeliveries(1)
+	require.NoError(t, err, "get pending webhook deliveries error")
+	require.Len(t, deliveries, 1, "invalid pending webhook deliveries count")
+}
+
+func TestBackend_ClaimWebhookDelivery(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	delivery := createWebhookDelivery(t, b, time.Now())
+
+	deliveries, err := b.GetPendingWebhookDeliveries(10)
+	require.NoError(t, err, "get pending webhook deliveries error")
+	require.Len(t, deliveries, 1, "invalid pending webhook deliveries count")
+
+	err = b.ClaimWebhookDelivery(delivery, time.Now().Add(time.Minute))
+	require.NoError(t, err, "claim webhook delivery error")
+	require.Equal(t, 1, delivery.Attempts, "invalid webhook delivery attempts")
+
+	// Already claimed by another instance
+	err = b.ClaimWebhookDelivery(deliveries[0], time.Now().Add(time.Minute))
+	require.Error(t, err, "claim webhook delivery error expected")
+
+	deliveries, err = b.GetPendingWebhookDeliveries(10)
+	require.NoError(t, err, "get pending webhook deliveries error")
+	require.Len(t, deliveries, 0, "invalid pending webhook deliveries count")
+}
+
+func TestBackend_RetryWebhookDelivery(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	delivery := createWebhookDelivery(t, b, time.Now().Add(time.Hour))
+
+	err := b.RetryWebhookDelivery(delivery, time.Now().Add(-time.Minute), "error")
+	require.NoError(t, err, "retry webhook delivery error")
+
+	deliveries, err := b.GetPendingWebhookDeliveries(10)
+	require.NoError(t, err, "get pending webhook deliveries error")
+	require.Len(t, deliveries, 1, "invalid pending webhook deliveries count")
+	require.Equal(t, "error", deliveries[0].LastError, "invalid webhook delivery last error")
+}
+
+func TestBackend_DeleteWebhookDelivery(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	delivery := createWebhookDelivery(t, b, time.Now())
+
+	err := b.DeleteWebhookDelivery(delivery.ID)
+	require.NoError(t, err, "delete webhook delivery error")
+
+	count := 0
+	err = b.ForEachWebhookDelivery(func(delivery *common.WebhookDelivery) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err, "for each webhook delivery error")
+	require.Equal(t, 0, count, "invalid webhook delivery count")
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// Audit record an audit event for the request once it has been served.
+// The request fails the event through ctx.Fail so redirected errors are also accounted.
+func Audit(action string) context.Middleware {
+	return func(ctx *context.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			// HEAD requests don't transfer any data
+			if !ctx.GetConfig().AuditLog || req.Method == "HEAD" {
+				next.ServeHTTP(resp, req)
+				return
+			}
+
+			event := common.NewAuditEvent(action)
+			ctx.SetAuditEvent(event)
+
+			// Create a response writer that keep track of the response status code
+			statusCodeResponseWriter := newStatusCodeResponseWriter(resp)
+			ctx.SetResp(statusCodeResponseWriter)
+
+			// Serve the request
+			next.ServeHTTP(statusCodeResponseWriter, req)
+
+			if event.Result != common.AuditFailure {
+				event.SetStatus(statusCodeResponseWriter.statusCode)
+			}
+
+			ctx.Audit(event)
+		})
+	}
+}
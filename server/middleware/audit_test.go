@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func getTestAuditEvents(t *testing.T, ctx *context.Context) (events []*common.AuditEvent) {
+	err := ctx.GetMetadataBackend().ForEachAuditEvent(func(event *common.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	require.NoError(t, err, "unable to get audit events")
+	return events
+}
+
+func TestAudit(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(&common.User{ID: "user"})
+	ctx.SetToken(&common.Token{Token: "token"})
+	ctx.SetSourceIP(net.ParseIP("1.3.3.7"))
+	ctx.SetUpload(&common.Upload{ID: "upload"})
+	ctx.SetFile(&common.File{ID: "file"})
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("User-Agent", "curl/7.0")
+
+	rr := ctx.NewRecorder(req)
+	Audit(common.AuditDownload)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+
+	events := getTestAuditEvents(t, ctx)
+	require.Len(t, events, 1, "invalid audit events count")
+	require.Equal(t, common.AuditDownload, events[0].Action, "invalid audit event action")
+	require.Equal(t, common.AuditSuccess, events[0].Result, "invalid audit event result")
+	require.Equal(t, http.StatusOK, events[0].Status, "invalid audit event status")
+	require.Equal(t, "user", events[0].UserID, "invalid audit event user")
+	require.Equal(t, common.TokenFingerprint("token"), events[0].TokenFingerprint, "invalid audit event token fingerprint")
+	require.NotContains(t, events[0].TokenFingerprint, "token", "audit event must not contain the token")
+	require.Equal(t, "1.3.3.7", events[0].SourceIP, "invalid audit event source ip")
+	require.Equal(t, "curl/7.0", events[0].UserAgent, "invalid audit event user agent")
+	require.Equal(t, "upload", events[0].UploadID, "invalid audit event upload")
+	require.Equal(t, "file", events[0].FileID, "invalid audit event file")
+}
+
+func TestAuditFailure(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	handler := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		ctx.Forbidden("nope")
+	})
+
+	rr := ctx.NewRecorder(req)
+	Audit(common.AuditLogin)(ctx, handler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "nope")
+
+	events := getTestAuditEvents(t, ctx)
+	require.Len(t, events, 1, "invalid audit events count")
+	require.Equal(t, common.AuditFailure, events[0].Result, "invalid audit event result")
+	require.Equal(t, http.StatusForbidden, events[0].Status, "invalid audit event status")
+	require.Equal(t, "nope", events[0].Details, "invalid audit event details")
+	require.Empty(t, events[0].UserID, "invalid audit event user")
+}
+
+func TestAuditRedirectedFailure(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetRedirectOnFailure(true)
+
+	req, err := http.NewRequest("GET", "/file/upload/file/name", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	handler := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		ctx.NotFound("not found")
+	})
+
+	rr := ctx.NewRecorder(req)
+	Audit(common.AuditDownload)(ctx, handler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusMovedPermanently, rr.Code, "invalid response status code")
+
+	events := getTestAuditEvents(t, ctx)
+	require.Len(t, events, 1, "invalid audit events count")
+	require.Equal(t, common.AuditFailure, events[0].Result, "invalid audit event result")
+	require.Equal(t, http.StatusNotFound, events[0].Status, "invalid audit event status")
+}
+
+func TestAuditHead(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("HEAD", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	Audit(common.AuditDownload)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+
+	require.Len(t, getTestAuditEvents(t, ctx), 0, "invalid audit events count")
+}
+
+func TestAuditDisabled(t *testing.T) {
+	config := common.NewConfiguration()
+	config.AuditLog = false
+	ctx := newTestingContext(config)
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	Audit(common.AuditDownload)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+
+	require.Len(t, getTestAuditEvents(t, ctx), 0, "invalid audit events count")
+}
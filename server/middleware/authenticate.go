@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
@@ -20,6 +21,9 @@ func getUserFromToken(ctx *context.Context) (*common.User, *common.Token, *commo
 		if token == nil {
 			return nil, nil, &common.HTTPError{Message: "invalid token", StatusCode: http.StatusForbidden}
 		}
+		if token.IsExpired() {
+			return nil, nil, &common.HTTPError{Message: "token has expired", StatusCode: http.StatusForbidden}
+		}
 
 		user, err := ctx.GetMetadataBackend().GetUser(token.UserID)
 		if err != nil {
@@ -29,6 +33,14 @@ func getUserFromToken(ctx *context.Context) (*common.User, *common.Token, *commo
 			return nil, nil, &common.HTTPError{Message: "invalid token", StatusCode: http.StatusForbidden}
 		}
 
+		// Only record the last usage every minute to spare the metadata backend
+		if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > time.Minute {
+			err = ctx.GetMetadataBackend().TouchToken(token)
+			if err != nil {
+				ctx.GetLogger().Warningf("unable to update token last usage date : %s", err)
+			}
+		}
+
 		return user, token, nil
 	}
 
@@ -119,6 +131,21 @@ func Authenticate(allowToken bool) context.Middleware {
 	}
 }
 
+// TokenScope middleware to forbid requests authenticated with a token that does not have the required scope
+func TokenScope(scope string) context.Middleware {
+	return func(ctx *context.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			token := ctx.GetToken()
+			if token != nil && !token.HasScope(scope) {
+				ctx.Forbidden("token does not have the %s scope", scope)
+				return
+			}
+
+			next.ServeHTTP(resp, req)
+		})
+	}
+}
+
 // AuthenticatedOnly middleware to allow only authenticated users to the next middleware in the chain
 func AuthenticatedOnly(ctx *context.Context, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
@@ -140,7 +167,7 @@ func AdminOnly(ctx *context.Context, next http.Handler) http.Handler {
 			return
 		}
 
-		if !user.IsAdmin {
+		if !ctx.IsAdmin() {
 			ctx.Forbidden("you need administrator privileges")
 			return
 		}
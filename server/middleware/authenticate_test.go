@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -61,6 +62,53 @@ func TestAuthenticateToken(t *testing.T) {
 	require.Equal(t, token.Token, tokenFromContext.Token, "invalid token from context")
 }
 
+func TestAuthenticateTokenExpired(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	expireAt := time.Now().Add(-time.Minute)
+	token.ExpireAt = &expireAt
+
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to save user : %s", err)
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("X-PlikToken", token.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestForbidden(t, rr, "token has expired")
+}
+
+func TestAuthenticateTokenLastUsedAt(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to save user : %s", err)
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("X-PlikToken", token.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+
+	tokenFromBackend, err := ctx.GetMetadataBackend().GetToken(token.Token)
+	require.NoError(t, err, "unable to get token")
+	require.NotNil(t, tokenFromBackend.LastUsedAt, "missing token last usage date")
+}
+
 func TestAuthenticateInvalidSessionCookie(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
@@ -259,3 +307,70 @@ func TestAdminOnly_NotAdmin(t *testing.T) {
 	AdminOnly(ctx, common.DummyHandler).ServeHTTP(rr, req)
 	context.TestForbidden(t, rr, "you need administrator privileges")
 }
+
+func TestTokenScope_NoToken(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	TokenScope(common.TokenScopeUpload)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestTokenScope_OK(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetToken(&common.Token{Scope: "upload read"})
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	TokenScope(common.TokenScopeRead)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestTokenScope_Forbidden(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetToken(&common.Token{Scope: common.TokenScopeUpload})
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	TokenScope(common.TokenScopeDelete)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "token does not have the delete scope")
+}
+
+func TestAdminOnly_TokenWithoutAdminScope(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	user.IsAdmin = true
+	ctx.SetUser(user)
+	ctx.SetToken(&common.Token{Scope: common.TokenScopeRead})
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AdminOnly(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "you need administrator privileges")
+}
+
+func TestAdminOnly_TokenWithoutScope(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	user.IsAdmin = true
+	ctx.SetUser(user)
+	ctx.SetToken(&common.Token{})
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AdminOnly(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "you need administrator privileges")
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/context"
+)
+
+// Group middleware for all the /group/{groupID} routes.
+// Only group members and administrators are allowed to access the group
+func Group(ctx *context.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		user := ctx.GetUser()
+		if user == nil {
+			ctx.Unauthorized("You must be authenticated, please login first")
+			return
+		}
+
+		// Get the group id from the url params
+		vars := mux.Vars(req)
+		groupID := vars["groupID"]
+		if groupID == "" {
+			ctx.MissingParameter("group id")
+			return
+		}
+
+		group, err := ctx.GetMetadataBackend().GetGroup(groupID)
+		if err != nil {
+			ctx.InternalServerError("unable to get group", err)
+			return
+		}
+
+		// Do not disclose the existence of groups the user is not a member of
+		if group == nil || !(group.IsMember(user.ID) || ctx.IsAdmin()) {
+			ctx.NotFound("group not found")
+			return
+		}
+
+		ctx.SetGroup(group)
+
+		next.ServeHTTP(resp, req)
+	})
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func newGroupRequest(t *testing.T, groupID string) *http.Request {
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"groupID": groupID,
+	}
+	return mux.SetURLVars(req, vars)
+}
+
+func createTestGroup(t *testing.T, ctx *context.Context, members ...string) *common.Group {
+	group := &common.Group{ID: "group"}
+	err := ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+
+	for _, member := range members {
+		err = ctx.GetMetadataBackend().AddGroupMember(group.ID, member)
+		require.NoError(t, err, "unable to add group member")
+	}
+
+	return group
+}
+
+func TestGroup_NotAuthenticated(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req := newGroupRequest(t, "group")
+	rr := ctx.NewRecorder(req)
+	Group(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestUnauthorized(t, rr, "please login first")
+}
+
+func TestGroup_MissingGroupID(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(&common.User{ID: "user"})
+
+	req := newGroupRequest(t, "")
+	rr := ctx.NewRecorder(req)
+	Group(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestMissingParameter(t, rr, "group id")
+}
+
+func TestGroup_NotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(&common.User{ID: "user"})
+
+	req := newGroupRequest(t, "group")
+	rr := ctx.NewRecorder(req)
+	Group(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestNotFound(t, rr, "group not found")
+}
+
+func TestGroup_NotMember(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(&common.User{ID: "user"})
+	createTestGroup(t, ctx, "member")
+
+	req := newGroupRequest(t, "group")
+	rr := ctx.NewRecorder(req)
+	Group(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestNotFound(t, rr, "group not found")
+	require.Nil(t, ctx.GetGroup(), "group should not be set in the context")
+}
+
+func TestGroup_Member(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(&common.User{ID: "member"})
+	group := createTestGroup(t, ctx, "member")
+
+	req := newGroupRequest(t, group.ID)
+	rr := ctx.NewRecorder(req)
+	Group(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+	require.NotNil(t, ctx.GetGroup(), "missing group from context")
+	require.Equal(t, group.ID, ctx.GetGroup().ID, "invalid group from context")
+}
+
+func TestGroup_Admin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(&common.User{ID: "admin", IsAdmin: true})
+	group := createTestGroup(t, ctx)
+
+	req := newGroupRequest(t, group.ID)
+	rr := ctx.NewRecorder(req)
+	Group(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+	require.Equal(t, group.ID, ctx.GetGroup().ID, "invalid group from context")
+}
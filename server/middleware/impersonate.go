@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 
+	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 )
 
@@ -13,8 +14,14 @@ func Impersonate(ctx *context.Context, next http.Handler) http.Handler {
 		newUserID := req.Header.Get("X-Plik-Impersonate")
 		if newUserID != "" {
 
+			// Record the impersonation attempts in the audit log
+			event := common.NewAuditEvent(common.AuditImpersonate)
+			event.Details = newUserID
+
 			// Check authorization
 			if !ctx.IsAdmin() {
+				event.SetStatus(http.StatusForbidden)
+				ctx.Audit(event)
 				ctx.Forbidden("you need administrator privileges")
 				return
 			}
@@ -26,10 +33,14 @@ func Impersonate(ctx *context.Context, next http.Handler) http.Handler {
 			}
 
 			if newUser == nil {
+				event.SetStatus(http.StatusForbidden)
+				ctx.Audit(event)
 				ctx.Forbidden("user to impersonate does not exists")
 				return
 			}
 
+			ctx.Audit(event)
+
 			// Change user in the request context
 			ctx.SetUser(newUser)
 
@@ -23,6 +23,12 @@ func TestImpersonateNotAdmin(t *testing.T) {
 	Impersonate(ctx, common.DummyHandler).ServeHTTP(rr, req)
 
 	context.TestForbidden(t, rr, "you need administrator privileges")
+
+	events := getTestAuditEvents(t, ctx)
+	require.Len(t, events, 1, "invalid audit events count")
+	require.Equal(t, common.AuditImpersonate, events[0].Action, "invalid audit event action")
+	require.Equal(t, common.AuditFailure, events[0].Result, "invalid audit event result")
+	require.Equal(t, "user", events[0].Details, "invalid audit event details")
 }
 
 func TestImpersonateUserNotFound(t *testing.T) {
@@ -46,7 +52,7 @@ func TestImpersonateUserNotFound(t *testing.T) {
 func TestImpersonate(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
-	user := &common.User{}
+	user := &common.User{ID: "admin"}
 	user.IsAdmin = true
 	ctx.SetUser(user)
 
@@ -68,4 +74,11 @@ func TestImpersonate(t *testing.T) {
 	userFromContext := ctx.GetUser()
 	require.NotNil(t, userFromContext, "missing user from context")
 	require.Equal(t, userToImpersonate.ID, userFromContext.ID, "invalid user from context")
+
+	events := getTestAuditEvents(t, ctx)
+	require.Len(t, events, 1, "invalid audit events count")
+	require.Equal(t, common.AuditImpersonate, events[0].Action, "invalid audit event action")
+	require.Equal(t, common.AuditSuccess, events[0].Result, "invalid audit event result")
+	require.Equal(t, "admin", events[0].UserID, "invalid audit event user")
+	require.Equal(t, "user", events[0].Details, "invalid audit event details")
 }
@@ -7,8 +7,7 @@ import (
 
 	"github.com/gorilla/mux"
 
-	"github.com/root-gg/utils"
-
+	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 )
 
@@ -49,6 +48,18 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 		// Save upload in the request context
 		ctx.SetUpload(upload)
 
+		// Save upload group in the request context to apply the group limits
+		if upload.Group != "" {
+			group, err := ctx.GetMetadataBackend().GetGroup(upload.Group)
+			if err != nil {
+				ctx.InternalServerError("unable to get upload group metadata", err)
+				return
+			}
+			if group != nil {
+				ctx.SetGroup(group)
+			}
+		}
+
 		// Being admin of an upload means that you can :
 		//  - Add files to the upload
 		//  - Remove the upload
@@ -57,6 +68,7 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 		//  - Being authenticated with an Admin user
 		//  - Being authenticated with a cookie with the user having created the upload
 		//  - Being authenticated with a token with the user and token having create the upload
+		//  - Being authenticated (cookie or token) with a user member of the group owning the upload
 
 		upload.IsAdmin = false
 		uploadToken := req.Header.Get("X-UploadToken")
@@ -80,6 +92,13 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 					}
 				}
 			}
+
+			// Group members can manage the uploads owned by the group
+			group := ctx.GetGroup()
+			user := ctx.GetUser()
+			if group != nil && user != nil && group.IsMember(user.ID) {
+				upload.IsAdmin = true
+			}
 		}
 
 		forbidden := func(message string) {
@@ -100,7 +119,7 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 			}
 
 			// Basic auth Authorization header must be set to
-			// "Basic base64("login:password")". Only the md5sum
+			// "Basic base64("login:password")". Only a salted hash
 			// of the base64 string is saved in the upload metadata
 			auth := strings.Split(req.Header.Get("Authorization"), " ")
 			if len(auth) != 2 {
@@ -111,16 +130,16 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 				forbidden("invalid http authorization scheme")
 				return
 			}
-			var md5sum string
-			md5sum, err = utils.Md5sum(auth[1])
-			if err != nil {
-				forbidden("unable to hash credentials")
-				return
-			}
-			if md5sum != upload.Password {
+			ok, needRehash := common.CheckUploadCredentials(auth[1], upload.Password)
+			if !ok {
 				forbidden("invalid credentials")
 				return
 			}
+
+			// Transparently replace legacy md5 hashes
+			if needRehash {
+				rehashUploadCredentials(ctx, upload, auth[1])
+			}
 		}
 
 		// Extend upload expiration date by TTL each time an upload is directly accessed
@@ -136,3 +155,19 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 		next.ServeHTTP(resp, req)
 	})
 }
+
+// rehashUploadCredentials replace the legacy hash of the upload basic auth credentials
+// Failing to do so is not fatal as the legacy hash is still valid
+func rehashUploadCredentials(ctx *context.Context, upload *common.Upload, credentials string) {
+	hash, err := common.HashUploadCredentials(credentials)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to hash upload credentials : %s", err)
+		return
+	}
+
+	upload.Password = hash
+	err = ctx.GetMetadataBackend().UpdateUploadPassword(upload)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to update upload credentials hash : %s", err)
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// UploadRequest middleware for all the /request/{requestID} routes.
+// It retrieves the upload request and the upload it feeds into and save them to the request context.
+// Senders only get to add files, they are never admin of the upload.
+func UploadRequest(ctx *context.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		log := ctx.GetLogger()
+
+		// Get the upload request id from the url params
+		vars := mux.Vars(req)
+		requestID := vars["requestID"]
+		if requestID == "" {
+			ctx.MissingParameter("upload request id")
+			return
+		}
+
+		request, err := ctx.GetMetadataBackend().GetUploadRequest(requestID)
+		if err != nil {
+			ctx.InternalServerError("unable to get upload request metadata", err)
+			return
+		}
+		if request == nil || request.IsExpired() {
+			ctx.NotFound("upload request %s not found", requestID)
+			return
+		}
+
+		// Check the upload request share token
+		token := req.Header.Get(common.UploadRequestTokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(request.Token)) != 1 {
+			ctx.Forbidden("invalid upload request token")
+			return
+		}
+
+		// Update request logger prefix
+		prefix := fmt.Sprintf("%s[%s]", log.Prefix, request.UploadID)
+		log.SetPrefix(prefix)
+
+		upload, err := ctx.GetMetadataBackend().GetUpload(request.UploadID)
+		if err != nil {
+			ctx.InternalServerError("unable to get upload metadata", err)
+			return
+		}
+		if upload == nil || upload.IsExpired() {
+			ctx.NotFound("upload request %s not found", requestID)
+			return
+		}
+
+		upload.IsAdmin = false
+
+		ctx.SetUpload(upload)
+		ctx.SetUploadRequest(request)
+
+		next.ServeHTTP(resp, req)
+	})
+}
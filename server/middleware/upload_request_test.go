@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func newUploadRequestRequest(t *testing.T, requestID string, token string) *http.Request {
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	if token != "" {
+		req.Header.Set(common.UploadRequestTokenHeader, token)
+	}
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"requestID": requestID,
+	}
+	return mux.SetURLVars(req, vars)
+}
+
+func createTestUploadRequest(t *testing.T, ctx *context.Context) (request *common.UploadRequest, upload *common.Upload) {
+	upload = &common.Upload{}
+	upload.InitializeForTests()
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	request = common.NewUploadRequest()
+	request.UploadID = upload.ID
+	err = ctx.GetMetadataBackend().CreateUploadRequest(request)
+	require.NoError(t, err, "unable to create upload request")
+
+	return request, upload
+}
+
+func TestUploadRequest(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	request, upload := createTestUploadRequest(t, ctx)
+
+	req := newUploadRequestRequest(t, request.ID, request.Token)
+	rr := ctx.NewRecorder(req)
+	UploadRequest(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+
+	require.NotNil(t, ctx.GetUploadRequest(), "missing upload request in context")
+	require.Equal(t, request.ID, ctx.GetUploadRequest().ID, "invalid upload request from context")
+	require.NotNil(t, ctx.GetUpload(), "missing upload in context")
+	require.Equal(t, upload.ID, ctx.GetUpload().ID, "invalid upload from context")
+	require.False(t, ctx.GetUpload().IsAdmin, "upload request senders should not be upload admin")
+}
+
+func TestUploadRequest_MissingRequestID(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req := newUploadRequestRequest(t, "", "token")
+	rr := ctx.NewRecorder(req)
+	UploadRequest(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestMissingParameter(t, rr, "upload request id")
+}
+
+func TestUploadRequest_NotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req := newUploadRequestRequest(t, "request", "token")
+	rr := ctx.NewRecorder(req)
+	UploadRequest(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestNotFound(t, rr, "upload request request not found")
+}
+
+func TestUploadRequest_InvalidToken(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	request, _ := createTestUploadRequest(t, ctx)
+
+	req := newUploadRequestRequest(t, request.ID, "")
+	rr := ctx.NewRecorder(req)
+	UploadRequest(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "invalid upload request token")
+
+	req = newUploadRequestRequest(t, request.ID, "invalid")
+	rr = ctx.NewRecorder(req)
+	UploadRequest(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "invalid upload request token")
+}
+
+func TestUploadRequest_Expired(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	deadline := time.Now().Add(-time.Hour)
+	request := common.NewUploadRequest()
+	request.ExpireAt = &deadline
+	err := ctx.GetMetadataBackend().CreateUploadRequest(request)
+	require.NoError(t, err, "unable to create upload request")
+
+	req := newUploadRequestRequest(t, request.ID, request.Token)
+	rr := ctx.NewRecorder(req)
+	UploadRequest(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestNotFound(t, rr, "not found")
+}
+
+func TestUploadRequest_UploadNotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	request := common.NewUploadRequest()
+	request.UploadID = "upload"
+	err := ctx.GetMetadataBackend().CreateUploadRequest(request)
+	require.NoError(t, err, "unable to create upload request")
+
+	req := newUploadRequestRequest(t, request.ID, request.Token)
+	rr := ctx.NewRecorder(req)
+	UploadRequest(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestNotFound(t, rr, "not found")
+}
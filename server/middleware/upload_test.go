@@ -289,6 +289,45 @@ func TestUploadUserAdmin(t *testing.T) {
 	require.True(t, ctx.IsAdmin(), "invalid admin status")
 }
 
+func TestUploadGroupMember(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	group := &common.Group{ID: "group"}
+	err := ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+	err = ctx.GetMetadataBackend().AddGroupMember(group.ID, "member")
+	require.NoError(t, err, "unable to add group member")
+
+	upload := &common.Upload{User: "owner", Group: group.ID}
+	upload.InitializeForTests()
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	for _, userID := range []string{"member", "other"} {
+		ctx.SetUser(&common.User{ID: userID})
+		ctx.SetGroup(nil)
+
+		req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+		require.NoError(t, err, "unable to create new request")
+
+		// Fake gorilla/mux vars
+		vars := map[string]string{
+			"uploadID": upload.ID,
+		}
+		req = mux.SetURLVars(req, vars)
+
+		rr := ctx.NewRecorder(req)
+		Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+		require.NotNil(t, ctx.GetGroup(), "missing group from context")
+		require.Equal(t, group.ID, ctx.GetGroup().ID, "invalid group from context")
+		require.Equal(t, userID == "member", ctx.GetUpload().IsAdmin, "invalid upload admin status")
+	}
+}
+
 func TestUploadPasswordMissingHeader(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
@@ -412,7 +451,7 @@ func TestUploadPassword(t *testing.T) {
 	upload.InitializeForTests()
 
 	// The Authorization header will contain the base64 version of "login:password"
-	// Save only the md5sum of this string to authenticate further requests
+	// Plik used to save only the md5sum of this string to authenticate further requests
 	b64str := base64.StdEncoding.EncodeToString([]byte(upload.Login + ":" + upload.Password))
 	upload.Password, err = utils.Md5sum(b64str)
 	require.NoError(t, err, "unable to b64encode upload credentials")
@@ -438,4 +477,60 @@ func TestUploadPassword(t *testing.T) {
 	require.NotNil(t, ctx.GetUpload(), "missing upload from context")
 	require.Equal(t, upload.ID, ctx.GetUpload().ID, "invalid upload from context")
 	require.False(t, upload.IsAdmin, "invalid upload admin status")
+
+	// The legacy md5 hash must have been replaced
+	result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.False(t, common.IsLegacyUploadCredentialsHash(result.Password), "legacy hash should have been replaced")
+
+	ok, needRehash := common.CheckUploadCredentials(b64str, result.Password)
+	require.True(t, ok, "invalid upload credentials hash")
+	require.False(t, needRehash, "invalid upload credentials hash")
+}
+
+func TestUploadPasswordHash(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	var err error
+
+	upload := &common.Upload{}
+	upload.ProtectedByPassword = true
+	upload.Login = "login"
+	upload.InitializeForTests()
+
+	b64str := common.EncodeAuthBasicHeader(upload.Login, "password")
+	upload.Password, err = common.HashUploadCredentials(b64str)
+	require.NoError(t, err, "unable to hash upload credentials")
+	hash := upload.Password
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"uploadID": upload.ID,
+	}
+	req = mux.SetURLVars(req, vars)
+
+	req.Header.Add("Authorization", "Basic "+common.EncodeAuthBasicHeader(upload.Login, "invalid"))
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestUnauthorized(t, rr, "please provide valid credentials to access this upload : invalid credentials")
+
+	req.Header.Set("Authorization", "Basic "+b64str)
+
+	rr = ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.NotNil(t, ctx.GetUpload(), "missing upload from context")
+
+	// The hash must not have changed
+	result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, hash, result.Password, "upload credentials hash should not have changed")
 }
@@ -0,0 +1,487 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/tls"
+	"embed"
+	"fmt"
+	htmlTemplate "html/template"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	textTemplate "text/template"
+	"time"
+
+	"github.com/root-gg/logger"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+/*
+  Email notification design :
+    - Emails are rendered when the notification is requested and added to the outbox table
+      of the metadata backend, one email per recipient
+    - A background routine sends the pending emails through the configured SMTP server and
+      retries the failed ones with an exponential backoff until the maximum number of attempts is reached
+    - Emails are claimed before being sent so several Plik instances sharing the same
+      metadata backend don't send the same email twice
+    - Each notification has three templates : <name>.subject.tmpl, <name>.txt.tmpl and <name>.html.tmpl
+      Default templates are embedded in the binary, a file with the same name in EmailTemplatesDir overrides them
+*/
+
+// TemplateUpload is sent to the recipients of a shared upload
+const TemplateUpload = "upload"
+
+// TemplateDownloadReceipt is sent to the upload owner on the first download
+const TemplateDownloadReceipt = "download_receipt"
+
+// TemplateUploadRequestUsed is sent to the upload request owner when a file is sent
+const TemplateUploadRequestUsed = "upload_request_used"
+
+var templateNames = []string{TemplateUpload, TemplateDownloadReceipt, TemplateUploadRequestUsed}
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// Data is passed to the email templates
+type Data struct {
+	ServerURL string
+	UploadURL string
+	Upload    *common.Upload
+	File      *common.File
+	User      *common.User
+	Request   *common.UploadRequest
+	Message   string
+}
+
+type emailTemplate struct {
+	subject *textTemplate.Template
+	text    *textTemplate.Template
+	html    *htmlTemplate.Template
+}
+
+// Notifier render email notifications, add them to the outbox and send them
+type Notifier struct {
+	config          *common.Configuration
+	metadataBackend *metadata.Backend
+	log             *logger.Logger
+	templates       map[string]*emailTemplate
+	from            *mail.Address
+	notify          chan struct{}
+
+	timeout      time.Duration
+	batchSize    int
+	pollInterval time.Duration
+	claimTimeout time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	maxAttempts  int
+}
+
+// NewNotifier create a new email notifier and load the templates
+func NewNotifier(config *common.Configuration, metadataBackend *metadata.Backend) (n *Notifier, err error) {
+	n = new(Notifier)
+	n.config = config
+	n.metadataBackend = metadataBackend
+	n.log = config.NewLogger()
+	n.notify = make(chan struct{}, 1)
+
+	n.from, err = mail.ParseAddress(config.EmailFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EmailFrom address %s : %s", config.EmailFrom, err)
+	}
+
+	n.templates = make(map[string]*emailTemplate)
+	for _, name := range templateNames {
+		n.templates[name], err = n.loadTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	n.timeout = 30 * time.Second
+	n.batchSize = 100
+	n.pollInterval = 30 * time.Second
+	n.claimTimeout = 5 * time.Minute
+	n.minBackoff = time.Minute
+	n.maxBackoff = 6 * time.Hour
+	n.maxAttempts = 10
+
+	return n, nil
+}
+
+// readTemplate return the template from EmailTemplatesDir if it exists or the embedded default one
+func (n *Notifier) readTemplate(filename string) (string, error) {
+	if n.config.EmailTemplatesDir != "" {
+		content, err := os.ReadFile(filepath.Join(n.config.EmailTemplatesDir, filename))
+		if err == nil {
+			return string(content), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("unable to read email template %s : %s", filename, err)
+		}
+	}
+
+	content, err := fs.ReadFile(defaultTemplates, "templates/"+filename)
+	if err != nil {
+		return "", fmt.Errorf("unable to read email template %s : %s", filename, err)
+	}
+
+	return string(content), nil
+}
+
+func (n *Notifier) loadTemplate(name string) (t *emailTemplate, err error) {
+	t = &emailTemplate{}
+
+	content, err := n.readTemplate(name + ".subject.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	t.subject, err = textTemplate.New(name + ".subject").Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse email template %s.subject.tmpl : %s", name, err)
+	}
+
+	content, err = n.readTemplate(name + ".txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	t.text, err = textTemplate.New(name + ".txt").Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse email template %s.txt.tmpl : %s", name, err)
+	}
+
+	content, err = n.readTemplate(name + ".html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	t.html, err = htmlTemplate.New(name + ".html").Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse email template %s.html.tmpl : %s", name, err)
+	}
+
+	return t, nil
+}
+
+// Notify render the template and add one email per recipient to the outbox
+func (n *Notifier) Notify(name string, to []string, data *Data) (err error) {
+	t, ok := n.templates[name]
+	if !ok {
+		return fmt.Errorf("unknown email template %s", name)
+	}
+
+	data = n.sanitize(data)
+
+	buf := &bytes.Buffer{}
+	err = t.subject.Execute(buf, data)
+	if err != nil {
+		return fmt.Errorf("unable to render email subject : %s", err)
+	}
+	subject := strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+	err = t.text.Execute(buf, data)
+	if err != nil {
+		return fmt.Errorf("unable to render email text : %s", err)
+	}
+	text := buf.String()
+
+	buf.Reset()
+	err = t.html.Execute(buf, data)
+	if err != nil {
+		return fmt.Errorf("unable to render email html : %s", err)
+	}
+	html := buf.String()
+
+	for _, recipient := range to {
+		email := &common.Email{}
+		email.ID = common.GenerateRandomID(32)
+		email.To = recipient
+		email.Subject = subject
+		email.Text = text
+		email.HTML = html
+		email.NextAttempt = time.Now()
+
+		err = n.metadataBackend.CreateEmail(email)
+		if err != nil {
+			return fmt.Errorf("unable to save email : %s", err)
+		}
+	}
+
+	// Wake up the sending routine
+	select {
+	case n.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// sanitize copy the template data without the secrets and fill the URLs
+func (n *Notifier) sanitize(data *Data) *Data {
+	d := *data
+	d.ServerURL = n.config.GetPublicURL().String()
+
+	if data.Upload != nil {
+		u := *data.Upload
+		u.Files = nil
+		for _, file := range data.Upload.Files {
+			f := *file
+			f.Sanitize()
+			u.Files = append(u.Files, &f)
+		}
+		u.UploadToken = ""
+		u.Token = ""
+		u.Login = ""
+		u.Password = ""
+		u.IsAdmin = false
+		d.Upload = &u
+		d.UploadURL = d.ServerURL + "/#/?id=" + u.ID
+	}
+
+	if data.File != nil {
+		f := *data.File
+		f.Sanitize()
+		d.File = &f
+	}
+
+	if data.User != nil {
+		d.User = &common.User{Login: data.User.Login, Name: data.User.Name, Email: data.User.Email}
+	}
+
+	if data.Request != nil {
+		r := *data.Request
+		r.Token = ""
+		d.Request = &r
+	}
+
+	return &d
+}
+
+// Run send the pending emails until done is closed
+func (n *Notifier) Run(done <-chan struct{}) {
+	for {
+		n.SendPending()
+
+		select {
+		case <-n.notify:
+		case <-time.After(n.pollInterval):
+		case <-done:
+			return
+		}
+	}
+}
+
+// SendPending send all the emails that are due
+func (n *Notifier) SendPending() {
+	for {
+		emails, err := n.metadataBackend.GetPendingEmails(n.batchSize)
+		if err != nil {
+			n.log.Warningf("Unable to get pending emails : %s", err)
+			return
+		}
+
+		claimed := 0
+		for _, email := range emails {
+			if n.deliver(email) {
+				claimed++
+			}
+		}
+
+		if len(emails) < n.batchSize || claimed == 0 {
+			return
+		}
+	}
+}
+
+// deliver send an email, return false if the email could not be claimed
+func (n *Notifier) deliver(email *common.Email) bool {
+	err := n.metadataBackend.ClaimEmail(email, time.Now().Add(n.claimTimeout))
+	if err != nil {
+		return false
+	}
+
+	err = n.send(email)
+	if err == nil {
+		n.delete(email)
+		return true
+	}
+
+	if email.Attempts >= n.maxAttempts {
+		n.log.Criticalf("Unable to send email %s to %s after %d attempts, giving up : %s", email.ID, email.To, email.Attempts, err)
+		n.delete(email)
+		return true
+	}
+
+	backoff := n.getBackoff(email.Attempts)
+	n.log.Warningf("Unable to send email %s to %s : %s, will retry in %s", email.ID, email.To, err, backoff)
+
+	err = n.metadataBackend.RetryEmail(email, time.Now().Add(backoff), err.Error())
+	if err != nil {
+		n.log.Warningf("Unable to update email %s : %s", email.ID, err)
+	}
+
+	return true
+}
+
+func (n *Notifier) send(email *common.Email) (err error) {
+	message, err := n.getMessage(email)
+	if err != nil {
+		return err
+	}
+
+	host := n.config.SMTPHost
+	address := net.JoinHostPort(host, strconv.Itoa(n.config.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: n.config.SMTPInsecureSkipVerify}
+	dialer := &net.Dialer{Timeout: n.timeout}
+
+	var conn net.Conn
+	if n.config.SMTPTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(n.timeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if n.config.SMTPStartTLS {
+		err = client.StartTLS(tlsConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	if n.config.SMTPUsername != "" {
+		err = client.Auth(smtp.PlainAuth("", n.config.SMTPUsername, n.config.SMTPPassword, host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(n.from.Address)
+	if err != nil {
+		return err
+	}
+
+	err = client.Rcpt(email.To)
+	if err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(message)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// getMessage build a multipart/alternative MIME message with a text and an html part
+func (n *Notifier) getMessage(email *common.Email) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+
+	domain := "plik"
+	if i := strings.LastIndex(n.from.Address, "@"); i >= 0 {
+		domain = n.from.Address[i+1:]
+	}
+
+	header := func(key string, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+	header("From", n.from.String())
+	header("To", email.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", email.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+email.ID+"@"+domain+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+w.Boundary())
+	buf.WriteString("\r\n")
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", email.Text},
+		{"text/html; charset=utf-8", email.HTML},
+	}
+
+	for _, part := range parts {
+		if part.body == "" {
+			continue
+		}
+
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", part.contentType)
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(pw)
+		_, err = qp.Write([]byte(part.body))
+		if err != nil {
+			return nil, err
+		}
+		err = qp.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (n *Notifier) delete(email *common.Email) {
+	err := n.metadataBackend.DeleteEmail(email.ID)
+	if err != nil {
+		n.log.Warningf("Unable to delete email %s : %s", email.ID, err)
+	}
+}
+
+// getBackoff return the delay before the next attempt : minBackoff * 2 ^ ( attempts - 1 ) up to maxBackoff
+func (n *Notifier) getBackoff(attempts int) time.Duration {
+	backoff := n.minBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= n.maxBackoff {
+			return n.maxBackoff
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,338 @@
+package notification
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+type testMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// testSMTPServer is a minimal SMTP server that records the received messages
+type testSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	fail     bool
+	messages []*testMessage
+}
+
+func newTestSMTPServer(t *testing.T) (server *testSMTPServer) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "unable to start smtp server")
+
+	server = &testSMTPServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handle(conn)
+		}
+	}()
+
+	return server
+}
+
+func (server *testSMTPServer) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP test")
+
+	message := &testMessage{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch command {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			message.from = line
+			reply("250 OK")
+		case "RCPT":
+			server.mu.Lock()
+			fail := server.fail
+			server.mu.Unlock()
+			if fail {
+				reply("451 try again later")
+				continue
+			}
+			message.to = append(message.to, line)
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			data := &strings.Builder{}
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			message.data = data.String()
+
+			server.mu.Lock()
+			server.messages = append(server.messages, message)
+			server.mu.Unlock()
+
+			message = &testMessage{}
+			reply("250 OK")
+		case "RSET", "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (server *testSMTPServer) setFail(fail bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.fail = fail
+}
+
+func (server *testSMTPServer) count() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return len(server.messages)
+}
+
+func (server *testSMTPServer) port() int {
+	return server.listener.Addr().(*net.TCPAddr).Port
+}
+
+func newTestNotifier(t *testing.T, server *testSMTPServer, templatesDir string) (n *Notifier) {
+	config := common.NewConfiguration()
+	config.SMTPHost = "127.0.0.1"
+	config.SMTPPort = server.port()
+	config.EmailFrom = "Plik <plik@root.gg>"
+	config.EmailTemplatesDir = templatesDir
+	config.PublicURL = "https://plik.root.gg"
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	metadataBackendConfig := &metadata.Config{Driver: "sqlite3", ConnectionString: "/tmp/plik.notification.test.db", EraseFirst: true}
+	metadataBackend, err := metadata.NewBackend(metadataBackendConfig, config.NewLogger())
+	require.NoError(t, err, "unable to create metadata backend")
+
+	n, err = NewNotifier(config, metadataBackend)
+	require.NoError(t, err, "unable to create notifier")
+	n.minBackoff = 0
+	n.maxBackoff = 0
+	n.maxAttempts = 3
+
+	return n
+}
+
+func countEmails(t *testing.T, n *Notifier) (count int) {
+	err := n.metadataBackend.ForEachEmail(func(email *common.Email) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err, "unable to list emails")
+	return count
+}
+
+// readMessage parse a received message and return the subject and the decoded parts by content type
+func readMessage(t *testing.T, data string) (subject string, parts map[string]string) {
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	require.NoError(t, err, "unable to parse message")
+
+	subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err, "unable to decode subject")
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err, "unable to parse content type")
+	require.Equal(t, "multipart/alternative", mediaType, "invalid content type")
+
+	parts = make(map[string]string)
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err, "unable to read part")
+
+		body, err := io.ReadAll(part)
+		require.NoError(t, err, "unable to read part body")
+
+		contentType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		require.NoError(t, err, "unable to parse part content type")
+		parts[contentType] = string(body)
+	}
+
+	return subject, parts
+}
+
+func TestNotifyAndSend(t *testing.T) {
+	server := newTestSMTPServer(t)
+	defer func() { _ = server.listener.Close() }()
+
+	n := newTestNotifier(t, server, "")
+
+	upload := common.NewUpload()
+	upload.UploadToken = "secret"
+	upload.NewFile().Name = "file.txt"
+	user := &common.User{Login: "foo", Name: "Foo Bar", Password: "secret"}
+
+	err := n.Notify(TemplateUpload, []string{"a@root.gg", "b@root.gg"}, &Data{Upload: upload, User: user, Message: "<b>hello</b>"})
+	require.NoError(t, err, "unable to notify")
+	require.Equal(t, 2, countEmails(t, n), "invalid email count")
+
+	n.SendPending()
+	require.Equal(t, 2, server.count(), "invalid message count")
+	require.Equal(t, 0, countEmails(t, n), "invalid email count")
+
+	message := server.messages[0]
+	require.Equal(t, "MAIL FROM:<plik@root.gg>", message.from, "invalid envelope sender")
+	require.Len(t, message.to, 1, "invalid envelope recipients")
+
+	subject, parts := readMessage(t, message.data)
+	require.Equal(t, "Foo Bar shared 1 file(s) with you", subject, "invalid subject")
+
+	text := parts["text/plain"]
+	require.Contains(t, text, "file.txt", "missing file name")
+	require.Contains(t, text, "<b>hello</b>", "missing message")
+	require.Contains(t, text, "https://plik.root.gg/#/?id="+upload.ID, "missing upload url")
+	require.NotContains(t, text, "secret", "upload token should not be sent")
+
+	html := parts["text/html"]
+	require.Contains(t, html, "&lt;b&gt;hello&lt;/b&gt;", "message should be escaped")
+	require.Contains(t, html, "https://plik.root.gg/#/?id="+upload.ID, "missing upload url")
+}
+
+func TestSendRetry(t *testing.T) {
+	server := newTestSMTPServer(t)
+	defer func() { _ = server.listener.Close() }()
+	server.setFail(true)
+
+	n := newTestNotifier(t, server, "")
+
+	upload := common.NewUpload()
+	file := upload.NewFile()
+	file.Name = "file.txt"
+
+	err := n.Notify(TemplateDownloadReceipt, []string{"owner@root.gg"}, &Data{Upload: upload, File: file})
+	require.NoError(t, err, "unable to notify")
+
+	n.SendPending()
+	require.Equal(t, 0, server.count(), "invalid message count")
+	require.Equal(t, 1, countEmails(t, n), "email should be retried")
+
+	err = n.metadataBackend.ForEachEmail(func(email *common.Email) error {
+		require.Equal(t, 1, email.Attempts, "invalid email attempts")
+		require.Contains(t, email.LastError, "try again later", "invalid email last error")
+		return nil
+	})
+	require.NoError(t, err, "unable to list emails")
+
+	server.setFail(false)
+	n.SendPending()
+	require.Equal(t, 1, server.count(), "invalid message count")
+	require.Equal(t, 0, countEmails(t, n), "invalid email count")
+}
+
+func TestSendGiveUp(t *testing.T) {
+	server := newTestSMTPServer(t)
+	defer func() { _ = server.listener.Close() }()
+	server.setFail(true)
+
+	n := newTestNotifier(t, server, "")
+
+	err := n.Notify(TemplateUpload, []string{"a@root.gg"}, &Data{Upload: common.NewUpload()})
+	require.NoError(t, err, "unable to notify")
+
+	for i := 0; i < n.maxAttempts; i++ {
+		n.SendPending()
+	}
+	require.Equal(t, 0, server.count(), "invalid message count")
+	require.Equal(t, 0, countEmails(t, n), "email should have been dropped")
+}
+
+func TestTemplateOverride(t *testing.T) {
+	server := newTestSMTPServer(t)
+	defer func() { _ = server.listener.Close() }()
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "upload.subject.tmpl"), []byte("Files for you : {{ .Upload.ID }}"), 0644)
+	require.NoError(t, err, "unable to write template")
+
+	n := newTestNotifier(t, server, dir)
+
+	upload := common.NewUpload()
+	err = n.Notify(TemplateUpload, []string{"a@root.gg"}, &Data{Upload: upload})
+	require.NoError(t, err, "unable to notify")
+
+	n.SendPending()
+	require.Equal(t, 1, server.count(), "invalid message count")
+
+	subject, parts := readMessage(t, server.messages[0].data)
+	require.Equal(t, "Files for you : "+upload.ID, subject, "invalid subject")
+	require.Contains(t, parts["text/plain"], upload.ID, "default text template should be used")
+}
+
+func TestInvalidTemplate(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "download_receipt.html.tmpl"), []byte("{{ .Upload.ID "), 0644)
+	require.NoError(t, err, "unable to write template")
+
+	config := common.NewConfiguration()
+	config.EmailFrom = "plik@root.gg"
+	config.EmailTemplatesDir = dir
+
+	_, err = NewNotifier(config, nil)
+	common.RequireError(t, err, "unable to parse email template download_receipt.html.tmpl")
+}
+
+func TestNotifyUnknownTemplate(t *testing.T) {
+	server := newTestSMTPServer(t)
+	defer func() { _ = server.listener.Close() }()
+
+	n := newTestNotifier(t, server, "")
+
+	err := n.Notify("foo", []string{"a@root.gg"}, &Data{})
+	common.RequireError(t, err, "unknown email template foo")
+}
+
+func TestGetBackoff(t *testing.T) {
+	n := &Notifier{minBackoff: 60, maxBackoff: 300}
+	require.Equal(t, 60, int(n.getBackoff(1)), "invalid backoff")
+	require.Equal(t, 120, int(n.getBackoff(2)), "invalid backoff")
+	require.Equal(t, 240, int(n.getBackoff(3)), "invalid backoff")
+	require.Equal(t, 300, int(n.getBackoff(4)), "invalid backoff")
+	require.Equal(t, 300, int(n.getBackoff(10)), "invalid backoff")
+}
@@ -0,0 +1,343 @@
+package processor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// ArchiveInspectorConfig configure the archive inspector
+type ArchiveInspectorConfig struct {
+	Config
+	MaxDepth      int    // Maximum number of nested archives ( an archive in an archive is 2 )
+	MaxSize       int64  // Maximum number of bytes stored and extracted to inspect an archive
+	TempDirectory string // Directory used to store the archives being inspected
+}
+
+// ArchiveInspector reject zip, tar and tar.gz archives containing too many nested archives.
+// Archives are stored in a temporary file while they are uploaded as the zip format can't be read sequentially.
+type ArchiveInspector struct {
+	config *ArchiveInspectorConfig
+}
+
+// NewArchiveInspector create an archive inspector from the plikd.cfg parameters
+func NewArchiveInspector(params map[string]interface{}) (f *ArchiveInspector, err error) {
+	f = new(ArchiveInspector)
+	f.config = new(ArchiveInspectorConfig)
+	f.config.MaxDepth = 3
+	f.config.MaxSize = 1 << 30
+	err = decodeConfig(params, f.config)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.config.MaxDepth <= 0 {
+		return nil, fmt.Errorf("invalid negative or zero value for MaxDepth")
+	}
+	if f.config.MaxSize <= 0 {
+		return nil, fmt.Errorf("invalid negative or zero value for MaxSize")
+	}
+
+	return f, nil
+}
+
+// Name of the step
+func (f *ArchiveInspector) Name() string {
+	return f.config.GetName()
+}
+
+// New return a processor for the file
+func (f *ArchiveInspector) New(file *common.File) (Processor, error) {
+	return &archiveProcessor{config: f.config}, nil
+}
+
+// archiveFormat detect archive formats from the first bytes of the data
+func archiveFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return "zip"
+	case bytes.HasPrefix(header, []byte("\x1f\x8b")):
+		return "gzip"
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return "tar"
+	default:
+		return ""
+	}
+}
+
+type archiveProcessor struct {
+	sniffer
+	config *ArchiveInspectorConfig
+
+	skip      bool     // Not an archive
+	spool     *os.File // Temporary copy of the archive
+	size      int64
+	extracted int64
+	temp      []string
+}
+
+func (p *archiveProcessor) Write(buf []byte) (n int, err error) {
+	n = len(buf)
+	if p.skip {
+		return n, nil
+	}
+
+	if p.spool == nil {
+		before := len(p.header)
+		_, _ = p.sniffer.Write(buf)
+		if !p.complete() {
+			return n, nil
+		}
+
+		if archiveFormat(p.header) == "" {
+			p.skip = true
+			return n, nil
+		}
+
+		p.spool, err = p.tempFile()
+		if err != nil {
+			return 0, err
+		}
+
+		err = p.store(p.header)
+		if err != nil {
+			return 0, err
+		}
+		buf = buf[len(p.header)-before:]
+	}
+
+	err = p.store(buf)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (p *archiveProcessor) store(buf []byte) (err error) {
+	p.size += int64(len(buf))
+	if p.size > p.config.MaxSize {
+		return p.tooBig()
+	}
+
+	_, err = p.spool.Write(buf)
+	if err != nil {
+		return fmt.Errorf("unable to store archive : %s", err)
+	}
+
+	return nil
+}
+
+func (p *archiveProcessor) Finish(file *common.File) (result string, err error) {
+	if p.skip {
+		return "", nil
+	}
+
+	var depth int
+	if p.spool == nil {
+		// Less than sniffLen bytes
+		if archiveFormat(p.header) == "" {
+			return "", nil
+		}
+		depth, err = p.inspect(bytes.NewReader(p.header), int64(len(p.header)), 0)
+	} else {
+		depth, err = p.inspect(p.spool, p.size, 0)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if depth == 0 {
+		return "", nil
+	}
+
+	return strconv.Itoa(depth), nil
+}
+
+func (p *archiveProcessor) Close() (err error) {
+	if p.spool != nil {
+		_ = p.spool.Close()
+	}
+	for _, path := range p.temp {
+		e := os.Remove(path)
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (p *archiveProcessor) tempFile() (file *os.File, err error) {
+	file, err = os.CreateTemp(p.config.TempDirectory, "plik-archive-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file : %s", err)
+	}
+	p.temp = append(p.temp, file.Name())
+	return file, nil
+}
+
+func (p *archiveProcessor) tooBig() error {
+	return Reject("archive too big to be inspected (limit is set to %s)", humanize.Bytes(uint64(p.config.MaxSize)))
+}
+
+// limit count the extracted bytes to stop decompression bombs
+func (p *archiveProcessor) limit(r io.Reader) io.Reader {
+	return &extractReader{p: p, r: r}
+}
+
+type extractReader struct {
+	p *archiveProcessor
+	r io.Reader
+}
+
+func (r *extractReader) Read(buf []byte) (n int, err error) {
+	n, err = r.r.Read(buf)
+	r.p.extracted += int64(n)
+	if r.p.extracted > r.p.config.MaxSize {
+		return n, r.p.tooBig()
+	}
+	return n, err
+}
+
+// inspect return the archive nesting depth of the data, level is the number of enclosing archives
+func (p *archiveProcessor) inspect(r io.ReaderAt, size int64, level int) (depth int, err error) {
+	header := make([]byte, sniffLen)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return 0, fmt.Errorf("unable to read archive : %s", err)
+	}
+
+	if archiveFormat(header[:n]) == "zip" {
+		return p.inspectZip(r, size, level)
+	}
+
+	return p.inspectStream(io.NewSectionReader(r, 0, size), level)
+}
+
+func (p *archiveProcessor) inspectStream(r io.Reader, level int) (depth int, err error) {
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+	header = header[:n]
+	r = io.MultiReader(bytes.NewReader(header), r)
+
+	switch archiveFormat(header) {
+	case "gzip":
+		// Compression does not count as a nesting level
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, Reject("invalid archive : %s", err)
+		}
+		defer func() { _ = gz.Close() }()
+		return p.inspectStream(p.limit(gz), level)
+	case "tar":
+		return p.inspectTar(r, level)
+	case "zip":
+		// The zip format can't be read sequentially
+		file, err := p.tempFile()
+		if err != nil {
+			return 0, err
+		}
+		defer func() { _ = file.Close() }()
+
+		size, err := io.Copy(file, r)
+		if err != nil {
+			return 0, err
+		}
+		return p.inspectZip(file, size, level)
+	default:
+		return level, nil
+	}
+}
+
+func (p *archiveProcessor) inspectTar(r io.Reader, level int) (depth int, err error) {
+	depth, err = p.enter(level)
+	if err != nil {
+		return 0, err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, p.invalid(err)
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		d, err := p.inspectStream(tr, level+1)
+		if err != nil {
+			return 0, p.invalid(err)
+		}
+		if d > depth {
+			depth = d
+		}
+	}
+
+	return depth, nil
+}
+
+func (p *archiveProcessor) inspectZip(r io.ReaderAt, size int64, level int) (depth int, err error) {
+	depth, err = p.enter(level)
+	if err != nil {
+		return 0, err
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return 0, Reject("invalid archive : %s", err)
+	}
+
+	for _, entry := range zr.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return 0, Reject("invalid archive : %s", err)
+		}
+		d, err := p.inspectStream(p.limit(rc), level+1)
+		_ = rc.Close()
+		if err != nil {
+			return 0, p.invalid(err)
+		}
+		if d > depth {
+			depth = d
+		}
+	}
+
+	return depth, nil
+}
+
+// enter check the nesting depth of a new archive
+func (p *archiveProcessor) enter(level int) (depth int, err error) {
+	depth = level + 1
+	if depth > p.config.MaxDepth {
+		return 0, Reject("too many nested archives (limit is %d)", p.config.MaxDepth)
+	}
+	return depth, nil
+}
+
+// invalid keep rejections and turn read errors into rejections
+func (p *archiveProcessor) invalid(err error) error {
+	if _, ok := err.(common.HTTPError); ok {
+		return err
+	}
+	return Reject("invalid archive : %s", err)
+}
@@ -0,0 +1,127 @@
+package processor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func newZip(t *testing.T, files map[string][]byte) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err, "unable to create zip entry")
+		_, err = w.Write(content)
+		require.NoError(t, err, "unable to write zip entry")
+	}
+	require.NoError(t, zw.Close(), "unable to close zip")
+	return buf.Bytes()
+}
+
+func newTarGz(t *testing.T, files map[string][]byte) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))})
+		require.NoError(t, err, "unable to write tar header")
+		_, err = tw.Write(content)
+		require.NoError(t, err, "unable to write tar entry")
+	}
+	require.NoError(t, tw.Close(), "unable to close tar")
+	require.NoError(t, gz.Close(), "unable to close gzip")
+	return buf.Bytes()
+}
+
+func newArchiveChain(t *testing.T, params map[string]interface{}) (chain *Chain, dir string) {
+	dir = t.TempDir()
+	params["Type"] = "archive"
+	params["TempDirectory"] = dir
+	chain, err := NewChain([]map[string]interface{}{params})
+	require.NoError(t, err, "unable to create chain")
+	return chain, dir
+}
+
+func requireEmptyDir(t *testing.T, dir string) {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err, "unable to list temporary directory")
+	require.Empty(t, entries, "temporary files should have been removed")
+}
+
+func TestArchiveInspector(t *testing.T) {
+	chain, dir := newArchiveChain(t, map[string]interface{}{"MaxDepth": 2})
+
+	file, err := process(t, chain, "file.txt", bytes.Repeat([]byte("plik"), 1000))
+	require.NoError(t, err, "unable to process file")
+	require.Nil(t, file.Processors, "plain files should not be annotated")
+
+	zip1 := newZip(t, map[string][]byte{"file.txt": []byte("plik"), "dir/": nil})
+	file, err = process(t, chain, "file.zip", zip1)
+	require.NoError(t, err, "unable to process file")
+	require.Equal(t, "1", file.Processors["archive"], "invalid archive depth")
+
+	zip2 := newZip(t, map[string][]byte{"file.zip": zip1, "file.txt": []byte("plik")})
+	file, err = process(t, chain, "file.zip", zip2)
+	require.NoError(t, err, "unable to process file")
+	require.Equal(t, "2", file.Processors["archive"], "invalid archive depth")
+
+	zip3 := newZip(t, map[string][]byte{"file.zip": zip2})
+	_, err = process(t, chain, "file.zip", zip3)
+	common.RequireError(t, err, "too many nested archives (limit is 2)")
+
+	requireEmptyDir(t, dir)
+}
+
+func TestArchiveInspectorTarGz(t *testing.T) {
+	chain, dir := newArchiveChain(t, map[string]interface{}{"MaxDepth": 2})
+
+	zip1 := newZip(t, map[string][]byte{"file.txt": []byte("plik")})
+	file, err := process(t, chain, "file.tar.gz", newTarGz(t, map[string][]byte{"file.zip": zip1}))
+	require.NoError(t, err, "unable to process file")
+	require.Equal(t, "2", file.Processors["archive"], "invalid archive depth")
+
+	nested := newTarGz(t, map[string][]byte{"file.tar.gz": newTarGz(t, map[string][]byte{"file.zip": zip1})})
+	_, err = process(t, chain, "file.tar.gz", nested)
+	common.RequireError(t, err, "too many nested archives (limit is 2)")
+
+	requireEmptyDir(t, dir)
+}
+
+func TestArchiveInspectorMaxSize(t *testing.T) {
+	chain, dir := newArchiveChain(t, map[string]interface{}{"MaxSize": 10000})
+
+	// Highly compressible data to simulate a decompression bomb
+	bomb := newTarGz(t, map[string][]byte{"file.txt": make([]byte, 100000), "file.zip": newZip(t, nil)})
+	require.True(t, len(bomb) < 10000, "archive should be smaller than the limit")
+
+	_, err := process(t, chain, "bomb.tar.gz", bomb)
+	common.RequireError(t, err, "archive too big to be inspected (limit is set to 10 kB)")
+
+	_, err = process(t, chain, "big.zip", append(newZip(t, nil), make([]byte, 10000)...))
+	common.RequireError(t, err, "archive too big to be inspected (limit is set to 10 kB)")
+
+	requireEmptyDir(t, dir)
+}
+
+func TestArchiveInspectorInvalid(t *testing.T) {
+	chain, dir := newArchiveChain(t, map[string]interface{}{})
+
+	_, err := process(t, chain, "file.zip", append([]byte("PK\x03\x04"), make([]byte, 1000)...))
+	common.RequireError(t, err, "invalid archive")
+
+	requireEmptyDir(t, dir)
+
+	_, err = NewArchiveInspector(map[string]interface{}{"Type": "archive", "MaxDepth": 0})
+	common.RequireError(t, err, "invalid negative or zero value for MaxDepth")
+
+	_, err = NewArchiveInspector(map[string]interface{}{"Type": "archive", "MaxSize": -1})
+	common.RequireError(t, err, "invalid negative or zero value for MaxSize")
+}
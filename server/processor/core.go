@@ -0,0 +1,164 @@
+package processor
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"net/http"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// sniffLen is the number of bytes used to detect the content type
+const sniffLen = 512
+
+// SizeLimit compute the file size and reject files bigger than the limit
+type SizeLimit struct {
+	maxFileSize int64
+}
+
+// NewSizeLimit create a size limit step ( no limit if maxFileSize <= 0 )
+func NewSizeLimit(maxFileSize int64) *SizeLimit {
+	return &SizeLimit{maxFileSize: maxFileSize}
+}
+
+// Name of the step
+func (f *SizeLimit) Name() string {
+	return "size"
+}
+
+// New return a processor for the file
+func (f *SizeLimit) New(file *common.File) (Processor, error) {
+	return &sizeProcessor{maxFileSize: f.maxFileSize}, nil
+}
+
+type sizeProcessor struct {
+	nopProcessor
+	maxFileSize int64
+	size        int64
+}
+
+func (p *sizeProcessor) Write(buf []byte) (n int, err error) {
+	p.size += int64(len(buf))
+	if p.maxFileSize > 0 && p.size > p.maxFileSize {
+		return 0, common.NewHTTPError(fmt.Sprintf("file too big (limit is set to %s)", humanize.Bytes(uint64(p.maxFileSize))), nil, http.StatusBadRequest)
+	}
+	return len(buf), nil
+}
+
+func (p *sizeProcessor) Finish(file *common.File) (result string, err error) {
+	file.Size = p.size
+	return "", nil
+}
+
+// MD5 compute the file md5sum
+type MD5 struct{}
+
+// NewMD5 create a md5sum step
+func NewMD5() *MD5 {
+	return &MD5{}
+}
+
+// Name of the step
+func (f *MD5) Name() string {
+	return "md5"
+}
+
+// New return a processor for the file
+func (f *MD5) New(file *common.File) (Processor, error) {
+	return &hashProcessor{hash: md5.New(), set: func(file *common.File, sum string) { file.Md5 = sum }}, nil
+}
+
+// SHA256 compute the file SHA-256 digest
+type SHA256 struct{}
+
+// NewSHA256 create a SHA-256 digest step
+func NewSHA256() *SHA256 {
+	return &SHA256{}
+}
+
+// Name of the step
+func (f *SHA256) Name() string {
+	return "sha256"
+}
+
+// New return a processor for the file
+func (f *SHA256) New(file *common.File) (Processor, error) {
+	return &hashProcessor{hash: sha256.New(), set: func(file *common.File, sum string) { file.Sha256 = sum }}, nil
+}
+
+type hashProcessor struct {
+	nopProcessor
+	hash hash.Hash
+	set  func(file *common.File, sum string)
+}
+
+func (p *hashProcessor) Write(buf []byte) (n int, err error) {
+	return p.hash.Write(buf)
+}
+
+func (p *hashProcessor) Finish(file *common.File) (result string, err error) {
+	p.set(file, fmt.Sprintf("%x", p.hash.Sum(nil)))
+	return "", nil
+}
+
+// ContentType detect the file content type
+type ContentType struct{}
+
+// NewContentType create a content type detection step
+func NewContentType() *ContentType {
+	return &ContentType{}
+}
+
+// Name of the step
+func (f *ContentType) Name() string {
+	return "type"
+}
+
+// New return a processor for the file
+func (f *ContentType) New(file *common.File) (Processor, error) {
+	return &contentTypeProcessor{}, nil
+}
+
+// sniffer keep the first bytes of the data to detect the content type
+type sniffer struct {
+	header []byte
+}
+
+func (s *sniffer) Write(buf []byte) (n int, err error) {
+	if missing := sniffLen - len(s.header); missing > 0 {
+		if missing > len(buf) {
+			missing = len(buf)
+		}
+		s.header = append(s.header, buf[:missing]...)
+	}
+	return len(buf), nil
+}
+
+func (s *sniffer) complete() bool {
+	return len(s.header) >= sniffLen
+}
+
+func (s *sniffer) contentType() string {
+	if len(s.header) == 0 {
+		return ""
+	}
+	return http.DetectContentType(s.header)
+}
+
+type contentTypeProcessor struct {
+	nopProcessor
+	sniffer
+}
+
+func (p *contentTypeProcessor) Write(buf []byte) (n int, err error) {
+	return p.sniffer.Write(buf)
+}
+
+func (p *contentTypeProcessor) Finish(file *common.File) (result string, err error) {
+	file.Type = p.contentType()
+	return "", nil
+}
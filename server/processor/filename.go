@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// FilenameFilterConfig configure the filename filter
+type FilenameFilterConfig struct {
+	Config
+	Deny []string // Regular expressions matched against the file name
+}
+
+// FilenameFilter reject files whose name match a denied regular expression
+type FilenameFilter struct {
+	config *FilenameFilterConfig
+	deny   []*regexp.Regexp
+}
+
+// NewFilenameFilter create a filename filter from the plikd.cfg parameters
+func NewFilenameFilter(params map[string]interface{}) (f *FilenameFilter, err error) {
+	f = new(FilenameFilter)
+	f.config = new(FilenameFilterConfig)
+	err = decodeConfig(params, f.config)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(f.config.Deny) == 0 {
+		return nil, fmt.Errorf("missing Deny regular expressions")
+	}
+
+	for _, expr := range f.config.Deny {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %s : %s", expr, err)
+		}
+		f.deny = append(f.deny, re)
+	}
+
+	return f, nil
+}
+
+// Name of the step
+func (f *FilenameFilter) Name() string {
+	return f.config.GetName()
+}
+
+// New reject the file before any data is read if its name is denied
+func (f *FilenameFilter) New(file *common.File) (Processor, error) {
+	for _, re := range f.deny {
+		if re.MatchString(file.Name) {
+			return nil, Reject("file name %s is not allowed", file.Name)
+		}
+	}
+	return nopProcessor{}, nil
+}
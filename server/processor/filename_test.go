@@ -0,0 +1,31 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestFilenameFilter(t *testing.T) {
+	chain, err := NewChain([]map[string]interface{}{{"Type": "filename", "Deny": []interface{}{`(?i)\.(exe|bat)$`, `^\.`}}})
+	require.NoError(t, err, "unable to create chain")
+
+	_, err = process(t, chain, "setup.EXE", []byte("data"))
+	common.RequireError(t, err, "file name setup.EXE is not allowed")
+
+	_, err = process(t, chain, ".htaccess", []byte("data"))
+	common.RequireError(t, err, "file name .htaccess is not allowed")
+
+	_, err = process(t, chain, "setup.exe.txt", []byte("data"))
+	require.NoError(t, err, "file should be allowed")
+}
+
+func TestFilenameFilterInvalid(t *testing.T) {
+	_, err := NewFilenameFilter(map[string]interface{}{"Type": "filename"})
+	common.RequireError(t, err, "missing Deny regular expressions")
+
+	_, err = NewFilenameFilter(map[string]interface{}{"Type": "filename", "Deny": []interface{}{"("}})
+	common.RequireError(t, err, "invalid regular expression (")
+}
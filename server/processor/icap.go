@@ -0,0 +1,213 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+/*
+  ICAP RESPMOD request ( RFC 3507 ) :
+    - The file is sent as the body of an encapsulated HTTP response using the chunked transfer encoding
+    - "ICAP/1.0 204 No Content" -> the file is accepted
+    - "ICAP/1.0 200 OK"         -> the server modified the response ( block page ), the file is rejected
+                                   the reason is read from the X-Infection-Found / X-Virus-ID / X-Violations-Found headers
+*/
+
+// ICAPClientConfig configure the ICAP client
+type ICAPClientConfig struct {
+	Config
+	URL     string // icap://host:port/service
+	Timeout string // Timeout of each network operation ( default 60s )
+}
+
+// ICAPClient send the files to an ICAP server
+type ICAPClient struct {
+	config  *ICAPClientConfig
+	url     *url.URL
+	timeout time.Duration
+}
+
+// NewICAPClient create an ICAP client from the plikd.cfg parameters
+func NewICAPClient(params map[string]interface{}) (f *ICAPClient, err error) {
+	f = new(ICAPClient)
+	f.config = new(ICAPClientConfig)
+	f.config.Timeout = "60s"
+	err = decodeConfig(params, f.config)
+	if err != nil {
+		return nil, err
+	}
+
+	f.url, err = url.Parse(f.config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s : %s", f.config.URL, err)
+	}
+	if f.url.Scheme != "icap" || f.url.Host == "" {
+		return nil, fmt.Errorf("invalid URL %s, expected icap://host:port/service", f.config.URL)
+	}
+	if f.url.Port() == "" {
+		f.url.Host = net.JoinHostPort(f.url.Hostname(), "1344")
+	}
+
+	f.timeout, err = time.ParseDuration(f.config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Timeout %s : %s", f.config.Timeout, err)
+	}
+	if f.timeout <= 0 {
+		return nil, fmt.Errorf("invalid negative or zero value for Timeout")
+	}
+
+	return f, nil
+}
+
+// Name of the step
+func (f *ICAPClient) Name() string {
+	return f.config.GetName()
+}
+
+// New connect to the ICAP server and send the request headers
+func (f *ICAPClient) New(file *common.File) (Processor, error) {
+	conn, err := net.DialTimeout("tcp", f.url.Host, f.timeout)
+	if err != nil {
+		return nil, f.unavailable(fmt.Errorf("unable to connect to icap server : %s", err))
+	}
+
+	p := &icapProcessor{client: f, conn: conn}
+
+	reqHeader := fmt.Sprintf("GET /%s HTTP/1.1\r\nHost: plik\r\n\r\n", url.PathEscape(file.Name))
+	resHeader := "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nTransfer-Encoding: chunked\r\n\r\n"
+
+	header := &strings.Builder{}
+	header.WriteString(fmt.Sprintf("RESPMOD %s ICAP/1.0\r\n", f.url.String()))
+	header.WriteString(fmt.Sprintf("Host: %s\r\n", f.url.Host))
+	header.WriteString("Allow: 204\r\n")
+	header.WriteString(fmt.Sprintf("Encapsulated: req-hdr=0, res-hdr=%d, res-body=%d\r\n", len(reqHeader), len(reqHeader)+len(resHeader)))
+	header.WriteString("\r\n")
+	header.WriteString(reqHeader)
+	header.WriteString(resHeader)
+
+	err = p.send([]byte(header.String()))
+	if err != nil {
+		_ = p.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (f *ICAPClient) unavailable(err error) error {
+	return common.NewHTTPError("unable to inspect file", err, http.StatusServiceUnavailable)
+}
+
+type icapProcessor struct {
+	client *ICAPClient
+	conn   net.Conn
+}
+
+func (p *icapProcessor) send(buf []byte) (err error) {
+	_ = p.conn.SetWriteDeadline(time.Now().Add(p.client.timeout))
+	_, err = p.conn.Write(buf)
+	if err != nil {
+		return p.client.unavailable(fmt.Errorf("unable to send data to icap server : %s", err))
+	}
+	return nil
+}
+
+// Write send the data as a chunk of the encapsulated body
+func (p *icapProcessor) Write(buf []byte) (n int, err error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	err = p.send([]byte(fmt.Sprintf("%x\r\n", len(buf))))
+	if err != nil {
+		return 0, err
+	}
+	err = p.send(buf)
+	if err != nil {
+		return 0, err
+	}
+	err = p.send([]byte("\r\n"))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(buf), nil
+}
+
+// Finish send the last chunk and read the ICAP server verdict
+func (p *icapProcessor) Finish(file *common.File) (result string, err error) {
+	err = p.send([]byte("0\r\n\r\n"))
+	if err != nil {
+		return "", err
+	}
+
+	_ = p.conn.SetReadDeadline(time.Now().Add(p.client.timeout))
+	reader := textproto.NewReader(bufio.NewReader(p.conn))
+
+	line, err := reader.ReadLine()
+	if err != nil {
+		return "", p.client.unavailable(fmt.Errorf("unable to read icap server reply : %s", err))
+	}
+
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return "", p.client.unavailable(fmt.Errorf("unable to read icap server reply : %s", err))
+	}
+
+	var status int
+	_, err = fmt.Sscanf(line, "ICAP/1.0 %d", &status)
+	if err != nil {
+		return "", p.client.unavailable(fmt.Errorf("invalid icap server reply : %s", line))
+	}
+
+	switch status {
+	case http.StatusNoContent:
+		if tag := strings.Trim(header.Get("ISTag"), "\""); tag != "" {
+			return "clean (" + tag + ")", nil
+		}
+		return "clean", nil
+	case http.StatusOK:
+		if reason := icapRejectReason(header); reason != "" {
+			return "", Reject("file rejected by %s : %s", p.client.Name(), reason)
+		}
+		return "", Reject("file rejected by %s", p.client.Name())
+	default:
+		return "", p.client.unavailable(fmt.Errorf("unexpected icap server reply : %s", line))
+	}
+}
+
+func (p *icapProcessor) Close() error {
+	return p.conn.Close()
+}
+
+// icapRejectReason extract the reason of the rejection from the ICAP reply headers
+func icapRejectReason(header textproto.MIMEHeader) string {
+	// X-Infection-Found: Type=0; Resolution=2; Threat=Eicar-Test-Signature;
+	if infection := header.Get("X-Infection-Found"); infection != "" {
+		for _, field := range strings.Split(infection, ";") {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, "Threat=") {
+				return strings.TrimPrefix(field, "Threat=")
+			}
+		}
+		return infection
+	}
+
+	if virus := header.Get("X-Virus-ID"); virus != "" {
+		return virus
+	}
+
+	if violations := header.Get("X-Violations-Found"); violations != "" {
+		return violations
+	}
+
+	return ""
+}
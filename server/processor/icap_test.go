@@ -0,0 +1,175 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// testICAPServer is a minimal ICAP server rejecting the files containing "virus"
+type testICAPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	status   string
+	requests []string
+	bodies   [][]byte
+}
+
+func newTestICAPServer(t *testing.T) (server *testICAPServer) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "unable to start icap server")
+
+	server = &testICAPServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handle(conn)
+		}
+	}()
+
+	return server
+}
+
+func (server *testICAPServer) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	reader := textproto.NewReader(r)
+
+	line, err := reader.ReadLine()
+	if err != nil {
+		return
+	}
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return
+	}
+
+	// Skip the encapsulated HTTP headers
+	var offset int
+	for _, field := range strings.Split(header.Get("Encapsulated"), ",") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(field), "res-body="); ok {
+			offset, _ = strconv.Atoi(value)
+		}
+	}
+	encapsulated := make([]byte, offset)
+	if _, err = io.ReadFull(r, encapsulated); err != nil {
+		return
+	}
+
+	// Read the chunked body
+	body := &bytes.Buffer{}
+	for {
+		sizeLine, err := reader.ReadLine()
+		if err != nil {
+			return
+		}
+		size, err := strconv.ParseInt(sizeLine, 16, 64)
+		if err != nil {
+			return
+		}
+		if size == 0 {
+			_, _ = reader.ReadLine()
+			break
+		}
+		if _, err = io.CopyN(body, r, size); err != nil {
+			return
+		}
+		if _, err = reader.ReadLine(); err != nil {
+			return
+		}
+	}
+
+	server.mu.Lock()
+	server.requests = append(server.requests, line+"\n"+string(encapsulated))
+	server.bodies = append(server.bodies, body.Bytes())
+	status := server.status
+	server.mu.Unlock()
+
+	switch {
+	case status != "":
+		_, _ = fmt.Fprintf(conn, "ICAP/1.0 %s\r\n\r\n", status)
+	case bytes.Contains(body.Bytes(), []byte("virus")):
+		_, _ = fmt.Fprintf(conn, "ICAP/1.0 200 OK\r\nISTag: \"test-1\"\r\nX-Infection-Found: Type=0; Resolution=2; Threat=Test-Virus;\r\nEncapsulated: null-body=0\r\n\r\n")
+	default:
+		_, _ = fmt.Fprintf(conn, "ICAP/1.0 204 No Content\r\nISTag: \"test-1\"\r\n\r\n")
+	}
+}
+
+func (server *testICAPServer) setStatus(status string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.status = status
+}
+
+func (server *testICAPServer) url() string {
+	return "icap://" + server.listener.Addr().String() + "/avscan"
+}
+
+func TestICAPClient(t *testing.T) {
+	server := newTestICAPServer(t)
+	defer func() { _ = server.listener.Close() }()
+
+	chain, err := NewChain([]map[string]interface{}{{"Type": "icap", "URL": server.url(), "Timeout": "5s"}})
+	require.NoError(t, err, "unable to create chain")
+
+	data := bytes.Repeat([]byte("plik"), 1000)
+	file, err := process(t, chain, "my file.txt", data)
+	require.NoError(t, err, "unable to process file")
+	require.Equal(t, "clean (test-1)", file.Processors["icap"], "invalid icap result")
+
+	require.Len(t, server.requests, 1, "invalid request count")
+	require.True(t, strings.HasPrefix(server.requests[0], "RESPMOD "+server.url()+" ICAP/1.0\n"), "invalid request line")
+	require.Contains(t, server.requests[0], "GET /my%20file.txt HTTP/1.1", "missing file name")
+	require.Equal(t, data, server.bodies[0], "invalid body")
+
+	_, err = process(t, chain, "virus.txt", []byte("this is a virus"))
+	common.RequireError(t, err, "file rejected by icap : Test-Virus")
+
+	file, err = process(t, chain, "empty", []byte{})
+	require.NoError(t, err, "unable to process empty file")
+	require.Equal(t, "clean (test-1)", file.Processors["icap"], "invalid icap result")
+}
+
+func TestICAPClientError(t *testing.T) {
+	server := newTestICAPServer(t)
+
+	chain, err := NewChain([]map[string]interface{}{{"Type": "icap", "URL": server.url()}})
+	require.NoError(t, err, "unable to create chain")
+
+	server.setStatus("500 Server Error")
+	_, err = process(t, chain, "file.txt", []byte("data"))
+	common.RequireError(t, err, "unable to inspect file")
+	require.Contains(t, err.(common.HTTPError).Err.Error(), "unexpected icap server reply : ICAP/1.0 500 Server Error", "invalid error")
+
+	_ = server.listener.Close()
+	_, err = process(t, chain, "file.txt", []byte("data"))
+	common.RequireError(t, err, "unable to inspect file")
+	require.Contains(t, err.(common.HTTPError).Err.Error(), "unable to connect to icap server", "invalid error")
+}
+
+func TestICAPClientInvalid(t *testing.T) {
+	_, err := NewICAPClient(map[string]interface{}{"Type": "icap", "URL": "http://127.0.0.1/avscan"})
+	common.RequireError(t, err, "invalid URL http://127.0.0.1/avscan, expected icap://host:port/service")
+
+	_, err = NewICAPClient(map[string]interface{}{"Type": "icap", "URL": "icap://127.0.0.1/avscan", "Timeout": "foo"})
+	common.RequireError(t, err, "invalid Timeout foo")
+
+	f, err := NewICAPClient(map[string]interface{}{"Type": "icap", "URL": "icap://127.0.0.1/avscan"})
+	require.NoError(t, err, "unable to create icap client")
+	require.Equal(t, "127.0.0.1:1344", f.url.Host, "invalid default port")
+}
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// MimeTypeFilterConfig configure the MIME type filter
+type MimeTypeFilterConfig struct {
+	Config
+	Deny []string // MIME types ( ex : "application/zip" ) or families ( ex : "video/*" )
+}
+
+// MimeTypeFilter reject files whose detected MIME type is denied
+type MimeTypeFilter struct {
+	config *MimeTypeFilterConfig
+}
+
+// NewMimeTypeFilter create a MIME type filter from the plikd.cfg parameters
+func NewMimeTypeFilter(params map[string]interface{}) (f *MimeTypeFilter, err error) {
+	f = new(MimeTypeFilter)
+	f.config = new(MimeTypeFilterConfig)
+	err = decodeConfig(params, f.config)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(f.config.Deny) == 0 {
+		return nil, fmt.Errorf("missing Deny MIME types")
+	}
+
+	for i, mimeType := range f.config.Deny {
+		f.config.Deny[i] = strings.ToLower(strings.TrimSpace(mimeType))
+		if !strings.Contains(mimeType, "/") {
+			return nil, fmt.Errorf("invalid MIME type %s", mimeType)
+		}
+	}
+
+	return f, nil
+}
+
+// Name of the step
+func (f *MimeTypeFilter) Name() string {
+	return f.config.GetName()
+}
+
+// New return a processor for the file
+func (f *MimeTypeFilter) New(file *common.File) (Processor, error) {
+	return &mimeTypeProcessor{filter: f}, nil
+}
+
+// denied check if the content type match a denied MIME type
+func (f *MimeTypeFilter) denied(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+
+	for _, deny := range f.config.Deny {
+		if deny == mediaType {
+			return true
+		}
+		if strings.HasSuffix(deny, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(deny, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type mimeTypeProcessor struct {
+	nopProcessor
+	sniffer
+	filter  *MimeTypeFilter
+	checked bool
+}
+
+// Write reject the file as soon as enough data has been received to detect the content type
+func (p *mimeTypeProcessor) Write(buf []byte) (n int, err error) {
+	if p.checked {
+		return len(buf), nil
+	}
+
+	_, _ = p.sniffer.Write(buf)
+	if p.complete() {
+		err = p.check()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(buf), nil
+}
+
+func (p *mimeTypeProcessor) Finish(file *common.File) (result string, err error) {
+	if !p.checked {
+		err = p.check()
+	}
+	return "", err
+}
+
+func (p *mimeTypeProcessor) check() error {
+	p.checked = true
+	contentType := p.contentType()
+	if contentType != "" && p.filter.denied(contentType) {
+		return Reject("file type %s is not allowed", strings.Split(contentType, ";")[0])
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package processor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+var png = append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), make([]byte, 1000)...)
+
+func TestMimeTypeFilter(t *testing.T) {
+	chain, err := NewChain([]map[string]interface{}{{"Type": "mimetype", "Deny": []interface{}{"image/*", "Application/PDF"}}})
+	require.NoError(t, err, "unable to create chain")
+
+	_, err = process(t, chain, "file.png", png)
+	common.RequireError(t, err, "file type image/png is not allowed")
+
+	_, err = process(t, chain, "file.pdf", []byte("%PDF-1.4"))
+	common.RequireError(t, err, "file type application/pdf is not allowed")
+
+	_, err = process(t, chain, "file.txt", bytes.Repeat([]byte("plik"), 1000))
+	require.NoError(t, err, "file should be allowed")
+
+	_, err = process(t, chain, "empty", []byte{})
+	require.NoError(t, err, "file should be allowed")
+}
+
+func TestMimeTypeFilterRejectEarly(t *testing.T) {
+	f, err := NewMimeTypeFilter(map[string]interface{}{"Type": "mimetype", "Deny": []interface{}{"image/png"}})
+	require.NoError(t, err, "unable to create filter")
+
+	p, err := f.New(common.NewFile())
+	require.NoError(t, err, "unable to create processor")
+
+	_, err = p.Write(png)
+	common.RequireError(t, err, "file type image/png is not allowed")
+}
+
+func TestMimeTypeFilterInvalid(t *testing.T) {
+	_, err := NewMimeTypeFilter(map[string]interface{}{"Type": "mimetype"})
+	common.RequireError(t, err, "missing Deny MIME types")
+
+	_, err = NewMimeTypeFilter(map[string]interface{}{"Type": "mimetype", "Deny": []interface{}{"image"}})
+	common.RequireError(t, err, "invalid MIME type image")
+}
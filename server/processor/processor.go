@@ -0,0 +1,203 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+/*
+  Content inspection pipeline :
+    - The chain is built at startup from the [[Processors]] sections of plikd.cfg
+    - For each uploaded file every Factory of the chain creates a new Processor
+    - The file data is written to each processor as it is uploaded
+    - Finish is called in order once all the data has been saved, so processors can rely on the file metadata
+      set by the previous ones ( size, md5, content type )
+    - Any processor can reject the file by returning an error, results are stored in file.Processors
+*/
+
+// Processor inspect the data of a file while it is uploaded
+type Processor interface {
+	// Write receive the file data as it is uploaded, returning an error rejects the file
+	Write(p []byte) (n int, err error)
+	// Finish is called once all the data has been received, it may update the file metadata,
+	// reject the file or return a result to be stored with the file
+	Finish(file *common.File) (result string, err error)
+	// Close release the processor resources, it is always called even if the upload failed
+	Close() error
+}
+
+// Factory create the processors of a chain step
+type Factory interface {
+	// Name of the step, used as the key of the results stored with the file
+	Name() string
+	// New return a processor for the file, returning an error rejects the file before any data is read
+	New(file *common.File) (Processor, error)
+}
+
+// Config common to every processor
+type Config struct {
+	Type string
+	Name string // Defaults to Type
+}
+
+// GetName return the name of the step
+func (config *Config) GetName() string {
+	if config.Name != "" {
+		return config.Name
+	}
+	return config.Type
+}
+
+// Reject return an error rejecting the file
+func Reject(format string, args ...interface{}) error {
+	return common.NewHTTPError(fmt.Sprintf(format, args...), nil, http.StatusBadRequest)
+}
+
+// decodeConfig decode the processor parameters from plikd.cfg into the processor configuration
+func decodeConfig(params map[string]interface{}, config interface{}) (err error) {
+	buf, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(buf)))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(config)
+}
+
+// Chain of the configured processors
+type Chain struct {
+	factories []Factory
+}
+
+// NewChain create the processor chain from the plikd.cfg configuration
+func NewChain(configs []map[string]interface{}) (chain *Chain, err error) {
+	chain = new(Chain)
+
+	names := make(map[string]bool)
+	for i, params := range configs {
+		typ, _ := params["Type"].(string)
+
+		var factory Factory
+		switch typ {
+		case "filename":
+			factory, err = NewFilenameFilter(params)
+		case "mimetype":
+			factory, err = NewMimeTypeFilter(params)
+		case "archive":
+			factory, err = NewArchiveInspector(params)
+		case "icap":
+			factory, err = NewICAPClient(params)
+		case "":
+			return nil, fmt.Errorf("missing type for processor %d", i+1)
+		default:
+			return nil, fmt.Errorf("invalid processor type %s", typ)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s processor configuration : %s", typ, err)
+		}
+
+		if names[factory.Name()] {
+			return nil, fmt.Errorf("duplicate processor name %s", factory.Name())
+		}
+		names[factory.Name()] = true
+
+		chain.factories = append(chain.factories, factory)
+	}
+
+	return chain, nil
+}
+
+// Len return the number of steps of the chain
+func (chain *Chain) Len() int {
+	if chain == nil {
+		return 0
+	}
+	return len(chain.factories)
+}
+
+// New create the pipeline of processors for a file.
+// The core factories are run first, then the steps of the chain ( the chain may be nil ).
+func (chain *Chain) New(file *common.File, core ...Factory) (pipeline *Pipeline, err error) {
+	factories := core
+	if chain != nil {
+		factories = append(factories, chain.factories...)
+	}
+
+	pipeline = new(Pipeline)
+	for _, factory := range factories {
+		p, err := factory.New(file)
+		if err != nil {
+			_ = pipeline.Close()
+			return nil, err
+		}
+		pipeline.names = append(pipeline.names, factory.Name())
+		pipeline.processors = append(pipeline.processors, p)
+	}
+
+	return pipeline, nil
+}
+
+// Pipeline of processors instantiated for a file
+type Pipeline struct {
+	names      []string
+	processors []Processor
+}
+
+// Write forward the data to every processor
+func (pipeline *Pipeline) Write(p []byte) (n int, err error) {
+	for _, processor := range pipeline.processors {
+		_, err = processor.Write(p)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Finish every processor in order and store the results in the file metadata
+func (pipeline *Pipeline) Finish(file *common.File) (err error) {
+	file.Processors = nil
+	for i, processor := range pipeline.processors {
+		result, err := processor.Finish(file)
+		if err != nil {
+			return err
+		}
+		if result != "" {
+			if file.Processors == nil {
+				file.Processors = make(map[string]string)
+			}
+			file.Processors[pipeline.names[i]] = result
+		}
+	}
+	return nil
+}
+
+// Close every processor
+func (pipeline *Pipeline) Close() (err error) {
+	for _, processor := range pipeline.processors {
+		if e := processor.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// nopProcessor implement the Processor methods a processor does not need
+type nopProcessor struct{}
+
+func (nopProcessor) Write(p []byte) (n int, err error) {
+	return len(p), nil
+}
+
+func (nopProcessor) Finish(file *common.File) (result string, err error) {
+	return "", nil
+}
+
+func (nopProcessor) Close() error {
+	return nil
+}
@@ -0,0 +1,181 @@
+package processor
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+type testFactory struct {
+	name      string
+	newErr    error
+	writeErr  error
+	finishErr error
+	result    string
+	closed    int
+}
+
+func (f *testFactory) Name() string {
+	return f.name
+}
+
+func (f *testFactory) New(file *common.File) (Processor, error) {
+	if f.newErr != nil {
+		return nil, f.newErr
+	}
+	return &testProcessor{factory: f}, nil
+}
+
+type testProcessor struct {
+	factory *testFactory
+	data    bytes.Buffer
+}
+
+func (p *testProcessor) Write(buf []byte) (n int, err error) {
+	if p.factory.writeErr != nil {
+		return 0, p.factory.writeErr
+	}
+	return p.data.Write(buf)
+}
+
+func (p *testProcessor) Finish(file *common.File) (result string, err error) {
+	if p.factory.finishErr != nil {
+		return "", p.factory.finishErr
+	}
+	if p.factory.result != "" {
+		return fmt.Sprintf("%s:%d", p.factory.result, p.data.Len()), nil
+	}
+	return "", nil
+}
+
+func (p *testProcessor) Close() error {
+	p.factory.closed++
+	return nil
+}
+
+// process run the data through a new pipeline and return the file
+func process(t *testing.T, chain *Chain, name string, data []byte, core ...Factory) (file *common.File, err error) {
+	file = common.NewFile()
+	file.Name = name
+
+	pipeline, err := chain.New(file, core...)
+	if err != nil {
+		return file, err
+	}
+	defer func() { require.NoError(t, pipeline.Close(), "unable to close pipeline") }()
+
+	// Write in small chunks to exercise the processors buffering
+	for len(data) > 0 {
+		n := 100
+		if n > len(data) {
+			n = len(data)
+		}
+		_, err = pipeline.Write(data[:n])
+		if err != nil {
+			return file, err
+		}
+		data = data[n:]
+	}
+
+	return file, pipeline.Finish(file)
+}
+
+func TestNewChain(t *testing.T) {
+	chain, err := NewChain(nil)
+	require.NoError(t, err, "unable to create chain")
+	require.Equal(t, 0, chain.Len(), "invalid chain length")
+
+	chain, err = NewChain([]map[string]interface{}{
+		{"Type": "filename", "Deny": []interface{}{`\.exe$`}},
+		{"Type": "mimetype", "Name": "nozip", "Deny": []interface{}{"application/zip"}},
+		{"Type": "archive", "MaxDepth": 2},
+		{"Type": "icap", "URL": "icap://127.0.0.1/avscan"},
+	})
+	require.NoError(t, err, "unable to create chain")
+	require.Equal(t, 4, chain.Len(), "invalid chain length")
+	require.Equal(t, "filename", chain.factories[0].Name(), "invalid processor name")
+	require.Equal(t, "nozip", chain.factories[1].Name(), "invalid processor name")
+}
+
+func TestNewChainInvalid(t *testing.T) {
+	_, err := NewChain([]map[string]interface{}{{"Deny": []interface{}{"foo"}}})
+	common.RequireError(t, err, "missing type for processor 1")
+
+	_, err = NewChain([]map[string]interface{}{{"Type": "foo"}})
+	common.RequireError(t, err, "invalid processor type foo")
+
+	_, err = NewChain([]map[string]interface{}{{"Type": "filename", "Deny": []interface{}{"foo"}, "Foo": "bar"}})
+	common.RequireError(t, err, "invalid filename processor configuration : json: unknown field \"Foo\"")
+
+	_, err = NewChain([]map[string]interface{}{
+		{"Type": "filename", "Deny": []interface{}{"foo"}},
+		{"Type": "filename", "Deny": []interface{}{"bar"}},
+	})
+	common.RequireError(t, err, "duplicate processor name filename")
+}
+
+func TestPipeline(t *testing.T) {
+	f1 := &testFactory{name: "one", result: "foo"}
+	f2 := &testFactory{name: "two"}
+	chain := &Chain{factories: []Factory{f2}}
+
+	data := bytes.Repeat([]byte("plik"), 1000)
+	file, err := process(t, chain, "file.txt", data, f1, NewSizeLimit(0), NewMD5(), NewSHA256(), NewContentType())
+	require.NoError(t, err, "unable to process file")
+
+	require.Equal(t, int64(len(data)), file.Size, "invalid file size")
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum(data)), file.Md5, "invalid file md5")
+	require.Equal(t, fmt.Sprintf("%x", sha256.Sum256(data)), file.Sha256, "invalid file sha256")
+	require.Equal(t, "text/plain; charset=utf-8", file.Type, "invalid file type")
+	require.Equal(t, map[string]string{"one": "foo:4000"}, file.Processors, "invalid processor results")
+	require.Equal(t, 1, f1.closed, "processor should be closed")
+	require.Equal(t, 1, f2.closed, "processor should be closed")
+}
+
+func TestPipelineNilChain(t *testing.T) {
+	var chain *Chain
+	file, err := process(t, chain, "file.txt", []byte{}, NewSizeLimit(10), NewMD5(), NewContentType())
+	require.NoError(t, err, "unable to process file")
+	require.Equal(t, int64(0), file.Size, "invalid file size")
+	require.Equal(t, "", file.Type, "invalid file type")
+	require.Nil(t, file.Processors, "invalid processor results")
+}
+
+func TestPipelineReject(t *testing.T) {
+	f1 := &testFactory{name: "one"}
+	f2 := &testFactory{name: "two", newErr: Reject("nope")}
+	chain := &Chain{factories: []Factory{f1, f2}}
+
+	_, err := process(t, chain, "file.txt", []byte("data"))
+	common.RequireError(t, err, "nope")
+	require.Equal(t, 1, f1.closed, "processor should be closed")
+
+	f2 = &testFactory{name: "two", writeErr: Reject("nope")}
+	chain = &Chain{factories: []Factory{f2}}
+	_, err = process(t, chain, "file.txt", []byte("data"))
+	common.RequireError(t, err, "nope")
+
+	f2 = &testFactory{name: "two", finishErr: Reject("nope")}
+	chain = &Chain{factories: []Factory{f2}}
+	_, err = process(t, chain, "file.txt", []byte("data"))
+	common.RequireError(t, err, "nope")
+	httpError, ok := err.(common.HTTPError)
+	require.True(t, ok, "invalid error type")
+	require.Equal(t, http.StatusBadRequest, httpError.StatusCode, "invalid status code")
+}
+
+func TestSizeLimit(t *testing.T) {
+	_, err := process(t, nil, "file.txt", make([]byte, 1000), NewSizeLimit(999))
+	common.RequireError(t, err, "file too big (limit is set to 999 B)")
+
+	file, err := process(t, nil, "file.txt", make([]byte, 1000), NewSizeLimit(1000))
+	require.NoError(t, err, "unable to process file")
+	require.Equal(t, int64(1000), file.Size, "invalid file size")
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
 )
 
 /*
@@ -27,6 +28,12 @@ import (
       1 Mark expired uploads and files as removed and ready to be cleaned
       2 Deletes all the removed files from the data backend
       3 Purge (real delete) removed upload and files from the metadata backend
+      4 Clean orphan files and tokens from the metadata backend
+      5 Delete the expired tokens
+      6 Delete the expired upload requests and the upload requests of purged uploads
+      7 Delete the audit events older than the audit log retention
+      8 Reconcile the metadata and data backends if OrphanReconciliation is set
+      9 Move the files matching the cold storage policy to the cold data backend if ColdDataBackend is set
 */
 
 // UploadsCleaningRoutine periodically remove expired uploads
@@ -64,8 +71,21 @@ func (ps *PlikServer) Clean() {
 	start := time.Now()
 	stats := &common.CleaningStats{}
 
+	// Expired uploads are notified to the webhooks, when cleaning from the CLI
+	// the events are only added to the outbox and sent by the running servers
+	ps.initializeWebhookDispatcher()
+	onExpired := func(upload *common.Upload) {
+		if ps.webhookDispatcher == nil {
+			return
+		}
+		err := ps.webhookDispatcher.Emit(common.NewWebhookEvent(common.EventUploadExpired, upload, nil))
+		if err != nil {
+			log.Warningf("Unable to emit %s webhook event : %s", common.EventUploadExpired, err)
+		}
+	}
+
 	// 1 - soft delete expired uploads
-	removed, err := ps.metadataBackend.RemoveExpiredUploads()
+	removed, err := ps.metadataBackend.RemoveExpiredUploads(onExpired)
 	if removed > 0 {
 		log.Infof("removed %d expired uploads", removed)
 	}
@@ -102,6 +122,49 @@ func (ps *PlikServer) Clean() {
 	stats.OrphanFilesCleaned = files
 	stats.OrphanTokensCleaned = tokens
 
+	// 5 - delete expired tokens
+	expiredTokens, err := ps.metadataBackend.DeleteExpiredTokens()
+	if expiredTokens > 0 {
+		log.Infof("deleted %d expired tokens", expiredTokens)
+	}
+	if err != nil {
+		log.Warning(err.Error())
+	}
+	stats.ExpiredTokensCleaned = expiredTokens
+
+	// 6 - delete expired upload requests
+	expiredRequests, err := ps.metadataBackend.DeleteExpiredUploadRequests()
+	if expiredRequests > 0 {
+		log.Infof("deleted %d expired upload requests", expiredRequests)
+	}
+	if err != nil {
+		log.Warning(err.Error())
+	}
+	stats.ExpiredUploadRequestsCleaned = expiredRequests
+
+	// 7 - delete expired audit events
+	if ps.config.AuditLogRetention > 0 {
+		deadline := time.Now().Add(-time.Duration(ps.config.AuditLogRetention) * time.Second)
+		events, err := ps.metadataBackend.DeleteAuditEventsBefore(deadline)
+		if events > 0 {
+			log.Infof("deleted %d expired audit events", events)
+		}
+		if err != nil {
+			log.Warning(err.Error())
+		}
+		stats.AuditEventsCleaned = events
+	}
+
+	// 8 - reconcile metadata and data backends
+	if ps.config.OrphanReconciliation != "" {
+		ps.reconcile(ps.config.OrphanReconciliation == common.ReconciliationDelete)
+	}
+
+	// 9 - move cold files to the cold data backend
+	if ps.config.ColdDataBackend != "" {
+		stats.DemotedFiles = ps.demoteFiles()
+	}
+
 	elapsed := time.Since(start)
 	ps.metrics.UpdateCleaningStatistics(stats, elapsed)
 }
@@ -119,6 +182,14 @@ func (ps *PlikServer) PurgeDeletedFiles() (deleted int, err error) {
 			return nil
 		}
 
+		// Remove resumable upload chunks if any
+		err = data.RemoveChunks(ps.dataBackend, file)
+		if err != nil {
+			errors = append(errors, err)
+			log.Warningf("unable to delete file %s/%s chunks : %s, will retry", file.UploadID, file.ID, err)
+			return nil
+		}
+
 		err = ps.metadataBackend.UpdateFileStatus(file, common.FileRemoved, common.FileDeleted)
 		if err != nil {
 			errors = append(errors, err)
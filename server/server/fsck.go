@@ -0,0 +1,182 @@
+package server
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+// File integrity check results
+const (
+	FsckOK         = "ok"
+	FsckMissing    = "missing"
+	FsckCorrupted  = "corrupted"
+	FsckBackfilled = "backfilled"
+	FsckError      = "error"
+)
+
+// FsckOptions select the files to check and whether to repair them
+type FsckOptions struct {
+	UploadID string
+	FileID   string
+	Repair   bool
+}
+
+// FsckStats summarize a file integrity check
+type FsckStats struct {
+	Checked    int
+	Missing    int
+	Corrupted  int
+	Backfilled int
+	Repaired   int
+	Errors     int
+}
+
+// FsckReport is called for each checked file with its result
+type FsckReport func(file *common.File, result string, err error)
+
+/*
+  Plik fsck design :
+    - Every uploaded file is re-read from the data backend and compared to the metadata
+    - When repair is enabled :
+      - Missing files have their status updated to deleted
+      - Corrupted files ( size or digest mismatch ) are removed and will be deleted by the next cleaning cycle
+      - Files uploaded before SHA-256 digests were computed get their digest backfilled if their md5sum is valid
+*/
+
+// Fsck verify the integrity of the uploaded files
+func (ps *PlikServer) Fsck(options *FsckOptions, report FsckReport) (stats *FsckStats, err error) {
+	stats = &FsckStats{}
+
+	check := func(file *common.File) error {
+		result, err := ps.fsckFile(file)
+		stats.Checked++
+
+		var repairErr error
+		switch result {
+		case FsckMissing:
+			stats.Missing++
+			if options.Repair {
+				repairErr = ps.removeMissingFile(file)
+				if repairErr == nil {
+					stats.Repaired++
+				}
+			}
+		case FsckCorrupted:
+			stats.Corrupted++
+			if options.Repair {
+				repairErr = ps.metadataBackend.RemoveFile(file)
+				if repairErr == nil {
+					stats.Repaired++
+				}
+			}
+		case FsckBackfilled:
+			if options.Repair {
+				repairErr = ps.metadataBackend.UpdateFile(file, common.FileUploaded)
+				if repairErr == nil {
+					stats.Backfilled++
+				}
+			} else {
+				result = FsckOK
+			}
+		}
+
+		if repairErr != nil {
+			result = FsckError
+			err = fmt.Errorf("unable to repair file : %s", repairErr)
+		}
+		if result == FsckError {
+			stats.Errors++
+		}
+
+		if report != nil {
+			report(file, result, err)
+		}
+
+		return nil
+	}
+
+	if options.FileID != "" {
+		file, err := ps.metadataBackend.GetFile(options.FileID)
+		if err != nil {
+			return stats, fmt.Errorf("unable to get file : %s", err)
+		}
+		if file == nil {
+			return stats, fmt.Errorf("file %s not found", options.FileID)
+		}
+		if file.Status == common.FileUploaded {
+			_ = check(file)
+		}
+	} else {
+		// The files are loaded by batches as they are updated while iterating
+		err = ps.metadataBackend.ForEachFileBatch(&common.File{UploadID: options.UploadID, Status: common.FileUploaded}, "", metadata.BatchSize, check)
+		if err != nil {
+			return stats, fmt.Errorf("unable to get files : %s", err)
+		}
+	}
+
+	return stats, nil
+}
+
+// fsckFile read the file from the data backend and compare its size and digests to the metadata
+func (ps *PlikServer) fsckFile(file *common.File) (result string, err error) {
+	reader, err := ps.dataBackend.GetFile(file)
+	if err != nil {
+		if errors.Is(err, data.ErrFileNotFound) {
+			return FsckMissing, err
+		}
+		return FsckError, fmt.Errorf("unable to get file from data backend : %s", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), reader)
+	if err != nil {
+		if errors.Is(err, data.ErrFileNotFound) {
+			return FsckMissing, err
+		}
+		return FsckCorrupted, fmt.Errorf("unable to read file : %s", err)
+	}
+
+	if size != file.Size {
+		return FsckCorrupted, fmt.Errorf("size mismatch : expected %d, got %d", file.Size, size)
+	}
+
+	if err = checkDigest("md5", file.Md5, md5Hash); err != nil {
+		return FsckCorrupted, err
+	}
+
+	if file.Sha256 == "" {
+		// The md5sum has been verified above so the SHA-256 digest can be trusted
+		if file.Md5 == "" {
+			return FsckOK, nil
+		}
+		file.Sha256 = fmt.Sprintf("%x", sha256Hash.Sum(nil))
+		return FsckBackfilled, nil
+	}
+
+	if err = checkDigest("sha256", file.Sha256, sha256Hash); err != nil {
+		return FsckCorrupted, err
+	}
+
+	return FsckOK, nil
+}
+
+func checkDigest(name string, expected string, h hash.Hash) error {
+	if expected == "" {
+		return nil
+	}
+	got := fmt.Sprintf("%x", h.Sum(nil))
+	if got != expected {
+		return fmt.Errorf("%s mismatch : expected %s, got %s", name, expected, got)
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package server
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/data/dedup"
+	"github.com/root-gg/plik/server/data/fallback"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+/*
+  Plik data migration design :
+    - The new data backend is configured as DataBackend and the previous one as FallbackDataBackend
+      - New files are added to the new data backend
+      - Files not found in the new data backend are read from the previous one
+    - The uploaded files are migrated in the order of their IDs :
+      - The content is copied to a staging file in the new data backend, its md5sum ( or the SHA-256
+        of deduplication blobs ) is checked while copying and again by reading the staging file
+      - The staging file is renamed and the backend details of the file ( or of the blob ) are updated
+      - Files uploaded since the fallback data backend was configured are already in the new data backend
+    - The ID of the last file migrated without error is saved in the metadata backend as a checkpoint,
+      an interrupted migration resumes from there
+    - Files are not removed from the previous data backend, it can be decommissioned once the migration is complete
+*/
+
+// Data migration results
+const (
+	MigrateOK      = "migrated"
+	MigrateSkipped = "skipped"
+	MigrateError   = "error"
+)
+
+// MigrateOptions configure the data migration
+type MigrateOptions struct {
+	Restart bool // Ignore the checkpoint of a previous migration
+}
+
+// MigrateStats summarize a data migration
+type MigrateStats struct {
+	Files    int
+	Migrated int
+	Skipped  int
+	Size     int64
+	Errors   int
+}
+
+// MigrateReport is called for each file with its result
+type MigrateReport func(file *common.File, result string, err error)
+
+// MigrateData copy the uploaded files from the fallback data backend to the data backend
+func (ps *PlikServer) MigrateData(options *MigrateOptions, report MigrateReport) (stats *MigrateStats, err error) {
+	stats = &MigrateStats{}
+
+	if report == nil {
+		report = func(file *common.File, result string, err error) {}
+	}
+
+	// Blobs are stored in the underlying data backend
+	backend := ps.dataBackend
+	if dedupBackend, ok := backend.(*dedup.Backend); ok {
+		backend = dedupBackend.GetBackend()
+	}
+
+	fallbackBackend, ok := backend.(*fallback.Backend)
+	if !ok {
+		return stats, fmt.Errorf("no fallback data backend to migrate the files from")
+	}
+
+	checkpoint, err := ps.metadataBackend.GetSetting(common.DataMigrationCheckpointSettingKey)
+	if err != nil {
+		return stats, fmt.Errorf("unable to get migration checkpoint : %s", err)
+	}
+
+	var resumeAfter string
+	if checkpoint != nil && !options.Restart {
+		resumeAfter = checkpoint.Value
+	}
+
+	// The files are loaded by batches as the checkpoint is updated while iterating
+	var stop error
+	blobs := make(map[string]bool)
+	failed := false
+	err = ps.metadataBackend.ForEachFileBatch(&common.File{Status: common.FileUploaded}, "", metadata.BatchSize, func(file *common.File) error {
+		select {
+		case <-ps.close:
+			stop = fmt.Errorf("data migration interrupted")
+			return stop
+		default:
+		}
+
+		stats.Files++
+		if file.ID <= resumeAfter {
+			stats.Skipped++
+			return nil
+		}
+
+		result, err := ps.migrateFile(fallbackBackend, file, blobs)
+		switch result {
+		case MigrateOK:
+			stats.Migrated++
+			stats.Size += file.Size
+		case MigrateSkipped:
+			stats.Skipped++
+		case MigrateError:
+			stats.Errors++
+			failed = true
+		}
+
+		report(file, result, err)
+
+		// Files after the first error will be migrated again by the next run
+		if !failed {
+			checkpoint, err = ps.saveMigrationCheckpoint(checkpoint, file.ID)
+			if err != nil {
+				stop = fmt.Errorf("unable to save migration checkpoint : %s", err)
+				return stop
+			}
+		}
+
+		return nil
+	})
+	if stop != nil {
+		return stats, stop
+	} else if err != nil {
+		return stats, fmt.Errorf("unable to get files : %s", err)
+	}
+
+	return stats, nil
+}
+
+// migrateFile migrate a file or the deduplication blob it references
+func (ps *PlikServer) migrateFile(backend *fallback.Backend, file *common.File, blobs map[string]bool) (result string, err error) {
+	blob, err := ps.metadataBackend.GetFileBlob(file.ID)
+	if err != nil {
+		return MigrateError, fmt.Errorf("unable to get file blob : %s", err)
+	}
+
+	if blob == nil {
+		result, err = migrateObject(backend, file, "md5", md5.New, file.Md5)
+		if result != MigrateOK {
+			return result, err
+		}
+
+		err = ps.metadataBackend.UpdateFileBackendDetails(file)
+		if err != nil {
+			return MigrateError, fmt.Errorf("unable to update file backend details : %s", err)
+		}
+
+		return MigrateOK, nil
+	}
+
+	if blobs[blob.ID] {
+		return MigrateSkipped, nil
+	}
+
+	// The blob ID is the SHA-256 of its content
+	blobFile := dedup.GetBlobFile(blob)
+	result, err = migrateObject(backend, blobFile, "sha256", sha256.New, blob.ID)
+	if result == MigrateError {
+		return result, err
+	}
+	blobs[blob.ID] = true
+	if result != MigrateOK {
+		return result, nil
+	}
+
+	blob.BackendDetails = blobFile.BackendDetails
+	err = ps.metadataBackend.UpdateBlobBackendDetails(blob)
+	if err != nil {
+		return MigrateError, fmt.Errorf("unable to update blob backend details : %s", err)
+	}
+
+	return MigrateOK, nil
+}
+
+// migrateObject copy a file from the fallback data backend to the data backend and verify its digest
+// file.BackendDetails is updated with the backend details of the copy
+func migrateObject(backend *fallback.Backend, file *common.File, name string, newHash func() hash.Hash, expected string) (result string, err error) {
+	primary := backend.GetBackend()
+
+	reader, err := openFile(backend.GetFallback(), file)
+	if err != nil {
+		if errors.Is(err, data.ErrFileNotFound) {
+			if r, e := openFile(primary, file); e == nil {
+				_ = r.Close()
+				return MigrateSkipped, nil
+			}
+		}
+		return MigrateError, fmt.Errorf("unable to get file from fallback data backend : %s", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	staging := &common.File{ID: file.ID + ".staging", UploadID: file.UploadID}
+	err = primary.RemoveFile(staging)
+	if err != nil {
+		return MigrateError, fmt.Errorf("unable to remove staging file : %s", err)
+	}
+
+	h := newHash()
+	err = primary.AddFile(staging, io.TeeReader(reader, h))
+	if err != nil {
+		_ = primary.RemoveFile(staging)
+		return MigrateError, fmt.Errorf("unable to copy file : %s", err)
+	}
+
+	err = checkDigest(name, expected, h)
+	if err != nil {
+		_ = primary.RemoveFile(staging)
+		return MigrateError, fmt.Errorf("invalid file in fallback data backend : %s", err)
+	}
+	if expected == "" {
+		expected = fmt.Sprintf("%x", h.Sum(nil))
+	}
+
+	err = verifyObject(primary, staging, name, newHash(), expected)
+	if err != nil {
+		_ = primary.RemoveFile(staging)
+		return MigrateError, err
+	}
+
+	target := &common.File{ID: file.ID, UploadID: file.UploadID}
+	err = data.MoveFile(primary, staging, target)
+	if err != nil {
+		_ = primary.RemoveFile(staging)
+		return MigrateError, fmt.Errorf("unable to move staging file : %s", err)
+	}
+
+	file.BackendDetails = target.BackendDetails
+	return MigrateOK, nil
+}
+
+// verifyObject read a file again to verify its digest
+func verifyObject(backend data.Backend, file *common.File, name string, h hash.Hash, expected string) (err error) {
+	reader, err := backend.GetFile(file)
+	if err != nil {
+		return fmt.Errorf("unable to get file copy : %s", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	_, err = io.Copy(h, reader)
+	if err != nil {
+		return fmt.Errorf("unable to read file copy : %s", err)
+	}
+
+	err = checkDigest(name, expected, h)
+	if err != nil {
+		return fmt.Errorf("invalid file copy : %s", err)
+	}
+
+	return nil
+}
+
+// openFile read the first byte of the file as some data backends only report missing files on the first read
+func openFile(backend data.Backend, file *common.File) (reader io.ReadCloser, err error) {
+	reader, err = backend.GetFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 1)
+	n, err := reader.Read(buf)
+	if err != nil && err != io.EOF {
+		_ = reader.Close()
+		return nil, err
+	}
+
+	return &readCloser{Reader: io.MultiReader(bytes.NewReader(buf[:n]), reader), Closer: reader}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// saveMigrationCheckpoint save the ID of the last migrated file
+func (ps *PlikServer) saveMigrationCheckpoint(checkpoint *common.Setting, fileID string) (*common.Setting, error) {
+	if checkpoint == nil {
+		checkpoint = &common.Setting{Key: common.DataMigrationCheckpointSettingKey, Value: fileID}
+		return checkpoint, ps.metadataBackend.CreateSetting(checkpoint)
+	}
+
+	err := ps.metadataBackend.UpdateSetting(checkpoint.Key, checkpoint.Value, fileID)
+	if err != nil {
+		return checkpoint, err
+	}
+
+	checkpoint.Value = fileID
+	return checkpoint, nil
+}
+
+// dataMigrationRoutine migrate the files from the fallback data backend in the background
+// and try again every hour until every file has been migrated
+func (ps *PlikServer) dataMigrationRoutine() {
+	log := ps.config.NewLogger()
+
+	report := func(file *common.File, result string, err error) {
+		if err != nil {
+			log.Warningf("unable to migrate file %s/%s : %s", file.UploadID, file.ID, err)
+		}
+	}
+
+	for {
+		log.Infof("Migrating files from the fallback data backend...")
+		stats, err := ps.MigrateData(&MigrateOptions{}, report)
+		if err != nil {
+			log.Warningf("unable to migrate files from the fallback data backend : %s", err)
+		} else {
+			log.Infof("%d files migrated ( %d bytes ), %d skipped, %d errors", stats.Migrated, stats.Size, stats.Skipped, stats.Errors)
+			if stats.Errors == 0 {
+				return
+			}
+		}
+
+		select {
+		case <-time.After(time.Hour):
+		case <-ps.close:
+			return
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/data/dedup"
+)
+
+/*
+  Plik reconciliation design :
+    - The metadata is loaded first, then the data backend is listed
+    - Orphans are files stored in the data backend without matching metadata :
+      - Chunks ( <file id>.<n> ) and staging files ( <file id>.staging ) belong to their file
+      - Blobs ( 64 hex chars, followed by the ID of the file that stored them ) belong to the deduplication blobs,
+        only the content named after the blob data ID is in use
+      - Files whose metadata is deleted are orphans, removed files are left to the cleaning routine
+      - Objects that do not look like a Plik file are ignored
+      - The metadata of each orphan is checked again before it is reported, as the metadata of a file
+        ( or of the file storing a blob ) is always created before its content the files uploaded meanwhile are not reported
+    - Missing files are uploaded files ( or blobs ) whose content is not in the data backend,
+      the content is read again before the file is reported
+    - In delete mode :
+      - Orphans are deleted from the data backend
+      - Missing files have their status updated to deleted
+*/
+
+// Reconciliation results
+const (
+	ReconcileOrphan  = "orphan"
+	ReconcileMissing = "missing"
+)
+
+// ReconcileOptions configure the reconciliation between the metadata and the data backends
+type ReconcileOptions struct {
+	Delete bool
+}
+
+// ReconcileReport is called for each orphan or missing file
+// err is set if the file could not be checked or deleted
+type ReconcileReport func(file *common.File, result string, err error)
+
+var objectIDRegexp = regexp.MustCompile(`^([a-zA-Z0-9]+)(\.(staging|[0-9]+(\.[a-zA-Z0-9]+)?))?$`)
+var blobIDRegexp = regexp.MustCompile(`^([a-f0-9]{64})(\.([a-zA-Z0-9]+))?$`)
+
+type reconciliation struct {
+	files      map[string]string // file ID -> status
+	blobs      map[string]string // blob ID -> blob data ID
+	references map[string]string // file ID -> blob ID
+	seen       map[string]bool   // IDs of the files and blobs found in the data backend
+}
+
+// Reconcile find orphan files in the data backend and files whose content is missing from the data backend
+func (ps *PlikServer) Reconcile(options *ReconcileOptions, report ReconcileReport) (stats *common.ReconciliationStats, err error) {
+	start := time.Now()
+	stats = &common.ReconciliationStats{}
+
+	if report == nil {
+		report = func(file *common.File, result string, err error) {}
+	}
+
+	// Blobs are stored in the underlying data backend
+	backend := ps.dataBackend
+	if dedupBackend, ok := backend.(*dedup.Backend); ok {
+		backend = dedupBackend.GetBackend()
+	}
+
+	listBackend, ok := backend.(data.ListBackend)
+	if !ok {
+		return stats, fmt.Errorf("data backend does not support listing files")
+	}
+
+	r, err := ps.loadReconciliation()
+	if err != nil {
+		return stats, err
+	}
+
+	// Find orphans
+	var orphans []*common.File
+	err = listBackend.ForEachFile(func(file *common.File) error {
+		stats.Files++
+
+		id, blob, ok := parseObjectID(file)
+		if !ok {
+			return nil
+		}
+
+		if blob {
+			if r.blobs[id] == file.ID {
+				r.seen[id] = true
+				return nil
+			}
+		} else {
+			status, ok := r.files[id]
+			if ok && status != common.FileDeleted {
+				if id == file.ID {
+					r.seen[id] = true
+				}
+				return nil
+			}
+		}
+
+		orphans = append(orphans, file)
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("unable to list data backend files : %s", err)
+	}
+
+	for _, file := range orphans {
+		orphan, err := ps.isOrphan(file)
+		if err != nil {
+			stats.Errors++
+			report(file, ReconcileOrphan, fmt.Errorf("unable to check metadata : %s", err))
+			continue
+		}
+		if !orphan {
+			continue
+		}
+
+		stats.OrphanFiles++
+		stats.OrphanSize += file.Size
+
+		if options.Delete {
+			err = backend.RemoveFile(file)
+			if err != nil {
+				stats.Errors++
+				err = fmt.Errorf("unable to delete file : %s", err)
+			} else {
+				stats.DeletedFiles++
+			}
+		}
+
+		report(file, ReconcileOrphan, err)
+	}
+
+	// Find missing files
+	var missing []*common.File
+	err = ps.metadataBackend.ForEachFile(func(file *common.File) error {
+		if file.Status != common.FileUploaded {
+			return nil
+		}
+
+		id := file.ID
+		if blobID, ok := r.references[file.ID]; ok {
+			id = blobID
+		}
+		if !r.seen[id] {
+			missing = append(missing, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("unable to get files : %s", err)
+	}
+
+	for _, file := range missing {
+		ok, err := ps.isMissing(backend, file)
+		if err != nil {
+			stats.Errors++
+			report(file, ReconcileMissing, fmt.Errorf("unable to check file : %s", err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		stats.MissingFiles++
+
+		if options.Delete {
+			err = ps.removeMissingFile(file)
+			if err != nil {
+				stats.Errors++
+				err = fmt.Errorf("unable to update file : %s", err)
+			} else {
+				stats.RemovedMissingFiles++
+			}
+		}
+
+		report(file, ReconcileMissing, err)
+	}
+
+	if ps.metrics != nil {
+		ps.metrics.UpdateReconciliationStatistics(stats, time.Since(start))
+	}
+
+	return stats, nil
+}
+
+// loadReconciliation load the IDs of all the files and blobs from the metadata backend
+func (ps *PlikServer) loadReconciliation() (r *reconciliation, err error) {
+	r = &reconciliation{
+		files:      make(map[string]string),
+		blobs:      make(map[string]string),
+		references: make(map[string]string),
+		seen:       make(map[string]bool),
+	}
+
+	err = ps.metadataBackend.ForEachFile(func(file *common.File) error {
+		r.files[file.ID] = file.Status
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get files : %s", err)
+	}
+
+	err = ps.metadataBackend.ForEachBlob(func(blob *common.Blob) error {
+		r.blobs[blob.ID] = dedup.GetBlobFile(blob).ID
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get blobs : %s", err)
+	}
+
+	err = ps.metadataBackend.ForEachBlobReference(func(reference *common.BlobReference) error {
+		r.references[reference.FileID] = reference.BlobID
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get blob references : %s", err)
+	}
+
+	return r, nil
+}
+
+// parseObjectID return the ID of the file or blob owning a data backend file
+func parseObjectID(file *common.File) (id string, blob bool, ok bool) {
+	if match := blobIDRegexp.FindStringSubmatch(file.ID); match != nil && (file.UploadID == dedup.BlobUploadID || file.UploadID == "") {
+		return match[1], true, true
+	}
+	if file.UploadID == dedup.BlobUploadID {
+		return "", false, false
+	}
+
+	match := objectIDRegexp.FindStringSubmatch(file.ID)
+	if match == nil {
+		return "", false, false
+	}
+
+	return match[1], false, true
+}
+
+// isOrphan check the metadata again as the file or blob might have been created after it was loaded
+func (ps *PlikServer) isOrphan(file *common.File) (orphan bool, err error) {
+	id, isBlob, _ := parseObjectID(file)
+	if isBlob {
+		blob, err := ps.metadataBackend.GetBlob(id)
+		if err != nil {
+			return false, err
+		}
+		if blob != nil && dedup.GetBlobFile(blob).ID == file.ID {
+			return false, nil
+		}
+
+		// The content of a new blob is stored before the blob is created,
+		// it is not an orphan while the file that stored it is being uploaded
+		match := blobIDRegexp.FindStringSubmatch(file.ID)
+		if match[3] == "" {
+			return true, nil
+		}
+		f, err := ps.metadataBackend.GetFile(match[3])
+		if err != nil {
+			return false, err
+		}
+		return f == nil || f.Status != common.FileUploading, nil
+	}
+
+	f, err := ps.metadataBackend.GetFile(id)
+	if err != nil {
+		return false, err
+	}
+	return f == nil || f.Status == common.FileDeleted, nil
+}
+
+// isMissing check the file metadata again and try to read the file content
+func (ps *PlikServer) isMissing(backend data.Backend, file *common.File) (missing bool, err error) {
+	f, err := ps.metadataBackend.GetFile(file.ID)
+	if err != nil {
+		return false, err
+	}
+	if f == nil || f.Status != common.FileUploaded {
+		return false, nil
+	}
+
+	blob, err := ps.metadataBackend.GetFileBlob(file.ID)
+	if err != nil {
+		return false, err
+	}
+	if blob != nil {
+		f = dedup.GetBlobFile(blob)
+	}
+
+	// Some data backends only report missing files on the first read
+	reader, err := backend.GetFile(f)
+	if err == nil {
+		_, err = reader.Read(make([]byte, 1))
+		_ = reader.Close()
+	}
+	if err != nil {
+		if errors.Is(err, data.ErrFileNotFound) {
+			return true, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return false, nil
+}
+
+// removeMissingFile mark a file whose content is missing as deleted
+func (ps *PlikServer) removeMissingFile(file *common.File) (err error) {
+	_, _, err = ps.metadataBackend.RemoveBlobReference(file.ID)
+	if err != nil {
+		return err
+	}
+	return ps.metadataBackend.UpdateFileStatus(file, common.FileUploaded, common.FileDeleted)
+}
+
+// reconcile run the reconciliation from the cleaning routine and log the results
+func (ps *PlikServer) reconcile(delete bool) {
+	log := ps.config.NewLogger()
+
+	report := func(file *common.File, result string, err error) {
+		if err != nil {
+			log.Warningf("%s file %s/%s : %s", result, file.UploadID, file.ID, err)
+		} else {
+			log.Infof("%s file %s/%s", result, file.UploadID, file.ID)
+		}
+	}
+
+	stats, err := ps.Reconcile(&ReconcileOptions{Delete: delete}, report)
+	if err != nil {
+		log.Warningf("unable to reconcile metadata and data backends : %s", err)
+		return
+	}
+	if stats.OrphanFiles > 0 || stats.MissingFiles > 0 {
+		log.Infof("found %d orphan files ( %d deleted ) and %d missing files", stats.OrphanFiles, stats.DeletedFiles, stats.MissingFiles)
+	}
+}
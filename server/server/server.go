@@ -16,18 +16,25 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/root-gg/logger"
 
+	"github.com/root-gg/plik/server/clamav"
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/data/dedup"
+	"github.com/root-gg/plik/server/data/fallback"
 	"github.com/root-gg/plik/server/data/file"
 	"github.com/root-gg/plik/server/data/gcs"
 	"github.com/root-gg/plik/server/data/s3"
 	"github.com/root-gg/plik/server/data/stream"
 	"github.com/root-gg/plik/server/data/swift"
 	data_test "github.com/root-gg/plik/server/data/testing"
+	"github.com/root-gg/plik/server/data/tiered"
 	"github.com/root-gg/plik/server/handlers"
 	"github.com/root-gg/plik/server/metadata"
 	"github.com/root-gg/plik/server/middleware"
+	"github.com/root-gg/plik/server/notification"
+	"github.com/root-gg/plik/server/processor"
+	"github.com/root-gg/plik/server/webhook"
 )
 
 // PlikServer is a Plik Server instance
@@ -40,6 +47,11 @@ type PlikServer struct {
 
 	authenticator *common.SessionAuthenticator
 
+	webhookDispatcher *webhook.Dispatcher
+	notifier          *notification.Notifier
+	scanner           *clamav.Scanner
+	processors        *processor.Chain
+
 	httpServer        *http.Server
 	metricsHTTPServer *http.Server
 
@@ -191,10 +203,37 @@ func (ps *PlikServer) start() (err error) {
 		return fmt.Errorf("unable to initialize session authenticator : %s", err)
 	}
 
+	ps.initializeWebhookDispatcher()
+	if ps.webhookDispatcher != nil {
+		go ps.webhookDispatcher.Run(ps.close)
+	}
+
+	err = ps.initializeNotifier()
+	if err != nil {
+		return fmt.Errorf("unable to initialize email notifier : %s", err)
+	}
+	if ps.notifier != nil {
+		go ps.notifier.Run(ps.close)
+	}
+
+	err = ps.initializeScanner()
+	if err != nil {
+		return fmt.Errorf("unable to initialize antivirus scanner : %s", err)
+	}
+
+	err = ps.initializeProcessors()
+	if err != nil {
+		return fmt.Errorf("unable to initialize content inspection processors : %s", err)
+	}
+
 	if ps.config.IsAutoClean() {
 		go ps.uploadsCleaningRoutine()
 	}
 
+	if ps.config.DataMigration {
+		go ps.dataMigrationRoutine()
+	}
+
 	go ps.refreshServerStatsRoutine()
 
 	handler := ps.getHTTPHandler()
@@ -313,8 +352,8 @@ func (ps *PlikServer) getHTTPHandler() (handler http.Handler) {
 	// A Chain that only allows authenticated users
 	authenticatedChain := authChain.Append(middleware.AuthenticatedOnly)
 
-	// A chain that only allows authenticated admin users
-	adminChain := stdChain.Append(middleware.Authenticate(false), middleware.AdminOnly)
+	// A chain that only allows authenticated admin users or tokens explicitly granted the admin scope
+	adminChain := stdChain.Append(middleware.Authenticate(true), middleware.AdminOnly)
 
 	// Chains that redirect on error for webapp
 	stdChainWithRedirect := context.NewChain(middleware.RedirectOnFailure).AppendChain(stdChain)
@@ -323,51 +362,77 @@ func (ps *PlikServer) getHTTPHandler() (handler http.Handler) {
 	// Chain that fetches the requested upload and file metadata
 	getFileChain := context.NewChain(middleware.Upload, middleware.File)
 	userChain := authenticatedChain.Append(middleware.User)
+	groupChain := authenticatedChain.Append(middleware.Group)
+	adminGroupChain := adminChain.Append(middleware.Group)
 
 	// HTTP Api routes configuration
 	router := mux.NewRouter()
-	router.Handle("/", tokenChain.Append(middleware.CreateUpload).Then(handlers.AddFile)).Methods("POST")
+	router.Handle("/", tokenChain.Append(middleware.Audit(common.AuditUpload), middleware.TokenScope(common.TokenScopeUpload), middleware.CreateUpload).Then(handlers.AddFile)).Methods("POST")
 
 	router.Handle("/config", stdChain.Then(handlers.GetConfiguration)).Methods("GET")
 	router.Handle("/version", stdChain.Then(handlers.GetVersion)).Methods("GET")
 	router.Handle("/qrcode", stdChain.Then(handlers.GetQrCode)).Methods("GET")
 	router.Handle("/health", emptyChain.Then(handlers.Health)).Methods("GET")
 
-	router.Handle("/upload", tokenChain.Then(handlers.CreateUpload)).Methods("POST")
-	router.Handle("/upload/{uploadID}", tokenChain.Append(middleware.Upload).Then(handlers.GetUpload)).Methods("GET")
-	router.Handle("/upload/{uploadID}", tokenChain.Append(middleware.Upload).Then(handlers.RemoveUpload)).Methods("DELETE")
-	router.Handle("/file/{uploadID}", tokenChain.Append(middleware.Upload).Then(handlers.AddFile)).Methods("POST")
-	router.Handle("/file/{uploadID}/{fileID}/{filename}", tokenChain.AppendChain(getFileChain).Then(handlers.AddFile)).Methods("POST")
-	router.Handle("/file/{uploadID}/{fileID}/{filename}", tokenChain.AppendChain(getFileChain).Then(handlers.RemoveFile)).Methods("DELETE")
-	router.Handle("/file/{uploadID}/{fileID}/{filename}", tokenChainWithRedirect.AppendChain(getFileChain).Then(handlers.GetFile)).Methods("HEAD", "GET")
-	router.Handle("/stream/{uploadID}/{fileID}/{filename}", tokenChain.AppendChain(getFileChain).Then(handlers.AddFile)).Methods("POST")
-	router.Handle("/stream/{uploadID}/{fileID}/{filename}", tokenChainWithRedirect.AppendChain(getFileChain).Then(handlers.GetFile)).Methods("HEAD", "GET")
-	router.Handle("/archive/{uploadID}/{filename}", tokenChainWithRedirect.Append(middleware.Upload).Then(handlers.GetArchive)).Methods("HEAD", "GET")
+	router.Handle("/upload", tokenChain.Append(middleware.Audit(common.AuditUpload), middleware.TokenScope(common.TokenScopeUpload)).Then(handlers.CreateUpload)).Methods("POST")
+	router.Handle("/upload/{uploadID}", tokenChain.Append(middleware.TokenScope(common.TokenScopeRead), middleware.Upload).Then(handlers.GetUpload)).Methods("GET")
+	router.Handle("/upload/{uploadID}", tokenChain.Append(middleware.Audit(common.AuditDelete), middleware.TokenScope(common.TokenScopeDelete), middleware.Upload).Then(handlers.RemoveUpload)).Methods("DELETE")
+	router.Handle("/upload/{uploadID}/notify", tokenChain.Append(middleware.AuthenticatedOnly, middleware.TokenScope(common.TokenScopeUpload), middleware.Upload).Then(handlers.NotifyUpload)).Methods("POST")
+	router.Handle("/file/{uploadID}", tokenChain.Append(middleware.Audit(common.AuditUpload), middleware.TokenScope(common.TokenScopeUpload), middleware.Upload).Then(handlers.AddFile)).Methods("POST")
+	router.Handle("/file/{uploadID}/{fileID}/{filename}", tokenChain.Append(middleware.Audit(common.AuditUpload), middleware.TokenScope(common.TokenScopeUpload)).AppendChain(getFileChain).Then(handlers.AddFile)).Methods("POST")
+	router.Handle("/file/{uploadID}/{fileID}/{filename}", tokenChain.Append(middleware.Audit(common.AuditDelete), middleware.TokenScope(common.TokenScopeDelete)).AppendChain(getFileChain).Then(handlers.RemoveFile)).Methods("DELETE")
+	router.Handle("/file/{uploadID}/{fileID}/{filename}", tokenChainWithRedirect.Append(middleware.Audit(common.AuditDownload), middleware.TokenScope(common.TokenScopeRead)).AppendChain(getFileChain).Then(handlers.GetFile)).Methods("HEAD", "GET")
+	router.Handle("/chunk/{uploadID}/{fileID}/{filename}", tokenChain.Append(middleware.TokenScope(common.TokenScopeUpload)).AppendChain(getFileChain).Then(handlers.GetFileUploadOffset)).Methods("HEAD")
+	router.Handle("/chunk/{uploadID}/{fileID}/{filename}", tokenChain.Append(middleware.TokenScope(common.TokenScopeUpload)).AppendChain(getFileChain).Then(handlers.AddFileChunk)).Methods("PATCH")
+	router.Handle("/chunk/{uploadID}/{fileID}/{filename}", tokenChain.Append(middleware.Audit(common.AuditUpload), middleware.TokenScope(common.TokenScopeUpload)).AppendChain(getFileChain).Then(handlers.CommitFileChunks)).Methods("POST")
+	router.Handle("/stream/{uploadID}/{fileID}/{filename}", tokenChain.Append(middleware.Audit(common.AuditUpload), middleware.TokenScope(common.TokenScopeUpload)).AppendChain(getFileChain).Then(handlers.AddFile)).Methods("POST")
+	router.Handle("/stream/{uploadID}/{fileID}/{filename}", tokenChainWithRedirect.Append(middleware.Audit(common.AuditDownload), middleware.TokenScope(common.TokenScopeRead)).AppendChain(getFileChain).Then(handlers.GetFile)).Methods("HEAD", "GET")
+	router.Handle("/archive/{uploadID}/{filename}", tokenChainWithRedirect.Append(middleware.Audit(common.AuditDownload), middleware.TokenScope(common.TokenScopeRead), middleware.Upload).Then(handlers.GetArchive)).Methods("HEAD", "GET")
+
+	router.Handle("/request/{requestID}", stdChain.Append(middleware.UploadRequest).Then(handlers.GetUploadRequest)).Methods("GET")
+	router.Handle("/request/{requestID}/file", stdChain.Append(middleware.Audit(common.AuditUpload), middleware.UploadRequest).Then(handlers.AddFile)).Methods("POST")
 
 	router.Handle("/auth/google/login", authChain.Then(handlers.GoogleLogin)).Methods("GET")
-	router.Handle("/auth/google/callback", stdChainWithRedirect.Then(handlers.GoogleCallback)).Methods("GET")
+	router.Handle("/auth/google/callback", stdChainWithRedirect.Append(middleware.Audit(common.AuditLogin)).Then(handlers.GoogleCallback)).Methods("GET")
 	router.Handle("/auth/ovh/login", authChain.Then(handlers.OvhLogin)).Methods("GET")
-	router.Handle("/auth/ovh/callback", stdChainWithRedirect.Then(handlers.OvhCallback)).Methods("GET")
-	router.Handle("/auth/local/login", authChain.Then(handlers.LocalLogin)).Methods("POST")
+	router.Handle("/auth/ovh/callback", stdChainWithRedirect.Append(middleware.Audit(common.AuditLogin)).Then(handlers.OvhCallback)).Methods("GET")
+	router.Handle("/auth/oidc/login", authChain.Then(handlers.OIDCLogin)).Methods("GET")
+	router.Handle("/auth/oidc/callback", stdChainWithRedirect.Append(middleware.Audit(common.AuditLogin)).Then(handlers.OIDCCallback)).Methods("GET")
+	router.Handle("/auth/local/login", authChain.Append(middleware.Audit(common.AuditLogin)).Then(handlers.LocalLogin)).Methods("POST")
 	router.Handle("/auth/logout", stdChain.Then(handlers.Logout)).Methods("GET")
 
-	router.Handle("/me", authenticatedChain.Then(handlers.UserInfo)).Methods("GET")
-	router.Handle("/me", authenticatedChain.Then(handlers.DeleteAccount)).Methods("DELETE")
-	router.Handle("/me/token", authenticatedChain.Append(middleware.Paginate).Then(handlers.GetUserTokens)).Methods("GET")
-	router.Handle("/me/token", authenticatedChain.Then(handlers.CreateToken)).Methods("POST")
-	router.Handle("/me/token/{token}", authenticatedChain.Then(handlers.RevokeToken)).Methods("DELETE")
-	router.Handle("/me/uploads", authenticatedChain.Append(middleware.Paginate).Then(handlers.GetUserUploads)).Methods("GET")
-	router.Handle("/me/uploads", authenticatedChain.Then(handlers.RemoveUserUploads)).Methods("DELETE")
-	router.Handle("/me/stats", authenticatedChain.Then(handlers.GetUserStatistics)).Methods("GET")
+	router.Handle("/me", tokenChain.Append(middleware.AuthenticatedOnly, middleware.TokenScope(common.TokenScopeRead)).Then(handlers.UserInfo)).Methods("GET")
+	router.Handle("/me", authenticatedChain.Append(middleware.Audit(common.AuditDelete)).Then(handlers.DeleteAccount)).Methods("DELETE")
+	router.Handle("/me/token", tokenChain.Append(middleware.AuthenticatedOnly, middleware.TokenScope(common.TokenScopeToken), middleware.Paginate).Then(handlers.GetUserTokens)).Methods("GET")
+	router.Handle("/me/token", tokenChain.Append(middleware.AuthenticatedOnly, middleware.TokenScope(common.TokenScopeToken), middleware.Audit(common.AuditTokenCreate)).Then(handlers.CreateToken)).Methods("POST")
+	router.Handle("/me/token/{token}", tokenChain.Append(middleware.AuthenticatedOnly, middleware.TokenScope(common.TokenScopeToken)).Then(handlers.RevokeToken)).Methods("DELETE")
+	router.Handle("/me/uploads", tokenChain.Append(middleware.AuthenticatedOnly, middleware.TokenScope(common.TokenScopeRead), middleware.Paginate).Then(handlers.GetUserUploads)).Methods("GET")
+	router.Handle("/me/uploads", authenticatedChain.Append(middleware.Audit(common.AuditDelete)).Then(handlers.RemoveUserUploads)).Methods("DELETE")
+	router.Handle("/me/request", tokenChain.Append(middleware.AuthenticatedOnly, middleware.TokenScope(common.TokenScopeRead), middleware.Paginate).Then(handlers.GetUserUploadRequests)).Methods("GET")
+	router.Handle("/me/request", tokenChain.Append(middleware.AuthenticatedOnly, middleware.TokenScope(common.TokenScopeUpload)).Then(handlers.CreateUploadRequest)).Methods("POST")
+	router.Handle("/me/request/{requestID}", tokenChain.Append(middleware.AuthenticatedOnly, middleware.TokenScope(common.TokenScopeDelete)).Then(handlers.DeleteUploadRequest)).Methods("DELETE")
+	router.Handle("/me/stats", tokenChain.Append(middleware.AuthenticatedOnly, middleware.TokenScope(common.TokenScopeRead)).Then(handlers.GetUserStatistics)).Methods("GET")
+	router.Handle("/me/groups", authenticatedChain.Then(handlers.GetUserGroups)).Methods("GET")
 
 	router.Handle("/user/{userID}", userChain.Then(handlers.UserInfo)).Methods("GET")
 	router.Handle("/user/{userID}", userChain.Then(handlers.UpdateUser)).Methods("POST")
-	router.Handle("/user/{userID}", userChain.Then(handlers.DeleteAccount)).Methods("DELETE")
+	router.Handle("/user/{userID}", userChain.Append(middleware.Audit(common.AuditDelete)).Then(handlers.DeleteAccount)).Methods("DELETE")
+
+	router.Handle("/group/{groupID}", groupChain.Then(handlers.GetGroup)).Methods("GET")
+	router.Handle("/group/{groupID}", adminGroupChain.Then(handlers.UpdateGroup)).Methods("POST")
+	router.Handle("/group/{groupID}", adminGroupChain.Then(handlers.DeleteGroup)).Methods("DELETE")
+	router.Handle("/group/{groupID}/member/{userID}", adminGroupChain.Then(handlers.AddGroupMember)).Methods("POST")
+	router.Handle("/group/{groupID}/member/{userID}", adminGroupChain.Then(handlers.RemoveGroupMember)).Methods("DELETE")
+	router.Handle("/group/{groupID}/uploads", groupChain.Append(middleware.Paginate).Then(handlers.GetGroupUploads)).Methods("GET")
+	router.Handle("/group/{groupID}/stats", groupChain.Then(handlers.GetGroupStatistics)).Methods("GET")
 
 	router.Handle("/user", adminChain.Then(handlers.CreateUser)).Methods("POST")
+	router.Handle("/group", adminChain.Then(handlers.CreateGroup)).Methods("POST")
 	router.Handle("/stats", adminChain.Then(handlers.GetServerStatistics)).Methods("GET")
 	router.Handle("/users", adminChain.Append(middleware.Paginate).Then(handlers.GetUsers)).Methods("GET")
+	router.Handle("/groups", adminChain.Append(middleware.Paginate).Then(handlers.GetGroups)).Methods("GET")
 	router.Handle("/uploads", adminChain.Append(middleware.Paginate).Then(handlers.GetUploads)).Methods("GET")
+	router.Handle("/audit", adminChain.Append(middleware.Paginate).Then(handlers.GetAuditEvents)).Methods("GET")
 
 	if !ps.config.NoWebInterface {
 
@@ -424,7 +489,10 @@ func (ps *PlikServer) WithDataBackend(backend data.Backend) *PlikServer {
 func NewDataBackend(impl string, params map[string]interface{}) (backend data.Backend, err error) {
 	switch impl {
 	case "file":
-		backend = file.NewBackend(file.NewConfig(params))
+		backend, err = file.NewBackend(file.NewConfig(params))
+		if err != nil {
+			return nil, err
+		}
 	case "s3":
 		backend, err = s3.NewBackend(s3.NewConfig(params))
 		if err != nil {
@@ -446,10 +514,44 @@ func NewDataBackend(impl string, params map[string]interface{}) (backend data.Ba
 	return backend, nil
 }
 
+// NewDataBackendStack initialize the data backend of the configuration wrapped by the tiered, fallback
+// and deduplication layers the configuration enables ( the metadata backend is only needed for deduplication )
+func NewDataBackendStack(config *common.Configuration, metadataBackend *metadata.Backend) (backend data.Backend, err error) {
+	backend, err = NewDataBackend(config.DataBackend, config.DataBackendConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.ColdDataBackend != "" {
+		coldBackend, err := NewDataBackend(config.ColdDataBackend, config.ColdDataBackendConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize cold data backend : %s", err)
+		}
+		backend = tiered.NewBackend(backend, coldBackend)
+	}
+
+	if config.FallbackDataBackend != "" {
+		fallbackBackend, err := NewDataBackend(config.FallbackDataBackend, config.FallbackDataBackendConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize fallback data backend : %s", err)
+		}
+		backend = fallback.NewBackend(backend, fallbackBackend)
+	}
+
+	if config.Deduplication {
+		if metadataBackend == nil {
+			return nil, fmt.Errorf("metadata backend must be initialized before the deduplication layer")
+		}
+		backend = dedup.NewBackend(backend, metadataBackend)
+	}
+
+	return backend, nil
+}
+
 // Initialize data backend from type found in configuration
 func (ps *PlikServer) initializeDataBackend() (err error) {
 	if ps.dataBackend == nil {
-		ps.dataBackend, err = NewDataBackend(ps.config.DataBackend, ps.config.DataBackendConfig)
+		ps.dataBackend, err = NewDataBackendStack(ps.config, ps.metadataBackend)
 		if err != nil {
 			return err
 		}
@@ -542,6 +644,68 @@ func (ps *PlikServer) GetStreamBackend() data.Backend {
 	return ps.streamBackend
 }
 
+func (ps *PlikServer) initializeWebhookDispatcher() {
+	if ps.webhookDispatcher == nil && len(ps.config.Webhooks) > 0 {
+		ps.webhookDispatcher = webhook.NewDispatcher(ps.config, ps.metadataBackend)
+	}
+}
+
+// GetWebhookDispatcher return the webhook dispatcher ( nil if no webhook is configured )
+func (ps *PlikServer) GetWebhookDispatcher() *webhook.Dispatcher {
+	return ps.webhookDispatcher
+}
+
+func (ps *PlikServer) initializeNotifier() (err error) {
+	if ps.notifier == nil && ps.config.EmailNotifications {
+		ps.notifier, err = notification.NewNotifier(ps.config, ps.metadataBackend)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetNotifier return the email notifier ( nil if email notifications are disabled )
+func (ps *PlikServer) GetNotifier() *notification.Notifier {
+	return ps.notifier
+}
+
+func (ps *PlikServer) initializeScanner() (err error) {
+	if ps.scanner == nil && ps.config.ClamAVAddress != "" {
+		ps.scanner, err = clamav.NewScanner(ps.config.ClamAVAddress, ps.config.GetClamAVTimeout())
+		if err != nil {
+			return err
+		}
+
+		// clamd might not be ready yet, uploads will be handled according to ClamAVErrorPolicy until it is
+		err = ps.scanner.Ping()
+		if err != nil {
+			ps.config.NewLogger().Warningf("Unable to reach clamd : %s", err)
+		}
+	}
+	return nil
+}
+
+// GetScanner return the antivirus scanner ( nil if antivirus scanning is disabled )
+func (ps *PlikServer) GetScanner() *clamav.Scanner {
+	return ps.scanner
+}
+
+func (ps *PlikServer) initializeProcessors() (err error) {
+	if ps.processors == nil {
+		ps.processors, err = processor.NewChain(ps.config.Processors)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetProcessors return the content inspection processor chain
+func (ps *PlikServer) GetProcessors() *processor.Chain {
+	return ps.processors
+}
+
 // SetupContext sets necessary context values
 func (ps *PlikServer) setupContext(ctx *context.Context) {
 	ctx.SetConfig(ps.config)
@@ -551,4 +715,8 @@ func (ps *PlikServer) setupContext(ctx *context.Context) {
 	ctx.SetStreamBackend(ps.streamBackend)
 	ctx.SetAuthenticator(ps.authenticator)
 	ctx.SetMetrics(ps.metrics)
+	ctx.SetWebhookDispatcher(ps.webhookDispatcher)
+	ctx.SetNotifier(ps.notifier)
+	ctx.SetScanner(ps.scanner)
+	ctx.SetProcessors(ps.processors)
 }
@@ -2,9 +2,12 @@ package server
 
 import (
 	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -12,7 +15,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/data/dedup"
+	"github.com/root-gg/plik/server/data/fallback"
 	data_test "github.com/root-gg/plik/server/data/testing"
+	"github.com/root-gg/plik/server/data/tiered"
 	"github.com/root-gg/plik/server/metadata"
 )
 
@@ -134,6 +141,39 @@ func TestNewPlikServerWithCustomBackends(t *testing.T) {
 
 }
 
+func TestNewDataBackendStack(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DataBackend = "testing"
+
+	backend, err := NewDataBackendStack(config, nil)
+	require.NoError(t, err, "unable to initialize data backend")
+	require.IsType(t, &data_test.Backend{}, backend, "invalid data backend")
+
+	config.ColdDataBackend = "testing"
+	config.FallbackDataBackend = "testing"
+	config.Deduplication = true
+	_, err = NewDataBackendStack(config, nil)
+	common.RequireError(t, err, "metadata backend must be initialized before the deduplication layer")
+
+	metadataBackendConfig := &metadata.Config{Driver: "sqlite3", ConnectionString: "/tmp/plik.stack.test.db", EraseFirst: true}
+	metadataBackend, err := metadata.NewBackend(metadataBackendConfig, config.NewLogger())
+	require.NoError(t, err, "unable to create metadata backend")
+	defer func() { _ = metadataBackend.Shutdown() }()
+
+	backend, err = NewDataBackendStack(config, metadataBackend)
+	require.NoError(t, err, "unable to initialize data backend")
+
+	dedupBackend, ok := backend.(*dedup.Backend)
+	require.True(t, ok, "missing deduplication layer")
+	fallbackBackend, ok := dedupBackend.GetBackend().(*fallback.Backend)
+	require.True(t, ok, "missing fallback layer")
+	require.IsType(t, &tiered.Backend{}, fallbackBackend.GetBackend(), "missing tiered layer")
+
+	config.ColdDataBackend = "foo"
+	_, err = NewDataBackendStack(config, metadataBackend)
+	common.RequireError(t, err, "unable to initialize cold data backend")
+}
+
 func TestDataBackend(t *testing.T) {
 	ps := newPlikServer()
 	defer ps.ShutdownNow()
@@ -221,6 +261,89 @@ func TestClean(t *testing.T) {
 	require.Error(t, err, "missing get file error")
 }
 
+func TestCleanAuditEvents(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.AuditLogRetention = 3600
+
+	expired := common.NewAuditEvent(common.AuditLogin)
+	expired.CreatedAt = time.Now().Add(-2 * time.Hour)
+	err := ps.metadataBackend.CreateAuditEvent(expired)
+	require.NoError(t, err, "unable to save audit event")
+
+	event := common.NewAuditEvent(common.AuditLogin)
+	err = ps.metadataBackend.CreateAuditEvent(event)
+	require.NoError(t, err, "unable to save audit event")
+
+	ps.Clean()
+
+	var events []*common.AuditEvent
+	err = ps.metadataBackend.ForEachAuditEvent(func(event *common.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	require.NoError(t, err, "unable to list audit events")
+	require.Len(t, events, 1, "invalid audit events count after clean")
+	require.Equal(t, event.ID, events[0].ID, "invalid remaining audit event")
+}
+
+func TestCleanExpiredTokens(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	expired := user.NewToken()
+	expireAt := time.Now().Add(-time.Hour)
+	expired.ExpireAt = &expireAt
+	token := user.NewToken()
+
+	err := ps.metadataBackend.CreateUser(user)
+	require.NoError(t, err, "unable to save user")
+
+	ps.Clean()
+
+	t1, err := ps.metadataBackend.GetToken(expired.Token)
+	require.NoError(t, err, "unable to get token")
+	require.Nil(t, t1, "expired token should have been deleted")
+
+	t2, err := ps.metadataBackend.GetToken(token.Token)
+	require.NoError(t, err, "unable to get token")
+	require.NotNil(t, t2, "valid token should not have been deleted")
+}
+
+func TestCleanExpiredUploadRequests(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	expireAt := time.Now().Add(-time.Hour)
+	expired := common.NewUploadRequest()
+	expired.UploadID = upload.ID
+	expired.ExpireAt = &expireAt
+	err = ps.metadataBackend.CreateUploadRequest(expired)
+	require.NoError(t, err, "unable to create upload request")
+
+	request := common.NewUploadRequest()
+	request.UploadID = upload.ID
+	err = ps.metadataBackend.CreateUploadRequest(request)
+	require.NoError(t, err, "unable to create upload request")
+
+	ps.Clean()
+
+	r1, err := ps.metadataBackend.GetUploadRequest(expired.ID)
+	require.NoError(t, err, "unable to get upload request")
+	require.Nil(t, r1, "expired upload request should have been deleted")
+
+	r2, err := ps.metadataBackend.GetUploadRequest(request.ID)
+	require.NoError(t, err, "unable to get upload request")
+	require.NotNil(t, r2, "valid upload request should not have been deleted")
+}
+
 func TestCleanUploadingFiles(t *testing.T) {
 	ps := newPlikServer()
 	defer ps.ShutdownNow()
@@ -280,3 +403,533 @@ func TestAutoClean(t *testing.T) {
 	require.NoError(t, err, "unexpected unable to get upload")
 	require.Nil(t, u, "should be unable to get expired upload after clean")
 }
+
+func TestFsck(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.dataBackend = data_test.NewBackend()
+
+	content := "data data data"
+	md5sum := fmt.Sprintf("%x", md5.Sum([]byte(content)))
+	sha256sum := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+
+	upload := &common.Upload{}
+	newFile := func(name string, md5sum string, sha256sum string) *common.File {
+		file := upload.NewFile()
+		file.Name = name
+		file.Status = common.FileUploaded
+		file.Size = int64(len(content))
+		file.Md5 = md5sum
+		file.Sha256 = sha256sum
+		return file
+	}
+	ok := newFile("ok", md5sum, sha256sum)
+	missing := newFile("missing", md5sum, sha256sum)
+	corrupted := newFile("corrupted", md5sum, sha256sum)
+	legacy := newFile("legacy", md5sum, "")
+	removed := newFile("removed", md5sum, sha256sum)
+	removed.Status = common.FileRemoved
+	upload.InitializeForTests()
+
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to save upload")
+
+	for _, file := range []*common.File{ok, legacy, removed} {
+		err = ps.dataBackend.AddFile(file, bytes.NewBufferString(content))
+		require.NoError(t, err, "unable to save file")
+	}
+	err = ps.dataBackend.AddFile(corrupted, bytes.NewBufferString("data data dada"))
+	require.NoError(t, err, "unable to save file")
+
+	// The missing file content is a deduplicated blob
+	_, err = ps.metadataBackend.AddBlobReference(missing.ID, &common.Blob{ID: sha256sum})
+	require.NoError(t, err, "unable to add blob reference")
+
+	results := make(map[string]string)
+	report := func(file *common.File, result string, err error) {
+		results[file.Name] = result
+	}
+
+	stats, err := ps.Fsck(&FsckOptions{}, report)
+	require.NoError(t, err, "unable to check files")
+	require.Equal(t, &FsckStats{Checked: 4, Missing: 1, Corrupted: 1}, stats, "invalid fsck stats")
+	require.Equal(t, map[string]string{"ok": FsckOK, "missing": FsckMissing, "corrupted": FsckCorrupted, "legacy": FsckOK}, results, "invalid fsck results")
+
+	stats, err = ps.Fsck(&FsckOptions{UploadID: upload.ID, Repair: true}, report)
+	require.NoError(t, err, "unable to repair files")
+	require.Equal(t, &FsckStats{Checked: 4, Missing: 1, Corrupted: 1, Backfilled: 1, Repaired: 2}, stats, "invalid fsck stats")
+	require.Equal(t, FsckBackfilled, results["legacy"], "invalid fsck result")
+
+	f, err := ps.metadataBackend.GetFile(missing.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileDeleted, f.Status, "invalid missing file status")
+
+	blob, err := ps.metadataBackend.GetBlob(sha256sum)
+	require.NoError(t, err, "unable to get blob")
+	require.Nil(t, blob, "missing file blob reference should have been removed")
+
+	f, err = ps.metadataBackend.GetFile(corrupted.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid corrupted file status")
+
+	f, err = ps.metadataBackend.GetFile(legacy.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, sha256sum, f.Sha256, "invalid backfilled file sha256")
+
+	stats, err = ps.Fsck(&FsckOptions{FileID: legacy.ID}, nil)
+	require.NoError(t, err, "unable to check file")
+	require.Equal(t, &FsckStats{Checked: 1}, stats, "invalid fsck stats")
+
+	_, err = ps.Fsck(&FsckOptions{FileID: "foo"}, nil)
+	common.RequireError(t, err, "file foo not found")
+}
+
+func TestReconcile(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.dataBackend = data_test.NewBackend()
+
+	upload := &common.Upload{}
+	newFile := func(status string) *common.File {
+		file := upload.NewFile()
+		file.Status = status
+		return file
+	}
+	ok := newFile(common.FileUploaded)
+	missing := newFile(common.FileUploaded)
+	deleted := newFile(common.FileDeleted)
+	removed := newFile(common.FileRemoved)
+	uploading := newFile(common.FileUploading)
+	deduplicated := newFile(common.FileUploaded)
+	missingBlob := newFile(common.FileUploaded)
+	upload.InitializeForTests()
+
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to save upload")
+
+	add := func(file *common.File) {
+		err := ps.dataBackend.AddFile(file, bytes.NewBufferString("data"))
+		require.NoError(t, err, "unable to save file")
+	}
+	add(ok)
+	add(deleted)
+	add(removed)
+	add(&common.File{ID: uploading.ID + ".0", UploadID: upload.ID})
+	add(&common.File{ID: uploading.ID + ".staging", UploadID: upload.ID})
+
+	blob := &common.Blob{ID: fmt.Sprintf("%x", sha256.Sum256([]byte("data"))), Size: 4}
+	blob.DataID = blob.ID + "." + deduplicated.ID
+	_, err = ps.metadataBackend.AddBlobReference(deduplicated.ID, blob)
+	require.NoError(t, err, "unable to add blob reference")
+	add(dedup.GetBlobFile(blob))
+
+	// Blob content stored by a file being uploaded before the blob is created
+	add(&common.File{ID: fmt.Sprintf("%x.%s", sha256.Sum256([]byte("uploading")), uploading.ID), UploadID: dedup.BlobUploadID})
+
+	_, err = ps.metadataBackend.AddBlobReference(missingBlob.ID, &common.Blob{ID: fmt.Sprintf("%x", sha256.Sum256([]byte("missing")))})
+	require.NoError(t, err, "unable to add blob reference")
+
+	orphan := &common.File{ID: common.GenerateRandomID(16), UploadID: upload.ID}
+	add(orphan)
+	orphanChunk := &common.File{ID: common.GenerateRandomID(16) + ".1." + common.GenerateRandomID(8), UploadID: upload.ID}
+	add(orphanChunk)
+	orphanBlob := dedup.GetBlobFile(&common.Blob{ID: fmt.Sprintf("%x", sha256.Sum256([]byte("orphan")))})
+	add(orphanBlob)
+	staleBlob := &common.File{ID: blob.ID + "." + ok.ID, UploadID: dedup.BlobUploadID}
+	add(staleBlob)
+	add(&common.File{ID: "not-a-plik-file", UploadID: upload.ID})
+
+	results := make(map[string]string)
+	report := func(file *common.File, result string, err error) {
+		require.NoError(t, err, "unexpected reconciliation error")
+		results[file.ID] = result
+	}
+
+	stats, err := ps.Reconcile(&ReconcileOptions{}, report)
+	require.NoError(t, err, "unable to reconcile")
+	require.Equal(t, 12, stats.Files, "invalid file count")
+	require.Equal(t, 5, stats.OrphanFiles, "invalid orphan file count")
+	require.Equal(t, int64(20), stats.OrphanSize, "invalid orphan size")
+	require.Equal(t, 2, stats.MissingFiles, "invalid missing file count")
+	require.Equal(t, 0, stats.DeletedFiles, "invalid deleted file count")
+	require.Equal(t, map[string]string{
+		deleted.ID:     ReconcileOrphan,
+		orphan.ID:      ReconcileOrphan,
+		orphanChunk.ID: ReconcileOrphan,
+		orphanBlob.ID:  ReconcileOrphan,
+		staleBlob.ID:   ReconcileOrphan,
+		missing.ID:     ReconcileMissing,
+		missingBlob.ID: ReconcileMissing,
+	}, results, "invalid reconciliation results")
+	require.Len(t, ps.dataBackend.(*data_test.Backend).GetFiles(), 12, "files should not have been deleted")
+
+	results = make(map[string]string)
+	stats, err = ps.Reconcile(&ReconcileOptions{Delete: true}, report)
+	require.NoError(t, err, "unable to reconcile")
+	require.Equal(t, 5, stats.DeletedFiles, "invalid deleted file count")
+	require.Equal(t, 2, stats.RemovedMissingFiles, "invalid removed missing file count")
+	require.Len(t, ps.dataBackend.(*data_test.Backend).GetFiles(), 7, "orphan files should have been deleted")
+
+	for _, file := range []*common.File{missing, missingBlob} {
+		f, err := ps.metadataBackend.GetFile(file.ID)
+		require.NoError(t, err, "unable to get file")
+		require.Equal(t, common.FileDeleted, f.Status, "invalid missing file status")
+	}
+
+	stats, err = ps.Reconcile(&ReconcileOptions{}, report)
+	require.NoError(t, err, "unable to reconcile")
+	require.Equal(t, &common.ReconciliationStats{Files: 7}, stats, "invalid reconciliation stats")
+}
+
+func TestReconcileFallback(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	primary := data_test.NewBackend()
+	secondary := data_test.NewBackend()
+	ps.dataBackend = fallback.NewBackend(primary, secondary)
+
+	upload := &common.Upload{}
+	migrated := upload.NewFile()
+	migrated.Status = common.FileUploaded
+	notMigrated := upload.NewFile()
+	notMigrated.Status = common.FileUploaded
+	upload.InitializeForTests()
+
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to save upload")
+
+	require.NoError(t, primary.AddFile(migrated, bytes.NewBufferString("data")), "unable to save file")
+	require.NoError(t, secondary.AddFile(migrated, bytes.NewBufferString("data")), "unable to save file")
+	require.NoError(t, secondary.AddFile(notMigrated, bytes.NewBufferString("data")), "unable to save file")
+	orphan := &common.File{ID: common.GenerateRandomID(16), UploadID: upload.ID}
+	require.NoError(t, secondary.AddFile(orphan, bytes.NewBufferString("data")), "unable to save file")
+
+	results := make(map[string]string)
+	stats, err := ps.Reconcile(&ReconcileOptions{Delete: true}, func(file *common.File, result string, err error) {
+		require.NoError(t, err, "unexpected reconciliation error")
+		results[file.ID] = result
+	})
+	require.NoError(t, err, "unable to reconcile")
+	require.Equal(t, 3, stats.Files, "invalid file count")
+	require.Equal(t, 0, stats.MissingFiles, "files not migrated yet must not be missing")
+	require.Equal(t, map[string]string{orphan.ID: ReconcileOrphan}, results, "invalid reconciliation results")
+	require.Len(t, secondary.GetFiles(), 2, "orphan file should have been deleted from the fallback data backend")
+}
+
+func TestReconcileNotSupported(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.dataBackend = struct{ data.Backend }{ps.dataBackend}
+
+	_, err := ps.Reconcile(&ReconcileOptions{}, nil)
+	common.RequireError(t, err, "data backend does not support listing files")
+}
+
+func TestCleanReconcile(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	backend := data_test.NewBackend()
+	ps.dataBackend = backend
+
+	err := backend.AddFile(&common.File{ID: common.GenerateRandomID(16)}, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to save file")
+
+	ps.config.OrphanReconciliation = common.ReconciliationReport
+	ps.Clean()
+	require.Len(t, backend.GetFiles(), 1, "orphan file should not have been deleted")
+
+	ps.config.OrphanReconciliation = common.ReconciliationDelete
+	ps.Clean()
+	require.Len(t, backend.GetFiles(), 0, "orphan file should have been deleted")
+}
+
+func TestMigrateData(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	from := data_test.NewBackend()
+	to := data_test.NewBackend()
+	ps.dataBackend = dedup.NewBackend(fallback.NewBackend(to, from), ps.metadataBackend)
+
+	upload := &common.Upload{}
+	// The files are migrated in the order of their IDs
+	newFile := func(id string, content string) *common.File {
+		file := upload.NewFile()
+		file.ID = id
+		file.Status = common.FileUploaded
+		file.Md5 = fmt.Sprintf("%x", md5.Sum([]byte(content)))
+		file.Size = int64(len(content))
+		return file
+	}
+	file1 := newFile("file1", "data")
+	file2 := newFile("file2", "more data")
+	corrupted := newFile("file3", "data")
+	migrated := newFile("file4", "data")
+	deduplicated1 := newFile("file5", "blob")
+	deduplicated2 := newFile("file6", "blob")
+	upload.InitializeForTests()
+
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to save upload")
+
+	require.NoError(t, from.AddFile(file1, bytes.NewBufferString("data")), "unable to save file")
+	require.NoError(t, from.AddFile(file2, bytes.NewBufferString("more data")), "unable to save file")
+	require.NoError(t, from.AddFile(corrupted, bytes.NewBufferString("corrupted")), "unable to save file")
+	require.NoError(t, to.AddFile(migrated, bytes.NewBufferString("data")), "unable to save file")
+
+	blob := &common.Blob{ID: fmt.Sprintf("%x", sha256.Sum256([]byte("blob"))), Size: 4}
+	_, err = ps.metadataBackend.AddBlobReference(deduplicated1.ID, blob)
+	require.NoError(t, err, "unable to add blob reference")
+	_, err = ps.metadataBackend.AddBlobReference(deduplicated2.ID, blob)
+	require.NoError(t, err, "unable to add blob reference")
+	require.NoError(t, from.AddFile(dedup.GetBlobFile(blob), bytes.NewBufferString("blob")), "unable to save blob")
+
+	results := make(map[string]string)
+	report := func(file *common.File, result string, err error) {
+		if file.ID == corrupted.ID {
+			common.RequireError(t, err, "md5 mismatch")
+		} else {
+			require.NoError(t, err, "unexpected migration error")
+		}
+		results[file.ID] = result
+	}
+
+	stats, err := ps.MigrateData(&MigrateOptions{}, report)
+	require.NoError(t, err, "unable to migrate data")
+	require.Equal(t, 6, stats.Files, "invalid file count")
+	require.Equal(t, 3, stats.Migrated, "invalid migrated file count")
+	require.Equal(t, 2, stats.Skipped, "invalid skipped file count")
+	require.Equal(t, 1, stats.Errors, "invalid error count")
+	require.Equal(t, MigrateError, results[corrupted.ID], "invalid corrupted file result")
+	require.Equal(t, MigrateSkipped, results[migrated.ID], "invalid migrated file result")
+
+	files := to.GetFiles()
+	require.Len(t, files, 4, "invalid migrated file count")
+	require.Equal(t, "data", string(files[file1.ID]), "invalid migrated file content")
+	require.Equal(t, "more data", string(files[file2.ID]), "invalid migrated file content")
+	require.Equal(t, "blob", string(files[blob.ID]), "invalid migrated blob content")
+	require.Len(t, from.GetFiles(), 4, "files should not be removed from the fallback data backend")
+
+	// Files are read from the data backend even once removed from the fallback data backend
+	require.NoError(t, from.RemoveFile(file1), "unable to remove file")
+	reader, err := ps.dataBackend.GetFile(file1)
+	require.NoError(t, err, "unable to get file")
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(content), "invalid file content")
+
+	// The migration resumes before the corrupted file
+	checkpoint, err := ps.metadataBackend.GetSetting(common.DataMigrationCheckpointSettingKey)
+	require.NoError(t, err, "unable to get checkpoint")
+	require.NotNil(t, checkpoint, "missing checkpoint")
+	require.Equal(t, file2.ID, checkpoint.Value, "invalid checkpoint")
+
+	require.NoError(t, from.RemoveFile(corrupted), "unable to remove file")
+	require.NoError(t, from.AddFile(corrupted, bytes.NewBufferString("data")), "unable to save file")
+
+	results = make(map[string]string)
+	stats, err = ps.MigrateData(&MigrateOptions{}, func(file *common.File, result string, err error) {
+		require.NoError(t, err, "unexpected migration error")
+		results[file.ID] = result
+	})
+	require.NoError(t, err, "unable to migrate data")
+	require.Equal(t, 0, stats.Errors, "invalid error count")
+	require.Equal(t, MigrateOK, results[corrupted.ID], "invalid corrupted file result")
+	require.Equal(t, "data", string(to.GetFiles()[corrupted.ID]), "invalid migrated file content")
+
+	// Every file has been migrated
+	stats, err = ps.MigrateData(&MigrateOptions{}, nil)
+	require.NoError(t, err, "unable to migrate data")
+	require.Equal(t, 6, stats.Skipped, "invalid skipped file count")
+	require.Equal(t, 0, stats.Migrated, "invalid migrated file count")
+}
+
+func TestMigrateDataNoFallback(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.dataBackend = data_test.NewBackend()
+
+	_, err := ps.MigrateData(&MigrateOptions{}, nil)
+	common.RequireError(t, err, "no fallback data backend")
+}
+
+func TestDemoteFiles(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.ColdStorageMinAge = 3600
+	ps.config.ColdStorageIdleTime = 86400
+	ps.config.ColdStorageMinSize = 4
+
+	hot := data_test.NewBackend()
+	cold := data_test.NewBackend()
+	ps.dataBackend = dedup.NewBackend(tiered.NewBackend(hot, cold), ps.metadataBackend)
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to save upload")
+
+	newFile := func(content string, age time.Duration, lastDownload time.Duration) *common.File {
+		file := upload.NewFile()
+		file.Status = common.FileUploaded
+		file.Size = int64(len(content))
+		err := ps.metadataBackend.CreateFile(file)
+		require.NoError(t, err, "unable to create file")
+
+		err = ps.dataBackend.AddFile(file, bytes.NewBufferString(content))
+		require.NoError(t, err, "unable to add file")
+
+		file.CreatedAt = time.Now().Add(-age)
+		if lastDownload > 0 {
+			lastDownloadAt := time.Now().Add(-lastDownload)
+			file.LastDownloadAt = &lastDownloadAt
+		}
+		err = ps.metadataBackend.UpdateFile(file, common.FileUploaded)
+		require.NoError(t, err, "unable to update file")
+		return file
+	}
+
+	day := 24 * time.Hour
+	idle := newFile("idle data", 7*day, 0)
+	downloaded := newFile("downloaded data", 7*day, time.Hour)
+	idleSinceDownload := newFile("idle since download", 7*day, 2*day)
+	recent := newFile("recent data", time.Minute, 0)
+	small := newFile("s", 7*day, 0)
+	deduplicated1 := newFile("idle data", 7*day, 0)
+
+	require.Len(t, hot.GetFiles(), 5, "invalid hot file count")
+
+	results := make(map[string]string)
+	stats, err := ps.DemoteFiles(func(file *common.File, result string, err error) {
+		require.NoError(t, err, "unexpected demotion error")
+		results[file.ID] = result
+	})
+	require.NoError(t, err, "unable to demote files")
+	require.Equal(t, 2, stats.Demoted, "invalid demoted file count")
+	require.Equal(t, 0, stats.Errors, "invalid error count")
+	require.Len(t, results, 2, "invalid demotion results")
+	require.Equal(t, DemoteOK, results[idleSinceDownload.ID], "invalid demotion result")
+
+	require.Len(t, hot.GetFiles(), 3, "invalid hot file count")
+	require.Len(t, cold.GetFiles(), 2, "invalid cold file count")
+
+	// Files are read from wherever they live
+	for _, file := range []*common.File{idle, downloaded, idleSinceDownload, recent, small, deduplicated1} {
+		reader, err := ps.dataBackend.GetFile(file)
+		require.NoError(t, err, "unable to get file")
+		_, err = io.ReadAll(reader)
+		require.NoError(t, err, "unable to read file")
+	}
+
+	blob, err := ps.metadataBackend.GetFileBlob(idle.ID)
+	require.NoError(t, err, "unable to get blob")
+	require.Equal(t, tiered.Cold, tiered.GetTier(dedup.GetBlobFile(blob)), "invalid blob tier")
+
+	// Demoted files are not demoted again
+	stats, err = ps.DemoteFiles(nil)
+	require.NoError(t, err, "unable to demote files")
+	require.Equal(t, 0, stats.Demoted, "invalid demoted file count")
+}
+
+func TestDemoteFilesNoColdBackend(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.dataBackend = data_test.NewBackend()
+
+	_, err := ps.DemoteFiles(nil)
+	common.RequireError(t, err, "no cold data backend")
+}
+
+func TestAdminAPITokenScope(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.FeatureAuthentication = common.FeatureEnabled
+	ps.config.NoWebInterface = true
+
+	user := common.NewUser(common.ProviderLocal, "admin")
+	user.IsAdmin = true
+	err := ps.metadataBackend.CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	handler := ps.getHTTPHandler()
+	get := func(token *common.Token) int {
+		token.UserID = user.ID
+		err := ps.metadataBackend.CreateToken(token)
+		require.NoError(t, err, "unable to create token")
+
+		req, err := http.NewRequest("GET", "/users", &bytes.Buffer{})
+		require.NoError(t, err, "unable to create request")
+		req.RemoteAddr = "127.0.0.1:1234"
+		req.Header.Set("X-PlikToken", token.Token)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusForbidden, get(common.NewToken()), "tokens without scope must not access the admin API")
+
+	token := common.NewToken()
+	token.Scope = common.TokenScopeRead
+	require.Equal(t, http.StatusForbidden, get(token), "tokens without the admin scope must not access the admin API")
+
+	token = common.NewToken()
+	token.Scope = common.TokenScopeAdmin
+	require.Equal(t, http.StatusOK, get(token), "tokens with the admin scope should access the admin API")
+}
+
+func TestTokenAPITokenScope(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.FeatureAuthentication = common.FeatureEnabled
+	ps.config.NoWebInterface = true
+
+	user := common.NewUser(common.ProviderLocal, "admin")
+	user.IsAdmin = true
+	err := ps.metadataBackend.CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	handler := ps.getHTTPHandler()
+	do := func(token *common.Token, method string, path string, body string) int {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		require.NoError(t, err, "unable to create request")
+		req.RemoteAddr = "127.0.0.1:1234"
+		req.Header.Set("X-PlikToken", token.Token)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	newToken := func(scope string) *common.Token {
+		token := common.NewToken()
+		token.Scope = scope
+		token.UserID = user.ID
+		err := ps.metadataBackend.CreateToken(token)
+		require.NoError(t, err, "unable to create token")
+		return token
+	}
+
+	for _, scope := range []string{"", common.TokenScopeRead, common.TokenScopeAdmin} {
+		token := newToken(scope)
+		require.Equal(t, http.StatusForbidden, do(token, "GET", "/me/token", ""), "tokens without the token scope must not list tokens ( scope %q )", scope)
+		require.Equal(t, http.StatusForbidden, do(token, "POST", "/me/token", "{}"), "tokens without the token scope must not create tokens ( scope %q )", scope)
+		require.Equal(t, http.StatusForbidden, do(token, "DELETE", "/me/token/"+token.Token, ""), "tokens without the token scope must not revoke tokens ( scope %q )", scope)
+	}
+
+	// Managing tokens does not grant admin rights
+	token := newToken(common.TokenScopeToken)
+	require.Equal(t, http.StatusOK, do(token, "GET", "/me/token", ""), "tokens with the token scope should list tokens")
+	require.Equal(t, http.StatusOK, do(token, "POST", "/me/token", "{}"), "tokens with the token scope should create tokens")
+	require.Equal(t, http.StatusForbidden, do(token, "POST", "/me/token", `{"scope":"admin"}`), "tokens must not create tokens with more scopes")
+	require.Equal(t, http.StatusForbidden, do(token, "GET", "/users", ""), "tokens with the token scope must not access the admin API")
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data/dedup"
+	"github.com/root-gg/plik/server/data/fallback"
+	"github.com/root-gg/plik/server/data/tiered"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+/*
+  Plik tiered storage design :
+    - The hot data backend is configured as DataBackend and the cold one as ColdDataBackend
+    - The cleaning routine demotes the uploaded files matching all the conditions of the cold storage policy :
+      - Uploaded more than ColdStorageMinAge ago
+      - Not downloaded for ColdStorageIdleTime ( since their upload if they have never been downloaded )
+      - At least ColdStorageMinSize bytes
+    - The file is copied to the cold data backend, its backend details are updated then the hot copy is removed
+    - Deduplication blobs are demoted with the first matching file referencing them
+*/
+
+// Demotion results
+const (
+	DemoteOK    = "demoted"
+	DemoteError = "error"
+)
+
+// DemoteStats summarize a demotion of files to the cold data backend
+type DemoteStats struct {
+	Demoted int
+	Size    int64
+	Errors  int
+}
+
+// DemoteReport is called for each file matching the cold storage policy
+type DemoteReport func(file *common.File, result string, err error)
+
+// DemoteFiles move the uploaded files matching the cold storage policy to the cold data backend
+func (ps *PlikServer) DemoteFiles(report DemoteReport) (stats *DemoteStats, err error) {
+	stats = &DemoteStats{}
+
+	if report == nil {
+		report = func(file *common.File, result string, err error) {}
+	}
+
+	// Blobs are stored in the underlying data backend
+	backend := ps.dataBackend
+	if dedupBackend, ok := backend.(*dedup.Backend); ok {
+		backend = dedupBackend.GetBackend()
+	}
+	if fallbackBackend, ok := backend.(*fallback.Backend); ok {
+		backend = fallbackBackend.GetBackend()
+	}
+
+	tieredBackend, ok := backend.(*tiered.Backend)
+	if !ok {
+		return stats, fmt.Errorf("no cold data backend to move the files to")
+	}
+
+	// The files are loaded by batches as they are updated while iterating
+	now := time.Now()
+	err = ps.metadataBackend.ForEachFileBatch(&common.File{Status: common.FileUploaded}, "", metadata.BatchSize, func(file *common.File) error {
+		if !ps.isCold(file, now) {
+			return nil
+		}
+
+		demoted, err := ps.demoteFile(tieredBackend, file)
+		if err != nil {
+			stats.Errors++
+			report(file, DemoteError, err)
+			return nil
+		}
+		if !demoted {
+			return nil
+		}
+
+		stats.Demoted++
+		stats.Size += file.Size
+		report(file, DemoteOK, nil)
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("unable to get files : %s", err)
+	}
+
+	return stats, nil
+}
+
+// isCold return true if the file matches the cold storage policy
+func (ps *PlikServer) isCold(file *common.File, now time.Time) bool {
+	if file.Size < ps.config.ColdStorageMinSize {
+		return false
+	}
+
+	if now.Sub(file.CreatedAt) < time.Duration(ps.config.ColdStorageMinAge)*time.Second {
+		return false
+	}
+
+	lastAccess := file.CreatedAt
+	if file.LastDownloadAt != nil {
+		lastAccess = *file.LastDownloadAt
+	}
+
+	return now.Sub(lastAccess) >= time.Duration(ps.config.ColdStorageIdleTime)*time.Second
+}
+
+// demoteFile move a file or the deduplication blob it references to the cold data backend
+func (ps *PlikServer) demoteFile(backend *tiered.Backend, file *common.File) (demoted bool, err error) {
+	blob, err := ps.metadataBackend.GetFileBlob(file.ID)
+	if err != nil {
+		return false, fmt.Errorf("unable to get file blob : %s", err)
+	}
+
+	if blob != nil {
+		return demoteObject(backend, dedup.GetBlobFile(blob), func(backendDetails string) error {
+			blob.BackendDetails = backendDetails
+			return ps.metadataBackend.UpdateBlobBackendDetails(blob)
+		})
+	}
+
+	return demoteObject(backend, file, func(backendDetails string) error {
+		f := *file
+		f.BackendDetails = backendDetails
+		return ps.metadataBackend.UpdateFileBackendDetails(&f)
+	})
+}
+
+// demoteObject copy a hot file to the cold data backend, save its new backend details then remove the hot copy
+func demoteObject(backend *tiered.Backend, file *common.File, save func(backendDetails string) error) (demoted bool, err error) {
+	if tiered.GetTier(file) == tiered.Cold {
+		return false, nil
+	}
+
+	backendDetails, err := backend.Demote(file)
+	if err != nil {
+		return false, err
+	}
+
+	err = save(backendDetails)
+	if err != nil {
+		return false, fmt.Errorf("unable to update backend details : %s", err)
+	}
+
+	// The hot copy is removed with its previous backend details
+	err = backend.RemoveHotFile(file)
+	if err != nil {
+		return false, fmt.Errorf("unable to remove file from hot data backend : %s", err)
+	}
+
+	return true, nil
+}
+
+// demoteFiles run the demotion from the cleaning routine and log the results
+func (ps *PlikServer) demoteFiles() (demoted int) {
+	log := ps.config.NewLogger()
+
+	report := func(file *common.File, result string, err error) {
+		if err != nil {
+			log.Warningf("unable to move file %s/%s to the cold data backend : %s", file.UploadID, file.ID, err)
+		}
+	}
+
+	stats, err := ps.DemoteFiles(report)
+	if err != nil {
+		log.Warningf("unable to move files to the cold data backend : %s", err)
+		return 0
+	}
+	if stats.Demoted > 0 {
+		log.Infof("moved %d files ( %d bytes ) to the cold data backend", stats.Demoted, stats.Size)
+	}
+
+	return stats.Demoted
+}
@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/root-gg/logger"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+/*
+  Webhook delivery design :
+    - Events are not sent from the HTTP handlers, instead a delivery is added to the outbox
+      table of the metadata backend for each webhook subscribed to the event
+    - A background routine sends the pending deliveries and retries the failed ones with
+      an exponential backoff until the maximum number of attempts is reached
+    - Deliveries are claimed before being sent so several Plik instances sharing the same
+      metadata backend don't send the same event twice
+    - As the outbox is persisted pending events survive restarts, events are delivered at least once
+*/
+
+// Dispatcher add webhook events to the outbox and deliver them
+type Dispatcher struct {
+	config          *common.Configuration
+	metadataBackend *metadata.Backend
+	log             *logger.Logger
+	client          *http.Client
+	notify          chan struct{}
+
+	batchSize    int
+	pollInterval time.Duration
+	claimTimeout time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	maxAttempts  int
+}
+
+// NewDispatcher create a new webhook dispatcher
+func NewDispatcher(config *common.Configuration, metadataBackend *metadata.Backend) (d *Dispatcher) {
+	d = new(Dispatcher)
+	d.config = config
+	d.metadataBackend = metadataBackend
+	d.log = config.NewLogger()
+	d.client = &http.Client{Timeout: 10 * time.Second}
+	d.notify = make(chan struct{}, 1)
+
+	d.batchSize = 100
+	d.pollInterval = 30 * time.Second
+	d.claimTimeout = time.Minute
+	d.minBackoff = 10 * time.Second
+	d.maxBackoff = time.Hour
+	d.maxAttempts = 10
+
+	return d
+}
+
+// Emit add the event to the outbox of every webhook subscribed to the event type
+func (d *Dispatcher) Emit(event *common.WebhookEvent) (err error) {
+	var payload []byte
+	for _, webhook := range d.config.Webhooks {
+		if !webhook.Accept(event.Type) {
+			continue
+		}
+
+		if payload == nil {
+			payload, err = json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("unable to serialize webhook event : %s", err)
+			}
+		}
+
+		delivery := &common.WebhookDelivery{}
+		delivery.ID = common.GenerateRandomID(32)
+		delivery.URL = webhook.URL
+		delivery.Event = event.Type
+		delivery.Payload = string(payload)
+		delivery.NextAttempt = time.Now()
+
+		err = d.metadataBackend.CreateWebhookDelivery(delivery)
+		if err != nil {
+			return fmt.Errorf("unable to save webhook delivery : %s", err)
+		}
+	}
+
+	if payload != nil {
+		// Wake up the delivery routine
+		select {
+		case d.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Run deliver the pending events until done is closed
+func (d *Dispatcher) Run(done <-chan struct{}) {
+	for {
+		d.DeliverPending()
+
+		select {
+		case <-d.notify:
+		case <-time.After(d.pollInterval):
+		case <-done:
+			return
+		}
+	}
+}
+
+// DeliverPending send all the deliveries that are due
+func (d *Dispatcher) DeliverPending() {
+	for {
+		deliveries, err := d.metadataBackend.GetPendingWebhookDeliveries(d.batchSize)
+		if err != nil {
+			d.log.Warningf("Unable to get pending webhook deliveries : %s", err)
+			return
+		}
+
+		claimed := 0
+		for _, delivery := range deliveries {
+			if d.deliver(delivery) {
+				claimed++
+			}
+		}
+
+		if len(deliveries) < d.batchSize || claimed == 0 {
+			return
+		}
+	}
+}
+
+// deliver send a delivery, return false if the delivery could not be claimed
+func (d *Dispatcher) deliver(delivery *common.WebhookDelivery) bool {
+	webhook := d.getWebhook(delivery.URL)
+	if webhook == nil {
+		d.log.Warningf("Webhook %s is not configured anymore, dropping %s event %s", delivery.URL, delivery.Event, delivery.ID)
+		d.delete(delivery)
+		return true
+	}
+
+	err := d.metadataBackend.ClaimWebhookDelivery(delivery, time.Now().Add(d.claimTimeout))
+	if err != nil {
+		return false
+	}
+
+	err = d.send(webhook, delivery)
+	if err == nil {
+		d.delete(delivery)
+		return true
+	}
+
+	if delivery.Attempts >= d.maxAttempts {
+		d.log.Criticalf("Unable to send %s event %s to webhook %s after %d attempts, giving up : %s", delivery.Event, delivery.ID, delivery.URL, delivery.Attempts, err)
+		d.delete(delivery)
+		return true
+	}
+
+	backoff := d.getBackoff(delivery.Attempts)
+	d.log.Warningf("Unable to send %s event %s to webhook %s : %s, will retry in %s", delivery.Event, delivery.ID, delivery.URL, err, backoff)
+
+	err = d.metadataBackend.RetryWebhookDelivery(delivery, time.Now().Add(backoff), err.Error())
+	if err != nil {
+		d.log.Warningf("Unable to update webhook delivery %s : %s", delivery.ID, err)
+	}
+
+	return true
+}
+
+func (d *Dispatcher) send(webhook *common.WebhookConfig, delivery *common.WebhookDelivery) (err error) {
+	payload := []byte(delivery.Payload)
+
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "plik/"+common.GetBuildInfo().Version)
+	req.Header.Set("X-Plik-Event", delivery.Event)
+	req.Header.Set("X-Plik-Delivery", delivery.ID)
+	if webhook.Secret != "" {
+		req.Header.Set(common.WebhookSignatureHeader, webhook.Sign(payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// Drain the body to reuse the connection
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) delete(delivery *common.WebhookDelivery) {
+	err := d.metadataBackend.DeleteWebhookDelivery(delivery.ID)
+	if err != nil {
+		d.log.Warningf("Unable to delete webhook delivery %s : %s", delivery.ID, err)
+	}
+}
+
+func (d *Dispatcher) getWebhook(URL string) *common.WebhookConfig {
+	for _, webhook := range d.config.Webhooks {
+		if webhook.URL == URL {
+			return webhook
+		}
+	}
+	return nil
+}
+
+// getBackoff return the delay before the next attempt : minBackoff * 2 ^ ( attempts - 1 ) up to maxBackoff
+func (d *Dispatcher) getBackoff(attempts int) time.Duration {
+	backoff := d.minBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= d.maxBackoff {
+			return d.maxBackoff
+		}
+	}
+	return backoff
+}
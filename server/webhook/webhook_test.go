@@ -0,0 +1,183 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+type testWebhook struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newTestWebhook() (webhook *testWebhook) {
+	webhook = &testWebhook{status: http.StatusOK}
+	webhook.server = httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		webhook.mu.Lock()
+		defer webhook.mu.Unlock()
+
+		webhook.requests = append(webhook.requests, req)
+		webhook.bodies = append(webhook.bodies, body)
+		resp.WriteHeader(webhook.status)
+	}))
+	return webhook
+}
+
+func (webhook *testWebhook) count() int {
+	webhook.mu.Lock()
+	defer webhook.mu.Unlock()
+	return len(webhook.requests)
+}
+
+func newTestDispatcher(t *testing.T, webhooks ...*common.WebhookConfig) (d *Dispatcher) {
+	config := common.NewConfiguration()
+	config.Webhooks = webhooks
+
+	metadataBackendConfig := &metadata.Config{Driver: "sqlite3", ConnectionString: "/tmp/plik.webhook.test.db", EraseFirst: true}
+	metadataBackend, err := metadata.NewBackend(metadataBackendConfig, config.NewLogger())
+	require.NoError(t, err, "unable to create metadata backend")
+
+	d = NewDispatcher(config, metadataBackend)
+	d.minBackoff = 0
+	d.maxBackoff = 0
+	d.maxAttempts = 3
+
+	return d
+}
+
+func countDeliveries(t *testing.T, d *Dispatcher) (count int) {
+	err := d.metadataBackend.ForEachWebhookDelivery(func(delivery *common.WebhookDelivery) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err, "unable to list webhook deliveries")
+	return count
+}
+
+func TestEmitAndDeliver(t *testing.T) {
+	webhook := newTestWebhook()
+	defer webhook.server.Close()
+
+	other := newTestWebhook()
+	defer other.server.Close()
+
+	d := newTestDispatcher(t,
+		&common.WebhookConfig{URL: webhook.server.URL, Secret: "secret"},
+		&common.WebhookConfig{URL: other.server.URL, Events: []string{common.EventUploadRemoved}},
+	)
+
+	upload := common.NewUpload()
+	event := common.NewWebhookEvent(common.EventUploadCreated, upload, nil)
+	err := d.Emit(event)
+	require.NoError(t, err, "unable to emit event")
+	require.Equal(t, 1, countDeliveries(t, d), "invalid webhook delivery count")
+
+	d.DeliverPending()
+	require.Equal(t, 1, webhook.count(), "invalid webhook request count")
+	require.Equal(t, 0, other.count(), "event should not have been sent")
+	require.Equal(t, 0, countDeliveries(t, d), "invalid webhook delivery count")
+
+	req := webhook.requests[0]
+	require.Equal(t, common.EventUploadCreated, req.Header.Get("X-Plik-Event"), "invalid event header")
+	require.Equal(t, "application/json", req.Header.Get("Content-Type"), "invalid content type")
+	require.Equal(t, d.config.Webhooks[0].Sign(webhook.bodies[0]), req.Header.Get(common.WebhookSignatureHeader), "invalid signature")
+
+	result := &common.WebhookEvent{}
+	err = json.Unmarshal(webhook.bodies[0], result)
+	require.NoError(t, err, "unable to unmarshal event")
+	require.Equal(t, event.ID, result.ID, "invalid event id")
+	require.Equal(t, upload.ID, result.Upload.ID, "invalid upload id")
+	require.Empty(t, result.Upload.UploadToken, "upload token should not be sent")
+}
+
+func TestDeliverRetry(t *testing.T) {
+	webhook := newTestWebhook()
+	defer webhook.server.Close()
+	webhook.status = http.StatusInternalServerError
+
+	d := newTestDispatcher(t, &common.WebhookConfig{URL: webhook.server.URL})
+
+	err := d.Emit(common.NewWebhookEvent(common.EventUploadRemoved, common.NewUpload(), nil))
+	require.NoError(t, err, "unable to emit event")
+
+	d.DeliverPending()
+	require.Equal(t, 1, webhook.count(), "invalid webhook request count")
+	require.Equal(t, 1, countDeliveries(t, d), "delivery should be retried")
+
+	webhook.mu.Lock()
+	webhook.status = http.StatusOK
+	webhook.mu.Unlock()
+
+	d.DeliverPending()
+	require.Equal(t, 2, webhook.count(), "invalid webhook request count")
+	require.Equal(t, 0, countDeliveries(t, d), "invalid webhook delivery count")
+}
+
+func TestDeliverGiveUp(t *testing.T) {
+	webhook := newTestWebhook()
+	defer webhook.server.Close()
+	webhook.status = http.StatusInternalServerError
+
+	d := newTestDispatcher(t, &common.WebhookConfig{URL: webhook.server.URL})
+
+	err := d.Emit(common.NewWebhookEvent(common.EventUploadRemoved, common.NewUpload(), nil))
+	require.NoError(t, err, "unable to emit event")
+
+	for i := 0; i < 5; i++ {
+		d.DeliverPending()
+	}
+	require.Equal(t, d.maxAttempts, webhook.count(), "invalid webhook request count")
+	require.Equal(t, 0, countDeliveries(t, d), "delivery should have been dropped")
+}
+
+func TestDeliverUnknownWebhook(t *testing.T) {
+	d := newTestDispatcher(t, &common.WebhookConfig{URL: "http://127.0.0.1:1/hook"})
+
+	err := d.Emit(common.NewWebhookEvent(common.EventUploadRemoved, common.NewUpload(), nil))
+	require.NoError(t, err, "unable to emit event")
+
+	// The webhook has been removed from the configuration
+	d.config.Webhooks = nil
+
+	d.DeliverPending()
+	require.Equal(t, 0, countDeliveries(t, d), "delivery should have been dropped")
+}
+
+func TestRun(t *testing.T) {
+	webhook := newTestWebhook()
+	defer webhook.server.Close()
+
+	d := newTestDispatcher(t, &common.WebhookConfig{URL: webhook.server.URL})
+
+	done := make(chan struct{})
+	defer close(done)
+	go d.Run(done)
+
+	err := d.Emit(common.NewWebhookEvent(common.EventUploadCreated, common.NewUpload(), nil))
+	require.NoError(t, err, "unable to emit event")
+
+	require.Eventually(t, func() bool { return webhook.count() == 1 }, 5*time.Second, 10*time.Millisecond, "event has not been delivered")
+}
+
+func TestGetBackoff(t *testing.T) {
+	d := NewDispatcher(common.NewConfiguration(), nil)
+	require.Equal(t, 10*time.Second, d.getBackoff(1), "invalid backoff")
+	require.Equal(t, 20*time.Second, d.getBackoff(2), "invalid backoff")
+	require.Equal(t, 80*time.Second, d.getBackoff(4), "invalid backoff")
+	require.Equal(t, time.Hour, d.getBackoff(20), "invalid backoff")
+}
@@ -5,6 +5,7 @@ var plik = angular.module('plik', ['ngRoute', 'api', 'config', 'dialog', 'paste'
             .when('/', {controller: 'MainCtrl', templateUrl: 'partials/main.html', reloadOnSearch: false})
             .when('/clients', {controller: 'ClientListCtrl', templateUrl: 'partials/clients.html'})
             .when('/login', {controller: 'LoginCtrl', templateUrl: 'partials/login.html'})
+            .when('/request', {controller: 'RequestCtrl', templateUrl: 'partials/request.html'})
             .when('/home', {controller: 'HomeCtrl', templateUrl: 'partials/home.html'})
             .when('/admin', {controller: 'AdminCtrl', templateUrl: 'partials/admin.html'})
             .otherwise({redirectTo: '/'});
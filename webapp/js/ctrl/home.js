@@ -15,6 +15,11 @@ plik.controller('HomeCtrl', ['$scope', '$api', '$config', '$dialog', '$location'
             $scope.refreshUser();
         };
 
+        $scope.displayRequests = function () {
+            $scope.display = 'requests';
+            $scope.getUploadRequests();
+        };
+
         // Get server config
         $config.config
             .then(function (config) {
@@ -89,6 +94,61 @@ plik.controller('HomeCtrl', ['$scope', '$api', '$config', '$dialog', '$location'
                 });
         };
 
+        // Get user upload request list
+        $scope.getUploadRequests = function (more) {
+            if (!more) {
+                $scope.requests = [];
+                $scope.requests_cursor = undefined;
+            }
+
+            $api.getUserUploadRequests($scope.limit, $scope.requests_cursor)
+                .then(function (result) {
+                    $scope.requests = $scope.requests.concat(result.results);
+                    $scope.requests_cursor = result.after;
+                })
+                .then(null, function (error) {
+                    $dialog.alert(error);
+                });
+        };
+
+        // Create a new upload request
+        $scope.requestParams = {};
+        $scope.createUploadRequest = function () {
+            $api.createUploadRequest($scope.requestParams)
+                .then(function () {
+                    $scope.requestParams = {};
+                    $scope.getUploadRequests();
+                })
+                .then(null, function (error) {
+                    $dialog.alert(error);
+                });
+        };
+
+        // Delete an upload request
+        $scope.deleteUploadRequest = function (request) {
+            $dialog.alert({
+                title: "Really ?",
+                message: "Deleting an upload request will not delete the files already received.",
+                confirm: true
+            }).result.then(
+                function () {
+                    $api.deleteUploadRequest(request.id)
+                        .then(function () {
+                            $scope.getUploadRequests();
+                        })
+                        .then(null, function (error) {
+                            $dialog.alert(error);
+                        });
+                }, function () {
+                    // Avoid "Possibly unhandled rejection"
+                });
+        };
+
+        // Get upload request url to share with the senders
+        $scope.getUploadRequestUrl = function (request) {
+            return $api.base + '/#/request?id=' + request.id + '&token=' + request.token;
+        };
+
         // Get user statistics
         $scope.getUserStats = function () {
             $api.getUserStats()
@@ -57,6 +57,18 @@ plik.controller('LoginCtrl', ['$scope', '$api', '$config', '$location', '$dialog
                 });
         };
 
+        // OpenID Connect authentication
+        $scope.oidc = function () {
+            $api.login("oidc")
+                .then(function (url) {
+                    // Redirect to OpenID Connect provider user consent dialog
+                    window.location.replace(url);
+                })
+                .then(null, function (error) {
+                    $dialog.alert(error);
+                });
+        };
+
         // Login with local user
         $scope.login = function () {
             $api.login("local", $scope.username, $scope.password)
@@ -541,6 +541,35 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                 }, discard);
         };
 
+        // Send the upload link by email dialog
+        $scope.sendByEmail = function () {
+            $dialog.openDialog({
+                backdrop: true,
+                backdropClick: true,
+                templateUrl: 'partials/email.html',
+                controller: 'EmailController',
+                resolve: {
+                    args: function () {
+                        return {
+                            user: $scope.user
+                        };
+                    }
+                }
+            }).result.then(
+                function (params) {
+                    $api.notifyUpload($scope.upload, params)
+                        .then(function () {
+                            $dialog.alert({
+                                status: 100,
+                                message: "The upload link has been sent to " + params.to.join(", ")
+                            });
+                        })
+                        .then(null, function (error) {
+                            $dialog.alert(error);
+                        });
+                }, discard);
+        };
+
         $scope.ttlUnits = ["days", "hours", "minutes"];
         $scope.ttlUnit = "days";
         $scope.ttlValue = 30;
@@ -0,0 +1,92 @@
+// Upload request controller, lets anyone with the link send files to the requester
+plik.controller('RequestCtrl', ['$scope', '$api', '$dialog', '$location',
+    function ($scope, $api, $dialog, $location) {
+
+        var requestId = $location.search().id;
+        var requestToken = $location.search().token;
+
+        $scope.files = [];
+
+        // Get upload request
+        $api.getUploadRequest(requestId, requestToken)
+            .then(function (request) {
+                $scope.request = request;
+            })
+            .then(null, function (error) {
+                $dialog.alert(error);
+            });
+
+        // Check the upload request limits before adding a file to the list
+        $scope.checkLimits = function (file) {
+            var request = $scope.request;
+            var files = request.files + _.filter($scope.files, function (f) {
+                return f.status !== 'uploaded';
+            }).length;
+            if (request.maxFiles > 0 && files >= request.maxFiles) {
+                $dialog.alert({
+                    status: 0,
+                    message: "This upload request accepts at most " + request.maxFiles + " files"
+                });
+                return false;
+            }
+            if (request.maxFileSize > 0 && file.size > request.maxFileSize) {
+                $dialog.alert({
+                    status: 0,
+                    message: "File is too big. Maximum file size is " + getHumanReadableSize(request.maxFileSize)
+                });
+                return false;
+            }
+            return true;
+        };
+
+        // Add a file to the upload list
+        $scope.onFileSelect = function (files) {
+            if (!$scope.request) return;
+            _.each(files, function (file) {
+                if (!$scope.checkLimits(file)) return;
+
+                file.fileName = file.name;
+                file.fileSize = file.size;
+                file.status = "toUpload";
+
+                $scope.files.push(file);
+            });
+        };
+
+        // Remove a file from the upload list
+        $scope.removeFile = function (file) {
+            $scope.files = _.without($scope.files, file);
+        };
+
+        // Is there something to send
+        $scope.somethingToUpload = function () {
+            return _.find($scope.files, function (file) {
+                return file.status === 'toUpload';
+            });
+        };
+
+        // Send every files
+        $scope.uploadFiles = function () {
+            _.each($scope.files, function (file) {
+                if (file.status !== 'toUpload') return;
+                var progress = function (event) {
+                    // Update progress bar callback
+                    file.progress = parseInt(100.0 * event.loaded / event.total);
+                };
+                file.status = "uploading";
+                $api.uploadRequestFile(requestId, requestToken, file, progress)
+                    .then(function () {
+                        file.status = "uploaded";
+                        $scope.request.files++;
+                        $scope.request.size += file.size;
+                    })
+                    .then(null, function (error) {
+                        file.status = "toUpload";
+                        $dialog.alert(error);
+                    });
+            });
+        };
+
+        // Compute human readable size
+        $scope.humanReadableSize = getHumanReadableSize;
+    }]);
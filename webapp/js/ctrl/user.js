@@ -3,7 +3,7 @@ plik.controller('UserController', ['$scope', 'args', '$config', '$q',
     function ($scope, args, $config, $q) {
         $scope.title = 'User :';
 
-        $scope.providers = ["local", "google", "ovh"];
+        $scope.providers = ["local", "google", "ovh", "oidc", "ldap"];
         $scope.edit = false;
         $scope.user = {};
         $scope.warning = null;
@@ -3,10 +3,11 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
     var api = {base: window.location.origin + window.location.pathname.replace(/\/$/, '')};
 
     // Make the actual HTTP call and return a promise
-    api.call = function (url, method, params, data, uploadToken) {
+    api.call = function (url, method, params, data, uploadToken, requestToken) {
         var promise = $q.defer();
         var headers = {};
         if (uploadToken) headers['X-UploadToken'] = uploadToken;
+        if (requestToken) headers['X-UploadRequestToken'] = requestToken;
         if (api.fake_user) headers['X-Plik-Impersonate'] = api.fake_user.id;
         $http({
             url: url,
@@ -26,10 +27,11 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
     };
 
     // Make the actual HTTP call to upload a file and return a promise
-    api.upload = function (url, file, progress_cb, basicAuth, uploadToken) {
+    api.upload = function (url, file, progress_cb, basicAuth, uploadToken, requestToken) {
         var promise = $q.defer();
         var headers = {};
         if (uploadToken) headers['X-UploadToken'] = uploadToken;
+        if (requestToken) headers['X-UploadRequestToken'] = requestToken;
         if (basicAuth) headers['Authorization'] = "Basic " + basicAuth;
 
         Upload
@@ -68,6 +70,12 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
         return api.call(url, 'DELETE', {}, {}, upload.uploadToken);
     };
 
+    // Send the upload link by email
+    api.notifyUpload = function (upload, params) {
+        var url = api.base + '/upload/' + upload.id + '/notify';
+        return api.call(url, 'POST', {}, params, upload.uploadToken);
+    };
+
     // Upload a file
     api.uploadFile = function (upload, file, progres_cb, basicAuth) {
         var mode = upload.stream ? "stream" : "file";
@@ -88,6 +96,18 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
         return api.call(url, 'DELETE', {}, {}, upload.uploadToken);
     };
 
+    // Get upload request
+    api.getUploadRequest = function (requestId, requestToken) {
+        var url = api.base + '/request/' + requestId;
+        return api.call(url, 'GET', {}, {}, null, requestToken);
+    };
+
+    // Send a file through an upload request
+    api.uploadRequestFile = function (requestId, requestToken, file, progress_cb) {
+        var url = api.base + '/request/' + requestId + '/file';
+        return api.upload(url, file, progress_cb, null, null, requestToken);
+    };
+
     // Log in
     api.login = function (provider, login, password) {
         var url = api.base + '/auth/' + provider + '/login';
@@ -134,6 +154,24 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
         return api.call(url, 'GET', {limit: limit, after: cursor});
     };
 
+    // Get user upload requests
+    api.getUserUploadRequests = function (limit, cursor) {
+        var url = api.base + '/me/request';
+        return api.call(url, 'GET', {limit: limit, after: cursor});
+    };
+
+    // Create a new upload request
+    api.createUploadRequest = function (request) {
+        var url = api.base + '/me/request';
+        return api.call(url, 'POST', {}, request);
+    };
+
+    // Remove an upload request
+    api.deleteUploadRequest = function (requestId) {
+        var url = api.base + '/me/request/' + requestId;
+        return api.call(url, 'DELETE');
+    };
+
     // Get user uploads
     api.getUserUploads = function (token, limit, cursor) {
         var url = api.base + '/me/uploads';
@@ -109,6 +109,30 @@ plik.controller('PasteController', ['$scope', 'args',
         };
     }]);
 
+// Send upload by email dialog controller
+plik.controller('EmailController', ['$scope', 'args',
+    function ($scope, args) {
+        // Ugly but it works
+        setTimeout(function () {
+            $("#to").focus();
+        }, 100);
+
+        $scope.title = 'Send by email';
+        $scope.to = '';
+        $scope.message = '';
+        $scope.downloadReceipt = false;
+        $scope.email = args.user ? args.user.email : undefined;
+
+        $scope.close = function (to, message, downloadReceipt) {
+            var recipients = _.compact(_.map(to.split(/[,;\s]+/), function (address) {
+                return address.trim();
+            }));
+            if (recipients.length) {
+                $scope.$close({to: recipients, message: message, downloadReceipt: downloadReceipt});
+            }
+        };
+    }]);
+
 // QRCode dialog controller
 plik.controller('QRCodeController', ['$scope', 'args',
     function ($scope, args) {